@@ -0,0 +1,408 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tar
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// An Index provides random access to the files of a tar archive
+// stored in an io.ReaderAt. It implements fs.FS, fs.ReadDirFS and fs.StatFS.
+//
+// The names of the files are the cleaned names of the archive entries,
+// without any leading slash. Entries whose names would refer outside
+// the archive, such as "../x", are omitted, as are entries of types
+// that do not describe files, such as TypeXGlobalHeader. If several
+// entries have the same name, the last one is used, as it would be
+// when extracting the archive. Directories implied by the names of
+// other entries are present even if the archive has no entry for them.
+//
+// Open and Stat follow symbolic links that lead to other files in the
+// archive; the entries returned by ReadDir describe the links themselves.
+// A hard link has the contents of its target. The Sys method of the
+// fs.FileInfo values returned by an Index returns the file's *Header,
+// or nil for a directory that has no entry of its own.
+type Index struct {
+	r     io.ReaderAt
+	files map[string]*indexEntry
+}
+
+// NewIndex returns an Index of the tar archive in r,
+// which is assumed to have the given size in bytes.
+// NewIndex reads the headers of all the entries in the archive;
+// the contents of the files are read only when they are opened.
+func NewIndex(r io.ReaderAt, size int64) (*Index, error) {
+	if size < 0 {
+		return nil, errors.New("archive/tar: size cannot be negative")
+	}
+	x := &Index{r: r, files: make(map[string]*indexEntry)}
+	sr := io.NewSectionReader(r, 0, size)
+	tr := NewReader(sr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name, ok := indexName(hdr.Name)
+		if !ok {
+			continue
+		}
+		e := &indexEntry{name: path.Base(name), hdr: hdr}
+		switch hdr.Typeflag {
+		case TypeReg, TypeGNUSparse, TypeCont:
+			// The data of the file starts at the current position.
+			if e.offset, err = sr.Seek(0, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			e.size = hdr.Size
+			if len(hdr.SparseHoles) > 0 || hdr.Typeflag == TypeGNUSparse {
+				e.spd = invertSparseEntries(append([]SparseEntry{}, hdr.SparseHoles...), hdr.Size)
+				if e.spd == nil {
+					e.spd = sparseDatas{}
+				}
+			}
+		case TypeLink:
+			target, ok := indexName(hdr.Linkname)
+			t := x.files[target]
+			if !ok || t == nil || t.hdr == nil || t.IsDir() || t.hdr.Typeflag == TypeSymlink {
+				continue // Not a link to a file we know about
+			}
+			e.offset, e.size, e.spd = t.offset, t.size, t.spd
+		case TypeSymlink, TypeChar, TypeBlock, TypeDir, TypeFifo:
+		default:
+			continue
+		}
+		if name == "." && !e.IsDir() {
+			continue
+		}
+		x.files[name] = e
+	}
+
+	// Add missing directories. Sorting the names means that
+	// the parents of a file have been checked before the file is.
+	names := make([]string, 0, len(x.files))
+	for name := range x.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if x.files["."] == nil {
+		x.files["."] = &indexEntry{name: "."}
+	}
+	for _, name := range names {
+		var missing []string
+		dir := path.Dir(name)
+		for x.files[dir] == nil {
+			missing = append(missing, dir)
+			dir = path.Dir(dir)
+		}
+		if !x.files[dir].IsDir() {
+			// A file cannot contain other files.
+			delete(x.files, name)
+			continue
+		}
+		for _, dir := range missing {
+			x.files[dir] = &indexEntry{name: path.Base(dir)}
+		}
+	}
+
+	// List the directories. Adding the files in order of their
+	// names sorts the lists.
+	names = names[:0]
+	for name := range x.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name != "." {
+			dir := x.files[path.Dir(name)]
+			dir.list = append(dir.list, x.files[name])
+		}
+	}
+	return x, nil
+}
+
+// indexName returns the name of the file with the given name
+// in the archive, and whether it has a valid name.
+func indexName(name string) (string, bool) {
+	name = path.Clean(name)
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	name = strings.TrimLeft(name, "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+const maxSymlinks = 40
+
+var errTooManyLinks = errors.New("archive/tar: too many levels of symbolic links")
+
+// lookup returns the entry for name, following symbolic links.
+func (x *Index) lookup(op, name string) (*indexEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	orig := name
+	links := 0
+walk:
+	for {
+		// Look up each element in turn, so that symbolic links
+		// to directories are followed.
+		for i := 0; i <= len(name); i++ {
+			if i < len(name) && name[i] != '/' {
+				continue
+			}
+			e := x.files[name[:i]]
+			if e == nil || (i < len(name) && !e.IsDir() && e.Type() != fs.ModeSymlink) {
+				return nil, &fs.PathError{Op: op, Path: orig, Err: fs.ErrNotExist}
+			}
+			if e.Type() != fs.ModeSymlink {
+				continue
+			}
+			if links++; links > maxSymlinks {
+				return nil, &fs.PathError{Op: op, Path: orig, Err: errTooManyLinks}
+			}
+			target := e.hdr.Linkname
+			if !path.IsAbs(target) {
+				target = path.Join(path.Dir(name[:i]), target)
+			}
+			target = path.Join(target, name[i:])
+			if !fs.ValidPath(target) {
+				// The link refers outside the archive.
+				return nil, &fs.PathError{Op: op, Path: orig, Err: fs.ErrNotExist}
+			}
+			name = target
+			continue walk
+		}
+		e := x.files[name]
+		if base := path.Base(orig); e.name != base {
+			// Describe the file by the name used to reach it.
+			e1 := *e
+			e1.name = base
+			e = &e1
+		}
+		return e, nil
+	}
+}
+
+// Open opens the named file.
+func (x *Index) Open(name string) (fs.File, error) {
+	e, err := x.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if e.IsDir() {
+		return &indexDir{e: e}, nil
+	}
+	if !e.Mode().IsRegular() {
+		return &indexFile{e: e}, nil
+	}
+	return &indexFile{e: e, r: x.r}, nil
+}
+
+// Stat returns a FileInfo describing the named file.
+func (x *Index) Stat(name string) (fs.FileInfo, error) {
+	e, err := x.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// ReadDir reads the named directory and returns
+// a list of directory entries sorted by filename.
+func (x *Index) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := x.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	list := make([]fs.DirEntry, len(e.list))
+	for i, c := range e.list {
+		list[i] = c
+	}
+	return list, nil
+}
+
+// An indexEntry is a file in an Index.
+// It implements fs.FileInfo and fs.DirEntry.
+type indexEntry struct {
+	name   string        // base name
+	hdr    *Header       // nil for a directory without an entry
+	offset int64         // offset of the contents in the archive
+	size   int64         // logical size of the contents
+	spd    sparseDatas   // data fragments of a sparse file, or nil
+	list   []*indexEntry // for a directory, its entries sorted by name
+}
+
+func (e *indexEntry) Name() string { return e.name }
+func (e *indexEntry) Size() int64  { return e.size }
+func (e *indexEntry) IsDir() bool  { return e.Mode().IsDir() }
+
+func (e *indexEntry) Mode() fs.FileMode {
+	if e.hdr == nil {
+		return fs.ModeDir | 0555
+	}
+	return headerFileInfo{e.hdr}.Mode()
+}
+
+func (e *indexEntry) ModTime() time.Time {
+	if e.hdr == nil {
+		return time.Time{}
+	}
+	return e.hdr.ModTime
+}
+
+func (e *indexEntry) Sys() interface{} {
+	if e.hdr == nil {
+		return nil
+	}
+	return e.hdr
+}
+
+func (e *indexEntry) Type() fs.FileMode          { return e.Mode().Type() }
+func (e *indexEntry) Info() (fs.FileInfo, error) { return e, nil }
+
+// readAt reads the contents of the file at offset off from r.
+func (e *indexEntry) readAt(r io.ReaderAt, b []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("archive/tar: negative offset")
+	}
+	if off >= e.size {
+		return 0, io.EOF
+	}
+	if rem := e.size - off; int64(len(b)) > rem {
+		b = b[:rem]
+		err = io.EOF
+	}
+	if e.spd == nil {
+		n, err1 := r.ReadAt(b, e.offset+off)
+		if n < len(b) {
+			if err1 == io.EOF || err1 == nil {
+				err1 = io.ErrUnexpectedEOF
+			}
+			return n, err1
+		}
+		return n, err
+	}
+
+	// Fill holes with NULs and copy in the fragments of data.
+	for i := range b {
+		b[i] = 0
+	}
+	end := off + int64(len(b))
+	phys := e.offset // offset of the current fragment in the archive
+	for _, s := range e.spd {
+		lo, hi := s.Offset, s.endOffset()
+		if lo < off {
+			lo = off
+		}
+		if hi > end {
+			hi = end
+		}
+		if lo < hi {
+			m, err1 := r.ReadAt(b[lo-off:hi-off], phys+lo-s.Offset)
+			if int64(m) < hi-lo {
+				if err1 == io.EOF || err1 == nil {
+					err1 = io.ErrUnexpectedEOF
+				}
+				return int(lo - off + int64(m)), err1
+			}
+		}
+		phys += s.Length
+	}
+	return len(b), err
+}
+
+// An indexFile is an open file from an Index.
+type indexFile struct {
+	e   *indexEntry
+	r   io.ReaderAt // nil if the file has no contents
+	off int64
+}
+
+func (f *indexFile) Stat() (fs.FileInfo, error) { return f.e, nil }
+func (f *indexFile) Close() error               { return nil }
+
+func (f *indexFile) Read(b []byte) (int, error) {
+	if f.r == nil {
+		return 0, io.EOF
+	}
+	n, err := f.e.readAt(f.r, b, f.off)
+	f.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *indexFile) ReadAt(b []byte, off int64) (int, error) {
+	if f.r == nil {
+		return 0, io.EOF
+	}
+	return f.e.readAt(f.r, b, off)
+}
+
+func (f *indexFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.e.size
+	default:
+		return 0, errors.New("archive/tar: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("archive/tar: negative position")
+	}
+	f.off = offset
+	return offset, nil
+}
+
+// An indexDir is an open directory from an Index.
+type indexDir struct {
+	e      *indexEntry
+	offset int
+}
+
+func (d *indexDir) Stat() (fs.FileInfo, error) { return d.e, nil }
+func (d *indexDir) Close() error               { return nil }
+
+func (d *indexDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.e.name, Err: errors.New("is a directory")}
+}
+
+func (d *indexDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	n := len(d.e.list) - d.offset
+	if count > 0 && n > count {
+		n = count
+	}
+	if n == 0 {
+		if count > 0 {
+			return nil, io.EOF
+		}
+		return []fs.DirEntry{}, nil
+	}
+	list := make([]fs.DirEntry, n)
+	for i := range list {
+		list[i] = d.e.list[d.offset+i]
+	}
+	d.offset += n
+	return list, nil
+}
@@ -0,0 +1,219 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tar
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// makeIndexTestArchive writes an archive with files of most kinds.
+// If badLinks is set, it includes symbolic links that fstest.TestFS
+// does not expect: to a directory, to outside the archive and to itself.
+func makeIndexTestArchive(t *testing.T, badLinks bool) []byte {
+	var buf bytes.Buffer
+	tw := NewWriter(&buf)
+	sparse := strings.Repeat("\x00", 100) + "data" + strings.Repeat("\x00", 100)
+	files := []struct {
+		hdr  Header
+		data string
+	}{
+		{Header{Name: "./", Typeflag: TypeDir, Mode: 0755}, ""},
+		{Header{Name: "a/b/file", Mode: 0644}, "file"},
+		{Header{Name: "a/b/old", Mode: 0644}, "replaced"},
+		{Header{Name: "a/b/old", Mode: 0644}, "old"},
+		{Header{Name: "/abs", Mode: 0644}, "abs"},
+		{Header{Name: "../escape", Mode: 0644}, "escape"},
+		{Header{Name: "dir/", Typeflag: TypeDir, Mode: 0755}, ""},
+		{Header{Name: strings.Repeat("long/", 30) + "name", Mode: 0644}, "long"},
+		{Header{Name: "hard", Typeflag: TypeLink, Linkname: "a/b/file"}, ""},
+		{Header{Name: "link", Typeflag: TypeSymlink, Linkname: "a/b/file"}, ""},
+		{Header{Name: "dir/up", Typeflag: TypeSymlink, Linkname: "../a/b/old"}, ""},
+		{Header{Name: "sparse-pax", Size: int64(len(sparse)), Mode: 0644,
+			SparseHoles: []SparseEntry{{0, 100}, {104, 100}}}, sparse},
+		{Header{Name: "sparse-gnu", Size: int64(len(sparse)), Mode: 0644, Typeflag: TypeGNUSparse,
+			SparseHoles: []SparseEntry{{0, 100}, {104, 100}}}, sparse},
+	}
+	if badLinks {
+		for _, link := range []Header{
+			{Name: "dirlink", Typeflag: TypeSymlink, Linkname: "a/./b"},
+			{Name: "outside", Typeflag: TypeSymlink, Linkname: "../x"},
+			{Name: "loop", Typeflag: TypeSymlink, Linkname: "loop"},
+		} {
+			files = append(files, struct {
+				hdr  Header
+				data string
+			}{link, ""})
+		}
+	}
+	for _, f := range files {
+		hdr := f.hdr
+		hdr.ModTime = time.Unix(1e9, 0)
+		hdr.Size = int64(len(f.data))
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatalf("WriteHeader(%q): %v", hdr.Name, err)
+		}
+		if _, err := io.WriteString(tw, f.data); err != nil {
+			t.Fatalf("Write(%q): %v", hdr.Name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestIndex(t *testing.T) {
+	data := makeIndexTestArchive(t, false)
+	x, err := NewIndex(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(x, "a/b/file", "a/b/old", "abs", "dir/up", "hard", "link",
+		strings.Repeat("long/", 30)+"name", "sparse-pax", "sparse-gnu"); err != nil {
+		t.Fatal(err)
+	}
+
+	data = makeIndexTestArchive(t, true)
+	x, err = NewIndex(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sparse := strings.Repeat("\x00", 100) + "data" + strings.Repeat("\x00", 100)
+	for name, want := range map[string]string{
+		"a/b/file":     "file",
+		"a/b/old":      "old",
+		"abs":          "abs",
+		"hard":         "file",
+		"link":         "file",
+		"dirlink/file": "file",
+		"dir/up":       "old",
+		"sparse-pax":   sparse,
+		"sparse-gnu":   sparse,
+	} {
+		got, err := fs.ReadFile(x, name)
+		if err != nil || string(got) != want {
+			t.Errorf("ReadFile(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"escape", "../escape", "outside", "loop", "missing", "a/b/file/x"} {
+		if _, err := x.Open(name); err == nil {
+			t.Errorf("Open(%q) succeeded, want error", name)
+		}
+	}
+
+	fi, err := x.Stat("link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Name() != "link" || !fi.Mode().IsRegular() || fi.Size() != 4 {
+		t.Errorf("Stat(link) = %q %v %d, want regular file link of size 4", fi.Name(), fi.Mode(), fi.Size())
+	}
+	if hdr, ok := fi.Sys().(*Header); !ok || hdr.Name != "a/b/file" {
+		t.Errorf("Stat(link).Sys() = %v, want header of a/b/file", fi.Sys())
+	}
+}
+
+func TestIndexSeek(t *testing.T) {
+	data := makeIndexTestArchive(t, false)
+	x, err := NewIndex(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := x.Open("sparse-gnu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		t.Fatal("file does not implement io.ReadSeeker")
+	}
+	if _, err := rs.Seek(98, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(rs, buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\x00\x00data\x00\x00"; string(buf) != want {
+		t.Errorf("read %q after Seek, want %q", buf, want)
+	}
+	if n, err := rs.Seek(-1, io.SeekEnd); err != nil || n != 203 {
+		t.Fatalf("Seek(-1, SeekEnd) = %d, %v", n, err)
+	}
+	if n, err := rs.Read(buf); n != 1 || (err != nil && err != io.EOF) {
+		t.Errorf("Read at end = %d, %v", n, err)
+	}
+	if _, err := rs.Read(buf); err != io.EOF {
+		t.Errorf("Read past end: %v, want EOF", err)
+	}
+}
+
+func TestIndexFormats(t *testing.T) {
+	// The Index must read the same contents as a Reader.
+	for _, file := range []string{"testdata/sparse-formats.tar", "testdata/gnu.tar", "testdata/pax.tar", "testdata/gnu-incremental.tar"} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, err := NewIndex(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		tr := NewReader(bytes.NewReader(data))
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hdr.Typeflag != TypeReg && hdr.Typeflag != TypeGNUSparse {
+				continue
+			}
+			want, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := fs.ReadFile(x, hdr.Name)
+			if err != nil {
+				t.Errorf("%s: %v", file, err)
+				continue
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: %s: contents differ from Reader", file, hdr.Name)
+			}
+		}
+	}
+}
+
+func TestIndexTruncated(t *testing.T) {
+	data := makeIndexTestArchive(t, false)
+	data = data[:700]
+	if _, err := NewIndex(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Error("NewIndex of truncated archive succeeded")
+	}
+	if _, err := NewIndex(bytes.NewReader(nil), -1); err == nil {
+		t.Error("NewIndex with negative size succeeded")
+	}
+	var pe *fs.PathError
+	x, err := NewIndex(bytes.NewReader(nil), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := x.Open("missing"); !errors.As(err, &pe) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open in empty archive: %v, want PathError wrapping ErrNotExist", err)
+	}
+}
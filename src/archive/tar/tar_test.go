@@ -98,94 +98,94 @@ func (f *testFile) Seek(pos int64, whence int) (int64, error) {
 	return f.pos, nil
 }
 
-func equalSparseEntries(x, y []sparseEntry) bool {
+func equalSparseEntries(x, y []SparseEntry) bool {
 	return (len(x) == 0 && len(y) == 0) || reflect.DeepEqual(x, y)
 }
 
 func TestSparseEntries(t *testing.T) {
 	vectors := []struct {
-		in   []sparseEntry
+		in   []SparseEntry
 		size int64
 
 		wantValid    bool          // Result of validateSparseEntries
-		wantAligned  []sparseEntry // Result of alignSparseEntries
-		wantInverted []sparseEntry // Result of invertSparseEntries
+		wantAligned  []SparseEntry // Result of alignSparseEntries
+		wantInverted []SparseEntry // Result of invertSparseEntries
 	}{{
-		in: []sparseEntry{}, size: 0,
+		in: []SparseEntry{}, size: 0,
 		wantValid:    true,
-		wantInverted: []sparseEntry{{0, 0}},
+		wantInverted: []SparseEntry{{0, 0}},
 	}, {
-		in: []sparseEntry{}, size: 5000,
+		in: []SparseEntry{}, size: 5000,
 		wantValid:    true,
-		wantInverted: []sparseEntry{{0, 5000}},
+		wantInverted: []SparseEntry{{0, 5000}},
 	}, {
-		in: []sparseEntry{{0, 5000}}, size: 5000,
+		in: []SparseEntry{{0, 5000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 5000}},
-		wantInverted: []sparseEntry{{5000, 0}},
+		wantAligned:  []SparseEntry{{0, 5000}},
+		wantInverted: []SparseEntry{{5000, 0}},
 	}, {
-		in: []sparseEntry{{1000, 4000}}, size: 5000,
+		in: []SparseEntry{{1000, 4000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{1024, 3976}},
-		wantInverted: []sparseEntry{{0, 1000}, {5000, 0}},
+		wantAligned:  []SparseEntry{{1024, 3976}},
+		wantInverted: []SparseEntry{{0, 1000}, {5000, 0}},
 	}, {
-		in: []sparseEntry{{0, 3000}}, size: 5000,
+		in: []SparseEntry{{0, 3000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 2560}},
-		wantInverted: []sparseEntry{{3000, 2000}},
+		wantAligned:  []SparseEntry{{0, 2560}},
+		wantInverted: []SparseEntry{{3000, 2000}},
 	}, {
-		in: []sparseEntry{{3000, 2000}}, size: 5000,
+		in: []SparseEntry{{3000, 2000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{3072, 1928}},
-		wantInverted: []sparseEntry{{0, 3000}, {5000, 0}},
+		wantAligned:  []SparseEntry{{3072, 1928}},
+		wantInverted: []SparseEntry{{0, 3000}, {5000, 0}},
 	}, {
-		in: []sparseEntry{{2000, 2000}}, size: 5000,
+		in: []SparseEntry{{2000, 2000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{2048, 1536}},
-		wantInverted: []sparseEntry{{0, 2000}, {4000, 1000}},
+		wantAligned:  []SparseEntry{{2048, 1536}},
+		wantInverted: []SparseEntry{{0, 2000}, {4000, 1000}},
 	}, {
-		in: []sparseEntry{{0, 2000}, {8000, 2000}}, size: 10000,
+		in: []SparseEntry{{0, 2000}, {8000, 2000}}, size: 10000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 1536}, {8192, 1808}},
-		wantInverted: []sparseEntry{{2000, 6000}, {10000, 0}},
+		wantAligned:  []SparseEntry{{0, 1536}, {8192, 1808}},
+		wantInverted: []SparseEntry{{2000, 6000}, {10000, 0}},
 	}, {
-		in: []sparseEntry{{0, 2000}, {2000, 2000}, {4000, 0}, {4000, 3000}, {7000, 1000}, {8000, 0}, {8000, 2000}}, size: 10000,
+		in: []SparseEntry{{0, 2000}, {2000, 2000}, {4000, 0}, {4000, 3000}, {7000, 1000}, {8000, 0}, {8000, 2000}}, size: 10000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 1536}, {2048, 1536}, {4096, 2560}, {7168, 512}, {8192, 1808}},
-		wantInverted: []sparseEntry{{10000, 0}},
+		wantAligned:  []SparseEntry{{0, 1536}, {2048, 1536}, {4096, 2560}, {7168, 512}, {8192, 1808}},
+		wantInverted: []SparseEntry{{10000, 0}},
 	}, {
-		in: []sparseEntry{{0, 0}, {1000, 0}, {2000, 0}, {3000, 0}, {4000, 0}, {5000, 0}}, size: 5000,
+		in: []SparseEntry{{0, 0}, {1000, 0}, {2000, 0}, {3000, 0}, {4000, 0}, {5000, 0}}, size: 5000,
 		wantValid:    true,
-		wantInverted: []sparseEntry{{0, 5000}},
+		wantInverted: []SparseEntry{{0, 5000}},
 	}, {
-		in: []sparseEntry{{1, 0}}, size: 0,
+		in: []SparseEntry{{1, 0}}, size: 0,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{-1, 0}}, size: 100,
+		in: []SparseEntry{{-1, 0}}, size: 100,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{0, -1}}, size: 100,
+		in: []SparseEntry{{0, -1}}, size: 100,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{0, 0}}, size: -100,
+		in: []SparseEntry{{0, 0}}, size: -100,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{math.MaxInt64, 3}, {6, -5}}, size: 35,
+		in: []SparseEntry{{math.MaxInt64, 3}, {6, -5}}, size: 35,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{1, 3}, {6, -5}}, size: 35,
+		in: []SparseEntry{{1, 3}, {6, -5}}, size: 35,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{math.MaxInt64, math.MaxInt64}}, size: math.MaxInt64,
+		in: []SparseEntry{{math.MaxInt64, math.MaxInt64}}, size: math.MaxInt64,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{3, 3}}, size: 5,
+		in: []SparseEntry{{3, 3}}, size: 5,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{2, 0}, {1, 0}, {0, 0}}, size: 3,
+		in: []SparseEntry{{2, 0}, {1, 0}, {0, 0}}, size: 3,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{1, 3}, {2, 2}}, size: 10,
+		in: []SparseEntry{{1, 3}, {2, 2}}, size: 10,
 		wantValid: false,
 	}}
 
@@ -197,11 +197,11 @@ func TestSparseEntries(t *testing.T) {
 		if !v.wantValid {
 			continue
 		}
-		gotAligned := alignSparseEntries(append([]sparseEntry{}, v.in...), v.size)
+		gotAligned := alignSparseEntries(append([]SparseEntry{}, v.in...), v.size)
 		if !equalSparseEntries(gotAligned, v.wantAligned) {
 			t.Errorf("test %d, alignSparseEntries():\ngot  %v\nwant %v", i, gotAligned, v.wantAligned)
 		}
-		gotInverted := invertSparseEntries(append([]sparseEntry{}, v.in...), v.size)
+		gotInverted := invertSparseEntries(append([]SparseEntry{}, v.in...), v.size)
 		if !equalSparseEntries(gotInverted, v.wantInverted) {
 			t.Errorf("test %d, inverseSparseEntries():\ngot  %v\nwant %v", i, gotInverted, v.wantInverted)
 		}
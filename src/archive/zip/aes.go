@@ -0,0 +1,215 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zip
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash"
+	"io"
+)
+
+// WinZip AES encryption.
+// See https://www.winzip.com/en/support/aes-encryption/.
+//
+// The data of an encrypted file consists of a salt, a two-byte
+// password verification value, the encrypted data, and a ten-byte
+// authentication code. The encryption key, the authentication key
+// and the verification value are derived from the password and salt
+// with PBKDF2-HMAC-SHA1. The data is encrypted with AES in counter mode,
+// using a little-endian counter starting at 1, and authenticated
+// with HMAC-SHA1 over the encrypted data.
+
+const (
+	aesMethod     = 99   // compression method of AES encrypted files
+	aesVerifyLen  = 2    // length of the password verification value
+	aesMACLen     = 10   // length of the authentication code
+	aesIterations = 1000 // PBKDF2 iterations
+)
+
+// aesExtra holds the contents of the WinZip AES extra field.
+type aesExtra struct {
+	version  uint16 // 1 for AE-1, 2 for AE-2, which does not store a CRC
+	strength uint8  // 1, 2 or 3 for 128, 192 or 256 bit keys
+	method   uint16 // compression method of the unencrypted data
+}
+
+// keyLen returns the length of the AES key in bytes,
+// or 0 if the strength is not valid.
+func (e *aesExtra) keyLen() int {
+	switch e.strength {
+	case 1:
+		return 16
+	case 2:
+		return 24
+	case 3:
+		return 32
+	}
+	return 0
+}
+
+// aesKeys derives the encryption key, authentication key
+// and password verification value from password and salt.
+func aesKeys(password string, salt []byte, keyLen int) (encKey, macKey, verify []byte) {
+	dk := pbkdf2([]byte(password), salt, aesIterations, 2*keyLen+aesVerifyLen)
+	return dk[:keyLen], dk[keyLen : 2*keyLen], dk[2*keyLen:]
+}
+
+// newAESReader returns a reader of the decrypted contents of the
+// size bytes of encrypted file data in r. It reports ErrPassword if the
+// password does not match the verification value. The returned reader
+// reports ErrChecksum if the authentication code does not match.
+func newAESReader(r io.Reader, size int64, e *aesExtra, password string) (*aesReader, error) {
+	keyLen := e.keyLen()
+	if keyLen == 0 {
+		return nil, ErrAlgorithm
+	}
+	saltLen := keyLen / 2
+	if size < int64(saltLen+aesVerifyLen+aesMACLen) {
+		return nil, ErrFormat
+	}
+	buf := make([]byte, saltLen+aesVerifyLen)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	encKey, macKey, verify := aesKeys(password, buf[:saltLen], keyLen)
+	if !hmac.Equal(verify, buf[saltLen:]) {
+		return nil, ErrPassword
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	return &aesReader{
+		r:      r,
+		n:      size - int64(len(buf)+aesMACLen),
+		stream: newAESStream(block),
+		mac:    hmac.New(sha1.New, macKey),
+	}, nil
+}
+
+type aesReader struct {
+	r      io.Reader
+	n      int64 // encrypted bytes remaining
+	stream cipher.Stream
+	mac    hash.Hash
+	err    error // sticky error
+}
+
+func (r *aesReader) Read(b []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.n == 0 {
+		r.err = r.checkMAC()
+		return 0, r.err
+	}
+	if int64(len(b)) > r.n {
+		b = b[:r.n]
+	}
+	n, err := r.r.Read(b)
+	r.mac.Write(b[:n])
+	r.stream.XORKeyStream(b[:n], b[:n])
+	r.n -= int64(n)
+	if err == io.EOF && r.n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// finish reads any encrypted data the caller left unread, such as
+// data after the end of a compressed stream, and checks the
+// authentication code. It returns nil if the code matches.
+func (r *aesReader) finish() error {
+	if r.err == nil {
+		io.Copy(io.Discard, r)
+	}
+	if r.err == io.EOF {
+		return nil
+	}
+	return r.err
+}
+
+func (r *aesReader) checkMAC() error {
+	var want [aesMACLen]byte
+	if _, err := io.ReadFull(r.r, want[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if !hmac.Equal(r.mac.Sum(nil)[:aesMACLen], want[:]) {
+		return ErrChecksum
+	}
+	return io.EOF
+}
+
+// aesStream is AES in counter mode with a little-endian counter,
+// as used by WinZip. It differs from cipher.NewCTR, which
+// increments the counter as a big-endian number.
+type aesStream struct {
+	block   cipher.Block
+	counter uint64
+	ctr     [aes.BlockSize]byte
+	key     [aes.BlockSize]byte
+	used    int // bytes of key already used
+}
+
+func newAESStream(block cipher.Block) *aesStream {
+	return &aesStream{block: block, used: aes.BlockSize}
+}
+
+func (s *aesStream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.used == aes.BlockSize {
+			s.counter++
+			binary.LittleEndian.PutUint64(s.ctr[:], s.counter)
+			s.block.Encrypt(s.key[:], s.ctr[:])
+			s.used = 0
+		}
+		dst[i] = src[i] ^ s.key[s.used]
+		s.used++
+	}
+}
+
+// pbkdf2 derives a key of length keyLen from password and salt
+// using PBKDF2 with HMAC-SHA1, as described in RFC 8018.
+func pbkdf2(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha1.New, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range u {
+				t[i] ^= u[i]
+			}
+		}
+	}
+	return dk[:keyLen]
+}
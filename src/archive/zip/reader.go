@@ -24,6 +24,7 @@ var (
 	ErrFormat    = errors.New("zip: not a valid zip file")
 	ErrAlgorithm = errors.New("zip: unsupported compression algorithm")
 	ErrChecksum  = errors.New("zip: checksum error")
+	ErrPassword  = errors.New("zip: missing or incorrect password")
 )
 
 // A Reader serves content from a ZIP archive.
@@ -32,6 +33,8 @@ type Reader struct {
 	File          []*File
 	Comment       string
 	decompressors map[uint16]Decompressor
+	password      func(f *File) (string, error)
+	dirOffset     int64 // offset of the central directory
 
 	// fileList is a list of files sorted by ename,
 	// for use by the Open method.
@@ -54,10 +57,7 @@ type File struct {
 	zipr         io.ReaderAt
 	zipsize      int64
 	headerOffset int64
-}
-
-func (f *File) hasDataDescriptor() bool {
-	return f.Flags&0x8 != 0
+	aes          *aesExtra // WinZip AES encryption parameters, if any
 }
 
 // OpenReader will open the Zip file specified by name and return a ReadCloser.
@@ -109,6 +109,7 @@ func (z *Reader) init(r io.ReaderAt, size int64) error {
 		z.File = make([]*File, 0, end.directoryRecords)
 	}
 	z.Comment = end.comment
+	z.dirOffset = int64(end.directoryOffset)
 	rs := io.NewSectionReader(r, 0, size)
 	if _, err = rs.Seek(int64(end.directoryOffset), io.SeekStart); err != nil {
 		return err
@@ -156,6 +157,14 @@ func (z *Reader) decompressor(method uint16) Decompressor {
 	return dcomp
 }
 
+// SetPasswordFunc sets the function that Open calls to obtain the password
+// of an encrypted file. Files encrypted with WinZip AES encryption can be read;
+// opening one fails with ErrPassword if no function has been set or
+// the password is incorrect. An error returned by fn is returned by Open.
+func (z *Reader) SetPasswordFunc(fn func(f *File) (password string, err error)) {
+	z.password = fn
+}
+
 // Close closes the Zip file, rendering it unusable for I/O.
 func (rc *ReadCloser) Close() error {
 	return rc.f.Close()
@@ -176,14 +185,37 @@ func (f *File) DataOffset() (offset int64, err error) {
 
 // Open returns a ReadCloser that provides access to the File's contents.
 // Multiple files may be read concurrently.
+//
+// If the file is encrypted, Open decrypts it using the password
+// returned by the function set with SetPasswordFunc.
 func (f *File) Open() (io.ReadCloser, error) {
 	bodyOffset, err := f.findBodyOffset()
 	if err != nil {
 		return nil, err
 	}
 	size := int64(f.CompressedSize64)
-	r := io.NewSectionReader(f.zipr, f.headerOffset+bodyOffset, size)
-	dcomp := f.zip.decompressor(f.Method)
+	var r io.Reader = io.NewSectionReader(f.zipr, f.headerOffset+bodyOffset, size)
+	method := f.Method
+	var ar *aesReader
+	if method == aesMethod {
+		if f.aes == nil {
+			return nil, ErrFormat
+		}
+		if f.zip.password == nil {
+			return nil, ErrPassword
+		}
+		password, err := f.zip.password(f)
+		if err != nil {
+			return nil, err
+		}
+		ar, err = newAESReader(r, size, f.aes, password)
+		if err != nil {
+			return nil, err
+		}
+		r = ar
+		method = f.aes.method
+	}
+	dcomp := f.zip.decompressor(method)
 	if dcomp == nil {
 		return nil, ErrAlgorithm
 	}
@@ -197,17 +229,34 @@ func (f *File) Open() (io.ReadCloser, error) {
 		hash: crc32.NewIEEE(),
 		f:    f,
 		desr: desr,
+		aes:  ar,
+		// AE-2 files do not store a CRC; the authentication
+		// code checked by the AES reader protects the data instead.
+		nocrc: f.Method == aesMethod && f.aes.version == 2,
 	}
 	return rc, nil
 }
 
+// OpenRaw returns a Reader that provides access to the File's contents
+// without decompression or decryption.
+func (f *File) OpenRaw() (io.Reader, error) {
+	bodyOffset, err := f.findBodyOffset()
+	if err != nil {
+		return nil, err
+	}
+	r := io.NewSectionReader(f.zipr, f.headerOffset+bodyOffset, int64(f.CompressedSize64))
+	return r, nil
+}
+
 type checksumReader struct {
 	rc    io.ReadCloser
 	hash  hash.Hash32
 	nread uint64 // number of bytes read so far
 	f     *File
-	desr  io.Reader // if non-nil, where to read the data descriptor
-	err   error     // sticky error
+	desr  io.Reader  // if non-nil, where to read the data descriptor
+	aes   *aesReader // if non-nil, the decrypted data to authenticate
+	nocrc bool       // the file has no CRC to check
+	err   error      // sticky error
 }
 
 func (r *checksumReader) Stat() (fs.FileInfo, error) {
@@ -225,6 +274,15 @@ func (r *checksumReader) Read(b []byte) (n int, err error) {
 		return
 	}
 	if err == io.EOF {
+		// A decompressor may stop at the end of its stream without
+		// reading to the end of the encrypted data, where the
+		// authentication code is checked.
+		if r.aes != nil {
+			if err1 := r.aes.finish(); err1 != nil {
+				r.err = err1
+				return n, err1
+			}
+		}
 		if r.nread != r.f.UncompressedSize64 {
 			return 0, io.ErrUnexpectedEOF
 		}
@@ -235,14 +293,14 @@ func (r *checksumReader) Read(b []byte) (n int, err error) {
 				} else {
 					err = err1
 				}
-			} else if r.hash.Sum32() != r.f.CRC32 {
+			} else if !r.nocrc && r.hash.Sum32() != r.f.CRC32 {
 				err = ErrChecksum
 			}
 		} else {
 			// If there's not a data descriptor, we still compare
 			// the CRC32 of what we've read against the file header
 			// or TOC's CRC32, if it seems like it was set.
-			if r.f.CRC32 != 0 && r.hash.Sum32() != r.f.CRC32 {
+			if !r.nocrc && r.f.CRC32 != 0 && r.hash.Sum32() != r.f.CRC32 {
 				err = ErrChecksum
 			}
 		}
@@ -405,6 +463,17 @@ parseExtras:
 			}
 			ts := int64(fieldBuf.uint32()) // ModTime since Unix epoch
 			modified = time.Unix(ts, 0)
+		case winzipAESExtraID:
+			if len(fieldBuf) < 7 {
+				continue parseExtras
+			}
+			e := &aesExtra{version: fieldBuf.uint16()}
+			if vendor := fieldBuf.uint16(); vendor != 'A'|'E'<<8 {
+				continue parseExtras
+			}
+			e.strength = fieldBuf.uint8()
+			e.method = fieldBuf.uint16()
+			f.aes = e
 		}
 	}
 
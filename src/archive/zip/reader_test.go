@@ -486,6 +486,18 @@ var tests = []ZipTest{
 			},
 		},
 	},
+	{
+		// Zstandard compressed, method 93
+		Name: "zstd.zip",
+		File: []ZipTestFile{
+			{
+				Name:     "zstd.txt",
+				Content:  bytes.Repeat([]byte("Zstandard compressed text.\n"), 20),
+				Modified: time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+				Mode:     0644,
+			},
+		},
+	},
 }
 
 func TestReader(t *testing.T) {
@@ -1243,3 +1255,125 @@ func TestCVE202139293(t *testing.T) {
 		t.Fatalf("unexpected error, got: %v, want: %v", err, ErrFormat)
 	}
 }
+
+func TestAES(t *testing.T) {
+	for _, name := range []string{"winzip-aes128.zip", "winzip-aes256.zip"} {
+		r, err := OpenReader(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		f := r.File[0]
+
+		if _, err := f.Open(); err != ErrPassword {
+			t.Errorf("%s: Open without password: %v, want ErrPassword", name, err)
+		}
+		r.SetPasswordFunc(func(*File) (string, error) { return "wrong", nil })
+		if _, err := f.Open(); err != ErrPassword {
+			t.Errorf("%s: Open with wrong password: %v, want ErrPassword", name, err)
+		}
+
+		var asked string
+		r.SetPasswordFunc(func(f *File) (string, error) {
+			asked = f.Name
+			return "golang", nil
+		})
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if want := "This is a test text file.\n"; string(got) != want {
+			t.Errorf("%s: read %q, want %q", name, got, want)
+		}
+		if asked != "test.txt" {
+			t.Errorf("%s: password requested for %q, want %q", name, asked, "test.txt")
+		}
+	}
+}
+
+func TestAESCorrupt(t *testing.T) {
+	data, err := os.ReadFile("testdata/winzip-aes128.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	off, err := r.File[0].DataOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flip a bit of the encrypted data, after the salt
+	// and password verification value.
+	data[off+16+2] ^= 1
+	r.SetPasswordFunc(func(*File) (string, error) { return "golang", nil })
+	rc, err := r.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, err := io.ReadAll(rc); err != ErrChecksum {
+		t.Errorf("reading corrupt file: %v, want ErrChecksum", err)
+	}
+}
+
+func TestAESBadMAC(t *testing.T) {
+	// The deflated entry is read to the end of the compressed
+	// stream, which does not read the authentication code.
+	for _, version := range []uint16{1, 2} {
+		data, err := os.ReadFile("testdata/winzip-aes256.zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := r.File[0]
+		if f.aes.method != Deflate {
+			t.Fatalf("method = %d, want Deflate", f.aes.method)
+		}
+		f.aes.version = version
+		off, err := f.DataOffset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[off+int64(f.CompressedSize64)-1] ^= 1
+		r.SetPasswordFunc(func(*File) (string, error) { return "golang", nil })
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(rc); err != ErrChecksum {
+			t.Errorf("AE-%d: reading file with bad authentication code: %v, want ErrChecksum", version, err)
+		}
+		rc.Close()
+	}
+}
+
+func TestOpenRaw(t *testing.T) {
+	r, err := OpenReader("testdata/test.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		raw, err := f.OpenRaw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uint64(len(data)) != f.CompressedSize64 {
+			t.Errorf("%s: read %d raw bytes, want %d", f.Name, len(data), f.CompressedSize64)
+		}
+	}
+}
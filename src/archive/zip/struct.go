@@ -9,6 +9,9 @@ See: https://www.pkware.com/appnote
 
 This package does not support disk spanning.
 
+Files encrypted with WinZip AES encryption can be read, given a password;
+see Reader.SetPasswordFunc. Writing encrypted files is not supported.
+
 A note about ZIP64:
 
 To be backwards compatible the FileHeader has both 32 and 64 bit Size
@@ -27,8 +30,9 @@ import (
 
 // Compression methods.
 const (
-	Store   uint16 = 0 // no compression
-	Deflate uint16 = 8 // DEFLATE compressed
+	Store   uint16 = 0  // no compression
+	Deflate uint16 = 8  // DEFLATE compressed
+	Zstd    uint16 = 93 // Zstandard compressed
 )
 
 const (
@@ -75,6 +79,7 @@ const (
 	unixExtraID        = 0x000d // UNIX
 	extTimeExtraID     = 0x5455 // Extended timestamp
 	infoZipUnixExtraID = 0x5855 // Info-ZIP Unix extension
+	winzipAESExtraID   = 0x9901 // WinZip AES encryption
 )
 
 // FileHeader describes a file within a zip file.
@@ -122,8 +127,9 @@ type FileHeader struct {
 	// If only the MS-DOS date is present, the timezone is assumed to be UTC.
 	//
 	// When writing, an extended timestamp (which is timezone-agnostic) is
-	// always emitted. The legacy MS-DOS date field is encoded according to the
-	// location of the Modified time.
+	// always emitted, replacing any timestamps in Extra, together with an
+	// NTFS timestamp if the time has a fractional second. The legacy MS-DOS
+	// date field is encoded according to the location of the Modified time.
 	Modified     time.Time
 	ModifiedTime uint16 // Deprecated: Legacy MS-DOS date; use Modified instead.
 	ModifiedDate uint16 // Deprecated: Legacy MS-DOS time; use Modified instead.
@@ -315,6 +321,12 @@ func (h *FileHeader) isZip64() bool {
 	return h.CompressedSize64 >= uint32max || h.UncompressedSize64 >= uint32max
 }
 
+// hasDataDescriptor reports whether the CRC and sizes of the file
+// are stored in a data descriptor following its data.
+func (h *FileHeader) hasDataDescriptor() bool {
+	return h.Flags&0x8 != 0
+}
+
 func msdosModeToFileMode(m uint32) (mode fs.FileMode) {
 	if m&msdosDir != 0 {
 		mode = fs.ModeDir | 0777
@@ -12,6 +12,7 @@ import (
 	"hash/crc32"
 	"io"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -37,6 +38,7 @@ type Writer struct {
 type header struct {
 	*FileHeader
 	offset uint64
+	raw    bool
 }
 
 // NewWriter returns a new Writer writing a zip file to w.
@@ -44,6 +46,40 @@ func NewWriter(w io.Writer) *Writer {
 	return &Writer{cw: &countWriter{w: bufio.NewWriter(w)}}
 }
 
+// NewAppendWriter returns a Writer that adds files to the existing
+// archive read by r. The archive is modified in place through w,
+// which must write to the same storage that r reads from,
+// such as the *os.File underlying r.
+//
+// The data of the files already in the archive is neither read nor
+// rewritten. New files are written over the old central directory,
+// and Close writes a new central directory listing both the existing
+// and the added files, followed by the archive comment, which is kept
+// unless changed with SetComment. Until Close returns, the archive is
+// not valid. The existing files may still be read through r, for
+// example to Copy them, but files added to the archive are not visible
+// through it.
+//
+// The new archive may be shorter than the old one, for example if
+// SetComment shortens the comment. Since w cannot be truncated through
+// the io.WriterAt interface, the caller must truncate the storage to
+// the size reported by Offset after Close, as with (*os.File).Truncate.
+func NewAppendWriter(r *Reader, w io.WriterAt) *Writer {
+	zw := &Writer{
+		cw:      &countWriter{w: bufio.NewWriter(&offsetWriter{w: w, off: r.dirOffset}), count: r.dirOffset},
+		comment: r.Comment,
+	}
+	for _, f := range r.File {
+		fh := f.FileHeader
+		// Close writes its own zip64 extra field as needed.
+		fh.Extra = removeExtra(fh.Extra, zip64ExtraID)
+		fh.CompressedSize = uint32(min64(fh.CompressedSize64, uint32max))
+		fh.UncompressedSize = uint32(min64(fh.UncompressedSize64, uint32max))
+		zw.dir = append(zw.dir, &header{FileHeader: &fh, offset: uint64(f.headerOffset), raw: true})
+	}
+	return zw
+}
+
 // SetOffset sets the offset of the beginning of the zip data within the
 // underlying writer. It should be used when the zip data is appended to an
 // existing file, such as a binary executable.
@@ -55,6 +91,13 @@ func (w *Writer) SetOffset(n int64) {
 	w.cw.count = n
 }
 
+// Offset returns the offset within the underlying writer of the end of
+// the data written so far, including any offset set with SetOffset.
+// After Close, it is the size of the zip file.
+func (w *Writer) Offset() int64 {
+	return w.cw.count
+}
+
 // Flush flushes any buffered data to the underlying writer.
 // Calling Flush is not normally necessary; calling Close is sufficient.
 func (w *Writer) Flush() error {
@@ -213,7 +256,7 @@ func (w *Writer) Close() error {
 // allowed. To create a directory instead of a file, add a trailing
 // slash to the name.
 // The file's contents must be written to the io.Writer before the next
-// call to Create, CreateHeader, or Close.
+// call to Create, CreateHeader, CreateRaw, or Close.
 func (w *Writer) Create(name string) (io.Writer, error) {
 	header := &FileHeader{
 		Name:   name,
@@ -251,16 +294,10 @@ func detectUTF8(s string) (valid, require bool) {
 //
 // This returns a Writer to which the file contents should be written.
 // The file's contents must be written to the io.Writer before the next
-// call to Create, CreateHeader, or Close.
+// call to Create, CreateHeader, CreateRaw, or Close.
 func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
-	if w.last != nil && !w.last.closed {
-		if err := w.last.close(); err != nil {
-			return nil, err
-		}
-	}
-	if len(w.dir) > 0 && w.dir[len(w.dir)-1].FileHeader == fh {
-		// See https://golang.org/issue/11144 confusion.
-		return nil, errors.New("archive/zip: invalid duplicate FileHeader")
+	if err := w.prepare(fh); err != nil {
+		return nil, err
 	}
 
 	// The ZIP format has a sad state of affairs regarding character encoding.
@@ -303,6 +340,14 @@ func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
 		// field directly themselves. All other approaches sets UTC.
 		fh.ModifiedDate, fh.ModifiedTime = timeToMsDosTime(fh.Modified)
 
+		// Drop the timestamp fields written below if they are already in
+		// Extra, such as in a header read from another archive, so that
+		// they cannot contradict Modified. The Unix fields also hold
+		// other data, such as owners and link targets, and are kept;
+		// the fields written below come later and so take precedence
+		// when reading.
+		fh.Extra = removeExtra(fh.Extra, ntfsExtraID, extTimeExtraID)
+
 		// Use "extended timestamp" format since this is what Info-ZIP uses.
 		// Nearly every major ZIP implementation uses a different format,
 		// but at least most seem to be able to understand the other formats.
@@ -317,6 +362,29 @@ func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
 		eb.uint8(1)   // Flags: ModTime
 		eb.uint32(mt) // ModTime
 		fh.Extra = append(fh.Extra, mbuf[:]...)
+
+		// The extended timestamp has a resolution of one second.
+		// Record finer times in an NTFS extra field as well,
+		// which comes later and so takes precedence when reading.
+		// Windows timestamps cannot represent times before 1601.
+		epoch := time.Date(1601, time.January, 1, 0, 0, 0, 0, time.UTC)
+		if fh.Modified.Nanosecond() != 0 && !fh.Modified.Before(epoch) {
+			const ticksPerSecond = 1e7 // Windows timestamp resolution
+			secs := fh.Modified.Unix() - epoch.Unix()
+			ts := uint64(secs)*ticksPerSecond + uint64(fh.Modified.Nanosecond())/(1e9/ticksPerSecond)
+
+			var nbuf [36]byte // 2*SizeOf(uint16) + SizeOf(uint32) + 2*SizeOf(uint16) + 3*SizeOf(uint64)
+			eb := writeBuf(nbuf[:])
+			eb.uint16(ntfsExtraID)
+			eb.uint16(32) // Size: SizeOf(uint32) + 2*SizeOf(uint16) + 3*SizeOf(uint64)
+			eb.uint32(0)  // Reserved
+			eb.uint16(1)  // Tag: timestamps
+			eb.uint16(24) // Size: 3*SizeOf(uint64)
+			eb.uint64(ts) // ModTime
+			eb.uint64(ts) // AcTime
+			eb.uint64(ts) // CrTime
+			fh.Extra = append(fh.Extra, nbuf[:]...)
+		}
 	}
 
 	var (
@@ -365,7 +433,7 @@ func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
 		ow = fw
 	}
 	w.dir = append(w.dir, h)
-	if err := writeHeader(w.cw, fh); err != nil {
+	if err := writeHeader(w.cw, h); err != nil {
 		return nil, err
 	}
 	// If we're creating a directory, fw is nil.
@@ -373,7 +441,22 @@ func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
 	return ow, nil
 }
 
-func writeHeader(w io.Writer, h *FileHeader) error {
+// prepare finishes the previous file and checks fh
+// before a new file is added to the archive.
+func (w *Writer) prepare(fh *FileHeader) error {
+	if w.last != nil && !w.last.closed {
+		if err := w.last.close(); err != nil {
+			return err
+		}
+	}
+	if len(w.dir) > 0 && w.dir[len(w.dir)-1].FileHeader == fh {
+		// See https://golang.org/issue/11144 confusion.
+		return errors.New("archive/zip: invalid duplicate FileHeader")
+	}
+	return nil
+}
+
+func writeHeader(w io.Writer, h *header) error {
 	const maxUint16 = 1<<16 - 1
 	if len(h.Name) > maxUint16 {
 		return errLongName
@@ -390,18 +473,104 @@ func writeHeader(w io.Writer, h *FileHeader) error {
 	b.uint16(h.Method)
 	b.uint16(h.ModifiedTime)
 	b.uint16(h.ModifiedDate)
-	b.uint32(0) // since we are writing a data descriptor crc32,
-	b.uint32(0) // compressed size,
-	b.uint32(0) // and uncompressed size should be zero
+	extra := h.Extra
+	if h.raw && !h.hasDataDescriptor() {
+		// The sizes and CRC are known in advance,
+		// so they are written here rather than in a data descriptor.
+		b.uint32(h.CRC32)
+		if h.isZip64() {
+			b.uint32(uint32max) // compressed size
+			b.uint32(uint32max) // uncompressed size
+
+			var buf [20]byte // 2x uint16 + 2x uint64
+			eb := writeBuf(buf[:])
+			eb.uint16(zip64ExtraID)
+			eb.uint16(16) // size = 2x uint64
+			eb.uint64(h.UncompressedSize64)
+			eb.uint64(h.CompressedSize64)
+			extra = append(extra[:len(extra):len(extra)], buf[:]...)
+			if len(extra) > maxUint16 {
+				return errLongExtra
+			}
+		} else {
+			b.uint32(uint32(h.CompressedSize64))
+			b.uint32(uint32(h.UncompressedSize64))
+		}
+	} else {
+		b.uint32(0) // since we are writing a data descriptor crc32,
+		b.uint32(0) // compressed size,
+		b.uint32(0) // and uncompressed size should be zero
+	}
 	b.uint16(uint16(len(h.Name)))
-	b.uint16(uint16(len(h.Extra)))
+	b.uint16(uint16(len(extra)))
 	if _, err := w.Write(buf[:]); err != nil {
 		return err
 	}
 	if _, err := io.WriteString(w, h.Name); err != nil {
 		return err
 	}
-	_, err := w.Write(h.Extra)
+	_, err := w.Write(extra)
+	return err
+}
+
+// CreateRaw adds a file to the zip archive using the provided FileHeader and
+// returns a Writer to which the file contents should be written. The file's
+// contents must be written to the io.Writer before the next call to Create,
+// CreateHeader, CreateRaw, or Close.
+//
+// In contrast to CreateHeader, the bytes passed to Writer are not compressed,
+// and the header is written as given: the caller must set the Method, CRC32,
+// CompressedSize64 and UncompressedSize64 fields to match the data written.
+// If the data descriptor flag (0x8) is set in Flags, the CRC and sizes are
+// written after the data instead of in the local file header.
+func (w *Writer) CreateRaw(fh *FileHeader) (io.Writer, error) {
+	if err := w.prepare(fh); err != nil {
+		return nil, err
+	}
+	fh.CompressedSize = uint32(min64(fh.CompressedSize64, uint32max))
+	fh.UncompressedSize = uint32(min64(fh.UncompressedSize64, uint32max))
+	if fh.isZip64() && fh.ReaderVersion < zipVersion45 {
+		fh.ReaderVersion = zipVersion45
+	}
+
+	h := &header{
+		FileHeader: fh,
+		offset:     uint64(w.cw.count),
+		raw:        true,
+	}
+	w.dir = append(w.dir, h)
+	if err := writeHeader(w.cw, h); err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(fh.Name, "/") {
+		w.last = nil
+		return dirWriter{}, nil
+	}
+	fw := &fileWriter{
+		header:    h,
+		zipw:      w.cw,
+		compCount: &countWriter{w: w.cw},
+	}
+	w.last = fw
+	return fw, nil
+}
+
+// Copy copies the file f, which is usually from a Reader, into w.
+// The file's data is copied as is, without being decompressed and
+// compressed again, or decrypted.
+func (w *Writer) Copy(f *File) error {
+	r, err := f.OpenRaw()
+	if err != nil {
+		return err
+	}
+	fh := f.FileHeader
+	// Close writes its own zip64 extra field as needed.
+	fh.Extra = removeExtra(fh.Extra, zip64ExtraID)
+	fw, err := w.CreateRaw(&fh)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, r)
 	return err
 }
 
@@ -446,6 +615,9 @@ func (w *fileWriter) Write(p []byte) (int, error) {
 	if w.closed {
 		return 0, errors.New("zip: write to closed file")
 	}
+	if w.raw {
+		return w.compCount.Write(p)
+	}
 	w.crc32.Write(p)
 	return w.rawCount.Write(p)
 }
@@ -455,6 +627,12 @@ func (w *fileWriter) close() error {
 		return errors.New("zip: file closed twice")
 	}
 	w.closed = true
+	if w.raw {
+		if uint64(w.compCount.count) != w.CompressedSize64 {
+			return errors.New("zip: raw file data does not match CompressedSize64")
+		}
+		return w.writeDataDescriptor()
+	}
 	if err := w.comp.Close(); err != nil {
 		return err
 	}
@@ -474,6 +652,15 @@ func (w *fileWriter) close() error {
 		fh.UncompressedSize = uint32(fh.UncompressedSize64)
 	}
 
+	return w.writeDataDescriptor()
+}
+
+func (w *fileWriter) writeDataDescriptor() error {
+	fh := w.header.FileHeader
+	if !fh.hasDataDescriptor() {
+		return nil
+	}
+
 	// Write data descriptor. This is more complicated than one would
 	// think, see e.g. comments in zipfile.c:putextended() and
 	// http://bugs.sun.com/bugdatabase/view_bug.do?bug_id=7073588.
@@ -510,6 +697,54 @@ func (w *countWriter) Write(p []byte) (int, error) {
 	return n, err
 }
 
+// An offsetWriter writes to an io.WriterAt at increasing offsets.
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.w.WriteAt(p, w.off)
+	w.off += int64(n)
+	return n, err
+}
+
+// removeExtra returns a copy of the extra fields in extra
+// without the fields with the given IDs.
+func removeExtra(extra []byte, ids ...uint16) []byte {
+	var out []byte
+	for b := readBuf(extra); len(b) > 0; {
+		if len(b) < 4 {
+			// Keep malformed trailing data as is.
+			return append(out, b...)
+		}
+		field := b
+		tag := b.uint16()
+		size := int(b.uint16())
+		if len(b) < size {
+			return append(out, field...)
+		}
+		b = b[size:]
+		keep := true
+		for _, id := range ids {
+			if tag == id {
+				keep = false
+			}
+		}
+		if keep {
+			out = append(out, field[:4+size]...)
+		}
+	}
+	return out
+}
+
+func min64(x, y uint64) uint64 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
 type nopCloser struct {
 	io.Writer
 }
@@ -8,10 +8,12 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"io/fs"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -57,6 +59,12 @@ var writeTests = []WriteTest{
 		Method: Deflate,
 		Mode:   0755 | fs.ModeSymlink,
 	},
+	{
+		Name:   "zstd",
+		Data:   []byte("Zstandard compressed file, Zstandard compressed file"),
+		Method: Zstd,
+		Mode:   0644,
+	},
 }
 
 func TestWriter(t *testing.T) {
@@ -423,3 +431,325 @@ func BenchmarkCompressedZipGarbage(b *testing.B) {
 		}
 	})
 }
+
+func TestWriterTimePrecision(t *testing.T) {
+	// A header read from another archive carries its timestamps in Extra.
+	r, err := OpenReader("testdata/time-infozip.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	fh := r.File[0].FileHeader
+	mod := time.Date(2021, 2, 3, 4, 5, 6, 789123400, time.UTC)
+	fh.Modified = mod
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if _, err := w.CreateHeader(&fh); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r2, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r2.File[0].Modified; !got.Equal(mod) {
+		t.Errorf("Modified = %v, want %v", got, mod)
+	}
+}
+
+func TestWriterTimeBefore1601(t *testing.T) {
+	// Windows timestamps start in 1601, so no NTFS extra
+	// field is written for earlier times.
+	fh := &FileHeader{
+		Name:     "old.txt",
+		Modified: time.Date(1500, 1, 2, 3, 4, 5, 600, time.UTC),
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if _, err := w.CreateHeader(fh); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for b := readBuf(fh.Extra); len(b) >= 4; {
+		tag := b.uint16()
+		size := int(b.uint16())
+		if tag == ntfsExtraID {
+			t.Fatalf("NTFS extra field written for %v", fh.Modified)
+		}
+		b = b[size:]
+	}
+}
+
+func TestWriterTimeKeepsUnixExtra(t *testing.T) {
+	// The PKWARE Unix extra field holds the owner and link target
+	// as well as times, so setting Modified must not remove it.
+	unix := []byte{
+		0x0d, 0x00, // tag
+		0x10, 0x00, // size
+		0x00, 0x00, 0x00, 0x00, // AcTime
+		0x00, 0x00, 0x00, 0x00, // ModTime
+		0xe8, 0x03, // UID
+		0xe8, 0x03, // GID
+		'd', 'e', 's', 't', // link target
+	}
+	fh := &FileHeader{
+		Name:     "link",
+		Modified: time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC),
+		Extra:    append([]byte(nil), unix...),
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if _, err := w.CreateHeader(fh); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := r.File[0]
+	if !bytes.HasPrefix(f.Extra, unix) {
+		t.Errorf("Extra = %x, want prefix %x", f.Extra, unix)
+	}
+	if !f.Modified.Equal(fh.Modified) {
+		t.Errorf("Modified = %v, want %v", f.Modified, fh.Modified)
+	}
+}
+
+func TestWriterCopy(t *testing.T) {
+	src, err := OpenReader("testdata/test.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, f := range src.File {
+		if err := w.Copy(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareFiles(t, src.File, r.File)
+}
+
+func TestWriterCreateRaw(t *testing.T) {
+	// Compress the data separately, then store it with CreateRaw
+	// with and without a data descriptor.
+	data := []byte(strings.Repeat("raw data ", 100))
+	var comp bytes.Buffer
+	fw := newFlateWriter(&comp)
+	fw.Write(data)
+	fw.Close()
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, flags := range []uint16{0, 0x8} {
+		fh := &FileHeader{
+			Name:               fmt.Sprintf("raw%d", flags),
+			Method:             Deflate,
+			Flags:              flags,
+			CRC32:              crc32.ChecksumIEEE(data),
+			CompressedSize64:   uint64(comp.Len()),
+			UncompressedSize64: uint64(len(data)),
+		}
+		rw, err := w.CreateRaw(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rw.Write(comp.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range r.File {
+		testFileContent(t, f, data)
+	}
+}
+
+func TestWriterCreateRawShort(t *testing.T) {
+	w := NewWriter(io.Discard)
+	fh := &FileHeader{
+		Name:               "short",
+		Method:             Store,
+		CompressedSize64:   10,
+		UncompressedSize64: 10,
+	}
+	rw, err := w.CreateRaw(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rw.Write([]byte("short")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close after writing less data than CompressedSize64 succeeded")
+	}
+}
+
+func TestAppendWriter(t *testing.T) {
+	orig, err := os.ReadFile("testdata/test.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "test.zip")
+	if err := os.WriteFile(name, orig, 0666); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := NewReader(f, int64(len(orig)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewAppendWriter(r, f)
+	data := []byte("appended data\n")
+	fw, err := w.Create("appended.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	// An existing file may be copied from the archive itself.
+	if err := w.Copy(r.File[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := NewReader(f, fi.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r2.File) != len(r.File)+2 {
+		t.Fatalf("appended archive has %d files, want %d", len(r2.File), len(r.File)+2)
+	}
+	if r2.Comment != r.Comment {
+		t.Errorf("Comment = %q, want %q", r2.Comment, r.Comment)
+	}
+	compareFiles(t, r.File, r2.File[:len(r.File)])
+	testFileContent(t, r2.File[len(r.File)], data)
+	compareFiles(t, r.File[:1], r2.File[len(r.File)+1:])
+}
+
+func TestAppendWriterShrink(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	fw, err := w.Create("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("hello\n"))
+	w.SetComment(strings.Repeat("long comment ", 100))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(t.TempDir(), "shrink.zip")
+	if err := os.WriteFile(name, buf.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := NewReader(f, int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = NewAppendWriter(r, f)
+	if err := w.SetComment("short"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	size := w.Offset()
+	if size >= int64(buf.Len()) {
+		t.Fatalf("Offset after Close = %d, want less than %d", size, buf.Len())
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+
+	r2, err := NewReader(f, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r2.Comment != "short" {
+		t.Errorf("Comment = %q, want %q", r2.Comment, "short")
+	}
+	compareFiles(t, r.File, r2.File)
+}
+
+// compareFiles checks that the files in got have the same
+// names and contents as those in want.
+func compareFiles(t *testing.T, want, got []*File) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d files, want %d", len(got), len(want))
+	}
+	for i, f := range want {
+		if got[i].Name != f.Name {
+			t.Errorf("file %d: name %q, want %q", i, got[i].Name, f.Name)
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		testFileContent(t, got[i], data)
+	}
+}
+
+func testFileContent(t *testing.T, f *File, want []byte) {
+	t.Helper()
+	rc, err := f.Open()
+	if err != nil {
+		t.Fatalf("%s: %v", f.Name, err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("%s: %v", f.Name, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: read %q, want %q", f.Name, got, want)
+	}
+}
@@ -826,3 +826,23 @@ func (zeros) Read(p []byte) (int, error) {
 	}
 	return len(p), nil
 }
+
+func TestPBKDF2(t *testing.T) {
+	// Test vectors from RFC 6070.
+	tests := []struct {
+		password, salt string
+		iter           int
+		want           string
+	}{
+		{"password", "salt", 1, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{"password", "salt", 2, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{"password", "salt", 4096, "4b007901b765489abead49d926f721d065a429c1"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, "3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+	}
+	for _, test := range tests {
+		got := pbkdf2([]byte(test.password), []byte(test.salt), test.iter, len(test.want)/2)
+		if fmt.Sprintf("%x", got) != test.want {
+			t.Errorf("pbkdf2(%q, %q, %d) = %x, want %s", test.password, test.salt, test.iter, got, test.want)
+		}
+	}
+}
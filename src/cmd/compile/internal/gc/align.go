@@ -433,6 +433,11 @@ func dowidth(t *types.Type) {
 			Fatalf("dowidth fn struct %v", t)
 		}
 		w = widstruct(t, t, 0, 1)
+		if isAtomicAlign64(t) {
+			// The fields of sync/atomic.align64 type make the structs
+			// that contain them 64-bit aligned, even on 32-bit systems.
+			t.Align = 8
+		}
 
 	// make fake type to check later to
 	// trigger function argument computation.
@@ -5,9 +5,13 @@
 package gc
 
 import (
+	"bytes"
 	"cmd/compile/internal/syntax"
 	"cmd/compile/internal/types"
 	"cmd/internal/obj"
+	"cmd/internal/src"
+	"compress/flate"
+	"crypto/sha256"
 	"encoding/json"
 	"io/ioutil"
 	"log"
@@ -15,6 +19,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var embedlist []*Node
@@ -22,6 +27,7 @@ var embedlist []*Node
 var embedCfg struct {
 	Patterns map[string][]string
 	Files    map[string]string
+	ModTimes map[string]time.Time
 }
 
 func readEmbedCfg(file string) {
@@ -87,17 +93,20 @@ func varEmbed(p *noder, names []*Node, typ *Node, exprs []*Node, embeds []Pragma
 
 	var list []irEmbed
 	for _, e := range embeds {
-		list = append(list, irEmbed{Pos: p.makeXPos(e.Pos), Patterns: e.Patterns})
+		list = append(list, irEmbed{Pos: p.makeXPos(e.Pos), Patterns: e.Patterns, Compress: e.Compress, ModTime: e.ModTime})
 	}
 	v := names[0]
 	v.Name.Param.SetEmbedList(list)
 	embedlist = append(embedlist, v)
 }
 
-func embedFileList(v *Node, kind int) []string {
+// embedFileList returns the sorted list of files to store for v,
+// and the options that apply to each file.
+func embedFileList(v *Node, kind int) ([]string, map[string]embedFileOptions) {
 	// Build list of files to store.
 	have := make(map[string]bool)
 	var list []string
+	opts := make(map[string]embedFileOptions)
 	for _, e := range v.Name.Param.EmbedList() {
 		for _, pattern := range e.Patterns {
 			files, ok := embedCfg.Patterns[pattern]
@@ -113,6 +122,10 @@ func embedFileList(v *Node, kind int) []string {
 					have[file] = true
 					list = append(list, file)
 				}
+				o := opts[file]
+				o.compress = o.compress || e.Compress
+				o.modTime = o.modTime || e.ModTime
+				opts[file] = o
 				if kind == embedFiles {
 					for dir := path.Dir(file); dir != "." && !have[dir]; dir = path.Dir(dir) {
 						have[dir] = true
@@ -129,11 +142,17 @@ func embedFileList(v *Node, kind int) []string {
 	if kind == embedString || kind == embedBytes {
 		if len(list) > 1 {
 			yyerrorl(v.Pos, "invalid go:embed: multiple files for type %v", v.Type)
-			return nil
+			return nil, nil
 		}
 	}
 
-	return list
+	return list, opts
+}
+
+// embedFileOptions are the go:embed options that apply to a file.
+type embedFileOptions struct {
+	compress bool
+	modTime  bool
 }
 
 // embedKind determines the kind of embedding variable.
@@ -190,17 +209,31 @@ func initEmbed(v *Node) {
 		lineno = lno
 		return
 	}
+	// Options depend only on the type of v, so check them
+	// before looking for the configuration from the build system.
+	kind := embedKind(v.Type)
+	if kind == embedString || kind == embedBytes {
+		bad := false
+		for _, e := range v.Name.Param.EmbedList() {
+			if e.Compress || e.ModTime {
+				yyerrorl(e.Pos, "invalid go:embed: options require type embed.FS")
+				bad = true
+			}
+		}
+		if bad {
+			return
+		}
+	}
 	if embedCfg.Patterns == nil {
 		yyerrorl(commentPos, "invalid go:embed: build system did not supply embed configuration")
 		return
 	}
-	kind := embedKind(v.Type)
 	if kind == embedUnknown {
 		yyerrorl(v.Pos, "go:embed cannot apply to var of type %v", v.Type)
 		return
 	}
 
-	files := embedFileList(v, kind)
+	files, opts := embedFileList(v, kind)
 	switch kind {
 	case embedString, embedBytes:
 		file := files[0]
@@ -225,9 +258,11 @@ func initEmbed(v *Node) {
 		off = duintptr(slicedata, off, uint64(len(files)))
 
 		// embed/embed.go type file is:
-		//	name string
-		//	data string
-		//	hash [16]byte
+		//	name    string
+		//	data    string
+		//	hash    [16]byte
+		//	size    int64
+		//	modTime int64
 		// Emit one of these per file in the set.
 		const hashSize = 16
 		hash := make([]byte, hashSize)
@@ -239,18 +274,61 @@ func initEmbed(v *Node) {
 				off = duintptr(slicedata, off, 0)
 				off = duintptr(slicedata, off, 0)
 				off += hashSize
+				off = duintxx(slicedata, off, 0, 8)
+				off = duintxx(slicedata, off, 0, 8)
+				continue
+			}
+			o := opts[file]
+			var fsym *obj.LSym
+			var size, datasize int64
+			var err error
+			if o.compress {
+				fsym, size, datasize, err = compressedFileSym(v.Pos, embedCfg.Files[file], hash)
 			} else {
-				fsym, size, err := fileStringSym(v.Pos, embedCfg.Files[file], true, hash)
-				if err != nil {
-					yyerrorl(v.Pos, "embed %s: %v", file, err)
+				fsym, size, err = fileStringSym(v.Pos, embedCfg.Files[file], true, hash)
+				datasize = size
+			}
+			if err != nil {
+				yyerrorl(v.Pos, "embed %s: %v", file, err)
+			}
+			var modTime int64
+			if o.modTime {
+				t, ok := embedCfg.ModTimes[file]
+				if !ok {
+					yyerrorl(v.Pos, "invalid go:embed: build system did not supply modification time of file: %s", file)
 				}
-				off = dsymptr(slicedata, off, fsym, 0) // data string
-				off = duintptr(slicedata, off, uint64(size))
-				off = int(slicedata.WriteBytes(Ctxt, int64(off), hash))
+				modTime = t.UnixNano()
 			}
+			off = dsymptr(slicedata, off, fsym, 0) // data string
+			off = duintptr(slicedata, off, uint64(datasize))
+			off = int(slicedata.WriteBytes(Ctxt, int64(off), hash))
+			off = duintxx(slicedata, off, uint64(size), 8)
+			off = duintxx(slicedata, off, uint64(modTime), 8)
 		}
 		ggloblsym(slicedata, int32(off), obj.RODATA|obj.LOCAL)
 		sym := v.Sym.Linksym()
 		dsymptr(sym, 0, slicedata, 0)
 	}
 }
+
+// compressedFileSym is like fileStringSym but returns a symbol for the
+// DEFLATE-compressed contents of file, along with the size of the contents
+// and the size of the symbol's data. If compression does not make the
+// contents smaller, the symbol holds them uncompressed.
+func compressedFileSym(pos src.XPos, file string, hash []byte) (sym *obj.LSym, size, datasize int64, err error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	size = int64(len(data))
+	sum := sha256.Sum256(data)
+	copy(hash, sum[:])
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	w.Write(data)
+	w.Close()
+	if buf.Len() < len(data) {
+		data = buf.Bytes()
+	}
+	return stringsym(pos, string(data)), size, int64(len(data)), nil
+}
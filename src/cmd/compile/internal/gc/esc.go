@@ -185,6 +185,11 @@ func heapAllocReason(n *Node) string {
 		return "too large for stack"
 	}
 
+	// The stack is only aligned to the pointer size.
+	if int(n.Type.Align) > Widthptr {
+		return "too aligned for stack"
+	}
+
 	if (n.Op == ONEW || n.Op == OPTRLIT) && n.Type.Elem().Width >= maxImplicitStackVarSize {
 		return "too large for stack"
 	}
@@ -53,6 +53,17 @@ func isReflectPkg(p *types.Pkg) bool {
 	return p.Path == "reflect"
 }
 
+// isAtomicAlign64 reports whether t is sync/atomic.align64.
+func isAtomicAlign64(t *types.Type) bool {
+	if t.Sym == nil || t.Sym.Name != "align64" {
+		return false
+	}
+	if t.Sym.Pkg == localpkg {
+		return myimportpath == "sync/atomic"
+	}
+	return t.Sym.Pkg.Path == "sync/atomic"
+}
+
 // The Class of a variable/function describes the "storage class"
 // of a variable or function. During parsing, storage classes are
 // called declaration contexts.
@@ -1540,6 +1540,8 @@ type PragmaPos struct {
 type PragmaEmbed struct {
 	Pos      syntax.Pos
 	Patterns []string
+	Compress bool // -compress option
+	ModTime  bool // -modtime option
 }
 
 func (p *noder) checkUnused(pragma *Pragma) {
@@ -1611,15 +1613,24 @@ func (p *noder) pragma(pos syntax.Pos, blankLine bool, text string, old syntax.P
 		p.linknames = append(p.linknames, linkname{pos, f[1], target})
 
 	case text == "go:embed", strings.HasPrefix(text, "go:embed "):
-		args, err := parseGoEmbed(text[len("go:embed"):])
+		opts, args, err := parseGoEmbed(text[len("go:embed"):])
 		if err != nil {
 			p.error(syntax.Error{Pos: pos, Msg: err.Error()})
 		}
 		if len(args) == 0 {
-			p.error(syntax.Error{Pos: pos, Msg: "usage: //go:embed pattern..."})
+			p.error(syntax.Error{Pos: pos, Msg: "usage: //go:embed [-compress] [-modtime] pattern..."})
 			break
 		}
-		pragma.Embeds = append(pragma.Embeds, PragmaEmbed{pos, args})
+		e := PragmaEmbed{Pos: pos, Patterns: args}
+		for _, opt := range opts {
+			switch opt {
+			case "-compress":
+				e.Compress = true
+			case "-modtime":
+				e.ModTime = true
+			}
+		}
+		pragma.Embeds = append(pragma.Embeds, e)
 
 	case strings.HasPrefix(text, "go:cgo_import_dynamic "):
 		// This is permitted for general use because Solaris
@@ -1694,11 +1705,11 @@ func mkname(sym *types.Sym) *Node {
 	return n
 }
 
-// parseGoEmbed parses the text following "//go:embed" to extract the glob patterns.
+// parseGoEmbed parses the text following "//go:embed" to extract the options and glob patterns.
 // It accepts unquoted space-separated patterns as well as double-quoted and back-quoted Go strings.
+// The unquoted words -compress and -modtime before the first pattern are options.
 // go/build/read.go also processes these strings and contains similar logic.
-func parseGoEmbed(args string) ([]string, error) {
-	var list []string
+func parseGoEmbed(args string) (opts, list []string, err error) {
 	for args = strings.TrimSpace(args); args != ""; args = strings.TrimSpace(args) {
 		var path string
 	Switch:
@@ -1713,11 +1724,15 @@ func parseGoEmbed(args string) ([]string, error) {
 			}
 			path = args[:i]
 			args = args[i:]
+			if len(list) == 0 && (path == "-compress" || path == "-modtime") {
+				opts = append(opts, path)
+				continue
+			}
 
 		case '`':
 			i := strings.Index(args[1:], "`")
 			if i < 0 {
-				return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args)
+				return nil, nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args)
 			}
 			path = args[1 : 1+i]
 			args = args[1+i+1:]
@@ -1732,7 +1747,7 @@ func parseGoEmbed(args string) ([]string, error) {
 				if args[i] == '"' {
 					q, err := strconv.Unquote(args[:i+1])
 					if err != nil {
-						return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args[:i+1])
+						return nil, nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args[:i+1])
 					}
 					path = q
 					args = args[i+1:]
@@ -1740,17 +1755,17 @@ func parseGoEmbed(args string) ([]string, error) {
 				}
 			}
 			if i >= len(args) {
-				return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args)
+				return nil, nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args)
 			}
 		}
 
 		if args != "" {
 			r, _ := utf8.DecodeRuneInString(args)
 			if !unicode.IsSpace(r) {
-				return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args)
+				return nil, nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args)
 			}
 		}
 		list = append(list, path)
 	}
-	return list, nil
+	return opts, list, nil
 }
@@ -502,6 +502,8 @@ type paramType struct {
 type irEmbed struct {
 	Pos      src.XPos
 	Patterns []string
+	Compress bool
+	ModTime  bool
 }
 
 type embedList []irEmbed
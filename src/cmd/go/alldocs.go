@@ -2634,6 +2634,12 @@
 // 	-failfast
 // 	    Do not start new tests after the first test failure.
 //
+// 	-goroutineleak
+// 	    Fail if, once the tests have run, any goroutines are blocked forever
+// 	    on channels, mutexes, wait groups or condition variables that no
+// 	    other goroutine can reach, and print their stacks.
+// 	    See the goroutineleak profile in runtime/pprof.
+//
 // 	-list regexp
 // 	    List tests, benchmarks, or examples matching the regular expression.
 // 	    No tests, benchmarks or examples will be run. This will only
@@ -206,6 +206,7 @@ type PackageInternal struct {
 	BuildInfo         string               // add this info to package main
 	TestmainGo        *[]byte              // content for _testmain.go
 	Embed             map[string][]string  // //go:embed comment mapping
+	EmbedModTime      []string             // //go:embed patterns with the -modtime option
 
 	Asmflags   []string // -asmflags for this package
 	Gcflags    []string // -gcflags for this package
@@ -1815,6 +1816,7 @@ func (p *Package) load(ctx context.Context, path string, stk *ImportStack, impor
 	defer stk.Pop()
 
 	p.EmbedFiles, p.Internal.Embed, err = resolveEmbed(p.Dir, p.EmbedPatterns)
+	p.Internal.EmbedModTime = p.Internal.Build.EmbedModTimePatterns
 	if err != nil {
 		p.Incomplete = true
 		setError(err)
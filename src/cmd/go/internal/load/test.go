@@ -202,6 +202,7 @@ func TestPackagesAndErrors(ctx context.Context, p *Package, cover *TestCover) (p
 			testEmbed[k] = v
 		}
 		ptest.Internal.Embed = testEmbed
+		ptest.Internal.EmbedModTime = str.StringList(p.Internal.EmbedModTime, p.Internal.Build.TestEmbedModTimePatterns)
 		ptest.EmbedFiles = str.StringList(p.EmbedFiles, p.TestEmbedFiles)
 		ptest.collectDeps()
 	} else {
@@ -232,11 +233,12 @@ func TestPackagesAndErrors(ctx context.Context, p *Package, cover *TestCover) (p
 				Imports:    ximports,
 				RawImports: rawXTestImports,
 
-				Asmflags:   p.Internal.Asmflags,
-				Gcflags:    p.Internal.Gcflags,
-				Ldflags:    p.Internal.Ldflags,
-				Gccgoflags: p.Internal.Gccgoflags,
-				Embed:      xtestEmbed,
+				Asmflags:     p.Internal.Asmflags,
+				Gcflags:      p.Internal.Gcflags,
+				Ldflags:      p.Internal.Ldflags,
+				Gccgoflags:   p.Internal.Gccgoflags,
+				Embed:        xtestEmbed,
+				EmbedModTime: p.Internal.Build.XTestEmbedModTimePatterns,
 			},
 		}
 		if pxtestNeedsPtest {
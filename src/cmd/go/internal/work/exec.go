@@ -344,6 +344,14 @@ func (b *Builder) buildActionID(a *Action) cache.ActionID {
 	for _, file := range inputFiles {
 		fmt.Fprintf(h, "file %s %s\n", file, b.fileHash(filepath.Join(p.Dir, file)))
 	}
+	// The compiler records the modification times of files embedded
+	// with //go:embed -modtime, so those times are inputs too.
+	modTimes := embedModTimes(p)
+	for _, file := range p.EmbedFiles {
+		if t, ok := modTimes[file]; ok {
+			fmt.Fprintf(h, "embed modtime %s %d\n", file, t.UnixNano())
+		}
+	}
 	for _, a1 := range a.Deps {
 		p1 := a1.Package
 		if p1 != nil {
@@ -730,12 +738,14 @@ OverlayLoop:
 		var embed struct {
 			Patterns map[string][]string
 			Files    map[string]string
+			ModTimes map[string]time.Time `json:",omitempty"`
 		}
 		embed.Patterns = p.Internal.Embed
 		embed.Files = make(map[string]string)
 		for _, file := range p.EmbedFiles {
 			embed.Files[file] = filepath.Join(p.Dir, file)
 		}
+		embed.ModTimes = embedModTimes(p)
 		js, err := json.MarshalIndent(&embed, "", "\t")
 		if err != nil {
 			return fmt.Errorf("marshal embedcfg: %v", err)
@@ -1187,6 +1197,24 @@ func (b *Builder) vet(ctx context.Context, a *Action) error {
 	return runErr
 }
 
+// embedModTimes returns the modification times of the files matched
+// by the patterns that p embeds with the -modtime option.
+// Files that cannot be stat'ed are omitted; the compiler reports them.
+func embedModTimes(p *load.Package) map[string]time.Time {
+	var m map[string]time.Time
+	for _, pattern := range p.Internal.EmbedModTime {
+		for _, file := range p.Internal.Embed[pattern] {
+			if m == nil {
+				m = make(map[string]time.Time)
+			}
+			if info, err := fsys.Stat(filepath.Join(p.Dir, file)); err == nil {
+				m[file] = info.ModTime()
+			}
+		}
+	}
+	return m
+}
+
 // linkActionID computes the action ID for a link action.
 func (b *Builder) linkActionID(a *Action) cache.ActionID {
 	p := a.Package
@@ -0,0 +1,396 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+// The kinds of type descriptor. They are the field numbers of
+// the corresponding fields in encoding/gob's wireType.
+const (
+	arrayKind = iota
+	sliceKind
+	structKind
+	mapKind
+	gobEncoderKind
+	binaryMarshalerKind
+	textMarshalerKind
+)
+
+var kindNames = [...]string{
+	gobEncoderKind:      "GobEncoder",
+	binaryMarshalerKind: "BinaryMarshaler",
+	textMarshalerKind:   "TextMarshaler",
+}
+
+// Predefined type ids.
+const (
+	boolId      = 1
+	intId       = 2
+	uintId      = 3
+	floatId     = 4
+	bytesId     = 5
+	stringId    = 6
+	complexId   = 7
+	interfaceId = 8
+	firstUserId = 64
+)
+
+var builtinNames = map[int]string{
+	boolId:      "bool",
+	intId:       "int",
+	uintId:      "uint",
+	floatId:     "float",
+	bytesId:     "bytes",
+	stringId:    "string",
+	complexId:   "complex",
+	interfaceId: "interface",
+}
+
+// A wireType is a type descriptor read from a stream.
+type wireType struct {
+	kind   int
+	name   string
+	elem   int // array, slice and map element
+	key    int // map key
+	len    int // array length
+	fields []wireField
+}
+
+type wireField struct {
+	name string
+	id   int
+}
+
+// A dumper prints a gob stream.
+type dumper struct {
+	w         io.Writer
+	types     map[int]*wireType
+	typesOnly bool
+	pending   []int // types read but not yet printed
+}
+
+// A parseError is raised by panic when the input is malformed.
+type parseError struct {
+	err error
+}
+
+func fail(format string, args ...interface{}) {
+	panic(parseError{fmt.Errorf(format, args...)})
+}
+
+// A buffer holds the unread part of a message.
+type buffer struct {
+	data []byte
+}
+
+func (b *buffer) next(n int) []byte {
+	if n < 0 || n > len(b.data) {
+		fail("unexpected end of data: need %d bytes, have %d", n, len(b.data))
+	}
+	p := b.data[:n]
+	b.data = b.data[n:]
+	return p
+}
+
+// uint reads an unsigned integer: a byte less than 128, or a negated
+// byte count followed by that many big-endian bytes.
+func (b *buffer) uint() uint64 {
+	c := b.next(1)[0]
+	if c < 0x80 {
+		return uint64(c)
+	}
+	n := -int(int8(c))
+	if n > 8 {
+		fail("invalid unsigned integer length %d", n)
+	}
+	var x uint64
+	for _, c := range b.next(n) {
+		x = x<<8 | uint64(c)
+	}
+	return x
+}
+
+func (b *buffer) int() int64 {
+	u := b.uint()
+	if u&1 != 0 {
+		return ^int64(u >> 1)
+	}
+	return int64(u >> 1)
+}
+
+func (b *buffer) float() float64 {
+	return math.Float64frombits(bits.ReverseBytes64(b.uint()))
+}
+
+func (b *buffer) length() int {
+	n := b.uint()
+	if n > uint64(len(b.data)) {
+		fail("length %d exceeds remaining %d bytes", n, len(b.data))
+	}
+	return int(n)
+}
+
+func (b *buffer) string() string {
+	return string(b.next(b.length()))
+}
+
+// fields reads a struct encoding, calling f with the number of each field.
+func (b *buffer) fields(f func(field int)) {
+	field := -1
+	for {
+		delta := b.uint()
+		if delta == 0 {
+			return
+		}
+		if delta > uint64(len(b.data))+1 {
+			fail("invalid field delta %d", delta)
+		}
+		field += int(delta)
+		f(field)
+	}
+}
+
+// stream prints the messages in data.
+func (d *dumper) stream(data []byte) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			pe, ok := e.(parseError)
+			if !ok {
+				panic(e)
+			}
+			err = pe.err
+		}
+	}()
+	defer d.flushPending()
+	in := &buffer{data}
+	for len(in.data) > 0 {
+		m := &buffer{in.next(in.length())}
+		id := int(m.int())
+		if id < 0 {
+			d.typeDef(-id, m)
+			if len(m.data) > 0 {
+				fail("extra data after type %d", -id)
+			}
+			continue
+		}
+		var s string
+		if w := d.types[id]; w != nil && w.kind == structKind {
+			s = d.structValue(m, w)
+		} else {
+			if m.uint() != 0 {
+				fail("non-zero delta for singleton value of type %d", id)
+			}
+			s = d.value(m, id)
+		}
+		d.flushPending()
+		if !d.typesOnly {
+			fmt.Fprintf(d.w, "value %d %s %s\n", id, d.typeName(id), s)
+		}
+	}
+	return nil
+}
+
+// flushPending prints the descriptors read since the last value.
+// Descriptors may refer to types defined after them, so they are
+// printed only once the value that needs them has been reached.
+func (d *dumper) flushPending() {
+	for _, id := range d.pending {
+		fmt.Fprintf(d.w, "type %d %s\n", id, d.describe(id))
+	}
+	d.pending = d.pending[:0]
+}
+
+// typeDef reads the descriptor of type id.
+func (d *dumper) typeDef(id int, m *buffer) {
+	if id < firstUserId {
+		fail("type id %d is predefined", id)
+	}
+	if d.types[id] != nil {
+		fail("duplicate type %d", id)
+	}
+	w := &wireType{kind: -1}
+	m.fields(func(kind int) {
+		if w.kind >= 0 || kind > textMarshalerKind {
+			fail("invalid descriptor for type %d", id)
+		}
+		w.kind = kind
+		m.fields(func(field int) {
+			if field == 0 {
+				// CommonType.
+				m.fields(func(field int) {
+					switch field {
+					case 0:
+						w.name = m.string()
+					case 1:
+						m.int() // id, already known
+					default:
+						fail("invalid CommonType field %d", field)
+					}
+				})
+				return
+			}
+			switch {
+			case kind == arrayKind && field == 1, kind == sliceKind && field == 1, kind == mapKind && field == 2:
+				w.elem = int(m.int())
+			case kind == arrayKind && field == 2:
+				w.len = int(m.int())
+			case kind == mapKind && field == 1:
+				w.key = int(m.int())
+			case kind == structKind && field == 1:
+				n := m.length()
+				for i := 0; i < n; i++ {
+					var f wireField
+					m.fields(func(field int) {
+						switch field {
+						case 0:
+							f.name = m.string()
+						case 1:
+							f.id = int(m.int())
+						default:
+							fail("invalid field descriptor field %d", field)
+						}
+					})
+					w.fields = append(w.fields, f)
+				}
+			default:
+				fail("invalid field %d in descriptor of type %d", field, id)
+			}
+		})
+	})
+	if w.kind < 0 {
+		fail("empty descriptor for type %d", id)
+	}
+	d.types[id] = w
+	d.pending = append(d.pending, id)
+}
+
+// typeName returns the name of a type, or its description
+// if it is an array, slice or map.
+func (d *dumper) typeName(id int) string {
+	if name, ok := builtinNames[id]; ok {
+		return name
+	}
+	w := d.types[id]
+	switch {
+	case w == nil:
+		return fmt.Sprintf("<type %d>", id)
+	case w.kind == arrayKind, w.kind == sliceKind, w.kind == mapKind:
+		return d.describe(id)
+	}
+	return w.name
+}
+
+// describe returns the definition of a type.
+func (d *dumper) describe(id int) string {
+	w := d.types[id]
+	switch w.kind {
+	case arrayKind:
+		return fmt.Sprintf("[%d]%s", w.len, d.typeName(w.elem))
+	case sliceKind:
+		return "[]" + d.typeName(w.elem)
+	case mapKind:
+		return fmt.Sprintf("map[%s]%s", d.typeName(w.key), d.typeName(w.elem))
+	case structKind:
+		fields := make([]string, len(w.fields))
+		for i, f := range w.fields {
+			fields[i] = f.name + " " + d.typeName(f.id)
+		}
+		return w.name + " struct {" + strings.Join(fields, "; ") + "}"
+	}
+	return w.name + " " + kindNames[w.kind]
+}
+
+// value reads and formats a value of type id.
+func (d *dumper) value(m *buffer, id int) string {
+	switch id {
+	case boolId:
+		return fmt.Sprint(m.uint() != 0)
+	case intId:
+		return fmt.Sprint(m.int())
+	case uintId:
+		return fmt.Sprint(m.uint())
+	case floatId:
+		return fmt.Sprint(m.float())
+	case bytesId, stringId:
+		return fmt.Sprintf("%q", m.string())
+	case complexId:
+		re := m.float()
+		return fmt.Sprint(complex(re, m.float()))
+	case interfaceId:
+		return d.interfaceValue(m)
+	}
+	w := d.types[id]
+	if w == nil {
+		fail("undefined type %d", id)
+	}
+	switch w.kind {
+	case arrayKind, sliceKind:
+		n := m.length()
+		elems := make([]string, n)
+		for i := range elems {
+			elems[i] = d.value(m, w.elem)
+		}
+		return "[" + strings.Join(elems, " ") + "]"
+	case mapKind:
+		n := m.length()
+		entries := make([]string, n)
+		for i := range entries {
+			k := d.value(m, w.key)
+			entries[i] = k + ":" + d.value(m, w.elem)
+		}
+		return "map[" + strings.Join(entries, " ") + "]"
+	case structKind:
+		return d.structValue(m, w)
+	}
+	return fmt.Sprintf("%s(%x)", kindNames[w.kind], m.next(m.length()))
+}
+
+// structValue reads and formats a struct of type w.
+// Fields with zero values are not sent and are not shown.
+func (d *dumper) structValue(m *buffer, w *wireType) string {
+	var fields []string
+	m.fields(func(field int) {
+		if field >= len(w.fields) {
+			fail("field %d out of range for type %s", field, w.name)
+		}
+		f := w.fields[field]
+		fields = append(fields, f.name+": "+d.value(m, f.id))
+	})
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// interfaceValue reads and formats an interface value: the name of the
+// concrete type, any descriptors it needs, its id, and a delimited value.
+func (d *dumper) interfaceValue(m *buffer) string {
+	name := m.string()
+	if name == "" {
+		return "nil"
+	}
+	id := int(m.int())
+	for id < 0 {
+		d.typeDef(-id, m)
+		if len(m.data) > 0 {
+			m.uint() // count of the next item
+		}
+		id = int(m.int())
+	}
+	v := &buffer{m.next(m.length())}
+	var s string
+	if w := d.types[id]; w != nil && w.kind == structKind {
+		s = d.structValue(v, w)
+	} else {
+		if v.uint() != 0 {
+			fail("non-zero delta for singleton value of type %d", id)
+		}
+		s = d.value(v, id)
+	}
+	return fmt.Sprintf("(%s) %s", name, s)
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strings"
+	"testing"
+)
+
+type Point struct {
+	X, Y int
+}
+
+type Shape struct {
+	Name   string
+	Points []Point
+	Tags   map[string]bool
+	Extra  interface{}
+	Scale  float64
+}
+
+func init() {
+	gob.RegisterName("Point", Point{})
+}
+
+func TestDump(t *testing.T) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	enc.SetDeterministic(true)
+	for _, v := range []interface{}{
+		Point{1, -2},
+		Shape{Name: "tri", Points: []Point{{0, 0}, {3, 4}}, Tags: map[string]bool{"a": true, "b": false}, Extra: Point{5, 6}, Scale: 1.5},
+		"hello",
+		[]int{7, 8},
+	} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := buf.Bytes()
+
+	var out bytes.Buffer
+	if err := dump(&out, bytes.NewReader(data), false); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	// Type ids depend on what the process has encoded before, so
+	// check the lines without them.
+	for _, want := range []string{
+		" Point struct {X int; Y int}\n",
+		" Point {X: 1, Y: -2}\n",
+		" Shape struct {Name string; Points []Point; Tags map[string]bool; Extra interface; Scale float}\n",
+		` Shape {Name: "tri", Points: [{} {X: 3, Y: 4}], Tags: map["a":true "b":false], Extra: (Point) {X: 5, Y: 6}, Scale: 1.5}` + "\n",
+		"value 6 string \"hello\"\n",
+		" []int [7 8]\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+
+	out.Reset()
+	if err := dump(&out, bytes.NewReader(data), true); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if !strings.HasPrefix(line, "type ") {
+			t.Errorf("-types printed %q", line)
+		}
+	}
+
+	if err := dump(&out, bytes.NewReader(data[:len(data)-1]), false); err == nil {
+		t.Errorf("dump of truncated stream: got nil error")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Gobdump prints the contents of gob streams for debugging.
+//
+// Usage:
+//	go tool gobdump [-types] [file...]
+//
+// Gobdump reads the gob streams in the named files, or standard input if
+// none are named, and prints each type descriptor and value in the order
+// they appear. Type descriptors are printed as
+//
+//	type 65 Point struct {X int; Y int}
+//
+// and values, whose concrete types need not be known to gobdump, as
+//
+//	value 65 Point {X: 1, Y: 2}
+//
+// The -types flag prints only the type descriptors.
+//
+// Gobdump parses the stream independently of package encoding/gob,
+// so it can show the data the package rejects, up to the point where
+// the stream is malformed.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+var typesOnly = flag.Bool("types", false, "print only type descriptors")
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: go tool gobdump [-types] [file...]\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("gobdump: ")
+	flag.Usage = usage
+	flag.Parse()
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	if flag.NArg() == 0 {
+		if err := dump(w, os.Stdin, *typesOnly); err != nil {
+			w.Flush()
+			log.Fatal(err)
+		}
+		return
+	}
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			w.Flush()
+			log.Fatal(err)
+		}
+		if flag.NArg() > 1 {
+			fmt.Fprintf(w, "# %s\n", name)
+		}
+		err = dump(w, f, *typesOnly)
+		f.Close()
+		if err != nil {
+			w.Flush()
+			log.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+// dump prints the gob stream read from r to w.
+func dump(w io.Writer, r io.Reader, typesOnly bool) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	d := &dumper{w: w, types: make(map[int]*wireType), typesOnly: typesOnly}
+	return d.stream(data)
+}
@@ -0,0 +1,29 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Heapview is a tool for viewing heap dumps.
+
+Heap dumps are written by runtime/debug.WriteHeapDump.
+
+Example usage:
+View what retains the memory of the heap in a web browser:
+	go tool heapview ./prog heap.dump
+Print the roots, types and objects that retain the most memory:
+	go tool heapview -top=20 ./prog heap.dump
+
+The first argument is the executable that wrote the heap dump. Its
+debug information gives the types of the global and stack variables,
+from which heapview infers the types of the objects of the heap.
+It can be omitted, leaving the objects untyped.
+
+An object dominates another one if all the paths from the roots of
+the heap to the other object go through it. The retained size of an
+object is the size of the objects that it dominates, including itself:
+the memory that would be freed if it were unreachable. Heapview groups
+the pointers of each global variable, goroutine, stack frame and other
+root of the heap in a node, and shows the retained size of these roots,
+of the types and of the objects.
+*/
+package main
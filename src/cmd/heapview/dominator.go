@@ -0,0 +1,112 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// dominators computes the dominator tree of the graph of n nodes
+// whose successors of node v are succ(v), rooted at node 0,
+// with the algorithm of Lengauer and Tarjan.
+//
+// It returns the immediate dominator of each node, or -1 for the
+// nodes unreachable from the root, and the reachable nodes in
+// depth-first preorder, in which dominators come before the nodes
+// they dominate. The root is its own immediate dominator.
+func dominators(n int, succ func(v int32) []int32) (idom, order []int32) {
+	// Number the nodes in depth-first preorder, from now on
+	// the algorithm works on these numbers.
+	num := make([]int32, n)
+	for i := range num {
+		num[i] = -1
+	}
+	var parent []int32
+	type visit struct {
+		v, parent int32
+	}
+	stack := []visit{{0, -1}}
+	for len(stack) > 0 {
+		x := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if num[x.v] >= 0 {
+			continue
+		}
+		num[x.v] = int32(len(order))
+		order = append(order, x.v)
+		parent = append(parent, x.parent)
+		ss := succ(x.v)
+		for i := len(ss) - 1; i >= 0; i-- {
+			if num[ss[i]] < 0 {
+				stack = append(stack, visit{ss[i], num[x.v]})
+			}
+		}
+	}
+
+	m := int32(len(order))
+	preds := make([][]int32, m)
+	for w := int32(0); w < m; w++ {
+		for _, s := range succ(order[w]) {
+			preds[num[s]] = append(preds[num[s]], w)
+		}
+	}
+	semi := make([]int32, m)
+	label := make([]int32, m)
+	ancestor := make([]int32, m)
+	dom := make([]int32, m)
+	for v := int32(0); v < m; v++ {
+		semi[v], label[v], ancestor[v] = v, v, -1
+	}
+	var path []int32
+	eval := func(v int32) int32 {
+		if ancestor[v] < 0 {
+			return v
+		}
+		// Compress the path from v to the root of its tree of the forest,
+		// from the top down.
+		path = path[:0]
+		for x := v; ancestor[ancestor[x]] >= 0; x = ancestor[x] {
+			path = append(path, x)
+		}
+		for i := len(path) - 1; i >= 0; i-- {
+			x := path[i]
+			a := ancestor[x]
+			if semi[label[a]] < semi[label[x]] {
+				label[x] = label[a]
+			}
+			ancestor[x] = ancestor[a]
+		}
+		return label[v]
+	}
+	buckets := make([][]int32, m)
+	for w := m - 1; w > 0; w-- {
+		for _, v := range preds[w] {
+			if u := eval(v); semi[u] < semi[w] {
+				semi[w] = semi[u]
+			}
+		}
+		buckets[semi[w]] = append(buckets[semi[w]], w)
+		p := parent[w]
+		ancestor[w] = p
+		for _, v := range buckets[p] {
+			if u := eval(v); semi[u] < semi[v] {
+				dom[v] = u
+			} else {
+				dom[v] = p
+			}
+		}
+		buckets[p] = nil
+	}
+	for w := int32(1); w < m; w++ {
+		if dom[w] != semi[w] {
+			dom[w] = dom[dom[w]]
+		}
+	}
+
+	idom = make([]int32, n)
+	for i := range idom {
+		idom[i] = -1
+	}
+	for w := int32(0); w < m; w++ {
+		idom[order[w]] = order[dom[w]]
+	}
+	return idom, order
+}
@@ -0,0 +1,133 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestDominators(t *testing.T) {
+	// The example of Lengauer and Tarjan's paper,
+	// with R, A, B, ... numbered 0, 1, 2, ...
+	const (
+		R = iota
+		A
+		B
+		C
+		D
+		E
+		F
+		G
+		H
+		I
+		J
+		K
+		L
+	)
+	succs := [][]int32{
+		R: {A, B, C},
+		A: {D},
+		B: {A, D, E},
+		C: {F, G},
+		D: {L},
+		E: {H},
+		F: {I},
+		G: {I, J},
+		H: {E, K},
+		I: {K},
+		J: {I},
+		K: {I, R},
+		L: {H},
+	}
+	idom, order := dominators(len(succs), func(v int32) []int32 { return succs[v] })
+	want := []int32{
+		R: R, A: R, B: R, C: R, D: R, E: R, F: C,
+		G: C, H: R, I: R, J: G, K: R, L: D,
+	}
+	if !reflect.DeepEqual(idom, want) {
+		t.Errorf("idom = %v, want %v", idom, want)
+	}
+	if len(order) != len(succs) || order[0] != R {
+		t.Errorf("order = %v, want all the nodes from R", order)
+	}
+}
+
+func TestDominatorsUnreachable(t *testing.T) {
+	succs := [][]int32{{1}, {}, {1, 3}, {0}}
+	idom, order := dominators(len(succs), func(v int32) []int32 { return succs[v] })
+	if want := []int32{0, 0, -1, -1}; !reflect.DeepEqual(idom, want) {
+		t.Errorf("idom = %v, want %v", idom, want)
+	}
+	if want := []int32{0, 1}; !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+// naiveDominators computes the dominators of the graph
+// by removing each node in turn.
+func naiveDominators(succs [][]int32) []int32 {
+	n := len(succs)
+	reach := func(removed int) []bool {
+		seen := make([]bool, n)
+		stack := []int32{0}
+		seen[0] = true
+		for len(stack) > 0 {
+			v := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, s := range succs[v] {
+				if int(s) != removed && !seen[s] {
+					seen[s] = true
+					stack = append(stack, s)
+				}
+			}
+		}
+		return seen
+	}
+	reachable := reach(-1)
+	// doms[v] are the nodes that dominate v, other than v.
+	doms := make([][]int32, n)
+	for d := 1; d < n; d++ {
+		r := reach(d)
+		for v := range succs {
+			if v != d && reachable[v] && !r[v] {
+				doms[v] = append(doms[v], int32(d))
+			}
+		}
+	}
+	idom := make([]int32, n)
+	for v := range idom {
+		idom[v] = -1
+		if !reachable[v] {
+			continue
+		}
+		idom[v] = 0
+		// The immediate dominator is the dominator dominated
+		// by all the others.
+		for _, d := range doms[v] {
+			if len(doms[d]) == len(doms[v])-1 {
+				idom[v] = d
+			}
+		}
+	}
+	return idom
+}
+
+func TestDominatorsRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		n := 1 + r.Intn(30)
+		succs := make([][]int32, n)
+		for e := r.Intn(3 * n); e > 0; e-- {
+			v := r.Intn(n)
+			succs[v] = append(succs[v], int32(r.Intn(n)))
+		}
+		idom, _ := dominators(n, func(v int32) []int32 { return succs[v] })
+		if want := naiveDominators(succs); !reflect.DeepEqual(idom, want) {
+			t.Fatalf("graph %v: idom = %v, want %v", succs, idom, want)
+		}
+	}
+}
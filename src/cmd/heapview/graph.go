@@ -0,0 +1,409 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"debug/heapdump"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// A graph is the reference graph of a heap dump.
+//
+// Node 0 is a pseudo-root, which points to the roots of the heap:
+// global variables, goroutines, finalizers and other roots.
+// Goroutines point to their stack frames. The roots are followed
+// by the objects of the heap, in the order of the dump.
+type graph struct {
+	d     *heapdump.Dump
+	roots []*root // roots[i] is node i, roots[0] is the pseudo-root
+	addrs []uint64
+
+	// The successors of node v are edges[start[v]:start[v+1]].
+	start []int32
+	edges []int32
+
+	idom     []int32  // immediate dominators, -1 for unreachable nodes
+	retained []uint64 // retained sizes
+
+	// The children of node v in the dominator tree
+	// are children[childStart[v]:childStart[v+1]],
+	// in decreasing order of retained size.
+	childStart []int32
+	children   []int32
+
+	types []*typeStats // in decreasing order of retained size
+
+	topOnce sync.Once
+	top     []int32 // the reachable objects that retain the most memory
+
+	reachable   int    // number of reachable objects
+	unreachable uint64 // size of the unreachable objects
+}
+
+// A root is a root of the heap.
+type root struct {
+	kind  rootKind
+	name  string
+	v     *heapdump.Var       // global variable
+	g     *heapdump.Goroutine // goroutine or frame
+	frame *heapdump.Frame
+}
+
+type rootKind uint8
+
+const (
+	rootPseudo rootKind = iota
+	rootGlobal
+	rootSegment // pointers of a segment outside of known global variables
+	rootGoroutine
+	rootFrame
+	rootFinalizers
+	rootOther
+)
+
+// typeStats are the statistics of the objects of a type.
+type typeStats struct {
+	Name     string
+	Count    int
+	Size     uint64
+	Retained uint64 // size retained by the objects not retained by others of the type
+	objects  []int32
+}
+
+// newGraph builds the reference graph of d, and computes
+// its dominator tree and the retained size of its nodes.
+func newGraph(d *heapdump.Dump) *graph {
+	g := &graph{d: d, addrs: make([]uint64, len(d.Objects))}
+	for i, o := range d.Objects {
+		g.addrs[i] = o.Addr
+	}
+	g.build()
+	g.dominate()
+	g.typeStats()
+	return g
+}
+
+// object returns the node of the object that contains addr, or -1.
+func (g *graph) object(addr uint64) int32 {
+	i := sort.Search(len(g.addrs), func(i int) bool { return g.addrs[i] > addr }) - 1
+	if i < 0 || addr >= g.addrs[i]+g.d.Objects[i].Size() {
+		return -1
+	}
+	return int32(len(g.roots) + i)
+}
+
+// obj returns the object of node v, or nil if v is a root.
+func (g *graph) obj(v int32) *heapdump.Object {
+	if int(v) < len(g.roots) {
+		return nil
+	}
+	return g.d.Objects[int(v)-len(g.roots)]
+}
+
+func (g *graph) numNodes() int {
+	return len(g.roots) + len(g.d.Objects)
+}
+
+func (g *graph) succ(v int32) []int32 {
+	return g.edges[g.start[v]:g.start[v+1]]
+}
+
+func (g *graph) size(v int32) uint64 {
+	if o := g.obj(v); o != nil {
+		return o.Size()
+	}
+	return 0
+}
+
+// build creates the roots, and the edges of the graph.
+func (g *graph) build() {
+	d := g.d
+	g.roots = append(g.roots, &root{kind: rootPseudo, name: "roots"})
+	type rootEdges struct {
+		r     *root
+		addrs []uint64 // the pointers of the root
+		nodes []int32  // or the nodes it points to
+	}
+	var rs []*rootEdges
+	add := func(r *root) *rootEdges {
+		g.roots = append(g.roots, r)
+		re := &rootEdges{r: r}
+		rs = append(rs, re)
+		return re
+	}
+
+	// Global variables, and the pointers of segments outside of them.
+	segs := func(ss []*heapdump.Segment, what string) {
+		for i, s := range ss {
+			name := what
+			if len(ss) > 1 {
+				name += " of module " + strconv.Itoa(i)
+			}
+			var global, rest *rootEdges
+			globals := d.Globals
+			for _, f := range s.Fields {
+				addr := s.Addr + f.Offset
+				for len(globals) > 0 && globals[0].Addr+globals[0].Type.Size <= addr {
+					globals = globals[1:]
+				}
+				p, ok := d.ReadPtr(addr)
+				if !ok || p == 0 {
+					continue
+				}
+				if len(globals) > 0 && globals[0].Addr <= addr {
+					if global == nil || global.r.v != globals[0] {
+						global = add(&root{kind: rootGlobal, name: globals[0].Name, v: globals[0]})
+					}
+					global.addrs = append(global.addrs, p)
+					continue
+				}
+				if rest == nil {
+					rest = add(&root{kind: rootSegment, name: name})
+				}
+				rest.addrs = append(rest.addrs, p)
+			}
+		}
+	}
+	segs(d.Data, "data segment")
+	segs(d.BSS, "bss segment")
+
+	// Goroutines and their frames.
+	for _, gr := range d.Goroutines {
+		gre := add(&root{kind: rootGoroutine, name: fmt.Sprintf("goroutine %d", gr.ID), g: gr})
+		for _, p := range gr.Panics {
+			gre.addrs = append(gre.addrs, p.Data)
+		}
+		for _, df := range gr.Defers {
+			gre.addrs = append(gre.addrs, df.Addr, df.FuncVal)
+		}
+		for _, f := range gr.Frames {
+			fre := add(&root{kind: rootFrame, name: fmt.Sprintf("goroutine %d: %s", gr.ID, f.Func), g: gr, frame: f})
+			gre.nodes = append(gre.nodes, int32(len(g.roots)-1))
+			for _, fld := range f.Fields {
+				if p, ok := d.ReadPtr(f.Addr + fld.Offset); ok {
+					fre.addrs = append(fre.addrs, p)
+				}
+			}
+		}
+	}
+
+	// Finalizers keep their functions, and the objects of
+	// the finalizers ready to run, alive.
+	if len(d.Finalizers) > 0 {
+		fre := add(&root{kind: rootFinalizers, name: "finalizers"})
+		for _, f := range d.Finalizers {
+			fre.addrs = append(fre.addrs, f.FuncVal)
+			if f.Queued {
+				fre.addrs = append(fre.addrs, f.Obj)
+			}
+		}
+	}
+	others := make(map[string]*rootEdges)
+	for _, o := range d.OtherRoots {
+		re := others[o.Description]
+		if re == nil {
+			re = add(&root{kind: rootOther, name: o.Description})
+			others[o.Description] = re
+		}
+		re.addrs = append(re.addrs, o.To)
+	}
+
+	// Edges of the pseudo-root, of the roots, then of the objects.
+	g.start = make([]int32, 0, g.numNodes()+1)
+	g.start = append(g.start, 0)
+	for i, re := range rs {
+		if re.r.kind != rootFrame {
+			g.edges = append(g.edges, int32(i+1))
+		}
+	}
+	g.start = append(g.start, int32(len(g.edges)))
+	for _, re := range rs {
+		g.edges = append(g.edges, re.nodes...)
+		for _, p := range re.addrs {
+			if v := g.object(p); v >= 0 {
+				g.edges = append(g.edges, v)
+			}
+		}
+		g.start = append(g.start, int32(len(g.edges)))
+	}
+	for _, o := range d.Objects {
+		for _, f := range o.Fields {
+			p, ok := d.ReadPtr(o.Addr + f.Offset)
+			if !ok {
+				continue
+			}
+			if v := g.object(p); v >= 0 {
+				g.edges = append(g.edges, v)
+			}
+		}
+		g.start = append(g.start, int32(len(g.edges)))
+	}
+}
+
+// dominate computes the dominator tree and the retained sizes.
+func (g *graph) dominate() {
+	n := g.numNodes()
+	var order []int32
+	g.idom, order = dominators(n, g.succ)
+	g.retained = make([]uint64, n)
+	for i := len(order) - 1; i > 0; i-- {
+		v := order[i]
+		g.retained[v] += g.size(v)
+		g.retained[g.idom[v]] += g.retained[v]
+	}
+	for v := int32(len(g.roots)); int(v) < n; v++ {
+		if g.idom[v] >= 0 {
+			g.reachable++
+		} else {
+			g.unreachable += g.size(v)
+		}
+	}
+
+	// The dominator tree.
+	g.childStart = make([]int32, n+1)
+	for v := 1; v < n; v++ {
+		if d := g.idom[v]; d >= 0 {
+			g.childStart[d+1]++
+		}
+	}
+	for v := 0; v < n; v++ {
+		g.childStart[v+1] += g.childStart[v]
+	}
+	g.children = make([]int32, g.childStart[n])
+	next := append([]int32(nil), g.childStart[:n]...)
+	for v := 1; v < n; v++ {
+		if d := g.idom[v]; d >= 0 {
+			g.children[next[d]] = int32(v)
+			next[d]++
+		}
+	}
+	for v := 0; v < n; v++ {
+		c := g.children[g.childStart[v]:g.childStart[v+1]]
+		sort.Slice(c, func(i, j int) bool { return g.retained[c[i]] > g.retained[c[j]] })
+	}
+}
+
+// dominated returns the children of v in the dominator tree.
+func (g *graph) dominated(v int32) []int32 {
+	return g.children[g.childStart[v]:g.childStart[v+1]]
+}
+
+// typeName returns the name that the statistics of the type
+// of object o are grouped by.
+func typeName(o *heapdump.Object) string {
+	t := o.Type
+	switch {
+	case t == nil:
+		return fmt.Sprintf("unknown (%d bytes)", o.Size())
+	case t.Kind == heapdump.Array && t.Elem != nil:
+		return "[...]" + t.Elem.Name
+	}
+	return t.Name
+}
+
+// typeStats computes the statistics of the types of the objects.
+// Objects retained by objects of the same type don't count
+// in the retained size of the type.
+func (g *graph) typeStats() {
+	byName := make(map[string]int)
+	typeOf := make([]int32, len(g.d.Objects))
+	for i, o := range g.d.Objects {
+		name := typeName(o)
+		t, ok := byName[name]
+		if !ok {
+			t = len(g.types)
+			byName[name] = t
+			g.types = append(g.types, &typeStats{Name: name})
+		}
+		typeOf[i] = int32(t)
+		ts := g.types[t]
+		ts.Count++
+		ts.Size += o.Size()
+		ts.objects = append(ts.objects, int32(len(g.roots)+i))
+	}
+
+	// Walk the dominator tree, counting the objects of each type
+	// on the path from the root.
+	onPath := make([]int32, len(g.types))
+	type visit struct {
+		v     int32
+		leave bool
+	}
+	stack := []visit{{0, false}}
+	for len(stack) > 0 {
+		x := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		t := int32(-1)
+		if int(x.v) >= len(g.roots) {
+			t = typeOf[int(x.v)-len(g.roots)]
+		}
+		if x.leave {
+			onPath[t]--
+			continue
+		}
+		if t >= 0 {
+			if onPath[t] == 0 {
+				g.types[t].Retained += g.retained[x.v]
+			}
+			onPath[t]++
+			stack = append(stack, visit{x.v, true})
+		}
+		for _, c := range g.dominated(x.v) {
+			stack = append(stack, visit{c, false})
+		}
+	}
+
+	sort.Slice(g.types, func(i, j int) bool {
+		ti, tj := g.types[i], g.types[j]
+		if ti.Retained != tj.Retained {
+			return ti.Retained > tj.Retained
+		}
+		return ti.Name < tj.Name
+	})
+	for _, ts := range g.types {
+		sort.Slice(ts.objects, func(i, j int) bool { return g.retained[ts.objects[i]] > g.retained[ts.objects[j]] })
+	}
+}
+
+// label returns a short description of node v.
+func (g *graph) label(v int32) string {
+	if o := g.obj(v); o != nil {
+		if o.Type == nil {
+			return fmt.Sprintf("%#x", o.Addr)
+		}
+		return fmt.Sprintf("%#x (%s)", o.Addr, o.Type.Name)
+	}
+	return g.roots[v].name
+}
+
+// path returns the dominators of v, from the pseudo-root.
+func (g *graph) path(v int32) []int32 {
+	var p []int32
+	for v > 0 && g.idom[v] >= 0 {
+		v = g.idom[v]
+		p = append(p, v)
+	}
+	for i, j := 0, len(p)-1; i < j; i, j = i+1, j-1 {
+		p[i], p[j] = p[j], p[i]
+	}
+	return p
+}
+
+// predecessors returns the nodes that point to v.
+func (g *graph) predecessors(v int32) []int32 {
+	var preds []int32
+	for u := 0; u < g.numNodes(); u++ {
+		for _, s := range g.succ(int32(u)) {
+			if s == v {
+				preds = append(preds, int32(u))
+				break
+			}
+		}
+	}
+	return preds
+}
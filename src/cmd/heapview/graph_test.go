@@ -0,0 +1,121 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+type testList struct {
+	next *testList
+	buf  [1 << 10]byte
+}
+
+var testRoot *testList
+
+func testGraph(t *testing.T) *graph {
+	if runtime.GOOS == "js" {
+		t.Skipf("WriteHeapDump is not available on %s", runtime.GOOS)
+	}
+	for i := 0; i < 100; i++ {
+		testRoot = &testList{next: testRoot}
+	}
+	f, err := os.CreateTemp("", "heapdump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	debug.WriteHeapDump(f.Fd())
+	d, err := readDump(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newGraph(d)
+}
+
+func TestGraph(t *testing.T) {
+	g := testGraph(t)
+	if g.reachable == 0 {
+		t.Fatalf("no reachable objects")
+	}
+
+	// The retained size of a reachable node is its size plus
+	// the retained sizes of the nodes it dominates.
+	for v := int32(0); int(v) < g.numNodes(); v++ {
+		if g.idom[v] < 0 {
+			continue
+		}
+		sum := g.size(v)
+		for _, c := range g.dominated(v) {
+			if g.idom[c] != v {
+				t.Fatalf("node %d dominated by %d, idom %d", c, v, g.idom[c])
+			}
+			sum += g.retained[c]
+		}
+		if sum != g.retained[v] {
+			t.Fatalf("node %d: retained %d, want %d", v, g.retained[v], sum)
+		}
+	}
+
+	// The global testRoot retains the whole list.
+	addr := uint64(uintptr(unsafe.Pointer(testRoot)))
+	var list int32 = -1
+	for v := 1; v < len(g.roots); v++ {
+		for _, s := range g.succ(int32(v)) {
+			if o := g.obj(s); o != nil && o.Addr == addr {
+				list = s
+			}
+		}
+	}
+	if list < 0 {
+		t.Fatalf("testRoot is not referenced by a root")
+	}
+	if min := uint64(100 << 10); g.retained[list] < min {
+		t.Errorf("testRoot retains %d bytes, want at least %d", g.retained[list], min)
+	}
+	if p := g.path(list); len(p) == 0 || p[0] != 0 {
+		t.Errorf("path of testRoot = %v, want a path from the pseudo-root", p)
+	}
+
+	var buf bytes.Buffer
+	printTop(&buf, g, 5)
+	if !strings.Contains(buf.String(), "retained") {
+		t.Errorf("printTop printed:\n%s", buf.String())
+	}
+
+	mux := http.NewServeMux()
+	g.register(mux)
+	for _, u := range []string{
+		"/",
+		"/roots",
+		"/types",
+		"/objects",
+		fmt.Sprintf("/node?id=%d", list),
+		fmt.Sprintf("/node?id=%d", g.topRoots()[0]),
+		"/type?name=" + url.QueryEscape(g.types[0].Name),
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", u, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s: %d %s", u, w.Code, w.Body.String())
+		}
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", fmt.Sprintf("/object?addr=%#x", addr), nil))
+	if w.Code != http.StatusFound {
+		t.Errorf("GET /object: %d, want %d", w.Code, http.StatusFound)
+	}
+}
@@ -0,0 +1,587 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"debug/heapdump"
+	"encoding/binary"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// register registers the handlers of the pages of the heap viewer.
+func (g *graph) register(mux *http.ServeMux) {
+	mux.HandleFunc("/", g.httpMain)
+	mux.HandleFunc("/roots", g.httpRoots)
+	mux.HandleFunc("/types", g.httpTypes)
+	mux.HandleFunc("/type", g.httpType)
+	mux.HandleFunc("/objects", g.httpObjects)
+	mux.HandleFunc("/node", g.httpNode)
+	mux.HandleFunc("/object", g.httpObject)
+}
+
+const (
+	maxListed = 1000 // nodes listed in a page
+	maxRows   = 1000 // rows of the contents of a node
+)
+
+// A nodeRow is a node listed in a page.
+type nodeRow struct {
+	ID       int32
+	Label    string
+	Size     uint64
+	Retained uint64
+}
+
+func (g *graph) nodeRows(vs []int32, max int) []nodeRow {
+	if len(vs) > max {
+		vs = vs[:max]
+	}
+	rows := make([]nodeRow, len(vs))
+	for i, v := range vs {
+		rows[i] = nodeRow{ID: v, Label: g.label(v), Size: g.size(v), Retained: g.retained[v]}
+	}
+	return rows
+}
+
+// topRoots returns the roots in decreasing order of retained size.
+func (g *graph) topRoots() []int32 {
+	vs := make([]int32, 0, len(g.roots)-1)
+	for v := 1; v < len(g.roots); v++ {
+		vs = append(vs, int32(v))
+	}
+	sort.SliceStable(vs, func(i, j int) bool { return g.retained[vs[i]] > g.retained[vs[j]] })
+	return vs
+}
+
+// topObjects returns the n reachable objects that retain the most memory.
+func (g *graph) topObjects(n int) []int32 {
+	g.topOnce.Do(func() {
+		for v := int32(len(g.roots)); int(v) < g.numNodes(); v++ {
+			if g.idom[v] >= 0 {
+				g.top = append(g.top, v)
+			}
+		}
+		sort.SliceStable(g.top, func(i, j int) bool { return g.retained[g.top[i]] > g.retained[g.top[j]] })
+		if len(g.top) > maxListed {
+			g.top = g.top[:maxListed]
+		}
+	})
+	if len(g.top) < n {
+		return g.top
+	}
+	return g.top[:n]
+}
+
+func (g *graph) httpMain(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	var typed, typedSize, size uint64
+	for _, o := range g.d.Objects {
+		size += o.Size()
+		if o.Type != nil {
+			typed++
+			typedSize += o.Size()
+		}
+	}
+	types := g.types
+	if len(types) > 20 {
+		types = types[:20]
+	}
+	g.execute(w, templMain, struct {
+		Params      heapdump.Params
+		MemStats    interface{}
+		Objects     int
+		Size        uint64
+		Typed       uint64
+		TypedSize   uint64
+		Reachable   int
+		Unreachable uint64
+		Goroutines  int
+		Roots       []nodeRow
+		Types       []*typeStats
+		TopObjects  []nodeRow
+	}{
+		Params:      g.d.Params,
+		MemStats:    g.d.MemStats,
+		Objects:     len(g.d.Objects),
+		Size:        size,
+		Typed:       typed,
+		TypedSize:   typedSize,
+		Reachable:   g.reachable,
+		Unreachable: g.unreachable,
+		Goroutines:  len(g.d.Goroutines),
+		Roots:       g.nodeRows(g.dominated(0), 20),
+		Types:       types,
+		TopObjects:  g.nodeRows(g.topObjects(20), 20),
+	})
+}
+
+func (g *graph) httpRoots(w http.ResponseWriter, r *http.Request) {
+	g.execute(w, templNodes, struct {
+		Title string
+		Nodes []nodeRow
+	}{"Roots", g.nodeRows(g.topRoots(), len(g.roots))})
+}
+
+func (g *graph) httpObjects(w http.ResponseWriter, r *http.Request) {
+	g.execute(w, templNodes, struct {
+		Title string
+		Nodes []nodeRow
+	}{"Objects", g.nodeRows(g.topObjects(maxListed), maxListed)})
+}
+
+func (g *graph) httpTypes(w http.ResponseWriter, r *http.Request) {
+	g.execute(w, templTypes, g.types)
+}
+
+func (g *graph) httpType(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	for _, t := range g.types {
+		if t.Name == name {
+			g.execute(w, templNodes, struct {
+				Title string
+				Nodes []nodeRow
+			}{"Objects of type " + name, g.nodeRows(t.objects, maxListed)})
+			return
+		}
+	}
+	http.Error(w, fmt.Sprintf("no objects of type %s", name), http.StatusNotFound)
+}
+
+// httpObject redirects to the page of the object at an address.
+func (g *graph) httpObject(w http.ResponseWriter, r *http.Request) {
+	addr, err := strconv.ParseUint(r.FormValue("addr"), 0, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid address: %v", err), http.StatusBadRequest)
+		return
+	}
+	v := g.object(addr)
+	if v < 0 {
+		http.Error(w, fmt.Sprintf("no object at %#x", addr), http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/node?id=%d", v), http.StatusFound)
+}
+
+func (g *graph) httpNode(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 0 || id >= g.numNodes() {
+		http.Error(w, "invalid node", http.StatusBadRequest)
+		return
+	}
+	v := int32(id)
+	succ := uniq(g.succ(v))
+	sort.SliceStable(succ, func(i, j int) bool { return g.retained[succ[i]] > g.retained[succ[j]] })
+	data := struct {
+		Node       nodeRow
+		Reachable  bool
+		Type       string
+		Path       []nodeRow
+		Goroutine  *heapdump.Goroutine
+		Frame      *heapdump.Frame
+		Values     []valueRow
+		Truncated  bool
+		Dominated  []nodeRow
+		NDominated int
+		Succ       []nodeRow
+		NSucc      int
+		Preds      []nodeRow
+	}{
+		Node:       g.nodeRows([]int32{v}, 1)[0],
+		Reachable:  g.idom[v] >= 0,
+		Path:       g.nodeRows(g.path(v), len(g.roots)+g.numNodes()),
+		Dominated:  g.nodeRows(g.dominated(v), 100),
+		NDominated: len(g.dominated(v)),
+		Succ:       g.nodeRows(succ, 100),
+		NSucc:      len(succ),
+		Preds:      g.nodeRows(uniq(g.predecessors(v)), 100),
+	}
+	vw := &valueWriter{g: g}
+	if o := g.obj(v); o != nil {
+		if o.Type != nil {
+			data.Type = o.Type.Name
+			vw.value(o.Contents, o.Addr, "", o.Type, o.Addr)
+		} else {
+			data.Type = "unknown"
+			vw.words(o)
+		}
+	} else {
+		rt := g.roots[v]
+		data.Goroutine, data.Frame = rt.g, rt.frame
+		switch {
+		case rt.v != nil:
+			data.Type = rt.v.Type.Name
+			if mem := g.d.Memory(rt.v.Addr, int(rt.v.Type.Size)); mem != nil {
+				vw.value(mem, rt.v.Addr, "", rt.v.Type, rt.v.Addr)
+			}
+		case rt.frame != nil:
+			for _, fv := range rt.frame.Vars {
+				if mem := g.d.Memory(fv.Addr, int(fv.Type.Size)); mem != nil {
+					vw.value(mem, fv.Addr, fv.Name, fv.Type, rt.frame.Addr)
+				}
+			}
+		}
+	}
+	data.Values, data.Truncated = vw.rows, vw.truncated
+	g.execute(w, templNode, data)
+}
+
+// uniq returns the distinct nodes of vs.
+func uniq(vs []int32) []int32 {
+	seen := make(map[int32]bool)
+	var u []int32
+	for _, v := range vs {
+		if !seen[v] {
+			seen[v] = true
+			u = append(u, v)
+		}
+	}
+	return u
+}
+
+// A valueRow is a row of the contents of a node.
+type valueRow struct {
+	Offset uint64
+	Name   string
+	Type   string
+	Value  string
+	Link   int32 // node that the value points to, or -1
+}
+
+// A valueWriter describes values as rows.
+type valueWriter struct {
+	g         *graph
+	rows      []valueRow
+	truncated bool
+}
+
+// value describes the value of type t in the memory mem at address base,
+// with offsets relative to origin.
+func (w *valueWriter) value(mem []byte, base uint64, name string, t *heapdump.Type, origin uint64) {
+	w.value1(mem, base, name, t, base, origin)
+}
+
+func (w *valueWriter) value1(mem []byte, base uint64, name string, t *heapdump.Type, addr, origin uint64) {
+	off := addr - base
+	if t == nil || off+t.Size > uint64(len(mem)) {
+		return
+	}
+	if len(w.rows) >= maxRows {
+		w.truncated = true
+		return
+	}
+	d := w.g.d
+	ptrSize := uint64(d.Params.PtrSize)
+	b := mem[off : off+t.Size]
+	row := valueRow{Offset: addr - origin, Name: name, Type: t.Name, Link: -1}
+	switch t.Kind {
+	case heapdump.Bool:
+		row.Value = strconv.FormatBool(b[0] != 0)
+	case heapdump.Int, heapdump.Int8, heapdump.Int16, heapdump.Int32, heapdump.Int64:
+		v := w.uint(b)
+		shift := 64 - 8*t.Size
+		row.Value = strconv.FormatInt(int64(v<<shift)>>shift, 10)
+	case heapdump.Uint, heapdump.Uint8, heapdump.Uint16, heapdump.Uint32, heapdump.Uint64:
+		row.Value = strconv.FormatUint(w.uint(b), 10)
+	case heapdump.Uintptr:
+		row.Value = fmt.Sprintf("%#x", w.uint(b))
+	case heapdump.Float32:
+		row.Value = strconv.FormatFloat(float64(math.Float32frombits(uint32(w.uint(b)))), 'g', -1, 32)
+	case heapdump.Float64:
+		row.Value = strconv.FormatFloat(math.Float64frombits(w.uint(b)), 'g', -1, 64)
+	case heapdump.Complex64, heapdump.Complex128:
+		half := t.Size / 2
+		re, im := w.uint(b[:half]), w.uint(b[half:])
+		if half == 4 {
+			row.Value = fmt.Sprint(complex(math.Float32frombits(uint32(re)), math.Float32frombits(uint32(im))))
+		} else {
+			row.Value = fmt.Sprint(complex(math.Float64frombits(re), math.Float64frombits(im)))
+		}
+	case heapdump.Ptr, heapdump.UnsafePointer, heapdump.Map, heapdump.Chan, heapdump.Func:
+		p := w.uint(b)
+		row.Value = fmt.Sprintf("%#x", p)
+		row.Link = w.g.object(p)
+	case heapdump.String:
+		p, n := w.uint(b), w.uint(b[ptrSize:])
+		row.Value = w.quote(p, n)
+		row.Link = w.g.object(p)
+	case heapdump.Slice:
+		p := w.uint(b)
+		row.Value = fmt.Sprintf("%#x, len %d, cap %d", p, w.uint(b[ptrSize:]), w.uint(b[2*ptrSize:]))
+		row.Link = w.g.object(p)
+	case heapdump.Interface:
+		tw, p := w.uint(b), w.uint(b[ptrSize:])
+		switch dyn := d.DynamicType(t, tw); {
+		case tw == 0:
+			row.Value = "nil"
+		case dyn != nil:
+			row.Value = fmt.Sprintf("(%s) %#x", dyn.Name, p)
+		default:
+			row.Value = fmt.Sprintf("(%#x) %#x", tw, p)
+		}
+		row.Link = w.g.object(p)
+	case heapdump.Array:
+		if t.Elem == nil {
+			return
+		}
+		if k := t.Elem.Kind; k == heapdump.Uint8 || k == heapdump.Int8 {
+			row.Value = w.quote(addr, t.Len)
+			break
+		}
+		for i := uint64(0); i < t.Len && !w.truncated; i++ {
+			w.value1(mem, base, fmt.Sprintf("%s[%d]", name, i), t.Elem, addr+i*t.Elem.Size, origin)
+		}
+		return
+	case heapdump.Struct:
+		for _, f := range t.Fields {
+			fname := f.Name
+			if name != "" {
+				fname = name + "." + f.Name
+			}
+			w.value1(mem, base, fname, f.Type, addr+f.Offset, origin)
+		}
+		return
+	default:
+		row.Value = fmt.Sprintf("% x", b)
+	}
+	w.rows = append(w.rows, row)
+}
+
+// words describes the untyped object o as words.
+func (w *valueWriter) words(o *heapdump.Object) {
+	ptrSize := uint64(w.g.d.Params.PtrSize)
+	fields := o.Fields
+	for off := uint64(0); off+ptrSize <= o.Size(); off += ptrSize {
+		if len(w.rows) >= maxRows {
+			w.truncated = true
+			return
+		}
+		row := valueRow{Offset: off, Value: fmt.Sprintf("%#x", w.uint(o.Contents[off:off+ptrSize])), Link: -1}
+		for len(fields) > 0 && fields[0].Offset < off {
+			fields = fields[1:]
+		}
+		if len(fields) > 0 && fields[0].Offset == off {
+			row.Type = "pointer"
+			row.Link = w.g.object(w.uint(o.Contents[off : off+ptrSize]))
+		}
+		w.rows = append(w.rows, row)
+	}
+}
+
+// uint decodes the unsigned integer b.
+func (w *valueWriter) uint(b []byte) uint64 {
+	var bo binary.ByteOrder = binary.LittleEndian
+	if w.g.d.Params.BigEndian {
+		bo = binary.BigEndian
+	}
+	switch len(b) {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(bo.Uint16(b))
+	case 4:
+		return uint64(bo.Uint32(b))
+	case 8:
+		return bo.Uint64(b)
+	}
+	return 0
+}
+
+// quote returns the quoted start of the n bytes at p.
+func (w *valueWriter) quote(p, n uint64) string {
+	const max = 64
+	m := n
+	if m > max {
+		m = max
+	}
+	b := w.g.d.Memory(p, int(m))
+	if b == nil {
+		return fmt.Sprintf("%#x, len %d", p, n)
+	}
+	s := strconv.Quote(string(b))
+	if m < n {
+		s += fmt.Sprintf("... (len %d)", n)
+	}
+	return s
+}
+
+func (g *graph) execute(w http.ResponseWriter, t *template.Template, data interface{}) {
+	if err := t.Execute(w, data); err != nil {
+		log.Printf("failed to execute template: %v", err)
+	}
+}
+
+// formatBytes formats a size in bytes for humans.
+func formatBytes(n uint64) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%d B", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	case n < 1<<30:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	}
+	return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+}
+
+var funcs = template.FuncMap{
+	"bytes": formatBytes,
+	"percent": func(part, total uint64) string {
+		if total == 0 {
+			return "0%"
+		}
+		return fmt.Sprintf("%.1f%%", 100*float64(part)/float64(total))
+	},
+}
+
+func parse(content string) *template.Template {
+	return template.Must(template.Must(template.New("").Funcs(funcs).Parse(templLayout)).Parse(content))
+}
+
+const templLayout = `
+{{define "nodes"}}
+<table>
+<tr><th>Retained</th><th>Size</th><th></th></tr>
+{{range .}}
+<tr><td class="num">{{bytes .Retained}}</td><td class="num">{{bytes .Size}}</td><td><a href="/node?id={{.ID}}">{{.Label}}</a></td></tr>
+{{end}}
+</table>
+{{end}}
+{{define "header"}}
+<!DOCTYPE html>
+<html>
+<head>
+<title>Heap viewer</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+td, th { padding: 0 8px; text-align: left; }
+td.num { text-align: right; white-space: nowrap; }
+tr:nth-child(even) { background: #f0f0f0; }
+code, td.code { font-family: monospace; }
+</style>
+</head>
+<body>
+<p><a href="/">Summary</a> | <a href="/roots">Roots</a> | <a href="/types">Types</a> | <a href="/objects">Objects</a></p>
+{{end}}
+{{define "footer"}}
+</body>
+</html>
+{{end}}
+`
+
+var templMain = parse(`{{template "header"}}
+<h1>Heap dump</h1>
+<p>
+{{with .Params}}{{if .GoVersion}}{{.GoVersion}}, {{end}}{{.GOARCH}}, {{.NCPU}} CPUs.{{end}}
+{{.Objects}} objects, {{bytes .Size}}, of which {{.Reachable}} are reachable
+and {{bytes .Unreachable}} are unreachable garbage.
+{{.Typed}} objects ({{percent .TypedSize .Size}} of the heap) have known types.
+{{.Goroutines}} goroutines.
+</p>
+<p>
+The retained size of an object or a root is the size of the objects
+that it dominates: they would be unreachable without it, and so are
+those it points to that aren't reachable otherwise.
+</p>
+<h2>Top roots</h2>
+{{template "nodes" .Roots}}
+<p><a href="/roots">All roots</a></p>
+<h2>Top types</h2>
+<table>
+<tr><th>Retained</th><th>Size</th><th>Count</th><th></th></tr>
+{{range .Types}}
+<tr><td class="num">{{bytes .Retained}}</td><td class="num">{{bytes .Size}}</td><td class="num">{{.Count}}</td><td><a href="/type?name={{.Name}}">{{.Name}}</a></td></tr>
+{{end}}
+</table>
+<p><a href="/types">All types</a></p>
+<h2>Top objects</h2>
+{{template "nodes" .TopObjects}}
+<p><a href="/objects">More objects</a></p>
+{{with .MemStats}}
+<h2>Memory statistics</h2>
+<table>
+<tr><td>HeapAlloc</td><td class="num">{{bytes .HeapAlloc}}</td></tr>
+<tr><td>HeapSys</td><td class="num">{{bytes .HeapSys}}</td></tr>
+<tr><td>HeapObjects</td><td class="num">{{.HeapObjects}}</td></tr>
+<tr><td>StackInuse</td><td class="num">{{bytes .StackInuse}}</td></tr>
+<tr><td>Sys</td><td class="num">{{bytes .Sys}}</td></tr>
+<tr><td>NumGC</td><td class="num">{{.NumGC}}</td></tr>
+</table>
+{{end}}
+{{template "footer"}}
+`)
+
+var templNodes = parse(`{{template "header"}}
+<h1>{{.Title}}</h1>
+{{template "nodes" .Nodes}}
+{{template "footer"}}
+`)
+
+var templTypes = parse(`{{template "header"}}
+<h1>Types</h1>
+<p>The retained size of a type is the size retained by its objects,
+except the objects retained by other objects of the type.</p>
+<table>
+<tr><th>Retained</th><th>Size</th><th>Count</th><th></th></tr>
+{{range .}}
+<tr><td class="num">{{bytes .Retained}}</td><td class="num">{{bytes .Size}}</td><td class="num">{{.Count}}</td><td><a href="/type?name={{.Name}}">{{.Name}}</a></td></tr>
+{{end}}
+</table>
+{{template "footer"}}
+`)
+
+var templNode = parse(`{{template "header"}}
+<h1>{{.Node.Label}}</h1>
+<p>
+{{if .Type}}Type <code>{{.Type}}</code>, size{{else}}Size{{end}} {{bytes .Node.Size}},
+retained size {{bytes .Node.Retained}}.
+{{if not .Reachable}}Unreachable.{{end}}
+</p>
+{{with .Goroutine}}
+<p>Goroutine {{.ID}}{{if .WaitReason}}, {{.WaitReason}}{{end}}{{if .System}}, system goroutine{{end}}.</p>
+{{end}}
+{{with .Frame}}
+<p>Function <code>{{.Func}}</code>, stack frame at {{printf "%#x" .Addr}}, PC {{printf "%#x" .PC}}.</p>
+{{end}}
+{{if .Path}}
+<h2>Dominated by</h2>
+{{template "nodes" .Path}}
+{{end}}
+{{if .Values}}
+<h2>Contents</h2>
+<table>
+<tr><th>Offset</th><th>Name</th><th>Type</th><th>Value</th></tr>
+{{range .Values}}
+<tr><td class="num">{{.Offset}}</td><td class="code">{{.Name}}</td><td class="code">{{.Type}}</td>
+<td class="code">{{if ge .Link 0}}<a href="/node?id={{.Link}}">{{.Value}}</a>{{else}}{{.Value}}{{end}}</td></tr>
+{{end}}
+</table>
+{{if .Truncated}}<p>Truncated.</p>{{end}}
+{{end}}
+{{if .Dominated}}
+<h2>Dominates</h2>
+<p>{{.NDominated}} nodes.</p>
+{{template "nodes" .Dominated}}
+{{end}}
+{{if .Succ}}
+<h2>Points to</h2>
+<p>{{.NSucc}} nodes.</p>
+{{template "nodes" .Succ}}
+{{end}}
+{{if .Preds}}
+<h2>Referenced by</h2>
+{{template "nodes" .Preds}}
+{{end}}
+{{template "footer"}}
+`)
@@ -0,0 +1,140 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"cmd/internal/browser"
+	"debug/heapdump"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"text/tabwriter"
+)
+
+const usageMessage = "" +
+	`Usage of 'go tool heapview':
+Given a heap dump written by runtime/debug.WriteHeapDump:
+	debug.WriteHeapDump(f.Fd())
+
+Open a web browser showing what retains the memory of the heap:
+	go tool heapview [flags] [binary] heapdump
+
+Print the roots, types and objects that retain the most memory:
+	go tool heapview -top=N [binary] heapdump
+
+The binary argument is the executable that wrote the heap dump,
+built with its debug information (without -ldflags=-w).
+Without it, the types of the objects are unknown.
+
+Flags:
+	-http=addr: HTTP service address (e.g., ':6060')
+	-top=n: print the n top retainers of each kind instead
+`
+
+var (
+	httpFlag = flag.String("http", "localhost:0", "HTTP service address (e.g., ':6060')")
+	topFlag  = flag.Int("top", 0, "print the `n` top retainers of each kind instead")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usageMessage)
+		os.Exit(2)
+	}
+	flag.Parse()
+
+	var programBinary, dumpFile string
+	switch flag.NArg() {
+	case 1:
+		dumpFile = flag.Arg(0)
+	case 2:
+		programBinary = flag.Arg(0)
+		dumpFile = flag.Arg(1)
+	default:
+		flag.Usage()
+	}
+
+	log.Print("Reading heap dump...")
+	d, err := readDump(dumpFile)
+	if err != nil {
+		dief("%v\n", err)
+	}
+	if programBinary != "" {
+		log.Print("Loading types...")
+		if err := d.LoadTypes(programBinary); err != nil {
+			dief("%v\n", err)
+		}
+	}
+	log.Print("Computing dominators...")
+	g := newGraph(d)
+
+	if *topFlag > 0 {
+		w := bufio.NewWriter(os.Stdout)
+		printTop(w, g, *topFlag)
+		if err := w.Flush(); err != nil {
+			dief("%v\n", err)
+		}
+		return
+	}
+
+	ln, err := net.Listen("tcp", *httpFlag)
+	if err != nil {
+		dief("failed to create server socket: %v\n", err)
+	}
+	addr := "http://" + ln.Addr().String()
+	log.Printf("Opening browser. Heap viewer is listening on %s", addr)
+	browser.Open(addr)
+
+	g.register(http.DefaultServeMux)
+	err = http.Serve(ln, nil)
+	dief("failed to start http server: %v\n", err)
+}
+
+func readDump(name string) (*heapdump.Dump, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return heapdump.Read(f)
+}
+
+// printTop prints the n roots, types and objects of g
+// that retain the most memory.
+func printTop(w io.Writer, g *graph, n int) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	defer tw.Flush()
+	fmt.Fprintf(tw, "retained\tsize\t root\n")
+	for i, v := range g.topRoots() {
+		if i == n {
+			break
+		}
+		fmt.Fprintf(tw, "%d\t%d\t %s\n", g.retained[v], g.size(v), g.label(v))
+	}
+	fmt.Fprintf(tw, "\nretained\tsize\tcount\t type\n")
+	for i, t := range g.types {
+		if i == n {
+			break
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%d\t %s\n", t.Retained, t.Size, t.Count, t.Name)
+	}
+	fmt.Fprintf(tw, "\nretained\tsize\t object\n")
+	for i, v := range g.topObjects(n) {
+		if i == n {
+			break
+		}
+		fmt.Fprintf(tw, "%d\t%d\t %s\n", g.retained[v], g.size(v), g.label(v))
+	}
+}
+
+func dief(msg string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, msg, args...)
+	os.Exit(1)
+}
@@ -6,9 +6,9 @@ package main
 
 import (
 	"bytes"
+	dtrace "debug/trace"
 	"fmt"
 	"html/template"
-	"internal/trace"
 	"log"
 	"math"
 	"net/http"
@@ -175,7 +175,7 @@ func httpUserTask(w http.ResponseWriter, r *http.Request) {
 	}
 	tasks := res.tasks
 
-	type event struct {
+	type taskEvent struct {
 		WhenString string
 		Elapsed    time.Duration
 		Go         uint64
@@ -187,7 +187,7 @@ func httpUserTask(w http.ResponseWriter, r *http.Request) {
 		ID         uint64
 		Duration   time.Duration
 		Complete   bool
-		Events     []event
+		Events     []taskEvent
 		Start, End time.Duration // Time since the beginning of the trace
 		GCTime     time.Duration
 	}
@@ -201,32 +201,32 @@ func httpUserTask(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		// merge events in the task.events and task.regions.Start
-		rawEvents := append([]*trace.Event{}, task.events...)
+		rawEvents := append([]*event{}, task.events...)
 		for _, s := range task.regions {
 			if s.Start != nil {
 				rawEvents = append(rawEvents, s.Start)
 			}
 		}
-		sort.SliceStable(rawEvents, func(i, j int) bool { return rawEvents[i].Ts < rawEvents[j].Ts })
+		sort.SliceStable(rawEvents, func(i, j int) bool { return rawEvents[i].Time < rawEvents[j].Time })
 
-		var events []event
+		var events []taskEvent
 		var last time.Duration
 		for i, ev := range rawEvents {
-			when := time.Duration(ev.Ts)*time.Nanosecond - base
-			elapsed := time.Duration(ev.Ts)*time.Nanosecond - last
+			when := ev.Time - base
+			elapsed := ev.Time - last
 			if i == 0 {
 				elapsed = 0
 			}
 
 			what := describeEvent(ev)
 			if what != "" {
-				events = append(events, event{
+				events = append(events, taskEvent{
 					WhenString: fmt.Sprintf("%2.9f", when.Seconds()),
 					Elapsed:    elapsed,
 					What:       what,
 					Go:         ev.G,
 				})
-				last = time.Duration(ev.Ts) * time.Nanosecond
+				last = ev.Time
 			}
 		}
 
@@ -263,41 +263,39 @@ func httpUserTask(w http.ResponseWriter, r *http.Request) {
 type annotationAnalysisResult struct {
 	tasks    map[uint64]*taskDesc          // tasks
 	regions  map[regionTypeID][]regionDesc // regions
-	gcEvents []*trace.Event                // GCStartevents, sorted
+	gcEvents []*event                      // GC mark phase begin events, sorted
 }
 
 type regionTypeID struct {
-	Frame trace.Frame // top frame
+	Frame dtrace.Frame // top frame
 	Type  string
 }
 
 // analyzeAnnotations analyzes user annotation events and
 // returns the task descriptors keyed by internal task id.
 func analyzeAnnotations() (annotationAnalysisResult, error) {
-	res, err := parseTrace()
+	events, err := parseEvents()
 	if err != nil {
 		return annotationAnalysisResult{}, fmt.Errorf("failed to parse trace: %v", err)
 	}
 
-	events := res.Events
 	if len(events) == 0 {
 		return annotationAnalysisResult{}, fmt.Errorf("empty trace")
 	}
 
 	tasks := allTasks{}
 	regions := map[regionTypeID][]regionDesc{}
-	var gcEvents []*trace.Event
+	var gcEvents []*event
 
 	for _, ev := range events {
-		switch typ := ev.Type; typ {
-		case trace.EvUserTaskCreate, trace.EvUserTaskEnd, trace.EvUserLog:
-			taskid := ev.Args[0]
-			task := tasks.task(taskid)
+		switch kind := ev.Kind; kind {
+		case dtrace.EventTaskBegin, dtrace.EventTaskEnd, dtrace.EventLog:
+			task := tasks.task(ev.Task)
 			task.addEvent(ev)
 
 			// retrieve parent task information
-			if typ == trace.EvUserTaskCreate {
-				if parentID := ev.Args[1]; parentID != 0 {
+			if kind == dtrace.EventTaskBegin {
+				if parentID := ev.Parent; parentID != 0 {
 					parentTask := tasks.task(parentID)
 					task.parent = parentTask
 					if parentTask != nil {
@@ -306,8 +304,10 @@ func analyzeAnnotations() (annotationAnalysisResult, error) {
 				}
 			}
 
-		case trace.EvGCStart:
-			gcEvents = append(gcEvents, ev)
+		case dtrace.EventRangeBegin:
+			if ev.Name == gcRange {
+				gcEvents = append(gcEvents, ev)
+			}
 		}
 	}
 	// combine region info.
@@ -322,9 +322,9 @@ func analyzeAnnotations() (annotationAnalysisResult, error) {
 				task.goroutines[goid] = struct{}{}
 				task.regions = append(task.regions, regionDesc{UserRegionDesc: s, G: goid})
 			}
-			var frame trace.Frame
-			if s.Start != nil {
-				frame = *s.Start.Stk[0]
+			var frame dtrace.Frame
+			if s.Start != nil && len(s.Start.Stack) > 0 {
+				frame = s.Start.Stack[0]
 			}
 			id := regionTypeID{Frame: frame, Type: s.Name}
 			regions[id] = append(regions[id], regionDesc{UserRegionDesc: s, G: goid})
@@ -348,12 +348,12 @@ func analyzeAnnotations() (annotationAnalysisResult, error) {
 type taskDesc struct {
 	name       string              // user-provided task name
 	id         uint64              // internal task id
-	events     []*trace.Event      // sorted based on timestamp.
+	events     []*event            // sorted based on timestamp.
 	regions    []regionDesc        // associated regions, sorted based on the start timestamp and then the last timestamp.
 	goroutines map[uint64]struct{} // involved goroutines
 
-	create *trace.Event // Task create event
-	end    *trace.Event // Task end event
+	create *event // Task begin event
+	end    *event // Task end event
 
 	parent   *taskDesc
 	children []*taskDesc
@@ -391,7 +391,7 @@ func (task *taskDesc) String() string {
 
 // regionDesc represents a region.
 type regionDesc struct {
-	*trace.UserRegionDesc
+	*UserRegionDesc
 	G uint64 // id of goroutine where the region was defined
 }
 
@@ -415,7 +415,7 @@ func (tasks allTasks) task(taskID uint64) *taskDesc {
 	return t
 }
 
-func (task *taskDesc) addEvent(ev *trace.Event) {
+func (task *taskDesc) addEvent(ev *event) {
 	if task == nil {
 		return
 	}
@@ -423,11 +423,11 @@ func (task *taskDesc) addEvent(ev *trace.Event) {
 	task.events = append(task.events, ev)
 	task.goroutines[ev.G] = struct{}{}
 
-	switch typ := ev.Type; typ {
-	case trace.EvUserTaskCreate:
-		task.name = ev.SArgs[0]
+	switch ev.Kind {
+	case dtrace.EventTaskBegin:
+		task.name = ev.Name
 		task.create = ev
-	case trace.EvUserTaskEnd:
+	case dtrace.EventTaskEnd:
 		task.end = ev
 	}
 }
@@ -461,7 +461,7 @@ func (task *taskDesc) descendants() []*taskDesc {
 // the first timestamp of the trace will be returned.
 func (task *taskDesc) firstTimestamp() int64 {
 	if task != nil && task.create != nil {
-		return task.create.Ts
+		return task.create.ts()
 	}
 	return firstTimestamp()
 }
@@ -471,8 +471,8 @@ func (task *taskDesc) firstTimestamp() int64 {
 // timestamp of the trace will be returned.
 func (task *taskDesc) lastTimestamp() int64 {
 	endTs := task.endTimestamp()
-	if last := task.lastEvent(); last != nil && last.Ts > endTs {
-		return last.Ts
+	if last := task.lastEvent(); last != nil && last.ts() > endTs {
+		return last.ts()
 	}
 	return endTs
 }
@@ -482,7 +482,7 @@ func (task *taskDesc) lastTimestamp() int64 {
 // timestamp of the trace will be returned.
 func (task *taskDesc) endTimestamp() int64 {
 	if task != nil && task.end != nil {
-		return task.end.Ts
+		return task.end.ts()
 	}
 	return lastTimestamp()
 }
@@ -496,10 +496,10 @@ func (region *regionDesc) duration() time.Duration {
 }
 
 // overlappingGCDuration returns the sum of GC period overlapping with the task's lifetime.
-func (task *taskDesc) overlappingGCDuration(evs []*trace.Event) (overlapping time.Duration) {
+func (task *taskDesc) overlappingGCDuration(evs []*event) (overlapping time.Duration) {
 	for _, ev := range evs {
 		// make sure we only consider the global GC events.
-		if typ := ev.Type; typ != trace.EvGCStart && typ != trace.EvGCSTWStart {
+		if ev.Kind != dtrace.EventRangeBegin || ev.Name != gcRange && !strings.HasPrefix(ev.Name, stwRange) {
 			continue
 		}
 
@@ -513,18 +513,18 @@ func (task *taskDesc) overlappingGCDuration(evs []*trace.Event) (overlapping tim
 // overlappingInstant reports whether the instantaneous event, ev, occurred during
 // any of the task's region if ev is a goroutine-local event, or overlaps with the
 // task's lifetime if ev is a global event.
-func (task *taskDesc) overlappingInstant(ev *trace.Event) bool {
-	if _, ok := isUserAnnotationEvent(ev); ok && task.id != ev.Args[0] {
+func (task *taskDesc) overlappingInstant(ev *event) bool {
+	if _, ok := isUserAnnotationEvent(ev); ok && task.id != ev.Task {
 		return false // not this task's user event.
 	}
 
-	ts := ev.Ts
+	ts := ev.ts()
 	taskStart := task.firstTimestamp()
 	taskEnd := task.endTimestamp()
 	if ts < taskStart || taskEnd < ts {
 		return false
 	}
-	if ev.P == trace.GCP {
+	if ev.P == gcP {
 		return true
 	}
 
@@ -545,11 +545,11 @@ func (task *taskDesc) overlappingInstant(ev *trace.Event) bool {
 // any of the task's region if ev is a goroutine-local event, or overlaps with
 // the task's lifetime if ev is a global event. It returns the overlapping time
 // as well.
-func (task *taskDesc) overlappingDuration(ev *trace.Event) (time.Duration, bool) {
-	start := ev.Ts
+func (task *taskDesc) overlappingDuration(ev *event) (time.Duration, bool) {
+	start := ev.ts()
 	end := lastTimestamp()
-	if ev.Link != nil {
-		end = ev.Link.Ts
+	if ev.link != nil {
+		end = ev.link.ts()
 	}
 
 	if start > end {
@@ -558,12 +558,12 @@ func (task *taskDesc) overlappingDuration(ev *trace.Event) (time.Duration, bool)
 
 	goid := ev.G
 	goid2 := ev.G
-	if ev.Link != nil {
-		goid2 = ev.Link.G
+	if ev.link != nil {
+		goid2 = ev.link.G
 	}
 
 	// This event is a global GC event
-	if ev.P == trace.GCP {
+	if ev.P == gcP {
 		taskStart := task.firstTimestamp()
 		taskEnd := task.endTimestamp()
 		o := overlappingDuration(taskStart, taskEnd, start, end)
@@ -609,7 +609,7 @@ func overlappingDuration(start1, end1, start2, end2 int64) time.Duration {
 	return time.Duration(end1 - start1)
 }
 
-func (task *taskDesc) lastEvent() *trace.Event {
+func (task *taskDesc) lastEvent() *event {
 	if task == nil {
 		return nil
 	}
@@ -625,7 +625,7 @@ func (task *taskDesc) lastEvent() *trace.Event {
 // the first timestamp of the trace will be returned.
 func (region *regionDesc) firstTimestamp() int64 {
 	if region.Start != nil {
-		return region.Start.Ts
+		return region.Start.ts()
 	}
 	return firstTimestamp()
 }
@@ -635,7 +635,7 @@ func (region *regionDesc) firstTimestamp() int64 {
 // the last timestamp of the trace will be returned.
 func (region *regionDesc) lastTimestamp() int64 {
 	if region.End != nil {
-		return region.End.Ts
+		return region.End.ts()
 	}
 	return lastTimestamp()
 }
@@ -644,7 +644,7 @@ func (region *regionDesc) lastTimestamp() int64 {
 // is related to the task if user annotation activities for the task occurred.
 // If non-zero depth is provided, this searches all events with BFS and includes
 // goroutines unblocked any of related goroutines to the result.
-func (task *taskDesc) RelatedGoroutines(events []*trace.Event, depth int) map[uint64]bool {
+func (task *taskDesc) RelatedGoroutines(events []*event, depth int) map[uint64]bool {
 	start, end := task.firstTimestamp(), task.endTimestamp()
 
 	gmap := map[uint64]bool{}
@@ -658,10 +658,10 @@ func (task *taskDesc) RelatedGoroutines(events []*trace.Event, depth int) map[ui
 			gmap1[g] = true
 		}
 		for _, ev := range events {
-			if ev.Ts < start || ev.Ts > end {
+			if ev.ts() < start || ev.ts() > end {
 				continue
 			}
-			if ev.Type == trace.EvGoUnblock && gmap[ev.Args[0]] {
+			if isUnblock(ev) && gmap[ev.Goroutine] {
 				gmap1[ev.G] = true
 			}
 			gmap = gmap1
@@ -738,12 +738,14 @@ func newTaskFilter(r *http.Request) (*taskFilter, error) {
 
 func taskMatches(t *taskDesc, text string) bool {
 	for _, ev := range t.events {
-		switch ev.Type {
-		case trace.EvUserTaskCreate, trace.EvUserRegion, trace.EvUserLog:
-			for _, s := range ev.SArgs {
-				if strings.Contains(s, text) {
-					return true
-				}
+		switch ev.Kind {
+		case dtrace.EventTaskBegin, dtrace.EventRegionBegin, dtrace.EventRegionEnd:
+			if strings.Contains(ev.Name, text) {
+				return true
+			}
+		case dtrace.EventLog:
+			if strings.Contains(ev.Category, text) || strings.Contains(ev.Message, text) {
+				return true
 			}
 		}
 	}
@@ -959,7 +961,7 @@ var templUserRegionTypes = template.Must(template.New("").Parse(`
 </tr>
 {{range $}}
   <tr>
-    <td>{{.Type}}<br>{{.Frame.Fn}}<br>{{.Frame.File}}:{{.Frame.Line}}</td>
+    <td>{{.Type}}<br>{{.Frame.Func}}<br>{{.Frame.File}}:{{.Frame.Line}}</td>
     <td><a href="/userregion?type={{.Type}}&pc={{.Frame.PC | printf "%x"}}">{{.Histogram.Count}}</a></td>
     <td>{{.Histogram.ToHTML (.UserRegionURL)}}</td>
   </tr>
@@ -1125,8 +1127,8 @@ func asMillisecond(d time.Duration) float64 {
 	return float64(d.Nanoseconds()) / 1e6
 }
 
-func formatUserLog(ev *trace.Event) string {
-	k, v := ev.SArgs[0], ev.SArgs[1]
+func formatUserLog(ev *event) string {
+	k, v := ev.Category, ev.Message
 	if k == "" {
 		return v
 	}
@@ -1136,37 +1138,39 @@ func formatUserLog(ev *trace.Event) string {
 	return fmt.Sprintf("%v=%v", k, v)
 }
 
-func describeEvent(ev *trace.Event) string {
-	switch ev.Type {
-	case trace.EvGoCreate:
-		goid := ev.Args[0]
-		return fmt.Sprintf("new goroutine %d: %s", goid, gs[goid].Name)
-	case trace.EvGoEnd, trace.EvGoStop:
-		return "goroutine stopped"
-	case trace.EvUserLog:
+func describeEvent(ev *event) string {
+	switch ev.Kind {
+	case dtrace.EventGoroutine:
+		switch {
+		case ev.From == dtrace.GoNotExist:
+			goid := ev.Goroutine
+			return fmt.Sprintf("new goroutine %d: %s", goid, gs[goid].Name)
+		case ev.To == dtrace.GoNotExist, ev.Reason == "forever":
+			return "goroutine stopped"
+		}
+	case dtrace.EventLog:
 		return formatUserLog(ev)
-	case trace.EvUserRegion:
-		if ev.Args[1] == 0 {
-			duration := "unknown"
-			if ev.Link != nil {
-				duration = (time.Duration(ev.Link.Ts-ev.Ts) * time.Nanosecond).String()
-			}
-			return fmt.Sprintf("region %s started (duration: %v)", ev.SArgs[0], duration)
+	case dtrace.EventRegionBegin:
+		duration := "unknown"
+		if ev.link != nil {
+			duration = (ev.link.Time - ev.Time).String()
 		}
-		return fmt.Sprintf("region %s ended", ev.SArgs[0])
-	case trace.EvUserTaskCreate:
-		return fmt.Sprintf("task %v (id %d, parent %d) created", ev.SArgs[0], ev.Args[0], ev.Args[1])
+		return fmt.Sprintf("region %s started (duration: %v)", ev.Name, duration)
+	case dtrace.EventRegionEnd:
+		return fmt.Sprintf("region %s ended", ev.Name)
+	case dtrace.EventTaskBegin:
+		return fmt.Sprintf("task %v (id %d, parent %d) created", ev.Name, ev.Task, ev.Parent)
 		// TODO: add child task creation events into the parent task events
-	case trace.EvUserTaskEnd:
+	case dtrace.EventTaskEnd:
 		return "task end"
 	}
 	return ""
 }
 
-func isUserAnnotationEvent(ev *trace.Event) (taskID uint64, ok bool) {
-	switch ev.Type {
-	case trace.EvUserLog, trace.EvUserRegion, trace.EvUserTaskCreate, trace.EvUserTaskEnd:
-		return ev.Args[0], true
+func isUserAnnotationEvent(ev *event) (taskID uint64, ok bool) {
+	switch ev.Kind {
+	case dtrace.EventLog, dtrace.EventRegionBegin, dtrace.EventRegionEnd, dtrace.EventTaskBegin, dtrace.EventTaskEnd:
+		return ev.Task, true
 	}
 	return 0, false
 }
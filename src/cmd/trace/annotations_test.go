@@ -9,6 +9,7 @@ package main
 import (
 	"bytes"
 	"context"
+	dtrace "debug/trace"
 	"flag"
 	"fmt"
 	traceparser "internal/trace"
@@ -279,14 +280,14 @@ func TestAnalyzeAnnotationGC(t *testing.T) {
 	// Check collected GC Start events are all sorted and non-overlapping.
 	lastTS := int64(0)
 	for i, ev := range res.gcEvents {
-		if ev.Type != traceparser.EvGCStart {
+		if ev.Kind != dtrace.EventRangeBegin || ev.Name != gcRange {
 			t.Errorf("unwanted event in gcEvents: %v", ev)
 		}
-		if i > 0 && lastTS > ev.Ts {
+		if i > 0 && lastTS > ev.ts() {
 			t.Errorf("overlapping GC events:\n%d: %v\n%d: %v", i-1, res.gcEvents[i-1], i, res.gcEvents[i])
 		}
-		if ev.Link != nil {
-			lastTS = ev.Link.Ts
+		if ev.link != nil {
+			lastTS = ev.link.ts()
 		}
 	}
 
@@ -310,7 +311,7 @@ func TestAnalyzeAnnotationGC(t *testing.T) {
 				buf := new(bytes.Buffer)
 				fmt.Fprintln(buf, "GC Events")
 				for _, ev := range res.gcEvents {
-					fmt.Fprintf(buf, " %s -> %s\n", ev, ev.Link)
+					fmt.Fprintf(buf, " %s -> %s\n", ev, ev.link)
 				}
 				fmt.Fprintln(buf, "Events in Task")
 				for i, ev := range task.events {
@@ -338,14 +339,14 @@ func traceProgram(t *testing.T, f func(), name string) error {
 	trace.Stop()
 
 	saveTrace(buf, name)
-	res, err := traceparser.Parse(buf, name+".faketrace")
+	events, err := readEvents(buf)
 	if err == traceparser.ErrTimeOrder {
 		t.Skipf("skipping due to golang.org/issue/16755: %v", err)
 	} else if err != nil {
 		return err
 	}
 
-	swapLoaderData(res, err)
+	swapLoaderData(events, err)
 	return nil
 }
 
@@ -370,15 +371,15 @@ func childrenNames(task *taskDesc) (ret []string) {
 	return ret
 }
 
-func swapLoaderData(res traceparser.ParseResult, err error) {
+func swapLoaderData(events []*event, err error) {
 	// swap loader's data.
-	parseTrace() // fool loader.once.
+	parseEvents() // fool loader.once.
 
-	loader.res = res
+	loader.events = events
 	loader.err = err
 
 	analyzeGoroutines(nil) // fool gsInit once.
-	gs = traceparser.GoroutineStats(res.Events)
+	gs = goroutineStats(events)
 
 }
 
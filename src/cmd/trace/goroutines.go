@@ -7,9 +7,9 @@
 package main
 
 import (
+	dtrace "debug/trace"
 	"fmt"
 	"html/template"
-	"internal/trace"
 	"log"
 	"net/http"
 	"reflect"
@@ -34,16 +34,355 @@ type gtype struct {
 
 var (
 	gsInit sync.Once
-	gs     map[uint64]*trace.GDesc
+	gs     map[uint64]*GDesc
 )
 
 // analyzeGoroutines generates statistics about execution of all goroutines and stores them in gs.
-func analyzeGoroutines(events []*trace.Event) {
+func analyzeGoroutines(events []*event) {
 	gsInit.Do(func() {
-		gs = trace.GoroutineStats(events)
+		gs = goroutineStats(events)
 	})
 }
 
+// GDesc contains statistics and execution details of a single goroutine.
+type GDesc struct {
+	ID           uint64
+	Name         string
+	PC           uint64
+	CreationTime int64
+	StartTime    int64
+	EndTime      int64
+
+	// List of regions in the goroutine, sorted based on the start time.
+	Regions []*UserRegionDesc
+
+	// Statistics of execution time during the goroutine execution.
+	GExecutionStat
+
+	*gdesc // private part.
+}
+
+// UserRegionDesc represents a region and goroutine execution stats
+// while the region was active.
+type UserRegionDesc struct {
+	TaskID uint64
+	Name   string
+
+	// Region start event. Normally a region begin event or nil,
+	// but can be the creation of the goroutine if the region is a
+	// synthetic region representing task inheritance from the
+	// parent goroutine.
+	Start *event
+
+	// Region end event. Normally a region end event or nil,
+	// but can be the goroutine stopping if the goroutine
+	// terminated without explicitly ending the region.
+	End *event
+
+	GExecutionStat
+}
+
+// GExecutionStat contains statistics about a goroutine's execution
+// during a period of time.
+type GExecutionStat struct {
+	ExecTime      int64
+	SchedWaitTime int64
+	IOTime        int64
+	BlockTime     int64
+	SyscallTime   int64
+	GCTime        int64
+	SweepTime     int64
+	TotalTime     int64
+}
+
+// sub returns the stats v-s.
+func (s GExecutionStat) sub(v GExecutionStat) (r GExecutionStat) {
+	r = s
+	r.ExecTime -= v.ExecTime
+	r.SchedWaitTime -= v.SchedWaitTime
+	r.IOTime -= v.IOTime
+	r.BlockTime -= v.BlockTime
+	r.SyscallTime -= v.SyscallTime
+	r.GCTime -= v.GCTime
+	r.SweepTime -= v.SweepTime
+	r.TotalTime -= v.TotalTime
+	return r
+}
+
+// snapshotStat returns the snapshot of the goroutine execution statistics.
+// This is called as we process the ordered trace event stream. lastTs and
+// activeGCStartTime are used to process pending statistics if this is called
+// before any goroutine end event.
+func (g *GDesc) snapshotStat(lastTs, activeGCStartTime int64) (ret GExecutionStat) {
+	ret = g.GExecutionStat
+
+	if g.gdesc == nil {
+		return ret // finalized GDesc. No pending state.
+	}
+
+	if activeGCStartTime != 0 { // terminating while GC is active
+		if g.CreationTime < activeGCStartTime {
+			ret.GCTime += lastTs - activeGCStartTime
+		} else {
+			// The goroutine's lifetime completely overlaps
+			// with a GC.
+			ret.GCTime += lastTs - g.CreationTime
+		}
+	}
+
+	if g.TotalTime == 0 {
+		ret.TotalTime = lastTs - g.CreationTime
+	}
+
+	if g.lastStartTime != 0 {
+		ret.ExecTime += lastTs - g.lastStartTime
+	}
+	if g.blockNetTime != 0 {
+		ret.IOTime += lastTs - g.blockNetTime
+	}
+	if g.blockSyncTime != 0 {
+		ret.BlockTime += lastTs - g.blockSyncTime
+	}
+	if g.blockSyscallTime != 0 {
+		ret.SyscallTime += lastTs - g.blockSyscallTime
+	}
+	if g.blockSchedTime != 0 {
+		ret.SchedWaitTime += lastTs - g.blockSchedTime
+	}
+	if g.blockSweepTime != 0 {
+		ret.SweepTime += lastTs - g.blockSweepTime
+	}
+	return ret
+}
+
+// finalize is called when processing a goroutine end event or at
+// the end of trace processing. This finalizes the execution stat
+// and any active regions in the goroutine, in which case trigger is nil.
+func (g *GDesc) finalize(lastTs, activeGCStartTime int64, trigger *event) {
+	if trigger != nil {
+		g.EndTime = trigger.ts()
+	}
+	finalStat := g.snapshotStat(lastTs, activeGCStartTime)
+
+	g.GExecutionStat = finalStat
+	for _, s := range g.activeRegions {
+		s.End = trigger
+		s.GExecutionStat = finalStat.sub(s.GExecutionStat)
+		g.Regions = append(g.Regions, s)
+	}
+	*(g.gdesc) = gdesc{}
+}
+
+// gdesc is a private part of GDesc that is required only during analysis.
+type gdesc struct {
+	lastStartTime    int64
+	blockNetTime     int64
+	blockSyncTime    int64
+	blockSyscallTime int64
+	blockSweepTime   int64
+	blockGCTime      int64
+	blockSchedTime   int64
+
+	activeRegions []*UserRegionDesc // stack of active regions
+}
+
+// goroutineStats generates statistics for all goroutines in the trace.
+func goroutineStats(events []*event) map[uint64]*GDesc {
+	gs := make(map[uint64]*GDesc)
+	var lastTs int64
+	var gcStartTime int64 // gcStartTime == 0 indicates gc is inactive.
+	for _, ev := range events {
+		lastTs = ev.ts()
+		switch ev.Kind {
+		case dtrace.EventGoroutine:
+			switch from, to := ev.From, ev.To; {
+			case from == dtrace.GoNotExist:
+				g := &GDesc{ID: ev.Goroutine, CreationTime: ev.ts(), gdesc: new(gdesc)}
+				g.blockSchedTime = ev.ts()
+				// When a goroutine is newly created, inherit the
+				// task of the active region. For ease handling of
+				// this case, we create a fake region description with
+				// the task id.
+				if creatorG := gs[ev.G]; creatorG != nil && len(creatorG.gdesc.activeRegions) > 0 {
+					regions := creatorG.gdesc.activeRegions
+					s := regions[len(regions)-1]
+					if s.TaskID != 0 {
+						g.gdesc.activeRegions = []*UserRegionDesc{
+							{TaskID: s.TaskID, Start: ev},
+						}
+					}
+				}
+				gs[g.ID] = g
+			case from == dtrace.GoRunnable && to == dtrace.GoRunning:
+				g := gs[ev.Goroutine]
+				if g.PC == 0 && len(ev.Stack) > 0 {
+					g.PC = ev.Stack[0].PC
+					g.Name = ev.Stack[0].Func
+				}
+				g.lastStartTime = ev.ts()
+				if g.StartTime == 0 {
+					g.StartTime = ev.ts()
+				}
+				if g.blockSchedTime != 0 {
+					g.SchedWaitTime += ev.ts() - g.blockSchedTime
+					g.blockSchedTime = 0
+				}
+			case to == dtrace.GoNotExist, ev.Reason == "forever":
+				g := gs[ev.Goroutine]
+				g.finalize(ev.ts(), gcStartTime, ev)
+			case from == dtrace.GoRunning && to == dtrace.GoWaiting:
+				g := gs[ev.Goroutine]
+				g.ExecTime += ev.ts() - g.lastStartTime
+				g.lastStartTime = 0
+				switch ev.Reason {
+				case "chan send", "chan receive", "select", "sync", "sync.Cond":
+					g.blockSyncTime = ev.ts()
+				case "network":
+					g.blockNetTime = ev.ts()
+				case "GC mark assist wait":
+					g.blockGCTime = ev.ts()
+				}
+			case from == dtrace.GoRunning && to == dtrace.GoRunnable:
+				g := gs[ev.Goroutine]
+				g.ExecTime += ev.ts() - g.lastStartTime
+				g.lastStartTime = 0
+				g.blockSchedTime = ev.ts()
+			case from == dtrace.GoWaiting && to == dtrace.GoRunnable:
+				g := gs[ev.Goroutine]
+				if g.blockNetTime != 0 {
+					g.IOTime += ev.ts() - g.blockNetTime
+					g.blockNetTime = 0
+				}
+				if g.blockSyncTime != 0 {
+					g.BlockTime += ev.ts() - g.blockSyncTime
+					g.blockSyncTime = 0
+				}
+				g.blockSchedTime = ev.ts()
+			case from == dtrace.GoRunning && to == dtrace.GoSyscall:
+				if ev.link != nil && ev.link.To == dtrace.GoRunning {
+					break // the system call did not block
+				}
+				g := gs[ev.Goroutine]
+				g.ExecTime += ev.ts() - g.lastStartTime
+				g.lastStartTime = 0
+				g.blockSyscallTime = ev.ts()
+			case from == dtrace.GoSyscall && to == dtrace.GoRunnable:
+				g := gs[ev.Goroutine]
+				if g.blockSyscallTime != 0 {
+					g.SyscallTime += ev.ts() - g.blockSyscallTime
+					g.blockSyscallTime = 0
+				}
+				g.blockSchedTime = ev.ts()
+			}
+		case dtrace.EventRangeBegin:
+			switch ev.Name {
+			case sweepRange:
+				g := gs[ev.G]
+				if g != nil {
+					// Sweep can happen during GC on system goroutine.
+					g.blockSweepTime = ev.ts()
+				}
+			case gcRange:
+				gcStartTime = ev.ts()
+			}
+		case dtrace.EventRangeEnd:
+			switch ev.Name {
+			case sweepRange:
+				g := gs[ev.G]
+				if g != nil && g.blockSweepTime != 0 {
+					g.SweepTime += ev.ts() - g.blockSweepTime
+					g.blockSweepTime = 0
+				}
+			case gcRange:
+				for _, g := range gs {
+					if g.EndTime != 0 {
+						continue
+					}
+					if gcStartTime < g.CreationTime {
+						g.GCTime += ev.ts() - g.CreationTime
+					} else {
+						g.GCTime += ev.ts() - gcStartTime
+					}
+				}
+				gcStartTime = 0 // indicates gc is inactive.
+			}
+		case dtrace.EventRegionBegin:
+			g := gs[ev.G]
+			g.activeRegions = append(g.activeRegions, &UserRegionDesc{
+				Name:           ev.Name,
+				TaskID:         ev.Task,
+				Start:          ev,
+				GExecutionStat: g.snapshotStat(lastTs, gcStartTime),
+			})
+		case dtrace.EventRegionEnd:
+			g := gs[ev.G]
+			var sd *UserRegionDesc
+			if regionStk := g.activeRegions; len(regionStk) > 0 {
+				n := len(regionStk)
+				sd = regionStk[n-1]
+				regionStk = regionStk[:n-1] // pop
+				g.activeRegions = regionStk
+			} else {
+				sd = &UserRegionDesc{
+					Name:   ev.Name,
+					TaskID: ev.Task,
+				}
+			}
+			sd.GExecutionStat = g.snapshotStat(lastTs, gcStartTime).sub(sd.GExecutionStat)
+			sd.End = ev
+			g.Regions = append(g.Regions, sd)
+		}
+	}
+
+	for _, g := range gs {
+		g.finalize(lastTs, gcStartTime, nil)
+
+		// sort based on region start time
+		sort.Slice(g.Regions, func(i, j int) bool {
+			x := g.Regions[i].Start
+			y := g.Regions[j].Start
+			if x == nil {
+				return true
+			}
+			if y == nil {
+				return false
+			}
+			return x.Time < y.Time
+		})
+
+		g.gdesc = nil
+	}
+
+	return gs
+}
+
+// relatedGoroutines finds a set of goroutines related to goroutine goid.
+func relatedGoroutines(events []*event, goid uint64) map[uint64]bool {
+	// BFS of depth 2 over "unblock" edges
+	// (what goroutines unblock goroutine goid?).
+	gmap := make(map[uint64]bool)
+	gmap[goid] = true
+	for i := 0; i < 2; i++ {
+		gmap1 := make(map[uint64]bool)
+		for g := range gmap {
+			gmap1[g] = true
+		}
+		for _, ev := range events {
+			if isUnblock(ev) && gmap[ev.Goroutine] {
+				gmap1[ev.G] = true
+			}
+		}
+		gmap = gmap1
+	}
+	gmap[0] = true // for GC events
+	return gmap
+}
+
+// isUnblock reports whether ev is a goroutine being unblocked.
+func isUnblock(ev *event) bool {
+	return ev.Kind == dtrace.EventGoroutine && ev.From == dtrace.GoWaiting && ev.To == dtrace.GoRunnable
+}
+
 // httpGoroutines serves list of goroutine groups.
 func httpGoroutines(w http.ResponseWriter, r *http.Request) {
 	events, err := parseEvents()
@@ -102,7 +441,7 @@ func httpGoroutine(w http.ResponseWriter, r *http.Request) {
 	}
 	analyzeGoroutines(events)
 	var (
-		glist                   []*trace.GDesc
+		glist                   []*GDesc
 		name                    string
 		totalExecTime, execTime int64
 		maxTotalTime            int64
@@ -128,7 +467,7 @@ func httpGoroutine(w http.ResponseWriter, r *http.Request) {
 	}
 
 	sortby := r.FormValue("sortby")
-	_, ok := reflect.TypeOf(trace.GDesc{}).FieldByNameFunc(func(s string) bool {
+	_, ok := reflect.TypeOf(GDesc{}).FieldByNameFunc(func(s string) bool {
 		return s == sortby
 	})
 	if !ok {
@@ -147,7 +486,7 @@ func httpGoroutine(w http.ResponseWriter, r *http.Request) {
 		N               int
 		ExecTimePercent string
 		MaxTotal        int64
-		GList           []*trace.GDesc
+		GList           []*GDesc
 	}{
 		Name:            name,
 		PC:              pc,
@@ -178,7 +517,7 @@ var templGoroutine = template.Must(template.New("").Funcs(template.FuncMap{
 		}
 		return template.HTML(fmt.Sprintf("%.2f%%", float64(dividend)/float64(divisor)*100))
 	},
-	"unknownTime": func(desc *trace.GDesc) int64 {
+	"unknownTime": func(desc *GDesc) int64 {
 		sum := desc.ExecTime + desc.IOTime + desc.BlockTime + desc.SyscallTime + desc.SchedWaitTime
 		if sum < desc.TotalTime {
 			return desc.TotalTime - sum
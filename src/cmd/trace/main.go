@@ -7,10 +7,10 @@ package main
 import (
 	"bufio"
 	"cmd/internal/browser"
+	dtrace "debug/trace"
 	"flag"
 	"fmt"
 	"html/template"
-	"internal/trace"
 	"io"
 	"log"
 	"net"
@@ -34,8 +34,8 @@ Open a web browser displaying trace:
 Generate a pprof-like profile from the trace:
     go tool trace -pprof=TYPE [pkg.test] trace.out
 
-[pkg.test] argument is required for traces produced by Go 1.6 and below.
-Go 1.7 does not require the binary argument.
+The [pkg.test] argument is accepted for compatibility and ignored.
+Traces produced by Go 1.6 and below, which needed it, are not supported.
 
 Supported profile types are:
     - net: network blocking profile
@@ -59,9 +59,7 @@ var (
 	pprofFlag = flag.String("pprof", "", "print a pprof-like profile instead")
 	debugFlag = flag.Bool("d", false, "print debug information such as parsed events list")
 
-	// The binary file name, left here for serveSVGProfile.
-	programBinary string
-	traceFile     string
+	traceFile string
 )
 
 func main() {
@@ -71,13 +69,12 @@ func main() {
 	}
 	flag.Parse()
 
-	// Go 1.7 traces embed symbol info and does not require the binary.
-	// But we optionally accept binary as first arg for Go 1.5 traces.
+	// Go 1.7 traces embed symbol info and do not require the binary.
+	// We still accept the binary as first arg, as older versions did.
 	switch flag.NArg() {
 	case 1:
 		traceFile = flag.Arg(0)
 	case 2:
-		programBinary = flag.Arg(0)
 		traceFile = flag.Arg(1)
 	default:
 		flag.Usage()
@@ -110,20 +107,22 @@ func main() {
 	}
 
 	log.Print("Parsing trace...")
-	res, err := parseTrace()
+	events, err := parseEvents()
 	if err != nil {
 		dief("%v\n", err)
 	}
 
 	if *debugFlag {
-		trace.Print(res.Events)
+		for _, ev := range events {
+			fmt.Println(ev)
+		}
 		os.Exit(0)
 	}
 	reportMemoryUsage("after parsing trace")
 	debug.FreeOSMemory()
 
 	log.Print("Splitting trace...")
-	ranges = splitTrace(res)
+	ranges = splitTrace(events)
 	reportMemoryUsage("after spliting trace")
 	debug.FreeOSMemory()
 
@@ -139,23 +138,53 @@ func main() {
 
 var ranges []Range
 
-var loader struct {
-	once sync.Once
-	res  trace.ParseResult
-	err  error
+// An event is an event of the trace, as read by package debug/trace.
+//
+// Events that begin an interval are linked to the event that ends it:
+// ranges, tasks and regions to their end, and goroutine state transitions
+// to the next transition of the same goroutine. The exception is a
+// goroutine starting to run, which is linked to the transition that
+// stops it running; system calls that return without blocking do not.
+type event struct {
+	dtrace.Event
+	link *event
 }
 
-// parseEvents is a compatibility wrapper that returns only
-// the Events part of trace.ParseResult returned by parseTrace.
-func parseEvents() ([]*trace.Event, error) {
-	res, err := parseTrace()
-	if err != nil {
-		return nil, err
-	}
-	return res.Events, err
+// ts returns the time of the event in nanoseconds.
+func (ev *event) ts() int64 {
+	return int64(ev.Time)
 }
 
-func parseTrace() (trace.ParseResult, error) {
+// Pseudo-Ps of the events that do not happen on a P.
+const (
+	fakeP    = 1000000 + iota
+	timerP   // goroutines unblocked by timers
+	netpollP // goroutines unblocked by the network poller
+	syscallP // goroutines returning from system calls
+	gcP      // GC state
+)
+
+// Names of the runtime ranges and metrics reported by debug/trace.
+const (
+	gcRange     = "GC concurrent mark phase"
+	stwRange    = "stop-the-world" // followed by the reason in parentheses
+	assistRange = "GC mark assist"
+	sweepRange  = "GC incremental sweep"
+
+	heapAllocMetric  = "/memory/classes/heap/objects:bytes"
+	heapGoalMetric   = "/gc/heap/goal:bytes"
+	gomaxprocsMetric = "/sched/gomaxprocs:threads"
+)
+
+var loader struct {
+	once   sync.Once
+	events []*event
+	err    error
+}
+
+// parseEvents reads the trace file the first time it is called,
+// and returns its events.
+func parseEvents() ([]*event, error) {
 	loader.once.Do(func() {
 		tracef, err := os.Open(traceFile)
 		if err != nil {
@@ -164,15 +193,129 @@ func parseTrace() (trace.ParseResult, error) {
 		}
 		defer tracef.Close()
 
-		// Parse and symbolize.
-		res, err := trace.Parse(bufio.NewReader(tracef), programBinary)
+		events, err := readEvents(bufio.NewReader(tracef))
 		if err != nil {
 			loader.err = fmt.Errorf("failed to parse trace: %v", err)
 			return
 		}
-		loader.res = res
+		loader.events = events
 	})
-	return loader.res, loader.err
+	return loader.events, loader.err
+}
+
+// readEvents reads the events of the trace in r and links them.
+func readEvents(r io.Reader) ([]*event, error) {
+	tr, err := dtrace.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var evs []event
+	for {
+		ev, err := tr.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		evs = append(evs, event{Event: ev})
+	}
+	events := make([]*event, len(evs))
+	for i := range evs {
+		events[i] = &evs[i]
+	}
+	linkEvents(events)
+	return events, nil
+}
+
+// linkEvents links the events that begin intervals to the events that
+// end them, and moves the events that do not happen on a P to the
+// pseudo-P that shows them.
+func linkEvents(events []*event) {
+	type rangeKey struct {
+		name string
+		g    uint64 // for the ranges of a single goroutine
+	}
+	last := make(map[uint64]*event)      // last transition of each goroutine
+	starts := make(map[uint64]*event)    // last start of each goroutine
+	regions := make(map[uint64][]*event) // active regions of each goroutine
+	ranges := make(map[rangeKey]*event)
+	tasks := make(map[uint64]*event)
+
+	for _, ev := range events {
+		switch ev.Kind {
+		case dtrace.EventGoroutine:
+			g := ev.Goroutine
+			prev := last[g]
+			if ev.P < 0 {
+				switch {
+				case ev.From == dtrace.GoSyscall:
+					ev.P = syscallP
+				case prev != nil && prev.Reason == "network":
+					ev.P = netpollP
+				default:
+					ev.P = timerP
+				}
+			}
+			if prev != nil && prev != starts[g] {
+				prev.link = ev
+			}
+			last[g] = ev
+
+			switch {
+			case ev.From == dtrace.GoRunnable && ev.To == dtrace.GoRunning:
+				starts[g] = ev
+			case ev.From == dtrace.GoSyscall && ev.To == dtrace.GoRunning:
+				// The system call returned without blocking,
+				// so the goroutine is still running.
+				if start := starts[g]; start != nil {
+					start.link = nil
+				}
+			case ev.From == dtrace.GoRunning:
+				if start := starts[g]; start != nil {
+					start.link = ev
+				}
+				if ev.To == dtrace.GoNotExist {
+					for _, r := range regions[g] {
+						r.link = ev
+					}
+					delete(regions, g)
+				}
+			}
+
+		case dtrace.EventRangeBegin, dtrace.EventRangeEnd:
+			k := rangeKey{name: ev.Name}
+			if ev.Name == assistRange || ev.Name == sweepRange {
+				k.g = ev.G
+			}
+			if ev.Kind == dtrace.EventRangeBegin {
+				ranges[k] = ev
+			} else if begin := ranges[k]; begin != nil {
+				begin.link = ev
+				delete(ranges, k)
+			}
+
+		case dtrace.EventTaskBegin:
+			tasks[ev.Task] = ev
+		case dtrace.EventTaskEnd:
+			if begin := tasks[ev.Task]; begin != nil {
+				begin.link = ev
+				delete(tasks, ev.Task)
+			}
+
+		case dtrace.EventRegionBegin:
+			regions[ev.G] = append(regions[ev.G], ev)
+		case dtrace.EventRegionEnd:
+			active := regions[ev.G]
+			if n := len(active); n > 0 && active[n-1].Task == ev.Task && active[n-1].Name == ev.Name {
+				active[n-1].link = ev
+				regions[ev.G] = active[:n-1]
+			}
+		}
+		if ev.P < 0 {
+			ev.P = gcP
+		}
+	}
 }
 
 // httpMain serves the starting page.
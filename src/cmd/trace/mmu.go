@@ -26,6 +26,7 @@
 package main
 
 import (
+	dtrace "debug/trace"
 	"encoding/json"
 	"fmt"
 	"internal/trace"
@@ -87,13 +88,186 @@ func getMMUCurve(r *http.Request) ([][]trace.MutatorUtil, *trace.MMUCurve, error
 		if err != nil {
 			c.err = err
 		} else {
-			c.util = trace.MutatorUtilization(events, flags)
+			c.util = mutatorUtilization(events, flags)
 			c.mmuCurve = trace.NewMMUCurve(c.util)
 		}
 	})
 	return c.util, c.mmuCurve, c.err
 }
 
+// mutatorUtilization returns a set of mutator utilization functions
+// for the given trace. Each function will always end with 0
+// utilization. The bounds of each function are implicit in the first
+// and last event; outside of these bounds each function is undefined.
+//
+// If the UtilPerProc flag is not given, this always returns a single
+// utilization function. Otherwise, it returns one function per P.
+func mutatorUtilization(events []*event, flags trace.UtilFlags) [][]trace.MutatorUtil {
+	if len(events) == 0 {
+		return nil
+	}
+
+	type perP struct {
+		// gc > 0 indicates that GC is active on this P.
+		gc int
+		// series the logical series number for this P. This
+		// is necessary because Ps may be removed and then
+		// re-added, and then the new P needs a new series.
+		series int
+	}
+	ps := []perP{}
+	stw := 0
+
+	out := [][]trace.MutatorUtil{}
+	assists := map[uint64]bool{}
+	block := map[uint64]*event{}
+	bgMark := map[uint64]bool{}
+
+	for _, ev := range events {
+		switch {
+		case ev.Kind == dtrace.EventMetric && ev.Name == gomaxprocsMetric:
+			gomaxprocs := int(ev.Value)
+			if len(ps) > gomaxprocs {
+				if flags&trace.UtilPerProc != 0 {
+					// End each P's series.
+					for _, p := range ps[gomaxprocs:] {
+						out[p.series] = addUtil(out[p.series], trace.MutatorUtil{Time: ev.ts(), Util: 0})
+					}
+				}
+				ps = ps[:gomaxprocs]
+			}
+			for len(ps) < gomaxprocs {
+				// Start new P's series.
+				series := 0
+				if flags&trace.UtilPerProc != 0 || len(out) == 0 {
+					series = len(out)
+					out = append(out, []trace.MutatorUtil{{Time: ev.ts(), Util: 1}})
+				}
+				ps = append(ps, perP{series: series})
+			}
+		case ev.Kind == dtrace.EventRangeBegin || ev.Kind == dtrace.EventRangeEnd:
+			delta := 1
+			if ev.Kind == dtrace.EventRangeEnd {
+				delta = -1
+			}
+			switch {
+			case strings.HasPrefix(ev.Name, stwRange):
+				if flags&trace.UtilSTW != 0 {
+					stw += delta
+				}
+			case ev.Name == assistRange:
+				if flags&trace.UtilAssist != 0 {
+					ps[ev.P].gc += delta
+					if delta > 0 {
+						assists[ev.G] = true
+					} else {
+						delete(assists, ev.G)
+					}
+				}
+			case ev.Name == sweepRange:
+				if flags&trace.UtilSweep != 0 {
+					ps[ev.P].gc += delta
+				}
+			}
+		case ev.Kind == dtrace.EventGoroutine && ev.From == dtrace.GoRunnable && ev.To == dtrace.GoRunning:
+			g := ev.Goroutine
+			if label := ev.Reason; flags&trace.UtilBackground != 0 && strings.HasPrefix(label, "GC ") && label != "GC (idle)" {
+				// Background mark worker.
+				//
+				// If we're in per-proc mode, we don't
+				// count dedicated workers because
+				// they kick all of the goroutines off
+				// that P, so don't directly
+				// contribute to goroutine latency.
+				if !(flags&trace.UtilPerProc != 0 && label == "GC (dedicated)") {
+					bgMark[g] = true
+					ps[ev.P].gc++
+				}
+			}
+			if assists[g] {
+				// Unblocked during assist.
+				ps[ev.P].gc++
+			}
+			block[g] = ev.link
+		default:
+			if ev.Kind != dtrace.EventGoroutine || ev != block[ev.Goroutine] {
+				continue
+			}
+			g := ev.Goroutine
+
+			if assists[g] {
+				// Blocked during assist.
+				ps[ev.P].gc--
+			}
+			if bgMark[g] {
+				// Background mark worker done.
+				ps[ev.P].gc--
+				delete(bgMark, g)
+			}
+			delete(block, g)
+		}
+
+		if flags&trace.UtilPerProc == 0 {
+			// Compute the current average utilization.
+			if len(ps) == 0 {
+				continue
+			}
+			gcPs := 0
+			if stw > 0 {
+				gcPs = len(ps)
+			} else {
+				for i := range ps {
+					if ps[i].gc > 0 {
+						gcPs++
+					}
+				}
+			}
+			mu := trace.MutatorUtil{Time: ev.ts(), Util: 1 - float64(gcPs)/float64(len(ps))}
+
+			// Record the utilization change. (Since
+			// len(ps) == len(out), we know len(out) > 0.)
+			out[0] = addUtil(out[0], mu)
+		} else {
+			// Check for per-P utilization changes.
+			for i := range ps {
+				p := &ps[i]
+				util := 1.0
+				if stw > 0 || p.gc > 0 {
+					util = 0.0
+				}
+				out[p.series] = addUtil(out[p.series], trace.MutatorUtil{Time: ev.ts(), Util: util})
+			}
+		}
+	}
+
+	// Add final 0 utilization event to any remaining series. This
+	// is important to mark the end of the trace. The exact value
+	// shouldn't matter since no window should extend beyond this,
+	// but using 0 is symmetric with the start of the trace.
+	mu := trace.MutatorUtil{Time: events[len(events)-1].ts(), Util: 0}
+	for i := range ps {
+		out[ps[i].series] = addUtil(out[ps[i].series], mu)
+	}
+	return out
+}
+
+func addUtil(util []trace.MutatorUtil, mu trace.MutatorUtil) []trace.MutatorUtil {
+	if len(util) > 0 {
+		if mu.Util == util[len(util)-1].Util {
+			// No change.
+			return util
+		}
+		if mu.Time == util[len(util)-1].Time {
+			// Take the lowest utilization at a time stamp.
+			if mu.Util < util[len(util)-1].Util {
+				util[len(util)-1] = mu
+			}
+			return util
+		}
+	}
+	return append(util, mu)
+}
+
 // httpMMU serves the MMU plot page.
 func httpMMU(w http.ResponseWriter, r *http.Request) {
 	http.ServeContent(w, r, "", time.Time{}, strings.NewReader(templMMU))
@@ -8,9 +8,9 @@ package main
 
 import (
 	"bufio"
+	dtrace "debug/trace"
 	"fmt"
 	exec "internal/execabs"
-	"internal/trace"
 	"io"
 	"net/http"
 	"os"
@@ -49,7 +49,7 @@ func init() {
 
 // Record represents one entry in pprof-like profiles.
 type Record struct {
-	stk  []*trace.Frame
+	stk  []dtrace.Frame
 	n    uint64
 	time int64
 }
@@ -59,7 +59,7 @@ type interval struct {
 	begin, end int64 // nanoseconds.
 }
 
-func pprofByGoroutine(compute func(io.Writer, map[uint64][]interval, []*trace.Event) error) func(w io.Writer, r *http.Request) error {
+func pprofByGoroutine(compute func(io.Writer, map[uint64][]interval, []*event) error) func(w io.Writer, r *http.Request) error {
 	return func(w io.Writer, r *http.Request) error {
 		id := r.FormValue("id")
 		events, err := parseEvents()
@@ -74,7 +74,7 @@ func pprofByGoroutine(compute func(io.Writer, map[uint64][]interval, []*trace.Ev
 	}
 }
 
-func pprofByRegion(compute func(io.Writer, map[uint64][]interval, []*trace.Event) error) func(w io.Writer, r *http.Request) error {
+func pprofByRegion(compute func(io.Writer, map[uint64][]interval, []*event) error) func(w io.Writer, r *http.Request) error {
 	return func(w io.Writer, r *http.Request) error {
 		filter, err := newRegionFilter(r)
 		if err != nil {
@@ -93,7 +93,7 @@ func pprofByRegion(compute func(io.Writer, map[uint64][]interval, []*trace.Event
 // pprofMatchingGoroutines parses the goroutine type id string (i.e. pc)
 // and returns the ids of goroutines of the matching type and its interval.
 // If the id string is empty, returns nil without an error.
-func pprofMatchingGoroutines(id string, events []*trace.Event) (map[uint64][]interval, error) {
+func pprofMatchingGoroutines(id string, events []*event) (map[uint64][]interval, error) {
 	if id == "" {
 		return nil, nil
 	}
@@ -171,87 +171,66 @@ func pprofMatchingRegions(filter *regionFilter) (map[uint64][]interval, error) {
 }
 
 // computePprofIO generates IO pprof-like profile (time spent in IO wait, currently only network blocking event).
-func computePprofIO(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
-	prof := make(map[uint64]Record)
-	for _, ev := range events {
-		if ev.Type != trace.EvGoBlockNet || ev.Link == nil || ev.StkID == 0 || len(ev.Stk) == 0 {
-			continue
-		}
-		overlapping := pprofOverlappingDuration(gToIntervals, ev)
-		if overlapping > 0 {
-			rec := prof[ev.StkID]
-			rec.stk = ev.Stk
-			rec.n++
-			rec.time += overlapping.Nanoseconds()
-			prof[ev.StkID] = rec
-		}
-	}
-	return buildProfile(prof).Write(w)
+func computePprofIO(w io.Writer, gToIntervals map[uint64][]interval, events []*event) error {
+	return computePprof(w, gToIntervals, events, func(ev *event) bool {
+		return isBlock(ev) && ev.Reason == "network"
+	})
 }
 
 // computePprofBlock generates blocking pprof-like profile (time spent blocked on synchronization primitives).
-func computePprofBlock(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
-	prof := make(map[uint64]Record)
-	for _, ev := range events {
-		switch ev.Type {
-		case trace.EvGoBlockSend, trace.EvGoBlockRecv, trace.EvGoBlockSelect,
-			trace.EvGoBlockSync, trace.EvGoBlockCond, trace.EvGoBlockGC:
-			// TODO(hyangah): figure out why EvGoBlockGC should be here.
-			// EvGoBlockGC indicates the goroutine blocks on GC assist, not
-			// on synchronization primitives.
-		default:
-			continue
+func computePprofBlock(w io.Writer, gToIntervals map[uint64][]interval, events []*event) error {
+	return computePprof(w, gToIntervals, events, func(ev *event) bool {
+		if !isBlock(ev) {
+			return false
 		}
-		if ev.Link == nil || ev.StkID == 0 || len(ev.Stk) == 0 {
-			continue
-		}
-		overlapping := pprofOverlappingDuration(gToIntervals, ev)
-		if overlapping > 0 {
-			rec := prof[ev.StkID]
-			rec.stk = ev.Stk
-			rec.n++
-			rec.time += overlapping.Nanoseconds()
-			prof[ev.StkID] = rec
+		switch ev.Reason {
+		case "chan send", "chan receive", "select", "sync", "sync.Cond", "GC mark assist wait":
+			// TODO(hyangah): figure out why GC mark assist waits should be here.
+			// They indicate the goroutine blocks on GC assist, not
+			// on synchronization primitives.
+			return true
 		}
-	}
-	return buildProfile(prof).Write(w)
+		return false
+	})
 }
 
 // computePprofSyscall generates syscall pprof-like profile (time spent blocked in syscalls).
-func computePprofSyscall(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
-	prof := make(map[uint64]Record)
-	for _, ev := range events {
-		if ev.Type != trace.EvGoSysCall || ev.Link == nil || ev.StkID == 0 || len(ev.Stk) == 0 {
-			continue
-		}
-		overlapping := pprofOverlappingDuration(gToIntervals, ev)
-		if overlapping > 0 {
-			rec := prof[ev.StkID]
-			rec.stk = ev.Stk
-			rec.n++
-			rec.time += overlapping.Nanoseconds()
-			prof[ev.StkID] = rec
-		}
-	}
-	return buildProfile(prof).Write(w)
+func computePprofSyscall(w io.Writer, gToIntervals map[uint64][]interval, events []*event) error {
+	return computePprof(w, gToIntervals, events, func(ev *event) bool {
+		return ev.Kind == dtrace.EventGoroutine && ev.From == dtrace.GoRunning && ev.To == dtrace.GoSyscall
+	})
 }
 
 // computePprofSched generates scheduler latency pprof-like profile
 // (time between a goroutine become runnable and actually scheduled for execution).
-func computePprofSched(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
-	prof := make(map[uint64]Record)
+func computePprofSched(w io.Writer, gToIntervals map[uint64][]interval, events []*event) error {
+	return computePprof(w, gToIntervals, events, func(ev *event) bool {
+		return ev.Kind == dtrace.EventGoroutine && ev.To == dtrace.GoRunnable &&
+			(ev.From == dtrace.GoNotExist || ev.From == dtrace.GoWaiting)
+	})
+}
+
+// isBlock reports whether ev is a goroutine blocking.
+func isBlock(ev *event) bool {
+	return ev.Kind == dtrace.EventGoroutine && ev.From == dtrace.GoRunning && ev.To == dtrace.GoWaiting
+}
+
+// computePprof generates a pprof-like profile of the time from the events
+// selected by wait to the events linked to them, by stack of the selected events.
+func computePprof(w io.Writer, gToIntervals map[uint64][]interval, events []*event, wait func(*event) bool) error {
+	// The stacks of the events with the same stack are the same slice.
+	prof := make(map[*dtrace.Frame]Record)
 	for _, ev := range events {
-		if (ev.Type != trace.EvGoUnblock && ev.Type != trace.EvGoCreate) ||
-			ev.Link == nil || ev.StkID == 0 || len(ev.Stk) == 0 {
+		if !wait(ev) || ev.link == nil || len(ev.Stack) == 0 {
 			continue
 		}
 		overlapping := pprofOverlappingDuration(gToIntervals, ev)
 		if overlapping > 0 {
-			rec := prof[ev.StkID]
-			rec.stk = ev.Stk
+			rec := prof[&ev.Stack[0]]
+			rec.stk = ev.Stack
 			rec.n++
 			rec.time += overlapping.Nanoseconds()
-			prof[ev.StkID] = rec
+			prof[&ev.Stack[0]] = rec
 		}
 	}
 	return buildProfile(prof).Write(w)
@@ -260,9 +239,9 @@ func computePprofSched(w io.Writer, gToIntervals map[uint64][]interval, events [
 // pprofOverlappingDuration returns the overlapping duration between
 // the time intervals in gToIntervals and the specified event.
 // If gToIntervals is nil, this simply returns the event's duration.
-func pprofOverlappingDuration(gToIntervals map[uint64][]interval, ev *trace.Event) time.Duration {
+func pprofOverlappingDuration(gToIntervals map[uint64][]interval, ev *event) time.Duration {
 	if gToIntervals == nil { // No filtering.
-		return time.Duration(ev.Link.Ts-ev.Ts) * time.Nanosecond
+		return ev.link.Time - ev.Time
 	}
 	intervals := gToIntervals[ev.G]
 	if len(intervals) == 0 {
@@ -271,7 +250,7 @@ func pprofOverlappingDuration(gToIntervals map[uint64][]interval, ev *trace.Even
 
 	var overlapping time.Duration
 	for _, i := range intervals {
-		if o := overlappingDuration(i.begin, i.end, ev.Ts, ev.Link.Ts); o > 0 {
+		if o := overlappingDuration(i.begin, i.end, ev.ts(), ev.link.ts()); o > 0 {
 			overlapping += o
 		}
 	}
@@ -326,7 +305,7 @@ func serveSVGProfile(prof func(w io.Writer, r *http.Request) error) http.Handler
 	}
 }
 
-func buildProfile(prof map[uint64]Record) *profile.Profile {
+func buildProfile(prof map[*dtrace.Frame]Record) *profile.Profile {
 	p := &profile.Profile{
 		PeriodType: &profile.ValueType{Type: "trace", Unit: "count"},
 		Period:     1,
@@ -342,16 +321,16 @@ func buildProfile(prof map[uint64]Record) *profile.Profile {
 		for _, frame := range rec.stk {
 			loc := locs[frame.PC]
 			if loc == nil {
-				fn := funcs[frame.File+frame.Fn]
+				fn := funcs[frame.File+frame.Func]
 				if fn == nil {
 					fn = &profile.Function{
 						ID:         uint64(len(p.Function) + 1),
-						Name:       frame.Fn,
-						SystemName: frame.Fn,
+						Name:       frame.Func,
+						SystemName: frame.Func,
 						Filename:   frame.File,
 					}
 					p.Function = append(p.Function, fn)
-					funcs[frame.File+frame.Fn] = fn
+					funcs[frame.File+frame.Func] = fn
 				}
 				loc = &profile.Location{
 					ID:      uint64(len(p.Location) + 1),
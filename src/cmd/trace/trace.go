@@ -6,9 +6,9 @@ package main
 
 import (
 	"cmd/internal/traceviewer"
+	dtrace "debug/trace"
 	"encoding/json"
 	"fmt"
-	"internal/trace"
 	"io"
 	"log"
 	"math"
@@ -31,7 +31,7 @@ func init() {
 
 // httpTrace serves either whole trace (goid==0) or trace for goid goroutine.
 func httpTrace(w http.ResponseWriter, r *http.Request) {
-	_, err := parseTrace()
+	_, err := parseEvents()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -188,14 +188,14 @@ func httpJsonTrace(w http.ResponseWriter, r *http.Request) {
 	defer debug.FreeOSMemory()
 	defer reportMemoryUsage("after httpJsonTrace")
 	// This is an AJAX handler, so instead of http.Error we use log.Printf to log errors.
-	res, err := parseTrace()
+	events, err := parseEvents()
 	if err != nil {
 		log.Printf("failed to parse trace: %v", err)
 		return
 	}
 
 	params := &traceParams{
-		parsed:  res,
+		events:  events,
 		endTime: math.MaxInt64,
 	}
 
@@ -206,7 +206,7 @@ func httpJsonTrace(w http.ResponseWriter, r *http.Request) {
 			log.Printf("failed to parse goid parameter %q: %v", goids, err)
 			return
 		}
-		analyzeGoroutines(res.Events)
+		analyzeGoroutines(events)
 		g, ok := gs[goid]
 		if !ok {
 			log.Printf("failed to find goroutine %d", goid)
@@ -220,7 +220,7 @@ func httpJsonTrace(w http.ResponseWriter, r *http.Request) {
 			params.endTime = lastTimestamp()
 		}
 		params.maing = goid
-		params.gs = trace.RelatedGoroutines(res.Events, goid)
+		params.gs = relatedGoroutines(events, goid)
 	} else if taskids := r.FormValue("taskid"); taskids != "" {
 		taskid, err := strconv.ParseUint(taskids, 10, 64)
 		if err != nil {
@@ -242,7 +242,7 @@ func httpJsonTrace(w http.ResponseWriter, r *http.Request) {
 		gs := map[uint64]bool{}
 		for _, t := range params.tasks {
 			// find only directly involved goroutines
-			for k, v := range t.RelatedGoroutines(res.Events, 0) {
+			for k, v := range t.RelatedGoroutines(events, 0) {
 				gs[k] = v
 			}
 		}
@@ -303,9 +303,9 @@ func (r Range) URL() string {
 // splitTrace splits the trace into a number of ranges,
 // each resulting in approx 100MB of json output
 // (trace viewer can hardly handle more).
-func splitTrace(res trace.ParseResult) []Range {
+func splitTrace(events []*event) []Range {
 	params := &traceParams{
-		parsed:  res,
+		events:  events,
 		endTime: math.MaxInt64,
 	}
 	s, c := splittingTraceConsumer(100 << 20) // 100M
@@ -412,7 +412,7 @@ func (cw *countingWriter) Write(data []byte) (int, error) {
 }
 
 type traceParams struct {
-	parsed    trace.ParseResult
+	events    []*event
 	mode      traceviewMode
 	startTime int64
 	endTime   int64
@@ -473,10 +473,10 @@ const (
 
 type gInfo struct {
 	state      gState // current state
-	name       string // name chosen for this goroutine at first EvGoStart
+	name       string // name chosen for this goroutine at its creation
 	isSystemG  bool
-	start      *trace.Event // most recent EvGoStart
-	markAssist *trace.Event // if non-nil, the mark assist currently running.
+	start      *event // most recent start
+	markAssist *event // if non-nil, the mark assist currently running.
 }
 
 type NameArg struct {
@@ -527,7 +527,6 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 	ctx.consumer.consumeTimeUnit("ns")
 	maxProc := 0
 	ginfos := make(map[uint64]*gInfo)
-	stacks := params.parsed.Stacks
 
 	getGInfo := func(g uint64) *gInfo {
 		info, ok := ginfos[g]
@@ -541,7 +540,7 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 	// Since we make many calls to setGState, we record a sticky
 	// error in setGStateErr and check it after every event.
 	var setGStateErr error
-	setGState := func(ev *trace.Event, g uint64, oldState, newState gState) {
+	setGState := func(ev *event, g uint64, oldState, newState gState) {
 		info := getGInfo(g)
 		if oldState == gWaiting && info.state == gWaitingGC {
 			// For checking, gWaiting counts as any gWaiting*.
@@ -554,81 +553,84 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 		ctx.gstates[newState]++
 		info.state = newState
 	}
+	inSyscall := func(g uint64, n int64) {
+		if getGInfo(g).isSystemG {
+			ctx.threadStats.insyscallRuntime += n
+		} else {
+			ctx.threadStats.insyscall += n
+		}
+	}
 
-	for _, ev := range ctx.parsed.Events {
+	for _, ev := range ctx.events {
 		// Handle state transitions before we filter out events.
-		switch ev.Type {
-		case trace.EvGoStart, trace.EvGoStartLabel:
-			setGState(ev, ev.G, gRunnable, gRunning)
-			info := getGInfo(ev.G)
-			info.start = ev
-		case trace.EvProcStart:
-			ctx.threadStats.prunning++
-		case trace.EvProcStop:
-			ctx.threadStats.prunning--
-		case trace.EvGoCreate:
-			newG := ev.Args[0]
-			info := getGInfo(newG)
-			if info.name != "" {
-				return fmt.Errorf("duplicate go create event for go id=%d detected at offset %d", newG, ev.Off)
-			}
+		switch ev.Kind {
+		case dtrace.EventGoroutine:
+			g := ev.Goroutine
+			switch from, to := ev.From, ev.To; {
+			case from == dtrace.GoNotExist:
+				info := getGInfo(g)
+				if info.name != "" {
+					return fmt.Errorf("duplicate go create event for go id=%d detected at %v", g, ev.Time)
+				}
 
-			stk, ok := stacks[ev.Args[1]]
-			if !ok || len(stk) == 0 {
-				return fmt.Errorf("invalid go create event: missing stack information for go id=%d at offset %d", newG, ev.Off)
+				fname := entryFunc(ev)
+				info.name = fmt.Sprintf("G%v %s", g, fname)
+				info.isSystemG = isSystemGoroutine(fname)
+
+				ctx.gcount++
+				setGState(ev, g, gDead, gRunnable)
+			case from == dtrace.GoRunnable && to == dtrace.GoRunning:
+				setGState(ev, g, gRunnable, gRunning)
+				info := getGInfo(g)
+				info.start = ev
+			case to == dtrace.GoNotExist:
+				ctx.gcount--
+				setGState(ev, g, gRunning, gDead)
+			case from == dtrace.GoWaiting && to == dtrace.GoRunnable:
+				setGState(ev, g, gWaiting, gRunnable)
+			case from == dtrace.GoSyscall && to == dtrace.GoRunnable:
+				setGState(ev, g, gWaiting, gRunnable)
+				inSyscall(g, -1)
+			case from == dtrace.GoRunning && to == dtrace.GoSyscall:
+				if ev.link != nil && ev.link.To == dtrace.GoRunning {
+					break // the system call did not block
+				}
+				setGState(ev, g, gRunning, gWaiting)
+				inSyscall(g, +1)
+			case from == dtrace.GoRunning && to == dtrace.GoRunnable:
+				setGState(ev, g, gRunning, gRunnable)
+			case from == dtrace.GoRunning && to == dtrace.GoWaiting:
+				if ev.Reason == "GC mark assist wait" {
+					setGState(ev, g, gRunning, gWaitingGC)
+				} else {
+					setGState(ev, g, gRunning, gWaiting)
+				}
+			case from == dtrace.GoRunnable && to == dtrace.GoWaiting:
+				setGState(ev, g, gRunnable, gWaiting)
+			case from == dtrace.GoRunnable && to == dtrace.GoSyscall:
+				// Cancel out the effect of the creation at the beginning.
+				setGState(ev, g, gRunnable, gWaiting)
+				inSyscall(g, +1)
 			}
-
-			fname := stk[0].Fn
-			info.name = fmt.Sprintf("G%v %s", newG, fname)
-			info.isSystemG = isSystemGoroutine(fname)
-
-			ctx.gcount++
-			setGState(ev, newG, gDead, gRunnable)
-		case trace.EvGoEnd:
-			ctx.gcount--
-			setGState(ev, ev.G, gRunning, gDead)
-		case trace.EvGoUnblock:
-			setGState(ev, ev.Args[0], gWaiting, gRunnable)
-		case trace.EvGoSysExit:
-			setGState(ev, ev.G, gWaiting, gRunnable)
-			if getGInfo(ev.G).isSystemG {
-				ctx.threadStats.insyscallRuntime--
-			} else {
-				ctx.threadStats.insyscall--
+		case dtrace.EventProcStart:
+			ctx.threadStats.prunning++
+		case dtrace.EventProcStop:
+			ctx.threadStats.prunning--
+		case dtrace.EventRangeBegin:
+			if ev.Name == assistRange {
+				getGInfo(ev.G).markAssist = ev
 			}
-		case trace.EvGoSysBlock:
-			setGState(ev, ev.G, gRunning, gWaiting)
-			if getGInfo(ev.G).isSystemG {
-				ctx.threadStats.insyscallRuntime++
-			} else {
-				ctx.threadStats.insyscall++
+		case dtrace.EventRangeEnd:
+			if ev.Name == assistRange {
+				getGInfo(ev.G).markAssist = nil
 			}
-		case trace.EvGoSched, trace.EvGoPreempt:
-			setGState(ev, ev.G, gRunning, gRunnable)
-		case trace.EvGoStop,
-			trace.EvGoSleep, trace.EvGoBlock, trace.EvGoBlockSend, trace.EvGoBlockRecv,
-			trace.EvGoBlockSelect, trace.EvGoBlockSync, trace.EvGoBlockCond, trace.EvGoBlockNet:
-			setGState(ev, ev.G, gRunning, gWaiting)
-		case trace.EvGoBlockGC:
-			setGState(ev, ev.G, gRunning, gWaitingGC)
-		case trace.EvGCMarkAssistStart:
-			getGInfo(ev.G).markAssist = ev
-		case trace.EvGCMarkAssistDone:
-			getGInfo(ev.G).markAssist = nil
-		case trace.EvGoWaiting:
-			setGState(ev, ev.G, gRunnable, gWaiting)
-		case trace.EvGoInSyscall:
-			// Cancel out the effect of EvGoCreate at the beginning.
-			setGState(ev, ev.G, gRunnable, gWaiting)
-			if getGInfo(ev.G).isSystemG {
-				ctx.threadStats.insyscallRuntime++
-			} else {
-				ctx.threadStats.insyscall++
+		case dtrace.EventMetric:
+			switch ev.Name {
+			case heapAllocMetric:
+				ctx.heapStats.heapAlloc = ev.Value
+			case heapGoalMetric:
+				ctx.heapStats.nextGC = ev.Value
 			}
-		case trace.EvHeapAlloc:
-			ctx.heapStats.heapAlloc = ev.Args[0]
-		case trace.EvNextGC:
-			ctx.heapStats.nextGC = ev.Args[0]
 		}
 		if setGStateErr != nil {
 			return setGStateErr
@@ -639,93 +641,91 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 
 		// Ignore events that are from uninteresting goroutines
 		// or outside of the interesting timeframe.
-		if ctx.gs != nil && ev.P < trace.FakeP && !ctx.gs[ev.G] {
+		if ctx.gs != nil && ev.P < fakeP && !ctx.gs[ev.G] {
 			continue
 		}
 		if !withinTimeRange(ev, ctx.startTime, ctx.endTime) {
 			continue
 		}
 
-		if ev.P < trace.FakeP && ev.P > maxProc {
+		if ev.P < fakeP && ev.P > maxProc {
 			maxProc = ev.P
 		}
 
 		// Emit trace objects.
-		switch ev.Type {
-		case trace.EvProcStart:
+		switch ev.Kind {
+		case dtrace.EventProcStart:
 			if ctx.mode&modeGoroutineOriented != 0 {
 				continue
 			}
 			ctx.emitInstant(ev, "proc start", "")
-		case trace.EvProcStop:
+		case dtrace.EventProcStop:
 			if ctx.mode&modeGoroutineOriented != 0 {
 				continue
 			}
 			ctx.emitInstant(ev, "proc stop", "")
-		case trace.EvGCStart:
-			ctx.emitSlice(ev, "GC")
-		case trace.EvGCDone:
-		case trace.EvGCSTWStart:
-			if ctx.mode&modeGoroutineOriented != 0 {
-				continue
-			}
-			ctx.emitSlice(ev, fmt.Sprintf("STW (%s)", ev.SArgs[0]))
-		case trace.EvGCSTWDone:
-		case trace.EvGCMarkAssistStart:
-			// Mark assists can continue past preemptions, so truncate to the
-			// whichever comes first. We'll synthesize another slice if
-			// necessary in EvGoStart.
-			markFinish := ev.Link
-			goFinish := getGInfo(ev.G).start.Link
-			fakeMarkStart := *ev
-			text := "MARK ASSIST"
-			if markFinish == nil || markFinish.Ts > goFinish.Ts {
-				fakeMarkStart.Link = goFinish
-				text = "MARK ASSIST (unfinished)"
-			}
-			ctx.emitSlice(&fakeMarkStart, text)
-		case trace.EvGCSweepStart:
-			slice := ctx.makeSlice(ev, "SWEEP")
-			if done := ev.Link; done != nil && done.Args[0] != 0 {
-				slice.Arg = struct {
-					Swept     uint64 `json:"Swept bytes"`
-					Reclaimed uint64 `json:"Reclaimed bytes"`
-				}{done.Args[0], done.Args[1]}
-			}
-			ctx.emit(slice)
-		case trace.EvGoStart, trace.EvGoStartLabel:
-			info := getGInfo(ev.G)
-			if ev.Type == trace.EvGoStartLabel {
-				ctx.emitSlice(ev, ev.SArgs[0])
-			} else {
-				ctx.emitSlice(ev, info.name)
-			}
-			if info.markAssist != nil {
-				// If we're in a mark assist, synthesize a new slice, ending
-				// either when the mark assist ends or when we're descheduled.
-				markFinish := info.markAssist.Link
-				goFinish := ev.Link
+		case dtrace.EventRangeBegin:
+			switch {
+			case ev.Name == gcRange:
+				ctx.emitSlice(ev, "GC")
+			case strings.HasPrefix(ev.Name, stwRange):
+				if ctx.mode&modeGoroutineOriented != 0 {
+					continue
+				}
+				reason := strings.TrimSuffix(strings.TrimPrefix(ev.Name, stwRange+" ("), ")")
+				ctx.emitSlice(ev, fmt.Sprintf("STW (%s)", reason))
+			case ev.Name == assistRange:
+				// Mark assists can continue past preemptions, so truncate to the
+				// whichever comes first. We'll synthesize another slice if
+				// necessary when the goroutine starts again.
+				markFinish := ev.link
+				goFinish := getGInfo(ev.G).start.link
 				fakeMarkStart := *ev
-				text := "MARK ASSIST (resumed, unfinished)"
-				if markFinish != nil && markFinish.Ts < goFinish.Ts {
-					fakeMarkStart.Link = markFinish
-					text = "MARK ASSIST (resumed)"
+				text := "MARK ASSIST"
+				if markFinish == nil || markFinish.Time > goFinish.Time {
+					fakeMarkStart.link = goFinish
+					text = "MARK ASSIST (unfinished)"
 				}
 				ctx.emitSlice(&fakeMarkStart, text)
+			case ev.Name == sweepRange:
+				ctx.emitSlice(ev, "SWEEP")
+			}
+		case dtrace.EventGoroutine:
+			switch from, to := ev.From, ev.To; {
+			case from == dtrace.GoRunnable && to == dtrace.GoRunning:
+				info := getGInfo(ev.Goroutine)
+				if ev.Reason != "" {
+					ctx.emitSlice(ev, ev.Reason)
+				} else {
+					ctx.emitSlice(ev, info.name)
+				}
+				if info.markAssist != nil {
+					// If we're in a mark assist, synthesize a new slice, ending
+					// either when the mark assist ends or when we're descheduled.
+					markFinish := info.markAssist.link
+					goFinish := ev.link
+					fakeMarkStart := *ev
+					text := "MARK ASSIST (resumed, unfinished)"
+					if markFinish != nil && markFinish.Time < goFinish.Time {
+						fakeMarkStart.link = markFinish
+						text = "MARK ASSIST (resumed)"
+					}
+					ctx.emitSlice(&fakeMarkStart, text)
+				}
+			case from == dtrace.GoNotExist:
+				ctx.emitArrow(ev, "go")
+			case from == dtrace.GoWaiting && to == dtrace.GoRunnable:
+				ctx.emitArrow(ev, "unblock")
+			case from == dtrace.GoRunning && to == dtrace.GoSyscall:
+				ctx.emitInstant(ev, "syscall", "")
+			case from == dtrace.GoSyscall && to == dtrace.GoRunnable:
+				ctx.emitArrow(ev, "sysexit")
 			}
-		case trace.EvGoCreate:
-			ctx.emitArrow(ev, "go")
-		case trace.EvGoUnblock:
-			ctx.emitArrow(ev, "unblock")
-		case trace.EvGoSysCall:
-			ctx.emitInstant(ev, "syscall", "")
-		case trace.EvGoSysExit:
-			ctx.emitArrow(ev, "sysexit")
-		case trace.EvUserLog:
+		case dtrace.EventLog:
 			ctx.emitInstant(ev, formatUserLog(ev), "user event")
-		case trace.EvUserTaskCreate:
+		case dtrace.EventTaskBegin:
 			ctx.emitInstant(ev, "task start", "user event")
-		case trace.EvUserTaskEnd:
+		case dtrace.EventTaskEnd:
 			ctx.emitInstant(ev, "task end", "user event")
 		}
 		// Emit any counter updates.
@@ -746,17 +746,17 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 		ctx.emitSectionFooter(procsSection, "PROCS", 2)
 	}
 
-	ctx.emitFooter(&traceviewer.Event{Name: "thread_name", Phase: "M", PID: procsSection, TID: trace.GCP, Arg: &NameArg{"GC"}})
-	ctx.emitFooter(&traceviewer.Event{Name: "thread_sort_index", Phase: "M", PID: procsSection, TID: trace.GCP, Arg: &SortIndexArg{-6}})
+	ctx.emitFooter(&traceviewer.Event{Name: "thread_name", Phase: "M", PID: procsSection, TID: gcP, Arg: &NameArg{"GC"}})
+	ctx.emitFooter(&traceviewer.Event{Name: "thread_sort_index", Phase: "M", PID: procsSection, TID: gcP, Arg: &SortIndexArg{-6}})
 
-	ctx.emitFooter(&traceviewer.Event{Name: "thread_name", Phase: "M", PID: procsSection, TID: trace.NetpollP, Arg: &NameArg{"Network"}})
-	ctx.emitFooter(&traceviewer.Event{Name: "thread_sort_index", Phase: "M", PID: procsSection, TID: trace.NetpollP, Arg: &SortIndexArg{-5}})
+	ctx.emitFooter(&traceviewer.Event{Name: "thread_name", Phase: "M", PID: procsSection, TID: netpollP, Arg: &NameArg{"Network"}})
+	ctx.emitFooter(&traceviewer.Event{Name: "thread_sort_index", Phase: "M", PID: procsSection, TID: netpollP, Arg: &SortIndexArg{-5}})
 
-	ctx.emitFooter(&traceviewer.Event{Name: "thread_name", Phase: "M", PID: procsSection, TID: trace.TimerP, Arg: &NameArg{"Timers"}})
-	ctx.emitFooter(&traceviewer.Event{Name: "thread_sort_index", Phase: "M", PID: procsSection, TID: trace.TimerP, Arg: &SortIndexArg{-4}})
+	ctx.emitFooter(&traceviewer.Event{Name: "thread_name", Phase: "M", PID: procsSection, TID: timerP, Arg: &NameArg{"Timers"}})
+	ctx.emitFooter(&traceviewer.Event{Name: "thread_sort_index", Phase: "M", PID: procsSection, TID: timerP, Arg: &SortIndexArg{-4}})
 
-	ctx.emitFooter(&traceviewer.Event{Name: "thread_name", Phase: "M", PID: procsSection, TID: trace.SyscallP, Arg: &NameArg{"Syscalls"}})
-	ctx.emitFooter(&traceviewer.Event{Name: "thread_sort_index", Phase: "M", PID: procsSection, TID: trace.SyscallP, Arg: &SortIndexArg{-3}})
+	ctx.emitFooter(&traceviewer.Event{Name: "thread_name", Phase: "M", PID: procsSection, TID: syscallP, Arg: &NameArg{"Syscalls"}})
+	ctx.emitFooter(&traceviewer.Event{Name: "thread_sort_index", Phase: "M", PID: procsSection, TID: syscallP, Arg: &SortIndexArg{-3}})
 
 	// Display rows for Ps if we are in the default trace view mode (not goroutine-oriented presentation)
 	if ctx.mode&modeGoroutineOriented == 0 {
@@ -823,40 +823,40 @@ func (ctx *traceContext) emitSectionFooter(sectionID uint64, name string, priori
 	ctx.emitFooter(&traceviewer.Event{Name: "process_sort_index", Phase: "M", PID: sectionID, Arg: &SortIndexArg{priority}})
 }
 
-func (ctx *traceContext) time(ev *trace.Event) float64 {
+func (ctx *traceContext) time(ev *event) float64 {
 	// Trace viewer wants timestamps in microseconds.
-	return float64(ev.Ts) / 1000
+	return float64(ev.ts()) / 1000
 }
 
-func withinTimeRange(ev *trace.Event, s, e int64) bool {
-	if evEnd := ev.Link; evEnd != nil {
-		return ev.Ts <= e && evEnd.Ts >= s
+func withinTimeRange(ev *event, s, e int64) bool {
+	if evEnd := ev.link; evEnd != nil {
+		return ev.ts() <= e && evEnd.ts() >= s
 	}
-	return ev.Ts >= s && ev.Ts <= e
+	return ev.ts() >= s && ev.ts() <= e
 }
 
 func tsWithinRange(ts, s, e int64) bool {
 	return s <= ts && ts <= e
 }
 
-func (ctx *traceContext) proc(ev *trace.Event) uint64 {
-	if ctx.mode&modeGoroutineOriented != 0 && ev.P < trace.FakeP {
+func (ctx *traceContext) proc(ev *event) uint64 {
+	if ctx.mode&modeGoroutineOriented != 0 && ev.P < fakeP {
 		return ev.G
 	} else {
 		return uint64(ev.P)
 	}
 }
 
-func (ctx *traceContext) emitSlice(ev *trace.Event, name string) {
+func (ctx *traceContext) emitSlice(ev *event, name string) {
 	ctx.emit(ctx.makeSlice(ev, name))
 }
 
-func (ctx *traceContext) makeSlice(ev *trace.Event, name string) *traceviewer.Event {
+func (ctx *traceContext) makeSlice(ev *event, name string) *traceviewer.Event {
 	// If ViewerEvent.Dur is not a positive value,
 	// trace viewer handles it as a non-terminating time interval.
 	// Avoid it by setting the field with a small value.
-	durationUsec := ctx.time(ev.Link) - ctx.time(ev)
-	if ev.Link.Ts-ev.Ts <= 0 {
+	durationUsec := ctx.time(ev.link) - ctx.time(ev)
+	if ev.link.ts()-ev.ts() <= 0 {
 		durationUsec = 0.0001 // 0.1 nanoseconds
 	}
 	sl := &traceviewer.Event{
@@ -865,14 +865,14 @@ func (ctx *traceContext) makeSlice(ev *trace.Event, name string) *traceviewer.Ev
 		Time:     ctx.time(ev),
 		Dur:      durationUsec,
 		TID:      ctx.proc(ev),
-		Stack:    ctx.stack(ev.Stk),
-		EndStack: ctx.stack(ev.Link.Stk),
+		Stack:    ctx.stack(ev.Stack),
+		EndStack: ctx.stack(ev.link.Stack),
 	}
 
 	// grey out non-overlapping events if the event is not a global event (ev.G == 0)
 	if ctx.mode&modeTaskOriented != 0 && ev.G != 0 {
 		// include P information.
-		if t := ev.Type; t == trace.EvGoStart || t == trace.EvGoStartLabel {
+		if ev.Kind == dtrace.EventGoroutine && ev.To == dtrace.GoRunning {
 			type Arg struct {
 				P int
 			}
@@ -912,19 +912,19 @@ func (ctx *traceContext) emitTask(task *taskDesc, sortIndex int) {
 	}
 	targ := TaskArg{ID: task.id}
 	if task.create != nil {
-		sl.Stack = ctx.stack(task.create.Stk)
+		sl.Stack = ctx.stack(task.create.Stack)
 		targ.StartG = task.create.G
 	}
 	if task.end != nil {
-		sl.EndStack = ctx.stack(task.end.Stk)
+		sl.EndStack = ctx.stack(task.end.Stack)
 		targ.EndG = task.end.G
 	}
 	sl.Arg = targ
 	ctx.emit(sl)
 
-	if task.create != nil && task.create.Type == trace.EvUserTaskCreate && task.create.Args[1] != 0 {
+	if task.create != nil && task.create.Parent != 0 {
 		ctx.arrowSeq++
-		ctx.emit(&traceviewer.Event{Name: "newTask", Phase: "s", TID: task.create.Args[1], ID: ctx.arrowSeq, Time: ts, PID: tasksSection})
+		ctx.emit(&traceviewer.Event{Name: "newTask", Phase: "s", TID: task.create.Parent, ID: ctx.arrowSeq, Time: ts, PID: tasksSection})
 		ctx.emit(&traceviewer.Event{Name: "newTask", Phase: "t", TID: taskRow, ID: ctx.arrowSeq, Time: ts, PID: tasksSection})
 	}
 }
@@ -957,7 +957,7 @@ func (ctx *traceContext) emitRegion(s regionDesc) {
 		Cname:    pickTaskColor(s.TaskID),
 	}
 	if s.Start != nil {
-		sl0.Stack = ctx.stack(s.Start.Stk)
+		sl0.Stack = ctx.stack(s.Start.Stack)
 	}
 	ctx.emit(sl0)
 
@@ -973,7 +973,7 @@ func (ctx *traceContext) emitRegion(s regionDesc) {
 		Arg:      RegionArg{TaskID: s.TaskID},
 	}
 	if s.End != nil {
-		sl1.Stack = ctx.stack(s.End.Stk)
+		sl1.Stack = ctx.stack(s.End.Stack)
 	}
 	ctx.emit(sl1)
 }
@@ -983,7 +983,7 @@ type heapCountersArg struct {
 	NextGC    uint64
 }
 
-func (ctx *traceContext) emitHeapCounters(ev *trace.Event) {
+func (ctx *traceContext) emitHeapCounters(ev *event) {
 	if ctx.prevHeapStats == ctx.heapStats {
 		return
 	}
@@ -991,7 +991,7 @@ func (ctx *traceContext) emitHeapCounters(ev *trace.Event) {
 	if ctx.heapStats.nextGC > ctx.heapStats.heapAlloc {
 		diff = ctx.heapStats.nextGC - ctx.heapStats.heapAlloc
 	}
-	if tsWithinRange(ev.Ts, ctx.startTime, ctx.endTime) {
+	if tsWithinRange(ev.ts(), ctx.startTime, ctx.endTime) {
 		ctx.emit(&traceviewer.Event{Name: "Heap", Phase: "C", Time: ctx.time(ev), PID: 1, Arg: &heapCountersArg{ctx.heapStats.heapAlloc, diff}})
 	}
 	ctx.prevHeapStats = ctx.heapStats
@@ -1003,11 +1003,11 @@ type goroutineCountersArg struct {
 	GCWaiting uint64
 }
 
-func (ctx *traceContext) emitGoroutineCounters(ev *trace.Event) {
+func (ctx *traceContext) emitGoroutineCounters(ev *event) {
 	if ctx.prevGstates == ctx.gstates {
 		return
 	}
-	if tsWithinRange(ev.Ts, ctx.startTime, ctx.endTime) {
+	if tsWithinRange(ev.ts(), ctx.startTime, ctx.endTime) {
 		ctx.emit(&traceviewer.Event{Name: "Goroutines", Phase: "C", Time: ctx.time(ev), PID: 1, Arg: &goroutineCountersArg{uint64(ctx.gstates[gRunning]), uint64(ctx.gstates[gRunnable]), uint64(ctx.gstates[gWaitingGC])}})
 	}
 	ctx.prevGstates = ctx.gstates
@@ -1018,11 +1018,11 @@ type threadCountersArg struct {
 	InSyscall int64
 }
 
-func (ctx *traceContext) emitThreadCounters(ev *trace.Event) {
+func (ctx *traceContext) emitThreadCounters(ev *event) {
 	if ctx.prevThreadStats == ctx.threadStats {
 		return
 	}
-	if tsWithinRange(ev.Ts, ctx.startTime, ctx.endTime) {
+	if tsWithinRange(ev.ts(), ctx.startTime, ctx.endTime) {
 		ctx.emit(&traceviewer.Event{Name: "Threads", Phase: "C", Time: ctx.time(ev), PID: 1, Arg: &threadCountersArg{
 			Running:   ctx.threadStats.prunning,
 			InSyscall: ctx.threadStats.insyscall}})
@@ -1030,8 +1030,8 @@ func (ctx *traceContext) emitThreadCounters(ev *trace.Event) {
 	ctx.prevThreadStats = ctx.threadStats
 }
 
-func (ctx *traceContext) emitInstant(ev *trace.Event, name, category string) {
-	if !tsWithinRange(ev.Ts, ctx.startTime, ctx.endTime) {
+func (ctx *traceContext) emitInstant(ev *event, name, category string) {
+	if !tsWithinRange(ev.ts(), ctx.startTime, ctx.endTime) {
 		return
 	}
 
@@ -1054,13 +1054,6 @@ func (ctx *traceContext) emitInstant(ev *trace.Event, name, category string) {
 			cname = colorLightGrey
 		}
 	}
-	var arg interface{}
-	if ev.Type == trace.EvProcStart {
-		type Arg struct {
-			ThreadID uint64
-		}
-		arg = &Arg{ev.Args[0]}
-	}
 	ctx.emit(&traceviewer.Event{
 		Name:     name,
 		Category: category,
@@ -1068,25 +1061,24 @@ func (ctx *traceContext) emitInstant(ev *trace.Event, name, category string) {
 		Scope:    "t",
 		Time:     ctx.time(ev),
 		TID:      ctx.proc(ev),
-		Stack:    ctx.stack(ev.Stk),
-		Cname:    cname,
-		Arg:      arg})
+		Stack:    ctx.stack(ev.Stack),
+		Cname:    cname})
 }
 
-func (ctx *traceContext) emitArrow(ev *trace.Event, name string) {
-	if ev.Link == nil {
+func (ctx *traceContext) emitArrow(ev *event, name string) {
+	if ev.link == nil || ev.link.To != dtrace.GoRunning {
 		// The other end of the arrow is not captured in the trace.
 		// For example, a goroutine was unblocked but was not scheduled before trace stop.
 		return
 	}
-	if ctx.mode&modeGoroutineOriented != 0 && (!ctx.gs[ev.Link.G] || ev.Link.Ts < ctx.startTime || ev.Link.Ts > ctx.endTime) {
+	if ctx.mode&modeGoroutineOriented != 0 && (!ctx.gs[ev.link.G] || ev.link.ts() < ctx.startTime || ev.link.ts() > ctx.endTime) {
 		return
 	}
 
-	if ev.P == trace.NetpollP || ev.P == trace.TimerP || ev.P == trace.SyscallP {
+	if ev.P == netpollP || ev.P == timerP || ev.P == syscallP {
 		// Trace-viewer discards arrows if they don't start/end inside of a slice or instant.
 		// So emit a fake instant at the start of the arrow.
-		ctx.emitInstant(&trace.Event{P: ev.P, Ts: ev.Ts}, "unblock", "")
+		ctx.emitInstant(&event{Event: dtrace.Event{P: ev.P, Time: ev.Time}}, "unblock", "")
 	}
 
 	color := ""
@@ -1105,16 +1097,16 @@ func (ctx *traceContext) emitArrow(ev *trace.Event, name string) {
 	}
 
 	ctx.arrowSeq++
-	ctx.emit(&traceviewer.Event{Name: name, Phase: "s", TID: ctx.proc(ev), ID: ctx.arrowSeq, Time: ctx.time(ev), Stack: ctx.stack(ev.Stk), Cname: color})
-	ctx.emit(&traceviewer.Event{Name: name, Phase: "t", TID: ctx.proc(ev.Link), ID: ctx.arrowSeq, Time: ctx.time(ev.Link), Cname: color})
+	ctx.emit(&traceviewer.Event{Name: name, Phase: "s", TID: ctx.proc(ev), ID: ctx.arrowSeq, Time: ctx.time(ev), Stack: ctx.stack(ev.Stack), Cname: color})
+	ctx.emit(&traceviewer.Event{Name: name, Phase: "t", TID: ctx.proc(ev.link), ID: ctx.arrowSeq, Time: ctx.time(ev.link), Cname: color})
 }
 
-func (ctx *traceContext) stack(stk []*trace.Frame) int {
+func (ctx *traceContext) stack(stk []dtrace.Frame) int {
 	return ctx.buildBranch(ctx.frameTree, stk)
 }
 
 // buildBranch builds one branch in the prefix tree rooted at ctx.frameTree.
-func (ctx *traceContext) buildBranch(parent frameNode, stk []*trace.Frame) int {
+func (ctx *traceContext) buildBranch(parent frameNode, stk []dtrace.Frame) int {
 	if len(stk) == 0 {
 		return parent.id
 	}
@@ -1128,7 +1120,7 @@ func (ctx *traceContext) buildBranch(parent frameNode, stk []*trace.Frame) int {
 		node.id = ctx.frameSeq
 		node.children = make(map[uint64]frameNode)
 		parent.children[frame.PC] = node
-		ctx.consumer.consumeViewerFrame(strconv.Itoa(node.id), traceviewer.Frame{Name: fmt.Sprintf("%v:%v", frame.Fn, frame.Line), Parent: parent.id})
+		ctx.consumer.consumeViewerFrame(strconv.Itoa(node.id), traceviewer.Frame{Name: fmt.Sprintf("%v:%v", frame.Func, frame.Line), Parent: parent.id})
 	}
 	return ctx.buildBranch(node, stk)
 }
@@ -1139,20 +1131,34 @@ func isSystemGoroutine(entryFn string) bool {
 	return entryFn != "runtime.main" && strings.HasPrefix(entryFn, "runtime.")
 }
 
+// entryFunc returns the name of the function that the goroutine created
+// by ev starts running, or "" if the trace does not tell.
+func entryFunc(create *event) string {
+	for ev := create.link; ev != nil; ev = ev.link {
+		if ev.To == dtrace.GoRunning {
+			if len(ev.Stack) > 0 {
+				return ev.Stack[0].Func
+			}
+			break
+		}
+	}
+	return ""
+}
+
 // firstTimestamp returns the timestamp of the first event record.
 func firstTimestamp() int64 {
-	res, _ := parseTrace()
-	if len(res.Events) > 0 {
-		return res.Events[0].Ts
+	events, _ := parseEvents()
+	if len(events) > 0 {
+		return events[0].ts()
 	}
 	return 0
 }
 
 // lastTimestamp returns the timestamp of the last event record.
 func lastTimestamp() int64 {
-	res, _ := parseTrace()
-	if n := len(res.Events); n > 1 {
-		return res.Events[n-1].Ts
+	events, _ := parseEvents()
+	if n := len(events); n > 1 {
+		return events[n-1].ts()
 	}
 	return 0
 }
@@ -18,21 +18,6 @@ import (
 	"time"
 )
 
-// stacks is a fake stack map populated for test.
-type stacks map[uint64][]*trace.Frame
-
-// add adds a stack with a single frame whose Fn field is
-// set to the provided fname and returns a unique stack id.
-func (s *stacks) add(fname string) uint64 {
-	if *s == nil {
-		*s = make(map[uint64][]*trace.Frame)
-	}
-
-	id := uint64(len(*s))
-	(*s)[id] = []*trace.Frame{{Fn: fname}}
-	return id
-}
-
 // TestGoroutineCount tests runnable/running goroutine counts computed by generateTrace
 // remain in the valid range.
 //   - the counts must not be negative. generateTrace will return an error.
@@ -42,37 +27,34 @@ func TestGoroutineCount(t *testing.T) {
 	w.Emit(trace.EvBatch, 0, 0)  // start of per-P batch event [pid, timestamp]
 	w.Emit(trace.EvFrequency, 1) // [ticks per second]
 
-	var s stacks
-
 	// In this test, we assume a valid trace contains EvGoWaiting or EvGoInSyscall
 	// event for every blocked goroutine.
 
 	// goroutine 10: blocked
-	w.Emit(trace.EvGoCreate, 1, 10, s.add("pkg.f1"), s.add("main.f1")) // [timestamp, new goroutine id, new stack id, stack id]
-	w.Emit(trace.EvGoWaiting, 1, 10)                                   // [timestamp, goroutine id]
+	w.Emit(trace.EvGoCreate, 1, 10, 0, 0) // [timestamp, new goroutine id, new stack id, stack id]
+	w.Emit(trace.EvGoWaiting, 1, 10)      // [timestamp, goroutine id]
 
 	// goroutine 20: in syscall
-	w.Emit(trace.EvGoCreate, 1, 20, s.add("pkg.f2"), s.add("main.f2"))
+	w.Emit(trace.EvGoCreate, 1, 20, 0, 0)
 	w.Emit(trace.EvGoInSyscall, 1, 20) // [timestamp, goroutine id]
 
 	// goroutine 30: runnable
-	w.Emit(trace.EvGoCreate, 1, 30, s.add("pkg.f3"), s.add("main.f3"))
+	w.Emit(trace.EvGoCreate, 1, 30, 0, 0)
 
 	w.Emit(trace.EvProcStart, 2, 0) // [timestamp, thread id]
 
 	// goroutine 40: runnable->running->runnable
-	w.Emit(trace.EvGoCreate, 1, 40, s.add("pkg.f4"), s.add("main.f4"))
-	w.Emit(trace.EvGoStartLocal, 1, 40)          // [timestamp, goroutine id]
-	w.Emit(trace.EvGoSched, 1, s.add("main.f4")) // [timestamp, stack]
+	w.Emit(trace.EvGoCreate, 1, 40, 0, 0)
+	w.Emit(trace.EvGoStartLocal, 1, 40) // [timestamp, goroutine id]
+	w.Emit(trace.EvGoSched, 1, 0)       // [timestamp, stack]
 
-	res, err := trace.Parse(w, "")
+	events, err := readEvents(w)
 	if err != nil {
 		t.Fatalf("failed to parse test trace: %v", err)
 	}
-	res.Stacks = s // use fake stacks.
 
 	params := &traceParams{
-		parsed:  res,
+		events:  events,
 		endTime: int64(1<<63 - 1),
 	}
 
@@ -99,34 +81,31 @@ func TestGoroutineFilter(t *testing.T) {
 	// Test that we handle state changes to selected goroutines
 	// caused by events on goroutines that are not selected.
 
-	var s stacks
-
 	w := trace.NewWriter()
 	w.Emit(trace.EvBatch, 0, 0)  // start of per-P batch event [pid, timestamp]
 	w.Emit(trace.EvFrequency, 1) // [ticks per second]
 
 	// goroutine 10: blocked
-	w.Emit(trace.EvGoCreate, 1, 10, s.add("pkg.f1"), s.add("main.f1")) // [timestamp, new goroutine id, new stack id, stack id]
-	w.Emit(trace.EvGoWaiting, 1, 10)                                   // [timestamp, goroutine id]
+	w.Emit(trace.EvGoCreate, 1, 10, 0, 0) // [timestamp, new goroutine id, new stack id, stack id]
+	w.Emit(trace.EvGoWaiting, 1, 10)      // [timestamp, goroutine id]
 
 	// goroutine 20: runnable->running->unblock 10
-	w.Emit(trace.EvGoCreate, 1, 20, s.add("pkg.f2"), s.add("main.f2"))
-	w.Emit(trace.EvGoStartLocal, 1, 20)                    // [timestamp, goroutine id]
-	w.Emit(trace.EvGoUnblockLocal, 1, 10, s.add("pkg.f2")) // [timestamp, goroutine id, stack]
-	w.Emit(trace.EvGoEnd, 1)                               // [timestamp]
+	w.Emit(trace.EvGoCreate, 1, 20, 0, 0)
+	w.Emit(trace.EvGoStartLocal, 1, 20)      // [timestamp, goroutine id]
+	w.Emit(trace.EvGoUnblockLocal, 1, 10, 0) // [timestamp, goroutine id, stack]
+	w.Emit(trace.EvGoEnd, 1)                 // [timestamp]
 
 	// goroutine 10: runnable->running->block
-	w.Emit(trace.EvGoStartLocal, 1, 10)         // [timestamp, goroutine id]
-	w.Emit(trace.EvGoBlock, 1, s.add("pkg.f3")) // [timestamp, stack]
+	w.Emit(trace.EvGoStartLocal, 1, 10) // [timestamp, goroutine id]
+	w.Emit(trace.EvGoBlock, 1, 0)       // [timestamp, stack]
 
-	res, err := trace.Parse(w, "")
+	events, err := readEvents(w)
 	if err != nil {
 		t.Fatalf("failed to parse test trace: %v", err)
 	}
-	res.Stacks = s // use fake stacks
 
 	params := &traceParams{
-		parsed:  res,
+		events:  events,
 		endTime: int64(1<<63 - 1),
 		gs:      map[uint64]bool{10: true},
 	}
@@ -142,24 +121,22 @@ func TestPreemptedMarkAssist(t *testing.T) {
 	w.Emit(trace.EvBatch, 0, 0)  // start of per-P batch event [pid, timestamp]
 	w.Emit(trace.EvFrequency, 1) // [ticks per second]
 
-	var s stacks
 	// goroutine 9999: running -> mark assisting -> preempted -> assisting -> running -> block
-	w.Emit(trace.EvGoCreate, 1, 9999, s.add("pkg.f1"), s.add("main.f1")) // [timestamp, new goroutine id, new stack id, stack id]
-	w.Emit(trace.EvGoStartLocal, 1, 9999)                                // [timestamp, goroutine id]
-	w.Emit(trace.EvGCMarkAssistStart, 1, s.add("main.f1"))               // [timestamp, stack]
-	w.Emit(trace.EvGoPreempt, 1, s.add("main.f1"))                       // [timestamp, stack]
-	w.Emit(trace.EvGoStartLocal, 1, 9999)                                // [timestamp, goroutine id]
-	w.Emit(trace.EvGCMarkAssistDone, 1)                                  // [timestamp]
-	w.Emit(trace.EvGoBlock, 1, s.add("main.f2"))                         // [timestamp, stack]
-
-	res, err := trace.Parse(w, "")
+	w.Emit(trace.EvGoCreate, 1, 9999, 0, 0) // [timestamp, new goroutine id, new stack id, stack id]
+	w.Emit(trace.EvGoStartLocal, 1, 9999)   // [timestamp, goroutine id]
+	w.Emit(trace.EvGCMarkAssistStart, 1, 0) // [timestamp, stack]
+	w.Emit(trace.EvGoPreempt, 1, 0)         // [timestamp, stack]
+	w.Emit(trace.EvGoStartLocal, 1, 9999)   // [timestamp, goroutine id]
+	w.Emit(trace.EvGCMarkAssistDone, 1)     // [timestamp]
+	w.Emit(trace.EvGoBlock, 1, 0)           // [timestamp, stack]
+
+	events, err := readEvents(w)
 	if err != nil {
 		t.Fatalf("failed to parse test trace: %v", err)
 	}
-	res.Stacks = s // use fake stacks
 
 	params := &traceParams{
-		parsed:  res,
+		events:  events,
 		endTime: int64(1<<63 - 1),
 	}
 
@@ -190,7 +167,7 @@ func TestFoo(t *testing.T) {
 	if err := traceProgram(t, prog0, "TestFoo"); err != nil {
 		t.Fatalf("failed to trace the program: %v", err)
 	}
-	res, err := parseTrace()
+	events, err := parseEvents()
 	if err != nil {
 		t.Fatalf("failed to parse the trace: %v", err)
 	}
@@ -207,7 +184,7 @@ func TestFoo(t *testing.T) {
 	}
 
 	params := &traceParams{
-		parsed:    res,
+		events:    events,
 		mode:      modeTaskOriented,
 		startTime: task.firstTimestamp() - 1,
 		endTime:   task.lastTimestamp() + 1,
@@ -262,7 +239,7 @@ func TestDirectSemaphoreHandoff(t *testing.T) {
 	if err := traceProgram(t, prog0, "TestDirectSemaphoreHandoff"); err != nil {
 		t.Fatalf("failed to trace the program: %v", err)
 	}
-	_, err := parseTrace()
+	_, err := parseEvents()
 	if err != nil {
 		t.Fatalf("failed to parse the trace: %v", err)
 	}
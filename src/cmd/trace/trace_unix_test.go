@@ -74,7 +74,7 @@ func TestGoroutineInSyscall(t *testing.T) {
 	}
 	trace.Stop()
 
-	res, err := traceparser.Parse(buf, "")
+	events, err := readEvents(buf)
 	if err == traceparser.ErrTimeOrder {
 		t.Skipf("skipping due to golang.org/issue/16755 (timestamps are unreliable): %v", err)
 	} else if err != nil {
@@ -94,7 +94,7 @@ func TestGoroutineInSyscall(t *testing.T) {
 	}
 
 	param := &traceParams{
-		parsed:  res,
+		events:  events,
 		endTime: int64(1<<63 - 1),
 	}
 	if err := generateTrace(param, c); err != nil {
@@ -0,0 +1,61 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzip2
+
+import "io"
+
+// bitWriter accumulates bits, most significant bit first, and writes them
+// to an io.Writer in whole bytes. Like bitReader, its Write* methods don't
+// return errors; any error from the underlying writer is kept in err.
+type bitWriter struct {
+	w    io.Writer
+	buf  []byte // complete bytes not yet written to w
+	n    uint64
+	bits uint
+	err  error
+}
+
+func (bw *bitWriter) reset(w io.Writer) {
+	bw.w = w
+	bw.buf = bw.buf[:0]
+	bw.n = 0
+	bw.bits = 0
+	bw.err = nil
+}
+
+// WriteBits writes the low bits bits of v. bits must be at most 32.
+func (bw *bitWriter) WriteBits(bits uint, v uint32) {
+	bw.n = bw.n<<bits | uint64(v)
+	bw.bits += bits
+	for bw.bits >= 8 {
+		bw.bits -= 8
+		bw.buf = append(bw.buf, byte(bw.n>>bw.bits))
+	}
+}
+
+// WriteBits64 is like WriteBits but accepts up to 64 bits.
+func (bw *bitWriter) WriteBits64(bits uint, v uint64) {
+	if bits > 32 {
+		bw.WriteBits(bits-32, uint32(v>>32))
+		bits = 32
+	}
+	bw.WriteBits(bits, uint32(v))
+}
+
+// Flush writes the complete bytes accumulated so far to the underlying writer.
+func (bw *bitWriter) Flush() {
+	if bw.err == nil && len(bw.buf) > 0 {
+		_, bw.err = bw.w.Write(bw.buf)
+	}
+	bw.buf = bw.buf[:0]
+}
+
+// Close pads the output with zero bits to a byte boundary and flushes it.
+func (bw *bitWriter) Close() {
+	if bw.bits > 0 {
+		bw.WriteBits(8-bw.bits, 0)
+	}
+	bw.Flush()
+}
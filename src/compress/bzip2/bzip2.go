@@ -2,7 +2,7 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package bzip2 implements bzip2 decompression.
+// Package bzip2 implements bzip2 compression and decompression.
 package bzip2
 
 import "io"
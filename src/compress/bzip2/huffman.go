@@ -235,3 +235,116 @@ func buildHuffmanNode(t *huffmanTree, codes []huffmanCode, level uint32) (nodeIn
 
 	return
 }
+
+// huffmanCodeLengths sets lengths to the code lengths of a Huffman code for
+// symbols with the given frequencies, limited to maxLen bits. It builds the
+// code the same way as the bzip2 reference implementation, breaking ties in
+// favor of shallower subtrees and halving the frequencies until the code fits,
+// so that the Writer produces the same output as the bzip2 command.
+func huffmanCodeLengths(lengths []uint8, freqs []int32, maxLen int) {
+	n := len(freqs)
+	// Weights hold a frequency in the upper 24 bits and a subtree depth in
+	// the lower 8 bits. Node 0 is a sentinel and the leaves are 1...n.
+	weight := make([]uint32, 2*n+1)
+	parent := make([]int, 2*n+1)
+	heap := make([]int, n+2)
+	for i, f := range freqs {
+		if f == 0 {
+			f = 1
+		}
+		weight[i+1] = uint32(f) << 8
+	}
+
+	up := func(z int) {
+		tmp := heap[z]
+		for weight[tmp] < weight[heap[z>>1]] {
+			heap[z] = heap[z>>1]
+			z >>= 1
+		}
+		heap[z] = tmp
+	}
+	down := func(z, nHeap int) {
+		tmp := heap[z]
+		for {
+			yy := z << 1
+			if yy > nHeap {
+				break
+			}
+			if yy < nHeap && weight[heap[yy+1]] < weight[heap[yy]] {
+				yy++
+			}
+			if weight[tmp] < weight[heap[yy]] {
+				break
+			}
+			heap[z] = heap[yy]
+			z = yy
+		}
+		heap[z] = tmp
+	}
+
+	for {
+		nNodes, nHeap := n, 0
+		heap[0], weight[0], parent[0] = 0, 0, -2
+		for i := 1; i <= n; i++ {
+			parent[i] = -1
+			nHeap++
+			heap[nHeap] = i
+			up(nHeap)
+		}
+		for nHeap > 1 {
+			n1 := heap[1]
+			heap[1] = heap[nHeap]
+			nHeap--
+			down(1, nHeap)
+			n2 := heap[1]
+			heap[1] = heap[nHeap]
+			nHeap--
+			down(1, nHeap)
+			nNodes++
+			parent[n1], parent[n2] = nNodes, nNodes
+			d1, d2 := weight[n1]&0xff, weight[n2]&0xff
+			if d2 > d1 {
+				d1 = d2
+			}
+			weight[nNodes] = (weight[n1]&^0xff + weight[n2]&^0xff) | (1 + d1)
+			parent[nNodes] = -1
+			nHeap++
+			heap[nHeap] = nNodes
+			up(nHeap)
+		}
+
+		tooLong := false
+		for i := 1; i <= n; i++ {
+			depth := 0
+			for k := i; parent[k] >= 0; k = parent[k] {
+				depth++
+			}
+			lengths[i-1] = uint8(depth)
+			if depth > maxLen {
+				tooLong = true
+			}
+		}
+		if !tooLong {
+			return
+		}
+		for i := 1; i <= n; i++ {
+			weight[i] = (1 + weight[i]>>8/2) << 8
+		}
+	}
+}
+
+// huffmanCodes sets codes to the canonical Huffman code with the given code
+// lengths: shorter codes come first and codes of the same length are
+// assigned in symbol order.
+func huffmanCodes(codes []uint32, lengths []uint8) {
+	code := uint32(0)
+	for length := uint8(1); length <= 32; length++ {
+		for i, l := range lengths {
+			if l == length {
+				codes[i] = code
+				code++
+			}
+		}
+		code <<= 1
+	}
+}
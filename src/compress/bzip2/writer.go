@@ -0,0 +1,452 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzip2
+
+import (
+	"errors"
+	"fmt"
+	"internal/sais"
+	"io"
+)
+
+// These constants are the compression levels accepted by NewWriterLevel.
+// The level is the block size in units of 100,000 bytes: higher levels
+// compress better but need more memory to compress and to decompress.
+const (
+	BestSpeed          = 1
+	BestCompression    = 9
+	DefaultCompression = -1 // same as BestCompression, like the bzip2 command
+)
+
+const (
+	maxHuffmanLen = 17 // the longest code the Writer generates
+	groupSize     = 50 // symbols coded with one Huffman table
+	numIterations = 4  // refinements of the Huffman tables
+)
+
+var errWriterClosed = errors.New("bzip2: write to closed Writer")
+
+// A Writer is an io.WriteCloser.
+// Writes to a Writer are compressed and written to w.
+type Writer struct {
+	bw          bitWriter
+	level       int
+	maxBlock    int // length of the block at which it is compressed
+	wroteHeader bool
+	closed      bool
+	err         error
+	combinedCRC uint32
+
+	// The current block, after the initial run-length encoding,
+	// and the run of runByte that is still to be added to it.
+	block    []byte
+	blockCRC uint32
+	runByte  byte
+	runLen   int
+
+	// Buffers reused across blocks.
+	text []byte   // the block, twice
+	sa   []int32  // suffix array of text
+	mtfv []uint16 // output of the move-to-front and zero run encoding
+}
+
+// NewWriter returns a new Writer.
+// Writes to the returned writer are compressed and written to w.
+//
+// It is the caller's responsibility to call Close on the Writer when done.
+// Writes may be buffered and not flushed until Close.
+func NewWriter(w io.Writer) *Writer {
+	z, _ := NewWriterLevel(w, DefaultCompression)
+	return z
+}
+
+// NewWriterLevel is like NewWriter but specifies the compression level
+// instead of assuming DefaultCompression.
+//
+// The compression level can be DefaultCompression or any integer value
+// between BestSpeed and BestCompression inclusive.
+// The error returned will be nil if the level is valid.
+func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
+	if level == DefaultCompression {
+		level = BestCompression
+	}
+	if level < BestSpeed || level > BestCompression {
+		return nil, fmt.Errorf("bzip2: invalid compression level: %d", level)
+	}
+	z := new(Writer)
+	z.init(w, level)
+	return z, nil
+}
+
+func (z *Writer) init(w io.Writer, level int) {
+	z.bw.reset(w)
+	z.level = level
+	// The bzip2 reference implementation leaves room for the
+	// run-length encoding of the last few bytes of a block.
+	z.maxBlock = level*100000 - 19
+	z.wroteHeader = false
+	z.closed = false
+	z.err = nil
+	z.combinedCRC = 0
+	z.block = z.block[:0]
+	z.blockCRC = 0
+	z.runLen = 0
+}
+
+// Reset discards the Writer z's state and makes it equivalent to the
+// result of its original state from NewWriter or NewWriterLevel, but
+// writing to w instead. This permits reusing a Writer rather than
+// allocating a new one.
+func (z *Writer) Reset(w io.Writer) {
+	z.init(w, z.level)
+}
+
+// Write writes a compressed form of p to the underlying io.Writer. The
+// compressed bytes are not necessarily flushed until the Writer is closed.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errWriterClosed
+	}
+	for i, b := range p {
+		if len(z.block) >= z.maxBlock {
+			// Like the reference implementation, carry the pending
+			// run over to the next block.
+			if err := z.writeBlock(); err != nil {
+				return i, err
+			}
+		}
+		if z.runLen > 0 && b == z.runByte && z.runLen < 255 {
+			z.runLen++
+			continue
+		}
+		z.flushRun()
+		z.runByte = b
+		z.runLen = 1
+	}
+	return len(p), nil
+}
+
+// flushRun adds the pending run of bytes to the block. Runs of four or more
+// bytes are stored as four bytes followed by a count of further repeats.
+func (z *Writer) flushRun() {
+	b, n := z.runByte, z.runLen
+	if n == 0 {
+		return
+	}
+	crc := ^z.blockCRC
+	for i := 0; i < n; i++ {
+		crc = crctab[byte(crc>>24)^b] ^ (crc << 8)
+	}
+	z.blockCRC = ^crc
+	if n < 4 {
+		for i := 0; i < n; i++ {
+			z.block = append(z.block, b)
+		}
+	} else {
+		z.block = append(z.block, b, b, b, b, byte(n-4))
+	}
+	z.runLen = 0
+}
+
+// writeHeader writes the stream header if it has not been written yet.
+func (z *Writer) writeHeader() {
+	if !z.wroteHeader {
+		z.wroteHeader = true
+		z.bw.WriteBits(16, bzip2FileMagic)
+		z.bw.WriteBits(8, 'h')
+		z.bw.WriteBits(8, '0'+uint32(z.level))
+	}
+}
+
+// writeBlock compresses and writes the current block.
+func (z *Writer) writeBlock() error {
+	z.writeHeader()
+	if len(z.block) > 0 {
+		z.combinedCRC = (z.combinedCRC<<1 | z.combinedCRC>>31) ^ z.blockCRC
+		z.bw.WriteBits64(48, bzip2BlockMagic)
+		z.bw.WriteBits(32, z.blockCRC)
+		z.bw.WriteBits(1, 0) // not randomized
+		z.compressBlock()
+		z.block = z.block[:0]
+		z.blockCRC = 0
+	}
+	z.bw.Flush()
+	z.err = z.bw.err
+	return z.err
+}
+
+// Close closes the Writer by flushing any unwritten data to the underlying
+// io.Writer and writing the end of the stream.
+// It does not close the underlying io.Writer.
+func (z *Writer) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+	z.flushRun()
+	z.writeHeader()
+	if len(z.block) > 0 {
+		if err := z.writeBlock(); err != nil {
+			return err
+		}
+	}
+	z.bw.WriteBits64(48, bzip2FinalMagic)
+	z.bw.WriteBits(32, z.combinedCRC)
+	z.bw.Close()
+	z.err = z.bw.err
+	return z.err
+}
+
+// compressBlock writes the origin pointer and the encoded contents of the
+// block: the Burrows-Wheeler transform of the block, move-to-front and
+// zero run encoded, and coded with several Huffman tables.
+func (z *Writer) compressBlock() {
+	bw := &z.bw
+	block := z.block
+	n := len(block)
+
+	// The Burrows-Wheeler transform sorts the rotations of the block.
+	// Sorting the suffixes of the block repeated twice and keeping those
+	// that start in the first copy gives the rotations in order.
+	z.text = append(append(z.text[:0], block...), block...)
+	if cap(z.sa) < 2*n {
+		z.sa = make([]int32, 2*n)
+	}
+	sa := z.sa[:2*n]
+	for i := range sa {
+		sa[i] = 0
+	}
+	sais.Text32(z.text, sa)
+
+	// The bytes in the block are renumbered to leave out unused values.
+	var inUse [256]bool
+	for _, b := range block {
+		inUse[b] = true
+	}
+	var unseqToSeq [256]byte
+	numInUse := 0
+	for i, used := range inUse {
+		if used {
+			unseqToSeq[i] = byte(numInUse)
+			numInUse++
+		}
+	}
+	alphaSize := numInUse + 2
+	eob := uint16(numInUse + 1)
+
+	// Move-to-front encode the last column of the sorted rotations,
+	// writing runs of zeros in bijective base 2 with the symbols
+	// RUNA and RUNB (see the comment in readBlock).
+	var freqs [258]int32
+	mtfv := z.mtfv[:0]
+	mtf := newMTFDecoderWithRange(numInUse)
+	zeros := 0
+	flushZeros := func() {
+		if zeros == 0 {
+			return
+		}
+		zeros--
+		for {
+			sym := uint16(zeros & 1) // RUNA or RUNB
+			mtfv = append(mtfv, sym)
+			freqs[sym]++
+			if zeros < 2 {
+				break
+			}
+			zeros = (zeros - 2) / 2
+		}
+		zeros = 0
+	}
+	origPtr, row := 0, 0
+	for _, p := range sa {
+		if int(p) >= n {
+			continue
+		}
+		if p == 0 {
+			origPtr = row
+		}
+		row++
+		j := int(p) - 1
+		if j < 0 {
+			j += n
+		}
+		c := unseqToSeq[block[j]]
+		if mtf.First() == c {
+			zeros++
+			continue
+		}
+		flushZeros()
+		pos := 1
+		for mtf[pos] != c {
+			pos++
+		}
+		mtf.Decode(pos)
+		mtfv = append(mtfv, uint16(pos+1))
+		freqs[pos+1]++
+	}
+	flushZeros()
+	mtfv = append(mtfv, eob)
+	freqs[eob]++
+	z.mtfv = mtfv
+
+	bw.WriteBits(24, uint32(origPtr))
+
+	// The symbols in use, as a two-level bitmap.
+	var inUse16 uint32
+	for i := 0; i < 16; i++ {
+		for j := 0; j < 16; j++ {
+			if inUse[i*16+j] {
+				inUse16 |= 1 << (15 - i)
+				break
+			}
+		}
+	}
+	bw.WriteBits(16, inUse16)
+	for i := 0; i < 16; i++ {
+		if inUse16&(1<<(15-i)) == 0 {
+			continue
+		}
+		var bits uint32
+		for j := 0; j < 16; j++ {
+			if inUse[i*16+j] {
+				bits |= 1 << (15 - j)
+			}
+		}
+		bw.WriteBits(16, bits)
+	}
+
+	z.writeSymbols(mtfv, freqs[:alphaSize])
+}
+
+// writeSymbols chooses Huffman tables for the output of the move-to-front
+// stage, writes them with the selectors that say which table codes each
+// group of 50 symbols, and writes the coded symbols. freqs holds the
+// frequency of each symbol in mtfv.
+func (z *Writer) writeSymbols(mtfv []uint16, freqs []int32) {
+	bw := &z.bw
+	alphaSize := len(freqs)
+	nMTF := len(mtfv)
+
+	var numTables int
+	switch {
+	case nMTF < 200:
+		numTables = 2
+	case nMTF < 600:
+		numTables = 3
+	case nMTF < 1200:
+		numTables = 4
+	case nMTF < 2400:
+		numTables = 5
+	default:
+		numTables = 6
+	}
+
+	// Start with tables that each favor a range of symbols
+	// with a similar share of the total frequency.
+	var lengths [6][258]uint8
+	remaining := nMTF
+	start := 0
+	for part := numTables; part > 0; part-- {
+		target := remaining / part
+		end := start - 1
+		sum := 0
+		for sum < target && end < alphaSize-1 {
+			end++
+			sum += int(freqs[end])
+		}
+		if end > start && part != numTables && part != 1 && (numTables-part)%2 == 1 {
+			sum -= int(freqs[end])
+			end--
+		}
+		for v := 0; v < alphaSize; v++ {
+			if v >= start && v <= end {
+				lengths[part-1][v] = 0
+			} else {
+				lengths[part-1][v] = 15
+			}
+		}
+		start = end + 1
+		remaining -= sum
+	}
+
+	// Refine the tables by coding each group with the table that codes
+	// it best and then rebuilding each table for the groups it codes.
+	numSelectors := (nMTF + groupSize - 1) / groupSize
+	selectors := make([]uint8, numSelectors)
+	for iter := 0; iter < numIterations; iter++ {
+		var groupFreqs [6][258]int32
+		for g := 0; g < numSelectors; g++ {
+			group := mtfv[g*groupSize:]
+			if len(group) > groupSize {
+				group = group[:groupSize]
+			}
+			best, bestCost := 0, -1
+			for t := 0; t < numTables; t++ {
+				cost := 0
+				for _, v := range group {
+					cost += int(lengths[t][v])
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = t, cost
+				}
+			}
+			selectors[g] = uint8(best)
+			for _, v := range group {
+				groupFreqs[best][v]++
+			}
+		}
+		for t := 0; t < numTables; t++ {
+			huffmanCodeLengths(lengths[t][:alphaSize], groupFreqs[t][:alphaSize], maxHuffmanLen)
+		}
+	}
+
+	bw.WriteBits(3, uint32(numTables))
+	bw.WriteBits(15, uint32(numSelectors))
+	mtf := newMTFDecoderWithRange(numTables)
+	for _, s := range selectors {
+		pos := 0
+		for mtf[pos] != s {
+			pos++
+		}
+		mtf.Decode(pos)
+		for ; pos > 0; pos-- {
+			bw.WriteBits(1, 1)
+		}
+		bw.WriteBits(1, 0)
+	}
+
+	// The code lengths are delta encoded.
+	var codes [6][258]uint32
+	for t := 0; t < numTables; t++ {
+		length := lengths[t][0]
+		bw.WriteBits(5, uint32(length))
+		for _, l := range lengths[t][:alphaSize] {
+			for ; length < l; length++ {
+				bw.WriteBits(2, 2)
+			}
+			for ; length > l; length-- {
+				bw.WriteBits(2, 3)
+			}
+			bw.WriteBits(1, 0)
+		}
+		huffmanCodes(codes[t][:alphaSize], lengths[t][:alphaSize])
+	}
+
+	for g, s := range selectors {
+		group := mtfv[g*groupSize:]
+		if len(group) > groupSize {
+			group = group[:groupSize]
+		}
+		for _, v := range group {
+			bw.WriteBits(uint(lengths[s][v]), codes[s][v])
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzip2
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func compress(t testing.TB, data []byte, level int) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriterLevel(&buf, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestWriterReference checks that the Writer produces the same output as
+// the bzip2 reference implementation, which wrote the files in testdata.
+func TestWriterReference(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		input []byte
+	}{
+		{desc: "hello world", input: mustDecodeHex("" +
+			"425a68393141592653594eece83600000251800010400006449080200031064c" +
+			"4101a7a9a580bb9431f8bb9229c28482776741b0",
+		)},
+		{desc: "32B zeros", input: mustDecodeHex("" +
+			"425a6839314159265359b5aa5098000000600040000004200021008283177245" +
+			"385090b5aa5098",
+		)},
+		{desc: "1MiB zeros", input: mustDecodeHex("" +
+			"425a683931415926535938571ce50008084000c0040008200030cc0529a60806" +
+			"c4201e2ee48a70a12070ae39ca",
+		)},
+		{desc: "random data", input: mustLoadFile("testdata/pass-random1.bz2")},
+		{desc: "random data - full symbol range", input: mustLoadFile("testdata/pass-random2.bz2")},
+		{desc: "digits", input: digits},
+		{desc: "newton", input: newton},
+		{desc: "random", input: random},
+	} {
+		data, err := io.ReadAll(NewReader(bytes.NewReader(tt.input)))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.desc, err)
+		}
+		if got := compress(t, data, int(tt.input[3]-'0')); !bytes.Equal(got, tt.input) {
+			t.Errorf("%s: output differs from reference:\ngot  %s\nwant %s", tt.desc, trim(got), trim(tt.input))
+		}
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomData := make([]byte, 150000)
+	rnd.Read(randomData)
+	sawtooth := make([]byte, 1<<20)
+	for i := range sawtooth {
+		sawtooth[i] = byte(i)
+	}
+	var runs []byte
+	for i := 0; len(runs) < 300000; i++ {
+		runs = append(runs, bytes.Repeat([]byte{byte(i % 7)}, i%300)...)
+	}
+	newtonData, err := io.ReadAll(NewReader(bytes.NewReader(newton)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		desc string
+		data []byte
+	}{
+		{"empty", nil},
+		{"one byte", []byte{'x'}},
+		{"short run", []byte("aaaa")},
+		{"long run", bytes.Repeat([]byte{'a'}, 1000)},
+		{"periodic", bytes.Repeat([]byte("abc"), 1000)},
+		{"sawtooth", sawtooth},
+		{"runs", runs},
+		{"random", randomData},
+		{"newton", newtonData},
+	} {
+		for _, level := range []int{BestSpeed, 5, BestCompression} {
+			compressed := compress(t, tt.data, level)
+			got, err := io.ReadAll(NewReader(bytes.NewReader(compressed)))
+			if err != nil {
+				t.Errorf("%s, level %d: %v", tt.desc, level, err)
+				continue
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("%s, level %d: output mismatch:\ngot  %s\nwant %s", tt.desc, level, trim(got), trim(tt.data))
+			}
+		}
+	}
+}
+
+func TestWriterReset(t *testing.T) {
+	data := bytes.Repeat([]byte("hello, world\n"), 10000)
+	var buf1, buf2 bytes.Buffer
+	w := NewWriter(&buf1)
+	w.Write([]byte("discarded"))
+	w.Reset(&buf1)
+	w.Write(data)
+	w.Close()
+	w.Reset(&buf2)
+	w.Write(data)
+	w.Close()
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Error("output after Reset differs")
+	}
+	if got := compress(t, data, DefaultCompression); !bytes.Equal(buf1.Bytes(), got) {
+		t.Error("output after Reset differs from output of a new Writer")
+	}
+	if _, err := w.Write(data); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
+
+func TestWriterInvalidLevel(t *testing.T) {
+	for _, level := range []int{-2, 0, BestCompression + 1} {
+		if _, err := NewWriterLevel(io.Discard, level); err == nil {
+			t.Errorf("NewWriterLevel with level %d succeeded", level)
+		}
+	}
+}
+
+func benchmarkEncode(b *testing.B, compressed []byte) {
+	data, err := io.ReadAll(NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	w := NewWriter(io.Discard)
+	for i := 0; i < b.N; i++ {
+		w.Reset(io.Discard)
+		w.Write(data)
+		w.Close()
+	}
+}
+
+func BenchmarkEncodeDigits(b *testing.B) { benchmarkEncode(b, digits) }
+func BenchmarkEncodeNewton(b *testing.B) { benchmarkEncode(b, newton) }
+func BenchmarkEncodeRand(b *testing.B)   { benchmarkEncode(b, random) }
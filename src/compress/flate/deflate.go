@@ -746,3 +746,18 @@ func (w *Writer) Reset(dst io.Writer) {
 		w.d.reset(dst)
 	}
 }
+
+// ResetDict discards the writer's state and makes it equivalent to
+// the result of NewWriterDict called with dst, w's level and dict.
+// Unlike NewWriterDict, it reuses w's memory, making it cheap to
+// compress many small messages with a preset dictionary.
+func (w *Writer) ResetDict(dst io.Writer, dict []byte) {
+	dw, ok := w.d.w.writer.(*dictWriter)
+	if !ok {
+		dw = new(dictWriter)
+	}
+	dw.w = dst
+	w.d.reset(dw)
+	w.dict = append(w.dict[:0], dict...)
+	w.d.fillWindow(w.dict)
+}
@@ -450,6 +450,41 @@ func TestWriterDict(t *testing.T) {
 	}
 }
 
+func TestWriterResetDict(t *testing.T) {
+	dicts := [][]byte{[]byte("hello world"), nil, []byte("world, hello")}
+	const text = "hello again world"
+	for _, level := range []int{NoCompression, BestSpeed, DefaultCompression, BestCompression, HuffmanOnly} {
+		var b bytes.Buffer
+		w, _ := NewWriter(&b, level)
+		for _, dict := range dicts {
+			b.Reset()
+			w.ResetDict(&b, dict)
+			w.Write([]byte(text))
+			w.Close()
+
+			var want bytes.Buffer
+			w1, _ := NewWriterDict(&want, level, dict)
+			w1.Write([]byte(text))
+			w1.Close()
+			if !bytes.Equal(b.Bytes(), want.Bytes()) {
+				t.Errorf("level %d, dictionary %q: ResetDict wrote %q, want %q", level, dict, b.Bytes(), want.Bytes())
+			}
+
+			// Reset keeps the dictionary.
+			b.Reset()
+			w.Reset(&b)
+			w.Write([]byte(text))
+			w.Close()
+			if !bytes.Equal(b.Bytes(), want.Bytes()) {
+				t.Errorf("level %d, dictionary %q: Reset after ResetDict wrote %q, want %q", level, dict, b.Bytes(), want.Bytes())
+			}
+		}
+		if n := testing.AllocsPerRun(10, func() { w.ResetDict(io.Discard, dicts[0]) }); n > 0 {
+			t.Errorf("level %d: ResetDict allocated %v times", level, n)
+		}
+	}
+}
+
 // See https://golang.org/issue/2508
 func TestRegression2508(t *testing.T) {
 	if testing.Short() {
@@ -0,0 +1,199 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flate
+
+// BuildDict uses the approach of the COVER algorithm by Liao, Petri, Moffat
+// and Wirth, "Effective Construction of Relative Lempel-Ziv Dictionaries":
+// the dictionary is made of the segments of the samples that cover the most
+// frequent short substrings, called dmers here. The frequency of a dmer is
+// the number of samples that contain it.
+
+const (
+	dmerLen    = 6   // length of the substrings counted
+	segmentLen = 128 // length of the segments making up a dictionary
+)
+
+// BuildDict returns a preset dictionary of at most size bytes for compressing
+// data similar to samples, for use with NewWriterDict and NewReaderDict.
+// Dictionaries help most with inputs of a few kilobytes or less, such as
+// messages of a network protocol, that are too short for the compressor to
+// find many repetitions within.
+//
+// The dictionary is made of pieces of the samples that contain the substrings
+// common to the most samples. The most useful pieces come last, where matches
+// are cheapest to encode. Since DEFLATE can only refer to the last 32 KB,
+// size is limited to 32 KB. BuildDict returns nil if the samples have nothing
+// in common.
+func BuildDict(samples [][]byte, size int) []byte {
+	if size > windowSize {
+		size = windowSize
+	}
+	if size <= 0 {
+		return nil
+	}
+
+	// Concatenate the samples and number their dmers. A dmer that
+	// crosses from one sample to the next is numbered -1.
+	var data []byte
+	for _, s := range samples {
+		data = append(data, s...)
+	}
+	if len(data) < dmerLen {
+		return nil
+	}
+	ids := make([]int32, len(data)-dmerLen+1)
+	number := make(map[uint64]int32)
+	var freq []int32
+	var last []int32 // the last sample containing each dmer
+	pos := 0
+	for i, s := range samples {
+		for j := range s {
+			if j+dmerLen > len(s) {
+				if pos < len(ids) {
+					ids[pos] = -1
+				}
+				pos++
+				continue
+			}
+			k := dmerKey(s[j:])
+			id, ok := number[k]
+			if !ok {
+				id = int32(len(freq))
+				number[k] = id
+				freq = append(freq, 0)
+				last = append(last, -1)
+			}
+			if last[id] != int32(i) {
+				last[id] = int32(i)
+				freq[id]++
+			}
+			ids[pos] = id
+			pos++
+		}
+	}
+	if len(samples) > 1 {
+		// A dmer found in a single sample is no help for the others.
+		for id, f := range freq {
+			if f == 1 {
+				freq[id] = 0
+			}
+		}
+	}
+
+	// Divide the data into epochs and pick the best segment of each,
+	// until the dictionary is full or no segment helps.
+	epochs := (size + segmentLen - 1) / segmentLen
+	epochLen := len(data) / epochs
+	if epochLen < segmentLen {
+		epochLen = segmentLen
+	}
+	active := make([]int32, len(freq))
+	var segments [][]byte
+	n := 0
+	for n < size {
+		found := false
+		for start := 0; start < len(ids) && n < size; start += epochLen {
+			end := start + epochLen
+			if end > len(ids) {
+				end = len(ids)
+			}
+			i, j := bestSegment(ids[start:end], freq, active)
+			if i == j {
+				continue
+			}
+			i += start
+			j += start
+			for _, id := range ids[i:j] {
+				if id >= 0 {
+					freq[id] = 0
+				}
+			}
+			seg := data[i : j-1+dmerLen]
+			segments = append(segments, seg)
+			n += len(seg)
+			found = true
+		}
+		if !found {
+			break
+		}
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+
+	// Put the best segments last.
+	dict := make([]byte, 0, n)
+	for i := len(segments) - 1; i >= 0; i-- {
+		dict = append(dict, segments[i]...)
+	}
+	if len(dict) > size {
+		dict = dict[len(dict)-size:]
+	}
+	return dict
+}
+
+// bestSegment returns the range [i, j) of dmer positions in ids of the
+// segment with the highest total frequency of distinct dmers, trimmed of
+// dmers of zero frequency at either end. It returns i == j if there is none.
+// The counts in active must be zero, and are left zero.
+func bestSegment(ids, freq, active []int32) (i, j int) {
+	const window = segmentLen - dmerLen + 1
+	var score, best int32
+	bestStart := 0
+	for k, id := range ids {
+		if id >= 0 {
+			if active[id] == 0 {
+				score += freq[id]
+			}
+			active[id]++
+		}
+		if k >= window {
+			if old := ids[k-window]; old >= 0 {
+				active[old]--
+				if active[old] == 0 {
+					score -= freq[old]
+				}
+			}
+		}
+		if score > best {
+			best = score
+			bestStart = k - window + 1
+		}
+	}
+	// Clear the counts of the last window.
+	from := len(ids) - window
+	if from < 0 {
+		from = 0
+	}
+	for _, id := range ids[from:] {
+		if id >= 0 {
+			active[id] = 0
+		}
+	}
+	if best == 0 {
+		return 0, 0
+	}
+	if bestStart < 0 {
+		bestStart = 0
+	}
+	i, j = bestStart, bestStart+window
+	if j > len(ids) {
+		j = len(ids)
+	}
+	for i < j && (ids[i] < 0 || freq[ids[i]] == 0) {
+		i++
+	}
+	for j > i && (ids[j-1] < 0 || freq[ids[j-1]] == 0) {
+		j--
+	}
+	return i, j
+}
+
+// dmerKey returns the dmer at the start of b as an integer.
+func dmerKey(b []byte) uint64 {
+	_ = b[dmerLen-1]
+	return uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16 |
+		uint64(b[3])<<24 | uint64(b[4])<<32 | uint64(b[5])<<40
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// jsonMessages returns n small JSON messages with the same structure.
+func jsonMessages(rnd *rand.Rand, n int) [][]byte {
+	statuses := []string{"active", "suspended", "pending_verification", "deleted"}
+	var msgs [][]byte
+	for i := 0; i < n; i++ {
+		msg := fmt.Sprintf(`{"id":%d,"user_name":"user%d","email_address":"user%d@example.com",`+
+			`"status":%q,"created_at":"2021-%02d-%02dT%02d:%02d:00Z","preferences":{"newsletter":%t,`+
+			`"language":"en-US","timezone":"America/New_York"},"login_count":%d}`,
+			rnd.Intn(1e6), rnd.Intn(1e4), rnd.Intn(1e4), statuses[rnd.Intn(len(statuses))],
+			1+rnd.Intn(12), 1+rnd.Intn(28), rnd.Intn(24), rnd.Intn(60), rnd.Intn(2) == 0, rnd.Intn(1000))
+		msgs = append(msgs, []byte(msg))
+	}
+	return msgs
+}
+
+func TestBuildDict(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	dict := BuildDict(jsonMessages(rnd, 1000), 4<<10)
+	if len(dict) == 0 || len(dict) > 4<<10 {
+		t.Fatalf("dictionary has %d bytes, want between 1 and %d", len(dict), 4<<10)
+	}
+
+	var plain, withDict int
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, DefaultCompression)
+	wd, _ := NewWriter(&buf, DefaultCompression)
+	for _, msg := range jsonMessages(rnd, 100) {
+		buf.Reset()
+		w.Reset(&buf)
+		w.Write(msg)
+		w.Close()
+		plain += buf.Len()
+
+		buf.Reset()
+		wd.ResetDict(&buf, dict)
+		wd.Write(msg)
+		wd.Close()
+		withDict += buf.Len()
+		got, err := io.ReadAll(NewReaderDict(&buf, dict))
+		if err != nil || !bytes.Equal(got, msg) {
+			t.Fatalf("round trip with dictionary: %q, %v", got, err)
+		}
+	}
+	if withDict > plain/2 {
+		t.Errorf("compressed to %d bytes with the dictionary, %d without", withDict, plain)
+	}
+}
+
+func TestBuildDictSize(t *testing.T) {
+	if dict := BuildDict(nil, 1<<10); dict != nil {
+		t.Errorf("dictionary of no samples has %d bytes", len(dict))
+	}
+	if dict := BuildDict([][]byte{[]byte("abcdefgh"), []byte("ijklmnop")}, 1<<10); dict != nil {
+		t.Errorf("dictionary of unrelated samples has %d bytes", len(dict))
+	}
+	rnd := rand.New(rand.NewSource(1))
+	samples := jsonMessages(rnd, 5000)
+	if dict := BuildDict(samples, 1<<20); len(dict) > windowSize {
+		t.Errorf("dictionary has %d bytes, want at most %d", len(dict), windowSize)
+	}
+	for _, size := range []int{0, 1, 100, 1000} {
+		if dict := BuildDict(samples, size); len(dict) > size {
+			t.Errorf("BuildDict(samples, %d) returned %d bytes", size, len(dict))
+		}
+	}
+}
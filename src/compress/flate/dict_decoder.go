@@ -31,6 +31,8 @@ type dictDecoder struct {
 	wrPos int  // Current output position in buffer
 	rdPos int  // Have emitted hist[:rdPos] already
 	full  bool // Has a full window length been written yet?
+
+	flushed int64 // Total number of bytes emitted by readFlush
 }
 
 // init initializes dictDecoder to have a sliding window dictionary of the given
@@ -63,6 +65,21 @@ func (dd *dictDecoder) histSize() int {
 	return dd.wrPos
 }
 
+// outOffset reports the total number of bytes written to the dictionary,
+// not counting a preset dictionary.
+func (dd *dictDecoder) outOffset() int64 {
+	return dd.flushed + int64(dd.availRead())
+}
+
+// appendHistory appends the historical data in the dictionary,
+// oldest first, to b and returns the result.
+func (dd *dictDecoder) appendHistory(b []byte) []byte {
+	if dd.full {
+		b = append(b, dd.hist[dd.wrPos:]...)
+	}
+	return append(b, dd.hist[:dd.wrPos]...)
+}
+
 // availRead reports the number of bytes that can be flushed by readFlush.
 func (dd *dictDecoder) availRead() int {
 	return dd.wrPos - dd.rdPos
@@ -174,6 +191,7 @@ func (dd *dictDecoder) tryWriteCopy(dist, length int) int {
 func (dd *dictDecoder) readFlush() []byte {
 	toRead := dd.hist[dd.rdPos:dd.wrPos]
 	dd.rdPos = dd.wrPos
+	dd.flushed += int64(len(toRead))
 	if dd.wrPos == len(dd.hist) {
 		dd.wrPos, dd.rdPos = 0, 0
 		dd.full = true
@@ -74,6 +74,32 @@ type Resetter interface {
 	Reset(r io.Reader, dict []byte) error
 }
 
+// A Checkpoint records the state of a decompressor at the start of a
+// DEFLATE block. Decompression can resume from a checkpoint without
+// decompressing the data before it, using NewReaderCheckpoint.
+type Checkpoint struct {
+	// In is the offset in bits of the start of the block
+	// in the compressed input.
+	In int64
+
+	// Out is the offset of the block's data in the decompressed output.
+	Out int64
+
+	// Window holds the decompressed data preceding the block,
+	// up to the 32 KB that the block may refer to.
+	Window []byte
+}
+
+// Checkpointer is implemented by the ReadCloser returned by NewReader,
+// NewReaderDict and NewReaderCheckpoint.
+type Checkpointer interface {
+	// SetCheckpointFunc arranges for fn to be called with a Checkpoint
+	// at the start of each DEFLATE block, before any of its data is
+	// decompressed. The Window of the Checkpoint is only valid during
+	// the call; fn must copy it to retain it. Reset clears the function.
+	SetCheckpointFunc(fn func(Checkpoint))
+}
+
 // The data structure for decoding Huffman tables is based on that of
 // zlib. There is a lookup table of a fixed bit width (huffmanChunkBits),
 // For codes smaller than the table width, there are multiple entries
@@ -296,9 +322,23 @@ type decompressor struct {
 	hl, hd    *huffmanDecoder
 	copyLen   int
 	copyDist  int
+
+	// Checkpoint callback and the buffer for its window,
+	// and the number of bits to skip when resuming.
+	checkpoint func(Checkpoint)
+	window     []byte
+	skip       uint
 }
 
 func (f *decompressor) nextBlock() {
+	if f.checkpoint != nil {
+		f.window = f.dict.appendHistory(f.window[:0])
+		f.checkpoint(Checkpoint{
+			In:     f.roffset*8 - int64(f.nb),
+			Out:    f.dict.outOffset(),
+			Window: f.window,
+		})
+	}
 	for f.nb < 1+2 {
 		if f.err = f.moreBits(); f.err != nil {
 			return
@@ -351,6 +391,25 @@ func (f *decompressor) Read(b []byte) (int, error) {
 	}
 }
 
+// resume discards the bits preceding the block at which
+// a reader returned by NewReaderCheckpoint starts.
+func (f *decompressor) resume() {
+	for f.nb < f.skip {
+		if f.err = f.moreBits(); f.err != nil {
+			return
+		}
+	}
+	f.b >>= f.skip
+	f.nb -= f.skip
+	f.skip = 0
+	f.step = (*decompressor).nextBlock
+	f.nextBlock()
+}
+
+func (f *decompressor) SetCheckpointFunc(fn func(Checkpoint)) {
+	f.checkpoint = fn
+}
+
 func (f *decompressor) Close() error {
 	if f.err == io.EOF {
 		return nil
@@ -780,6 +839,7 @@ func (f *decompressor) Reset(r io.Reader, dict []byte) error {
 		codebits: f.codebits,
 		dict:     f.dict,
 		step:     (*decompressor).nextBlock,
+		window:   f.window,
 	}
 	f.dict.init(maxMatchOffset, dict)
 	return nil
@@ -823,3 +883,21 @@ func NewReaderDict(r io.Reader, dict []byte) io.ReadCloser {
 	f.dict.init(maxMatchOffset, dict)
 	return &f
 }
+
+// NewReaderCheckpoint returns a new ReadCloser that resumes decompression
+// at the checkpoint c, which was recorded while decompressing the same
+// compressed data. The reader r must be positioned at the byte of the
+// compressed data that contains the first bit of the checkpoint's block,
+// that is, at byte c.In/8. Reading from the returned ReadCloser yields
+// the decompressed data from offset c.Out on.
+//
+// Offsets in the checkpoints of the returned ReadCloser are relative to
+// the start of the data from which c was recorded.
+func NewReaderCheckpoint(r io.Reader, c Checkpoint) io.ReadCloser {
+	f := NewReaderDict(r, c.Window).(*decompressor)
+	f.roffset = c.In / 8
+	f.skip = uint(c.In % 8)
+	f.dict.flushed = c.Out
+	f.step = (*decompressor).resume
+	return f
+}
@@ -7,6 +7,7 @@ package flate
 import (
 	"bytes"
 	"io"
+	"os"
 	"strings"
 	"testing"
 )
@@ -95,3 +96,51 @@ func TestResetDict(t *testing.T) {
 		}
 	}
 }
+
+func TestReaderCheckpoint(t *testing.T) {
+	e, err := os.ReadFile("../testdata/e.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := os.ReadFile("../testdata/gettysburg.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(bytes.Repeat(text, 200), e...)
+	for _, level := range []int{NoCompression, BestSpeed, DefaultCompression, HuffmanOnly} {
+		var buf bytes.Buffer
+		w, _ := NewWriter(&buf, level)
+		w.Write(data[:1000])
+		w.Flush()
+		w.Write(data[1000:])
+		w.Close()
+		compressed := buf.Bytes()
+
+		var checkpoints []Checkpoint
+		r := NewReader(bytes.NewReader(compressed))
+		r.(Checkpointer).SetCheckpointFunc(func(c Checkpoint) {
+			c.Window = append([]byte(nil), c.Window...)
+			checkpoints = append(checkpoints, c)
+		})
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			t.Fatal(err)
+		}
+		if len(checkpoints) < 3 {
+			t.Errorf("level %d: got %d checkpoints, want at least 3", level, len(checkpoints))
+		}
+		for _, c := range checkpoints {
+			if len(c.Window) != int(c.Out) && len(c.Window) != maxMatchOffset {
+				t.Errorf("level %d: checkpoint at %d has %d bytes of window", level, c.Out, len(c.Window))
+			}
+			r := NewReaderCheckpoint(bytes.NewReader(compressed[c.In/8:]), c)
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Errorf("level %d: resuming at bit %d: %v", level, c.In, err)
+				continue
+			}
+			if !bytes.Equal(got, data[c.Out:]) {
+				t.Errorf("level %d: resuming at bit %d: wrong data", level, c.In)
+			}
+		}
+	}
+}
@@ -80,6 +80,7 @@ type Reader struct {
 	buf          [512]byte
 	err          error
 	multistream  bool
+	resumed      bool // the member was not read from its start
 }
 
 // NewReader creates a new Reader reading the given reader.
@@ -263,11 +264,12 @@ func (z *Reader) Read(p []byte) (n int, err error) {
 	}
 	digest := le.Uint32(z.buf[:4])
 	size := le.Uint32(z.buf[4:8])
-	if digest != z.digest || size != z.size {
+	if !z.resumed && (digest != z.digest || size != z.size) {
 		z.err = ErrChecksum
 		return n, z.err
 	}
 	z.digest, z.size = 0, 0
+	z.resumed = false
 
 	// File is ok; check if there is another.
 	if !z.multistream {
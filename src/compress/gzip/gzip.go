@@ -36,6 +36,15 @@ type Writer struct {
 	closed      bool
 	buf         [10]byte
 	err         error
+
+	// Concurrent compression; see SetConcurrency.
+	blockSize   int
+	concurrency int
+	block       []byte   // input not yet submitted for compression
+	dict        []byte   // the last 32 KB of submitted input
+	pending     []*block // blocks being compressed, oldest first
+	free        [][]byte // input buffers for reuse
+	idle        []*flate.Writer
 }
 
 // NewWriter returns a new Writer.
@@ -75,9 +84,13 @@ func (z *Writer) init(w io.Writer, level int) {
 		Header: Header{
 			OS: 255, // unknown
 		},
-		w:          w,
-		level:      level,
-		compressor: compressor,
+		w:           w,
+		level:       level,
+		compressor:  compressor,
+		blockSize:   z.blockSize,
+		concurrency: z.concurrency,
+		free:        z.free,
+		idle:        z.idle,
 	}
 }
 
@@ -187,12 +200,15 @@ func (z *Writer) Write(p []byte) (int, error) {
 				return 0, z.err
 			}
 		}
-		if z.compressor == nil {
+		if z.compressor == nil && z.blockSize == 0 {
 			z.compressor, _ = flate.NewWriter(z.w, z.level)
 		}
 	}
 	z.size += uint32(len(p))
 	z.digest = crc32.Update(z.digest, crc32.IEEETable, p)
+	if z.blockSize > 0 {
+		return z.writeBlocks(p)
+	}
 	n, z.err = z.compressor.Write(p)
 	return n, z.err
 }
@@ -218,6 +234,10 @@ func (z *Writer) Flush() error {
 			return z.err
 		}
 	}
+	if z.blockSize > 0 {
+		z.err = z.flushBlocks()
+		return z.err
+	}
 	z.err = z.compressor.Flush()
 	return z.err
 }
@@ -239,7 +259,11 @@ func (z *Writer) Close() error {
 			return z.err
 		}
 	}
-	z.err = z.compressor.Close()
+	if z.blockSize > 0 {
+		z.err = z.closeBlocks()
+	} else {
+		z.err = z.compressor.Close()
+	}
 	if z.err != nil {
 		return z.err
 	}
@@ -8,6 +8,7 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"os"
 	"reflect"
 	"testing"
 	"time"
@@ -273,3 +274,96 @@ func TestLimitedWrite(t *testing.T) {
 		}
 	}
 }
+
+func TestWriterConcurrency(t *testing.T) {
+	e, err := os.ReadFile("../testdata/e.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat(e, 5)
+	for _, level := range []int{NoCompression, BestSpeed, DefaultCompression, HuffmanOnly} {
+		var serial, buf bytes.Buffer
+		w, _ := NewWriterLevel(&serial, level)
+		w.Write(data)
+		w.Close()
+
+		w, _ = NewWriterLevel(&buf, level)
+		if err := w.SetConcurrency(64<<10, 4); err != nil {
+			t.Fatal(err)
+		}
+		// Write in pieces that don't line up with the blocks.
+		for i := 0; i < len(data); i += 10000 {
+			end := i + 10000
+			if end > len(data) {
+				end = len(data)
+			}
+			w.Write(data[i:end])
+			if i == 50000 {
+				if err := w.Flush(); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		size := buf.Len()
+
+		// The output must be a single gzip member.
+		r, err := NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Multistream(false)
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("level %d: round trip changed the data", level)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("level %d: %d bytes follow the gzip member", level, buf.Len())
+		}
+		if size > serial.Len()*11/10 {
+			t.Errorf("level %d: compressed %d bytes to %d, but to %d serially", level, len(data), size, serial.Len())
+		}
+	}
+}
+
+func TestWriterConcurrencyShortWrite(t *testing.T) {
+	// The 10-byte header fits, but writing the first compressed block fails
+	// while the second is submitted. Write must count both blocks.
+	w := NewWriter(&limitedWriter{10})
+	if err := w.SetConcurrency(1000, 1); err != nil {
+		t.Fatal(err)
+	}
+	n, err := w.Write(make([]byte, 10000))
+	if n != 2000 || err != io.ErrShortWrite {
+		t.Errorf("Write = %d, %v, want 2000, %v", n, err, io.ErrShortWrite)
+	}
+}
+
+func TestWriterConcurrencyReset(t *testing.T) {
+	var buf1, buf2 bytes.Buffer
+	w := NewWriter(&buf1)
+	if err := w.SetConcurrency(1000, 2); err != nil {
+		t.Fatal(err)
+	}
+	msg := bytes.Repeat([]byte("hello world "), 1000)
+	w.Write(msg)
+	w.Close()
+	w.Reset(&buf2)
+	w.Write(msg)
+	w.Close()
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Error("output after Reset differs")
+	}
+	if err := w.SetConcurrency(1000, 2); err == nil {
+		t.Error("SetConcurrency after Write succeeded")
+	}
+	if err := NewWriter(io.Discard).SetConcurrency(0, 2); err == nil {
+		t.Error("SetConcurrency with a zero block size succeeded")
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gzip
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+)
+
+var errIndex = errors.New("gzip: invalid index")
+
+// indexMagic starts the binary form of an Index.
+const indexMagic = "gzindex\x01"
+
+// An Index records checkpoints in a gzip file, from which
+// a SeekReader can resume decompression without decompressing
+// the data before them.
+//
+// Each checkpoint holds up to 32 KB of decompressed data,
+// so the span between checkpoints should usually be 1 MB or more.
+type Index struct {
+	size   int64 // size of the decompressed data
+	points []checkpoint
+}
+
+// A checkpoint is the start of a DEFLATE block in a gzip file.
+type checkpoint struct {
+	in     int64  // offset in bits of the block in the gzip file
+	out    int64  // offset of the block's data in the decompressed data
+	window []byte // the decompressed data preceding the block within its member
+}
+
+// NewIndex reads the gzip file from r and returns an index of it with
+// checkpoints about span bytes of decompressed data apart. Like a Reader,
+// it accepts a concatenation of gzip files and verifies their checksums.
+func NewIndex(r io.Reader, span int64) (*Index, error) {
+	cr := new(countingReader)
+	if rr, ok := r.(flate.Reader); ok {
+		cr.r = rr
+	} else {
+		cr.r = bufio.NewReader(r)
+	}
+	x := new(Index)
+	z := new(Reader)
+	for {
+		if err := z.Reset(cr); err != nil {
+			if err == io.EOF {
+				return x, nil
+			}
+			return nil, err
+		}
+		z.Multistream(false)
+		in, out := cr.n*8, x.size
+		z.decompressor.(flate.Checkpointer).SetCheckpointFunc(func(c flate.Checkpoint) {
+			if n := len(x.points); n > 0 && out+c.Out-x.points[n-1].out < span {
+				return
+			}
+			x.points = append(x.points, checkpoint{
+				in:     in + c.In,
+				out:    out + c.Out,
+				window: append([]byte(nil), c.Window...),
+			})
+		})
+		n, err := io.Copy(io.Discard, z)
+		x.size += n
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Size returns the size of the decompressed data of the indexed file.
+func (x *Index) Size() int64 {
+	return x.size
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (x *Index) MarshalBinary() ([]byte, error) {
+	b := []byte(indexMagic)
+	b = appendUvarint(b, uint64(x.size))
+	b = appendUvarint(b, uint64(len(x.points)))
+	var out int64
+	for _, p := range x.points {
+		b = appendUvarint(b, uint64(p.in))
+		b = appendUvarint(b, uint64(p.out-out))
+		b = appendUvarint(b, uint64(len(p.window)))
+		b = append(b, p.window...)
+		out = p.out
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (x *Index) UnmarshalBinary(data []byte) error {
+	if len(data) < len(indexMagic) || string(data[:len(indexMagic)]) != indexMagic {
+		return errIndex
+	}
+	data = data[len(indexMagic):]
+	next := func() uint64 {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			data = nil
+			return math.MaxUint64
+		}
+		data = data[n:]
+		return v
+	}
+	size, n := next(), next()
+	if size > math.MaxInt64 || n > uint64(len(data)) {
+		return errIndex
+	}
+	points := make([]checkpoint, n)
+	var out uint64
+	for i := range points {
+		in, delta, m := next(), next(), next()
+		out += delta
+		if in > math.MaxInt64 || out > size || m > windowSize || m > uint64(len(data)) {
+			return errIndex
+		}
+		if i == 0 && out != 0 {
+			return errIndex
+		}
+		points[i] = checkpoint{
+			in:     int64(in),
+			out:    int64(out),
+			window: append([]byte(nil), data[:m]...),
+		}
+		data = data[m:]
+	}
+	if len(data) > 0 || size > 0 && n == 0 {
+		return errIndex
+	}
+	*x = Index{size: int64(size), points: points}
+	return nil
+}
+
+// A SeekReader is an io.ReadSeeker that reads the decompressed data of an
+// indexed gzip file. Unlike a Reader, it does not verify the checksums of
+// gzip members whose start it skipped.
+type SeekReader struct {
+	r    io.ReaderAt
+	x    *Index
+	off  int64   // offset of the next Read
+	z    *Reader // positioned at zoff, or nil
+	zoff int64
+}
+
+// NewSeekReader returns a SeekReader reading from the gzip file in r,
+// which must be the file that x indexes.
+func NewSeekReader(r io.ReaderAt, x *Index) *SeekReader {
+	return &SeekReader{r: r, x: x}
+}
+
+// Read implements io.Reader.
+func (s *SeekReader) Read(p []byte) (int, error) {
+	if s.off >= s.x.size {
+		return 0, io.EOF
+	}
+	if s.z == nil || s.zoff != s.off {
+		if err := s.position(); err != nil {
+			return 0, err
+		}
+	}
+	if max := s.x.size - s.off; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := s.z.Read(p)
+	s.off += int64(n)
+	s.zoff = s.off
+	if err != nil {
+		s.z = nil
+		if err == io.EOF && s.off < s.x.size {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	}
+	return n, nil
+}
+
+// position prepares s.z to read from s.off, resuming decompression at the
+// last checkpoint before it unless s.z can get there sooner.
+func (s *SeekReader) position() error {
+	points := s.x.points
+	i := sort.Search(len(points), func(i int) bool { return points[i].out > s.off }) - 1
+	if i < 0 {
+		return errIndex
+	}
+	if s.z == nil || s.zoff > s.off || s.zoff < points[i].out {
+		p := points[i]
+		br := bufio.NewReader(io.NewSectionReader(s.r, p.in/8, math.MaxInt64-p.in/8))
+		s.z = &Reader{
+			r:            br,
+			decompressor: flate.NewReaderCheckpoint(br, flate.Checkpoint{In: p.in, Window: p.window}),
+			multistream:  true,
+			resumed:      true,
+		}
+		s.zoff = p.out
+	}
+	n, err := io.CopyN(io.Discard, s.z, s.off-s.zoff)
+	s.zoff += n
+	if err != nil {
+		s.z = nil
+		return noEOF(err)
+	}
+	return nil
+}
+
+// Seek implements io.Seeker.
+func (s *SeekReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.off
+	case io.SeekEnd:
+		offset += s.x.size
+	default:
+		return 0, errors.New("gzip.SeekReader.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("gzip.SeekReader.Seek: negative position")
+	}
+	s.off = offset
+	return offset, nil
+}
+
+// countingReader counts the bytes read from a flate.Reader.
+type countingReader struct {
+	r flate.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gzip
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	e, err := os.ReadFile("../testdata/e.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := os.ReadFile("../testdata/gettysburg.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two gzip members, the second written concurrently.
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write(e)
+	w.Write(bytes.Repeat(text, 100))
+	w.Close()
+	w = NewWriter(&buf)
+	w.SetConcurrency(50<<10, 2)
+	w.Write(e)
+	w.Close()
+	compressed := buf.Bytes()
+	data := append(append(append([]byte(nil), e...), bytes.Repeat(text, 100)...), e...)
+
+	x, err := NewIndex(bytes.NewReader(compressed), 20<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.Size() != int64(len(data)) {
+		t.Errorf("Size() = %d, want %d", x.Size(), len(data))
+	}
+	if len(x.points) < 5 {
+		t.Errorf("index has %d checkpoints, want at least 5", len(x.points))
+	}
+	b, err := x.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x2 Index
+	if err := x2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(b); i++ {
+		if err := new(Index).UnmarshalBinary(b[:i]); err == nil {
+			t.Errorf("unmarshaling %d of %d bytes succeeded", i, len(b))
+		}
+	}
+
+	s := NewSeekReader(bytes.NewReader(compressed), &x2)
+	got, err := io.ReadAll(s)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("reading all: got %d bytes, %v", len(got), err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		off := rnd.Int63n(int64(len(data)) + 10)
+		if _, err := s.Seek(off, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		p := make([]byte, rnd.Intn(100<<10))
+		n, err := io.ReadFull(s, p)
+		want := data[minInt64(off, int64(len(data))):]
+		if len(want) > len(p) {
+			want = want[:len(p)]
+		}
+		if !bytes.Equal(p[:n], want) {
+			t.Fatalf("reading %d bytes at %d: wrong data", len(p), off)
+		}
+		if n < len(p) && err != io.ErrUnexpectedEOF && err != io.EOF {
+			t.Fatalf("reading %d bytes at %d: %v", len(p), off, err)
+		}
+	}
+	if _, err := s.Seek(-1, io.SeekStart); err == nil {
+		t.Error("seeking to a negative position succeeded")
+	}
+}
+
+func TestIndexCorrupt(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write([]byte("hello, world"))
+	w.Close()
+	b := buf.Bytes()
+	b[len(b)-5] ^= 1
+	if _, err := NewIndex(bytes.NewReader(b), 1<<20); err != ErrChecksum {
+		t.Errorf("indexing corrupt file: got %v, want ErrChecksum", err)
+	}
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gzip
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+)
+
+// windowSize is the size of the DEFLATE sliding window.
+// Each block compressed concurrently uses the preceding
+// windowSize bytes of input as its dictionary.
+const windowSize = 32 << 10
+
+// finalBlock is an empty, final DEFLATE block with fixed Huffman codes.
+// It ends a stream whose blocks were compressed concurrently.
+var finalBlock = []byte{0x03, 0x00}
+
+// A block is a piece of the input being compressed concurrently.
+type block struct {
+	in   []byte
+	fw   *flate.Writer
+	out  bytes.Buffer
+	err  error
+	done chan struct{}
+}
+
+// SetConcurrency arranges for z to split its input into blocks of
+// blockSize bytes and to compress up to n blocks concurrently.
+//
+// The output is still a single gzip stream that any gzip reader can
+// decompress. Each block is compressed independently, with the 32 KB of
+// input preceding it as a dictionary, and ends on a byte boundary, so the
+// output is slightly larger than when compressing serially. Blocks of
+// 128 KB or more make the difference negligible.
+//
+// SetConcurrency must be called before the first call to Write, Flush
+// or Close. The setting is kept by Reset.
+func (z *Writer) SetConcurrency(blockSize, n int) error {
+	if z.wroteHeader {
+		return errors.New("gzip: SetConcurrency called after Write")
+	}
+	if blockSize <= 0 || n <= 0 {
+		return errors.New("gzip: invalid concurrency")
+	}
+	z.blockSize = blockSize
+	z.concurrency = n
+	return nil
+}
+
+// writeBlocks adds p to the input and submits each full block.
+// On error it returns the number of bytes of p already added.
+func (z *Writer) writeBlocks(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if z.block == nil {
+			z.block = z.newBuffer()
+		}
+		m := z.blockSize - len(z.block)
+		if m > len(p) {
+			m = len(p)
+		}
+		z.block = append(z.block, p[:m]...)
+		p = p[m:]
+		if len(z.block) == z.blockSize {
+			if z.err = z.submit(); z.err != nil {
+				return n - len(p), z.err
+			}
+		}
+	}
+	return n, nil
+}
+
+// newBuffer returns an empty buffer for a block of input.
+func (z *Writer) newBuffer() []byte {
+	if n := len(z.free); n > 0 {
+		b := z.free[n-1]
+		z.free = z.free[:n-1]
+		return b[:0]
+	}
+	return make([]byte, 0, z.blockSize)
+}
+
+// submit starts compressing the pending input, first writing
+// the oldest blocks if n are already being compressed.
+func (z *Writer) submit() error {
+	for len(z.pending) >= z.concurrency {
+		if err := z.writeOldest(); err != nil {
+			return err
+		}
+	}
+	b := &block{in: z.block, done: make(chan struct{})}
+	if n := len(z.idle); n > 0 {
+		b.fw = z.idle[n-1]
+		z.idle = z.idle[:n-1]
+	}
+	z.block = nil
+	dict := z.dict
+	if len(b.in) >= windowSize {
+		z.dict = append([]byte(nil), b.in[len(b.in)-windowSize:]...)
+	} else {
+		z.dict = append(dict[:len(dict):len(dict)], b.in...)
+		if len(z.dict) > windowSize {
+			z.dict = z.dict[len(z.dict)-windowSize:]
+		}
+	}
+	z.pending = append(z.pending, b)
+	go b.compress(z.level, dict)
+	return nil
+}
+
+// compress compresses the block's input and ends its output with a sync flush.
+func (b *block) compress(level int, dict []byte) {
+	defer close(b.done)
+	if b.fw == nil {
+		fw, err := flate.NewWriterDict(&b.out, level, dict)
+		if err != nil {
+			b.err = err
+			return
+		}
+		b.fw = fw
+	} else {
+		b.fw.ResetDict(&b.out, dict)
+	}
+	if _, err := b.fw.Write(b.in); err != nil {
+		b.err = err
+		return
+	}
+	b.err = b.fw.Flush()
+}
+
+// writeOldest waits for the oldest block to be compressed and writes it.
+func (z *Writer) writeOldest() error {
+	b := z.pending[0]
+	z.pending[0] = nil
+	z.pending = z.pending[1:]
+	<-b.done
+	if b.err != nil {
+		return b.err
+	}
+	z.free = append(z.free, b.in)
+	z.idle = append(z.idle, b.fw)
+	_, err := z.w.Write(b.out.Bytes())
+	return err
+}
+
+// flushBlocks submits the pending input and writes all blocks.
+func (z *Writer) flushBlocks() error {
+	if len(z.block) > 0 {
+		if err := z.submit(); err != nil {
+			return err
+		}
+	}
+	for len(z.pending) > 0 {
+		if err := z.writeOldest(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeBlocks writes all blocks and ends the DEFLATE stream.
+func (z *Writer) closeBlocks() error {
+	if err := z.flushBlocks(); err != nil {
+		return err
+	}
+	_, err := z.w.Write(finalBlock)
+	return err
+}
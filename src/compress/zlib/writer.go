@@ -89,6 +89,23 @@ func (z *Writer) Reset(w io.Writer) {
 	z.wroteHeader = false
 }
 
+// ResetDict is like Reset but also replaces the Writer's dictionary with dict,
+// making z equivalent to the result of NewWriterLevelDict called with w,
+// z's level and dict. It reuses the memory of the underlying compressor.
+func (z *Writer) ResetDict(w io.Writer, dict []byte) {
+	z.w = w
+	z.dict = dict
+	if z.compressor != nil {
+		z.compressor.ResetDict(w, dict)
+	}
+	if z.digest != nil {
+		z.digest.Reset()
+	}
+	z.err = nil
+	z.scratch = [4]byte{}
+	z.wroteHeader = false
+}
+
 // writeHeader writes the ZLIB header.
 func (z *Writer) writeHeader() (err error) {
 	z.wroteHeader = true
@@ -206,6 +206,37 @@ func TestWriterReset(t *testing.T) {
 	}
 }
 
+func TestWriterResetDict(t *testing.T) {
+	const text = "test a reasonable sized string that can be compressed"
+	var buf bytes.Buffer
+	z := NewWriter(&buf)
+	for _, dict := range []string{"compressed string", "", "reasonable"} {
+		var d []byte
+		if dict != "" {
+			d = []byte(dict)
+		}
+		buf.Reset()
+		z.ResetDict(&buf, d)
+		z.Write([]byte(text))
+		z.Close()
+
+		var want bytes.Buffer
+		z1, _ := NewWriterLevelDict(&want, DefaultCompression, d)
+		z1.Write([]byte(text))
+		z1.Close()
+		if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+			t.Errorf("dictionary %q: ResetDict wrote %q, want %q", dict, buf.Bytes(), want.Bytes())
+		}
+		r, err := NewReaderDict(&buf, d)
+		if err != nil {
+			t.Fatalf("dictionary %q: %v", dict, err)
+		}
+		if got, err := io.ReadAll(r); err != nil || string(got) != text {
+			t.Errorf("dictionary %q: read %q, %v", dict, got, err)
+		}
+	}
+}
+
 func TestWriterDictIsUsed(t *testing.T) {
 	var input = []byte("Lorem ipsum dolor sit amet, consectetur adipisicing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua.")
 	var buf bytes.Buffer
@@ -0,0 +1,143 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "math/bits"
+
+// A forwardBitReader reads bits from the start of a byte slice,
+// least significant bit first. It is used for FSE table descriptions.
+type forwardBitReader struct {
+	data []byte
+	off  int // offset in bits
+}
+
+// peek returns the next n bits, padding with zeros past the end.
+func (br *forwardBitReader) peek(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		if b := br.off + i; b>>3 < len(br.data) {
+			v |= uint32(br.data[b>>3]>>(b&7)&1) << i
+		}
+	}
+	return v
+}
+
+func (br *forwardBitReader) skip(n int) { br.off += n }
+
+// bytes reports the number of bytes read, including a partial last byte,
+// and whether they are all present.
+func (br *forwardBitReader) bytes() (int, bool) {
+	n := (br.off + 7) >> 3
+	return n, n <= len(br.data)
+}
+
+// A backwardBitReader reads a bitstream written by a bitWriter,
+// beginning with the last bit written. The stream ends with
+// a 1 bit that marks its end, followed by zero padding.
+//
+// Reading past the start of the stream returns zero bits and
+// is reported by overflow.
+type backwardBitReader struct {
+	data []byte
+	off  int    // bytes in data not yet loaded into bits
+	bits uint64 // loaded bits; the low cnt bits are unread
+	cnt  uint
+	over uint // bits read past the start of the stream
+}
+
+// init prepares to read the stream in data, and reports
+// whether it has a valid end marker.
+func (br *backwardBitReader) init(data []byte) bool {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return false
+	}
+	last := data[len(data)-1]
+	*br = backwardBitReader{
+		data: data,
+		off:  len(data) - 1,
+		bits: uint64(last),
+		cnt:  uint(bits.Len8(last)) - 1,
+	}
+	return true
+}
+
+func (br *backwardBitReader) fill() {
+	for br.cnt <= 56 && br.off > 0 {
+		br.off--
+		br.bits = br.bits<<8 | uint64(br.data[br.off])
+		br.cnt += 8
+	}
+}
+
+// peek returns the next n bits, for n <= 32.
+func (br *backwardBitReader) peek(n uint) uint32 {
+	if br.cnt < n {
+		br.fill()
+		if br.cnt < n {
+			return uint32(br.bits&(1<<br.cnt-1)) << (n - br.cnt)
+		}
+	}
+	return uint32(br.bits>>(br.cnt-n)) & (1<<n - 1)
+}
+
+func (br *backwardBitReader) skip(n uint) {
+	if n > br.cnt {
+		br.over += n - br.cnt
+		br.cnt = 0
+		return
+	}
+	br.cnt -= n
+}
+
+// read returns the next n bits, for n <= 32.
+func (br *backwardBitReader) read(n uint) uint32 {
+	if n == 0 {
+		return 0
+	}
+	v := br.peek(n)
+	br.skip(n)
+	return v
+}
+
+// overflow reports whether bits have been read past the start of the stream.
+func (br *backwardBitReader) overflow() bool { return br.over > 0 }
+
+// done reports whether the stream has been read exactly to its start.
+func (br *backwardBitReader) done() bool {
+	return br.off == 0 && br.cnt == 0 && br.over == 0
+}
+
+// A bitWriter writes bits least significant bit first.
+// A stream closed with a marker bit can be read by a backwardBitReader.
+type bitWriter struct {
+	out  []byte
+	bits uint64
+	n    uint
+}
+
+// add writes the low n bits of v, for n <= 32.
+func (w *bitWriter) add(v uint32, n uint) {
+	w.bits |= uint64(v&(1<<n-1)) << w.n
+	w.n += n
+	for w.n >= 8 {
+		w.out = append(w.out, byte(w.bits))
+		w.bits >>= 8
+		w.n -= 8
+	}
+}
+
+// flush writes any partial byte, padded with zeros.
+func (w *bitWriter) flush() {
+	if w.n > 0 {
+		w.out = append(w.out, byte(w.bits))
+		w.bits, w.n = 0, 0
+	}
+}
+
+// close writes the end marker of a stream to be read backwards.
+func (w *bitWriter) close() {
+	w.add(1, 1)
+	w.flush()
+}
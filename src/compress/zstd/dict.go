@@ -0,0 +1,72 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "errors"
+
+var errDict = errors.New("zstd: invalid dictionary")
+
+// A dictionary primes the compressor and decompressor (section 5).
+type dictionary struct {
+	id      uint32
+	content []byte
+
+	// Entropy tables, present only in dictionaries in the
+	// zstd format, which begin with dictMagic.
+	hasTables bool
+	huff      huffTable
+	ll, of    fseTable
+	ml        fseTable
+	reps      repeatOffsets
+}
+
+// parseDict parses a dictionary. A dictionary that does not begin
+// with dictMagic is raw content, with an ID of zero.
+func parseDict(b []byte) (*dictionary, error) {
+	if len(b) < 8 || le.Uint32(b) != dictMagic {
+		return &dictionary{content: b, reps: initialRepeatOffsets}, nil
+	}
+	d := &dictionary{id: le.Uint32(b[4:]), hasTables: true}
+	if d.id == 0 {
+		return nil, errDict
+	}
+	b = b[8:]
+
+	var err error
+	var n int
+	if d.huff, n, err = readHuffTable(b); err != nil {
+		return nil, errDict
+	}
+	b = b[n:]
+	for _, t := range []struct {
+		table  *fseTable
+		maxSym int
+		maxLog uint8
+	}{
+		{&d.of, maxOFCode, maxOFLog},
+		{&d.ml, maxMLCode, maxMLLog},
+		{&d.ll, maxLLCode, maxLLLog},
+	} {
+		norm, log, n, err := readFSENorm(b, t.maxSym, t.maxLog)
+		if err != nil {
+			return nil, errDict
+		}
+		if *t.table, err = buildFSETable(norm, log); err != nil {
+			return nil, errDict
+		}
+		b = b[n:]
+	}
+	if len(b) < 12 {
+		return nil, errDict
+	}
+	for i := range d.reps {
+		d.reps[i] = le.Uint32(b[4*i:])
+		if d.reps[i] == 0 || int(d.reps[i]) > len(b)-12 {
+			return nil, errDict
+		}
+	}
+	d.content = b[12:]
+	return d, nil
+}
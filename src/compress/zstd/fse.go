@@ -0,0 +1,409 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// Finite State Entropy coding (section 4.1).
+
+var errFSETable = errors.New("invalid FSE table")
+
+// An fseEntry is an entry in an FSE decoding table.
+type fseEntry struct {
+	sym  uint8  // symbol decoded in this state
+	bits uint8  // number of bits to read for the next state
+	base uint16 // base of the next state
+}
+
+// An fseTable is an FSE decoding table with 1<<log entries.
+type fseTable struct {
+	log     uint8
+	entries []fseEntry
+}
+
+// readFSENorm reads an FSE table description from the start of data
+// (section 4.1.1). It returns the normalized counts of the symbols,
+// the accuracy log and the number of bytes read. A count of -1 marks
+// a symbol with a "less than 1" probability.
+func readFSENorm(data []byte, maxSym int, maxLog uint8) (norm []int16, log uint8, n int, err error) {
+	br := forwardBitReader{data: data}
+	log = uint8(br.peek(4)) + 5
+	br.skip(4)
+	if log > maxLog {
+		return nil, 0, 0, errFSETable
+	}
+	remaining := int32(1)<<log + 1
+	threshold := int32(1) << log
+	nbBits := int(log) + 1
+	norm = make([]int16, 0, maxSym+1)
+	for remaining > 1 {
+		if len(norm) > maxSym {
+			return nil, 0, 0, errFSETable
+		}
+		max := 2*threshold - 1 - remaining
+		var v int32
+		if low := int32(br.peek(nbBits - 1)); low < max {
+			v = low
+			br.skip(nbBits - 1)
+		} else {
+			v = int32(br.peek(nbBits))
+			if v >= threshold {
+				v -= max
+			}
+			br.skip(nbBits)
+		}
+		count := v - 1
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		norm = append(norm, int16(count))
+		if count == 0 {
+			// A zero count is followed by the number of
+			// further symbols with zero counts.
+			for {
+				rep := br.peek(2)
+				br.skip(2)
+				for i := uint32(0); i < rep; i++ {
+					norm = append(norm, 0)
+				}
+				if rep != 3 {
+					break
+				}
+			}
+		}
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	n, ok := br.bytes()
+	if remaining != 1 || len(norm) > maxSym+1 || !ok {
+		return nil, 0, 0, errFSETable
+	}
+	return norm, log, n, nil
+}
+
+// tableStep returns the step used to spread the symbols of
+// an FSE table with the given size (section 4.1.1).
+func tableStep(size int) int {
+	return size>>1 + size>>3 + 3
+}
+
+// buildFSETable builds the decoding table with the given normalized counts.
+func buildFSETable(norm []int16, log uint8) (fseTable, error) {
+	size := 1 << log
+	t := fseTable{log: log, entries: make([]fseEntry, size)}
+	next := make([]uint16, len(norm))
+	high := size - 1
+	for s, c := range norm {
+		if c == -1 {
+			t.entries[high].sym = uint8(s)
+			high--
+			next[s] = 1
+		} else {
+			next[s] = uint16(c)
+		}
+	}
+	pos, mask, step := 0, size-1, tableStep(size)
+	for s, c := range norm {
+		for i := 0; i < int(c); i++ {
+			t.entries[pos].sym = uint8(s)
+			pos = (pos + step) & mask
+			for pos > high {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+	if pos != 0 {
+		return fseTable{}, errFSETable
+	}
+	for i := range t.entries {
+		e := &t.entries[i]
+		x := next[e.sym]
+		next[e.sym]++
+		if x == 0 {
+			return fseTable{}, errFSETable
+		}
+		nb := int(log) - (bits.Len16(x) - 1)
+		e.bits = uint8(nb)
+		e.base = uint16(int(x)<<nb - size)
+	}
+	return t, nil
+}
+
+// rleTable returns a decoding table that always decodes sym.
+func rleTable(sym uint8) fseTable {
+	return fseTable{entries: []fseEntry{{sym: sym}}}
+}
+
+func mustBuildFSETable(norm []int16, log uint8) fseTable {
+	t, err := buildFSETable(norm, log)
+	if err != nil {
+		panic("zstd: bad predefined table")
+	}
+	return t
+}
+
+var (
+	llDefaultTable = mustBuildFSETable(llDefaultNorm, llDefaultLog)
+	mlDefaultTable = mustBuildFSETable(mlDefaultNorm, mlDefaultLog)
+	ofDefaultTable = mustBuildFSETable(ofDefaultNorm, ofDefaultLog)
+)
+
+// An fseDecoder is the state of an FSE decoder.
+type fseDecoder struct {
+	t     *fseTable
+	state uint32
+}
+
+func (d *fseDecoder) init(t *fseTable, br *backwardBitReader) {
+	d.t = t
+	d.state = br.read(uint(t.log))
+}
+
+func (d *fseDecoder) symbol() uint8 { return d.t.entries[d.state].sym }
+
+func (d *fseDecoder) update(br *backwardBitReader) {
+	e := &d.t.entries[d.state]
+	d.state = uint32(e.base) + br.read(uint(e.bits))
+}
+
+// Encoding.
+
+// normalizeCounts scales the counts of the symbols, whose sum is total,
+// to a sum of 1<<log, where log is at most maxLog and large enough to
+// give each symbol that occurs a count of at least 1.
+func normalizeCounts(counts []uint32, total uint32, maxLog uint8) (norm []int16, log uint8) {
+	used := 0
+	for _, c := range counts {
+		if c > 0 {
+			used++
+		}
+	}
+	// Use a table somewhat smaller than the input,
+	// within the range the format allows.
+	l := bits.Len32(total) - 2
+	if min := bits.Len(uint(used)) + 1; l < min {
+		l = min
+	}
+	if l < 5 {
+		l = 5
+	}
+	if l > int(maxLog) {
+		l = int(maxLog)
+	}
+	log = uint8(l)
+	size := int32(1) << log
+	norm = make([]int16, len(counts))
+	sum := int32(0)
+	for s, c := range counts {
+		if c == 0 {
+			continue
+		}
+		n := int32((uint64(c)<<log + uint64(total)/2) / uint64(total))
+		if n < 1 {
+			n = 1
+		}
+		norm[s] = int16(n)
+		sum += n
+	}
+	// Correct rounding errors by adjusting the largest counts,
+	// which changes their probabilities the least.
+	for sum != size {
+		best := -1
+		for s, n := range norm {
+			if n > 1 && (best < 0 || n > norm[best]) {
+				best = s
+			}
+		}
+		if best < 0 {
+			// Every symbol has a count of 1, and there is room
+			// for more: give it to the most frequent symbol.
+			for s, c := range counts {
+				if best < 0 || c > counts[best] {
+					best = s
+				}
+			}
+		}
+		if sum > size {
+			norm[best]--
+			sum--
+		} else {
+			d := size - sum
+			norm[best] += int16(d)
+			sum += d
+		}
+	}
+	// Trim zero counts at the end.
+	last := len(norm) - 1
+	for last > 0 && norm[last] == 0 {
+		last--
+	}
+	return norm[:last+1], log
+}
+
+// appendFSENorm appends the description of an FSE table with the given
+// normalized counts to b (section 4.1.1).
+func appendFSENorm(b []byte, norm []int16, log uint8) []byte {
+	w := bitWriter{out: b}
+	w.add(uint32(log-5), 4)
+	remaining := int32(1)<<log + 1
+	threshold := int32(1) << log
+	nbBits := uint(log) + 1
+	prev0 := false
+	for s := 0; s < len(norm) && remaining > 1; {
+		if prev0 {
+			start := s
+			for s < len(norm) && norm[s] == 0 {
+				s++
+			}
+			zeros := s - start
+			for ; zeros >= 3; zeros -= 3 {
+				w.add(3, 2)
+			}
+			w.add(uint32(zeros), 2)
+		}
+		count := int32(norm[s])
+		s++
+		max := 2*threshold - 1 - remaining
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		v := count + 1
+		if v >= threshold {
+			v += max
+		}
+		if v < max {
+			w.add(uint32(v), nbBits-1)
+		} else {
+			w.add(uint32(v), nbBits)
+		}
+		prev0 = count == 0
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	w.flush()
+	return w.out
+}
+
+// An fseEncTable is an FSE encoding table.
+type fseEncTable struct {
+	log    uint8
+	states []uint16
+	syms   []fseSymbolTransform
+}
+
+type fseSymbolTransform struct {
+	deltaBits  uint32 // (max bits out << 16) - min state plus
+	deltaState int32  // offset of the symbol's states in states
+}
+
+// buildFSEEncTable builds the encoding table for the given normalized counts.
+func buildFSEEncTable(norm []int16, log uint8) *fseEncTable {
+	size := 1 << log
+	t := &fseEncTable{
+		log:    log,
+		states: make([]uint16, size),
+		syms:   make([]fseSymbolTransform, len(norm)),
+	}
+
+	// Spread the symbols as the decoder does.
+	spread := make([]uint8, size)
+	cumul := make([]int, len(norm)+1)
+	high := size - 1
+	for s, c := range norm {
+		if c == -1 {
+			cumul[s+1] = cumul[s] + 1
+			spread[high] = uint8(s)
+			high--
+		} else {
+			cumul[s+1] = cumul[s] + int(c)
+		}
+	}
+	pos, mask, step := 0, size-1, tableStep(size)
+	for s, c := range norm {
+		for i := 0; i < int(c); i++ {
+			spread[pos] = uint8(s)
+			pos = (pos + step) & mask
+			for pos > high {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+	for u, s := range spread {
+		t.states[cumul[s]] = uint16(size + u)
+		cumul[s]++
+	}
+
+	total := int32(0)
+	for s, c := range norm {
+		switch c {
+		case 0:
+		case -1, 1:
+			t.syms[s] = fseSymbolTransform{
+				deltaBits:  uint32(log)<<16 - uint32(size),
+				deltaState: total - 1,
+			}
+			total++
+		default:
+			maxBitsOut := uint32(log) - uint32(bits.Len16(uint16(c-1))-1)
+			minStatePlus := uint32(c) << maxBitsOut
+			t.syms[s] = fseSymbolTransform{
+				deltaBits:  maxBitsOut<<16 - minStatePlus,
+				deltaState: total - int32(c),
+			}
+			total += int32(c)
+		}
+	}
+	return t
+}
+
+// An fseEncoder is the state of an FSE encoder.
+// Symbols are encoded in the reverse of the order in which they are decoded.
+// An encoder with a nil table encodes a run of one symbol, using no bits.
+type fseEncoder struct {
+	t     *fseEncTable
+	state uint32
+}
+
+// init sets the initial state to one that encodes sym,
+// which is the last symbol to be decoded, without writing any bits.
+func (e *fseEncoder) init(t *fseEncTable, sym uint8) {
+	e.t = t
+	if t == nil {
+		return
+	}
+	tt := t.syms[sym]
+	nbBitsOut := (tt.deltaBits + 1<<15) >> 16
+	value := nbBitsOut<<16 - tt.deltaBits
+	e.state = uint32(t.states[int32(value>>nbBitsOut)+tt.deltaState])
+}
+
+func (e *fseEncoder) encode(w *bitWriter, sym uint8) {
+	if e.t == nil {
+		return
+	}
+	tt := e.t.syms[sym]
+	nbBitsOut := (e.state + tt.deltaBits) >> 16
+	w.add(e.state, uint(nbBitsOut))
+	e.state = uint32(e.t.states[int32(e.state>>nbBitsOut)+tt.deltaState])
+}
+
+// flush writes the final state, which the decoder reads first.
+func (e *fseEncoder) flush(w *bitWriter) {
+	if e.t == nil {
+		return
+	}
+	w.add(e.state, uint(e.t.log))
+}
@@ -0,0 +1,438 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"errors"
+	"math/bits"
+	"sort"
+)
+
+// Huffman coding of literals (section 4.2).
+
+const (
+	maxHuffBits       = 11 // longest code
+	maxHuffWeightLog  = 6  // largest accuracy log of the weights table
+	maxHuffWeight     = maxHuffBits
+	maxDirectWeights  = 128
+	minHuffLiterals   = 32  // fewer literals are not worth a table
+	maxSingleStreamLn = 255 // larger literals sections use 4 streams
+)
+
+var errHuffTable = errors.New("invalid Huffman table")
+
+// A huffEntry is an entry in a Huffman decoding table.
+type huffEntry struct {
+	sym  uint8
+	bits uint8
+}
+
+// A huffTable is a Huffman decoding table indexed by
+// the next maxBits bits of the stream.
+type huffTable struct {
+	maxBits uint8
+	entries []huffEntry
+}
+
+// readHuffTable reads a Huffman tree description from the start of data
+// (section 4.2.1) and returns the decoding table and the number of bytes read.
+func readHuffTable(data []byte) (huffTable, int, error) {
+	if len(data) == 0 {
+		return huffTable{}, 0, errHuffTable
+	}
+	var weights [256]uint8
+	var nw, n int
+	if hdr := int(data[0]); hdr >= 128 {
+		// Weights are stored directly, four bits each.
+		nw = hdr - 127
+		n = 1 + (nw+1)/2
+		if n > len(data) {
+			return huffTable{}, 0, errHuffTable
+		}
+		for i := 0; i < nw; i++ {
+			b := data[1+i/2]
+			if i%2 == 0 {
+				weights[i] = b >> 4
+			} else {
+				weights[i] = b & 15
+			}
+		}
+	} else {
+		// Weights are compressed with FSE.
+		n = 1 + hdr
+		if n > len(data) {
+			return huffTable{}, 0, errHuffTable
+		}
+		var err error
+		nw, err = readHuffWeights(data[1:n], weights[:255])
+		if err != nil {
+			return huffTable{}, 0, err
+		}
+	}
+
+	// Find the weight of the last symbol, which is implied.
+	var total uint32
+	for _, w := range weights[:nw] {
+		if w > maxHuffWeight {
+			return huffTable{}, 0, errHuffTable
+		}
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	if total == 0 {
+		return huffTable{}, 0, errHuffTable
+	}
+	maxBits := uint8(bits.Len32(total))
+	rest := uint32(1)<<maxBits - total
+	if maxBits > maxHuffBits || rest&(rest-1) != 0 {
+		return huffTable{}, 0, errHuffTable
+	}
+	weights[nw] = uint8(bits.Len32(rest))
+	nw++
+
+	// Fill the table in order of increasing weight,
+	// and of symbol within each weight.
+	var start [maxHuffWeight + 2]uint32
+	for _, w := range weights[:nw] {
+		if w > 0 {
+			start[w+1] += 1 << (w - 1)
+		}
+	}
+	for w := 2; w < len(start); w++ {
+		start[w] += start[w-1]
+	}
+	t := huffTable{maxBits: maxBits, entries: make([]huffEntry, 1<<maxBits)}
+	for s, w := range weights[:nw] {
+		if w == 0 {
+			continue
+		}
+		e := huffEntry{sym: uint8(s), bits: maxBits + 1 - w}
+		pos := start[w]
+		for i := uint32(0); i < 1<<(w-1); i++ {
+			t.entries[pos+i] = e
+		}
+		start[w] = pos + 1<<(w-1)
+	}
+	return t, n, nil
+}
+
+// readHuffWeights decodes FSE compressed Huffman weights from data
+// into weights, returning their number.
+func readHuffWeights(data []byte, weights []uint8) (int, error) {
+	norm, log, n, err := readFSENorm(data, maxHuffWeight, maxHuffWeightLog)
+	if err != nil {
+		return 0, err
+	}
+	t, err := buildFSETable(norm, log)
+	if err != nil {
+		return 0, err
+	}
+	var br backwardBitReader
+	if !br.init(data[n:]) {
+		return 0, errHuffTable
+	}
+	// Two states take turns decoding symbols until the stream is exhausted.
+	var s1, s2 fseDecoder
+	s1.init(&t, &br)
+	s2.init(&t, &br)
+	nw := 0
+	for {
+		if nw+2 > len(weights) {
+			return 0, errHuffTable
+		}
+		weights[nw] = s1.symbol()
+		nw++
+		s1.update(&br)
+		if br.overflow() {
+			weights[nw] = s2.symbol()
+			nw++
+			break
+		}
+		weights[nw] = s2.symbol()
+		nw++
+		s2.update(&br)
+		if br.overflow() {
+			weights[nw] = s1.symbol()
+			nw++
+			break
+		}
+	}
+	return nw, nil
+}
+
+// decodeHuff decodes len(out) literals from the single stream in data.
+func decodeHuff(t *huffTable, data []byte, out []byte) error {
+	var br backwardBitReader
+	if !br.init(data) {
+		return errHuffTable
+	}
+	mb := uint(t.maxBits)
+	for i := range out {
+		e := t.entries[br.peek(mb)]
+		out[i] = e.sym
+		br.skip(uint(e.bits))
+	}
+	if !br.done() {
+		return errHuffTable
+	}
+	return nil
+}
+
+// decodeHuff4 decodes len(out) literals from the four streams in data,
+// which begins with a jump table giving the sizes of the first three.
+func decodeHuff4(t *huffTable, data []byte, out []byte) error {
+	if len(data) < 10 {
+		return errHuffTable
+	}
+	s1 := int(le.Uint16(data[0:]))
+	s2 := int(le.Uint16(data[2:]))
+	s3 := int(le.Uint16(data[4:]))
+	data = data[6:]
+	if s1+s2+s3 > len(data) {
+		return errHuffTable
+	}
+	n := (len(out) + 3) / 4
+	if 3*n > len(out) {
+		return errHuffTable
+	}
+	streams := [4][]byte{data[:s1], data[s1 : s1+s2], data[s1+s2 : s1+s2+s3], data[s1+s2+s3:]}
+	for i, s := range streams {
+		end := (i + 1) * n
+		if i == 3 {
+			end = len(out)
+		}
+		if err := decodeHuff(t, s, out[i*n:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Encoding.
+
+// A huffEncoder holds a Huffman code for literals.
+type huffEncoder struct {
+	maxBits uint8
+	maxSym  int // largest symbol with a code
+	codes   [256]uint16
+	lens    [256]uint8
+}
+
+// build computes a code for symbols with the given counts, of which
+// at least two must be non-zero.
+func (h *huffEncoder) build(counts *[256]uint32) {
+	type node struct {
+		count       uint32
+		sym         int // -1 for an internal node
+		left, right int
+	}
+	var nodes []node
+	for s, c := range counts {
+		if c > 0 {
+			nodes = append(nodes, node{count: c, sym: s})
+			h.maxSym = s
+		}
+	}
+	h.lens = [256]uint8{}
+	leaves := len(nodes)
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].count < nodes[j].count })
+
+	// Build the tree with two queues: the sorted leaves and
+	// the internal nodes, which are created in sorted order.
+	li, ii := 0, leaves
+	pick := func() int {
+		if li < leaves && (ii >= len(nodes) || nodes[li].count <= nodes[ii].count) {
+			li++
+			return li - 1
+		}
+		ii++
+		return ii - 1
+	}
+	for k := 0; k < leaves-1; k++ {
+		a := pick()
+		b := pick()
+		nodes = append(nodes, node{count: nodes[a].count + nodes[b].count, sym: -1, left: a, right: b})
+	}
+	depth := make([]uint8, len(nodes))
+	for i := len(nodes) - 1; i >= leaves; i-- {
+		depth[nodes[i].left] = depth[i] + 1
+		depth[nodes[i].right] = depth[i] + 1
+	}
+	leaf := nodes[:leaves]
+
+	// Limit the lengths of the codes, lengthening the codes of
+	// the least frequent symbols that are not already too long.
+	limit := uint8(maxHuffBits)
+	kraft := uint32(0)
+	for i := range leaf {
+		if depth[i] > limit {
+			depth[i] = limit
+		}
+		kraft += 1 << (limit - depth[i])
+	}
+	for kraft > 1<<limit {
+		for i := range leaf {
+			if depth[i] < limit {
+				kraft -= 1 << (limit - depth[i] - 1)
+				depth[i]++
+				break
+			}
+		}
+	}
+	// Fill any gap by shortening the codes of the most frequent
+	// symbols that fit, so that the code is complete.
+	for kraft < 1<<limit {
+		for i := len(leaf) - 1; i >= 0; i-- {
+			if add := uint32(1) << (limit - depth[i]); depth[i] > 1 && kraft+add <= 1<<limit {
+				kraft += add
+				depth[i]--
+				break
+			}
+		}
+	}
+
+	max := uint8(0)
+	for i, n := range leaf {
+		h.lens[n.sym] = depth[i]
+		if depth[i] > max {
+			max = depth[i]
+		}
+	}
+	h.maxBits = max
+
+	// Assign codes as the decoder fills its table.
+	pos := uint32(0)
+	for w := uint8(1); w <= max; w++ {
+		for s := 0; s <= h.maxSym; s++ {
+			if h.lens[s] != 0 && max+1-h.lens[s] == w {
+				h.codes[s] = uint16(pos >> (w - 1))
+				pos += 1 << (w - 1)
+			}
+		}
+	}
+}
+
+// weight returns the weight of symbol s.
+func (h *huffEncoder) weight(s int) uint8 {
+	if h.lens[s] == 0 {
+		return 0
+	}
+	return h.maxBits + 1 - h.lens[s]
+}
+
+// appendTable appends the description of the code to b (section 4.2.1),
+// or returns nil if the code cannot be described.
+func (h *huffEncoder) appendTable(b []byte) []byte {
+	nw := h.maxSym // the last weight is implied
+	var weights [256]uint8
+	for s := 0; s < nw; s++ {
+		weights[s] = h.weight(s)
+	}
+	var best []byte
+	if nw <= maxDirectWeights {
+		best = append(best, byte(127+nw))
+		for i := 0; i < nw; i += 2 {
+			best = append(best, weights[i]<<4|weights[i+1])
+		}
+	}
+	if c := compressHuffWeights(weights[:nw]); c != nil && (best == nil || len(c) < len(best)) {
+		best = c
+	}
+	if best == nil {
+		return nil
+	}
+	return append(b, best...)
+}
+
+// compressHuffWeights returns the FSE compressed form of weights,
+// preceded by its size, or nil if they cannot be compressed.
+func compressHuffWeights(weights []uint8) []byte {
+	var counts [maxHuffWeight + 1]uint32
+	distinct := 0
+	for _, w := range weights {
+		if counts[w] == 0 {
+			distinct++
+		}
+		counts[w]++
+	}
+	if len(weights) < 3 || distinct < 2 {
+		return nil
+	}
+	norm, log := normalizeCounts(counts[:], uint32(len(weights)), maxHuffWeightLog)
+	b := appendFSENorm([]byte{0}, norm, log)
+	t := buildFSEEncTable(norm, log)
+
+	// The decoder alternates between two states, the first decoding
+	// the weights at even indexes. Encode in reverse order.
+	w := bitWriter{out: b}
+	n := len(weights)
+	var s1, s2 fseEncoder
+	i := n - 2
+	if n%2 == 1 {
+		s1.init(t, weights[n-1])
+		s2.init(t, weights[n-2])
+		s1.encode(&w, weights[n-3])
+		i = n - 3
+	} else {
+		s2.init(t, weights[n-1])
+		s1.init(t, weights[n-2])
+	}
+	for ; i > 0; i -= 2 {
+		s2.encode(&w, weights[i-1])
+		s1.encode(&w, weights[i-2])
+	}
+	s2.flush(&w)
+	s1.flush(&w)
+	w.close()
+	b = w.out
+	if len(b)-1 >= 128 {
+		return nil
+	}
+	b[0] = byte(len(b) - 1)
+
+	// The decoder stops when it runs out of bits, which some
+	// distributions do not ensure. Check that it would stop in time.
+	var check [256]uint8
+	if m, err := readHuffWeights(b[1:], check[:255]); err != nil || m != n || string(check[:m]) != string(weights) {
+		return nil
+	}
+	return b
+}
+
+// appendStream appends the Huffman coded literals as a single stream.
+func (h *huffEncoder) appendStream(b []byte, lits []byte) []byte {
+	w := bitWriter{out: b}
+	for i := len(lits) - 1; i >= 0; i-- {
+		s := lits[i]
+		w.add(uint32(h.codes[s]), uint(h.lens[s]))
+	}
+	w.close()
+	return w.out
+}
+
+// appendStreams appends the literals as four streams, preceded by
+// a jump table, and reports false if a stream is too large.
+func (h *huffEncoder) appendStreams(b []byte, lits []byte) ([]byte, bool) {
+	n := (len(lits) + 3) / 4
+	start := len(b)
+	b = append(b, 0, 0, 0, 0, 0, 0)
+	for i := 0; i < 4; i++ {
+		end := (i + 1) * n
+		if end > len(lits) {
+			end = len(lits)
+		}
+		s := len(b)
+		b = h.appendStream(b, lits[i*n:end])
+		if i < 3 {
+			size := len(b) - s
+			if size > 0xFFFF {
+				return b, false
+			}
+			le.PutUint16(b[start+2*i:], uint16(size))
+		}
+	}
+	return b, true
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "math/bits"
+
+const minMatch = 4
+
+// Parameters of the compression levels.
+var levels = [...]struct {
+	windowLog uint8
+	hashLog   uint8
+	depth     int // candidates to examine; 0 for a single candidate
+	lazy      int // positions to look ahead for a longer match
+}{
+	BestSpeed:       {19, 16, 0, 0},
+	BestSpeed + 1:   {20, 17, 16, 1},
+	BestCompression: {22, 18, 128, 2},
+}
+
+// A matcher finds matches in the history of a Writer.
+// Positions are stored plus one, so that zero means none.
+type matcher struct {
+	hashLog uint8
+	depth   int
+	window  int
+	table   []int32
+	chain   []int32 // previous position with the same hash, indexed by position mod window
+}
+
+func (m *matcher) init(level int) {
+	p := levels[level]
+	m.hashLog = p.hashLog
+	m.depth = p.depth
+	m.window = 1 << p.windowLog
+	if m.table == nil {
+		m.table = make([]int32, 1<<p.hashLog)
+	} else {
+		for i := range m.table {
+			m.table[i] = 0
+		}
+	}
+	m.chain = m.chain[:0]
+}
+
+func load32(b []byte, i int) uint32 {
+	b = b[i : i+4]
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+func (m *matcher) hash(u uint32) uint32 {
+	return (u * 2654435761) >> (32 - m.hashLog)
+}
+
+// insert adds position pos of hist, which must be followed
+// by at least three more bytes, to the tables.
+func (m *matcher) insert(hist []byte, pos int) {
+	h := m.hash(load32(hist, pos))
+	if m.depth > 0 {
+		if pos >= len(m.chain) {
+			// The chain grows with the history until it spans the window.
+			n := 2 * (pos + 1)
+			if n > m.window {
+				n = m.window
+			}
+			m.chain = append(m.chain, make([]int32, n-len(m.chain))...)
+		}
+		m.chain[pos&(m.window-1)] = m.table[h]
+	}
+	m.table[h] = int32(pos + 1)
+}
+
+// find returns the offset and length of the longest match it finds for
+// the data at pos that ends by end, or a length of zero.
+func (m *matcher) find(hist []byte, pos, end int) (off, length int) {
+	min := pos - m.window
+	cand := int(m.table[m.hash(load32(hist, pos))]) - 1
+	for n := 0; cand >= 0 && cand >= min && cand < pos; n++ {
+		if length == 0 || hist[cand+length] == hist[pos+length] {
+			if l := matchLen(hist[cand:], hist[pos:end]); l > length && l >= minMatch {
+				off, length = pos-cand, l
+				if pos+l == end {
+					break
+				}
+			}
+		}
+		if n >= m.depth {
+			break
+		}
+		next := int(m.chain[cand&(m.window-1)]) - 1
+		if next >= cand {
+			break // overwritten by a later position
+		}
+		cand = next
+	}
+	return off, length
+}
+
+// slide adjusts the tables after the first n bytes of the history,
+// a multiple of the window size, have been discarded.
+func (m *matcher) slide(n int) {
+	adjust := func(t []int32) {
+		for i, v := range t {
+			if int(v) > n {
+				t[i] = v - int32(n)
+			} else {
+				t[i] = 0
+			}
+		}
+	}
+	adjust(m.table)
+	adjust(m.chain)
+}
+
+// matchLen returns the length of the common prefix of a and b.
+func matchLen(a, b []byte) int {
+	n := 0
+	for len(a) >= 8 && len(b) >= 8 {
+		if x := le.Uint64(a) ^ le.Uint64(b); x != 0 {
+			return n + bits.TrailingZeros64(x)>>3
+		}
+		a, b, n = a[8:], b[8:], n+8
+	}
+	for i := 0; i < len(a) && i < len(b) && a[i] == b[i]; i++ {
+		n++
+	}
+	return n
+}
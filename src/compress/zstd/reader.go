@@ -0,0 +1,580 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"errors"
+	"io"
+)
+
+// A Reader is an io.Reader that decompresses Zstandard data.
+//
+// The Reader reads exactly the bytes of the compressed frames from its
+// underlying reader, so data that follows them can be read from the
+// underlying reader once Read returns io.EOF.
+//
+// Frames may include a checksum of their contents. The Reader returns
+// ErrChecksum when Read reaches the end of a frame whose contents do
+// not match its checksum. Clients should treat data returned by Read
+// as tentative until they receive the io.EOF marking the end of the data.
+type Reader struct {
+	r    io.Reader
+	roff int64 // bytes read from r
+	err  error // sticky error
+	dict *dictionary
+
+	maxWindow int // largest window size accepted
+
+	// State of the current frame.
+	inFrame  bool
+	window   int   // window size
+	content  int64 // content size, or -1 if unknown
+	produced int64 // bytes of content decoded so far
+	checksum bool
+	hash     xxhash64
+
+	// hist holds the data decoded in the frame that may be referred
+	// to by later matches, preceded by the content of any dictionary.
+	// hist[off:] has not yet been returned by Read.
+	hist []byte
+	off  int
+
+	reps       repeatOffsets
+	huff       huffTable // last Huffman table, for treeless literals
+	ll, of, ml fseTable  // last sequence tables, for repeat mode
+
+	block []byte // compressed block
+	lits  []byte // decoded literals
+	buf   [18]byte
+}
+
+// NewReader returns a new Reader that decompresses from r.
+func NewReader(r io.Reader) *Reader {
+	z := &Reader{maxWindow: maxWindowSize}
+	z.Reset(r)
+	return z
+}
+
+// NewReaderDict is like NewReader but uses the given dictionary.
+// The dictionary is used for frames that name its ID, and for
+// frames that do not name a dictionary. Reading a frame that names
+// a different dictionary returns ErrDictionary.
+func NewReaderDict(r io.Reader, dict []byte) (*Reader, error) {
+	d, err := parseDict(append([]byte(nil), dict...))
+	if err != nil {
+		return nil, err
+	}
+	z := NewReader(r)
+	z.dict = d
+	return z, nil
+}
+
+// Reset discards the Reader z's state and makes it equivalent to the
+// result of its original state from NewReader or NewReaderDict, but
+// reading from r instead. This permits reusing a Reader rather than
+// allocating a new one.
+func (z *Reader) Reset(r io.Reader) {
+	z.r = r
+	z.roff = 0
+	z.err = nil
+	z.inFrame = false
+	z.hist = z.hist[:0]
+	z.off = 0
+}
+
+// SetMaxWindow limits the window size of the frames that z reads to
+// n bytes, which bounds the memory z uses. Reading a frame that requires
+// a larger window returns ErrWindowSize. The default limit, which is also
+// the largest, is 128 MiB. Clients decoding the zstd HTTP content coding
+// should use 8 MiB, the limit set by RFC 9659. Reset does not change
+// the limit.
+func (z *Reader) SetMaxWindow(n int) {
+	if n <= 0 || n > maxWindowSize {
+		n = maxWindowSize
+	}
+	z.maxWindow = n
+}
+
+// Read reads uncompressed data into p.
+func (z *Reader) Read(p []byte) (int, error) {
+	for z.off == len(z.hist) {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.next()
+	}
+	n := copy(p, z.hist[z.off:])
+	z.off += n
+	return n, nil
+}
+
+// next decodes the next block, beginning or ending a frame as needed.
+func (z *Reader) next() error {
+	if !z.inFrame {
+		if err := z.readFrameHeader(); err != nil {
+			return err
+		}
+	}
+	last, err := z.readBlock()
+	if err != nil {
+		return err
+	}
+	if last {
+		z.inFrame = false
+		if z.content >= 0 && z.produced != z.content {
+			return z.corrupt()
+		}
+		if z.checksum {
+			if err := z.readFull(z.buf[:4]); err != nil {
+				return noEOF(err)
+			}
+			if le.Uint32(z.buf[:]) != uint32(z.hash.sum64()) {
+				return ErrChecksum
+			}
+		}
+	}
+	return nil
+}
+
+func (z *Reader) readFull(b []byte) error {
+	n, err := io.ReadFull(z.r, b)
+	z.roff += int64(n)
+	return err
+}
+
+// noEOF converts io.EOF to io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (z *Reader) corrupt() error {
+	return CorruptInputError(z.roff)
+}
+
+// readFrameHeader reads the header of the next frame (section 3.1.1),
+// skipping any skippable frames. It returns io.EOF if there are no
+// more frames.
+func (z *Reader) readFrameHeader() error {
+	for {
+		if err := z.readFull(z.buf[:4]); err != nil {
+			if err == io.EOF {
+				return io.EOF
+			}
+			return noEOF(err)
+		}
+		magic := le.Uint32(z.buf[:])
+		if magic == frameMagic {
+			break
+		}
+		if magic&skippableMask != skippableMagic {
+			return ErrHeader
+		}
+		if err := z.readFull(z.buf[:4]); err != nil {
+			return noEOF(err)
+		}
+		size := int64(le.Uint32(z.buf[:]))
+		n, err := io.CopyN(io.Discard, z.r, size)
+		z.roff += n
+		if err != nil {
+			return noEOF(err)
+		}
+	}
+
+	if err := z.readFull(z.buf[:1]); err != nil {
+		return noEOF(err)
+	}
+	desc := z.buf[0]
+	fcsFlag := desc >> 6
+	single := desc&(1<<5) != 0
+	if desc&(1<<3) != 0 {
+		return ErrHeader // reserved bit
+	}
+	z.checksum = desc&(1<<2) != 0
+	dictIDSize := [4]int{0, 1, 2, 4}[desc&3]
+	fcsSize := [4]int{0, 2, 4, 8}[fcsFlag]
+	if fcsFlag == 0 && single {
+		fcsSize = 1
+	}
+	n := dictIDSize + fcsSize
+	if !single {
+		n++
+	}
+	b := z.buf[:n]
+	if err := z.readFull(b); err != nil {
+		return noEOF(err)
+	}
+
+	window := uint64(0)
+	if !single {
+		exp, mantissa := b[0]>>3, b[0]&7
+		base := uint64(1) << (minWindowLog + exp)
+		window = base + base/8*uint64(mantissa)
+		b = b[1:]
+	}
+	var dictID uint32
+	switch dictIDSize {
+	case 1:
+		dictID = uint32(b[0])
+	case 2:
+		dictID = uint32(le.Uint16(b))
+	case 4:
+		dictID = le.Uint32(b)
+	}
+	b = b[dictIDSize:]
+	z.content = -1
+	switch fcsSize {
+	case 1:
+		z.content = int64(b[0])
+	case 2:
+		z.content = int64(le.Uint16(b)) + 256
+	case 4:
+		z.content = int64(le.Uint32(b))
+	case 8:
+		z.content = int64(le.Uint64(b))
+		if z.content < 0 {
+			return ErrWindowSize
+		}
+	}
+	if single {
+		window = uint64(z.content)
+	}
+	if window > uint64(z.maxWindow) {
+		return ErrWindowSize
+	}
+	z.window = int(window)
+
+	// Start the frame with the dictionary, if any.
+	d := z.dict
+	if dictID != 0 && (d == nil || d.id != dictID) {
+		return ErrDictionary
+	}
+	z.hist = z.hist[:0]
+	z.reps = initialRepeatOffsets
+	z.huff = huffTable{}
+	z.ll, z.of, z.ml = fseTable{}, fseTable{}, fseTable{}
+	if d != nil {
+		z.hist = append(z.hist, d.content...)
+		z.reps = d.reps
+		if d.hasTables {
+			z.huff = d.huff
+			z.ll, z.of, z.ml = d.ll, d.of, d.ml
+		}
+	}
+	z.off = len(z.hist)
+	z.produced = 0
+	z.hash.reset()
+	z.inFrame = true
+	return nil
+}
+
+// trim discards history that can no longer be referred to.
+func (z *Reader) trim() {
+	keep := z.window
+	if z.dict != nil {
+		keep += len(z.dict.content)
+	}
+	if excess := len(z.hist) - keep; excess >= keep && excess >= maxBlockSize {
+		n := copy(z.hist, z.hist[excess:])
+		z.hist = z.hist[:n]
+		z.off = n
+	}
+}
+
+// readBlock reads and decodes a block (section 3.1.1.2)
+// and reports whether it is the last block of the frame.
+func (z *Reader) readBlock() (last bool, err error) {
+	z.trim()
+	if err := z.readFull(z.buf[:3]); err != nil {
+		return false, noEOF(err)
+	}
+	hdr := uint32(z.buf[0]) | uint32(z.buf[1])<<8 | uint32(z.buf[2])<<16
+	last = hdr&1 != 0
+	size := int(hdr >> 3)
+	maxSize := maxBlockSize
+	if z.window < maxSize {
+		maxSize = z.window
+	}
+	start := len(z.hist)
+	switch hdr >> 1 & 3 {
+	case blockRaw:
+		if size > maxSize {
+			return false, z.corrupt()
+		}
+		z.hist = grow(z.hist, size)
+		if err := z.readFull(z.hist[start:]); err != nil {
+			return false, noEOF(err)
+		}
+	case blockRLE:
+		if size > maxSize {
+			return false, z.corrupt()
+		}
+		if err := z.readFull(z.buf[:1]); err != nil {
+			return false, noEOF(err)
+		}
+		z.hist = grow(z.hist, size)
+		for i := start; i < len(z.hist); i++ {
+			z.hist[i] = z.buf[0]
+		}
+	case blockCompressed:
+		if size > maxSize {
+			return false, z.corrupt()
+		}
+		z.block = grow(z.block[:0], size)
+		if err := z.readFull(z.block); err != nil {
+			return false, noEOF(err)
+		}
+		if err := z.decodeBlock(z.block, maxSize); err != nil {
+			return false, z.corrupt()
+		}
+	default:
+		return false, z.corrupt()
+	}
+	z.produced += int64(len(z.hist) - start)
+	if z.checksum {
+		z.hash.write(z.hist[start:])
+	}
+	return last, nil
+}
+
+// grow extends b by n bytes.
+func grow(b []byte, n int) []byte {
+	if len(b)+n > cap(b) {
+		nb := make([]byte, len(b), 2*cap(b)+n)
+		copy(nb, b)
+		b = nb
+	}
+	return b[:len(b)+n]
+}
+
+var errCorrupt = errors.New("corrupt block")
+
+// decodeBlock decodes a compressed block (section 3.1.1.3),
+// which must produce at most maxSize bytes, appending to z.hist.
+func (z *Reader) decodeBlock(data []byte, maxSize int) error {
+	lits, n, err := z.readLiterals(data, maxSize)
+	if err != nil {
+		return err
+	}
+	data = data[n:]
+
+	// Sequences section header (section 3.1.1.3.2.1).
+	if len(data) == 0 {
+		return errCorrupt
+	}
+	nseq := int(data[0])
+	switch {
+	case nseq < 128:
+		data = data[1:]
+	case nseq < 255:
+		if len(data) < 2 {
+			return errCorrupt
+		}
+		nseq = (nseq-128)<<8 + int(data[1])
+		data = data[2:]
+	default:
+		if len(data) < 3 {
+			return errCorrupt
+		}
+		nseq = int(le.Uint16(data[1:])) + 0x7F00
+		data = data[3:]
+	}
+	start := len(z.hist)
+	if nseq == 0 {
+		if len(data) != 0 || len(lits) > maxSize {
+			return errCorrupt
+		}
+		z.hist = append(z.hist, lits...)
+		return nil
+	}
+	if len(data) == 0 {
+		return errCorrupt
+	}
+	modes := data[0]
+	if modes&3 != 0 {
+		return errCorrupt
+	}
+	data = data[1:]
+	for _, t := range []struct {
+		table      *fseTable
+		mode       uint8
+		predefined *fseTable
+		maxSym     int
+		maxLog     uint8
+	}{
+		{&z.ll, modes >> 6, &llDefaultTable, maxLLCode, maxLLLog},
+		{&z.of, modes >> 4 & 3, &ofDefaultTable, maxOFCode, maxOFLog},
+		{&z.ml, modes >> 2 & 3, &mlDefaultTable, maxMLCode, maxMLLog},
+	} {
+		switch t.mode {
+		case modePredefined:
+			*t.table = *t.predefined
+		case modeRLE:
+			if len(data) == 0 || int(data[0]) > t.maxSym {
+				return errCorrupt
+			}
+			*t.table = rleTable(data[0])
+			data = data[1:]
+		case modeFSE:
+			norm, log, n, err := readFSENorm(data, t.maxSym, t.maxLog)
+			if err != nil {
+				return err
+			}
+			if *t.table, err = buildFSETable(norm, log); err != nil {
+				return err
+			}
+			data = data[n:]
+		case modeRepeat:
+			if t.table.entries == nil {
+				return errCorrupt
+			}
+		}
+	}
+
+	var br backwardBitReader
+	if !br.init(data) {
+		return errCorrupt
+	}
+	var ll, of, ml fseDecoder
+	ll.init(&z.ll, &br)
+	of.init(&z.of, &br)
+	ml.init(&z.ml, &br)
+	for i := 0; i < nseq; i++ {
+		ofCode, mlCode, llCode := of.symbol(), ml.symbol(), ll.symbol()
+		if mlCode > maxMLCode || llCode > maxLLCode {
+			return errCorrupt
+		}
+		ofValue := uint32(1)<<ofCode + br.read(uint(ofCode))
+		matchLen := mlBase[mlCode] + br.read(uint(mlExtra[mlCode]))
+		litLen := llBase[llCode] + br.read(uint(llExtra[llCode]))
+		if i < nseq-1 {
+			ll.update(&br)
+			ml.update(&br)
+			of.update(&br)
+		}
+		if br.overflow() {
+			return errCorrupt
+		}
+
+		// Execute the sequence (section 3.1.2.4).
+		off := z.reps.resolve(ofValue, litLen)
+		if int(litLen) > len(lits) || len(z.hist)-start+int(litLen)+int(matchLen) > maxSize {
+			return errCorrupt
+		}
+		z.hist = append(z.hist, lits[:litLen]...)
+		lits = lits[litLen:]
+		if off == 0 || int(off) > len(z.hist) {
+			return errCorrupt
+		}
+		from := len(z.hist) - int(off)
+		for n := int(matchLen); n > 0; {
+			m := n
+			if m > int(off) {
+				m = int(off)
+			}
+			z.hist = append(z.hist, z.hist[from:from+m]...)
+			from += m
+			n -= m
+		}
+	}
+	if !br.done() || len(z.hist)-start+len(lits) > maxSize {
+		return errCorrupt
+	}
+	z.hist = append(z.hist, lits...)
+	return nil
+}
+
+// readLiterals reads the literals section at the start of data
+// (section 3.1.1.3.1) and returns the literals and its size.
+func (z *Reader) readLiterals(data []byte, maxSize int) ([]byte, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errCorrupt
+	}
+	b0 := data[0]
+	kind := b0 & 3
+	sizeFormat := b0 >> 2 & 3
+	if kind == litRaw || kind == litRLE {
+		var size, n int
+		switch sizeFormat {
+		case 0, 2:
+			size, n = int(b0>>3), 1
+		case 1:
+			if len(data) < 2 {
+				return nil, 0, errCorrupt
+			}
+			size, n = int(b0>>4)+int(data[1])<<4, 2
+		case 3:
+			if len(data) < 3 {
+				return nil, 0, errCorrupt
+			}
+			size, n = int(b0>>4)+int(data[1])<<4+int(data[2])<<12, 3
+		}
+		if size > maxSize {
+			return nil, 0, errCorrupt
+		}
+		if kind == litRaw {
+			if len(data) < n+size {
+				return nil, 0, errCorrupt
+			}
+			return data[n : n+size], n + size, nil
+		}
+		if len(data) < n+1 {
+			return nil, 0, errCorrupt
+		}
+		z.lits = grow(z.lits[:0], size)
+		for i := range z.lits {
+			z.lits[i] = data[n]
+		}
+		return z.lits, n + 1, nil
+	}
+
+	// Huffman coded literals.
+	n, sizeBits, streams := 3, uint(10), 4
+	switch sizeFormat {
+	case 0:
+		streams = 1
+	case 2:
+		n, sizeBits = 4, 14
+	case 3:
+		n, sizeBits = 5, 18
+	}
+	if len(data) < n {
+		return nil, 0, errCorrupt
+	}
+	var v uint64
+	for i := n - 1; i >= 0; i-- {
+		v = v<<8 | uint64(data[i])
+	}
+	mask := uint64(1)<<sizeBits - 1
+	regen := int(v >> 4 & mask)
+	size := int(v >> (4 + sizeBits) & mask)
+	if regen > maxSize || len(data) < n+size {
+		return nil, 0, errCorrupt
+	}
+	src := data[n : n+size]
+	if kind == litCompressed {
+		t, tn, err := readHuffTable(src)
+		if err != nil {
+			return nil, 0, err
+		}
+		z.huff = t
+		src = src[tn:]
+	} else if z.huff.entries == nil {
+		return nil, 0, errCorrupt
+	}
+	z.lits = grow(z.lits[:0], regen)
+	var err error
+	if streams == 1 {
+		err = decodeHuff(&z.huff, src, z.lits)
+	} else {
+		err = decodeHuff4(&z.huff, src, z.lits)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return z.lits, n + size, nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// The compressed files in testdata were written by the zstd command,
+// version 1.5, with options -19 and, for sample.json.zst, -D json.dict.
+
+func TestReaderFiles(t *testing.T) {
+	for _, tt := range []struct {
+		name, orig, dict string
+	}{
+		{"testdata/e.txt.zst", "../testdata/e.txt", ""},
+		{"testdata/gettysburg.txt.zst", "../testdata/gettysburg.txt", ""},
+		{"testdata/sample.json.zst", "testdata/sample.json", "testdata/json.dict"},
+	} {
+		data, err := os.ReadFile(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := os.ReadFile(tt.orig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var z *Reader
+		if tt.dict != "" {
+			dict, err := os.ReadFile(tt.dict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if z, err = NewReaderDict(bytes.NewReader(data), dict); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			z = NewReader(bytes.NewReader(data))
+		}
+		got, err := io.ReadAll(z)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: decompressed data differs from %s", tt.name, tt.orig)
+		}
+	}
+}
+
+func TestReaderDictionaryMismatch(t *testing.T) {
+	data, err := os.ReadFile("testdata/sample.json.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(NewReader(bytes.NewReader(data))); err != ErrDictionary {
+		t.Errorf("reading without dictionary: got %v, want ErrDictionary", err)
+	}
+	z, err := NewReaderDict(bytes.NewReader(data), []byte("raw content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(z); err != ErrDictionary {
+		t.Errorf("reading with other dictionary: got %v, want ErrDictionary", err)
+	}
+}
+
+func TestReaderFrames(t *testing.T) {
+	compress := func(s string) []byte {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		io.WriteString(w, s)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	skippable := []byte{0x5A, 0x2A, 0x4D, 0x18, 3, 0, 0, 0, 'a', 'b', 'c'}
+
+	var input []byte
+	input = append(input, skippable...)
+	input = append(input, compress("hello, ")...)
+	input = append(input, skippable...)
+	input = append(input, compress("")...)
+	input = append(input, compress("world")...)
+	input = append(input, skippable...)
+	got, err := io.ReadAll(NewReader(bytes.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello, world" {
+		t.Errorf("got %q, want %q", got, "hello, world")
+	}
+
+	// Data following the frames is an error.
+	got, err = io.ReadAll(NewReader(bytes.NewReader(append(input, "trailer"...))))
+	if string(got) != "hello, world" || err != ErrHeader {
+		t.Errorf("with trailing data: got %q, %v; want %q, ErrHeader", got, err, "hello, world")
+	}
+}
+
+func TestReaderReset(t *testing.T) {
+	data, err := os.ReadFile("testdata/gettysburg.txt.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("../testdata/gettysburg.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := NewReader(strings.NewReader("not zstd data"))
+	if _, err := io.ReadAll(z); err != ErrHeader {
+		t.Errorf("reading invalid data: got %v, want ErrHeader", err)
+	}
+	for i := 0; i < 2; i++ {
+		z.Reset(bytes.NewReader(data))
+		got, err := io.ReadAll(z)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("after Reset: got %d bytes, %v", len(got), err)
+		}
+	}
+}
+
+func TestReaderMaxWindow(t *testing.T) {
+	// A frame with a 16 MiB window and one raw block.
+	frame := []byte{0x28, 0xB5, 0x2F, 0xFD, 0x00, 14 << 3, 5<<3 | 1, 0, 0, 'h', 'e', 'l', 'l', 'o'}
+	z := NewReader(bytes.NewReader(frame))
+	if got, err := io.ReadAll(z); err != nil || string(got) != "hello" {
+		t.Fatalf("got %q, %v; want %q", got, err, "hello")
+	}
+	z.SetMaxWindow(8 << 20)
+	z.Reset(bytes.NewReader(frame))
+	if _, err := io.ReadAll(z); err != ErrWindowSize {
+		t.Errorf("with an 8 MiB limit: got %v, want ErrWindowSize", err)
+	}
+	z.SetMaxWindow(16 << 20)
+	z.Reset(bytes.NewReader(frame))
+	if got, err := io.ReadAll(z); err != nil || string(got) != "hello" {
+		t.Errorf("with a 16 MiB limit: got %q, %v; want %q", got, err, "hello")
+	}
+}
+
+func TestReaderCorrupt(t *testing.T) {
+	data, err := os.ReadFile("testdata/gettysburg.txt.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < len(data); n++ {
+		_, err := io.ReadAll(NewReader(bytes.NewReader(data[:n])))
+		if n == 0 && err != nil {
+			t.Errorf("reading empty input: %v", err)
+		}
+		if n > 0 && err != io.ErrUnexpectedEOF {
+			t.Errorf("reading %d of %d bytes: got %v, want io.ErrUnexpectedEOF", n, len(data), err)
+		}
+	}
+
+	// Changing any byte after the frame header must be detected.
+	var corrupt CorruptInputError
+	for i := 6; i < len(data); i++ {
+		b := append([]byte(nil), data...)
+		b[i] ^= 0x55
+		_, err := io.ReadAll(NewReader(bytes.NewReader(b)))
+		if err == nil {
+			t.Errorf("changing byte %d: no error", i)
+		} else if err != ErrChecksum && err != io.ErrUnexpectedEOF && !errors.As(err, &corrupt) {
+			t.Errorf("changing byte %d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestXXHash64(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+		{"Nobody inspects the spammish repetition", 0xfbcea83c8a378bf1},
+	} {
+		// Write the input in pieces to test buffering.
+		for split := 0; split <= len(tt.in); split++ {
+			var h xxhash64
+			h.reset()
+			h.write([]byte(tt.in[:split]))
+			h.write([]byte(tt.in[split:]))
+			if got := h.sum64(); got != tt.want {
+				t.Errorf("xxhash64(%q) split at %d = %#x, want %#x", tt.in, split, got, tt.want)
+			}
+		}
+	}
+}
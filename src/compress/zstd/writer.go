@@ -0,0 +1,604 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// These constants are the compression levels accepted by NewWriterLevel.
+const (
+	BestSpeed          = 1
+	BestCompression    = 3
+	DefaultCompression = -1
+
+	defaultLevel = 2
+)
+
+var errWriterClosed = errors.New("zstd: write to closed Writer")
+
+// A Writer is an io.WriteCloser.
+// Writes to a Writer are compressed and written to w.
+//
+// The Writer writes a single frame, with a checksum of its contents.
+// The frame does not record the size of its contents, which is not
+// known when the frame begins.
+type Writer struct {
+	w           io.Writer
+	level       int
+	dict        *dictionary
+	err         error
+	wroteHeader bool
+	closed      bool
+	hash        xxhash64
+
+	// hist holds the data that later matches may refer to, preceded
+	// by the content of any dictionary. hist[pos:] has not yet been
+	// compressed.
+	m    matcher
+	hist []byte
+	pos  int
+	reps repeatOffsets
+
+	seqs  []sequence
+	lits  []byte
+	codes [3][]uint8 // codes of the sequences
+	huff  huffEncoder
+	tmp   []byte
+	out   []byte
+}
+
+// A sequence is a run of literals followed by a match (section 3.1.1.3.2).
+type sequence struct {
+	litLen   uint32
+	matchLen uint32
+	ofValue  uint32 // the match offset, encoded using the repeat offsets
+}
+
+// NewWriter returns a new Writer.
+// Writes to the returned writer are compressed and written to w.
+//
+// It is the caller's responsibility to call Close on the Writer when done.
+// Writes may be buffered and not flushed until Close.
+func NewWriter(w io.Writer) *Writer {
+	z, _ := NewWriterLevel(w, DefaultCompression)
+	return z
+}
+
+// NewWriterLevel is like NewWriter but specifies the compression level
+// instead of assuming DefaultCompression.
+//
+// The compression level can be DefaultCompression or any integer value
+// between BestSpeed and BestCompression inclusive.
+// The error returned will be nil if the level is valid.
+func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
+	return NewWriterDict(w, level, nil)
+}
+
+// NewWriterDict is like NewWriterLevel but uses a dictionary.
+// The frame written names the ID of the dictionary, unless it is
+// a dictionary of raw content, which has no ID. The compressed data
+// can only be decompressed by a Reader using the same dictionary.
+func NewWriterDict(w io.Writer, level int, dict []byte) (*Writer, error) {
+	if level == DefaultCompression {
+		level = defaultLevel
+	}
+	if level < BestSpeed || level > BestCompression {
+		return nil, fmt.Errorf("zstd: invalid compression level: %d", level)
+	}
+	var d *dictionary
+	if dict != nil {
+		var err error
+		if d, err = parseDict(append([]byte(nil), dict...)); err != nil {
+			return nil, err
+		}
+	}
+	z := new(Writer)
+	z.init(w, level, d)
+	return z, nil
+}
+
+// Reset discards the Writer z's state and makes it equivalent to the
+// result of its original state from NewWriter, NewWriterLevel or
+// NewWriterDict, but writing to w instead. This permits reusing a
+// Writer rather than allocating a new one.
+func (z *Writer) Reset(w io.Writer) {
+	z.init(w, z.level, z.dict)
+}
+
+func (z *Writer) init(w io.Writer, level int, d *dictionary) {
+	z.w = w
+	z.level = level
+	z.dict = d
+	z.err = nil
+	z.wroteHeader = false
+	z.closed = false
+	z.hash.reset()
+	z.m.init(level)
+	z.hist = z.hist[:0]
+	z.reps = initialRepeatOffsets
+	if d != nil {
+		content := d.content
+		if len(content) > z.m.window {
+			content = content[len(content)-z.m.window:]
+		}
+		z.hist = append(z.hist, content...)
+		z.insert(0, len(z.hist))
+		z.reps = d.reps
+	}
+	z.pos = len(z.hist)
+}
+
+// insert adds the positions from start to end to the match tables.
+func (z *Writer) insert(start, end int) {
+	if end > len(z.hist)-minMatch+1 {
+		end = len(z.hist) - minMatch + 1
+	}
+	for i := start; i < end; i++ {
+		z.m.insert(z.hist, i)
+	}
+}
+
+// Write writes a compressed form of p to the underlying io.Writer.
+// The compressed bytes are not necessarily flushed until the Writer is closed.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errWriterClosed
+	}
+	n := len(p)
+	z.hash.write(p)
+	for len(p) > 0 {
+		// Keep a full block until more data arrives, so that
+		// Close can mark it as the last block.
+		if len(z.hist)-z.pos == maxBlockSize {
+			if err := z.compressBlock(false); err != nil {
+				return 0, err
+			}
+		}
+		if limit := 2 * z.m.window; len(z.hist)+maxBlockSize > limit {
+			// Discard the oldest history.
+			drop := z.m.window
+			copy(z.hist, z.hist[drop:])
+			z.hist = z.hist[:len(z.hist)-drop]
+			z.pos -= drop
+			z.m.slide(drop)
+		}
+		m := maxBlockSize - (len(z.hist) - z.pos)
+		if m > len(p) {
+			m = len(p)
+		}
+		z.hist = append(z.hist, p[:m]...)
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// Flush compresses any pending data and writes it to the underlying writer.
+// It is useful mainly in compressed network protocols, to ensure that
+// a remote reader has enough data to reconstruct a packet.
+// Flush does not return until the data has been written.
+// If the underlying writer returns an error, Flush returns that error.
+func (z *Writer) Flush() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	if len(z.hist) > z.pos {
+		return z.compressBlock(false)
+	}
+	return z.writeHeader()
+}
+
+// Close closes the Writer by flushing any unwritten data to the underlying
+// io.Writer and writing the end of the frame.
+// It does not close the underlying io.Writer.
+func (z *Writer) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+	if err := z.compressBlock(true); err != nil {
+		return err
+	}
+	var sum [4]byte
+	le.PutUint32(sum[:], uint32(z.hash.sum64()))
+	return z.write(sum[:])
+}
+
+func (z *Writer) write(b []byte) error {
+	if z.err == nil {
+		_, z.err = z.w.Write(b)
+	}
+	return z.err
+}
+
+// writeHeader writes the frame header (section 3.1.1.1), if it has not
+// been written.
+func (z *Writer) writeHeader() error {
+	if z.wroteHeader {
+		return z.err
+	}
+	z.wroteHeader = true
+	var hdr [10]byte
+	le.PutUint32(hdr[:], frameMagic)
+	desc := byte(1 << 2) // checksum
+	hdr[5] = (levels[z.level].windowLog - minWindowLog) << 3
+	n := 6
+	if z.dict != nil && z.dict.id != 0 {
+		desc |= 3 // 4-byte dictionary ID
+		le.PutUint32(hdr[6:], z.dict.id)
+		n = 10
+	}
+	hdr[4] = desc
+	return z.write(hdr[:n])
+}
+
+// compressBlock compresses the pending data as a block.
+func (z *Writer) compressBlock(last bool) error {
+	if err := z.writeHeader(); err != nil {
+		return err
+	}
+	start, end := z.pos, len(z.hist)
+	src := z.hist[start:end]
+	z.pos = end
+
+	b := append(z.out[:0], 0, 0, 0) // header, filled in below
+	kind, size := blockCompressed, 0
+	switch {
+	case len(src) == 0:
+		kind = blockRaw
+	case isRun(src):
+		kind, size = blockRLE, len(src)
+		b = append(b, src[0])
+		z.insert(start, end)
+	default:
+		reps := z.reps
+		z.parse(start, end, &reps)
+		b = z.appendLiterals(b)
+		b = z.appendSequences(b)
+		size = len(b) - 3
+		if size >= len(src) {
+			kind, size = blockRaw, len(src)
+			b = append(b[:3], src...)
+		} else {
+			z.reps = reps
+		}
+	}
+	if kind == blockRaw {
+		size = len(src)
+	}
+	hdr := uint32(size)<<3 | uint32(kind)<<1
+	if last {
+		hdr |= 1
+	}
+	b[0], b[1], b[2] = byte(hdr), byte(hdr>>8), byte(hdr>>16)
+	z.out = b
+	return z.write(b)
+}
+
+// isRun reports whether all the bytes of b are the same.
+func isRun(b []byte) bool {
+	for _, c := range b {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// parse finds the sequences that encode the data from start to end,
+// updating reps as they are encoded.
+func (z *Writer) parse(start, end int, reps *repeatOffsets) {
+	z.seqs = z.seqs[:0]
+	z.lits = z.lits[:0]
+	hist, m := z.hist, &z.m
+	lazy := levels[z.level].lazy
+	limit := end - minMatch
+	lit := start
+	for i := start; i <= limit; {
+		var off, ml int
+		// Try the most recent offset, which is cheap to encode.
+		if r := int(reps[0]); i > lit && r <= i && r <= m.window && load32(hist, i) == load32(hist, i-r) {
+			off, ml = r, matchLen(hist[i-r:], hist[i:end])
+		}
+		if o, l := m.find(hist, i, end); l > ml {
+			off, ml = o, l
+		}
+		m.insert(hist, i)
+		if ml < minMatch {
+			if lazy == 0 {
+				// Skip ahead faster through data that does not compress.
+				i += 1 + (i-lit)>>6
+			} else {
+				i++
+			}
+			continue
+		}
+		// Look for a longer match at the following positions.
+		for k := 0; k < lazy && i+1 <= limit; k++ {
+			o, l := m.find(hist, i+1, end)
+			if l <= ml {
+				break
+			}
+			i++
+			m.insert(hist, i)
+			off, ml = o, l
+		}
+		// Extend the match backwards.
+		for i > lit && i-off > 0 && hist[i-1] == hist[i-off-1] {
+			i--
+			ml++
+		}
+
+		litLen := i - lit
+		z.lits = append(z.lits, hist[lit:i]...)
+		z.seqs = append(z.seqs, sequence{
+			litLen:   uint32(litLen),
+			matchLen: uint32(ml),
+			ofValue:  reps.encode(uint32(off), uint32(litLen)),
+		})
+		next := i + ml
+		if lazy == 0 {
+			for _, j := range [2]int{i + 1, next - 2} {
+				if j <= limit {
+					m.insert(hist, j)
+				}
+			}
+		} else {
+			for j := i + 1; j < next && j <= limit; j++ {
+				m.insert(hist, j)
+			}
+		}
+		i, lit = next, next
+	}
+	z.lits = append(z.lits, hist[lit:end]...)
+}
+
+// encode returns the offset value for a match at offset off after litLen
+// literals, using a repeat offset if possible, and updates r to match.
+func (r *repeatOffsets) encode(off, litLen uint32) uint32 {
+	v := off + 3
+	if litLen > 0 {
+		switch off {
+		case r[0]:
+			v = 1
+		case r[1]:
+			v = 2
+		case r[2]:
+			v = 3
+		}
+	} else {
+		switch off {
+		case r[1]:
+			v = 1
+		case r[2]:
+			v = 2
+		case r[0] - 1:
+			v = 3
+		}
+	}
+	r.resolve(v, litLen)
+	return v
+}
+
+// appendLiterals appends the literals section for z.lits to b
+// (section 3.1.1.3.1).
+func (z *Writer) appendLiterals(b []byte) []byte {
+	lits := z.lits
+	n := len(lits)
+	if n >= minHuffLiterals {
+		var counts [256]uint32
+		for _, c := range lits {
+			counts[c]++
+		}
+		if counts[lits[0]] == uint32(n) {
+			b = appendLiteralsHeader(b, litRLE, n)
+			return append(b, lits[0])
+		}
+		z.huff.build(&counts)
+		if payload := z.huff.appendTable(z.tmp[:0]); payload != nil {
+			ok := true
+			single := n <= maxSingleStreamLn
+			if single {
+				payload = z.huff.appendStream(payload, lits)
+			} else {
+				payload, ok = z.huff.appendStreams(payload, lits)
+			}
+			z.tmp = payload
+			size := len(payload)
+			sizeFormat, hdrLen, sizeBits := 0, 3, uint(10)
+			if !single {
+				switch max := maxInt(n, size); {
+				case max < 1<<10:
+					sizeFormat = 1
+				case max < 1<<14:
+					sizeFormat, hdrLen, sizeBits = 2, 4, 14
+				default:
+					sizeFormat, hdrLen, sizeBits = 3, 5, 18
+				}
+			}
+			if ok && size < 1<<sizeBits && hdrLen+size < n {
+				v := uint64(litCompressed) | uint64(sizeFormat)<<2 | uint64(n)<<4 | uint64(size)<<(4+sizeBits)
+				for i := 0; i < hdrLen; i++ {
+					b = append(b, byte(v>>(8*i)))
+				}
+				return append(b, payload...)
+			}
+		}
+	}
+	b = appendLiteralsHeader(b, litRaw, n)
+	return append(b, lits...)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// appendLiteralsHeader appends the header of a raw or RLE literals section.
+func appendLiteralsHeader(b []byte, kind uint8, n int) []byte {
+	switch {
+	case n < 1<<5:
+		return append(b, kind|byte(n)<<3)
+	case n < 1<<12:
+		return append(b, kind|1<<2|byte(n)<<4, byte(n>>4))
+	default:
+		return append(b, kind|3<<2|byte(n)<<4, byte(n>>4), byte(n>>12))
+	}
+}
+
+// Predefined encoding tables.
+var (
+	llDefaultEnc = buildFSEEncTable(llDefaultNorm, llDefaultLog)
+	mlDefaultEnc = buildFSEEncTable(mlDefaultNorm, mlDefaultLog)
+	ofDefaultEnc = buildFSEEncTable(ofDefaultNorm, ofDefaultLog)
+)
+
+// llCode returns the literals length code for n.
+func llCode(n uint32) uint8 {
+	if n < 16 {
+		return uint8(n)
+	}
+	c := uint8(maxLLCode)
+	for llBase[c] > n {
+		c--
+	}
+	return c
+}
+
+// mlCode returns the match length code for n.
+func mlCode(n uint32) uint8 {
+	if n < 35 {
+		return uint8(n - 3)
+	}
+	c := uint8(maxMLCode)
+	for mlBase[c] > n {
+		c--
+	}
+	return c
+}
+
+// appendSequences appends the sequences section for z.seqs to b
+// (section 3.1.1.3.2).
+func (z *Writer) appendSequences(b []byte) []byte {
+	seqs := z.seqs
+	n := len(seqs)
+	switch {
+	case n < 128:
+		b = append(b, byte(n))
+	case n < 0x7F00:
+		b = append(b, byte(n>>8+128), byte(n))
+	default:
+		b = append(b, 255, byte(n-0x7F00), byte((n-0x7F00)>>8))
+	}
+	if n == 0 {
+		return b
+	}
+
+	ll, of, ml := z.codes[0][:0], z.codes[1][:0], z.codes[2][:0]
+	for _, s := range seqs {
+		ll = append(ll, llCode(s.litLen))
+		of = append(of, uint8(bits.Len32(s.ofValue)-1))
+		ml = append(ml, mlCode(s.matchLen))
+	}
+	z.codes = [3][]uint8{ll, of, ml}
+
+	var enc [3]fseEncoder
+	modes := len(b)
+	b = append(b, 0)
+	for i, t := range []struct {
+		codes  []uint8
+		maxLog uint8
+		norm   []int16
+		log    uint8
+		enc    *fseEncTable
+	}{
+		{ll, maxLLLog, llDefaultNorm, llDefaultLog, llDefaultEnc},
+		{of, maxOFLog, ofDefaultNorm, ofDefaultLog, ofDefaultEnc},
+		{ml, maxMLLog, mlDefaultNorm, mlDefaultLog, mlDefaultEnc},
+	} {
+		var mode uint8
+		mode, enc[i].t, b = chooseTable(b, t.codes, t.maxLog, t.norm, t.log, t.enc)
+		b[modes] |= mode << (6 - 2*i)
+	}
+
+	// Encode the sequences in reverse, so that they decode in order.
+	llEnc, ofEnc, mlEnc := &enc[0], &enc[1], &enc[2]
+	w := bitWriter{out: b}
+	last := n - 1
+	mlEnc.init(mlEnc.t, ml[last])
+	ofEnc.init(ofEnc.t, of[last])
+	llEnc.init(llEnc.t, ll[last])
+	for i := last; i >= 0; i-- {
+		if i < last {
+			ofEnc.encode(&w, of[i])
+			mlEnc.encode(&w, ml[i])
+			llEnc.encode(&w, ll[i])
+		}
+		s := &seqs[i]
+		w.add(s.litLen-llBase[ll[i]], uint(llExtra[ll[i]]))
+		w.add(s.matchLen-mlBase[ml[i]], uint(mlExtra[ml[i]]))
+		w.add(s.ofValue, uint(of[i]))
+	}
+	mlEnc.flush(&w)
+	ofEnc.flush(&w)
+	llEnc.flush(&w)
+	w.close()
+	return w.out
+}
+
+// chooseTable chooses how to encode codes: with the predefined table,
+// as a run of one code, or with a table described in the block. It appends
+// any table description to b and returns the mode and the encoding table,
+// which is nil for a run.
+func chooseTable(b []byte, codes []uint8, maxLog uint8, defNorm []int16, defLog uint8, defEnc *fseEncTable) (uint8, *fseEncTable, []byte) {
+	var counts [maxMLCode + 1]uint32
+	max := 0
+	for _, c := range codes {
+		counts[c]++
+		if int(c) > max {
+			max = int(c)
+		}
+	}
+	if counts[codes[0]] == uint32(len(codes)) {
+		return modeRLE, nil, append(b, codes[0])
+	}
+
+	// Estimate the sizes of the codes in bits using each table.
+	cost := func(norm []int16, log uint8) float64 {
+		bits := 0.0
+		for s, c := range counts[:max+1] {
+			if c > 0 {
+				p := float64(norm[s])
+				if p < 0 {
+					p = 1
+				}
+				bits += float64(c) * (float64(log) - math.Log2(p))
+			}
+		}
+		return bits
+	}
+	norm, log := normalizeCounts(counts[:max+1], uint32(len(codes)), maxLog)
+	desc := appendFSENorm(b, norm, log)
+	custom := cost(norm, log) + 8*float64(len(desc)-len(b))
+	if max < len(defNorm) && cost(defNorm, defLog) <= custom {
+		return modePredefined, defEnc, b
+	}
+	return modeFSE, buildFSEEncTable(norm, log), desc
+}
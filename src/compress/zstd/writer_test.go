@@ -0,0 +1,215 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// testInputs returns inputs of various kinds for round trip tests.
+func testInputs(t testing.TB) map[string][]byte {
+	e, err := os.ReadFile("../testdata/e.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := os.ReadFile("../testdata/gettysburg.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 300<<10)
+	rnd.Read(random)
+	// Random data repeated at a distance of more than a block.
+	repeated := append(random[:200<<10:200<<10], random[:200<<10]...)
+	// Text with matches at many distances.
+	var mixed []byte
+	for len(mixed) < 1<<20 {
+		if n := len(mixed); n > 0 && rnd.Intn(3) > 0 {
+			start := rnd.Intn(n)
+			end := start + rnd.Intn(100)
+			if end > n {
+				end = n
+			}
+			mixed = append(mixed, mixed[start:end]...)
+		} else {
+			i := rnd.Intn(len(text) - 20)
+			mixed = append(mixed, text[i:i+rnd.Intn(20)]...)
+		}
+	}
+	return map[string][]byte{
+		"empty":    {},
+		"byte":     {'x'},
+		"short":    []byte("hello, world\n"),
+		"zeros":    make([]byte, 300<<10),
+		"e":        e,
+		"text":     bytes.Repeat(text, 50),
+		"random":   random,
+		"repeated": repeated,
+		"mixed":    mixed,
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	for name, data := range testInputs(t) {
+		for _, level := range []int{BestSpeed, DefaultCompression, BestCompression} {
+			var buf bytes.Buffer
+			w, err := NewWriterLevel(&buf, level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(data); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			size := buf.Len()
+			got, err := io.ReadAll(NewReader(&buf))
+			if err != nil {
+				t.Errorf("%s, level %d: %v", name, level, err)
+				continue
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("%s, level %d: round trip changed the data", name, level)
+			}
+			if name == "zeros" || name == "text" || name == "repeated" {
+				if size > len(data)*2/3 {
+					t.Errorf("%s, level %d: compressed %d bytes to %d", name, level, len(data), size)
+				}
+			}
+		}
+	}
+}
+
+func TestWriterInvalidLevel(t *testing.T) {
+	for _, level := range []int{-2, 0, BestCompression + 1} {
+		if _, err := NewWriterLevel(io.Discard, level); err == nil {
+			t.Errorf("NewWriterLevel with level %d succeeded", level)
+		}
+	}
+}
+
+func TestWriterFlush(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	r := NewReader(&buf)
+	for _, s := range []string{"hello, ", "", "hello, world", "!"} {
+		io.WriteString(w, s)
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		// Everything written so far must be readable.
+		got := make([]byte, len(s))
+		if _, err := io.ReadFull(r, got); err != nil || string(got) != s {
+			t.Fatalf("after Flush, read %q, %v; want %q", got, err, s)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("after Close, Read = %d, %v; want EOF", n, err)
+	}
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
+
+func TestWriterReset(t *testing.T) {
+	data := testInputs(t)["mixed"]
+	var buf1, buf2 bytes.Buffer
+	w, err := NewWriterLevel(&buf1, BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data[:1000])
+	w.Reset(&buf1)
+	w.Write(data)
+	w.Close()
+	w.Reset(&buf2)
+	w.Write(data)
+	w.Close()
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Error("output after Reset differs")
+	}
+	got, err := io.ReadAll(NewReader(&buf1))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("reading output after Reset: %v", err)
+	}
+}
+
+func TestWriterDict(t *testing.T) {
+	dict, err := os.ReadFile("testdata/json.dict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sample, err := os.ReadFile("testdata/sample.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range [][]byte{dict, sample} {
+		var buf bytes.Buffer
+		w, err := NewWriterDict(&buf, DefaultCompression, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(sample)
+		w.Close()
+		if buf.Len() >= 40 {
+			t.Errorf("compressed %d bytes with dictionary to %d", len(sample), buf.Len())
+		}
+		compressed := buf.Bytes()
+		r, err := NewReaderDict(bytes.NewReader(compressed), d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, sample) {
+			t.Errorf("reading with dictionary: %v", err)
+		}
+	}
+}
+
+func BenchmarkWriter(b *testing.B) {
+	data := testInputs(b)["mixed"]
+	for _, level := range []int{BestSpeed, DefaultCompression, BestCompression} {
+		b.Run(fmt.Sprint("level", levelIndex(level)), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			w, _ := NewWriterLevel(io.Discard, level)
+			for i := 0; i < b.N; i++ {
+				w.Reset(io.Discard)
+				w.Write(data)
+				w.Close()
+			}
+		})
+	}
+}
+
+func BenchmarkReader(b *testing.B) {
+	data := testInputs(b)["mixed"]
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	compressed := buf.Bytes()
+	b.SetBytes(int64(len(data)))
+	r := NewReader(nil)
+	for i := 0; i < b.N; i++ {
+		r.Reset(bytes.NewReader(compressed))
+		io.Copy(io.Discard, r)
+	}
+}
+
+func levelIndex(level int) int {
+	if level == DefaultCompression {
+		return defaultLevel
+	}
+	return level
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "math/bits"
+
+// XXH64 with a seed of zero, used for the frame checksums
+// (section 3.1.1). See https://github.com/Cyan4973/xxHash.
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+type xxhash64 struct {
+	v     [4]uint64
+	total uint64
+	buf   [32]byte
+	n     int // bytes in buf
+}
+
+func (h *xxhash64) reset() {
+	p1 := xxPrime1 // as a variable, to wrap around
+	h.v = [4]uint64{p1 + xxPrime2, xxPrime2, 0, -p1}
+	h.total = 0
+	h.n = 0
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMerge(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*xxPrime1 + xxPrime4
+}
+
+func (h *xxhash64) write(b []byte) {
+	h.total += uint64(len(b))
+	if h.n > 0 {
+		m := copy(h.buf[h.n:], b)
+		h.n += m
+		b = b[m:]
+		if h.n < len(h.buf) {
+			return
+		}
+		h.blocks(h.buf[:])
+		h.n = 0
+	}
+	if len(b) >= 32 {
+		m := len(b) &^ 31
+		h.blocks(b[:m])
+		b = b[m:]
+	}
+	h.n = copy(h.buf[:], b)
+}
+
+// blocks processes b, whose length is a multiple of 32.
+func (h *xxhash64) blocks(b []byte) {
+	v0, v1, v2, v3 := h.v[0], h.v[1], h.v[2], h.v[3]
+	for ; len(b) >= 32; b = b[32:] {
+		v0 = xxRound(v0, le.Uint64(b[0:]))
+		v1 = xxRound(v1, le.Uint64(b[8:]))
+		v2 = xxRound(v2, le.Uint64(b[16:]))
+		v3 = xxRound(v3, le.Uint64(b[24:]))
+	}
+	h.v = [4]uint64{v0, v1, v2, v3}
+}
+
+func (h *xxhash64) sum64() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		v := h.v
+		acc = bits.RotateLeft64(v[0], 1) + bits.RotateLeft64(v[1], 7) +
+			bits.RotateLeft64(v[2], 12) + bits.RotateLeft64(v[3], 18)
+		for _, x := range v {
+			acc = xxMerge(acc, x)
+		}
+	} else {
+		acc = xxPrime5
+	}
+	acc += h.total
+
+	b := h.buf[:h.n]
+	for ; len(b) >= 8; b = b[8:] {
+		acc ^= xxRound(0, le.Uint64(b))
+		acc = bits.RotateLeft64(acc, 27)*xxPrime1 + xxPrime4
+	}
+	if len(b) >= 4 {
+		acc ^= uint64(le.Uint32(b)) * xxPrime1
+		acc = bits.RotateLeft64(acc, 23)*xxPrime2 + xxPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		acc ^= uint64(c) * xxPrime5
+		acc = bits.RotateLeft64(acc, 11) * xxPrime1
+	}
+
+	acc ^= acc >> 33
+	acc *= xxPrime2
+	acc ^= acc >> 29
+	acc *= xxPrime3
+	acc ^= acc >> 32
+	return acc
+}
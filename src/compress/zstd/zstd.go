@@ -0,0 +1,188 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zstd implements reading and writing of Zstandard compressed data,
+// as specified in RFC 8878.
+//
+// A stream of Zstandard data is a sequence of frames. The Reader
+// decompresses all the frames in a stream, skipping skippable frames,
+// and returns the concatenation of their contents. The Writer writes
+// a single frame, with a checksum of its contents.
+//
+// Compressed data may refer to a dictionary that was used to prime the
+// compressor. Dictionaries are either in the format produced by
+// "zstd --train", which begins with a magic number and an ID and
+// includes entropy tables, or raw content with no particular format.
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+const (
+	frameMagic     = 0xFD2FB528
+	skippableMagic = 0x184D2A50 // low four bits are free
+	skippableMask  = 0xFFFFFFF0
+	dictMagic      = 0xEC30A437
+
+	maxBlockSize = 128 << 10
+
+	minWindowLog = 10
+	maxWindowLog = 31
+
+	// maxWindowSize is the largest window the Reader supports.
+	// It is the largest window used by the zstd command with
+	// default settings and by its --long option.
+	maxWindowSize = 1 << 27
+)
+
+// Kinds of blocks (section 3.1.1.2).
+const (
+	blockRaw        = 0
+	blockRLE        = 1
+	blockCompressed = 2
+)
+
+// Kinds of literals sections (section 3.1.1.3.1).
+const (
+	litRaw        = 0
+	litRLE        = 1
+	litCompressed = 2
+	litTreeless   = 3
+)
+
+// Compression modes of the sequence tables (section 3.1.1.3.2.1).
+const (
+	modePredefined = 0
+	modeRLE        = 1
+	modeFSE        = 2
+	modeRepeat     = 3
+)
+
+var (
+	// ErrChecksum is returned when reading Zstandard data that has an invalid checksum.
+	ErrChecksum = errors.New("zstd: invalid checksum")
+	// ErrHeader is returned when reading Zstandard data that has an invalid frame header.
+	ErrHeader = errors.New("zstd: invalid header")
+	// ErrDictionary is returned when reading a frame that was compressed
+	// with a dictionary other than the one given to the Reader.
+	ErrDictionary = errors.New("zstd: frame requires a different dictionary")
+	// ErrWindowSize is returned when reading a frame that requires
+	// more memory than the Reader supports.
+	ErrWindowSize = errors.New("zstd: window size too large")
+)
+
+// A CorruptInputError reports the presence of corrupt input
+// in the block that ends at the given offset.
+type CorruptInputError int64
+
+func (e CorruptInputError) Error() string {
+	return "zstd: corrupt input before offset " + strconv.FormatInt(int64(e), 10)
+}
+
+var le = binary.LittleEndian
+
+// Baselines and numbers of extra bits of the literals length codes
+// (section 3.1.1.3.2.1.1).
+var (
+	llBase = [36]uint32{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536,
+	}
+	llExtra = [36]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+)
+
+// Baselines and numbers of extra bits of the match length codes
+// (section 3.1.1.3.2.1.1).
+var (
+	mlBase = [53]uint32{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539,
+	}
+	mlExtra = [53]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16,
+	}
+)
+
+const (
+	maxLLCode = 35
+	maxMLCode = 52
+	maxOFCode = 31
+
+	maxLLLog = 9
+	maxMLLog = 9
+	maxOFLog = 8
+)
+
+// Default distributions of the sequence codes (section 3.1.1.3.2.2).
+var (
+	llDefaultNorm = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	mlDefaultNorm = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+	ofDefaultNorm = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+)
+
+const (
+	llDefaultLog = 6
+	mlDefaultLog = 6
+	ofDefaultLog = 5
+)
+
+// repeatOffsets holds the three most recent offsets (section 3.1.2.5).
+type repeatOffsets [3]uint32
+
+var initialRepeatOffsets = repeatOffsets{1, 4, 8}
+
+// resolve returns the offset of a match given the offset value from a
+// sequence and the literals length of the sequence, and updates r.
+// It returns 0 if the offset is invalid.
+func (r *repeatOffsets) resolve(ofValue, litLen uint32) uint32 {
+	if ofValue > 3 {
+		off := ofValue - 3
+		r[2], r[1], r[0] = r[1], r[0], off
+		return off
+	}
+	if litLen == 0 {
+		ofValue++
+	}
+	var off uint32
+	switch ofValue {
+	case 1:
+		return r[0]
+	case 2:
+		off = r[1]
+		r[1] = r[0]
+	case 3:
+		off = r[2]
+		r[2], r[1] = r[1], r[0]
+	case 4:
+		off = r[0] - 1
+		r[2], r[1] = r[1], r[0]
+	}
+	r[0] = off
+	return off
+}
@@ -0,0 +1,675 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package heapdump reads the heap dumps written by runtime/debug.WriteHeapDump.
+
+A heap dump records the objects of the heap, the roots that refer to
+them (the data and bss segments, the stacks of the goroutines,
+finalizers, and so on), and some of the state of the runtime.
+The runtime doesn't record the types of most objects, so Read
+returns untyped objects, and LoadTypes infers their types from the
+debug information of the executable that wrote the dump, starting
+from the typed global and stack variables and following pointers.
+
+Format
+
+A heap dump starts with the header "go heap dump v2\n", which gives
+the version of the format. Version 1 dumps, which start with
+"go1.7 heap dump\n", can be read too, but they lack the module records
+needed to load types.
+
+The header is followed by a sequence of records, ending with an
+end-of-file record. Each record starts with a tag giving its kind.
+Tags, numbers and addresses are unsigned varints as written by
+encoding/binary.PutUvarint; booleans are the numbers 0 and 1;
+strings and memory contents are a length followed by as many bytes.
+A field list is a sequence of (kind, offset) pairs, ending with kind 0,
+giving the offsets of the pointers of a memory range: kind 1 is a pointer,
+2 a non-empty interface and 3 an empty interface.
+
+The records are:
+
+	0 end of file
+	1 object: address, contents, field list
+	2 other root: description, pointer
+	3 type: address, size, name, whether values are stored indirectly
+	  in interfaces
+	4 goroutine: address of the G, stack pointer, goroutine ID,
+	  PC of the go statement, status, whether it's a system goroutine,
+	  a reserved boolean, wait start time, wait reason, context pointer,
+	  address of the M, top defer record, top panic record
+	5 stack frame: stack pointer, depth, stack pointer of the callee
+	  (0 for the innermost frame), contents, entry PC of the function,
+	  PC, continuation PC, function name, field list
+	6 parameters: whether pointers are big-endian, pointer size,
+	  heap start and end addresses, GOARCH, GOEXPERIMENT, number of CPUs,
+	  and, since version 2, the Go version
+	7 finalizer: object, funcval, function PC, type of the finalizer's
+	  argument, pointer type of the object
+	8 itab: address, address of the type
+	9 OS thread: address of the M, ID, OS thread ID
+	10 memory statistics: the fields of runtime.MemStats up to NumGC,
+	  in order, except EnableGC, DebugGC and BySize
+	11 queued finalizer: as finalizer
+	12 data segment: address, contents, field list
+	13 bss segment: address, contents, field list
+	14 defer record: address, goroutine, stack pointer, PC, funcval,
+	  function PC, next defer record
+	15 panic record: address, goroutine, type and data of the panic value,
+	  a reserved number, next panic record
+	16 memory profile bucket: bucket ID, allocation size, stack depth,
+	  then for each frame its function, file and line, then the number
+	  of allocations and frees
+	17 allocation sample: object address, bucket ID
+	18 module (version 2): module name, then the start and end addresses
+	  of its text, types, noptrdata, data, bss and noptrbss sections
+
+Goroutine records are followed by the records of their stack frames,
+innermost first, and then by their defer and panic records.
+*/
+package heapdump
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+)
+
+const (
+	headerV1 = "go1.7 heap dump\n"
+	headerV2 = "go heap dump v2\n"
+)
+
+const (
+	tagEOF             = 0
+	tagObject          = 1
+	tagOtherRoot       = 2
+	tagType            = 3
+	tagGoroutine       = 4
+	tagStackFrame      = 5
+	tagParams          = 6
+	tagFinalizer       = 7
+	tagItab            = 8
+	tagOSThread        = 9
+	tagMemStats        = 10
+	tagQueuedFinalizer = 11
+	tagData            = 12
+	tagBSS             = 13
+	tagDefer           = 14
+	tagPanic           = 15
+	tagMemProf         = 16
+	tagAllocSample     = 17
+	tagModule          = 18
+)
+
+// A Dump is a heap dump.
+type Dump struct {
+	Version      int // format version
+	Params       Params
+	Modules      []*Module
+	Types        []*RuntimeType
+	Itabs        []*Itab
+	Objects      []*Object // in increasing address order
+	Goroutines   []*Goroutine
+	Threads      []*Thread
+	Data         []*Segment
+	BSS          []*Segment
+	OtherRoots   []*OtherRoot
+	Finalizers   []*Finalizer
+	MemStats     *runtime.MemStats
+	MemProf      []*MemProfBucket
+	AllocSamples []*AllocSample
+
+	// Globals are the global variables of the executable that
+	// hold pointers, in increasing address order.
+	// They are set by LoadTypes.
+	Globals []*Var
+
+	frames []*Frame // in increasing address order
+	typer  *typer   // set by LoadTypes
+}
+
+// Params are the parameters of the process that wrote a dump.
+type Params struct {
+	BigEndian    bool
+	PtrSize      int
+	HeapStart    uint64
+	HeapEnd      uint64
+	GOARCH       string
+	GOEXPERIMENT string
+	NCPU         int
+	GoVersion    string // empty before version 2
+}
+
+// A Range is an address range.
+type Range struct {
+	Start, End uint64
+}
+
+// Contains reports whether r contains addr.
+func (r Range) Contains(addr uint64) bool {
+	return r.Start <= addr && addr < r.End
+}
+
+// A Module is a module of the process: the executable, or a shared library.
+type Module struct {
+	Name      string
+	Text      Range
+	Types     Range
+	NoPtrData Range
+	Data      Range
+	BSS       Range
+	NoPtrBSS  Range
+}
+
+// A RuntimeType is a type descriptor that the runtime dumped.
+type RuntimeType struct {
+	Addr     uint64
+	Size     uint64
+	Name     string
+	Indirect bool // values are stored indirectly in interfaces
+}
+
+// An Itab is the descriptor of a non-empty interface value.
+type Itab struct {
+	Addr uint64
+	Type uint64 // address of the dynamic type
+}
+
+// A FieldKind is the kind of a pointer field.
+type FieldKind uint8
+
+const (
+	FieldPtr   FieldKind = 1 // a pointer
+	FieldIface FieldKind = 2 // a non-empty interface
+	FieldEface FieldKind = 3 // an empty interface
+)
+
+// A Field is a pointer field of a memory range.
+type Field struct {
+	Kind   FieldKind
+	Offset uint64
+}
+
+// An Object is a heap object.
+type Object struct {
+	Addr     uint64
+	Contents []byte
+	Fields   []Field // in increasing offset order
+
+	// Type is the type of the object, set by LoadTypes,
+	// or nil if it's unknown. It may be smaller than the object,
+	// whose size is rounded up to a size class.
+	Type *Type
+}
+
+// Size returns the size of o.
+func (o *Object) Size() uint64 {
+	return uint64(len(o.Contents))
+}
+
+// A Segment is a data or bss segment of a module.
+type Segment struct {
+	Addr     uint64
+	Contents []byte
+	Fields   []Field
+}
+
+// A Goroutine is a goroutine of the process.
+type Goroutine struct {
+	Addr       uint64 // address of the G
+	SP         uint64
+	ID         uint64
+	GoPC       uint64 // PC of the go statement that created it
+	Status     uint64
+	System     bool
+	WaitSince  int64
+	WaitReason string
+	Ctxt       uint64
+	M          uint64
+	Frames     []*Frame // innermost first
+	Defers     []*Defer
+	Panics     []*Panic
+}
+
+// A Frame is a stack frame of a goroutine.
+type Frame struct {
+	Goroutine *Goroutine
+	Addr      uint64 // stack pointer
+	Depth     int
+	Contents  []byte
+	Entry     uint64 // entry PC of the function
+	PC        uint64
+	ContinPC  uint64
+	Func      string
+	Fields    []Field
+
+	// Vars are the variables of the frame whose location is known,
+	// set by LoadTypes. Arguments live in the frame of the caller.
+	Vars []*Var
+}
+
+// A Var is a typed variable.
+type Var struct {
+	Name string
+	Addr uint64
+	Type *Type
+}
+
+// A Defer is a defer record of a goroutine.
+type Defer struct {
+	Addr    uint64
+	SP      uint64
+	PC      uint64
+	FuncVal uint64
+	Fn      uint64
+}
+
+// A Panic is a panic record of a goroutine.
+type Panic struct {
+	Addr uint64
+	Type uint64 // type of the panic value
+	Data uint64 // data word of the panic value
+}
+
+// A Thread is an OS thread of the process.
+type Thread struct {
+	Addr   uint64 // address of the M
+	ID     uint64
+	ProcID uint64
+}
+
+// An OtherRoot is a root of the heap other than the segments,
+// stacks and finalizers.
+type OtherRoot struct {
+	Description string
+	To          uint64
+}
+
+// A Finalizer is a finalizer set by runtime.SetFinalizer.
+type Finalizer struct {
+	Obj     uint64
+	FuncVal uint64
+	Fn      uint64
+	ArgType uint64 // type of the finalizer's argument
+	ObjType uint64 // pointer type of the object
+	Queued  bool   // the object is unreachable and the finalizer is ready to run
+}
+
+// A MemProfBucket is a bucket of the memory profile.
+type MemProfBucket struct {
+	ID     uint64
+	Size   uint64
+	Stack  []MemProfFrame
+	Allocs uint64
+	Frees  uint64
+}
+
+// A MemProfFrame is a frame of the stack of a memory profile bucket.
+type MemProfFrame struct {
+	Func string
+	File string
+	Line int
+}
+
+// An AllocSample is an object sampled by the memory profile.
+type AllocSample struct {
+	Addr   uint64
+	Bucket uint64
+}
+
+// ErrFormat is returned by Read for data that isn't a valid heap dump.
+var ErrFormat = errors.New("heapdump: invalid heap dump format")
+
+type reader struct {
+	r       *bufio.Reader
+	err     error
+	version int
+}
+
+// Read reads a heap dump.
+func Read(r io.Reader) (*Dump, error) {
+	rd := &reader{r: bufio.NewReader(r)}
+	hdr := make([]byte, len(headerV2))
+	if _, err := io.ReadFull(rd.r, hdr); err != nil {
+		return nil, ErrFormat
+	}
+	switch string(hdr) {
+	case headerV1:
+		rd.version = 1
+	case headerV2:
+		rd.version = 2
+	default:
+		return nil, ErrFormat
+	}
+	d := &Dump{Version: rd.version}
+	if err := rd.records(d); err != nil {
+		return nil, err
+	}
+	sort.Slice(d.Objects, func(i, j int) bool { return d.Objects[i].Addr < d.Objects[j].Addr })
+	sort.Slice(d.frames, func(i, j int) bool { return d.frames[i].Addr < d.frames[j].Addr })
+	return d, nil
+}
+
+func (rd *reader) records(d *Dump) error {
+	var g *Goroutine
+	for {
+		tag := rd.uint()
+		if rd.err != nil {
+			return rd.err
+		}
+		switch tag {
+		case tagEOF:
+			return nil
+		case tagObject:
+			d.Objects = append(d.Objects, &Object{Addr: rd.uint(), Contents: rd.bytes(), Fields: rd.fields()})
+		case tagOtherRoot:
+			d.OtherRoots = append(d.OtherRoots, &OtherRoot{Description: rd.string(), To: rd.uint()})
+		case tagType:
+			d.Types = append(d.Types, &RuntimeType{Addr: rd.uint(), Size: rd.uint(), Name: rd.string(), Indirect: rd.bool()})
+		case tagGoroutine:
+			g = &Goroutine{
+				Addr:   rd.uint(),
+				SP:     rd.uint(),
+				ID:     rd.uint(),
+				GoPC:   rd.uint(),
+				Status: rd.uint(),
+				System: rd.bool(),
+			}
+			rd.bool() // reserved
+			g.WaitSince = int64(rd.uint())
+			g.WaitReason = rd.string()
+			g.Ctxt = rd.uint()
+			g.M = rd.uint()
+			rd.uint() // top defer, recorded by the defer records
+			rd.uint() // top panic, recorded by the panic records
+			d.Goroutines = append(d.Goroutines, g)
+		case tagStackFrame:
+			f := &Frame{Goroutine: g, Addr: rd.uint(), Depth: int(rd.uint())}
+			rd.uint() // callee's stack pointer, implied by the order of the frames
+			f.Contents = rd.bytes()
+			f.Entry = rd.uint()
+			f.PC = rd.uint()
+			f.ContinPC = rd.uint()
+			f.Func = rd.string()
+			f.Fields = rd.fields()
+			if g == nil {
+				return fmt.Errorf("heapdump: stack frame of %s outside of a goroutine", f.Func)
+			}
+			g.Frames = append(g.Frames, f)
+			d.frames = append(d.frames, f)
+		case tagParams:
+			p := &d.Params
+			p.BigEndian = rd.bool()
+			p.PtrSize = int(rd.uint())
+			p.HeapStart = rd.uint()
+			p.HeapEnd = rd.uint()
+			p.GOARCH = rd.string()
+			p.GOEXPERIMENT = rd.string()
+			p.NCPU = int(rd.uint())
+			if rd.version >= 2 {
+				p.GoVersion = rd.string()
+			}
+		case tagFinalizer, tagQueuedFinalizer:
+			d.Finalizers = append(d.Finalizers, &Finalizer{
+				Obj:     rd.uint(),
+				FuncVal: rd.uint(),
+				Fn:      rd.uint(),
+				ArgType: rd.uint(),
+				ObjType: rd.uint(),
+				Queued:  tag == tagQueuedFinalizer,
+			})
+		case tagItab:
+			d.Itabs = append(d.Itabs, &Itab{Addr: rd.uint(), Type: rd.uint()})
+		case tagOSThread:
+			d.Threads = append(d.Threads, &Thread{Addr: rd.uint(), ID: rd.uint(), ProcID: rd.uint()})
+		case tagMemStats:
+			d.MemStats = rd.memStats()
+		case tagData, tagBSS:
+			s := &Segment{Addr: rd.uint(), Contents: rd.bytes(), Fields: rd.fields()}
+			if tag == tagData {
+				d.Data = append(d.Data, s)
+			} else {
+				d.BSS = append(d.BSS, s)
+			}
+		case tagDefer:
+			df := &Defer{Addr: rd.uint()}
+			gaddr := rd.uint()
+			df.SP = rd.uint()
+			df.PC = rd.uint()
+			df.FuncVal = rd.uint()
+			df.Fn = rd.uint()
+			rd.uint() // next record
+			if g == nil || g.Addr != gaddr {
+				return fmt.Errorf("heapdump: defer record %#x outside of its goroutine", df.Addr)
+			}
+			g.Defers = append(g.Defers, df)
+		case tagPanic:
+			p := &Panic{Addr: rd.uint()}
+			gaddr := rd.uint()
+			p.Type = rd.uint()
+			p.Data = rd.uint()
+			rd.uint() // reserved
+			rd.uint() // next record
+			if g == nil || g.Addr != gaddr {
+				return fmt.Errorf("heapdump: panic record %#x outside of its goroutine", p.Addr)
+			}
+			g.Panics = append(g.Panics, p)
+		case tagMemProf:
+			b := &MemProfBucket{ID: rd.uint(), Size: rd.uint()}
+			n := rd.uint()
+			for i := uint64(0); i < n && rd.err == nil; i++ {
+				b.Stack = append(b.Stack, MemProfFrame{Func: rd.string(), File: rd.string(), Line: int(rd.uint())})
+			}
+			b.Allocs = rd.uint()
+			b.Frees = rd.uint()
+			d.MemProf = append(d.MemProf, b)
+		case tagAllocSample:
+			d.AllocSamples = append(d.AllocSamples, &AllocSample{Addr: rd.uint(), Bucket: rd.uint()})
+		case tagModule:
+			d.Modules = append(d.Modules, &Module{
+				Name:      rd.string(),
+				Text:      rd.rng(),
+				Types:     rd.rng(),
+				NoPtrData: rd.rng(),
+				Data:      rd.rng(),
+				BSS:       rd.rng(),
+				NoPtrBSS:  rd.rng(),
+			})
+		default:
+			return fmt.Errorf("heapdump: unknown record tag %d", tag)
+		}
+	}
+}
+
+func (rd *reader) uint() uint64 {
+	if rd.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(rd.r)
+	if err != nil {
+		rd.fail(err)
+	}
+	return v
+}
+
+func (rd *reader) bool() bool {
+	return rd.uint() != 0
+}
+
+func (rd *reader) bytes() []byte {
+	n := rd.uint()
+	if rd.err != nil {
+		return nil
+	}
+	if n > 1<<40 {
+		rd.fail(ErrFormat)
+		return nil
+	}
+	// Don't trust n for the allocation: grow the buffer as data comes.
+	var b []byte
+	for uint64(len(b)) < n {
+		chunk := n - uint64(len(b))
+		if chunk > 1<<20 {
+			chunk = 1 << 20
+		}
+		start := len(b)
+		b = append(b, make([]byte, chunk)...)
+		if _, err := io.ReadFull(rd.r, b[start:]); err != nil {
+			rd.fail(err)
+			return nil
+		}
+	}
+	return b
+}
+
+func (rd *reader) string() string {
+	return string(rd.bytes())
+}
+
+func (rd *reader) rng() Range {
+	return Range{Start: rd.uint(), End: rd.uint()}
+}
+
+func (rd *reader) fields() []Field {
+	var fields []Field
+	for rd.err == nil {
+		kind := rd.uint()
+		if kind == 0 {
+			break
+		}
+		if kind > uint64(FieldEface) {
+			rd.fail(ErrFormat)
+			break
+		}
+		fields = append(fields, Field{Kind: FieldKind(kind), Offset: rd.uint()})
+	}
+	if !sort.SliceIsSorted(fields, func(i, j int) bool { return fields[i].Offset < fields[j].Offset }) {
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Offset < fields[j].Offset })
+	}
+	return fields
+}
+
+func (rd *reader) memStats() *runtime.MemStats {
+	m := new(runtime.MemStats)
+	for _, p := range []*uint64{
+		&m.Alloc, &m.TotalAlloc, &m.Sys, &m.Lookups, &m.Mallocs, &m.Frees,
+		&m.HeapAlloc, &m.HeapSys, &m.HeapIdle, &m.HeapInuse, &m.HeapReleased, &m.HeapObjects,
+		&m.StackInuse, &m.StackSys, &m.MSpanInuse, &m.MSpanSys, &m.MCacheInuse, &m.MCacheSys,
+		&m.BuckHashSys, &m.GCSys, &m.OtherSys, &m.NextGC, &m.LastGC, &m.PauseTotalNs,
+	} {
+		*p = rd.uint()
+	}
+	for i := range m.PauseNs {
+		m.PauseNs[i] = rd.uint()
+	}
+	m.NumGC = uint32(rd.uint())
+	return m
+}
+
+func (rd *reader) fail(err error) {
+	if rd.err != nil {
+		return
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	rd.err = fmt.Errorf("heapdump: %w", err)
+}
+
+// FindObject returns the object that contains addr, or nil.
+func (d *Dump) FindObject(addr uint64) *Object {
+	i := sort.Search(len(d.Objects), func(i int) bool { return d.Objects[i].Addr > addr }) - 1
+	if i < 0 {
+		return nil
+	}
+	if o := d.Objects[i]; addr < o.Addr+o.Size() {
+		return o
+	}
+	return nil
+}
+
+// findFrame returns the stack frame that contains addr, or nil.
+func (d *Dump) findFrame(addr uint64) *Frame {
+	i := sort.Search(len(d.frames), func(i int) bool { return d.frames[i].Addr > addr }) - 1
+	if i < 0 {
+		return nil
+	}
+	if f := d.frames[i]; addr < f.Addr+uint64(len(f.Contents)) {
+		return f
+	}
+	return nil
+}
+
+// findSegment returns the data or bss segment that contains addr, or nil.
+func (d *Dump) findSegment(addr uint64) *Segment {
+	for _, ss := range [][]*Segment{d.Data, d.BSS} {
+		for _, s := range ss {
+			if s.Addr <= addr && addr < s.Addr+uint64(len(s.Contents)) {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// region returns the dumped memory that contains addr,
+// its address, and its pointer fields.
+func (d *Dump) region(addr uint64) (base uint64, contents []byte, fields []Field) {
+	if o := d.FindObject(addr); o != nil {
+		return o.Addr, o.Contents, o.Fields
+	}
+	if s := d.findSegment(addr); s != nil {
+		return s.Addr, s.Contents, s.Fields
+	}
+	if f := d.findFrame(addr); f != nil {
+		return f.Addr, f.Contents, f.Fields
+	}
+	return 0, nil, nil
+}
+
+// Memory returns the n bytes of memory at addr, if they are part of
+// a single heap object, segment or stack frame of the dump,
+// or nil otherwise.
+func (d *Dump) Memory(addr uint64, n int) []byte {
+	base, contents, _ := d.region(addr)
+	off := addr - base
+	if contents == nil || uint64(n) > uint64(len(contents))-off {
+		return nil
+	}
+	return contents[off : off+uint64(n)]
+}
+
+// ReadPtr returns the pointer-sized word of memory at addr,
+// and whether it's part of the dump.
+func (d *Dump) ReadPtr(addr uint64) (uint64, bool) {
+	b := d.Memory(addr, d.Params.PtrSize)
+	if b == nil {
+		return 0, false
+	}
+	return d.word(b), true
+}
+
+// word decodes a pointer-sized word.
+func (d *Dump) word(b []byte) uint64 {
+	var bo binary.ByteOrder = binary.LittleEndian
+	if d.Params.BigEndian {
+		bo = binary.BigEndian
+	}
+	if d.Params.PtrSize == 4 {
+		return uint64(bo.Uint32(b))
+	}
+	return bo.Uint64(b)
+}
+
+// hasField reports whether the sorted fields include a pointer at off.
+func hasField(fields []Field, off uint64) bool {
+	i := sort.Search(len(fields), func(i int) bool { return fields[i].Offset >= off })
+	return i < len(fields) && fields[i].Offset == off
+}
@@ -0,0 +1,290 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package heapdump_test
+
+import (
+	"bytes"
+	. "debug/heapdump"
+	"fmt"
+	"internal/testenv"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+type testNode struct {
+	name  string
+	next  *testNode
+	items []*testItem
+	value interface{}
+	err   error
+	m     map[string]*testItem
+}
+
+type testItem struct {
+	n   int
+	buf [100]byte
+}
+
+type testError struct {
+	msg string
+}
+
+func (e testError) Error() string { return e.msg }
+
+var testRoot *testNode
+
+// writeDump returns a heap dump of the process.
+func writeDump(t *testing.T) *Dump {
+	if runtime.GOOS == "js" {
+		t.Skipf("WriteHeapDump is not available on %s", runtime.GOOS)
+	}
+	f, err := os.CreateTemp("", "heapdump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	debug.WriteHeapDump(f.Fd())
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	d, err := Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func newTestRoot() {
+	items := make([]*testItem, 10)
+	for i := range items {
+		items[i] = &testItem{n: i}
+	}
+	testRoot = &testNode{
+		name:  strings.Repeat("root", 10),
+		next:  &testNode{name: "next"},
+		items: items,
+		value: testItem{n: 100},
+		err:   testError{strings.Repeat("error", 10)},
+		m:     map[string]*testItem{"a": {n: 200}},
+	}
+}
+
+func TestRead(t *testing.T) {
+	newTestRoot()
+	d := writeDump(t)
+	if d.Version != 2 {
+		t.Errorf("Version = %d, want 2", d.Version)
+	}
+	p := d.Params
+	if p.PtrSize != int(unsafe.Sizeof(uintptr(0))) || p.GOARCH != runtime.GOARCH || p.GoVersion != runtime.Version() || p.NCPU != runtime.NumCPU() {
+		t.Errorf("Params = %+v, want pointer size %d, GOARCH %s, Go version %s and %d CPUs",
+			p, unsafe.Sizeof(uintptr(0)), runtime.GOARCH, runtime.Version(), runtime.NumCPU())
+	}
+	if len(d.Modules) == 0 || len(d.Data) != len(d.Modules) || len(d.BSS) != len(d.Modules) {
+		t.Fatalf("%d modules, %d data and %d bss segments, want at least one module and one segment of each per module",
+			len(d.Modules), len(d.Data), len(d.BSS))
+	}
+	if d.MemStats == nil || d.MemStats.HeapObjects == 0 {
+		t.Errorf("MemStats = %+v, want a non-empty heap", d.MemStats)
+	}
+
+	addr := uint64(uintptr(unsafe.Pointer(testRoot)))
+	o := d.FindObject(addr + 8)
+	if o == nil || o.Addr != addr {
+		t.Fatalf("FindObject didn't find the object at %#x", addr)
+	}
+	if o.Size() < uint64(unsafe.Sizeof(*testRoot)) || len(o.Fields) == 0 {
+		t.Errorf("object of %d bytes with %d pointers, want %d bytes and some pointers", o.Size(), len(o.Fields), unsafe.Sizeof(*testRoot))
+	}
+	if next, ok := d.ReadPtr(addr + uint64(unsafe.Offsetof(testRoot.next))); !ok || next != uint64(uintptr(unsafe.Pointer(testRoot.next))) {
+		t.Errorf("ReadPtr(&testRoot.next) = %#x, %v; want %p", next, ok, testRoot.next)
+	}
+	name := (*[2]uintptr)(unsafe.Pointer(&testRoot.name))
+	if b := d.Memory(uint64(name[0]), int(name[1])); !bytes.Equal(b, []byte(testRoot.name)) {
+		t.Errorf("Memory(testRoot.name) = %q, want %q", b, testRoot.name)
+	}
+
+	found := false
+	for _, g := range d.Goroutines {
+		for _, f := range g.Frames {
+			found = found || f.Func == "debug/heapdump_test.TestRead"
+		}
+	}
+	if !found {
+		t.Error("no stack frame of TestRead")
+	}
+	runtime.KeepAlive(testRoot)
+}
+
+func TestReadInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"go heap dump v3\n",
+		"go heap dump v2\n",
+		"go heap dump v2\n\x63",
+		"go heap dump v2\n\x01\x10\x05abc",
+	} {
+		if _, err := Read(strings.NewReader(s)); err == nil {
+			t.Errorf("Read(%q) succeeded, want an error", s)
+		}
+	}
+	if d, err := Read(strings.NewReader("go1.7 heap dump\n\x00")); err != nil || d.Version != 1 {
+		t.Errorf("Read(empty version 1 dump) = %v, %v; want version 1", d, err)
+	}
+}
+
+const loadTypesProgram = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"unsafe"
+)
+
+type node struct {
+	name  string
+	next  *node
+	items []*item
+	value interface{}
+	err   error
+	m     map[string]*item
+}
+
+type item struct {
+	n   int
+	buf [100]byte
+}
+
+type myError struct {
+	msg string
+}
+
+func (e myError) Error() string { return e.msg }
+
+var root *node
+
+//go:noinline
+func newItem(n int) *item {
+	return &item{n: n}
+}
+
+func main() {
+	items := make([]*item, 10)
+	for i := range items {
+		items[i] = &item{n: i}
+	}
+	root = &node{
+		name:  strings.Repeat("root", 10),
+		next:  &node{name: "next"},
+		items: items,
+		value: item{n: 100},
+		err:   myError{strings.Repeat("error", 10)},
+		m:     map[string]*item{"a": {n: 200}},
+	}
+	local := newItem(300)
+
+	f, err := os.Create(os.Args[1])
+	if err != nil {
+		panic(err)
+	}
+	debug.WriteHeapDump(f.Fd())
+	f.Close()
+
+	report := func(what string, p unsafe.Pointer, typ string) {
+		fmt.Printf("%s %#x %s\n", what, uintptr(p), typ)
+	}
+	report("&root", unsafe.Pointer(&root), "*main.node")
+	report("root", unsafe.Pointer(root), "main.node")
+	report("root.next", unsafe.Pointer(root.next), "main.node")
+	report("root.items", unsafe.Pointer(&root.items[0]), "[10]*main.item")
+	report("root.items[0]", unsafe.Pointer(root.items[0]), "main.item")
+	report("root.name", unsafe.Pointer((*[2]uintptr)(unsafe.Pointer(&root.name))[0]), "[48]uint8")
+	report("root.value", (*[2]unsafe.Pointer)(unsafe.Pointer(&root.value))[1], "main.item")
+	report("root.err", (*[2]unsafe.Pointer)(unsafe.Pointer(&root.err))[1], "main.myError")
+	report("root.m", *(*unsafe.Pointer)(unsafe.Pointer(&root.m)), "hash<string,*main.item>")
+	report("root.m[a]", unsafe.Pointer(root.m["a"]), "main.item")
+	report("local", unsafe.Pointer(local), "main.item")
+	runtime.KeepAlive(local)
+}
+`
+
+func TestLoadTypes(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+	if runtime.GOOS == "js" {
+		t.Skipf("WriteHeapDump is not available on %s", runtime.GOOS)
+	}
+	t.Parallel()
+
+	// Test executables have no debug information:
+	// build a program with its debug information.
+	dir := t.TempDir()
+	src := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(src, []byte(loadTypesProgram), 0666); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(dir, "main.exe")
+	if out, err := exec.Command(testenv.GoToolPath(t), "build", "-o", exe, src).CombinedOutput(); err != nil {
+		t.Fatalf("building the test program: %v\n%s", err, out)
+	}
+	dump := filepath.Join(dir, "heapdump")
+	out, err := exec.Command(exe, dump).CombinedOutput()
+	if err != nil {
+		t.Fatalf("running the test program: %v\n%s", err, out)
+	}
+	f, err := os.Open(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	d, err := Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.LoadTypes(exe); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		var (
+			what, want string
+			addr       uint64
+		)
+		if _, err := fmt.Sscanf(line, "%s %v %s", &what, &addr, &want); err != nil {
+			t.Fatalf("parsing %q: %v", line, err)
+		}
+		if what == "&root" {
+			var global *Var
+			for _, g := range d.Globals {
+				if g.Name == "main.root" {
+					global = g
+				}
+			}
+			if global == nil || global.Addr != addr || global.Type.Name != want {
+				t.Errorf("global main.root = %+v, want a %s at %#x", global, want, addr)
+			}
+			continue
+		}
+		got := "unknown"
+		if o := d.FindObject(addr); o == nil {
+			got = "no object"
+		} else if o.Type != nil {
+			got = o.Type.Name
+		}
+		if got != want {
+			t.Errorf("type of %s = %s, want %s", what, got, want)
+		}
+	}
+}
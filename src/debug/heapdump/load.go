@@ -0,0 +1,311 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package heapdump
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// LoadTypes reads the debug information of the executable that wrote d,
+// and sets the types of the objects of d, and its global and stack
+// variables.
+//
+// The types of the variables are given by the debug information,
+// and LoadTypes infers the types of the objects they point to,
+// transitively. The dynamic types of interfaces are known too,
+// so that most reachable objects are typed, unless the process
+// hides pointers (in a uintptr or an unsafe.Pointer).
+// Only the variables of the executable are typed, not those of the
+// shared libraries and plugins of the process.
+func (d *Dump) LoadTypes(executable string) error {
+	if len(d.Modules) == 0 {
+		return errors.New("heapdump: heap dump has no module information")
+	}
+	p, err := openProgram(executable, &d.Params)
+	if err != nil {
+		return err
+	}
+	mod := d.Modules[0]
+	t := &typer{
+		d:      d,
+		p:      p,
+		mod:    mod,
+		bias:   mod.Text.Start - p.text,
+		itabs:  make(map[uint64]uint64),
+		arrays: make(map[arrayKey]*Type),
+	}
+	for _, itab := range d.Itabs {
+		t.itabs[itab.Addr] = itab.Type
+	}
+	if !t.matches() {
+		return fmt.Errorf("heapdump: executable %s did not write the heap dump", executable)
+	}
+	for _, o := range d.Objects {
+		o.Type = nil
+	}
+	t.globals()
+	t.frames()
+	t.finalizers()
+	t.drain()
+	d.typer = t
+	return nil
+}
+
+// DynamicType returns the dynamic type of a value of the interface type
+// iface whose first word is word, or nil if it's unknown.
+// It returns nil before LoadTypes is called.
+func (d *Dump) DynamicType(iface *Type, word uint64) *Type {
+	if d.typer == nil || iface.Kind != Interface {
+		return nil
+	}
+	return d.typer.dynamicType(iface, word)
+}
+
+// A typer infers the types of the objects of a dump.
+type typer struct {
+	d     *Dump
+	p     *program
+	mod   *Module           // the executable
+	bias  uint64            // difference between run-time and link-time addresses
+	itabs map[uint64]uint64 // type addresses, by itab address
+
+	arrays map[arrayKey]*Type
+	queue  []*Object // typed objects whose pointers are to follow
+}
+
+type arrayKey struct {
+	elem *Type
+	n    uint64
+}
+
+// matches reports whether the stack frames of the executable
+// are those of the program.
+func (t *typer) matches() bool {
+	frames, found := 0, 0
+	for _, f := range t.d.frames {
+		if !t.mod.Text.Contains(f.Entry) {
+			continue
+		}
+		frames++
+		if t.p.funcs[f.Entry-t.bias] != nil {
+			found++
+		}
+	}
+	// Assembly functions have no debug information.
+	return frames == 0 || found > frames/2
+}
+
+// globals types the global variables and what they point to.
+func (t *typer) globals() {
+	d := t.d
+	d.Globals = nil
+	for _, g := range t.p.globals {
+		addr := g.addr + t.bias
+		s := d.findSegment(addr)
+		if s == nil || g.typ == nil {
+			continue
+		}
+		d.Globals = append(d.Globals, &Var{Name: g.name, Addr: addr, Type: g.typ})
+		t.walk(s.Contents, s.Fields, addr-s.Addr, g.typ)
+	}
+	sort.Slice(d.Globals, func(i, j int) bool { return d.Globals[i].Addr < d.Globals[j].Addr })
+}
+
+// frames types the variables of the stack frames and what they point to.
+func (t *typer) frames() {
+	for _, f := range t.d.frames {
+		f.Vars = nil
+		fn := t.p.funcs[f.Entry-t.bias]
+		if fn == nil {
+			continue
+		}
+		pc := f.PC
+		if pc != f.Entry {
+			pc-- // the call instruction
+		}
+		cfa := f.Addr + uint64(len(f.Contents))
+		for _, v := range fn.vars {
+			if v.typ == nil {
+				continue
+			}
+			off, ok := frameOffset(t.p.location(v, pc-t.bias), v.typ.Size)
+			if !ok {
+				continue
+			}
+			// Arguments are in the frame of the caller.
+			addr := cfa + uint64(off)
+			vf := t.d.findFrame(addr)
+			if vf == nil || vf.Goroutine != f.Goroutine || addr+v.typ.Size > vf.Addr+uint64(len(vf.Contents)) {
+				continue
+			}
+			f.Vars = append(f.Vars, &Var{Name: v.name, Addr: addr, Type: v.typ})
+			t.walk(vf.Contents, vf.Fields, addr-vf.Addr, v.typ)
+		}
+	}
+}
+
+// finalizers types the objects that have finalizers,
+// and panic values.
+func (t *typer) finalizers() {
+	for _, f := range t.d.Finalizers {
+		if pt := t.rtype(f.ObjType); pt != nil && pt.Elem != nil {
+			t.setObject(f.Obj, pt.Elem)
+		}
+	}
+	for _, g := range t.d.Goroutines {
+		for _, p := range g.Panics {
+			if typ := t.rtype(p.Type); typ != nil {
+				t.setIface(p.Data, typ)
+			}
+		}
+	}
+}
+
+// drain follows the pointers of the typed objects, until all the
+// objects they reach are typed.
+func (t *typer) drain() {
+	for len(t.queue) > 0 {
+		o := t.queue[len(t.queue)-1]
+		t.queue = t.queue[:len(t.queue)-1]
+		t.walk(o.Contents, o.Fields, 0, o.Type)
+	}
+}
+
+// rtype returns the type whose descriptor is at addr, or nil.
+func (t *typer) rtype(addr uint64) *Type {
+	if !t.mod.Types.Contains(addr) {
+		return nil
+	}
+	return t.p.rtype(addr - t.mod.Types.Start)
+}
+
+// walk types the objects that the value of type typ at offset off
+// of memory contents points to. The memory has pointers at fields.
+func (t *typer) walk(contents []byte, fields []Field, off uint64, typ *Type) {
+	if typ == nil || off+typ.Size > uint64(len(contents)) {
+		return
+	}
+	typ.layout(t.p.ptrSize)
+	if len(typ.ptrs) == 0 {
+		return
+	}
+	switch typ.Kind {
+	case Ptr, Map, Chan:
+		if typ.Elem != nil && hasField(fields, off) {
+			t.setObject(t.d.word(contents[off:]), typ.Elem)
+		}
+	case Array:
+		for i := uint64(0); i < typ.Len; i++ {
+			t.walk(contents, fields, off+i*typ.Elem.Size, typ.Elem)
+		}
+	case Struct, String, Slice:
+		for _, f := range typ.Fields {
+			t.walk(contents, fields, off+f.Offset, f.Type)
+		}
+	case Interface:
+		data := off + t.p.ptrSize
+		if !hasField(fields, data) {
+			return
+		}
+		dyn := t.dynamicType(typ, t.d.word(contents[off:]))
+		if dyn == nil {
+			return
+		}
+		dyn.layout(t.p.ptrSize)
+		if dyn.direct {
+			t.walk(contents, fields, data, dyn)
+		} else {
+			t.setObject(t.d.word(contents[data:]), dyn)
+		}
+	}
+}
+
+// dynamicType returns the dynamic type of a value of the interface
+// type iface whose first word is word, or nil.
+func (t *typer) dynamicType(iface *Type, word uint64) *Type {
+	if !iface.IsEmptyInterface() {
+		word = t.itabs[word]
+	}
+	return t.rtype(word)
+}
+
+// setIface types the value of type typ that is the data word p
+// of an interface.
+func (t *typer) setIface(p uint64, typ *Type) {
+	typ.layout(t.p.ptrSize)
+	if !typ.direct {
+		t.setObject(p, typ)
+		return
+	}
+	b := make([]byte, t.p.ptrSize)
+	if t.p.ptrSize == 4 {
+		t.p.byteOrder.PutUint32(b, uint32(p))
+	} else {
+		t.p.byteOrder.PutUint64(b, p)
+	}
+	t.walk(b, []Field{{FieldPtr, 0}}, 0, typ)
+}
+
+// setObject types the object that addr points to,
+// which holds a value or an array of values of type typ.
+func (t *typer) setObject(addr uint64, typ *Type) {
+	o := t.d.FindObject(addr)
+	if o == nil || o.Type != nil || typ.Size == 0 {
+		return
+	}
+	off := addr - o.Addr
+	if off%typ.Size != 0 {
+		return
+	}
+	// Objects are rounded up to size classes, which are less than
+	// twice the size of the value, except for tiny noscan values.
+	// Bigger objects are arrays, like the backing arrays of slices.
+	n := o.Size() / typ.Size
+	ot := typ
+	switch {
+	case n == 0, n == 1 && off != 0:
+		return
+	case n > 1:
+		ot = t.arrayOf(typ, n)
+	}
+	if !fits(o, ot, t.p.ptrSize) {
+		return
+	}
+	o.Type = ot
+	t.queue = append(t.queue, o)
+}
+
+// fits reports whether the pointers of o match those of typ.
+func fits(o *Object, typ *Type, ptrSize uint64) bool {
+	typ.layout(ptrSize)
+	if len(typ.ptrs) == 0 || len(o.Fields) == 0 {
+		return len(typ.ptrs) == 0 && len(o.Fields) == 0
+	}
+	for _, off := range typ.ptrs {
+		if !hasField(o.Fields, off) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *typer) arrayOf(elem *Type, n uint64) *Type {
+	k := arrayKey{elem, n}
+	if a := t.arrays[k]; a != nil {
+		return a
+	}
+	a := &Type{
+		Name: "[" + strconv.FormatUint(n, 10) + "]" + elem.Name,
+		Kind: Array,
+		Size: n * elem.Size,
+		Elem: elem,
+		Len:  n,
+	}
+	t.arrays[k] = a
+	return a
+}
@@ -0,0 +1,574 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package heapdump
+
+import (
+	"bytes"
+	"compress/zlib"
+	"debug/dwarf"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Go-specific DWARF attributes, see cmd/internal/dwarf.
+const (
+	attrGoKind          dwarf.Attr = 0x2900
+	attrGoEmbeddedField dwarf.Attr = 0x2903
+	attrGoRuntimeType   dwarf.Attr = 0x2904
+)
+
+// DWARF location operations.
+const (
+	opAddr         = 0x03
+	opFbreg        = 0x91
+	opPiece        = 0x93
+	opCallFrameCFA = 0x9c
+)
+
+// DWARF base type encodings.
+const (
+	encBoolean      = 0x02
+	encComplexFloat = 0x03
+	encFloat        = 0x04
+	encSigned       = 0x05
+	encUnsigned     = 0x08
+)
+
+// A program is the debug information of an executable.
+type program struct {
+	ptrSize   uint64
+	byteOrder binary.ByteOrder
+	text      uint64 // address of the text section
+	loc       []byte // contents of the .debug_loc section
+
+	dies    map[dwarf.Offset]*die
+	types   map[dwarf.Offset]*Type
+	rtypes  map[uint64]dwarf.Offset // by offset in the types section
+	globals []*global
+	funcs   map[uint64]*function // by entry PC
+}
+
+// A die is a debugging information entry and its children.
+type die struct {
+	*dwarf.Entry
+	children []*die
+}
+
+type global struct {
+	name string
+	addr uint64
+	typ  *Type
+}
+
+type function struct {
+	name string
+	vars []*funcVar
+}
+
+// A funcVar is a local variable or an argument of a function.
+type funcVar struct {
+	name string
+	typ  *Type
+	loc  []byte // location expression, if list < 0
+	list int64  // offset of the location list in .debug_loc, or -1
+	base uint64 // base address of the location list
+}
+
+// openProgram reads the debug information of the executable file name,
+// for a process with the parameters p.
+func openProgram(name string, p *Params) (*program, error) {
+	prog := &program{
+		ptrSize:   uint64(p.PtrSize),
+		byteOrder: binary.LittleEndian,
+		dies:      make(map[dwarf.Offset]*die),
+		types:     make(map[dwarf.Offset]*Type),
+		rtypes:    make(map[uint64]dwarf.Offset),
+		funcs:     make(map[uint64]*function),
+	}
+	if p.BigEndian {
+		prog.byteOrder = binary.BigEndian
+	}
+	d, err := prog.open(name)
+	if err != nil {
+		return nil, fmt.Errorf("heapdump: reading debug information of %s: %v", name, err)
+	}
+	if err := prog.load(d); err != nil {
+		return nil, fmt.Errorf("heapdump: reading debug information of %s: %v", name, err)
+	}
+	return prog, nil
+}
+
+// open opens the executable file name, records the address of
+// its text section and its location lists, and returns its DWARF data.
+func (p *program) open(name string) (*dwarf.Data, error) {
+	var (
+		d      *dwarf.Data
+		text   uint64
+		textOK bool
+		loc    func(name string) ([]byte, error)
+		err    error
+	)
+	if f, ferr := elf.Open(name); ferr == nil {
+		defer f.Close()
+		if s := f.Section(".text"); s != nil {
+			text, textOK = s.Addr, true
+		}
+		loc = func(name string) ([]byte, error) {
+			if s := f.Section(name); s != nil {
+				return s.Data()
+			}
+			return nil, nil
+		}
+		d, err = f.DWARF()
+	} else if f, ferr := macho.Open(name); ferr == nil {
+		defer f.Close()
+		if s := f.Section("__text"); s != nil {
+			text, textOK = s.Addr, true
+		}
+		loc = func(name string) ([]byte, error) {
+			if s := f.Section("__" + name[1:]); s != nil {
+				return s.Data()
+			}
+			return nil, nil
+		}
+		d, err = f.DWARF()
+	} else if f, ferr := pe.Open(name); ferr == nil {
+		defer f.Close()
+		var base uint64
+		switch h := f.OptionalHeader.(type) {
+		case *pe.OptionalHeader32:
+			base = uint64(h.ImageBase)
+		case *pe.OptionalHeader64:
+			base = h.ImageBase
+		}
+		if s := f.Section(".text"); s != nil {
+			text, textOK = base+uint64(s.VirtualAddress), true
+		}
+		loc = func(name string) ([]byte, error) {
+			s := f.Section(name)
+			if s == nil {
+				return nil, nil
+			}
+			b, err := s.Data()
+			if err == nil && s.VirtualSize != 0 && uint64(s.VirtualSize) < uint64(len(b)) {
+				b = b[:s.VirtualSize]
+			}
+			return b, err
+		}
+		d, err = f.DWARF()
+	} else {
+		return nil, fmt.Errorf("unrecognized executable format")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !textOK {
+		return nil, fmt.Errorf("no text section")
+	}
+	p.text = text
+	if p.loc, err = loc(".debug_loc"); err != nil {
+		return nil, err
+	}
+	if p.loc == nil {
+		b, err := loc(".zdebug_loc")
+		if err != nil {
+			return nil, err
+		}
+		if p.loc, err = decompress(b); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// decompress decompresses the contents of a .zdebug section.
+func decompress(b []byte) ([]byte, error) {
+	if len(b) < 12 || string(b[:4]) != "ZLIB" {
+		return b, nil
+	}
+	size := binary.BigEndian.Uint64(b[4:12])
+	r, err := zlib.NewReader(bytes.NewReader(b[12:]))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, size)
+	if _, err := io.ReadFull(r, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// load reads the entries of d, and the global variables and functions
+// they describe.
+func (p *program) load(d *dwarf.Data) error {
+	var units, stack []*die
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return err
+		}
+		if e == nil {
+			break
+		}
+		if e.Tag == 0 {
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		n := &die{Entry: e}
+		p.dies[e.Offset] = n
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, n)
+		} else {
+			units = append(units, n)
+		}
+		if e.Children {
+			stack = append(stack, n)
+		}
+		if rt, ok := e.Val(attrGoRuntimeType).(uint64); ok && rt != 0 {
+			p.rtypes[rt] = e.Offset
+		}
+	}
+	for _, u := range units {
+		base, _ := u.Val(dwarf.AttrLowpc).(uint64)
+		for _, n := range u.children {
+			switch n.Tag {
+			case dwarf.TagVariable:
+				p.addGlobal(n)
+			case dwarf.TagSubprogram:
+				p.addFunc(n, base)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *program) addGlobal(n *die) {
+	loc, _ := n.Val(dwarf.AttrLocation).([]byte)
+	name, _ := n.Val(dwarf.AttrName).(string)
+	if len(loc) != 1+int(p.ptrSize) || loc[0] != opAddr {
+		return
+	}
+	p.globals = append(p.globals, &global{
+		name: name,
+		addr: p.word(loc[1:]),
+		typ:  p.typeOf(n),
+	})
+}
+
+func (p *program) addFunc(n *die, base uint64) {
+	entry, ok := n.Val(dwarf.AttrLowpc).(uint64)
+	if !ok {
+		return // abstract function
+	}
+	f := &function{name: p.name(n)}
+	var walk func(n *die)
+	walk = func(n *die) {
+		for _, c := range n.children {
+			switch c.Tag {
+			case dwarf.TagLexDwarfBlock:
+				walk(c)
+			case dwarf.TagVariable, dwarf.TagFormalParameter:
+				v := &funcVar{name: p.name(c), typ: p.typeOf(c), list: -1, base: base}
+				fld := c.AttrField(dwarf.AttrLocation)
+				if fld == nil {
+					continue
+				}
+				switch fld.Class {
+				case dwarf.ClassExprLoc, dwarf.ClassBlock:
+					v.loc, _ = fld.Val.([]byte)
+				case dwarf.ClassLocListPtr:
+					v.list, _ = fld.Val.(int64)
+				default:
+					continue
+				}
+				f.vars = append(f.vars, v)
+			}
+		}
+	}
+	walk(n)
+	p.funcs[entry] = f
+}
+
+// origin returns the entry that n is a concrete instance of, or n.
+func (p *program) origin(n *die) *die {
+	if off, ok := n.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset); ok {
+		if o := p.dies[off]; o != nil {
+			return o
+		}
+	}
+	return n
+}
+
+func (p *program) name(n *die) string {
+	name, _ := p.origin(n).Val(dwarf.AttrName).(string)
+	return name
+}
+
+// typeOf returns the type of the variable n.
+func (p *program) typeOf(n *die) *Type {
+	off, ok := p.origin(n).Val(dwarf.AttrType).(dwarf.Offset)
+	if !ok {
+		return nil
+	}
+	return p.typ(off)
+}
+
+// rtype returns the type whose descriptor is at offset off
+// in the types section, or nil.
+func (p *program) rtype(off uint64) *Type {
+	if doff, ok := p.rtypes[off]; ok {
+		return p.typ(doff)
+	}
+	return nil
+}
+
+// typ returns the type described by the entry at off.
+func (p *program) typ(off dwarf.Offset) *Type {
+	if t, ok := p.types[off]; ok {
+		return t
+	}
+	n := p.dies[off]
+	if n == nil {
+		return nil
+	}
+	name, _ := n.Val(dwarf.AttrName).(string)
+	size, _ := n.Val(dwarf.AttrByteSize).(int64)
+	kind, _ := n.Val(attrGoKind).(int64)
+	under, _ := n.Val(dwarf.AttrType).(dwarf.Offset)
+
+	if n.Tag == dwarf.TagTypedef {
+		switch Kind(kind) {
+		case Interface, Map, Chan, Func:
+		default:
+			// A named type, described by its underlying entry.
+			t := p.typ(under)
+			p.types[off] = t
+			return t
+		}
+	}
+
+	t := &Type{Name: name, Kind: Kind(kind), Size: uint64(size)}
+	p.types[off] = t // before describing the elements of recursive types
+	switch n.Tag {
+	case dwarf.TagBaseType:
+		if t.Kind == Invalid {
+			t.Kind = baseKind(n, t.Size)
+		}
+	case dwarf.TagPointerType:
+		if t.Kind == Invalid {
+			t.Kind = Ptr
+		}
+		t.Size = p.ptrSize
+		if under != 0 {
+			t.Elem = p.typ(under)
+		}
+	case dwarf.TagStructType:
+		if t.Kind == Invalid {
+			t.Kind = Struct
+		}
+		for _, c := range n.children {
+			if c.Tag != dwarf.TagMember {
+				continue
+			}
+			f := &StructField{}
+			f.Name, _ = c.Val(dwarf.AttrName).(string)
+			loc, _ := c.Val(dwarf.AttrDataMemberLoc).(int64)
+			f.Offset = uint64(loc)
+			if foff, ok := c.Val(dwarf.AttrType).(dwarf.Offset); ok {
+				f.Type = p.typ(foff)
+			}
+			f.Embedded, _ = c.Val(attrGoEmbeddedField).(bool)
+			t.Fields = append(t.Fields, f)
+		}
+		if t.Kind == Slice && len(t.Fields) > 0 && t.Fields[0].Type != nil {
+			t.Elem = t.Fields[0].Type.Elem
+		}
+	case dwarf.TagArrayType:
+		t.Kind = Array
+		t.Elem = p.typ(under)
+		for _, c := range n.children {
+			if c.Tag != dwarf.TagSubrangeType {
+				continue
+			}
+			if n, ok := c.Val(dwarf.AttrCount).(int64); ok {
+				t.Len = uint64(n)
+			} else if n, ok := c.Val(dwarf.AttrUpperBound).(int64); ok {
+				t.Len = uint64(n + 1)
+			}
+		}
+	case dwarf.TagTypedef:
+		// An interface, map, channel or function,
+		// described by its representation.
+		u := p.typ(under)
+		if u == nil {
+			break
+		}
+		t.Size = u.Size
+		switch t.Kind {
+		case Interface:
+			t.Fields = u.Fields
+		case Map, Chan:
+			t.Elem = u.Elem
+		}
+	case dwarf.TagSubroutineType:
+		t.Kind = Func
+		t.Size = p.ptrSize
+	default:
+		t.Kind = Invalid
+	}
+	return t
+}
+
+func baseKind(n *die, size uint64) Kind {
+	enc, _ := n.Val(dwarf.AttrEncoding).(int64)
+	switch enc {
+	case encBoolean:
+		return Bool
+	case encSigned:
+		return sizedKind(size, Int8, Int16, Int32, Int64)
+	case encUnsigned:
+		return sizedKind(size, Uint8, Uint16, Uint32, Uint64)
+	case encFloat:
+		if size == 4 {
+			return Float32
+		}
+		return Float64
+	case encComplexFloat:
+		if size == 8 {
+			return Complex64
+		}
+		return Complex128
+	}
+	return Invalid
+}
+
+// sizedKind returns the kind of the given size among
+// the kinds of sizes 1, 2, 4 and 8.
+func sizedKind(size uint64, k1, k2, k4, k8 Kind) Kind {
+	switch size {
+	case 1:
+		return k1
+	case 2:
+		return k2
+	case 4:
+		return k4
+	case 8:
+		return k8
+	}
+	return Invalid
+}
+
+// word decodes a pointer-sized word.
+func (p *program) word(b []byte) uint64 {
+	if p.ptrSize == 4 {
+		return uint64(p.byteOrder.Uint32(b))
+	}
+	return p.byteOrder.Uint64(b)
+}
+
+// location returns the location expression of v at pc.
+func (p *program) location(v *funcVar, pc uint64) []byte {
+	if v.list < 0 {
+		return v.loc
+	}
+	if v.list >= int64(len(p.loc)) {
+		return nil
+	}
+	b := p.loc[v.list:]
+	base := v.base
+	max := ^uint64(0) >> (64 - 8*p.ptrSize)
+	for uint64(len(b)) >= 2*p.ptrSize {
+		lo, hi := p.word(b), p.word(b[p.ptrSize:])
+		b = b[2*p.ptrSize:]
+		switch {
+		case lo == 0 && hi == 0:
+			return nil
+		case lo == max:
+			base = hi
+			continue
+		}
+		if len(b) < 2 {
+			return nil
+		}
+		n := int(p.byteOrder.Uint16(b))
+		b = b[2:]
+		if len(b) < n {
+			return nil
+		}
+		if base+lo <= pc && pc < base+hi {
+			return b[:n]
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// frameOffset returns the offset from the canonical frame address
+// of a variable of the given size whose location is loc, and whether
+// the variable is entirely in memory in the frame.
+func frameOffset(loc []byte, size uint64) (int64, bool) {
+	var off int64
+	switch {
+	case len(loc) > 0 && loc[0] == opCallFrameCFA:
+		loc = loc[1:]
+	case len(loc) > 1 && loc[0] == opFbreg:
+		v, n := sleb128(loc[1:])
+		if n == 0 {
+			return 0, false
+		}
+		off, loc = v, loc[1+n:]
+	default:
+		return 0, false
+	}
+	if len(loc) == 0 {
+		return off, true
+	}
+	// A single piece covering the whole variable.
+	if loc[0] == opPiece {
+		if v, n := uleb128(loc[1:]); n == len(loc)-1 && v == size {
+			return off, true
+		}
+	}
+	return 0, false
+}
+
+func uleb128(b []byte) (uint64, int) {
+	var v uint64
+	for i, c := range b {
+		if i == 10 {
+			break
+		}
+		v |= uint64(c&0x7f) << (7 * i)
+		if c&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+func sleb128(b []byte) (int64, int) {
+	var v int64
+	for i, c := range b {
+		if i == 10 {
+			break
+		}
+		v |= int64(c&0x7f) << (7 * i)
+		if c&0x80 == 0 {
+			if shift := 7 * (i + 1); shift < 64 && c&0x40 != 0 {
+				v |= -1 << shift
+			}
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package heapdump
+
+import "strconv"
+
+// A Kind is the kind of a type. Its values are those of reflect.Kind.
+type Kind uint8
+
+const (
+	Invalid Kind = iota
+	Bool
+	Int
+	Int8
+	Int16
+	Int32
+	Int64
+	Uint
+	Uint8
+	Uint16
+	Uint32
+	Uint64
+	Uintptr
+	Float32
+	Float64
+	Complex64
+	Complex128
+	Array
+	Chan
+	Func
+	Interface
+	Map
+	Ptr
+	Slice
+	String
+	Struct
+	UnsafePointer
+)
+
+var kindNames = []string{
+	Invalid:       "invalid",
+	Bool:          "bool",
+	Int:           "int",
+	Int8:          "int8",
+	Int16:         "int16",
+	Int32:         "int32",
+	Int64:         "int64",
+	Uint:          "uint",
+	Uint8:         "uint8",
+	Uint16:        "uint16",
+	Uint32:        "uint32",
+	Uint64:        "uint64",
+	Uintptr:       "uintptr",
+	Float32:       "float32",
+	Float64:       "float64",
+	Complex64:     "complex64",
+	Complex128:    "complex128",
+	Array:         "array",
+	Chan:          "chan",
+	Func:          "func",
+	Interface:     "interface",
+	Map:           "map",
+	Ptr:           "ptr",
+	Slice:         "slice",
+	String:        "string",
+	Struct:        "struct",
+	UnsafePointer: "unsafe.Pointer",
+}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "kind" + strconv.Itoa(int(k))
+}
+
+// A Type is a Go type, as described by the debug information
+// of an executable.
+//
+// Strings, slices and interfaces are described as the structures
+// that represent them: a string has the fields str and len,
+// a slice the fields array, len and cap, an empty interface the
+// fields _type and data, and a non-empty interface the fields tab
+// and data. Maps and channels are pointers to the runtime structures
+// that represent them.
+type Type struct {
+	Name string
+	Kind Kind
+	Size uint64
+
+	// Elem is the element type of an array, pointer or slice,
+	// and the runtime structure a map or a channel points to.
+	// It's nil if unknown.
+	Elem *Type
+
+	// Len is the length of an array.
+	Len uint64
+
+	// Fields are the fields of a struct, string, slice or interface.
+	Fields []*StructField
+
+	direct bool     // stored directly in interfaces
+	ptrs   []uint64 // offsets of the pointers of a value, up to maxPtrs
+	done   bool     // direct and ptrs are computed
+}
+
+// A StructField is a field of a struct.
+type StructField struct {
+	Name     string
+	Offset   uint64
+	Type     *Type
+	Embedded bool
+}
+
+// IsEmptyInterface reports whether t is an empty interface.
+func (t *Type) IsEmptyInterface() bool {
+	return t.Kind == Interface && len(t.Fields) > 0 && t.Fields[0].Name == "_type"
+}
+
+func (t *Type) String() string {
+	return t.Name
+}
+
+// maxPtrs is the maximum number of pointer offsets that we record
+// for a type, to check objects against it.
+const maxPtrs = 64
+
+// layout computes whether t is stored directly in interfaces,
+// and the offsets of its first pointers.
+func (t *Type) layout(ptrSize uint64) {
+	if t.done {
+		return
+	}
+	t.done = true
+	switch t.Kind {
+	case Ptr, Map, Chan, Func, UnsafePointer:
+		t.direct = true
+		t.ptrs = []uint64{0}
+	case Array:
+		if t.Elem == nil {
+			return
+		}
+		t.Elem.layout(ptrSize)
+		t.direct = t.Len == 1 && t.Elem.direct
+		if len(t.Elem.ptrs) == 0 {
+			return
+		}
+		for i := uint64(0); i < t.Len && len(t.ptrs) < maxPtrs; i++ {
+			for _, off := range t.Elem.ptrs {
+				t.ptrs = append(t.ptrs, i*t.Elem.Size+off)
+			}
+		}
+	case Interface:
+		// The type word points to static data,
+		// only the data word is a pointer for the garbage collector.
+		t.ptrs = []uint64{ptrSize}
+	case Struct, String, Slice:
+		for _, f := range t.Fields {
+			if f.Type == nil {
+				continue
+			}
+			f.Type.layout(ptrSize)
+			for _, off := range f.Type.ptrs {
+				t.ptrs = append(t.ptrs, f.Offset+off)
+			}
+		}
+		t.direct = t.Kind == Struct && len(t.Fields) == 1 && t.Fields[0].Type != nil && t.Fields[0].Type.direct
+	}
+	if len(t.ptrs) > maxPtrs {
+		t.ptrs = t.ptrs[:maxPtrs]
+	}
+}
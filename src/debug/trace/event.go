@@ -0,0 +1,182 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// An EventKind is the kind of an Event.
+type EventKind uint8
+
+const (
+	EventBad EventKind = iota
+
+	// EventGoroutine is a goroutine state transition.
+	// Goroutine, From, To and Reason describe the transition.
+	EventGoroutine
+
+	// EventProcStart and EventProcStop indicate that P starts or stops
+	// running on a thread.
+	EventProcStart
+	EventProcStop
+
+	// EventRangeBegin and EventRangeEnd delimit a runtime activity,
+	// such as a garbage collection phase, named by Name. A range that
+	// is specific to a goroutine, such as a mark assist, has G set.
+	EventRangeBegin
+	EventRangeEnd
+
+	// EventTaskBegin and EventTaskEnd delimit a user task created with
+	// runtime/trace.NewTask. Task, Parent and Name describe the task.
+	EventTaskBegin
+	EventTaskEnd
+
+	// EventRegionBegin and EventRegionEnd delimit a user region in
+	// goroutine G, created with runtime/trace.WithRegion or
+	// runtime/trace.StartRegion. Task and Name describe the region.
+	EventRegionBegin
+	EventRegionEnd
+
+	// EventLog is a user log message recorded with runtime/trace.Log.
+	// Task, Category and Message describe the message.
+	EventLog
+
+	// EventMetric is a new sample of the runtime metric Name,
+	// using the naming scheme of runtime/metrics. Value is the sample.
+	EventMetric
+)
+
+var eventKindStrings = [...]string{
+	EventBad:         "Bad",
+	EventGoroutine:   "Goroutine",
+	EventProcStart:   "ProcStart",
+	EventProcStop:    "ProcStop",
+	EventRangeBegin:  "RangeBegin",
+	EventRangeEnd:    "RangeEnd",
+	EventTaskBegin:   "TaskBegin",
+	EventTaskEnd:     "TaskEnd",
+	EventRegionBegin: "RegionBegin",
+	EventRegionEnd:   "RegionEnd",
+	EventLog:         "Log",
+	EventMetric:      "Metric",
+}
+
+func (k EventKind) String() string {
+	if int(k) < len(eventKindStrings) {
+		return eventKindStrings[k]
+	}
+	return fmt.Sprintf("EventKind(%d)", k)
+}
+
+// A GoState is the state of a goroutine.
+type GoState uint8
+
+const (
+	GoNotExist GoState = iota // not created yet, or exited
+	GoRunnable                // ready to run, waiting for a P
+	GoRunning                 // running on a P
+	GoWaiting                 // blocked, e.g. on a channel, a lock or the network
+	GoSyscall                 // in a system call
+)
+
+var goStateStrings = [...]string{
+	GoNotExist: "NotExist",
+	GoRunnable: "Runnable",
+	GoRunning:  "Running",
+	GoWaiting:  "Waiting",
+	GoSyscall:  "Syscall",
+}
+
+func (s GoState) String() string {
+	if int(s) < len(goStateStrings) {
+		return goStateStrings[s]
+	}
+	return fmt.Sprintf("GoState(%d)", s)
+}
+
+// A Frame is a frame of a stack trace.
+type Frame struct {
+	PC   uint64
+	Func string
+	File string
+	Line int
+}
+
+// An Event is an event in an execution trace.
+//
+// The fields that are meaningful depend on Kind; the others are zero.
+type Event struct {
+	Kind EventKind
+
+	// Time is the time of the event, relative to the start of the trace.
+	Time time.Duration
+
+	// G is the goroutine running when the event happened,
+	// or 0 if there is none or it is not known.
+	G uint64
+
+	// P is the P on which the event happened, or -1 if the event
+	// did not happen on a P, such as a goroutine unblocked by the
+	// network poller or a timer.
+	P int
+
+	// Stack is the stack trace of the event, if any.
+	Stack []Frame
+
+	// Goroutine, From and To describe a goroutine state transition.
+	// Reason tells why a goroutine blocks or stops running, when known,
+	// and holds the label of goroutines that run GC work.
+	Goroutine uint64
+	From, To  GoState
+	Reason    string
+
+	// Task is the ID of the task of a task, region or log event.
+	// Parent is the ID of the parent of a task.
+	Task   uint64
+	Parent uint64
+
+	// Name is the name of a task, region, range or metric.
+	Name string
+
+	// Category and Message are the contents of a log event.
+	Category string
+	Message  string
+
+	// Value is the value of a metric sample.
+	Value uint64
+}
+
+// String returns a short description of the event, for debugging.
+func (ev *Event) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v %v", ev.Time, ev.Kind)
+	if ev.P >= 0 {
+		fmt.Fprintf(&b, " P=%d", ev.P)
+	}
+	if ev.G != 0 {
+		fmt.Fprintf(&b, " G=%d", ev.G)
+	}
+	switch ev.Kind {
+	case EventGoroutine:
+		fmt.Fprintf(&b, " goroutine=%d %v->%v", ev.Goroutine, ev.From, ev.To)
+		if ev.Reason != "" {
+			fmt.Fprintf(&b, " reason=%q", ev.Reason)
+		}
+	case EventRangeBegin, EventRangeEnd:
+		fmt.Fprintf(&b, " name=%q", ev.Name)
+	case EventTaskBegin, EventTaskEnd:
+		fmt.Fprintf(&b, " task=%d parent=%d name=%q", ev.Task, ev.Parent, ev.Name)
+	case EventRegionBegin, EventRegionEnd:
+		fmt.Fprintf(&b, " task=%d name=%q", ev.Task, ev.Name)
+	case EventLog:
+		fmt.Fprintf(&b, " task=%d category=%q message=%q", ev.Task, ev.Category, ev.Message)
+	case EventMetric:
+		fmt.Fprintf(&b, " name=%q value=%d", ev.Name, ev.Value)
+	}
+	return b.String()
+}
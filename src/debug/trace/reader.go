@@ -0,0 +1,240 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package trace implements reading of the execution traces
+// produced by the runtime/trace package.
+//
+// A Reader presents a trace as a sequence of events in time order:
+// goroutine state transitions, Ps starting and stopping, garbage
+// collection phases, samples of some runtime metrics, and the tasks,
+// regions and log messages of the runtime/trace user annotation API.
+//
+// The Reader validates and orders the whole trace when it is created,
+// so NewReader reads all of its input before returning.
+package trace
+
+import (
+	"internal/trace"
+	"io"
+	"time"
+)
+
+// A Reader reads the events of an execution trace.
+type Reader struct {
+	events []*trace.Event
+	next   int
+	queue  []Event
+
+	stacks   map[uint64][]Frame // converted stacks, by stack ID
+	tasks    map[uint64]task    // tasks that have begun, by ID
+	stw      string             // name of the stop-the-world range in progress
+	blocking map[*trace.Event]bool
+}
+
+type task struct {
+	parent uint64
+	name   string
+}
+
+// NewReader reads and validates the execution trace in r,
+// and returns a Reader for its events.
+func NewReader(r io.Reader) (*Reader, error) {
+	res, err := trace.Parse(r, "")
+	if err != nil {
+		return nil, err
+	}
+	rd := &Reader{
+		events:   res.Events,
+		stacks:   make(map[uint64][]Frame),
+		tasks:    make(map[uint64]task),
+		blocking: blockingSyscalls(res.Events),
+	}
+	return rd, nil
+}
+
+// ReadEvent returns the next event of the trace.
+// At the end of the trace, it returns io.EOF.
+func (r *Reader) ReadEvent() (Event, error) {
+	for len(r.queue) == 0 {
+		if r.next >= len(r.events) {
+			return Event{}, io.EOF
+		}
+		ev := r.events[r.next]
+		r.next++
+		r.translate(ev)
+	}
+	ev := r.queue[0]
+	r.queue = r.queue[1:]
+	return ev, nil
+}
+
+// blockingSyscalls returns the set of syscall events after which the
+// goroutine blocked in the system call and lost its P.
+func blockingSyscalls(events []*trace.Event) map[*trace.Event]bool {
+	blocking := make(map[*trace.Event]bool)
+	inSyscall := make(map[uint64]*trace.Event)
+	for _, ev := range events {
+		switch ev.Type {
+		case trace.EvGoSysCall:
+			inSyscall[ev.G] = ev
+		case trace.EvGoSysBlock:
+			if sys := inSyscall[ev.G]; sys != nil {
+				blocking[sys] = true
+			}
+			delete(inSyscall, ev.G)
+		default:
+			delete(inSyscall, ev.G)
+		}
+	}
+	return blocking
+}
+
+// blockReasons are the reasons goroutines block for, by event type.
+var blockReasons = map[byte]string{
+	trace.EvGoStop:        "forever",
+	trace.EvGoSleep:       "sleep",
+	trace.EvGoBlock:       "blocked",
+	trace.EvGoBlockSend:   "chan send",
+	trace.EvGoBlockRecv:   "chan receive",
+	trace.EvGoBlockSelect: "select",
+	trace.EvGoBlockSync:   "sync",
+	trace.EvGoBlockCond:   "sync.Cond",
+	trace.EvGoBlockNet:    "network",
+	trace.EvGoBlockGC:     "GC mark assist wait",
+}
+
+// translate appends the events that correspond
+// to the internal event ev to the queue.
+func (r *Reader) translate(ev *trace.Event) {
+	out := Event{
+		Time:  time.Duration(ev.Ts),
+		G:     ev.G,
+		P:     ev.P,
+		Stack: r.stack(ev),
+	}
+	if out.P >= trace.FakeP {
+		out.P = -1
+	}
+	transition := func(g uint64, from, to GoState, reason string) {
+		out.Kind = EventGoroutine
+		out.Goroutine, out.From, out.To, out.Reason = g, from, to, reason
+		r.queue = append(r.queue, out)
+	}
+	rng := func(kind EventKind, name string) {
+		out.Kind, out.Name = kind, name
+		r.queue = append(r.queue, out)
+	}
+	metric := func(name string) {
+		out.Kind, out.Name, out.Value = EventMetric, name, ev.Args[0]
+		out.G, out.Stack = 0, nil
+		r.queue = append(r.queue, out)
+	}
+
+	switch ev.Type {
+	case trace.EvGoCreate:
+		transition(ev.Args[0], GoNotExist, GoRunnable, "")
+	case trace.EvGoStart, trace.EvGoStartLocal:
+		transition(ev.G, GoRunnable, GoRunning, "")
+	case trace.EvGoStartLabel:
+		transition(ev.G, GoRunnable, GoRunning, ev.SArgs[0])
+	case trace.EvGoEnd:
+		transition(ev.G, GoRunning, GoNotExist, "")
+	case trace.EvGoSched:
+		transition(ev.G, GoRunning, GoRunnable, "yield")
+	case trace.EvGoPreempt:
+		transition(ev.G, GoRunning, GoRunnable, "preempted")
+	case trace.EvGoStop, trace.EvGoSleep, trace.EvGoBlock, trace.EvGoBlockSend,
+		trace.EvGoBlockRecv, trace.EvGoBlockSelect, trace.EvGoBlockSync,
+		trace.EvGoBlockCond, trace.EvGoBlockNet, trace.EvGoBlockGC:
+		transition(ev.G, GoRunning, GoWaiting, blockReasons[ev.Type])
+	case trace.EvGoUnblock, trace.EvGoUnblockLocal:
+		transition(ev.Args[0], GoWaiting, GoRunnable, "")
+	case trace.EvGoSysCall:
+		transition(ev.G, GoRunning, GoSyscall, "")
+		if !r.blocking[ev] {
+			// The goroutine kept its P and returned
+			// without an event of its own.
+			out.Stack = nil
+			transition(ev.G, GoSyscall, GoRunning, "")
+		}
+	case trace.EvGoSysExit, trace.EvGoSysExitLocal:
+		out.G = 0
+		transition(ev.G, GoSyscall, GoRunnable, "")
+	case trace.EvGoWaiting:
+		out.G = 0
+		transition(ev.G, GoRunnable, GoWaiting, "")
+	case trace.EvGoInSyscall:
+		out.G = 0
+		transition(ev.G, GoRunnable, GoSyscall, "")
+
+	case trace.EvProcStart:
+		out.Kind = EventProcStart
+		r.queue = append(r.queue, out)
+	case trace.EvProcStop:
+		out.Kind = EventProcStop
+		r.queue = append(r.queue, out)
+
+	case trace.EvGCStart:
+		rng(EventRangeBegin, "GC concurrent mark phase")
+	case trace.EvGCDone:
+		rng(EventRangeEnd, "GC concurrent mark phase")
+	case trace.EvGCSTWStart:
+		r.stw = "stop-the-world (" + ev.SArgs[0] + ")"
+		rng(EventRangeBegin, r.stw)
+	case trace.EvGCSTWDone:
+		rng(EventRangeEnd, r.stw)
+	case trace.EvGCMarkAssistStart:
+		rng(EventRangeBegin, "GC mark assist")
+	case trace.EvGCMarkAssistDone:
+		rng(EventRangeEnd, "GC mark assist")
+	case trace.EvGCSweepStart:
+		rng(EventRangeBegin, "GC incremental sweep")
+	case trace.EvGCSweepDone:
+		rng(EventRangeEnd, "GC incremental sweep")
+
+	case trace.EvUserTaskCreate:
+		r.tasks[ev.Args[0]] = task{parent: ev.Args[1], name: ev.SArgs[0]}
+		out.Kind, out.Task, out.Parent, out.Name = EventTaskBegin, ev.Args[0], ev.Args[1], ev.SArgs[0]
+		r.queue = append(r.queue, out)
+	case trace.EvUserTaskEnd:
+		t := r.tasks[ev.Args[0]]
+		delete(r.tasks, ev.Args[0])
+		out.Kind, out.Task, out.Parent, out.Name = EventTaskEnd, ev.Args[0], t.parent, t.name
+		r.queue = append(r.queue, out)
+	case trace.EvUserRegion:
+		out.Kind, out.Task, out.Name = EventRegionBegin, ev.Args[0], ev.SArgs[0]
+		if ev.Args[1] == 1 {
+			out.Kind = EventRegionEnd
+		}
+		r.queue = append(r.queue, out)
+	case trace.EvUserLog:
+		out.Kind, out.Task, out.Category, out.Message = EventLog, ev.Args[0], ev.SArgs[0], ev.SArgs[1]
+		r.queue = append(r.queue, out)
+
+	case trace.EvHeapAlloc:
+		metric("/memory/classes/heap/objects:bytes")
+	case trace.EvNextGC:
+		metric("/gc/heap/goal:bytes")
+	case trace.EvGomaxprocs:
+		metric("/sched/gomaxprocs:threads")
+	}
+}
+
+// stack returns the stack trace of ev.
+func (r *Reader) stack(ev *trace.Event) []Frame {
+	if len(ev.Stk) == 0 {
+		return nil
+	}
+	if stk, ok := r.stacks[ev.StkID]; ok && ev.StkID != 0 {
+		return stk
+	}
+	stk := make([]Frame, len(ev.Stk))
+	for i, f := range ev.Stk {
+		stk[i] = Frame{PC: f.PC, Func: f.Fn, File: f.File, Line: f.Line}
+	}
+	if ev.StkID != 0 {
+		r.stacks[ev.StkID] = stk
+	}
+	return stk
+}
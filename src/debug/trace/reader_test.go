@@ -0,0 +1,122 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace_test
+
+import (
+	"bytes"
+	"context"
+	. "debug/trace"
+	"io"
+	"runtime"
+	rtrace "runtime/trace"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReader(t *testing.T) {
+	if rtrace.IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	var buf bytes.Buffer
+	if err := rtrace.Start(&buf); err != nil {
+		t.Fatal(err)
+	}
+	ctx, task := rtrace.NewTask(context.Background(), "reader")
+	var wg sync.WaitGroup
+	c := make(chan int)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rtrace.WithRegion(ctx, "recv", func() {
+			<-c
+		})
+	}()
+	time.Sleep(time.Millisecond)
+	c <- 1
+	wg.Wait()
+	rtrace.Log(ctx, "category", "message")
+	runtime.GC()
+	task.End()
+	rtrace.Stop()
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		prev                    time.Duration
+		states                  = make(map[uint64]GoState)
+		taskID                  uint64
+		begin, end, log, region bool
+		chanRecv, gc            bool
+	)
+	for {
+		ev, err := r.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Time < prev {
+			t.Fatalf("event %v is before the previous event at %v", &ev, prev)
+		}
+		prev = ev.Time
+		switch ev.Kind {
+		case EventGoroutine:
+			if from, ok := states[ev.Goroutine]; ok && from != ev.From {
+				t.Fatalf("event %v: goroutine was %v", &ev, from)
+			}
+			states[ev.Goroutine] = ev.To
+			if ev.Reason == "chan receive" {
+				chanRecv = true
+			}
+		case EventTaskBegin:
+			if ev.Name == "reader" {
+				taskID = ev.Task
+				begin = true
+			}
+		case EventTaskEnd:
+			if ev.Task == taskID && ev.Name == "reader" {
+				end = true
+			}
+		case EventRegionBegin:
+			if ev.Task == taskID && ev.Name == "recv" {
+				region = true
+			}
+		case EventLog:
+			if ev.Task == taskID && ev.Category == "category" && ev.Message == "message" {
+				log = true
+			}
+		case EventRangeBegin:
+			if strings.HasPrefix(ev.Name, "GC") {
+				gc = true
+			}
+		}
+	}
+	for _, check := range []struct {
+		ok   bool
+		what string
+	}{
+		{begin, "task begin"},
+		{end, "task end"},
+		{region, "region"},
+		{log, "log message"},
+		{chanRecv, "channel receive"},
+		{gc, "garbage collection"},
+	} {
+		if !check.ok {
+			t.Errorf("trace is missing the %s event", check.what)
+		}
+	}
+}
+
+func TestReaderBadInput(t *testing.T) {
+	if _, err := NewReader(strings.NewReader("go 1.17 trace\x00\x00\x00\x00\xff")); err == nil {
+		t.Error("NewReader succeeded on a bad trace")
+	}
+}
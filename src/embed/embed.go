@@ -92,6 +92,32 @@
 //
 // If any patterns are invalid or have invalid matches, the build will fail.
 //
+// Options
+//
+// A //go:embed directive for a variable of type FS can begin with options,
+// which apply to the files matched by the patterns on the same line.
+// The options are:
+//
+//	-compress  store the files compressed. They are decompressed
+//	           transparently when read. Files that do not shrink
+//	           when compressed are stored as they are.
+//	-modtime   record the modification time of each file at build time,
+//	           to be reported by its fs.FileInfo.
+//
+// For example, to embed a web site that http.FileServer serves
+// with Last-Modified headers:
+//
+//	//go:embed -compress -modtime static
+//	var static embed.FS
+//
+// Options are unquoted and precede the patterns.
+// A pattern named -compress or -modtime must be quoted.
+//
+// Compressed files take less space in the binary but longer to read:
+// an open compressed file is decompressed in full when first read.
+// Recording modification times makes the build depend on the file system,
+// not just the contents of the files.
+//
 // Strings and Bytes
 //
 // The //go:embed line for a variable of type string or []byte can have only a single pattern,
@@ -214,9 +240,11 @@ var (
 type file struct {
 	// The compiler knows the layout of this struct.
 	// See cmd/compile/internal/gc's initEmbed.
-	name string
-	data string
-	hash [16]byte // truncated SHA256 hash
+	name    string
+	data    string
+	hash    [16]byte // truncated SHA256 hash of the contents
+	size    int64    // size of the contents; if len(data) < size, data is DEFLATE-compressed
+	modTime int64    // modification time in Unix nanoseconds, or 0 if not recorded
 }
 
 var (
@@ -225,13 +253,22 @@ var (
 )
 
 func (f *file) Name() string               { _, elem, _ := split(f.name); return elem }
-func (f *file) Size() int64                { return int64(len(f.data)) }
-func (f *file) ModTime() time.Time         { return time.Time{} }
+func (f *file) Size() int64                { return f.size }
 func (f *file) IsDir() bool                { _, _, isDir := split(f.name); return isDir }
 func (f *file) Sys() interface{}           { return nil }
 func (f *file) Type() fs.FileMode          { return f.Mode().Type() }
 func (f *file) Info() (fs.FileInfo, error) { return f, nil }
 
+func (f *file) ModTime() time.Time {
+	if f.modTime == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, f.modTime)
+}
+
+// compressed reports whether the file's data is compressed.
+func (f *file) compressed() bool { return int64(len(f.data)) < f.size }
+
 func (f *file) Mode() fs.FileMode {
 	if f.IsDir() {
 		return fs.ModeDir | 0555
@@ -300,7 +337,7 @@ func (f FS) Open(name string) (fs.File, error) {
 	if file.IsDir() {
 		return &openDir{file, f.readDir(name), 0}, nil
 	}
-	return &openFile{file, 0}, nil
+	return &openFile{f: file}, nil
 }
 
 // ReadDir reads and returns the entire named directory.
@@ -330,26 +367,61 @@ func (f FS) ReadFile(name string) ([]byte, error) {
 	if !ok {
 		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
 	}
-	return []byte(ofile.f.data), nil
+	if !ofile.f.compressed() {
+		return []byte(ofile.f.data), nil
+	}
+	data, err := inflate(ofile.f.data, ofile.f.size)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+// Hash returns a hash of the contents of the named file: the first 16 bytes
+// of their SHA-256 checksum. Since it depends only on the contents, the hash
+// is stable across builds and can serve, for example, as an HTTP entity tag.
+func (f FS) Hash(name string) ([16]byte, error) {
+	file := f.lookup(name)
+	if file == nil {
+		return [16]byte{}, &fs.PathError{Op: "hash", Path: name, Err: fs.ErrNotExist}
+	}
+	if file.IsDir() {
+		return [16]byte{}, &fs.PathError{Op: "hash", Path: name, Err: errors.New("is a directory")}
+	}
+	return file.hash, nil
 }
 
 // An openFile is a regular file open for reading.
 type openFile struct {
-	f      *file // the file itself
-	offset int64 // current read offset
+	f      *file  // the file itself
+	offset int64  // current read offset
+	data   []byte // for a compressed file, the contents once read
 }
 
 func (f *openFile) Close() error               { return nil }
 func (f *openFile) Stat() (fs.FileInfo, error) { return f.f, nil }
 
 func (f *openFile) Read(b []byte) (int, error) {
-	if f.offset >= int64(len(f.f.data)) {
+	if f.offset >= f.f.size {
 		return 0, io.EOF
 	}
 	if f.offset < 0 {
 		return 0, &fs.PathError{Op: "read", Path: f.f.name, Err: fs.ErrInvalid}
 	}
-	n := copy(b, f.f.data[f.offset:])
+	var n int
+	if f.f.compressed() {
+		// Decompress the whole file on the first read.
+		if f.data == nil {
+			data, err := inflate(f.f.data, f.f.size)
+			if err != nil {
+				return 0, &fs.PathError{Op: "read", Path: f.f.name, Err: err}
+			}
+			f.data = data
+		}
+		n = copy(b, f.data[f.offset:])
+	} else {
+		n = copy(b, f.f.data[f.offset:])
+	}
 	f.offset += int64(n)
 	return n, nil
 }
@@ -361,9 +433,9 @@ func (f *openFile) Seek(offset int64, whence int) (int64, error) {
 	case 1:
 		offset += f.offset
 	case 2:
-		offset += int64(len(f.f.data))
+		offset += f.f.size
 	}
-	if offset < 0 || offset > int64(len(f.f.data)) {
+	if offset < 0 || offset > f.f.size {
 		return 0, &fs.PathError{Op: "seek", Path: f.f.name, Err: fs.ErrInvalid}
 	}
 	f.offset = offset
@@ -0,0 +1,333 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package embed
+
+import (
+	"errors"
+	"io"
+)
+
+// This file holds a small DEFLATE (RFC 1951) decoder for files
+// stored with //go:embed -compress. It decodes a whole file at once,
+// which keeps it much simpler than compress/flate, so that programs
+// using package embed need not import compress/flate.
+
+var errCorrupt = errors.New("corrupt compressed data")
+
+const (
+	maxCodeBits = 15  // maximum bits in a code
+	maxLitCodes = 286 // number of literal/length codes
+	maxDistCode = 30  // number of distance codes
+)
+
+var (
+	lengthBase  = [29]uint16{3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31, 35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 258}
+	lengthExtra = [29]uint8{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0}
+	distBase    = [30]uint16{1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193, 257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577}
+	distExtra   = [30]uint8{0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13}
+
+	// order of the code length code lengths in a dynamic block header
+	codeLengthOrder = [19]uint8{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+)
+
+// A huffman is a canonical Huffman code.
+type huffman struct {
+	count  [maxCodeBits + 1]uint16 // number of codes of each length
+	symbol []uint16                // symbols ordered by code
+}
+
+// init builds the code with the given code lengths, indexed by symbol.
+// A length of 0 means the symbol is not used.
+func (h *huffman) init(lengths []uint8) error {
+	h.count = [maxCodeBits + 1]uint16{}
+	for _, l := range lengths {
+		h.count[l]++
+	}
+	left := 1
+	for l := 1; l <= maxCodeBits; l++ {
+		left = left<<1 - int(h.count[l])
+		if left < 0 {
+			return errCorrupt // more codes than fit in l bits
+		}
+	}
+	var offs [maxCodeBits + 1]uint16
+	for l := 1; l < maxCodeBits; l++ {
+		offs[l+1] = offs[l] + h.count[l]
+	}
+	h.symbol = make([]uint16, len(lengths))
+	for sym, l := range lengths {
+		if l != 0 {
+			h.symbol[offs[l]] = uint16(sym)
+			offs[l]++
+		}
+	}
+	return nil
+}
+
+// An inflater decodes a compressed file.
+type inflater struct {
+	in    string // compressed data
+	pos   int    // next byte of in to read
+	bits  uint32 // bits read from in but not yet used
+	nbits uint   // number of valid bits in bits
+	out   []byte // decompressed data, with the expected size as capacity
+}
+
+// inflate returns the decompressed form of in,
+// which must have the given size.
+func inflate(in string, size int64) ([]byte, error) {
+	f := &inflater{in: in, out: make([]byte, 0, size)}
+	for {
+		final, err := f.readBits(1)
+		if err != nil {
+			return nil, err
+		}
+		typ, err := f.readBits(2)
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case 0:
+			err = f.stored()
+		case 1:
+			err = f.fixed()
+		case 2:
+			err = f.dynamic()
+		default:
+			err = errCorrupt
+		}
+		if err != nil {
+			return nil, err
+		}
+		if final == 1 {
+			break
+		}
+	}
+	if len(f.out) != cap(f.out) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return f.out, nil
+}
+
+// readBits returns the next n bits of input, least significant first.
+func (f *inflater) readBits(n uint) (int, error) {
+	for f.nbits < n {
+		if f.pos >= len(f.in) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		f.bits |= uint32(f.in[f.pos]) << f.nbits
+		f.pos++
+		f.nbits += 8
+	}
+	v := int(f.bits & (1<<n - 1))
+	f.bits >>= n
+	f.nbits -= n
+	return v, nil
+}
+
+// decode reads and returns the next symbol in the code h.
+func (f *inflater) decode(h *huffman) (int, error) {
+	code, first, index := 0, 0, 0
+	for l := 1; l <= maxCodeBits; l++ {
+		b, err := f.readBits(1)
+		if err != nil {
+			return 0, err
+		}
+		code |= b
+		count := int(h.count[l])
+		if code-first < count {
+			return int(h.symbol[index+code-first]), nil
+		}
+		index += count
+		first = (first + count) << 1
+		code <<= 1
+	}
+	return 0, errCorrupt
+}
+
+// stored copies an uncompressed block.
+func (f *inflater) stored() error {
+	f.bits, f.nbits = 0, 0 // the block starts on a byte boundary
+	if len(f.in)-f.pos < 4 {
+		return io.ErrUnexpectedEOF
+	}
+	n := int(f.in[f.pos]) | int(f.in[f.pos+1])<<8
+	if n != ^(int(f.in[f.pos+2])|int(f.in[f.pos+3])<<8)&0xffff {
+		return errCorrupt
+	}
+	f.pos += 4
+	if len(f.in)-f.pos < n {
+		return io.ErrUnexpectedEOF
+	}
+	if cap(f.out)-len(f.out) < n {
+		return errCorrupt
+	}
+	f.out = append(f.out, f.in[f.pos:f.pos+n]...)
+	f.pos += n
+	return nil
+}
+
+// fixed decodes a block compressed with the fixed codes.
+func (f *inflater) fixed() error {
+	var lengths [288 + maxDistCode]uint8
+	for i := range lengths {
+		switch {
+		case i < 144:
+			lengths[i] = 8
+		case i < 256:
+			lengths[i] = 9
+		case i < 280:
+			lengths[i] = 7
+		default:
+			lengths[i] = 8
+		}
+	}
+	for i := 288; i < len(lengths); i++ {
+		lengths[i] = 5
+	}
+	var lit, dist huffman
+	lit.init(lengths[:288])
+	dist.init(lengths[288:])
+	return f.codes(&lit, &dist)
+}
+
+// dynamic decodes a block compressed with codes given in its header.
+func (f *inflater) dynamic() error {
+	nlen, err := f.readBits(5)
+	if err != nil {
+		return err
+	}
+	ndist, err := f.readBits(5)
+	if err != nil {
+		return err
+	}
+	ncode, err := f.readBits(4)
+	if err != nil {
+		return err
+	}
+	nlen += 257
+	ndist++
+	ncode += 4
+	if nlen > maxLitCodes || ndist > maxDistCode {
+		return errCorrupt
+	}
+
+	var lengths [maxLitCodes + maxDistCode]uint8
+	for i := 0; i < ncode; i++ {
+		l, err := f.readBits(3)
+		if err != nil {
+			return err
+		}
+		lengths[codeLengthOrder[i]] = uint8(l)
+	}
+	var lencode huffman
+	if err := lencode.init(lengths[:19]); err != nil {
+		return err
+	}
+	for i := 0; i < 19; i++ {
+		lengths[i] = 0
+	}
+
+	for i := 0; i < nlen+ndist; {
+		sym, err := f.decode(&lencode)
+		if err != nil {
+			return err
+		}
+		if sym < 16 {
+			lengths[i] = uint8(sym)
+			i++
+			continue
+		}
+		var l uint8
+		var rep int
+		switch sym {
+		case 16:
+			if i == 0 {
+				return errCorrupt
+			}
+			l = lengths[i-1]
+			rep, err = f.readBits(2)
+			rep += 3
+		case 17:
+			rep, err = f.readBits(3)
+			rep += 3
+		default:
+			rep, err = f.readBits(7)
+			rep += 11
+		}
+		if err != nil {
+			return err
+		}
+		if i+rep > nlen+ndist {
+			return errCorrupt
+		}
+		for ; rep > 0; rep-- {
+			lengths[i] = l
+			i++
+		}
+	}
+	if lengths[256] == 0 {
+		return errCorrupt // no end of block code
+	}
+
+	var lit, dist huffman
+	if err := lit.init(lengths[:nlen]); err != nil {
+		return err
+	}
+	if err := dist.init(lengths[nlen : nlen+ndist]); err != nil {
+		return err
+	}
+	return f.codes(&lit, &dist)
+}
+
+// codes decodes the data of a compressed block.
+func (f *inflater) codes(lit, dist *huffman) error {
+	for {
+		sym, err := f.decode(lit)
+		if err != nil {
+			return err
+		}
+		if sym < 256 {
+			if len(f.out) == cap(f.out) {
+				return errCorrupt
+			}
+			f.out = append(f.out, byte(sym))
+			continue
+		}
+		if sym == 256 {
+			return nil
+		}
+
+		sym -= 257
+		if sym >= len(lengthBase) {
+			return errCorrupt
+		}
+		n, err := f.readBits(uint(lengthExtra[sym]))
+		if err != nil {
+			return err
+		}
+		n += int(lengthBase[sym])
+
+		sym, err = f.decode(dist)
+		if err != nil {
+			return err
+		}
+		if sym >= len(distBase) {
+			return errCorrupt
+		}
+		d, err := f.readBits(uint(distExtra[sym]))
+		if err != nil {
+			return err
+		}
+		d += int(distBase[sym])
+
+		if d > len(f.out) || n > cap(f.out)-len(f.out) {
+			return errCorrupt
+		}
+		for ; n > 0; n-- {
+			f.out = append(f.out, f.out[len(f.out)-d])
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package embed
+
+import (
+	"bytes"
+	"compress/flate"
+	"math/rand"
+	"testing"
+)
+
+func TestInflate(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 100000)
+	rnd.Read(random)
+	text := bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog. "), 2000)
+	mixed := append(append([]byte(nil), text[:30000]...), random[:30000]...)
+
+	for _, data := range [][]byte{nil, []byte("a"), text, random, mixed} {
+		for _, level := range []int{flate.NoCompression, flate.BestSpeed, flate.DefaultCompression, flate.BestCompression, flate.HuffmanOnly} {
+			var buf bytes.Buffer
+			w, err := flate.NewWriter(&buf, level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write(data)
+			w.Close()
+			comp := buf.String()
+
+			got, err := inflate(comp, int64(len(data)))
+			if err != nil {
+				t.Errorf("inflate %d bytes compressed at level %d: %v", len(data), level, err)
+				continue
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("inflate %d bytes compressed at level %d: wrong data", len(data), level)
+			}
+
+			if len(data) == 0 {
+				continue
+			}
+			if _, err := inflate(comp[:len(comp)/2], int64(len(data))); err == nil {
+				t.Errorf("inflate of truncated data at level %d succeeded", level)
+			}
+			if _, err := inflate(comp, int64(len(data)-1)); err == nil {
+				t.Errorf("inflate with short size at level %d succeeded", level)
+			}
+			if _, err := inflate(comp, int64(len(data)+1)); err == nil {
+				t.Errorf("inflate with long size at level %d succeeded", level)
+			}
+		}
+	}
+}
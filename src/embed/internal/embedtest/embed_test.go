@@ -5,7 +5,10 @@
 package embedtest
 
 import (
+	"crypto/sha256"
 	"embed"
+	"io"
+	"os"
 	"reflect"
 	"testing"
 	"testing/fstest"
@@ -129,3 +132,91 @@ func TestUninitialized(t *testing.T) {
 		t.Errorf("in uninitialized embed.FS, . is not a directory")
 	}
 }
+
+//go:embed -compress testdata/*.txt
+var compressed embed.FS
+
+func TestCompress(t *testing.T) {
+	for _, name := range []string{"testdata/ascii.txt", "testdata/hello.txt"} {
+		want, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testFiles(t, compressed, name, string(want))
+
+		f, err := compressed.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != int64(len(want)) {
+			t.Errorf("%s: Size() = %d, want %d", name, info.Size(), len(want))
+		}
+		// Read backward.
+		rs := f.(io.ReadSeeker)
+		for off := len(want) - 5; off >= 0; off -= 500 {
+			if _, err := rs.Seek(int64(off), io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]byte, 5)
+			if _, err := io.ReadFull(rs, buf); err != nil || string(buf) != string(want[off:off+5]) {
+				t.Errorf("%s: read %q, %v at %d; want %q", name, buf, err, off, want[off:off+5])
+			}
+		}
+		f.Close()
+	}
+	if err := fstest.TestFS(compressed, "testdata/ascii.txt", "testdata/glass.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHash(t *testing.T) {
+	data, err := os.ReadFile("testdata/ascii.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	var want [16]byte
+	copy(want[:], sum[:])
+	for _, f := range []embed.FS{testDirAll, compressed} {
+		if h, err := f.Hash("testdata/ascii.txt"); err != nil || h != want {
+			t.Errorf("Hash = %x, %v; want %x", h, err, want)
+		}
+	}
+	if _, err := testDirAll.Hash("testdata"); err == nil {
+		t.Error("Hash of a directory succeeded")
+	}
+	if _, err := testDirAll.Hash("missing.txt"); err == nil {
+		t.Error("Hash of a missing file succeeded")
+	}
+}
+
+//go:embed -modtime testdata/hello.txt
+//go:embed testdata/glass.txt
+var modTimes embed.FS
+
+func TestModTime(t *testing.T) {
+	want, err := os.Stat("testdata/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := modTimes.Open("testdata/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := f.Stat()
+	if !info.ModTime().Equal(want.ModTime()) {
+		t.Errorf("hello.txt: ModTime() = %v, want %v", info.ModTime(), want.ModTime())
+	}
+	f, err = modTimes.Open("testdata/glass.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ = f.Stat()
+	if !info.ModTime().IsZero() {
+		t.Errorf("glass.txt: ModTime() = %v, want zero time", info.ModTime())
+	}
+}
@@ -129,3 +129,57 @@ func ExampleWriter_WriteAll() {
 	// Ken,Thompson,ken
 	// Robert,Griesemer,gri
 }
+
+func ExampleDecoder() {
+	in := `first_name,last_name,commits
+Rob,Pike,102
+Ken,Thompson,87
+`
+	type Person struct {
+		First   string `csv:"first_name"`
+		Last    string `csv:"last_name"`
+		Commits int    `csv:"commits"`
+	}
+	d := csv.NewDecoder(csv.NewReader(strings.NewReader(in)))
+
+	for {
+		var p Person
+		err := d.Decode(&p)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("%+v\n", p)
+	}
+	// Output:
+	// {First:Rob Last:Pike Commits:102}
+	// {First:Ken Last:Thompson Commits:87}
+}
+
+func ExampleEncoder() {
+	type Person struct {
+		First string `csv:"first_name"`
+		Last  string `csv:"last_name"`
+		Admin bool   `csv:"admin"`
+	}
+	w := csv.NewWriter(os.Stdout)
+	e := csv.NewEncoder(w)
+
+	for _, p := range []Person{{"Rob", "Pike", true}, {"Ken", "Thompson", false}} {
+		if err := e.Encode(p); err != nil {
+			log.Fatalln("error writing record to csv:", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// first_name,last_name,admin
+	// Rob,Pike,true
+	// Ken,Thompson,false
+}
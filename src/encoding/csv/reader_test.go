@@ -411,6 +411,26 @@ x,,,
 	}
 }
 
+func TestFieldPos(t *testing.T) {
+	r := NewReader(strings.NewReader("a,\"b\nc\",d\n  e,f\n"))
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+	want := [][][2]int{
+		{{1, 1}, {1, 3}, {2, 4}},
+		{{3, 3}, {3, 5}},
+	}
+	for _, w := range want {
+		if _, err := r.Read(); err != nil {
+			t.Fatal(err)
+		}
+		for i, pos := range w {
+			if line, col := r.FieldPos(i); line != pos[0] || col != pos[1] {
+				t.Errorf("FieldPos(%d) = %d:%d, want %d:%d", i, line, col, pos[0], pos[1])
+			}
+		}
+	}
+}
+
 // nTimes is an io.Reader which yields the string s n times.
 type nTimes struct {
 	s   string
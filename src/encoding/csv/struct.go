@@ -0,0 +1,453 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A FieldError describes a field that could not be
+// decoded into the struct field for its column.
+// Line and column numbers are 1-indexed, as reported by Reader.FieldPos.
+type FieldError struct {
+	Line   int          // Line where the field starts
+	Column int          // Column (byte index) where the field starts
+	Header string       // Header of the field's column
+	Value  string       // Field value
+	Type   reflect.Type // Type of the struct field
+	Err    error        // The actual error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("csv: cannot decode %q into %v (column %q) on line %d, column %d: %v", e.Value, e.Type, e.Header, e.Line, e.Column, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// A field is a struct field stored in a column.
+type field struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+// typeFields returns the fields of the struct type t that are stored in
+// columns, in the order of their columns in an Encoder's header.
+// As with Go's embedding rules, the fields of embedded structs are
+// promoted, and a name is dropped if it is ambiguous.
+func typeFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	all := appendFields(nil, t, nil, map[reflect.Type]bool{t: true})
+
+	depth := make(map[string]int) // name -> least depth
+	count := make(map[string]int) // name -> number of fields at least depth
+	for _, f := range all {
+		d, ok := depth[f.name]
+		switch {
+		case !ok || len(f.index) < d:
+			depth[f.name] = len(f.index)
+			count[f.name] = 1
+		case len(f.index) == d:
+			count[f.name]++
+		}
+	}
+	var fields []field
+	for _, f := range all {
+		if len(f.index) == depth[f.name] && count[f.name] == 1 {
+			fields = append(fields, f)
+		}
+	}
+	f, _ := fieldCache.LoadOrStore(t, fields)
+	return f.([]field)
+}
+
+func appendFields(fields []field, t reflect.Type, index []int, visited map[reflect.Type]bool) []field {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("csv")
+		if tag == "-" {
+			continue
+		}
+		if i := strings.Index(tag, ","); i >= 0 {
+			tag = tag[:i]
+		}
+		ft := sf.Type
+		if sf.Anonymous && tag == "" {
+			et := ft
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct && !implementsText(et) {
+				// Pointers to unexported struct types
+				// cannot be allocated when decoding.
+				if ft.Kind() == reflect.Ptr && sf.PkgPath != "" || visited[et] {
+					continue
+				}
+				visited[et] = true
+				fields = appendFields(fields, et, append(index[:len(index):len(index)], i), visited)
+				delete(visited, et)
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			continue // unexported
+		}
+		name := tag
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, field{
+			name:  name,
+			index: append(index[:len(index):len(index)], i),
+			typ:   ft,
+		})
+	}
+	return fields
+}
+
+// implementsText reports whether t or *t implements
+// encoding.TextMarshaler or encoding.TextUnmarshaler.
+func implementsText(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	return t.Implements(textMarshalerType) || pt.Implements(textMarshalerType) ||
+		t.Implements(textUnmarshalerType) || pt.Implements(textUnmarshalerType)
+}
+
+// checkType returns an error if values of type t cannot be
+// stored in a column using the given text interface.
+func checkType(t reflect.Type, text reflect.Type, name string) error {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Implements(text) || reflect.PtrTo(t).Implements(text) {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return nil
+	}
+	return fmt.Errorf("csv: unsupported type %v for column %q", t, name)
+}
+
+// A Decoder reads records from a Reader and stores them in structs.
+//
+// The first record read is the header, which names the columns.
+// Each struct field is stored from the column whose header matches the
+// field's name, preferring an exact match but accepting a case-insensitive
+// match. The name is the field name, or the name given in the field's
+// "csv" struct tag. A field with tag "-" is ignored, as are unexported
+// fields and fields with no matching column. The fields of embedded
+// structs are treated as if they were in the outer struct, following the
+// usual Go visibility rules.
+//
+// A field is decoded using the UnmarshalText method if the struct field
+// (or a pointer to it) implements encoding.TextUnmarshaler, and otherwise
+// according to its kind, which must be a string, boolean, integer or
+// floating-point kind, or a pointer to one of these. Numbers are parsed
+// with the strconv package, in base 10 for integers. An empty field sets
+// the struct field to its zero value.
+type Decoder struct {
+	r                      *Reader
+	header                 []string
+	err                    error // error reading the header
+	disallowUnknownColumns bool
+
+	typ     reflect.Type
+	columns []*field // columns[i] is the field stored from column i, or nil
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+func NewDecoder(r *Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// DisallowUnknownColumns causes Decode to return an error when the
+// header has a column that does not match a field of the destination struct.
+func (d *Decoder) DisallowUnknownColumns() { d.disallowUnknownColumns = true }
+
+// Header returns the header record, reading it if it has not been read.
+// If the input is empty, Header returns io.EOF.
+func (d *Decoder) Header() ([]string, error) {
+	if d.header == nil && d.err == nil {
+		record, err := d.r.Read()
+		if err != nil {
+			d.err = err
+		} else {
+			// Copy the record in case the Reader reuses it.
+			d.header = append([]string{}, record...)
+		}
+	}
+	return d.header, d.err
+}
+
+// Decode reads the next record and stores it in the struct pointed to by v.
+// If there are no records left to be read, Decode returns io.EOF.
+//
+// An error reading the record is returned as is, typically as a *ParseError.
+// An error decoding a field is returned as a *FieldError, after the
+// preceding fields have been stored.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("csv: Decode requires a non-nil pointer to a struct")
+	}
+	if _, err := d.Header(); err != nil {
+		return err
+	}
+	if err := d.bind(rv.Elem().Type()); err != nil {
+		return err
+	}
+	record, err := d.r.Read()
+	if err != nil {
+		return err
+	}
+	sv := rv.Elem()
+	for i, s := range record {
+		if i >= len(d.columns) || d.columns[i] == nil {
+			continue
+		}
+		f := d.columns[i]
+		if err := decodeField(fieldByIndex(sv, f.index), s); err != nil {
+			line, col := d.r.FieldPos(i)
+			if ne, ok := err.(*strconv.NumError); ok {
+				err = ne.Err
+			}
+			return &FieldError{Line: line, Column: col, Header: d.header[i], Value: s, Type: f.typ, Err: err}
+		}
+	}
+	return nil
+}
+
+// bind maps the header's columns to the fields of t.
+func (d *Decoder) bind(t reflect.Type) error {
+	if d.typ == t {
+		return nil
+	}
+	fields := typeFields(t)
+	columns := make([]*field, len(d.header))
+	used := make([]bool, len(fields))
+	// Exact matches take precedence over case-insensitive ones.
+	for _, fold := range []bool{false, true} {
+		for i, h := range d.header {
+			if columns[i] != nil {
+				continue
+			}
+			for j := range fields {
+				f := &fields[j]
+				if !used[j] && (f.name == h || fold && strings.EqualFold(f.name, h)) {
+					columns[i] = f
+					used[j] = true
+					break
+				}
+			}
+		}
+	}
+	for i, f := range columns {
+		if f == nil {
+			if d.disallowUnknownColumns {
+				return fmt.Errorf("csv: unknown column %q", d.header[i])
+			}
+			continue
+		}
+		if err := checkType(f.typ, textUnmarshalerType, d.header[i]); err != nil {
+			return err
+		}
+	}
+	d.typ = t
+	d.columns = columns
+	return nil
+}
+
+// fieldByIndex returns the nested field of v with the given index,
+// allocating nil embedded pointers.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func decodeField(v reflect.Value, s string) error {
+	if s == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	}
+	return nil
+}
+
+// An Encoder writes structs as records to a Writer.
+//
+// Before the first record, an Encoder writes a header naming the
+// columns. The columns and their names are those a Decoder would use
+// for the struct type, in the order of the struct fields.
+//
+// A field is encoded using the MarshalText method if the struct field
+// (or a pointer to it) implements encoding.TextMarshaler, and otherwise
+// according to its kind, as for a Decoder. Floating-point numbers are
+// written in the shortest representation that reads back exactly. A nil
+// pointer is written as an empty field.
+type Encoder struct {
+	w      *Writer
+	typ    reflect.Type
+	fields []field
+	record []string
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w *Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the struct v, or the struct pointed to by v, as a record,
+// preceded by the header if this is the first call. All calls must be
+// given values of the same struct type.
+//
+// As with Writer.Write, the record is buffered; the Writer's Flush method
+// must eventually be called.
+func (e *Encoder) Encode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errors.New("csv: Encode requires a struct or a non-nil pointer to a struct")
+	}
+	if e.typ == nil {
+		if err := e.writeHeader(rv.Type()); err != nil {
+			return err
+		}
+	} else if rv.Type() != e.typ {
+		return fmt.Errorf("csv: Encode of %v after %v", rv.Type(), e.typ)
+	}
+	if !rv.CanAddr() {
+		// Make the value addressable so that
+		// pointer methods of its fields can be called.
+		pv := reflect.New(rv.Type())
+		pv.Elem().Set(rv)
+		rv = pv.Elem()
+	}
+	for i, f := range e.fields {
+		s, err := encodeField(rv, f.index)
+		if err != nil {
+			return err
+		}
+		e.record[i] = s
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *Encoder) writeHeader(t reflect.Type) error {
+	fields := typeFields(t)
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		if err := checkType(f.typ, textMarshalerType, f.name); err != nil {
+			return err
+		}
+		header[i] = f.name
+	}
+	if err := e.w.Write(header); err != nil {
+		return err
+	}
+	e.typ = t
+	e.fields = fields
+	e.record = make([]string, len(fields))
+	return nil
+}
+
+func encodeField(v reflect.Value, index []int) (string, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return "", nil
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if m, ok := v.Addr().Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Base struct {
+	ID   int
+	Name string `csv:"name"`
+}
+
+type Extra struct {
+	Note string
+}
+
+type Record struct {
+	Base
+	*Extra
+	Score   float64
+	Active  bool      `csv:"active"`
+	Count   *uint8    `csv:"count"`
+	When    time.Time `csv:"when"`
+	Skipped string    `csv:"-"`
+	private int
+}
+
+func TestDecode(t *testing.T) {
+	const input = `id,name,score,active,count,when,Note,other
+1,"Rob",2.5,true,7,2021-02-03T04:05:06Z,a note,x
+2,Ken,,false,,,,y
+`
+	d := NewDecoder(NewReader(strings.NewReader(input)))
+	var got []Record
+	for {
+		var r Record
+		err := d.Decode(&r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		got = append(got, r)
+	}
+	seven := uint8(7)
+	want := []Record{{
+		Base:   Base{1, "Rob"},
+		Extra:  &Extra{"a note"},
+		Score:  2.5,
+		Active: true,
+		Count:  &seven,
+		When:   time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC),
+	}, {
+		Base:  Base{2, "Ken"},
+		Extra: &Extra{},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+	header, err := d.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"id", "name", "score", "active", "count", "when", "Note", "other"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("Header() = %q, want %q", header, want)
+	}
+}
+
+func TestDecodeExactMatchFirst(t *testing.T) {
+	type T struct {
+		A string
+		B string `csv:"a"`
+	}
+	d := NewDecoder(NewReader(strings.NewReader("A,a\n1,2\n")))
+	var v T
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != "1" || v.B != "2" {
+		t.Errorf("got %+v, want {A:1 B:2}", v)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	type T struct {
+		N    int8
+		When time.Time
+	}
+	tests := []struct {
+		input string
+		want  error
+	}{{
+		input: "N,When\n300,\n",
+		want: &FieldError{Line: 2, Column: 1, Header: "N", Value: "300",
+			Type: reflect.TypeOf(int8(0)), Err: strconv.ErrRange},
+	}, {
+		input: "When,N\n\n,\"a\nb\"\n",
+		want: &FieldError{Line: 3, Column: 2, Header: "N", Value: "a\nb",
+			Type: reflect.TypeOf(int8(0)), Err: strconv.ErrSyntax},
+	}, {
+		input: "N,When\n1,\"x\"y\n",
+		want:  &ParseError{StartLine: 2, Line: 2, Column: 4, Err: ErrQuote},
+	}}
+	for _, tt := range tests {
+		d := NewDecoder(NewReader(strings.NewReader(tt.input)))
+		var v T
+		err := d.Decode(&v)
+		if !reflect.DeepEqual(err, tt.want) {
+			t.Errorf("Decode(%q):\ngot  %v\nwant %v", tt.input, err, tt.want)
+		}
+	}
+
+	d := NewDecoder(NewReader(strings.NewReader("N,When\n1,yesterday\n")))
+	var v T
+	err := d.Decode(&v)
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Line != 2 || fe.Column != 3 || fe.Header != "When" {
+		t.Errorf("Decode: got %v, want FieldError for When at 2:3", err)
+	}
+	if v.N != 1 {
+		t.Errorf("preceding field N = %d, want 1", v.N)
+	}
+
+	d = NewDecoder(NewReader(strings.NewReader("N,M\n1,2\n")))
+	d.DisallowUnknownColumns()
+	if err := d.Decode(&v); err == nil || err.Error() != `csv: unknown column "M"` {
+		t.Errorf("Decode with unknown column: got %v", err)
+	}
+
+	d = NewDecoder(NewReader(strings.NewReader("C\n1\n")))
+	var bad struct{ C []int }
+	if err := d.Decode(&bad); err == nil || err.Error() != `csv: unsupported type []int for column "C"` {
+		t.Errorf("Decode into unsupported type: got %v", err)
+	}
+	if err := d.Decode(bad); err == nil {
+		t.Errorf("Decode into non-pointer: got nil error")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	e := NewEncoder(w)
+	seven := uint8(7)
+	records := []Record{{
+		Base:   Base{1, "Rob, Pike"},
+		Extra:  &Extra{`say "hi"`},
+		Score:  0.1,
+		Active: true,
+		Count:  &seven,
+		When:   time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC),
+	}, {
+		Base: Base{ID: 2},
+	}}
+	for i := range records {
+		if err := e.Encode(&records[i]); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+	}
+	if err := e.Encode(Base{}); err == nil {
+		t.Errorf("Encode of different type: got nil error")
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		t.Fatal(err)
+	}
+	const want = `ID,name,Note,Score,active,count,when
+1,"Rob, Pike","say ""hi""",0.1,true,7,2021-02-03T04:05:06Z
+2,,,0,false,,0001-01-01T00:00:00Z
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Decoding the output gives back the records.
+	d := NewDecoder(NewReader(&buf))
+	for i, want := range records {
+		var got Record
+		if err := d.Decode(&got); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if want.Extra == nil {
+			want.Extra = &Extra{}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("record %d: got %+v, want %+v", i, got, want)
+		}
+	}
+}
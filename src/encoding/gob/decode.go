@@ -1115,9 +1115,9 @@ func (dec *Decoder) compileDec(remoteId typeId, ut *userTypeInfo) (engine *decEn
 		}
 		ovfl := overflow(wireField.Name)
 		// Find the field of the local type with the same name.
-		localField, present := srt.FieldByName(wireField.Name)
+		localField, present := fieldByWireName(srt, wireField.Name)
 		// TODO(r): anonymous names
-		if !present || !isExported(wireField.Name) {
+		if !present {
 			op := dec.decIgnoreOpFor(wireField.Id, make(map[typeId]*decOp))
 			engine.instr[fieldnum] = decInstr{*op, fieldnum, nil, ovfl}
 			continue
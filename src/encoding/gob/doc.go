@@ -77,8 +77,15 @@ the destination variable must be able to represent the value or the decode
 operation will fail.
 
 Structs, arrays and slices are also supported. Structs encode and decode only
-exported fields. Strings and arrays of bytes are supported with a special,
-efficient representation (see below). When a slice is decoded, if the existing
+exported fields. A field is transmitted under its name unless its struct tag
+gives another name, as in `gob:"Name"`; a field with tag `gob:"-"` is not
+transmitted. Because fields are matched by the transmitted name, a tag lets a
+renamed field keep receiving data written under its old name:
+
+	struct { Surname string `gob:"LastName"` }	// receives field LastName
+
+Strings and arrays of bytes are supported with a special, efficient
+representation (see below). When a slice is decoded, if the existing
 slice has capacity the slice will be extended in place; if not, a new array is
 allocated. Regardless, the length of the resulting slice reports the number of
 elements decoded.
@@ -9,6 +9,7 @@ package gob
 import (
 	"encoding"
 	"encoding/binary"
+	"internal/fmtsort"
 	"math"
 	"math/bits"
 	"reflect"
@@ -368,6 +369,16 @@ func (enc *Encoder) encodeMap(b *encBuffer, mv reflect.Value, keyOp, elemOp encO
 	state := enc.newEncoderState(b)
 	state.fieldnum = -1
 	state.sendZero = true
+	if enc.sortMaps {
+		sorted := fmtsort.Sort(mv)
+		state.encodeUint(uint64(len(sorted.Key)))
+		for i, key := range sorted.Key {
+			encodeReflectValue(state, key, keyOp, keyIndir)
+			encodeReflectValue(state, sorted.Value[i], elemOp, elemIndir)
+		}
+		enc.freeEncoderState(state)
+		return
+	}
 	keys := mv.MapKeys()
 	state.encodeUint(uint64(len(keys)))
 	for _, key := range keys {
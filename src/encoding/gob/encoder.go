@@ -21,6 +21,8 @@ type Encoder struct {
 	countState *encoderState           // stage for writing counts
 	freeList   *encoderState           // list of free encoderStates; avoids reallocation
 	byteBuf    encBuffer               // buffer for top-level encoderState
+	schema     *Schema                 // if set, the types known to the receiver
+	sortMaps   bool                    // whether to send map entries in key order
 	err        error
 }
 
@@ -39,6 +41,16 @@ func NewEncoder(w io.Writer) *Encoder {
 	return enc
 }
 
+// SetDeterministic sets whether the Encoder sends the entries of maps
+// sorted by key, in the order used by package fmt, so that equal values
+// are always encoded identically. By default, map entries are sent in
+// iteration order, which is faster.
+func (enc *Encoder) SetDeterministic(on bool) {
+	enc.mutex.Lock()
+	defer enc.mutex.Unlock()
+	enc.sortMaps = on
+}
+
 // writer() returns the innermost writer the encoder is using
 func (enc *Encoder) writer() io.Writer {
 	return enc.w[len(enc.w)-1]
@@ -118,8 +130,8 @@ func (enc *Encoder) sendActualType(w io.Writer, state *encoderState, ut *userTyp
 	switch st := actual; st.Kind() {
 	case reflect.Struct:
 		for i := 0; i < st.NumField(); i++ {
-			if isExported(st.Field(i).Name) {
-				enc.sendType(w, state, st.Field(i).Type)
+			if f := st.Field(i); isSent(&f) {
+				enc.sendType(w, state, f.Type)
 			}
 		}
 	case reflect.Array, reflect.Slice:
@@ -186,6 +198,17 @@ func (enc *Encoder) sendTypeDescriptor(w io.Writer, state *encoderState, ut *use
 	if ut.externalEnc != 0 {
 		rt = ut.user
 	}
+	if _, alreadySent := enc.sent[rt]; !alreadySent && enc.schema != nil {
+		// The receiver knows the types from the schema.
+		id, err := enc.schema.idOf(rt)
+		if err != nil {
+			enc.setError(err)
+			return
+		}
+		enc.sent[rt] = id
+		enc.sent[ut.base] = id
+		return
+	}
 	if _, alreadySent := enc.sent[rt]; !alreadySent {
 		// No, so send it.
 		sent := enc.sendType(w, state, rt)
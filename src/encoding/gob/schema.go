@@ -0,0 +1,404 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gob
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// A Schema is a set of gob type descriptors: the definitions of the
+// struct, array, slice, map and marshaler types that an Encoder sends
+// ahead of the values that use them, each identified by a type id.
+//
+// A schema makes the types of persisted gob data explicit. It can be
+// computed from Go types with SchemaOf, recovered from a stream with
+// Decoder.Schema, printed, and stored with MarshalBinary. An Encoder
+// and a Decoder that are given the same schema with SetSchema exchange
+// values without any type descriptors, so the schema can be kept apart
+// from the data, for example once for many stored records. Because gob
+// matches struct fields by name, a Decoder can use the schema of data
+// written by an older version of a program to decode that data into the
+// current version of its types.
+type Schema struct {
+	types map[typeId]*wireType
+}
+
+// SchemaOf returns the schema of the types of the given values, which
+// must be encodable. The types are numbered in the order they are found,
+// starting with the first id available for user types, so the schema
+// depends only on the types, not on what else the program has encoded.
+func SchemaOf(values ...interface{}) (*Schema, error) {
+	s := &Schema{types: make(map[typeId]*wireType)}
+	b := &schemaBuilder{s: s, ids: make(map[typeId]typeId)}
+	for _, v := range values {
+		if v == nil {
+			return nil, errors.New("gob: SchemaOf of nil value")
+		}
+		if _, err := b.add(reflect.TypeOf(v)); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// A schemaBuilder adds the descriptors of Go types to a schema,
+// numbering them consecutively.
+type schemaBuilder struct {
+	s   *Schema
+	ids map[typeId]typeId // global id -> id in s
+}
+
+// add adds the descriptor of rt and those of the types it refers to,
+// and returns the id of rt in the schema.
+func (b *schemaBuilder) add(rt reflect.Type) (typeId, error) {
+	ut, err := validUserType(rt)
+	if err != nil {
+		return 0, err
+	}
+	if ut.externalEnc == 0 {
+		switch ut.base.Kind() {
+		case reflect.Chan, reflect.Func:
+			return 0, errors.New("gob: type not encodable: " + rt.String())
+		}
+	}
+	info, err := getTypeInfo(ut)
+	if err != nil {
+		return 0, err
+	}
+	if info.wire == nil {
+		// A basic type, []byte or an interface.
+		return info.id, nil
+	}
+	if id, ok := b.ids[info.id]; ok {
+		return id, nil
+	}
+	id := firstUserId + typeId(len(b.s.types))
+	b.ids[info.id] = id
+	b.s.types[id] = nil // reserve the id
+	w := info.wire
+	wire := new(wireType)
+	switch t := ut.base; {
+	case w.GobEncoderT != nil:
+		wire.GobEncoderT = &gobEncoderType{CommonType{w.GobEncoderT.Name, id}}
+	case w.BinaryMarshalerT != nil:
+		wire.BinaryMarshalerT = &gobEncoderType{CommonType{w.BinaryMarshalerT.Name, id}}
+	case w.TextMarshalerT != nil:
+		wire.TextMarshalerT = &gobEncoderType{CommonType{w.TextMarshalerT.Name, id}}
+	case w.ArrayT != nil:
+		elem, err := b.add(t.Elem())
+		if err != nil {
+			return 0, err
+		}
+		wire.ArrayT = &arrayType{CommonType{w.ArrayT.Name, id}, elem, w.ArrayT.Len}
+	case w.SliceT != nil:
+		elem, err := b.add(t.Elem())
+		if err != nil {
+			return 0, err
+		}
+		wire.SliceT = &sliceType{CommonType{w.SliceT.Name, id}, elem}
+	case w.MapT != nil:
+		key, err := b.add(t.Key())
+		if err != nil {
+			return 0, err
+		}
+		elem, err := b.add(t.Elem())
+		if err != nil {
+			return 0, err
+		}
+		wire.MapT = &mapType{CommonType{w.MapT.Name, id}, key, elem}
+	case w.StructT != nil:
+		st := &structType{CommonType: CommonType{w.StructT.Name, id}}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !isSent(&f) {
+				continue
+			}
+			fid, err := b.add(f.Type)
+			if err != nil {
+				return 0, err
+			}
+			st.Field = append(st.Field, &fieldType{wireName(&f), fid})
+		}
+		wire.StructT = st
+	}
+	b.s.types[id] = wire
+	return id, nil
+}
+
+// ids returns the type ids of s in increasing order.
+func (s *Schema) ids() []typeId {
+	ids := make([]typeId, 0, len(s.types))
+	for id := range s.types {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// idOf returns the id in s of the type that describes rt.
+func (s *Schema) idOf(rt reflect.Type) (typeId, error) {
+	t := &Schema{types: make(map[typeId]*wireType)}
+	id, err := (&schemaBuilder{s: t, ids: make(map[typeId]typeId)}).add(rt)
+	if err != nil || id < firstUserId {
+		return id, err
+	}
+	for _, sid := range s.ids() {
+		if sameType(t, id, s, sid, make(map[[2]typeId]bool)) {
+			return sid, nil
+		}
+	}
+	return 0, errors.New("gob: type not in schema: " + rt.String())
+}
+
+// sameType reports whether type aid in a has the same
+// description as type bid in b.
+func sameType(a *Schema, aid typeId, b *Schema, bid typeId, assumed map[[2]typeId]bool) bool {
+	if aid < firstUserId || bid < firstUserId {
+		return aid == bid
+	}
+	if assumed[[2]typeId{aid, bid}] {
+		// Recursive types: already being compared.
+		return true
+	}
+	assumed[[2]typeId{aid, bid}] = true
+	x, y := a.types[aid], b.types[bid]
+	if x == nil || y == nil || x.string() != y.string() {
+		return false
+	}
+	switch {
+	case x.ArrayT != nil:
+		return y.ArrayT != nil && x.ArrayT.Len == y.ArrayT.Len &&
+			sameType(a, x.ArrayT.Elem, b, y.ArrayT.Elem, assumed)
+	case x.SliceT != nil:
+		return y.SliceT != nil && sameType(a, x.SliceT.Elem, b, y.SliceT.Elem, assumed)
+	case x.MapT != nil:
+		return y.MapT != nil && sameType(a, x.MapT.Key, b, y.MapT.Key, assumed) &&
+			sameType(a, x.MapT.Elem, b, y.MapT.Elem, assumed)
+	case x.StructT != nil:
+		if y.StructT == nil || len(x.StructT.Field) != len(y.StructT.Field) {
+			return false
+		}
+		for i, f := range x.StructT.Field {
+			g := y.StructT.Field[i]
+			if f.Name != g.Name || !sameType(a, f.Id, b, g.Id, assumed) {
+				return false
+			}
+		}
+		return true
+	case x.GobEncoderT != nil:
+		return y.GobEncoderT != nil
+	case x.BinaryMarshalerT != nil:
+		return y.BinaryMarshalerT != nil
+	case x.TextMarshalerT != nil:
+		return y.TextMarshalerT != nil
+	}
+	return false
+}
+
+// check reports an error if s refers to a type it does not define.
+func (s *Schema) check() error {
+	ok := func(id typeId) bool {
+		if id < firstUserId {
+			_, builtin := builtinIdToType[id]
+			return builtin
+		}
+		return s.types[id] != nil
+	}
+	for _, id := range s.ids() {
+		w := s.types[id]
+		var refs []typeId
+		switch {
+		case w.ArrayT != nil:
+			refs = append(refs, w.ArrayT.Elem)
+		case w.SliceT != nil:
+			refs = append(refs, w.SliceT.Elem)
+		case w.MapT != nil:
+			refs = append(refs, w.MapT.Key, w.MapT.Elem)
+		case w.StructT != nil:
+			for _, f := range w.StructT.Field {
+				refs = append(refs, f.Id)
+			}
+		case w.GobEncoderT == nil && w.BinaryMarshalerT == nil && w.TextMarshalerT == nil:
+			return fmt.Errorf("gob: schema type %d is empty", id)
+		}
+		for _, ref := range refs {
+			if !ok(ref) {
+				return fmt.Errorf("gob: schema type %d refers to undefined type %d", id, ref)
+			}
+		}
+	}
+	return nil
+}
+
+// String returns a description of the types in s, one per line,
+// in order of their ids.
+func (s *Schema) String() string {
+	var b strings.Builder
+	for _, id := range s.ids() {
+		w := s.types[id]
+		if w.ArrayT != nil || w.SliceT != nil || w.MapT != nil {
+			fmt.Fprintf(&b, "%d %s\n", id, s.describe(w))
+		} else {
+			fmt.Fprintf(&b, "%d %s %s\n", id, w.string(), s.describe(w))
+		}
+	}
+	return b.String()
+}
+
+// name returns the name of type id in s. Arrays, slices and maps
+// are named by their description.
+func (s *Schema) name(id typeId) string {
+	if t, ok := builtinIdToType[id]; ok {
+		return t.name()
+	}
+	w := s.types[id]
+	if w == nil {
+		return fmt.Sprintf("<type %d>", id)
+	}
+	if w.ArrayT != nil || w.SliceT != nil || w.MapT != nil {
+		return s.describe(w)
+	}
+	return w.string()
+}
+
+// describe returns the definition of w.
+func (s *Schema) describe(w *wireType) string {
+	switch {
+	case w.ArrayT != nil:
+		return fmt.Sprintf("[%d]%s", w.ArrayT.Len, s.name(w.ArrayT.Elem))
+	case w.SliceT != nil:
+		return "[]" + s.name(w.SliceT.Elem)
+	case w.MapT != nil:
+		return fmt.Sprintf("map[%s]%s", s.name(w.MapT.Key), s.name(w.MapT.Elem))
+	case w.StructT != nil:
+		fields := make([]string, len(w.StructT.Field))
+		for i, f := range w.StructT.Field {
+			fields[i] = f.Name + " " + s.name(f.Id)
+		}
+		return "struct {" + strings.Join(fields, "; ") + "}"
+	case w.GobEncoderT != nil:
+		return "GobEncoder"
+	case w.BinaryMarshalerT != nil:
+		return "BinaryMarshaler"
+	case w.TextMarshalerT != nil:
+		return "TextMarshaler"
+	}
+	return "unknown type"
+}
+
+// MarshalBinary encodes s as a sequence of type definitions in the gob
+// stream format, so that it can be stored and later restored with
+// UnmarshalBinary.
+func (s *Schema) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, id := range s.ids() {
+		enc.byteBuf.Reset()
+		enc.byteBuf.Write(spaceForLength)
+		state := enc.newEncoderState(&enc.byteBuf)
+		state.encodeInt(-int64(id))
+		enc.encode(state.b, reflect.ValueOf(s.types[id]), wireTypeUserInfo)
+		if enc.err == nil {
+			enc.writeMessage(&buf, state.b)
+		}
+		enc.freeEncoderState(state)
+		if enc.err != nil {
+			return nil, enc.err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary sets s to the schema encoded in data by MarshalBinary.
+func (s *Schema) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	dec := NewDecoder(r)
+	for {
+		// Read the messages here rather than with recvMessage, so that a
+		// corrupt count cannot make readMessage allocate more than the
+		// data holds.
+		nbytes, _, err := decodeUintReader(r, dec.countBuf)
+		if err != nil {
+			dec.err = err
+			break
+		}
+		if nbytes > uint64(r.Len()) {
+			return errBadCount
+		}
+		dec.readMessage(int(nbytes))
+		if dec.err != nil {
+			return dec.err
+		}
+		id := typeId(dec.nextInt())
+		if dec.err == nil && id >= 0 {
+			dec.err = errors.New("gob: schema contains a value")
+		}
+		if dec.err == nil {
+			dec.recvType(-id)
+		}
+		if dec.err == nil && dec.buf.Len() > 0 {
+			dec.err = errors.New("gob: extra data in schema")
+		}
+		if dec.err != nil {
+			return dec.err
+		}
+	}
+	if dec.err != io.EOF {
+		return dec.err
+	}
+	t := &Schema{types: dec.wireType}
+	if err := t.check(); err != nil {
+		return err
+	}
+	*s = *t
+	return nil
+}
+
+// Schema returns the schema of the types the Decoder has received so far.
+func (dec *Decoder) Schema() *Schema {
+	dec.mutex.Lock()
+	defer dec.mutex.Unlock()
+	s := &Schema{types: make(map[typeId]*wireType, len(dec.wireType))}
+	for id, w := range dec.wireType {
+		s.types[id] = w
+	}
+	return s
+}
+
+// SetSchema adds the types in s to those the Decoder knows, as if
+// their descriptors had been received. It allows the decoder to read
+// values from a stream written by an Encoder given the same schema,
+// which carries no type descriptors. A stream that defines one of the
+// types in s again cannot be decoded.
+func (dec *Decoder) SetSchema(s *Schema) error {
+	dec.mutex.Lock()
+	defer dec.mutex.Unlock()
+	for id := range s.types {
+		if dec.wireType[id] != nil {
+			return fmt.Errorf("gob: SetSchema: type %d already defined", id)
+		}
+	}
+	for id, w := range s.types {
+		dec.wireType[id] = w
+	}
+	return nil
+}
+
+// SetSchema causes the Encoder to identify values by the type ids of s
+// and to send no type descriptors, leaving the receiver to learn them
+// from the schema, as with Decoder.SetSchema. Encoding a value whose type,
+// or the concrete type of an interface value within it, is not described
+// by s fails. SetSchema must be called before the first value is encoded.
+func (enc *Encoder) SetSchema(s *Schema) {
+	enc.mutex.Lock()
+	defer enc.mutex.Unlock()
+	enc.schema = s
+}
@@ -0,0 +1,269 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gob
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type SchemaPoint struct {
+	X, Y int
+}
+
+type SchemaShape struct {
+	Name   string
+	Points []SchemaPoint
+	Tags   map[string]bool
+	Next   *SchemaShape
+	Extra  interface{}
+	Scale  [2]float64
+}
+
+func TestSchemaOf(t *testing.T) {
+	s, err := SchemaOf(SchemaShape{}, &SchemaPoint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `64 SchemaShape struct {Name string; Points []SchemaPoint; Tags map[string]bool; Next SchemaShape; Extra interface; Scale [2]float}
+65 []SchemaPoint
+66 SchemaPoint struct {X int; Y int}
+67 map[string]bool
+68 [2]float
+`
+	if got := s.String(); got != want {
+		t.Errorf("SchemaOf:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Encoding other types first does not change the schema.
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(struct{ A, B []string }{}); err != nil {
+		t.Fatal(err)
+	}
+	s2, err := SchemaOf(SchemaShape{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s2.String(); got != want {
+		t.Errorf("SchemaOf after encoding:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := SchemaOf(make(chan int)); err == nil {
+		t.Errorf("SchemaOf(chan int): got nil error")
+	}
+}
+
+func TestSchemaMarshal(t *testing.T) {
+	s, err := SchemaOf(SchemaShape{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s2 Schema
+	if err := s2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if s.String() != s2.String() {
+		t.Errorf("round trip:\ngot:\n%s\nwant:\n%s", s2.String(), s.String())
+	}
+	if err := s2.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Errorf("UnmarshalBinary of truncated data: got nil error")
+	}
+	// A message count larger than the data must not be trusted.
+	huge := append([]byte{0xFC, 0x40, 0x00, 0x00, 0x00}, data...)
+	if err := s2.UnmarshalBinary(huge); err != errBadCount {
+		t.Errorf("UnmarshalBinary with corrupt count: got %v, want %v", err, errBadCount)
+	}
+
+	// A schema that does not define all the types it refers to.
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.Encode(SchemaPoint{}); err != nil {
+		t.Fatal(err)
+	}
+	var bad Schema
+	if err := bad.UnmarshalBinary(buf.Bytes()); err == nil || !strings.Contains(err.Error(), "contains a value") {
+		t.Errorf("UnmarshalBinary of value: got %v", err)
+	}
+}
+
+type SchemaV1 struct {
+	Name  string
+	Count int
+}
+
+type SchemaV2 struct {
+	Title string `gob:"Name"`
+	Count int64
+	Notes string
+}
+
+func TestSchemaEncodeDecode(t *testing.T) {
+	s, err := SchemaOf(SchemaV1{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plain, withSchema bytes.Buffer
+	if err := NewEncoder(&plain).Encode(SchemaV1{"a", 1}); err != nil {
+		t.Fatal(err)
+	}
+	enc := NewEncoder(&withSchema)
+	enc.SetSchema(s)
+	for _, v := range []SchemaV1{{"a", 1}, {"b", 2}} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Encode(SchemaPoint{}); err == nil || !strings.Contains(err.Error(), "not in schema") {
+		t.Errorf("Encode of type not in schema: got %v", err)
+	}
+	if withSchema.Len() >= plain.Len() {
+		t.Errorf("stream with schema has %d bytes for two values; want fewer than %d for one with type descriptors", withSchema.Len(), plain.Len())
+	}
+
+	// Store the schema and use it later to decode into a newer version of the type.
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var old Schema
+	if err := old.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	dec := NewDecoder(&withSchema)
+	if err := dec.SetSchema(&old); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []SchemaV2{{"a", 1, ""}, {"b", 2, ""}} {
+		var got SchemaV2
+		if err := dec.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+	if err := dec.SetSchema(&old); err == nil {
+		t.Errorf("second SetSchema: got nil error")
+	}
+
+	// Without the schema, the stream cannot be decoded.
+	if err := NewDecoder(bytes.NewReader(data)).Decode(new(SchemaV2)); err == nil {
+		t.Errorf("Decode of schema alone: got nil error")
+	}
+}
+
+func TestDecoderSchema(t *testing.T) {
+	var buf bytes.Buffer
+	in := SchemaShape{Name: "s", Points: []SchemaPoint{{1, 2}}}
+	if err := NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	dec := NewDecoder(&buf)
+	if err := dec.Decode(new(SchemaShape)); err != nil {
+		t.Fatal(err)
+	}
+	s := dec.Schema()
+	if !strings.Contains(s.String(), " SchemaPoint struct {X int; Y int}\n") {
+		t.Errorf("Decoder.Schema:\n%s", s)
+	}
+
+	// The exported schema, with the sender's ids, can be used to write more values.
+	buf.Reset()
+	enc := NewEncoder(&buf)
+	enc.SetSchema(s)
+	if err := enc.Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	dec = NewDecoder(&buf)
+	if err := dec.SetSchema(s); err != nil {
+		t.Fatal(err)
+	}
+	var out SchemaShape
+	if err := dec.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
+
+type TaggedFields struct {
+	A    int    `gob:"B"`
+	B    string `gob:"Old"`
+	Skip int    `gob:"-"`
+	C    int    `gob:"c,ignored"`
+}
+
+func TestFieldTags(t *testing.T) {
+	var buf bytes.Buffer
+	in := TaggedFields{A: 1, B: "two", Skip: 3, C: 4}
+	if err := NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	var out TaggedFields
+	if err := NewDecoder(bytes.NewReader(data)).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if want := (TaggedFields{A: 1, B: "two", C: 4}); out != want {
+		t.Errorf("got %+v, want %+v", out, want)
+	}
+
+	var other struct {
+		B   int
+		Old string
+		c   int
+		A   int
+	}
+	if err := NewDecoder(bytes.NewReader(data)).Decode(&other); err != nil {
+		t.Fatal(err)
+	}
+	if other.B != 1 || other.Old != "two" || other.A != 0 {
+		t.Errorf("decoded by wire name: got %+v", other)
+	}
+
+	var dup struct {
+		A int
+		B int `gob:"A"`
+	}
+	if err := NewEncoder(&buf).Encode(dup); err == nil || !strings.Contains(err.Error(), "duplicate field name A") {
+		t.Errorf("Encode with duplicate field names: got %v", err)
+	}
+}
+
+func TestSetDeterministic(t *testing.T) {
+	m := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		m[string(rune('a'+i%26))+strings.Repeat("x", i)] = i
+	}
+	encode := func() []byte {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetDeterministic(true)
+		if err := enc.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	first := encode()
+	for i := 0; i < 10; i++ {
+		if !bytes.Equal(encode(), first) {
+			t.Fatal("encodings of the same map differ")
+		}
+	}
+	var out map[string]int
+	if err := NewDecoder(bytes.NewReader(first)).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, m) {
+		t.Errorf("round trip of sorted map failed")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"unicode"
@@ -522,11 +523,18 @@ func newTypeObject(name string, ut *userTypeInfo, rt reflect.Type) (gobType, err
 		st := newStructType(name)
 		types[rt] = st
 		idToType[st.id()] = st
+		names := make(map[string]bool)
 		for i := 0; i < t.NumField(); i++ {
 			f := t.Field(i)
 			if !isSent(&f) {
 				continue
 			}
+			fname := wireName(&f)
+			if names[fname] {
+				err = errors.New("gob: duplicate field name " + fname + " in type " + rt.String())
+				return nil, err
+			}
+			names[fname] = true
 			typ := userType(f.Type).base
 			tname := typ.Name()
 			if tname == "" {
@@ -544,7 +552,7 @@ func newTypeObject(name string, ut *userTypeInfo, rt reflect.Type) (gobType, err
 			if gt.id() == 0 {
 				setTypeId(gt)
 			}
-			st.Field = append(st.Field, &fieldType{f.Name, gt.id()})
+			st.Field = append(st.Field, &fieldType{fname, gt.id()})
 		}
 		return st, nil
 
@@ -560,10 +568,10 @@ func isExported(name string) bool {
 }
 
 // isSent reports whether this struct field is to be transmitted.
-// It will be transmitted only if it is exported and not a chan or func field
-// or pointer to chan or func.
+// It will be transmitted only if it is exported, not tagged `gob:"-"`,
+// and not a chan or func field or pointer to chan or func.
 func isSent(field *reflect.StructField) bool {
-	if !isExported(field.Name) {
+	if !isExported(field.Name) || field.Tag.Get("gob") == "-" {
 		return false
 	}
 	// If the field is a chan or func or pointer thereto, don't send it.
@@ -578,6 +586,38 @@ func isSent(field *reflect.StructField) bool {
 	return true
 }
 
+// wireName returns the name under which the struct field is transmitted:
+// the name given in its gob struct tag, if any, or else the field name.
+func wireName(field *reflect.StructField) string {
+	name := field.Tag.Get("gob")
+	if i := strings.Index(name, ","); i >= 0 {
+		name = name[:i]
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// fieldByWireName returns the field of the struct type t that is
+// transmitted under the given name. A field promoted from an embedded
+// struct is found by its field name, as by reflect.Type.FieldByName.
+func fieldByWireName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if isSent(&f) && wireName(&f) == name {
+			return f, true
+		}
+	}
+	f, ok := t.FieldByName(name)
+	if !ok || len(f.Index) == 1 || !isExported(name) {
+		// A field of t itself with this name is either
+		// sent under another name or not sent at all.
+		return reflect.StructField{}, false
+	}
+	return f, true
+}
+
 // getBaseType returns the Gob type describing the given reflect.Type's base type.
 // typeLock must be held.
 func getBaseType(name string, rt reflect.Type) (gobType, error) {
@@ -42,9 +42,14 @@ import (
 //
 // To unmarshal JSON into a struct, Unmarshal matches incoming object
 // keys to the keys used by Marshal (either the struct field name or its tag),
-// preferring an exact match but also accepting a case-insensitive match. By
-// default, object keys which don't have a corresponding struct field are
-// ignored (see Decoder.DisallowUnknownFields for an alternative).
+// preferring an exact match but also accepting a case-insensitive match
+// (see Decoder.MatchCaseSensitive for an alternative). If the struct has a
+// map field tagged ",inline", object keys which don't have a corresponding
+// struct field are stored in that map, allocating it if it is nil.
+// Otherwise such keys are ignored by default (see
+// Decoder.DisallowUnknownFields for an alternative). If a key appears more
+// than once, the last value wins (see Decoder.DisallowDuplicateFields for
+// an alternative).
 //
 // To unmarshal JSON into an interface value,
 // Unmarshal stores one of these in the interface value:
@@ -79,9 +84,11 @@ import (
 // or if a JSON number overflows the target type, Unmarshal
 // skips that field and completes the unmarshaling as best it can.
 // If no more serious errors are encountered, Unmarshal returns
-// an UnmarshalTypeError describing the earliest such error. In any
-// case, it's not guaranteed that all the remaining fields following
-// the problematic one will be unmarshaled into the target object.
+// an UnmarshalTypeError describing the earliest such error, including
+// its location in the input (see Decoder.CollectErrors to report all
+// such errors). In any case, it's not guaranteed that all the remaining
+// fields following the problematic one will be unmarshaled into the
+// target object.
 //
 // The JSON null value unmarshals into an interface, map, pointer, or slice
 // by setting that Go value to nil. Because null is often used in JSON to mean
@@ -127,6 +134,9 @@ type UnmarshalTypeError struct {
 	Offset int64        // error occurred after reading Offset bytes
 	Struct string       // name of the struct type containing the field
 	Field  string       // the full path from root node to the field
+	Path   string       // JSON Pointer (RFC 6901) to the value in the input
+	Line   int          // 1-based line of the input at Offset
+	Column int          // 1-based byte column of the input at Offset
 }
 
 func (e *UnmarshalTypeError) Error() string {
@@ -136,6 +146,36 @@ func (e *UnmarshalTypeError) Error() string {
 	return "json: cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String()
 }
 
+// An UnknownFieldError describes a JSON object key that does not
+// correspond to any field of the destination struct. It is reported
+// only by a Decoder configured with DisallowUnknownFields.
+type UnknownFieldError struct {
+	Key    string // the object key
+	Offset int64  // the key starts after reading Offset bytes
+	Path   string // JSON Pointer (RFC 6901) to the object member in the input
+	Line   int    // 1-based line of the input at Offset
+	Column int    // 1-based byte column of the input at Offset
+}
+
+func (e *UnknownFieldError) Error() string {
+	return "json: unknown field " + strconv.Quote(e.Key)
+}
+
+// UnmarshalErrors is a list of errors, in input order, returned by a
+// Decoder configured with CollectErrors when decoding a value fails.
+type UnmarshalErrors []error
+
+// Error returns the first error and the number of further errors.
+func (e UnmarshalErrors) Error() string {
+	switch len(e) {
+	case 0:
+		return "no errors"
+	case 1:
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", e[0], len(e)-1)
+}
+
 // An UnmarshalFieldError describes a JSON object key that
 // led to an unexported (and therefore unwritable) struct field.
 //
@@ -179,7 +219,14 @@ func (d *decodeState) unmarshal(v interface{}) error {
 	// test must be applied at the top level of the value.
 	err := d.value(rv)
 	if err != nil {
-		return d.addErrorContext(err)
+		err = d.addErrorContext(err)
+		if d.collectErrors {
+			return append(d.savedErrors, err)
+		}
+		return err
+	}
+	if d.collectErrors && len(d.savedErrors) > 0 {
+		return d.savedErrors
 	}
 	return d.savedError
 }
@@ -210,9 +257,22 @@ type decodeState struct {
 		Struct     reflect.Type
 		FieldStack []string
 	}
-	savedError            error
-	useNumber             bool
-	disallowUnknownFields bool
+	path                    []pathElem // location of the current value
+	line, column            int        // position of data[0] in the input
+	savedError              error
+	savedErrors             UnmarshalErrors
+	useNumber               bool
+	disallowUnknownFields   bool
+	disallowDuplicateFields bool
+	caseSensitive           bool
+	collectErrors           bool
+}
+
+// A pathElem is an object key or, if key is nil, an array index
+// on the path from the top-level value to the current value.
+type pathElem struct {
+	key   []byte
+	index int
 }
 
 // readIndex returns the position of the last byte read.
@@ -228,7 +288,10 @@ const phasePanicMsg = "JSON decoder out of sync - data changing underfoot?"
 func (d *decodeState) init(data []byte) *decodeState {
 	d.data = data
 	d.off = 0
+	d.path = d.path[:0]
+	d.line, d.column = 1, 1
 	d.savedError = nil
+	d.savedErrors = nil
 	d.errorContext.Struct = nil
 
 	// Reuse the allocated space for the FieldStack slice.
@@ -238,25 +301,87 @@ func (d *decodeState) init(data []byte) *decodeState {
 
 // saveError saves the first err it is called with,
 // for reporting at the end of the unmarshal.
+// If d.collectErrors is set, it saves every err.
 func (d *decodeState) saveError(err error) {
+	if d.collectErrors {
+		d.savedErrors = append(d.savedErrors, d.addErrorContext(err))
+		return
+	}
 	if d.savedError == nil {
 		d.savedError = d.addErrorContext(err)
 	}
 }
 
 // addErrorContext returns a new error enhanced with information from d.errorContext
+// and the location of the current value.
 func (d *decodeState) addErrorContext(err error) error {
-	if d.errorContext.Struct != nil || len(d.errorContext.FieldStack) > 0 {
-		switch err := err.(type) {
-		case *UnmarshalTypeError:
+	switch err := err.(type) {
+	case *UnmarshalTypeError:
+		if d.errorContext.Struct != nil || len(d.errorContext.FieldStack) > 0 {
 			err.Struct = d.errorContext.Struct.Name()
 			err.Field = strings.Join(d.errorContext.FieldStack, ".")
-			return err
+		}
+		if err.Line == 0 {
+			err.Path = d.pointer()
+			err.Line, err.Column = d.position(err.Offset)
+		}
+	case *UnknownFieldError:
+		if err.Line == 0 {
+			err.Path = d.pointer()
+			err.Line, err.Column = d.position(err.Offset)
 		}
 	}
 	return err
 }
 
+// pushKey and pushIndex record that the decoder is descending into
+// the object member with the given key or the array element with the
+// given index. popPath undoes the most recent push.
+func (d *decodeState) pushKey(key []byte) { d.path = append(d.path, pathElem{key: key}) }
+func (d *decodeState) pushIndex(i int)    { d.path = append(d.path, pathElem{index: i}) }
+func (d *decodeState) popPath()           { d.path = d.path[:len(d.path)-1] }
+
+// pointer returns the JSON Pointer for the current value.
+func (d *decodeState) pointer() string {
+	var b []byte
+	for _, e := range d.path {
+		b = append(b, '/')
+		if e.key == nil {
+			b = strconv.AppendInt(b, int64(e.index), 10)
+			continue
+		}
+		for _, c := range e.key {
+			switch c {
+			case '~':
+				b = append(b, "~0"...)
+			case '/':
+				b = append(b, "~1"...)
+			default:
+				b = append(b, c)
+			}
+		}
+	}
+	return string(b)
+}
+
+// position returns the line and column in the input of the byte
+// at offset off in d.data.
+func (d *decodeState) position(off int64) (line, column int) {
+	if off > int64(len(d.data)) {
+		off = int64(len(d.data))
+	}
+	line, column = d.line, d.column
+	for _, c := range d.data[:off] {
+		if c == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
+
 // skip scans to the end of what was started.
 func (d *decodeState) skip() {
 	s, data, i := &d.scan, d.data, d.off
@@ -553,6 +678,7 @@ func (d *decodeState) array(v reflect.Value) error {
 			}
 		}
 
+		d.pushIndex(i)
 		if i < v.Len() {
 			// Decode into element.
 			if err := d.value(v.Index(i)); err != nil {
@@ -564,6 +690,7 @@ func (d *decodeState) array(v reflect.Value) error {
 				return err
 			}
 		}
+		d.popPath()
 		i++
 
 		// Next token must be , or ].
@@ -656,7 +783,8 @@ func (d *decodeState) object(v reflect.Value) error {
 		return nil
 	}
 
-	var mapElem reflect.Value
+	var mapElem, inlineMap reflect.Value
+	var seen map[string]bool
 	origErrorContext := d.errorContext
 
 	for {
@@ -678,10 +806,13 @@ func (d *decodeState) object(v reflect.Value) error {
 		if !ok {
 			panic(phasePanicMsg)
 		}
+		d.pushKey(key)
 
 		// Figure out field corresponding to key.
 		var subv reflect.Value
-		destring := false // whether the value is wrapped in a string to be decoded first
+		destring := false    // whether the value is wrapped in a string to be decoded first
+		var name string      // name used to detect duplicate keys, if not key itself
+		storeInline := false // whether subv is to be stored in inlineMap
 
 		if v.Kind() == reflect.Map {
 			elemType := t.Elem()
@@ -696,7 +827,7 @@ func (d *decodeState) object(v reflect.Value) error {
 			if i, ok := fields.nameIndex[string(key)]; ok {
 				// Found an exact name match.
 				f = &fields.list[i]
-			} else {
+			} else if !d.caseSensitive {
 				// Fall back to the expensive case-insensitive
 				// linear search.
 				for i := range fields.list {
@@ -708,35 +839,47 @@ func (d *decodeState) object(v reflect.Value) error {
 				}
 			}
 			if f != nil {
-				subv = v
+				name = f.name
 				destring = f.quoted
-				for _, i := range f.index {
-					if subv.Kind() == reflect.Ptr {
-						if subv.IsNil() {
-							// If a struct embeds a pointer to an unexported type,
-							// it is not possible to set a newly allocated value
-							// since the field is unexported.
-							//
-							// See https://golang.org/issue/21357
-							if !subv.CanSet() {
-								d.saveError(fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v", subv.Type().Elem()))
-								// Invalidate subv to ensure d.value(subv) skips over
-								// the JSON value without assigning it to subv.
-								subv = reflect.Value{}
-								destring = false
-								break
-							}
-							subv.Set(reflect.New(subv.Type().Elem()))
-						}
-						subv = subv.Elem()
-					}
-					subv = subv.Field(i)
+				subv = d.fieldByIndex(v, f.index)
+				if !subv.IsValid() {
+					destring = false
 				}
 				d.errorContext.FieldStack = append(d.errorContext.FieldStack, f.name)
 				d.errorContext.Struct = t
+			} else if fields.inline != nil {
+				if !inlineMap.IsValid() {
+					inlineMap = d.fieldByIndex(v, fields.inline.index)
+					if inlineMap.IsValid() && inlineMap.IsNil() {
+						inlineMap.Set(reflect.MakeMap(inlineMap.Type()))
+					}
+				}
+				if inlineMap.IsValid() {
+					elemType := inlineMap.Type().Elem()
+					if !mapElem.IsValid() {
+						mapElem = reflect.New(elemType).Elem()
+					} else {
+						mapElem.Set(reflect.Zero(elemType))
+					}
+					subv = mapElem
+					storeInline = true
+				}
 			} else if d.disallowUnknownFields {
-				d.saveError(fmt.Errorf("json: unknown field %q", key))
+				d.saveError(&UnknownFieldError{Key: string(key), Offset: int64(start)})
+			}
+		}
+
+		if d.disallowDuplicateFields {
+			if seen == nil {
+				seen = make(map[string]bool)
+			}
+			if name == "" {
+				name = string(key)
 			}
+			if seen[name] {
+				d.saveError(fmt.Errorf("json: duplicate field %q", key))
+			}
+			seen[name] = true
 		}
 
 		// Read : before value.
@@ -769,7 +912,9 @@ func (d *decodeState) object(v reflect.Value) error {
 
 		// Write value back to map;
 		// if using struct, subv points into struct already.
-		if v.Kind() == reflect.Map {
+		if storeInline {
+			inlineMap.SetMapIndex(reflect.ValueOf(string(key)).Convert(inlineMap.Type().Key()), subv)
+		} else if v.Kind() == reflect.Map {
 			kt := t.Key()
 			var kv reflect.Value
 			switch {
@@ -817,6 +962,7 @@ func (d *decodeState) object(v reflect.Value) error {
 		// space and avoid unnecessary allocs.
 		d.errorContext.FieldStack = d.errorContext.FieldStack[:len(origErrorContext.FieldStack)]
 		d.errorContext.Struct = origErrorContext.Struct
+		d.popPath()
 		if d.opcode == scanEndObject {
 			break
 		}
@@ -827,6 +973,32 @@ func (d *decodeState) object(v reflect.Value) error {
 	return nil
 }
 
+// fieldByIndex returns the nested field of the struct v selected by index,
+// allocating embedded struct pointers along the way. If an embedded
+// pointer cannot be set, it records an error and returns the zero Value,
+// which causes d.value to skip over the JSON value without assigning it.
+func (d *decodeState) fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				// If a struct embeds a pointer to an unexported type,
+				// it is not possible to set a newly allocated value
+				// since the field is unexported.
+				//
+				// See https://golang.org/issue/21357
+				if !v.CanSet() {
+					d.saveError(fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v", v.Type().Elem()))
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
 // convertNumber converts the number literal s to a float64 or a Number
 // depending on the setting of d.useNumber.
 func (d *decodeState) convertNumber(s string) (interface{}, error) {
@@ -1052,7 +1224,9 @@ func (d *decodeState) arrayInterface() []interface{} {
 			break
 		}
 
+		d.pushIndex(len(v))
 		v = append(v, d.valueInterface())
+		d.popPath()
 
 		// Next token must be , or ].
 		if d.opcode == scanSkipSpace {
@@ -1086,10 +1260,11 @@ func (d *decodeState) objectInterface() map[string]interface{} {
 		start := d.readIndex()
 		d.rescanLiteral()
 		item := d.data[start:d.readIndex()]
-		key, ok := unquote(item)
+		keyBytes, ok := unquoteBytes(item)
 		if !ok {
 			panic(phasePanicMsg)
 		}
+		key := string(keyBytes)
 
 		// Read : before value.
 		if d.opcode == scanSkipSpace {
@@ -1100,8 +1275,16 @@ func (d *decodeState) objectInterface() map[string]interface{} {
 		}
 		d.scanWhile(scanSkipSpace)
 
+		if d.disallowDuplicateFields {
+			if _, ok := m[key]; ok {
+				d.saveError(fmt.Errorf("json: duplicate field %q", key))
+			}
+		}
+
 		// Read value.
+		d.pushKey(keyBytes)
 		m[key] = d.valueInterface()
+		d.popPath()
 
 		// Next token must be , or }.
 		if d.opcode == scanSkipSpace {
@@ -388,13 +388,31 @@ type mapStringToStringData struct {
 }
 
 type unmarshalTest struct {
-	in                    string
-	ptr                   interface{} // new(type)
-	out                   interface{}
-	err                   error
-	useNumber             bool
-	golden                bool
-	disallowUnknownFields bool
+	in                      string
+	ptr                     interface{} // new(type)
+	out                     interface{}
+	err                     error
+	useNumber               bool
+	golden                  bool
+	disallowUnknownFields   bool
+	disallowDuplicateFields bool
+	caseSensitive           bool
+}
+
+type Inline struct {
+	A     int
+	Extra map[string]interface{} `json:",inline"`
+}
+
+type InlineStruct struct {
+	B     int
+	Inner Inline `json:"ignored,inline"`
+}
+
+type InlineAmbiguous struct {
+	A int
+	X map[string]int `json:",inline"`
+	Y map[string]int `json:",inline"`
 }
 
 type B struct {
@@ -421,11 +439,11 @@ var unmarshalTests = []unmarshalTest{
 	{in: `"g-clef: \uD834\uDD1E"`, ptr: new(string), out: "g-clef: \U0001D11E"},
 	{in: `"invalid: \uD834x\uDD1E"`, ptr: new(string), out: "invalid: \uFFFDx\uFFFD"},
 	{in: "null", ptr: new(interface{}), out: nil},
-	{in: `{"X": [1,2,3], "Y": 4}`, ptr: new(T), out: T{Y: 4}, err: &UnmarshalTypeError{"array", reflect.TypeOf(""), 7, "T", "X"}},
-	{in: `{"X": 23}`, ptr: new(T), out: T{}, err: &UnmarshalTypeError{"number", reflect.TypeOf(""), 8, "T", "X"}}, {in: `{"x": 1}`, ptr: new(tx), out: tx{}},
+	{in: `{"X": [1,2,3], "Y": 4}`, ptr: new(T), out: T{Y: 4}, err: &UnmarshalTypeError{Value: "array", Type: reflect.TypeOf(""), Offset: 7, Struct: "T", Field: "X"}},
+	{in: `{"X": 23}`, ptr: new(T), out: T{}, err: &UnmarshalTypeError{Value: "number", Type: reflect.TypeOf(""), Offset: 8, Struct: "T", Field: "X"}}, {in: `{"x": 1}`, ptr: new(tx), out: tx{}},
 	{in: `{"x": 1}`, ptr: new(tx), out: tx{}},
 	{in: `{"x": 1}`, ptr: new(tx), err: fmt.Errorf("json: unknown field \"x\""), disallowUnknownFields: true},
-	{in: `{"S": 23}`, ptr: new(W), out: W{}, err: &UnmarshalTypeError{"number", reflect.TypeOf(SS("")), 0, "W", "S"}},
+	{in: `{"S": 23}`, ptr: new(W), out: W{}, err: &UnmarshalTypeError{Value: "number", Type: reflect.TypeOf(SS("")), Offset: 0, Struct: "W", Field: "S"}},
 	{in: `{"F1":1,"F2":2,"F3":3}`, ptr: new(V), out: V{F1: float64(1), F2: int32(2), F3: Number("3")}},
 	{in: `{"F1":1,"F2":2,"F3":3}`, ptr: new(V), out: V{F1: Number("1"), F2: int32(2), F3: Number("3")}, useNumber: true},
 	{in: `{"k1":1,"k2":"s","k3":[1,2.0,3e-3],"k4":{"kk1":"s","kk2":2}}`, ptr: new(interface{}), out: ifaceNumAsFloat64},
@@ -980,6 +998,38 @@ var unmarshalTests = []unmarshalTest{
 		ptr: new(map[string]Number),
 		err: fmt.Errorf("json: invalid number literal, trying to unmarshal %q into Number", `"invalid"`),
 	},
+
+	// case-sensitive matching
+	{in: `{"alpha": "abc"}`, ptr: new(U), out: U{Alphabet: "abc"}, caseSensitive: true},
+	{in: `{"Alpha": "abc"}`, ptr: new(U), out: U{}, caseSensitive: true},
+	{in: `{"Alpha": "abc"}`, ptr: new(U), err: fmt.Errorf("json: unknown field \"Alpha\""), caseSensitive: true, disallowUnknownFields: true},
+
+	// duplicate names
+	{in: `{"alpha": "abc", "alpha": "xyz"}`, ptr: new(U), out: U{Alphabet: "xyz"}},
+	{in: `{"alpha": "abc", "alpha": "xyz"}`, ptr: new(U), err: fmt.Errorf("json: duplicate field \"alpha\""), disallowDuplicateFields: true},
+	{in: `{"alpha": "abc", "ALPHA": "xyz"}`, ptr: new(U), err: fmt.Errorf("json: duplicate field \"ALPHA\""), disallowDuplicateFields: true},
+	{in: `{"alpha": "abc", "ALPHA": "xyz"}`, ptr: new(U), out: U{Alphabet: "abc"}, disallowDuplicateFields: true, caseSensitive: true},
+	{in: `{"x": 1, "x": 2}`, ptr: new(map[string]int), err: fmt.Errorf("json: duplicate field \"x\""), disallowDuplicateFields: true},
+	{in: `[{"x": 1}, {"x": 2}]`, ptr: new([]map[string]int), out: []map[string]int{{"x": 1}, {"x": 2}}, disallowDuplicateFields: true},
+	{in: `{"x": 1, "x": 2}`, ptr: new(interface{}), err: fmt.Errorf("json: duplicate field \"x\""), disallowDuplicateFields: true},
+
+	// inline fields
+	{
+		in:     `{"A":1,"b":"x","c":true}`,
+		ptr:    new(Inline),
+		out:    Inline{A: 1, Extra: map[string]interface{}{"b": "x", "c": true}},
+		golden: true,
+	},
+	{in: `{"A":1}`, ptr: new(Inline), out: Inline{A: 1}, golden: true},
+	{in: `{"a":1,"b":2}`, ptr: new(Inline), out: Inline{A: 1, Extra: map[string]interface{}{"b": float64(2)}}, disallowUnknownFields: true},
+	{in: `{"a":1,"b":2}`, ptr: new(Inline), out: Inline{Extra: map[string]interface{}{"a": float64(1), "b": float64(2)}}, caseSensitive: true},
+	{
+		in:     `{"B":1,"A":2,"c":3}`,
+		ptr:    new(InlineStruct),
+		out:    InlineStruct{B: 1, Inner: Inline{A: 2, Extra: map[string]interface{}{"c": float64(3)}}},
+		golden: true,
+	},
+	{in: `{"A":1,"b":2}`, ptr: new(InlineAmbiguous), err: fmt.Errorf("json: unknown field \"b\""), disallowUnknownFields: true},
 }
 
 func TestMarshal(t *testing.T) {
@@ -1130,6 +1180,12 @@ func TestUnmarshal(t *testing.T) {
 		if tt.disallowUnknownFields {
 			dec.DisallowUnknownFields()
 		}
+		if tt.disallowDuplicateFields {
+			dec.DisallowDuplicateFields()
+		}
+		if tt.caseSensitive {
+			dec.MatchCaseSensitive()
+		}
 		if err := dec.Decode(v.Interface()); !equalError(err, tt.err) {
 			t.Errorf("#%d: %v, want %v", i, err, tt.err)
 			continue
@@ -1160,6 +1216,9 @@ func TestUnmarshal(t *testing.T) {
 			if tt.useNumber {
 				dec.UseNumber()
 			}
+			if tt.caseSensitive {
+				dec.MatchCaseSensitive()
+			}
 			if err := dec.Decode(vv.Interface()); err != nil {
 				t.Errorf("#%d: error re-unmarshaling %#q: %v", i, enc, err)
 				continue
@@ -2010,6 +2069,37 @@ func TestUnmarshalTypeError(t *testing.T) {
 	}
 }
 
+var decodeErrorLocationTests = []struct {
+	dest   interface{}
+	src    string
+	path   string
+	line   int
+	column int
+}{
+	{new(int), `"x"`, "", 1, 4},
+	{new(struct{ A []int }), `{"A": [1, "x"]}`, "/A/1", 1, 14},
+	{new(struct{ A []int }), `{"a": [1, true]}`, "/a/1", 1, 15},
+	{new(map[string]int), `{"x/y~": "s"}`, "/x~1y~0", 1, 13},
+	{new([]map[string]int8), `[{}, {"\u0061": 300}]`, "/1/a", 1, 20},
+	{new(struct{ B struct{ C int } }), "{\n  \"B\": {\n    \"C\": \"z\"\n  }\n}", "/B/C", 3, 13},
+	{new(interface{}), `{"a": [1e400]}`, "/a/0", 1, 14},
+}
+
+func TestUnmarshalTypeErrorLocation(t *testing.T) {
+	for _, tt := range decodeErrorLocationTests {
+		err := Unmarshal([]byte(tt.src), tt.dest)
+		e, ok := err.(*UnmarshalTypeError)
+		if !ok {
+			t.Errorf("Unmarshal(%q, type %T): got %T, want *UnmarshalTypeError", tt.src, tt.dest, err)
+			continue
+		}
+		if e.Path != tt.path || e.Line != tt.line || e.Column != tt.column {
+			t.Errorf("Unmarshal(%q, type %T): got location %q %d:%d, want %q %d:%d",
+				tt.src, tt.dest, e.Path, e.Line, e.Column, tt.path, tt.line, tt.column)
+		}
+	}
+}
+
 var unmarshalSyntaxTests = []string{
 	"tru",
 	"fals",
@@ -42,6 +42,8 @@ import (
 // Boolean values encode as JSON booleans.
 //
 // Floating point, integer, and Number values encode as JSON numbers.
+// By default floating point values are formatted like ES6 number to string
+// conversion; an Encoder can select a fixed format with SetFloatFormat.
 //
 // String values encode as JSON strings coerced to valid UTF-8,
 // replacing invalid bytes with the Unicode replacement rune.
@@ -72,6 +74,13 @@ import (
 // false, 0, a nil pointer, a nil interface value, and any empty array,
 // slice, map, or string.
 //
+// The "omitzero" option specifies that the field should be omitted
+// from the encoding if the field has a zero value. If the field type
+// has an "IsZero() bool" method, that method is used to determine
+// whether the value is zero; otherwise the value is zero if it is the
+// zero value for its type. Unlike "omitempty", "omitzero" omits a zero
+// time.Time and a struct whose fields are all zero.
+//
 // As a special case, if the field tag is "-", the field is always omitted.
 // Note that a field with name "-" can still be generated using the tag "-,".
 //
@@ -96,6 +105,9 @@ import (
 //   // Field appears in JSON as key "-".
 //   Field int `json:"-,"`
 //
+//   // Field is omitted if it is the zero time.
+//   Field time.Time `json:",omitzero"`
+//
 // The "string" option signals that a field is stored as JSON inside a
 // JSON-encoded string. It applies only to fields of string, floating point,
 // integer, or boolean types. This extra level of encoding is sometimes used
@@ -128,6 +140,19 @@ import (
 //
 // 3) Otherwise there are multiple fields, and all are ignored; no error occurs.
 //
+// The "inline" option applies to struct fields of struct type and of map
+// type with string keys. A struct field tagged "inline" is treated as if
+// it were anonymous, so that its inner fields are promoted into the outer
+// object; any name given in the tag is ignored. A map field tagged
+// "inline" holds members of the object that do not correspond to any
+// other field: Marshal encodes its entries, in sorted key order, after
+// the other fields of the object, skipping any entry whose key is the
+// name of another field. At most one inline map is used per struct,
+// selected by the same visibility rules as other fields.
+//
+//   // Unknown object members are collected into Extra.
+//   Extra map[string]interface{} `json:",inline"`
+//
 // Handling of anonymous struct fields is new in Go 1.1.
 // Prior to Go 1.1, anonymous struct fields were ignored. To force ignoring of
 // an anonymous struct field in both current and earlier versions, give the field
@@ -365,6 +390,13 @@ type encOpts struct {
 	quoted bool
 	// escapeHTML causes '<', '>', and '&' to be escaped in JSON strings.
 	escapeHTML bool
+	// disallowDuplicateFields causes ambiguous struct field names and
+	// inline map keys that collide with other fields to be reported as errors.
+	disallowDuplicateFields bool
+	// floatFmt, if non-zero, is the strconv format used for floating point
+	// values, with floatPrec as the precision.
+	floatFmt  byte
+	floatPrec int
 }
 
 type encoderFunc func(e *encodeState, v reflect.Value, opts encOpts)
@@ -578,6 +610,18 @@ func (bits floatEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 		e.error(&UnsupportedValueError{v, strconv.FormatFloat(f, 'g', -1, int(bits))})
 	}
 
+	if opts.floatFmt != 0 {
+		b := strconv.AppendFloat(e.scratch[:0], f, opts.floatFmt, opts.floatPrec, int(bits))
+		if opts.quoted {
+			e.WriteByte('"')
+		}
+		e.Write(b)
+		if opts.quoted {
+			e.WriteByte('"')
+		}
+		return
+	}
+
 	// Convert as if by ES6 number to string conversion.
 	// This matches most other JSON generators.
 	// See golang.org/issue/6384 and golang.org/issue/14135.
@@ -727,29 +771,51 @@ type structEncoder struct {
 type structFields struct {
 	list      []field
 	nameIndex map[string]int
+
+	// inline is the map field tagged ",inline" that holds
+	// object members not matching any other field, if any.
+	inline *field
+
+	// ambiguous lists the names of fields that were dropped
+	// because several fields at the same depth share the name.
+	ambiguous []string
+}
+
+// fieldByIndex returns the nested field of v selected by index,
+// or the zero Value if the path goes through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
 }
 
 func (se structEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
+	if opts.disallowDuplicateFields && len(se.fields.ambiguous) > 0 {
+		e.error(fmt.Errorf("json: duplicate field %q in Go struct type %v", se.fields.ambiguous[0], v.Type()))
+	}
 	next := byte('{')
-FieldLoop:
 	for i := range se.fields.list {
 		f := &se.fields.list[i]
 
 		// Find the nested struct field by following f.index.
-		fv := v
-		for _, i := range f.index {
-			if fv.Kind() == reflect.Ptr {
-				if fv.IsNil() {
-					continue FieldLoop
-				}
-				fv = fv.Elem()
-			}
-			fv = fv.Field(i)
+		fv := fieldByIndex(v, f.index)
+		if !fv.IsValid() {
+			continue
 		}
 
 		if f.omitEmpty && isEmptyValue(fv) {
 			continue
 		}
+		if f.omitZero && f.isZero(fv) {
+			continue
+		}
 		e.WriteByte(next)
 		next = ','
 		if opts.escapeHTML {
@@ -760,6 +826,27 @@ FieldLoop:
 		opts.quoted = f.quoted
 		f.encoder(e, fv, opts)
 	}
+	if f := se.fields.inline; f != nil {
+		if mv := fieldByIndex(v, f.index); mv.IsValid() && mv.Len() > 0 {
+			keys := mv.MapKeys()
+			sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+			opts.quoted = false
+			for _, k := range keys {
+				name := k.String()
+				if _, ok := se.fields.nameIndex[name]; ok {
+					if opts.disallowDuplicateFields {
+						e.error(fmt.Errorf("json: duplicate field %q in Go struct type %v", name, v.Type()))
+					}
+					continue
+				}
+				e.WriteByte(next)
+				next = ','
+				e.string(name, opts.escapeHTML)
+				e.WriteByte(':')
+				f.encoder(e, mv.MapIndex(k), opts)
+			}
+		}
+	}
 	if next == '{' {
 		e.WriteString("{}")
 	} else {
@@ -1182,8 +1269,10 @@ type field struct {
 	index     []int
 	typ       reflect.Type
 	omitEmpty bool
+	omitZero  bool
 	quoted    bool
 
+	isZero  func(reflect.Value) bool // reports whether the field value is zero, for omitZero
 	encoder encoderFunc
 }
 
@@ -1223,6 +1312,9 @@ func typeFields(t reflect.Type) structFields {
 	// Fields found.
 	var fields []field
 
+	// Inline map fields found.
+	var inlines []field
+
 	// Buffer to run HTMLEscape on field names.
 	var nameEscBuf bytes.Buffer
 
@@ -1286,8 +1378,18 @@ func typeFields(t reflect.Type) structFields {
 					}
 				}
 
+				// Record inline map field.
+				inline := opts.Contains("inline")
+				if inline && sf.Type.Kind() == reflect.Map && sf.Type.Key().Kind() == reflect.String {
+					inlines = append(inlines, field{name: sf.Name, index: index, typ: sf.Type})
+					if count[f.typ] > 1 {
+						inlines = append(inlines, inlines[len(inlines)-1])
+					}
+					continue
+				}
+
 				// Record found field and index sequence.
-				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+				if (name != "" || !sf.Anonymous) && !inline || ft.Kind() != reflect.Struct {
 					tagged := name != ""
 					if name == "" {
 						name = sf.Name
@@ -1298,8 +1400,12 @@ func typeFields(t reflect.Type) structFields {
 						index:     index,
 						typ:       ft,
 						omitEmpty: opts.Contains("omitempty"),
+						omitZero:  opts.Contains("omitzero"),
 						quoted:    quoted,
 					}
+					if field.omitZero {
+						field.isZero = zeroFunc(sf.Type)
+					}
 					field.nameBytes = []byte(field.name)
 					field.equalFold = foldFunc(field.nameBytes)
 
@@ -1354,6 +1460,7 @@ func typeFields(t reflect.Type) structFields {
 	// The fields are sorted in primary order of name, secondary order
 	// of field index length. Loop over names; for each name, delete
 	// hidden fields by choosing the one dominant field that survives.
+	var ambiguous []string
 	out := fields[:0]
 	for advance, i := 0, 0; i < len(fields); i += advance {
 		// One iteration per name.
@@ -1373,6 +1480,8 @@ func typeFields(t reflect.Type) structFields {
 		dominant, ok := dominantField(fields[i : i+advance])
 		if ok {
 			out = append(out, dominant)
+		} else {
+			ambiguous = append(ambiguous, name)
 		}
 	}
 
@@ -1387,7 +1496,58 @@ func typeFields(t reflect.Type) structFields {
 	for i, field := range fields {
 		nameIndex[field.name] = i
 	}
-	return structFields{fields, nameIndex}
+
+	// Choose the inline map field by the same rules, ignoring tags:
+	// the least nested one wins, and several at that depth cancel out.
+	var inline *field
+	if len(inlines) > 0 {
+		sort.Sort(byIndex(inlines))
+		sort.SliceStable(inlines, func(i, j int) bool {
+			return len(inlines[i].index) < len(inlines[j].index)
+		})
+		if len(inlines) == 1 || len(inlines[0].index) < len(inlines[1].index) {
+			inline = &inlines[0]
+			inline.encoder = typeEncoder(inline.typ.Elem())
+		}
+	}
+	return structFields{list: fields, nameIndex: nameIndex, inline: inline, ambiguous: ambiguous}
+}
+
+// isZeroer is implemented by types, such as time.Time,
+// that define their own notion of a zero value.
+type isZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerType = reflect.TypeOf((*isZeroer)(nil)).Elem()
+
+// zeroFunc returns a function reporting whether a value of type t
+// is zero, for the omitzero option.
+func zeroFunc(t reflect.Type) func(reflect.Value) bool {
+	switch {
+	case t.Kind() == reflect.Interface:
+		return reflect.Value.IsNil
+	case t.Implements(isZeroerType):
+		if t.Kind() == reflect.Ptr {
+			return func(v reflect.Value) bool {
+				return v.IsNil() || v.Interface().(isZeroer).IsZero()
+			}
+		}
+		return func(v reflect.Value) bool {
+			return v.Interface().(isZeroer).IsZero()
+		}
+	case reflect.PtrTo(t).Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			if !v.CanAddr() {
+				// Temporarily box v so we can take the address.
+				v2 := reflect.New(v.Type()).Elem()
+				v2.Set(v)
+				v = v2
+			}
+			return v.Addr().Interface().(isZeroer).IsZero()
+		}
+	}
+	return reflect.Value.IsZero
 }
 
 // dominantField looks through the fields, all of which are known to
@@ -14,6 +14,7 @@ import (
 	"regexp"
 	"strconv"
 	"testing"
+	"time"
 	"unicode"
 )
 
@@ -71,6 +72,110 @@ func TestOmitEmpty(t *testing.T) {
 	}
 }
 
+type zeroByMethod struct {
+	N int
+}
+
+func (z *zeroByMethod) IsZero() bool { return z.N < 0 }
+
+type OptionalsZero struct {
+	Ir int `json:"ir"`
+	Iz int `json:"iz,omitzero"`
+
+	Sr struct{ A int } `json:"sr"`
+	Sz struct{ A int } `json:"sz,omitzero"`
+
+	Tr time.Time `json:"tr"`
+	Tz time.Time `json:"tz,omitzero"`
+
+	Pz    *int                   `json:"pz,omitzero"`
+	Mz    map[string]interface{} `json:"mz,omitzero"`
+	Iface interface{}            `json:"iface,omitzero"`
+
+	Zm zeroByMethod  `json:"zm,omitzero"`
+	Zp *zeroByMethod `json:"zp,omitzero"`
+}
+
+var optionalsZeroExpected = `{
+ "ir": 0,
+ "sr": {
+  "A": 0
+ },
+ "tr": "0001-01-01T00:00:00Z",
+ "mz": {},
+ "zm": {
+  "N": 0
+ }
+}`
+
+func TestOmitZero(t *testing.T) {
+	var o OptionalsZero
+	o.Mz = map[string]interface{}{}
+	o.Zp = &zeroByMethod{N: -1}
+
+	got, err := MarshalIndent(&o, "", " ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(got); got != optionalsZeroExpected {
+		t.Errorf(" got: %s\nwant: %s\n", got, optionalsZeroExpected)
+	}
+
+	// A non-addressable value still consults the pointer method.
+	o.Zm.N = -1
+	got, err = Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"ir":0,"sr":{"A":0},"tr":"0001-01-01T00:00:00Z","mz":{}}`; string(got) != want {
+		t.Errorf(" got: %s\nwant: %s\n", got, want)
+	}
+}
+
+func TestEncodeInline(t *testing.T) {
+	type Inner struct {
+		C int
+		D map[string]int `json:",inline"`
+	}
+	type Outer struct {
+		A     int
+		Inner `json:"-,"`
+		I     Inner           `json:",inline"`
+		Rest  map[string]bool `json:",inline"`
+		Z     int
+	}
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{Inline{A: 1}, `{"A":1}`},
+		{Inline{A: 1, Extra: map[string]interface{}{"z": 1, "b": "x"}}, `{"A":1,"b":"x","z":1}`},
+		{Inline{A: 1, Extra: map[string]interface{}{"A": 2, "a": 3}}, `{"A":1,"a":3}`},
+		{InlineStruct{B: 1, Inner: Inline{A: 2, Extra: map[string]interface{}{"c": 3}}}, `{"B":1,"A":2,"c":3}`},
+		{InlineAmbiguous{A: 1, X: map[string]int{"x": 1}, Y: map[string]int{"y": 2}}, `{"A":1}`},
+		{struct {
+			P *Inline `json:",inline"`
+		}{}, `{}`},
+		{struct {
+			P *Inline `json:",inline"`
+		}{&Inline{A: 1, Extra: map[string]interface{}{"b": 2}}}, `{"A":1,"b":2}`},
+		{
+			Outer{A: 1, I: Inner{C: 2, D: map[string]int{"d": 4}}, Rest: map[string]bool{"r": true, "Z": true}, Z: 3},
+			`{"A":1,"-":{"C":0},"C":2,"Z":3,"r":true}`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := Marshal(tt.in)
+		if err != nil {
+			t.Errorf("Marshal(%#v): %v", tt.in, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("Marshal(%#v):\n got: %s\nwant: %s", tt.in, got, tt.want)
+		}
+	}
+}
+
 type StringTag struct {
 	BoolStr    bool    `json:",string"`
 	IntStr     int64   `json:",string"`
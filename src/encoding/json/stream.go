@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"strconv"
 )
 
 // A Decoder reads and decodes JSON values from an input stream.
@@ -22,6 +23,13 @@ type Decoder struct {
 
 	tokenState int
 	tokenStack []int
+
+	// Input position bookkeeping for error locations:
+	// lines newlines were seen before absolute offset linePos,
+	// the last of them ending at absolute offset lineStart.
+	lines     int
+	lineStart int64
+	linePos   int64
 }
 
 // NewDecoder returns a new decoder that reads from r.
@@ -41,6 +49,25 @@ func (dec *Decoder) UseNumber() { dec.d.useNumber = true }
 // non-ignored, exported fields in the destination.
 func (dec *Decoder) DisallowUnknownFields() { dec.d.disallowUnknownFields = true }
 
+// DisallowDuplicateFields causes the Decoder to return an error when an
+// object in the input contains the same key more than once. When the
+// destination is a struct, two keys that match the same field, such as
+// "name" and "Name" without MatchCaseSensitive, are also duplicates.
+func (dec *Decoder) DisallowDuplicateFields() { dec.d.disallowDuplicateFields = true }
+
+// MatchCaseSensitive causes the Decoder to match object keys to struct
+// fields only if they are exactly equal to the field name, instead of
+// also accepting a case-insensitive match. Keys that differ from every
+// field name only by case are then treated as unknown fields.
+func (dec *Decoder) MatchCaseSensitive() { dec.d.caseSensitive = true }
+
+// CollectErrors causes Decode to keep decoding after an error that does
+// not prevent it from continuing, such as a type mismatch or, when
+// DisallowUnknownFields is set, an unknown field, and to report every
+// such error. If any errors occur, Decode returns them as an
+// UnmarshalErrors in input order.
+func (dec *Decoder) CollectErrors() { dec.d.collectErrors = true }
+
 // Decode reads the next JSON-encoded value from its
 // input and stores it in the value pointed to by v.
 //
@@ -64,7 +91,10 @@ func (dec *Decoder) Decode(v interface{}) error {
 	if err != nil {
 		return err
 	}
+	dec.countLines(dec.scanp)
 	dec.d.init(dec.buf[dec.scanp : dec.scanp+n])
+	dec.d.line = dec.lines + 1
+	dec.d.column = int(dec.scanned+int64(dec.scanp)-dec.lineStart) + 1
 	dec.scanp += n
 
 	// Don't save err from unmarshal into dec.err:
@@ -147,6 +177,7 @@ func (dec *Decoder) refill() error {
 	// Make room to read more into the buffer.
 	// First slide down data already consumed.
 	if dec.scanp > 0 {
+		dec.countLines(dec.scanp)
 		dec.scanned += int64(dec.scanp)
 		n := copy(dec.buf, dec.buf[dec.scanp:])
 		dec.buf = dec.buf[:n]
@@ -168,6 +199,20 @@ func (dec *Decoder) refill() error {
 	return err
 }
 
+// countLines accounts for the newlines in dec.buf before dec.buf[n]
+// that have not been counted yet.
+func (dec *Decoder) countLines(n int) {
+	for i := int(dec.linePos - dec.scanned); i < n; i++ {
+		if dec.buf[i] == '\n' {
+			dec.lines++
+			dec.lineStart = dec.scanned + int64(i) + 1
+		}
+	}
+	if pos := dec.scanned + int64(n); pos > dec.linePos {
+		dec.linePos = pos
+	}
+}
+
 func nonSpace(b []byte) bool {
 	for _, c := range b {
 		if !isSpace(c) {
@@ -179,9 +224,12 @@ func nonSpace(b []byte) bool {
 
 // An Encoder writes JSON values to an output stream.
 type Encoder struct {
-	w          io.Writer
-	err        error
-	escapeHTML bool
+	w                       io.Writer
+	err                     error
+	escapeHTML              bool
+	disallowDuplicateFields bool
+	floatFmt                byte
+	floatPrec               int
 
 	indentBuf    *bytes.Buffer
 	indentPrefix string
@@ -203,7 +251,12 @@ func (enc *Encoder) Encode(v interface{}) error {
 		return enc.err
 	}
 	e := newEncodeState()
-	err := e.marshal(v, encOpts{escapeHTML: enc.escapeHTML})
+	err := e.marshal(v, encOpts{
+		escapeHTML:              enc.escapeHTML,
+		disallowDuplicateFields: enc.disallowDuplicateFields,
+		floatFmt:                enc.floatFmt,
+		floatPrec:               enc.floatPrec,
+	})
 	if err != nil {
 		return err
 	}
@@ -254,6 +307,35 @@ func (enc *Encoder) SetEscapeHTML(on bool) {
 	enc.escapeHTML = on
 }
 
+// DisallowDuplicateFields causes the Encoder to return an error instead of
+// silently dropping object members whose names are not unique: struct
+// fields that are ignored because several fields at the same level share
+// a JSON name, and entries of an ",inline" map whose keys are the names
+// of other fields.
+func (enc *Encoder) DisallowDuplicateFields() {
+	enc.disallowDuplicateFields = true
+}
+
+// SetFloatFormat specifies how the encoder formats floating point values.
+// The format fmt and precision prec are interpreted as by
+// strconv.FormatFloat, except that fmt must be one of 'e', 'E', 'f',
+// 'g' or 'G', so that the result is a valid JSON number. The output then
+// depends only on the value and its bit size.
+//
+// Calling SetFloatFormat(0, 0) restores the default behavior, which formats
+// values like the ES6 number to string conversion: the shortest decimal
+// that round-trips, using exponent notation only for very large or small
+// magnitudes.
+func (enc *Encoder) SetFloatFormat(fmt byte, prec int) {
+	switch fmt {
+	case 0, 'e', 'E', 'f', 'g', 'G':
+	default:
+		panic("json: invalid float format " + strconv.QuoteRune(rune(fmt)))
+	}
+	enc.floatFmt = fmt
+	enc.floatPrec = prec
+}
+
 // RawMessage is a raw encoded JSON value.
 // It implements Marshaler and Unmarshaler and can
 // be used to delay JSON decoding or precompute a JSON encoding.
@@ -169,6 +169,69 @@ func TestEncoderSetEscapeHTML(t *testing.T) {
 	}
 }
 
+func TestEncoderDisallowDuplicateFields(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want string
+		err  string
+	}{
+		{Inline{A: 1, Extra: map[string]interface{}{"b": 2}}, `{"A":1,"b":2}`, ""},
+		{Inline{A: 1, Extra: map[string]interface{}{"A": 2}}, `{"A":1}`, `json: duplicate field "A" in Go struct type json.Inline`},
+		{BugZ{}, `{}`, `json: duplicate field "S" in Go struct type json.BugZ`},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		if err := enc.Encode(tt.v); err != nil {
+			t.Errorf("Encode(%#v): %v", tt.v, err)
+		} else if got := strings.TrimSpace(buf.String()); got != tt.want {
+			t.Errorf("Encode(%#v) = %#q, want %#q", tt.v, got, tt.want)
+		}
+
+		buf.Reset()
+		enc.DisallowDuplicateFields()
+		err := enc.Encode(tt.v)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("DisallowDuplicateFields Encode(%#v): %v", tt.v, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("DisallowDuplicateFields Encode(%#v) error = %v, want %q", tt.v, err, tt.err)
+		}
+	}
+}
+
+func TestEncoderSetFloatFormat(t *testing.T) {
+	tests := []struct {
+		fmt  byte
+		prec int
+		v    interface{}
+		want string
+	}{
+		{0, 0, []float64{1, 1e21, 1e-7, 0.1}, `[1,1e+21,1e-7,0.1]`},
+		{'e', -1, []float64{1, 1e21, 1e-7, 0.1}, `[1e+00,1e+21,1e-07,1e-01]`},
+		{'f', 3, []float64{1, 1234.5678, -0.0004}, `[1.000,1234.568,-0.000]`},
+		{'g', -1, []float32{1, 1e21, 0.1}, `[1,1e+21,0.1]`},
+		{'f', 2, struct {
+			F float64 `json:",string"`
+		}{2.5}, `{"F":"2.50"}`},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetFloatFormat(tt.fmt, tt.prec)
+		if err := enc.Encode(tt.v); err != nil {
+			t.Errorf("SetFloatFormat(%q, %d) Encode(%v): %v", tt.fmt, tt.prec, tt.v, err)
+			continue
+		}
+		if got := strings.TrimSpace(buf.String()); got != tt.want {
+			t.Errorf("SetFloatFormat(%q, %d) Encode(%v) = %#q, want %#q", tt.fmt, tt.prec, tt.v, got, tt.want)
+		}
+	}
+}
+
 func TestDecoder(t *testing.T) {
 	for i := 0; i <= len(streamTest); i++ {
 		// Use stream without newlines as input,
@@ -201,6 +264,69 @@ func TestDecoder(t *testing.T) {
 	}
 }
 
+func TestDecoderCollectErrors(t *testing.T) {
+	type T struct {
+		A int
+		B []string
+		C struct{ D bool }
+	}
+	const in = `{"A": 1}
+{
+	"A": "one",
+	"B": ["x", 2, "y", 3],
+	"X": null,
+	"C": {"D": 0, "E": 1}
+}`
+	dec := NewDecoder(strings.NewReader(in))
+	dec.DisallowUnknownFields()
+	dec.CollectErrors()
+	var v T
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("Decode #1: %v", err)
+	}
+	err := dec.Decode(&v)
+	errs, ok := err.(UnmarshalErrors)
+	if !ok {
+		t.Fatalf("Decode #2: got %T, want UnmarshalErrors", err)
+	}
+	want := []struct {
+		path         string
+		line, column int
+	}{
+		{"/A", 3, 12},
+		{"/B/1", 4, 14},
+		{"/B/3", 4, 22},
+		{"/X", 5, 2},
+		{"/C/D", 6, 14},
+		{"/C/E", 6, 16},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Decode #2: got %d errors, want %d: %v", len(errs), len(want), errs)
+	}
+	for i, err := range errs {
+		var path string
+		var line, column int
+		switch err := err.(type) {
+		case *UnmarshalTypeError:
+			path, line, column = err.Path, err.Line, err.Column
+		case *UnknownFieldError:
+			path, line, column = err.Path, err.Line, err.Column
+		default:
+			t.Errorf("error #%d: unexpected type %T", i, err)
+			continue
+		}
+		if w := want[i]; path != w.path || line != w.line || column != w.column {
+			t.Errorf("error #%d (%v): got %q %d:%d, want %q %d:%d", i, err, path, line, column, w.path, w.line, w.column)
+		}
+	}
+	if got, want := err.Error(), `json: cannot unmarshal string into Go struct field T.A of type int (and 5 more errors)`; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if want := []string{"x", "", "y", ""}; !reflect.DeepEqual(v.B, want) {
+		t.Errorf("B = %q, want %q", v.B, want)
+	}
+}
+
 func TestDecoderBuffered(t *testing.T) {
 	r := strings.NewReader(`{"Name": "Gopher"} extra `)
 	var m struct {
@@ -0,0 +1,378 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// A Canonicalizer writes a sequence of tokens in the canonical form
+// defined by Exclusive XML Canonicalization Version 1.0
+// (https://www.w3.org/TR/xml-exc-c14n/), as used to compute and
+// verify XML signatures.
+//
+// The canonical form depends on the name space prefixes used in the
+// document. The Canonicalizer writes each name with the prefix of the
+// innermost declaration in scope for its name space, so the tokens
+// should come from a Decoder with PreservePrefixes set, which makes
+// that prefix the one used in the document. To canonicalize a document
+// subset, such as a signed element, write only the tokens of that subset.
+//
+// Name space declarations are written only on the elements that
+// visibly use them, attributes are sorted, empty elements are written
+// as start-end pairs, character references are replaced by the
+// characters they denote, and CDATA sections, the XML declaration
+// and document type declarations are removed.
+type Canonicalizer struct {
+	// WithComments causes comments to be written.
+	// By default they are omitted.
+	WithComments bool
+
+	// InclusivePrefixes lists name space prefixes that are
+	// treated as defined by inclusive canonicalization: a declaration
+	// of such a prefix that is in scope in the input is written on
+	// each element unless it was already written on an output ancestor.
+	// The name "#default" denotes the default name space. This
+	// corresponds to the InclusiveNamespaces PrefixList parameter.
+	InclusivePrefixes []string
+
+	// Context holds the name space declarations, as xmlns attributes,
+	// that are in scope in the input where the tokens written start,
+	// such as those on the ancestors of a signed element. They are used
+	// only for InclusivePrefixes.
+	Context []Attr
+
+	w        *bufio.Writer
+	err      error
+	tags     []c14nElement
+	inScope  []nsBinding // declarations seen in the input
+	rendered []nsBinding // declarations written on open elements
+	seenRoot bool
+}
+
+// A c14nElement records an open element and the
+// length of the binding stacks before it.
+type c14nElement struct {
+	name              Name
+	prefix            string
+	inScope, rendered int
+}
+
+// NewCanonicalizer returns a new Canonicalizer that writes to w.
+func NewCanonicalizer(w io.Writer) *Canonicalizer {
+	return &Canonicalizer{w: bufio.NewWriter(w)}
+}
+
+// EncodeToken writes the canonical form of the given token.
+// It returns an error if StartElement and EndElement tokens are not
+// properly matched or if a name uses a name space that cannot be
+// given a prefix.
+//
+// EncodeToken does not call Flush; callers must call Flush when
+// finished to ensure that the output is written to the underlying writer.
+func (c *Canonicalizer) EncodeToken(t Token) error {
+	if c.err != nil {
+		return c.err
+	}
+	var err error
+	switch t := t.(type) {
+	case StartElement:
+		err = c.writeStart(&t)
+	case EndElement:
+		err = c.writeEnd(t.Name)
+	case CharData:
+		// Character data outside the document element
+		// can only be white space, which is removed.
+		if len(c.tags) > 0 {
+			escapeC14N(c.w, t, false)
+		}
+	case Comment:
+		if !c.WithComments {
+			break
+		}
+		if bytes.Contains(t, endComment) {
+			return errors.New("xml: EncodeToken of Comment containing --> marker")
+		}
+		c.beforeNode()
+		c.w.WriteString("<!--")
+		c.w.Write(t)
+		c.w.WriteString("-->")
+		c.afterNode()
+	case ProcInst:
+		if t.Target == "xml" {
+			// The XML declaration is removed.
+			break
+		}
+		if !isNameString(t.Target) {
+			return errors.New("xml: EncodeToken of ProcInst with invalid Target")
+		}
+		if bytes.Contains(t.Inst, endProcInst) {
+			return errors.New("xml: EncodeToken of ProcInst containing ?> marker")
+		}
+		c.beforeNode()
+		c.w.WriteString("<?")
+		c.w.WriteString(t.Target)
+		if len(t.Inst) > 0 {
+			c.w.WriteByte(' ')
+			c.w.Write(t.Inst)
+		}
+		c.w.WriteString("?>")
+		c.afterNode()
+	case Directive:
+		// Document type declarations are removed.
+	default:
+		return errors.New("xml: EncodeToken of invalid token type")
+	}
+	if err != nil {
+		return err
+	}
+	return c.err
+}
+
+// Flush flushes any buffered output to the underlying writer.
+func (c *Canonicalizer) Flush() error {
+	if c.err != nil {
+		return c.err
+	}
+	c.err = c.w.Flush()
+	return c.err
+}
+
+// beforeNode and afterNode write the line breaks that separate
+// comments and processing instructions outside the document element
+// from the document element.
+func (c *Canonicalizer) beforeNode() {
+	if len(c.tags) == 0 && c.seenRoot {
+		c.w.WriteByte('\n')
+	}
+}
+
+func (c *Canonicalizer) afterNode() {
+	if len(c.tags) == 0 && !c.seenRoot {
+		c.w.WriteByte('\n')
+	}
+}
+
+// lookup returns the URL bound to prefix in the given bindings.
+func lookup(bindings []nsBinding, prefix string) (url string, ok bool) {
+	for i := len(bindings) - 1; i >= 0; i-- {
+		if b := bindings[i]; b.prefix == prefix {
+			return b.url, true
+		}
+	}
+	return "", false
+}
+
+// attrPrefix returns the prefix to use for the attribute name n.
+func (c *Canonicalizer) attrPrefix(n Name) (string, error) {
+	switch n.Space {
+	case "":
+		return "", nil
+	case xmlURL:
+		return xmlPrefix, nil
+	}
+	if prefix, ok := boundPrefix(c.inScope, n.Space, false); ok {
+		return prefix, nil
+	}
+	return "", fmt.Errorf("xml: no prefix for attribute %s in name space %s", n.Local, n.Space)
+}
+
+// isDeclaration reports whether n is the name of
+// a name space declaration attribute.
+func isDeclaration(n Name) bool {
+	return n.Space == xmlnsPrefix || n.Space == "" && n.Local == xmlnsPrefix
+}
+
+// declare records the name space declarations among attrs as in scope.
+func (c *Canonicalizer) declare(attrs []Attr) {
+	for _, a := range attrs {
+		switch {
+		case a.Name.Space == xmlnsPrefix:
+			c.inScope = append(c.inScope, nsBinding{a.Name.Local, a.Value})
+		case a.Name.Space == "" && a.Name.Local == xmlnsPrefix:
+			c.inScope = append(c.inScope, nsBinding{"", a.Value})
+		}
+	}
+}
+
+type c14nAttr struct {
+	space, prefix, local, value string
+}
+
+func (c *Canonicalizer) writeStart(start *StartElement) error {
+	if start.Name.Local == "" {
+		return errors.New("xml: start tag with no name")
+	}
+	name := start.Name
+	if len(c.tags) == 0 {
+		c.inScope = c.inScope[:0]
+		c.declare(c.Context)
+	}
+	mark := len(c.inScope)
+	c.declare(start.Attr)
+	// A name space without a declaration in scope
+	// is declared as the default name space.
+	prefix := ""
+	switch name.Space {
+	case "":
+	case xmlURL:
+		prefix = xmlPrefix
+	default:
+		prefix, _ = boundPrefix(c.inScope, name.Space, true)
+	}
+
+	// Check the attributes before recording the element,
+	// so that an error leaves the open elements unchanged.
+	var attrs []c14nAttr
+	for _, a := range start.Attr {
+		if a.Name.Local != "" && !isDeclaration(a.Name) {
+			p, err := c.attrPrefix(a.Name)
+			if err != nil {
+				c.inScope = c.inScope[:mark]
+				return err
+			}
+			attrs = append(attrs, c14nAttr{a.Name.Space, p, a.Name.Local, a.Value})
+		}
+	}
+	c.tags = append(c.tags, c14nElement{name, prefix, mark, len(c.rendered)})
+	c.seenRoot = true
+
+	// Find the name spaces visibly used by the element and its
+	// attributes, and those to be treated inclusively.
+	used := map[string]string{prefix: name.Space}
+	for _, a := range attrs {
+		if a.prefix != "" {
+			used[a.prefix] = a.space
+		}
+	}
+	for _, p := range c.InclusivePrefixes {
+		if p == "#default" {
+			p = ""
+		}
+		if url, ok := lookup(c.inScope, p); ok {
+			if _, ok := used[p]; !ok {
+				used[p] = url
+			}
+		}
+	}
+
+	// Write only declarations that differ from those
+	// already written on output ancestors.
+	var decls []nsBinding
+	for p, url := range used {
+		if p == xmlPrefix {
+			continue
+		}
+		// Nothing written means no default name space. Prefixes
+		// cannot be undeclared, so an empty URL is never written for them.
+		if r, _ := lookup(c.rendered, p); r == url || p != "" && url == "" {
+			continue
+		}
+		decls = append(decls, nsBinding{p, url})
+	}
+	sort.Slice(decls, func(i, j int) bool { return decls[i].prefix < decls[j].prefix })
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].space != attrs[j].space {
+			return attrs[i].space < attrs[j].space
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	c.w.WriteByte('<')
+	writeQName(c.w, prefix, name.Local)
+	for _, d := range decls {
+		c.rendered = append(c.rendered, d)
+		c.w.WriteString(" xmlns")
+		if d.prefix != "" {
+			c.w.WriteByte(':')
+			c.w.WriteString(d.prefix)
+		}
+		c.w.WriteString(`="`)
+		escapeC14N(c.w, []byte(d.url), true)
+		c.w.WriteByte('"')
+	}
+	for _, a := range attrs {
+		c.w.WriteByte(' ')
+		writeQName(c.w, a.prefix, a.local)
+		c.w.WriteString(`="`)
+		escapeC14N(c.w, []byte(a.value), true)
+		c.w.WriteByte('"')
+	}
+	c.w.WriteByte('>')
+	return nil
+}
+
+func (c *Canonicalizer) writeEnd(name Name) error {
+	if len(c.tags) == 0 {
+		return fmt.Errorf("xml: end tag </%s> without start tag", name.Local)
+	}
+	top := c.tags[len(c.tags)-1]
+	if top.name.Local != name.Local || top.name.Space != name.Space {
+		return fmt.Errorf("xml: end tag </%s> does not match start tag <%s>", name.Local, top.name.Local)
+	}
+	c.tags = c.tags[:len(c.tags)-1]
+	c.inScope = c.inScope[:top.inScope]
+	c.rendered = c.rendered[:top.rendered]
+	c.w.WriteString("</")
+	writeQName(c.w, top.prefix, name.Local)
+	c.w.WriteByte('>')
+	return nil
+}
+
+func writeQName(w *bufio.Writer, prefix, local string) {
+	if prefix != "" {
+		w.WriteString(prefix)
+		w.WriteByte(':')
+	}
+	w.WriteString(local)
+}
+
+// escapeC14N writes s escaped as required for canonical character
+// data or, if attr is set, for canonical attribute values.
+func escapeC14N(w *bufio.Writer, s []byte, attr bool) {
+	last := 0
+	for i, c := range s {
+		var esc string
+		switch c {
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			if attr {
+				continue
+			}
+			esc = "&gt;"
+		case '"':
+			if !attr {
+				continue
+			}
+			esc = "&quot;"
+		case '\t':
+			if !attr {
+				continue
+			}
+			esc = "&#x9;"
+		case '\n':
+			if !attr {
+				continue
+			}
+			esc = "&#xA;"
+		case '\r':
+			esc = "&#xD;"
+		default:
+			continue
+		}
+		w.Write(s[last:i])
+		w.WriteString(esc)
+		last = i + 1
+	}
+	w.Write(s[last:])
+}
@@ -0,0 +1,189 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// canonicalize writes the canonical form of the first element named
+// local in input, or of the whole document if local is empty.
+func canonicalize(t *testing.T, c *Canonicalizer, input, local string) {
+	d := NewDecoder(strings.NewReader(input))
+	d.PreservePrefixes = true
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Token: %v", err)
+		}
+		if local != "" {
+			switch tok := tok.(type) {
+			case StartElement:
+				if depth == 0 && tok.Name.Local != local {
+					continue
+				}
+				depth++
+			case EndElement:
+				if depth == 0 {
+					continue
+				}
+				depth--
+			default:
+				if depth == 0 {
+					continue
+				}
+			}
+		}
+		if err := c.EncodeToken(tok); err != nil {
+			t.Fatalf("EncodeToken: %v", err)
+		}
+		if local != "" && depth == 0 {
+			break
+		}
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+}
+
+// Example from section 2.2 of the Exclusive XML Canonicalization
+// specification.
+const c14nSpecInput = `<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"/>
+  </n1:elem2>
+</n0:local>`
+
+const c14nDocInput = `<?xml version="1.0"?>
+<!DOCTYPE doc>
+<?pi-before data?>
+<!-- c1 -->
+<doc xmlns="urn:a" b="2" a="1&amp;&lt;&gt;&quot;&#9;&#10;&#13;" xmlns:z="urn:z" z:c="3" xmlns:y="urn:y" y:d='4'><e xmlns=""><![CDATA[<&>]]>&#13;</e><z:f xmlns:y="urn:y"/><!-- c3 --></doc>
+<!-- c2 -->
+`
+
+var c14nTests = []struct {
+	input     string
+	element   string
+	comments  bool
+	inclusive []string
+	context   []Attr
+	want      string
+}{
+	{
+		input:   c14nSpecInput,
+		element: "elem2",
+		want: `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>`,
+	},
+	{
+		input: c14nSpecInput,
+		want: `<n0:local xmlns:n0="foo:bar">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>
+</n0:local>`,
+	},
+	{
+		input:     c14nSpecInput,
+		element:   "elem2",
+		inclusive: []string{"n0", "#default"},
+		context:   []Attr{{Name{Space: "xmlns", Local: "n0"}, "foo:bar"}},
+		want: `<n1:elem2 xmlns:n0="foo:bar" xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>`,
+	},
+	{
+		input: c14nDocInput,
+		want: "<?pi-before data?>\n" +
+			`<doc xmlns="urn:a" xmlns:y="urn:y" xmlns:z="urn:z" a="1&amp;&lt;>&quot;&#x9;&#xA;&#xD;" b="2" y:d="4" z:c="3"><e xmlns="">&lt;&amp;&gt;&#xD;</e><z:f></z:f></doc>`,
+	},
+	{
+		input:    c14nDocInput,
+		comments: true,
+		want: "<?pi-before data?>\n<!-- c1 -->\n" +
+			`<doc xmlns="urn:a" xmlns:y="urn:y" xmlns:z="urn:z" a="1&amp;&lt;>&quot;&#x9;&#xA;&#xD;" b="2" y:d="4" z:c="3"><e xmlns="">&lt;&amp;&gt;&#xD;</e><z:f></z:f><!-- c3 --></doc>` +
+			"\n<!-- c2 -->",
+	},
+	{
+		input:     c14nDocInput,
+		element:   "f",
+		inclusive: []string{"y", "#default"},
+		want:      `<z:f xmlns:y="urn:y" xmlns:z="urn:z"></z:f>`,
+	},
+	{
+		input:     c14nDocInput,
+		element:   "f",
+		inclusive: []string{"y", "#default"},
+		context:   []Attr{{Name{Local: "xmlns"}, "urn:a"}},
+		want:      `<z:f xmlns="urn:a" xmlns:y="urn:y" xmlns:z="urn:z"></z:f>`,
+	},
+	{
+		input: `<r xmlns:a="urn:x"><b:e xmlns:b="urn:x"><a:f a:at="1"/></b:e></r>`,
+		want:  `<r><b:e xmlns:b="urn:x"><a:f xmlns:a="urn:x" a:at="1"></a:f></b:e></r>`,
+	},
+}
+
+func TestCanonicalizer(t *testing.T) {
+	for i, tt := range c14nTests {
+		var buf bytes.Buffer
+		c := NewCanonicalizer(&buf)
+		c.WithComments = tt.comments
+		c.InclusivePrefixes = tt.inclusive
+		c.Context = tt.context
+		canonicalize(t, c, tt.input, tt.element)
+		if got := buf.String(); got != tt.want {
+			t.Errorf("#%d: got:\n%s\nwant:\n%s", i, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalizerErrors(t *testing.T) {
+	tests := []struct {
+		toks []Token
+		want string
+	}{
+		{[]Token{StartElement{Name: Name{Local: "a"}}, EndElement{Name{Local: "b"}}}, "xml: end tag </b> does not match start tag <a>"},
+		{[]Token{EndElement{Name{Local: "b"}}}, "xml: end tag </b> without start tag"},
+		{[]Token{StartElement{Name: Name{Local: "a"}, Attr: []Attr{{Name{Space: "urn:x", Local: "b"}, ""}}}}, "xml: no prefix for attribute b in name space urn:x"},
+	}
+	for i, tt := range tests {
+		c := NewCanonicalizer(io.Discard)
+		var err error
+		for _, tok := range tt.toks {
+			if err = c.EncodeToken(tok); err != nil {
+				break
+			}
+		}
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("#%d: got error %v, want %q", i, err, tt.want)
+		}
+	}
+}
+
+func TestCanonicalizerAttrErrorState(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewCanonicalizer(&buf)
+	a := Name{Local: "a"}
+	bad := StartElement{Name: a, Attr: []Attr{{Name{Space: "urn:x", Local: "b"}, ""}}}
+	if err := c.EncodeToken(bad); err == nil {
+		t.Fatal("EncodeToken of start tag with unbound attribute succeeded")
+	}
+	if err := c.EncodeToken(EndElement{a}); err == nil {
+		t.Error("EncodeToken of end tag for failed start tag succeeded")
+	}
+	c.Flush()
+	if buf.Len() != 0 {
+		t.Errorf("output = %q, want none", buf.String())
+	}
+}
@@ -152,6 +152,27 @@ func (enc *Encoder) Indent(prefix, indent string) {
 	enc.p.indent = indent
 }
 
+// SetPreservePrefixes sets whether the encoder writes name space
+// prefixes as given in the names it encodes, instead of inventing them.
+//
+// By default, an element name with a non-empty Space is written with an
+// xmlns attribute declaring it as the default name space, attributes in
+// a name space use prefixes derived from the name space URL, and xmlns
+// attributes in a StartElement are written like any other attributes.
+//
+// In prefix-preserving mode, xmlns attributes in a StartElement (those
+// named xmlns, or with Space "xmlns", as reported by Decoder.Token) are
+// written as given and declare prefixes for the element and its
+// descendants, but those that repeat the binding of their prefix in scope
+// are omitted. Each name is then written with the prefix of the innermost
+// declaration for its Space, the default name space counting only for
+// element names. A declaration is added only for a Space that has none.
+// Combined with a Decoder that has PreservePrefixes set, this writes the
+// document's original prefixes and declarations.
+func (enc *Encoder) SetPreservePrefixes(on bool) {
+	enc.p.preservePrefixes = on
+}
+
 // Encode writes the XML encoding of v to the stream.
 //
 // See the documentation for Marshal for details about the conversion
@@ -313,6 +334,24 @@ type printer struct {
 	attrPrefix map[string]string // map name space -> prefix
 	prefixes   []string
 	tags       []Name
+
+	// Prefix-preserving mode state. nsScope holds the in-scope prefix
+	// bindings, and nsTags the prefix of each open element and the
+	// length of nsScope before it.
+	preservePrefixes bool
+	nsScope          []nsBinding
+	nsTags           []nsTag
+}
+
+type nsTag struct {
+	prefix string
+	scope  int
+}
+
+// An nsBinding binds a name space prefix ("" for the default
+// name space) to a URL.
+type nsBinding struct {
+	prefix, url string
 }
 
 // createAttrPrefix finds the name space prefix attribute to use for the given name space,
@@ -336,22 +375,7 @@ func (p *printer) createAttrPrefix(url string) string {
 		p.attrNS = make(map[string]string)
 	}
 
-	// Pick a name. We try to use the final element of the path
-	// but fall back to _.
-	prefix := strings.TrimRight(url, "/")
-	if i := strings.LastIndex(prefix, "/"); i >= 0 {
-		prefix = prefix[i+1:]
-	}
-	if prefix == "" || !isName([]byte(prefix)) || strings.Contains(prefix, ":") {
-		prefix = "_"
-	}
-	// xmlanything is reserved and any variant of it regardless of
-	// case should be matched, so:
-	//    (('X'|'x') ('M'|'m') ('L'|'l'))
-	// See Section 2.3 of https://www.w3.org/TR/REC-xml/
-	if len(prefix) >= 3 && strings.EqualFold(prefix[:3], "xml") {
-		prefix = "_" + prefix
-	}
+	prefix := prefixForURL(url)
 	if p.attrNS[prefix] != "" {
 		// Name is taken. Find a better one.
 		for p.seq++; ; p.seq++ {
@@ -376,6 +400,73 @@ func (p *printer) createAttrPrefix(url string) string {
 	return prefix
 }
 
+// prefixForURL returns the prefix to try first when a prefix
+// must be invented for the name space url.
+func prefixForURL(url string) string {
+	// Pick a name. We try to use the final element of the path
+	// but fall back to _.
+	prefix := strings.TrimRight(url, "/")
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		prefix = prefix[i+1:]
+	}
+	if prefix == "" || !isName([]byte(prefix)) || strings.Contains(prefix, ":") {
+		prefix = "_"
+	}
+	// xmlanything is reserved and any variant of it regardless of
+	// case should be matched, so:
+	//    (('X'|'x') ('M'|'m') ('L'|'l'))
+	// See Section 2.3 of https://www.w3.org/TR/REC-xml/
+	if len(prefix) >= 3 && strings.EqualFold(prefix[:3], "xml") {
+		prefix = "_" + prefix
+	}
+	return prefix
+}
+
+// lookupPrefix returns the URL bound to prefix in prefix-preserving mode.
+func (p *printer) lookupPrefix(prefix string) (url string, ok bool) {
+	if prefix == xmlPrefix {
+		return xmlURL, true
+	}
+	return lookup(p.nsScope, prefix)
+}
+
+// isBound reports whether prefix is bound in prefix-preserving mode.
+func (p *printer) isBound(prefix string) bool {
+	_, ok := p.lookupPrefix(prefix)
+	return ok
+}
+
+// boundPrefix returns the prefix of the innermost of bindings that
+// binds a prefix to url and is not shadowed by a later binding.
+// The default name space is considered only for element names.
+func boundPrefix(bindings []nsBinding, url string, isElementName bool) (string, bool) {
+	for i := len(bindings) - 1; i >= 0; i-- {
+		b := bindings[i]
+		if b.url != url || b.prefix == "" && !isElementName {
+			continue
+		}
+		if u, _ := lookup(bindings, b.prefix); u == url {
+			return b.prefix, true
+		}
+	}
+	return "", false
+}
+
+// declarePrefix writes a declaration binding prefix to url
+// and records it in the scope of the current element.
+func (p *printer) declarePrefix(prefix, url string) {
+	p.nsScope = append(p.nsScope, nsBinding{prefix, url})
+	if prefix == "" {
+		p.WriteString(` xmlns="`)
+	} else {
+		p.WriteString(` xmlns:`)
+		p.WriteString(prefix)
+		p.WriteString(`="`)
+	}
+	p.EscapeString(url)
+	p.WriteByte('"')
+}
+
 // deleteAttrPrefix removes an attribute name space prefix.
 func (p *printer) deleteAttrPrefix(prefix string) {
 	delete(p.attrPrefix, p.attrNS[prefix])
@@ -693,6 +784,9 @@ func (p *printer) writeStart(start *StartElement) error {
 	if start.Name.Local == "" {
 		return fmt.Errorf("xml: start tag with no name")
 	}
+	if p.preservePrefixes {
+		return p.writeStartPrefixed(start)
+	}
 
 	p.tags = append(p.tags, start.Name)
 	p.markPrefix()
@@ -727,6 +821,105 @@ func (p *printer) writeStart(start *StartElement) error {
 	return nil
 }
 
+// writeStartPrefixed writes the given start element in
+// prefix-preserving mode.
+func (p *printer) writeStartPrefixed(start *StartElement) error {
+	mark := len(p.nsScope)
+
+	// Declarations in the element apply to the element's own name,
+	// so bind them before choosing its prefix.
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Space == xmlnsPrefix && attr.Name.Local != "":
+			p.nsScope = append(p.nsScope, nsBinding{attr.Name.Local, attr.Value})
+		case attr.Name.Space == "" && attr.Name.Local == xmlnsPrefix:
+			p.nsScope = append(p.nsScope, nsBinding{"", attr.Value})
+		}
+	}
+
+	name := start.Name
+	prefix, declare := "", false
+	switch {
+	case name.Space == xmlURL:
+		prefix = xmlPrefix
+	case name.Space == "":
+		url, _ := p.lookupPrefix("")
+		declare = url != ""
+	default:
+		var ok bool
+		prefix, ok = boundPrefix(p.nsScope, name.Space, true)
+		declare = !ok
+	}
+	p.tags = append(p.tags, name)
+	p.nsTags = append(p.nsTags, nsTag{prefix, mark})
+	p.writeIndent(1)
+	p.WriteByte('<')
+	if prefix != "" {
+		p.WriteString(prefix)
+		p.WriteByte(':')
+	}
+	p.WriteString(name.Local)
+
+	// Write the given declarations that change the binding
+	// of their prefix, then any needed for the name.
+	for i, b := range p.nsScope[mark:] {
+		if url, ok := lookup(p.nsScope[:mark+i], b.prefix); ok && url == b.url {
+			continue
+		}
+		p.WriteString(" xmlns")
+		if b.prefix != "" {
+			p.WriteByte(':')
+			p.WriteString(b.prefix)
+		}
+		p.WriteString(`="`)
+		p.EscapeString(b.url)
+		p.WriteByte('"')
+	}
+	if declare {
+		p.declarePrefix("", name.Space)
+	}
+
+	// Attributes
+	for _, attr := range start.Attr {
+		name := attr.Name
+		if name.Local == "" || name.Space == xmlnsPrefix || name.Space == "" && name.Local == xmlnsPrefix {
+			continue
+		}
+		prefix := ""
+		switch name.Space {
+		case "":
+		case xmlURL:
+			prefix = xmlPrefix
+		default:
+			var ok bool
+			if prefix, ok = boundPrefix(p.nsScope, name.Space, false); !ok {
+				prefix = prefixForURL(name.Space)
+				if p.isBound(prefix) {
+					// Name is taken. Find a better one.
+					base := prefix
+					for p.seq++; ; p.seq++ {
+						if prefix = base + "_" + strconv.Itoa(p.seq); !p.isBound(prefix) {
+							break
+						}
+					}
+				}
+				p.declarePrefix(prefix, name.Space)
+			}
+		}
+		p.WriteByte(' ')
+		if prefix != "" {
+			p.WriteString(prefix)
+			p.WriteByte(':')
+		}
+		p.WriteString(name.Local)
+		p.WriteString(`="`)
+		p.EscapeString(attr.Value)
+		p.WriteByte('"')
+	}
+	p.WriteByte('>')
+	return nil
+}
+
 func (p *printer) writeEnd(name Name) error {
 	if name.Local == "" {
 		return fmt.Errorf("xml: end tag with no name")
@@ -734,10 +927,11 @@ func (p *printer) writeEnd(name Name) error {
 	if len(p.tags) == 0 || p.tags[len(p.tags)-1].Local == "" {
 		return fmt.Errorf("xml: end tag </%s> without start tag", name.Local)
 	}
-	if top := p.tags[len(p.tags)-1]; top != name {
-		if top.Local != name.Local {
-			return fmt.Errorf("xml: end tag </%s> does not match start tag <%s>", name.Local, top.Local)
-		}
+	top := p.tags[len(p.tags)-1]
+	if top.Local != name.Local {
+		return fmt.Errorf("xml: end tag </%s> does not match start tag <%s>", name.Local, top.Local)
+	}
+	if top.Space != name.Space {
 		return fmt.Errorf("xml: end tag </%s> in namespace %s does not match start tag <%s> in namespace %s", name.Local, name.Space, top.Local, top.Space)
 	}
 	p.tags = p.tags[:len(p.tags)-1]
@@ -745,6 +939,18 @@ func (p *printer) writeEnd(name Name) error {
 	p.writeIndent(-1)
 	p.WriteByte('<')
 	p.WriteByte('/')
+	if p.preservePrefixes {
+		tag := p.nsTags[len(p.nsTags)-1]
+		p.nsTags = p.nsTags[:len(p.nsTags)-1]
+		p.nsScope = p.nsScope[:tag.scope]
+		if tag.prefix != "" {
+			p.WriteString(tag.prefix)
+			p.WriteByte(':')
+		}
+		p.WriteString(name.Local)
+		p.WriteByte('>')
+		return nil
+	}
 	p.WriteString(name.Local)
 	p.WriteByte('>')
 	p.popPrefix()
@@ -2490,3 +2490,80 @@ func TestInvalidXMLName(t *testing.T) {
 		t.Errorf("error %q does not contain %q", err, want)
 	}
 }
+
+var preservePrefixesTests = []struct {
+	input, want string
+}{
+	{
+		input: `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="urn:m">` +
+			`<soap:Body m:id="1"><m:Get xmlns="urn:d"><Item xml:lang="en">x</Item><m:Ref/></m:Get></soap:Body></soap:Envelope>`,
+		want: `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="urn:m">` +
+			`<soap:Body m:id="1"><m:Get xmlns="urn:d"><Item xml:lang="en">x</Item><m:Ref></m:Ref></m:Get></soap:Body></soap:Envelope>`,
+	},
+	{
+		// Prefixes bound to the same name space.
+		input: `<r xmlns:a="urn:x"><b:e xmlns:b="urn:x"><a:f a:at="1"/><b:g/></b:e><a:h/></r>`,
+		want:  `<r xmlns:a="urn:x"><b:e xmlns:b="urn:x"><a:f a:at="1"></a:f><b:g></b:g></b:e><a:h></a:h></r>`,
+	},
+	{
+		input: `<r xmlns="urn:x" xmlns:a="urn:x"><a:e><f/></a:e><a:g xmlns:a="urn:x"/></r>`,
+		want:  `<r xmlns="urn:x" xmlns:a="urn:x"><a:e><f></f></a:e><a:g></a:g></r>`,
+	},
+}
+
+func TestDecodeEncodePreservePrefixes(t *testing.T) {
+	for _, tt := range preservePrefixesTests {
+		dec := NewDecoder(strings.NewReader(tt.input))
+		dec.PreservePrefixes = true
+		var out bytes.Buffer
+		enc := NewEncoder(&out)
+		enc.SetPreservePrefixes(true)
+		for {
+			tok, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("dec.Token: %v", err)
+			}
+			if err := enc.EncodeToken(tok); err != nil {
+				t.Fatalf("enc.EncodeToken(%#v): %v", tok, err)
+			}
+		}
+		if err := enc.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != tt.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeTokenPreservePrefixes(t *testing.T) {
+	var out bytes.Buffer
+	enc := NewEncoder(&out)
+	enc.SetPreservePrefixes(true)
+	toks := []Token{
+		StartElement{Name: Name{"urn:a", "root"}, Attr: []Attr{{Name{"xmlns", "a"}, "urn:a"}}},
+		StartElement{Name: Name{"urn:a", "child"}, Attr: []Attr{
+			{Name{"urn:a", "x"}, "1"},
+			{Name{"urn:b", "y"}, "2"},
+		}},
+		StartElement{Name: Name{"urn:c", "leaf"}},
+		EndElement{Name{"urn:c", "leaf"}},
+		EndElement{Name{"urn:a", "child"}},
+		EndElement{Name{"urn:a", "root"}},
+	}
+	for _, tok := range toks {
+		if err := enc.EncodeToken(tok); err != nil {
+			t.Fatalf("enc.EncodeToken(%#v): %v", tok, err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	const want = `<a:root xmlns:a="urn:a"><a:child a:x="1" xmlns:_="urn:b" _:y="2"><leaf xmlns="urn:c"></leaf></a:child></a:root>`
+	if got := out.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
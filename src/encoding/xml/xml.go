@@ -206,6 +206,18 @@ type Decoder struct {
 	// the attribute xmlns="DefaultSpace".
 	DefaultSpace string
 
+	// PreservePrefixes causes Token to add name space declarations,
+	// as xmlns attributes, to each StartElement that doesn't declare the
+	// prefixes used by its names itself, so that the prefix used for each
+	// name is the last one the element declares for its name space.
+	// An Encoder in prefix-preserving mode and a Canonicalizer write names
+	// with those prefixes, so that they reproduce the prefixes of the
+	// document, unless an element and its attributes use different
+	// prefixes for the same name space. The added declarations repeat
+	// bindings already in scope, so they don't change the meaning of the
+	// document.
+	PreservePrefixes bool
+
 	r              io.ByteReader
 	t              TokenReader
 	buf            bytes.Buffer
@@ -322,10 +334,21 @@ func (d *Decoder) Token() (Token, error) {
 			}
 		}
 
+		var prefixes []string
+		if d.PreservePrefixes {
+			prefixes = make([]string, 0, 1+len(t1.Attr))
+			prefixes = append(prefixes, t1.Name.Space)
+			for _, a := range t1.Attr {
+				prefixes = append(prefixes, a.Name.Space)
+			}
+		}
 		d.translate(&t1.Name, true)
 		for i := range t1.Attr {
 			d.translate(&t1.Attr[i].Name, false)
 		}
+		if d.PreservePrefixes {
+			d.declarePrefixes(&t1, prefixes)
+		}
 		d.pushElement(t1.Name)
 		t = t1
 
@@ -366,6 +389,57 @@ func (d *Decoder) translate(n *Name, isElementName bool) {
 	}
 }
 
+// declarePrefixes adds declarations to start where the prefixes used
+// in the document, which are prefixes[0] for the element name and
+// prefixes[i+1] for start.Attr[i], are not the last ones declared by
+// the element for the name space of the name. See PreservePrefixes.
+func (d *Decoder) declarePrefixes(start *StartElement, prefixes []string) {
+	// The element name comes last, so that its prefix
+	// wins over those of the attributes.
+	n := len(start.Attr)
+	for i := 0; i <= n; i++ {
+		name, prefix, isElementName := start.Name, prefixes[0], true
+		if i < n {
+			name, prefix, isElementName = start.Attr[i].Name, prefixes[i+1], false
+		}
+		// Declarations, names in no name space, unknown
+		// prefixes and the xml prefix need no declaration.
+		if isDeclaration(name) || name.Space == prefix || name.Space == xmlURL {
+			continue
+		}
+		if p, ok := lastDeclared(start.Attr, name.Space, isElementName); ok && p == prefix {
+			continue
+		}
+		v, ok := d.ns[prefix]
+		d.pushNs(prefix, v, ok)
+		d.ns[prefix] = name.Space
+		decl := Name{Space: xmlnsPrefix, Local: prefix}
+		if prefix == "" {
+			decl = Name{Local: xmlnsPrefix}
+		}
+		start.Attr = append(start.Attr, Attr{decl, name.Space})
+	}
+}
+
+// lastDeclared returns the prefix of the last declaration in attrs
+// that binds a prefix to url. The default name space is considered
+// only for element names.
+func lastDeclared(attrs []Attr, url string, isElementName bool) (string, bool) {
+	for i := len(attrs) - 1; i >= 0; i-- {
+		a := attrs[i]
+		if a.Value != url {
+			continue
+		}
+		switch {
+		case a.Name.Space == xmlnsPrefix:
+			return a.Name.Local, true
+		case a.Name.Space == "" && a.Name.Local == xmlnsPrefix && isElementName:
+			return "", true
+		}
+	}
+	return "", false
+}
+
 func (d *Decoder) switchToReader(r io.Reader) {
 	// Get efficient byte at a time reader.
 	// Assume that if reader has its own
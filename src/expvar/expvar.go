@@ -29,6 +29,7 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"runtime/metrics"
 	"sort"
 	"strconv"
 	"strings"
@@ -360,6 +361,54 @@ func memstats() interface{} {
 	return *stats
 }
 
+// RuntimeMetrics returns the current values of all the metrics
+// supported by runtime/metrics.All, for use with Func. Unlike the
+// memstats variable, computing them does not stop the world.
+// They are not published by default; to publish them as "metrics", use
+//
+//	expvar.Publish("metrics", expvar.Func(expvar.RuntimeMetrics))
+//
+// The value is a map with an entry for each metric, keyed by its name.
+// Scalar metrics are numbers. Histograms are maps from the upper bound
+// of each non-empty bucket, formatted as by strconv.FormatFloat,
+// to the count of the bucket.
+func RuntimeMetrics() interface{} {
+	descs := metrics.All()
+	samples := make([]metrics.Sample, len(descs))
+	for i := range samples {
+		samples[i].Name = descs[i].Name
+	}
+	metrics.Read(samples)
+	m := make(map[string]interface{}, len(samples))
+	for _, s := range samples {
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			m[s.Name] = s.Value.Uint64()
+		case metrics.KindFloat64:
+			m[s.Name] = s.Value.Float64()
+		case metrics.KindFloat64Histogram:
+			h := s.Value.Float64Histogram()
+			buckets := make(map[string]uint64)
+			for i, n := range h.Counts {
+				if n != 0 {
+					buckets[formatBound(h.Buckets[i+1])] = n
+				}
+			}
+			m[s.Name] = buckets
+		}
+	}
+	return m
+}
+
+// formatBound formats a histogram bucket boundary,
+// which may be infinite, for use as a JSON key.
+func formatBound(b float64) string {
+	if math.IsInf(b, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(b, 'g', -1, 64)
+}
+
 func init() {
 	http.HandleFunc("/debug/vars", expvarHandler)
 	Publish("cmdline", Func(cmdline))
@@ -442,6 +442,29 @@ func TestFunc(t *testing.T) {
 	}
 }
 
+func TestRuntimeMetrics(t *testing.T) {
+	runtime.GC()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(Func(RuntimeMetrics).String()), &m); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := m["/sched/goroutines:goroutines"].(float64); !ok || n < 1 {
+		t.Errorf("/sched/goroutines:goroutines = %v, want a positive number", m["/sched/goroutines:goroutines"])
+	}
+	h, ok := m["/gc/pauses:seconds"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("/gc/pauses:seconds = %v, want an object", m["/gc/pauses:seconds"])
+	}
+	if len(h) == 0 {
+		t.Error("/gc/pauses:seconds has no samples after a GC")
+	}
+	for bound := range h {
+		if _, err := strconv.ParseFloat(bound, 64); err != nil {
+			t.Errorf("/gc/pauses:seconds has bad bucket bound %q", bound)
+		}
+	}
+}
+
 func TestHandler(t *testing.T) {
 	RemoveAll()
 	m := NewMap("map1")
@@ -455,6 +455,12 @@ type Package struct {
 	TestEmbedPatternPos  map[string][]token.Position // line information for TestEmbedPatterns
 	XTestEmbedPatterns   []string                    // patterns from XTestGoFiles
 	XTestEmbedPatternPos map[string][]token.Position // line information for XTestEmbedPatternPos
+
+	// //go:embed patterns from lines with the -modtime option,
+	// whose files' modification times are recorded at build time.
+	EmbedModTimePatterns      []string // patterns from GoFiles, CgoFiles
+	TestEmbedModTimePatterns  []string // patterns from TestGoFiles
+	XTestEmbedModTimePatterns []string // patterns from XTestGoFiles
 }
 
 // IsCommand reports whether the package is considered a
@@ -800,6 +806,9 @@ Found:
 	embedPos := make(map[string][]token.Position)
 	testEmbedPos := make(map[string][]token.Position)
 	xTestEmbedPos := make(map[string][]token.Position)
+	embedModTimePos := make(map[string][]token.Position)
+	testEmbedModTimePos := make(map[string][]token.Position)
+	xTestEmbedModTimePos := make(map[string][]token.Position)
 	importPos := make(map[string][]token.Position)
 	testImportPos := make(map[string][]token.Position)
 	xTestImportPos := make(map[string][]token.Position)
@@ -926,7 +935,7 @@ Found:
 		}
 
 		var fileList *[]string
-		var importMap, embedMap map[string][]token.Position
+		var importMap, embedMap, modTimeMap map[string][]token.Position
 		switch {
 		case isCgo:
 			allTags["cgo"] = true
@@ -934,6 +943,7 @@ Found:
 				fileList = &p.CgoFiles
 				importMap = importPos
 				embedMap = embedPos
+				modTimeMap = embedModTimePos
 			} else {
 				// Ignore imports and embeds from cgo files if cgo is disabled.
 				fileList = &p.IgnoredGoFiles
@@ -942,14 +952,17 @@ Found:
 			fileList = &p.XTestGoFiles
 			importMap = xTestImportPos
 			embedMap = xTestEmbedPos
+			modTimeMap = xTestEmbedModTimePos
 		case isTest:
 			fileList = &p.TestGoFiles
 			importMap = testImportPos
 			embedMap = testEmbedPos
+			modTimeMap = testEmbedModTimePos
 		default:
 			fileList = &p.GoFiles
 			importMap = importPos
 			embedMap = embedPos
+			modTimeMap = embedModTimePos
 		}
 		*fileList = append(*fileList, name)
 		if importMap != nil {
@@ -960,6 +973,9 @@ Found:
 		if embedMap != nil {
 			for _, emb := range info.embeds {
 				embedMap[emb.pattern] = append(embedMap[emb.pattern], emb.pos)
+				if emb.modTime {
+					modTimeMap[emb.pattern] = append(modTimeMap[emb.pattern], emb.pos)
+				}
 			}
 		}
 	}
@@ -972,6 +988,9 @@ Found:
 	p.EmbedPatterns, p.EmbedPatternPos = cleanDecls(embedPos)
 	p.TestEmbedPatterns, p.TestEmbedPatternPos = cleanDecls(testEmbedPos)
 	p.XTestEmbedPatterns, p.XTestEmbedPatternPos = cleanDecls(xTestEmbedPos)
+	p.EmbedModTimePatterns, _ = cleanDecls(embedModTimePos)
+	p.TestEmbedModTimePatterns, _ = cleanDecls(testEmbedModTimePos)
+	p.XTestEmbedModTimePatterns, _ = cleanDecls(xTestEmbedModTimePos)
 
 	p.Imports, p.ImportPos = cleanDecls(importPos)
 	p.TestImports, p.TestImportPos = cleanDecls(testImportPos)
@@ -1360,6 +1379,7 @@ type fileImport struct {
 type fileEmbed struct {
 	pattern string
 	pos     token.Position
+	modTime bool // the line has the -modtime option
 }
 
 // matchFile determines whether the file with the given name in the given directory
@@ -72,7 +72,7 @@ var depsRules = `
 	NONE
 	< container/list, container/ring,
 	  internal/cfg, internal/cpu,
-	  internal/goversion, internal/nettrace,
+	  internal/goversion, internal/nettrace, internal/sais,
 	  unicode/utf8, unicode/utf16, unicode,
 	  unsafe;
 
@@ -212,6 +212,9 @@ var depsRules = `
 	  text/scanner,
 	  text/tabwriter;
 
+	runtime/debug, runtime/trace
+	< sync/errgroup;
+
 	# encodings
 	# core ones do not use fmt.
 	io, strconv
@@ -237,9 +240,9 @@ var depsRules = `
 	< math/big;
 
 	# compression
-	FMT, encoding/binary, hash/adler32, hash/crc32
-	< compress/bzip2, compress/flate, compress/lzw
-	< archive/zip, compress/gzip, compress/zlib;
+	FMT, encoding/binary, hash/adler32, hash/crc32, internal/sais
+	< compress/bzip2, compress/flate, compress/lzw, compress/zstd
+	< compress/gzip, compress/zlib;
 
 	# templates
 	FMT
@@ -259,7 +262,7 @@ var depsRules = `
 	< internal/lazyregexp;
 
 	# suffix array
-	encoding/binary, regexp
+	encoding/binary, internal/sais, regexp
 	< index/suffixarray;
 
 	# executable parsing
@@ -268,6 +271,9 @@ var depsRules = `
 	< debug/elf, debug/gosym, debug/macho, debug/pe, debug/plan9obj, internal/xcoff
 	< DEBUG;
 
+	DEBUG
+	< debug/heapdump;
+
 	# go parser and friends.
 	FMT
 	< go/token
@@ -390,6 +396,10 @@ var depsRules = `
 
 	CGO, fmt, net !< CRYPTO;
 
+	# archive/zip reads WinZip AES encrypted files.
+	CRYPTO, FMT, compress/flate, compress/zstd
+	< archive/zip;
+
 	# CRYPTO-MATH is core bignum-based crypto - no cgo, net; fmt now ok.
 	CRYPTO, FMT, math/big
 	< crypto/rand
@@ -443,6 +453,7 @@ var depsRules = `
 	< net/http/httptrace;
 
 	compress/gzip,
+	compress/zstd,
 	golang.org/x/net/http/httpguts,
 	golang.org/x/net/http/httpproxy,
 	golang.org/x/net/http2/hpack,
@@ -454,9 +465,12 @@ var depsRules = `
 
 	# HTTP-aware packages
 
-	encoding/json, net/http
+	encoding/json, net/http, runtime/metrics
 	< expvar;
 
+	net/http, runtime/metrics
+	< net/http/metrics;
+
 	net/http
 	< net/http/cookiejar, net/http/httputil;
 
@@ -514,6 +528,9 @@ var depsRules = `
 
 	FMT, container/heap, math/rand
 	< internal/trace;
+
+	internal/trace
+	< debug/trace;
 `
 
 // listStdPkgs returns the same list of packages as "go list std".
@@ -474,6 +474,8 @@ func readGoInfo(f io.Reader, info *fileInfo) error {
 
 // parseGoEmbed parses the text following "//go:embed" to extract the glob patterns.
 // It accepts unquoted space-separated patterns as well as double-quoted and back-quoted Go strings.
+// The unquoted words -compress and -modtime before the first pattern are options;
+// the patterns are marked if -modtime is among them.
 // This is based on a similar function in cmd/compile/internal/gc/noder.go;
 // this version calculates position information as well.
 func parseGoEmbed(args string, pos token.Position) ([]fileEmbed, error) {
@@ -488,6 +490,7 @@ func parseGoEmbed(args string, pos token.Position) ([]fileEmbed, error) {
 	}
 
 	var list []fileEmbed
+	modTime := false
 	for trimSpace(); args != ""; trimSpace() {
 		var path string
 		pathPos := pos
@@ -503,6 +506,10 @@ func parseGoEmbed(args string, pos token.Position) ([]fileEmbed, error) {
 			}
 			path = args[:i]
 			trimBytes(i)
+			if len(list) == 0 && (path == "-compress" || path == "-modtime") {
+				modTime = modTime || path == "-modtime"
+				continue
+			}
 
 		case '`':
 			i := strings.Index(args[1:], "`")
@@ -540,7 +547,7 @@ func parseGoEmbed(args string, pos token.Position) ([]fileEmbed, error) {
 				return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args)
 			}
 		}
-		list = append(list, fileEmbed{path, pathPos})
+		list = append(list, fileEmbed{path, pathPos, modTime})
 	}
 	return list, nil
 }
@@ -258,6 +258,17 @@ var readEmbedTests = []struct {
 		 test:4:16:y
 		 test:5:14:z`,
 	},
+	{
+		"package p\nimport \"embed\"\nvar i int\n//go:embed -compress -modtime x \"-y\" -z\nvar files embed.FS",
+		`test:4:31:x:modtime
+		 test:4:33:-y:modtime
+		 test:4:38:-z:modtime`,
+	},
+	{
+		"package p\nimport \"embed\"\nvar i int\n//go:embed -compress -name x\nvar files embed.FS",
+		`test:4:22:-name
+		 test:4:28:x`,
+	},
 	{
 		"package p\nimport \"embed\"\n//go:embed x y z\nvar files embed.FS",
 		`test:3:12:x
@@ -310,6 +321,9 @@ func TestReadEmbed(t *testing.T) {
 		sep := ""
 		for _, emb := range info.embeds {
 			fmt.Fprintf(b, "%s%v:%s", sep, emb.pos, emb.pattern)
+			if emb.modTime {
+				b.WriteString(":modtime")
+			}
 			sep = "\n"
 		}
 		got := b.String()
@@ -54,6 +54,12 @@ func (s *StdSizes) Alignof(T Type) int64 {
 		// is the same as unsafe.Alignof(x[0]), but at least 1."
 		return s.Alignof(t.elem)
 	case *Struct:
+		if len(t.fields) == 0 && isSyncAtomicAlign64(T) {
+			// Special case: sync/atomic.align64 is an empty struct
+			// whose fields make the structs containing them 64-bit
+			// aligned, as in cmd/compile.
+			return 8
+		}
 		// spec: "For a variable x of struct type: unsafe.Alignof(x)
 		// is the largest of the values unsafe.Alignof(x.f) for each
 		// field f of x, but at least 1."
@@ -89,6 +95,16 @@ func (s *StdSizes) Alignof(T Type) int64 {
 	return a
 }
 
+// isSyncAtomicAlign64 reports whether T is sync/atomic.align64.
+func isSyncAtomicAlign64(T Type) bool {
+	named, ok := T.(*Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Name() == "align64" && obj.Pkg() != nil && obj.Pkg().Path() == "sync/atomic"
+}
+
 func (s *StdSizes) Offsetsof(fields []*Var) []int64 {
 	offsets := make([]int64, len(fields))
 	var o int64
@@ -110,3 +110,49 @@ const _ = unsafe.Offsetof(struct{ x int64 }{}.x)
 		_ = conf.Sizes.Alignof(tv.Type)
 	}
 }
+
+func TestAtomicAlign(t *testing.T) {
+	const src = `
+package main
+
+import "sync/atomic"
+
+var s struct {
+	x uint32
+	y atomic.Int64
+	z atomic.Uint64
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	conf := types.Config{
+		Importer: importer.Default(),
+		Sizes:    types.SizesFor("gc", "386"),
+	}
+	if _, err := conf.Check("x", fset, []*ast.File{f}, &info); err != nil {
+		t.Fatal(err)
+	}
+	for _, tv := range info.Types {
+		ts, ok := tv.Type.(*types.Struct)
+		if !ok {
+			continue
+		}
+		var fields []*types.Var
+		for i := 0; i < ts.NumFields(); i++ {
+			fields = append(fields, ts.Field(i))
+		}
+		offsets := conf.Sizes.Offsetsof(fields)
+		if offsets[1] != 8 || offsets[2] != 16 {
+			t.Errorf("OffsetsOf(%v) = %v want %v", ts, offsets, []int{0, 8, 16})
+		}
+		if got := conf.Sizes.Alignof(ts); got != 8 {
+			t.Errorf("Alignof(%v) = %d want 8", ts, got)
+		}
+		return
+	}
+	t.Fatal("failed to find a struct type")
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"internal/sais"
 	"io"
 	"math"
 	"regexp"
@@ -77,10 +78,10 @@ func New(data []byte) *Index {
 	ix := &Index{data: data}
 	if len(data) <= maxData32 {
 		ix.sa.int32 = make([]int32, len(data))
-		text_32(data, ix.sa.int32)
+		sais.Text32(data, ix.sa.int32)
 	} else {
 		ix.sa.int64 = make([]int64, len(data))
-		text_64(data, ix.sa.int64)
+		sais.Text64(data, ix.sa.int64)
 	}
 	return ix
 }
@@ -7,6 +7,7 @@ package suffixarray
 import (
 	"bytes"
 	"fmt"
+	"internal/sais"
 	"io/fs"
 	"math/rand"
 	"os"
@@ -317,7 +318,7 @@ func TestIndex64(t *testing.T) {
 func TestNew32(t *testing.T) {
 	test(t, func(x []byte) []int {
 		sa := make([]int32, len(x))
-		text_32(x, sa)
+		sais.Text32(x, sa)
 		out := make([]int, len(sa))
 		for i, v := range sa {
 			out[i] = int(v)
@@ -329,7 +330,7 @@ func TestNew32(t *testing.T) {
 func TestNew64(t *testing.T) {
 	test(t, func(x []byte) []int {
 		sa := make([]int64, len(x))
-		text_64(x, sa)
+		sais.Text64(x, sa)
 		out := make([]int, len(sa))
 		for i, v := range sa {
 			out[i] = int(v)
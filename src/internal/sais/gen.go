@@ -32,7 +32,7 @@ func main() {
 
 	var buf bytes.Buffer
 	buf.Write(data[:x])
-	buf.WriteString("\n\n// Code generated by go generate; DO NOT EDIT.\n\npackage suffixarray\n")
+	buf.WriteString("\n\n// Code generated by go generate; DO NOT EDIT.\n\npackage sais\n")
 
 	for {
 		x := bytes.Index(data, []byte("\nfunc "))
@@ -120,14 +120,28 @@
 
 //go:generate go run gen.go
 
-package suffixarray
+// Package sais computes suffix arrays by induced sorting.
+// It is used by index/suffixarray and compress/bzip2.
+package sais
+
+// Text32 stores the suffix array for text in sa.
+// It requires that len(text) fit in an int32,
+// that len(sa) == len(text) and that the caller zero sa.
+func Text32(text []byte, sa []int32) {
+	text_32(text, sa)
+}
+
+// Text64 is like Text32 but for suffix arrays of type []int64.
+func Text64(text []byte, sa []int64) {
+	text_64(text, sa)
+}
 
 // text_32 returns the suffix array for the input text.
 // It requires that len(text) fit in an int32
 // and that the caller zero sa.
 func text_32(text []byte, sa []int32) {
 	if int(int32(len(text))) != len(text) || len(text) != len(sa) {
-		panic("suffixarray: misuse of text_32")
+		panic("sais: misuse of text_32")
 	}
 	sais_8_32(text, 256, sa, make([]int32, 2*256))
 }
@@ -142,7 +156,7 @@ func text_32(text []byte, sa []int32) {
 // If sais_8_32 modifies tmp, it sets tmp[0] = -1 on return.
 func sais_8_32(text []byte, textMax int, sa, tmp []int32) {
 	if len(sa) != len(text) || len(tmp) < int(textMax) {
-		panic("suffixarray: misuse of sais_8_32")
+		panic("sais: misuse of sais_8_32")
 	}
 
 	// Trivial base cases. Sorting 0 or 1 things is easy.
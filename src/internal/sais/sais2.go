@@ -4,18 +4,18 @@
 
 // Code generated by go generate; DO NOT EDIT.
 
-package suffixarray
+package sais
 
 func text_64(text []byte, sa []int64) {
 	if int(int64(len(text))) != len(text) || len(text) != len(sa) {
-		panic("suffixarray: misuse of text_64")
+		panic("sais: misuse of text_64")
 	}
 	sais_8_64(text, 256, sa, make([]int64, 2*256))
 }
 
 func sais_8_64(text []byte, textMax int, sa, tmp []int64) {
 	if len(sa) != len(text) || len(tmp) < int(textMax) {
-		panic("suffixarray: misuse of sais_8_64")
+		panic("sais: misuse of sais_8_64")
 	}
 
 	// Trivial base cases. Sorting 0 or 1 things is easy.
@@ -77,7 +77,7 @@ func sais_8_64(text []byte, textMax int, sa, tmp []int64) {
 
 func sais_32(text []int32, textMax int, sa, tmp []int32) {
 	if len(sa) != len(text) || len(tmp) < int(textMax) {
-		panic("suffixarray: misuse of sais_32")
+		panic("sais: misuse of sais_32")
 	}
 
 	// Trivial base cases. Sorting 0 or 1 things is easy.
@@ -139,7 +139,7 @@ func sais_32(text []int32, textMax int, sa, tmp []int32) {
 
 func sais_64(text []int64, textMax int, sa, tmp []int64) {
 	if len(sa) != len(text) || len(tmp) < int(textMax) {
-		panic("suffixarray: misuse of sais_64")
+		panic("sais: misuse of sais_64")
 	}
 
 	// Trivial base cases. Sorting 0 or 1 things is easy.
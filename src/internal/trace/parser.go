@@ -14,6 +14,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	_ "unsafe"
@@ -43,6 +44,7 @@ type Event struct {
 	Stk   []*Frame  // stack trace (can be empty)
 	Args  [3]uint64 // event-type-specific arguments
 	SArgs []string  // event-type-specific string args
+	state bool      // part of the state snapshot at the start of a generation
 	// linked event (can be nil), depends on event type:
 	// for GCStart: the GCStop
 	// for GCSTWStart: the GCSTWDone
@@ -100,11 +102,11 @@ func Parse(r io.Reader, bin string) (ParseResult, error) {
 // parse parses, post-processes and verifies the trace. It returns the
 // trace version and the list of events.
 func parse(r io.Reader, bin string) (int, ParseResult, error) {
-	ver, rawEvents, strings, err := readTrace(r)
+	ver, gens, err := readTrace(r)
 	if err != nil {
 		return 0, ParseResult{}, err
 	}
-	events, stacks, err := parseEvents(ver, rawEvents, strings)
+	events, stacks, err := parseGenerations(ver, gens)
 	if err != nil {
 		return 0, ParseResult{}, err
 	}
@@ -127,17 +129,79 @@ func parse(r io.Reader, bin string) (int, ParseResult, error) {
 	return ver, ParseResult{Events: events, Stacks: stacks}, nil
 }
 
+// parseGenerations parses each generation of the trace and joins them.
+//
+// Every generation starts with a snapshot of the goroutine states, which
+// is only needed for the first one: the events of the previous generation
+// already establish the states of later ones. Stack IDs are renumbered so
+// that they are unique across generations, and timestamps are made
+// continuous, as each generation has its own timer frequency.
+func parseGenerations(ver int, gens []rawGeneration) (events []*Event, stacks map[uint64][]*Frame, err error) {
+	stacks = make(map[uint64][]*Frame)
+	var stkOff, maxStk uint64
+	var lastTicks, lastTs int64
+	for i, gen := range gens {
+		evs, stks, ticksPerSec, err := parseEvents(ver, gen.events, gen.strings)
+		if err != nil {
+			return nil, nil, err
+		}
+		for id, stk := range stks {
+			stacks[id+stkOff] = stk
+			if id > maxStk {
+				maxStk = id
+			}
+		}
+		// Translate cpu ticks to real time.
+		// Use floating point to avoid integer overflows.
+		freq := 1e9 / float64(ticksPerSec)
+		start := lastTs
+		if i > 0 {
+			start += int64(float64(evs[0].Ts-lastTicks) * freq)
+			if start < lastTs {
+				start = lastTs
+			}
+		}
+		first := evs[0].Ts
+		for _, ev := range evs {
+			if i > 0 && ev.state {
+				continue
+			}
+			if ev.StkID != 0 {
+				ev.StkID += stkOff
+			}
+			if ev.Type == EvGoCreate && ev.Args[1] != 0 {
+				ev.Args[1] += stkOff
+			}
+			lastTicks = ev.Ts
+			ev.Ts = start + int64(float64(ev.Ts-first)*freq)
+			lastTs = ev.Ts
+			events = append(events, ev)
+		}
+		stkOff += maxStk
+		maxStk = 0
+	}
+	return events, stacks, nil
+}
+
 // rawEvent is a helper type used during parsing.
 type rawEvent struct {
 	off   int
 	typ   byte
 	args  []uint64
 	sargs []string
+	state bool
+}
+
+// rawGeneration holds the raw events of a trace generation and the string
+// dictionary they refer to. Traces before Go 1.17 have a single generation.
+type rawGeneration struct {
+	events  []rawEvent
+	strings map[uint64]string
 }
 
 // readTrace does wire-format parsing and verification.
 // It does not care about specific event types and argument meaning.
-func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]string, err error) {
+func readTrace(r io.Reader) (ver int, gens []rawGeneration, err error) {
 	// Read and validate trace header.
 	var buf [16]byte
 	off, err := io.ReadFull(r, buf[:])
@@ -150,7 +214,7 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 		return
 	}
 	switch ver {
-	case 1005, 1007, 1008, 1009, 1010, 1011:
+	case 1005, 1007, 1008, 1009, 1010, 1011, 1017:
 		// Note: When adding a new version, add canned traces
 		// from the old version to the test suite using mkcanned.bash.
 		break
@@ -160,7 +224,13 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 	}
 
 	// Read events.
-	strings = make(map[uint64]string)
+	gen := &rawGeneration{strings: make(map[uint64]string)}
+	var genSeq uint64
+	defer func() {
+		if err == nil && (len(gen.events) > 0 || len(gens) == 0) {
+			gens = append(gens, *gen)
+		}
+	}()
 	for {
 		// Read event type and number of arguments (1 byte).
 		off0 := off
@@ -197,7 +267,7 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 				err = fmt.Errorf("string at offset %d has invalid id 0", off)
 				return
 			}
-			if strings[id] != "" {
+			if gen.strings[id] != "" {
 				err = fmt.Errorf("string at offset %d has duplicate id %v", off, id)
 				return
 			}
@@ -222,7 +292,7 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 				return
 			}
 			off += n
-			strings[id] = string(buf)
+			gen.strings[id] = string(buf)
 			continue
 		}
 		ev := rawEvent{typ: typ, off: off0}
@@ -264,12 +334,49 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 			var s string
 			s, off, err = readStr(r, off)
 			ev.sargs = append(ev.sargs, s)
+		case EvGeneration:
+			if genSeq != 0 && ev.args[0] != genSeq+1 {
+				err = fmt.Errorf("generation %v follows generation %v at offset 0x%x", ev.args[0], genSeq, off0)
+				return
+			}
+			genSeq = ev.args[0]
+			// The marker starts a batch, which belongs to the new generation.
+			var batch []rawEvent
+			if n := len(gen.events); n > 0 && gen.events[n-1].typ == EvBatch {
+				batch = gen.events[n-1:]
+				gen.events = gen.events[:n-1]
+			}
+			if len(gen.events) > 0 {
+				gens = append(gens, *gen)
+				gen = &rawGeneration{strings: make(map[uint64]string)}
+			}
+			gen.events = append([]rawEvent(nil), batch...)
+			continue
+		case EvState:
+			// The wrapped event describes a goroutine or P as it was
+			// at the start of the generation.
+			if len(ev.args) < 2 || !isStateEvent(ev.args[1]) {
+				err = fmt.Errorf("bad state event at offset 0x%x", off0)
+				return
+			}
+			ev.typ = byte(ev.args[1])
+			ev.args = append(ev.args[:1], ev.args[2:]...)
+			ev.state = true
 		}
-		events = append(events, ev)
+		gen.events = append(gen.events, ev)
 	}
 	return
 }
 
+// isStateEvent reports whether typ is an event type that EvState can wrap.
+func isStateEvent(typ uint64) bool {
+	switch typ {
+	case EvProcStart, EvGCSweepStart, EvGoCreate, EvGoStart, EvGoStartLabel, EvGoWaiting, EvGoInSyscall:
+		return true
+	}
+	return false
+}
+
 func readStr(r io.Reader, off0 int) (s string, off int, err error) {
 	var sz uint64
 	sz, off, err = readVal(r, off0)
@@ -313,10 +420,15 @@ func parseHeader(buf []byte) (int, error) {
 
 // Parse events transforms raw events into events.
 // It does analyze and verify per-event-type arguments.
-func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (events []*Event, stacks map[uint64][]*Frame, err error) {
-	var ticksPerSec, lastSeq, lastTs int64
+// The timestamps of the events are in cpu ticks, of which there
+// are ticksPerSec per second.
+func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (events []*Event, stacks map[uint64][]*Frame, ticksPerSec int64, err error) {
+	var lastSeq, lastTs int64
 	var lastG uint64
 	var lastP int
+	if ver >= 1017 {
+		rawEvents = sortBatches(rawEvents)
+	}
 	timerGoids := make(map[uint64]bool)
 	lastGs := make(map[int]uint64) // last goroutine running on P
 	stacks = make(map[uint64][]*Frame)
@@ -341,8 +453,10 @@ func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (even
 			if ver < 1007 {
 				lastSeq = int64(raw.args[1])
 				lastTs = int64(raw.args[2])
-			} else {
+			} else if ver < 1017 {
 				lastTs = int64(raw.args[1])
+			} else {
+				lastTs = int64(raw.args[2])
 			}
 		case EvFrequency:
 			ticksPerSec = int64(raw.args[0])
@@ -393,7 +507,7 @@ func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (even
 				stacks[id] = stk
 			}
 		default:
-			e := &Event{Off: raw.off, Type: raw.typ, P: lastP, G: lastG}
+			e := &Event{Off: raw.off, Type: raw.typ, P: lastP, G: lastG, state: raw.state}
 			var argOffset int
 			if ver < 1007 {
 				e.seq = lastSeq + int64(raw.args[0])
@@ -479,12 +593,7 @@ func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (even
 		return
 	}
 
-	// Translate cpu ticks to real time.
-	minTs := events[0].Ts
-	// Use floating point to avoid integer overflows.
-	freq := 1e9 / float64(ticksPerSec)
 	for _, ev := range events {
-		ev.Ts = int64(float64(ev.Ts-minTs) * freq)
 		// Move timers and syscalls to separate fake Ps.
 		if timerGoids[ev.G] && ev.Type == EvGoUnblock {
 			ev.P = TimerP
@@ -497,6 +606,43 @@ func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (even
 	return
 }
 
+// sortBatches puts the batches of events of each P in order.
+// Since Go 1.17, each M writes the events of the P it holds to a buffer
+// of its own, so the batches of a P are spread over the buffers of the
+// Ms that held it. Their sequence numbers give their order.
+func sortBatches(rawEvents []rawEvent) []rawEvent {
+	type batch struct {
+		p      int
+		seq    uint64
+		events []rawEvent
+	}
+	var batches []batch
+	for i := 0; i < len(rawEvents); {
+		b := batch{p: -1}
+		if raw := rawEvents[i]; raw.typ == EvBatch && len(raw.args) == 3 {
+			b.p, b.seq = int(raw.args[0]), raw.args[1]
+		}
+		j := i + 1
+		for j < len(rawEvents) && rawEvents[j].typ != EvBatch {
+			j++
+		}
+		b.events = rawEvents[i:j]
+		batches = append(batches, b)
+		i = j
+	}
+	sort.SliceStable(batches, func(i, j int) bool {
+		if batches[i].p != batches[j].p {
+			return batches[i].p < batches[j].p
+		}
+		return batches[i].seq < batches[j].seq
+	})
+	sorted := make([]rawEvent, 0, len(rawEvents))
+	for _, b := range batches {
+		sorted = append(sorted, b.events...)
+	}
+	return sorted
+}
+
 // removeFutile removes all constituents of futile wakeups (block, unblock, start).
 // For example, a goroutine was unblocked on a mutex, but another goroutine got
 // ahead and acquired the mutex before the first goroutine is scheduled,
@@ -976,10 +1122,13 @@ func argNum(raw rawEvent, ver int) int {
 		narg++
 	}
 	switch raw.typ {
-	case EvBatch, EvFrequency, EvTimerGoroutine:
+	case EvBatch, EvFrequency, EvTimerGoroutine, EvGeneration:
 		if ver < 1007 {
 			narg++ // there was an unused arg before 1.7
 		}
+		if raw.typ == EvBatch && ver >= 1017 {
+			narg++ // 1.17 added the batch sequence number
+		}
 		return narg
 	}
 	narg++ // timestamp
@@ -1058,7 +1207,9 @@ const (
 	EvUserTaskEnd       = 46 // end of task [timestamp, internal task id, stack]
 	EvUserRegion        = 47 // trace.WithRegion [timestamp, internal task id, mode(0:start, 1:end), stack, name string]
 	EvUserLog           = 48 // trace.Log [timestamp, internal id, key string id, stack, value string]
-	EvCount             = 49
+	EvGeneration        = 49 // start of a trace generation [generation number]
+	EvState             = 50 // state at the start of a generation [timestamp, event type, event args]
+	EvCount             = 51
 )
 
 var EventDescriptions = [EvCount]struct {
@@ -1069,7 +1220,7 @@ var EventDescriptions = [EvCount]struct {
 	SArgs      []string // string arguments
 }{
 	EvNone:              {"None", 1005, false, []string{}, nil},
-	EvBatch:             {"Batch", 1005, false, []string{"p", "ticks"}, nil}, // in 1.5 format it was {"p", "seq", "ticks"}
+	EvBatch:             {"Batch", 1005, false, []string{"p", "ticks"}, nil}, // in 1.5 format it was {"p", "seq", "ticks"}, in 1.17 {"p", "batchseq", "ticks"}
 	EvFrequency:         {"Frequency", 1005, false, []string{"freq"}, nil},   // in 1.5 format it was {"freq", "unused"}
 	EvStack:             {"Stack", 1005, false, []string{"id", "siz"}, nil},
 	EvGomaxprocs:        {"Gomaxprocs", 1005, true, []string{"procs"}, nil},
@@ -1117,4 +1268,6 @@ var EventDescriptions = [EvCount]struct {
 	EvUserTaskEnd:       {"UserTaskEnd", 1011, true, []string{"taskid"}, nil},
 	EvUserRegion:        {"UserRegion", 1011, true, []string{"taskid", "mode", "typeid"}, []string{"name"}},
 	EvUserLog:           {"UserLog", 1011, true, []string{"id", "keyid"}, []string{"category", "message"}},
+	EvGeneration:        {"Generation", 1017, false, []string{"gen"}, nil},
+	EvState:             {"State", 1017, false, []string{"type"}, nil},
 }
@@ -0,0 +1,128 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import "path"
+
+// Filter returns a file system containing the files of fsys
+// selected by the include and exclude patterns.
+//
+// The patterns use the syntax of path.Match and are matched against
+// slash-separated names relative to the root of fsys. A pattern that
+// matches a directory also matches everything inside it.
+// A file is present in the result if it matches no exclude pattern and,
+// when include is not empty, at least one include pattern.
+// Directories are not subject to the include patterns:
+// every directory that matches no exclude pattern is present,
+// even if it contains no included files.
+//
+// For example, to serve the Go and text files of a tree,
+// except for those in testdata directories:
+//
+//	fsys, err := fs.Filter(tree, []string{"*.go", "*/*.go", "*.txt"}, []string{"testdata", "*/testdata"})
+//
+// Filter returns an error only if a pattern is malformed.
+// The result implements StatFS and ReadDirFS.
+func Filter(fsys FS, include, exclude []string) (FS, error) {
+	for _, patterns := range [][]string{include, exclude} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &filterFS{fsys, include, exclude}, nil
+}
+
+type filterFS struct {
+	fsys    FS
+	include []string
+	exclude []string
+}
+
+// matchAny reports whether name, or one of its parent directories,
+// matches one of the patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		for n := name; n != "."; n = path.Dir(n) {
+			if ok, _ := path.Match(p, n); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// visible reports whether name is present in the filtered file system.
+func (f *filterFS) visible(name string, isDir bool) bool {
+	if matchAny(f.exclude, name) {
+		return false
+	}
+	return isDir || len(f.include) == 0 || matchAny(f.include, name)
+}
+
+func (f *filterFS) Open(name string) (File, error) {
+	if !ValidPath(name) {
+		return nil, &PathError{Op: "open", Path: name, Err: ErrInvalid}
+	}
+	if matchAny(f.exclude, name) {
+		return nil, &PathError{Op: "open", Path: name, Err: ErrNotExist}
+	}
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if !info.IsDir() {
+		if !f.visible(name, false) {
+			file.Close()
+			return nil, &PathError{Op: "open", Path: name, Err: ErrNotExist}
+		}
+		return file, nil
+	}
+	list, err := readDirFile(file, name)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	entries := list[:0]
+	for _, e := range list {
+		if f.visible(path.Join(name, e.Name()), e.IsDir()) {
+			entries = append(entries, e)
+		}
+	}
+	return &listDir{name: name, info: info, entries: entries}, nil
+}
+
+func (f *filterFS) Stat(name string) (FileInfo, error) {
+	if !ValidPath(name) {
+		return nil, &PathError{Op: "stat", Path: name, Err: ErrInvalid}
+	}
+	info, err := Stat(f.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if !f.visible(name, info.IsDir()) {
+		return nil, &PathError{Op: "stat", Path: name, Err: ErrNotExist}
+	}
+	return info, nil
+}
+
+func (f *filterFS) ReadDir(name string) ([]DirEntry, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	d, ok := file.(*listDir)
+	if !ok {
+		return nil, &PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	return append([]DirEntry(nil), d.entries...), nil
+}
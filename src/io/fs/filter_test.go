@@ -0,0 +1,45 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs_test
+
+import (
+	"errors"
+	. "io/fs"
+	"path"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFilter(t *testing.T) {
+	tree := fstest.MapFS{
+		"main.go":              {Data: []byte("package main")},
+		"README.txt":           {Data: []byte("readme")},
+		"image.png":            {Data: []byte("png")},
+		"pkg/lib.go":           {Data: []byte("package lib")},
+		"pkg/testdata/x.go":    {Data: []byte("excluded")},
+		"pkg/doc/guide.md":     {Data: []byte("included by directory")},
+		"vendor/dep/dep.go":    {Data: []byte("excluded directory")},
+		"pkg/assets/empty.bin": {Data: []byte("not included")},
+	}
+	fsys, err := Filter(tree, []string{"*.go", "*/*.go", "*.txt", "pkg/doc"}, []string{"*/testdata", "vendor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "main.go", "README.txt", "pkg/lib.go", "pkg/doc/guide.md", "pkg/assets"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"image.png", "pkg/testdata", "pkg/testdata/x.go", "vendor", "vendor/dep/dep.go", "pkg/assets/empty.bin"} {
+		if _, err := fsys.Open(name); !errors.Is(err, ErrNotExist) {
+			t.Errorf("Open(%s) = %v, want ErrNotExist", name, err)
+		}
+		if _, err := Stat(fsys, name); !errors.Is(err, ErrNotExist) {
+			t.Errorf("Stat(%s) = %v, want ErrNotExist", name, err)
+		}
+	}
+
+	if _, err := Filter(tree, []string{"["}, nil); err != path.ErrBadPattern {
+		t.Errorf("Filter with bad pattern: %v, want ErrBadPattern", err)
+	}
+}
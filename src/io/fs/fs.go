@@ -8,6 +8,7 @@
 package fs
 
 import (
+	"errors"
 	"internal/oserror"
 	"time"
 	"unicode/utf8"
@@ -138,6 +139,10 @@ var (
 	ErrExist      = errExist()      // "file already exists"
 	ErrNotExist   = errNotExist()   // "file does not exist"
 	ErrClosed     = errClosed()     // "file already closed"
+
+	// ErrUnsupported is returned by the write helpers, such as Mkdir,
+	// when the file system does not implement the operation.
+	ErrUnsupported = errUnsupported() // "operation not supported"
 )
 
 func errInvalid() error    { return oserror.ErrInvalid }
@@ -146,6 +151,8 @@ func errExist() error      { return oserror.ErrExist }
 func errNotExist() error   { return oserror.ErrNotExist }
 func errClosed() error     { return oserror.ErrClosed }
 
+func errUnsupported() error { return errors.New("operation not supported") }
+
 // A FileInfo describes a file and is returned by Stat.
 type FileInfo interface {
 	Name() string       // base name of the file
@@ -0,0 +1,190 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"path"
+	"sort"
+)
+
+// MapNames returns a file system containing the files of fsys
+// under different names.
+//
+// The names are mapped one path element at a time, so the
+// directory structure of fsys is preserved. The function toOuter maps an
+// element of a name in fsys to the corresponding element in the result,
+// and toInner maps it back; each reports false if there is no
+// corresponding element. Files whose names do not map, or do not map
+// back to themselves, are not present in the result.
+//
+// For example, to present a tree whose file names are stored
+// URL-escaped under their unescaped names:
+//
+//	fsys := fs.MapNames(escaped,
+//		func(elem string) (string, bool) {
+//			s, err := url.PathUnescape(elem)
+//			return s, err == nil
+//		},
+//		func(elem string) (string, bool) { return url.PathEscape(elem), true })
+//
+// The result implements StatFS and ReadDirFS.
+func MapNames(fsys FS, toOuter, toInner func(elem string) (string, bool)) FS {
+	return &mapNamesFS{fsys, toOuter, toInner}
+}
+
+type mapNamesFS struct {
+	fsys    FS
+	toOuter func(string) (string, bool)
+	toInner func(string) (string, bool)
+}
+
+// validElem reports whether elem can be an element of a valid path.
+func validElem(elem string) bool {
+	return elem != "." && ValidPath(elem) && path.Base(elem) == elem
+}
+
+// inner returns the name in the underlying file system for name.
+func (m *mapNamesFS) inner(op, name string) (string, error) {
+	if !ValidPath(name) {
+		return "", &PathError{Op: op, Path: name, Err: ErrInvalid}
+	}
+	if name == "." {
+		return name, nil
+	}
+	var inner []byte
+	for i := 0; i < len(name); {
+		j := i
+		for j < len(name) && name[j] != '/' {
+			j++
+		}
+		elem, ok := m.toInner(name[i:j])
+		if ok {
+			// The element must also be listed under this name.
+			out, ok1 := m.outer(elem)
+			ok = ok1 && out == name[i:j]
+		}
+		if !ok {
+			return "", &PathError{Op: op, Path: name, Err: ErrNotExist}
+		}
+		if len(inner) > 0 {
+			inner = append(inner, '/')
+		}
+		inner = append(inner, elem...)
+		i = j + 1
+	}
+	return string(inner), nil
+}
+
+// outer returns the element of the result corresponding
+// to the element elem of a name in the underlying file system.
+func (m *mapNamesFS) outer(elem string) (string, bool) {
+	out, ok := m.toOuter(elem)
+	if !ok || !validElem(out) {
+		return "", false
+	}
+	if back, ok := m.toInner(out); !ok || back != elem {
+		return "", false
+	}
+	return out, true
+}
+
+// fixErr reports err, from an operation on the underlying name,
+// in terms of name.
+func (m *mapNamesFS) fixErr(err error, name string) error {
+	if e, ok := err.(*PathError); ok {
+		return &PathError{Op: e.Op, Path: name, Err: e.Err}
+	}
+	return err
+}
+
+func (m *mapNamesFS) Open(name string) (File, error) {
+	in, err := m.inner("open", name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := m.fsys.Open(in)
+	if err != nil {
+		return nil, m.fixErr(err, name)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, m.fixErr(err, name)
+	}
+	if name != "." {
+		info = renamedInfo{info, path.Base(name)}
+	}
+	if !info.IsDir() {
+		return &renamedFile{file, info}, nil
+	}
+	list, err := readDirFile(file, in)
+	file.Close()
+	if err != nil {
+		return nil, m.fixErr(err, name)
+	}
+	entries := list[:0]
+	for _, e := range list {
+		if out, ok := m.outer(e.Name()); ok {
+			entries = append(entries, renamedEntry{e, out})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return &listDir{name: name, info: info, entries: entries}, nil
+}
+
+func (m *mapNamesFS) Stat(name string) (FileInfo, error) {
+	file, err := m.Open(name)
+	if err != nil {
+		if e, ok := err.(*PathError); ok {
+			e.Op = "stat"
+		}
+		return nil, err
+	}
+	defer file.Close()
+	return file.Stat()
+}
+
+func (m *mapNamesFS) ReadDir(name string) ([]DirEntry, error) {
+	file, err := m.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	d, ok := file.(*listDir)
+	if !ok {
+		return nil, &PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	return append([]DirEntry(nil), d.entries...), nil
+}
+
+// A renamedFile is a File whose Stat reports a different name.
+type renamedFile struct {
+	File
+	info FileInfo
+}
+
+func (f *renamedFile) Stat() (FileInfo, error) { return f.info, nil }
+
+type renamedInfo struct {
+	FileInfo
+	name string
+}
+
+func (i renamedInfo) Name() string { return i.name }
+
+type renamedEntry struct {
+	DirEntry
+	name string
+}
+
+func (e renamedEntry) Name() string { return e.name }
+
+func (e renamedEntry) Info() (FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return renamedInfo{info, e.name}, nil
+}
@@ -0,0 +1,40 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs_test
+
+import (
+	"errors"
+	. "io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMapNames(t *testing.T) {
+	tree := fstest.MapFS{
+		"a-b.txt":     {Data: []byte("a b")},
+		"dir-x/c.txt": {Data: []byte("c")},
+		"d e.txt":     {Data: []byte("hidden: does not map back")},
+	}
+	fsys := MapNames(tree,
+		func(elem string) (string, bool) { return strings.ReplaceAll(elem, "-", " "), true },
+		func(elem string) (string, bool) { return strings.ReplaceAll(elem, " ", "-"), true })
+	if err := fstest.TestFS(fsys, "a b.txt", "dir x/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ReadFile(fsys, "dir x/c.txt"); err != nil || string(data) != "c" {
+		t.Errorf("ReadFile(dir x/c.txt) = %q, %v", data, err)
+	}
+	for _, name := range []string{"a-b.txt", "d e.txt", "dir-x/c.txt"} {
+		_, err := fsys.Open(name)
+		if !errors.Is(err, ErrNotExist) {
+			t.Errorf("Open(%s) = %v, want ErrNotExist", name, err)
+		}
+	}
+	_, err := Stat(fsys, "dir x/missing")
+	if pe, ok := err.(*PathError); !ok || pe.Path != "dir x/missing" || !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat(dir x/missing) = %v, want ErrNotExist for outer name", err)
+	}
+}
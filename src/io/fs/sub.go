@@ -7,6 +7,7 @@ package fs
 import (
 	"errors"
 	"path"
+	"time"
 )
 
 // A SubFS is a file system with a Sub method.
@@ -23,7 +24,8 @@ type SubFS interface {
 // Otherwise, if dir is ".", Sub returns fsys unchanged.
 // Otherwise, Sub returns a new FS implementation sub that,
 // in effect, implements sub.Open(dir) as fsys.Open(path.Join(dir, name)).
-// The implementation also translates calls to ReadDir, ReadFile, and Glob appropriately.
+// The implementation also translates calls to ReadDir, ReadFile, and Glob appropriately,
+// as well as calls to the write helpers, such as OpenFile, Mkdir and Rename.
 //
 // Note that Sub(os.DirFS("/"), "prefix") is equivalent to os.DirFS("/prefix")
 // and that neither of them guarantees to avoid operating system
@@ -125,3 +127,97 @@ func (f *subFS) Glob(pattern string) ([]string, error) {
 	}
 	return list, f.fixErr(err)
 }
+
+func (f *subFS) OpenFile(name string, flag int, perm FileMode) (File, error) {
+	full, err := f.fullName("open", name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := OpenFile(f.fsys, full, flag, perm)
+	return file, f.fixErr(err)
+}
+
+func (f *subFS) Mkdir(name string, perm FileMode) error {
+	full, err := f.fullName("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return f.fixErr(Mkdir(f.fsys, full, perm))
+}
+
+func (f *subFS) MkdirAll(name string, perm FileMode) error {
+	full, err := f.fullName("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return f.fixErr(MkdirAll(f.fsys, full, perm))
+}
+
+func (f *subFS) Remove(name string) error {
+	full, err := f.fullName("remove", name)
+	if err != nil {
+		return err
+	}
+	if full == f.dir {
+		return &PathError{Op: "remove", Path: name, Err: ErrInvalid}
+	}
+	return f.fixErr(Remove(f.fsys, full))
+}
+
+func (f *subFS) RemoveAll(name string) error {
+	full, err := f.fullName("removeall", name)
+	if err != nil {
+		return err
+	}
+	if full == f.dir {
+		return &PathError{Op: "removeall", Path: name, Err: ErrInvalid}
+	}
+	return f.fixErr(RemoveAll(f.fsys, full))
+}
+
+func (f *subFS) Rename(oldname, newname string) error {
+	oldfull, err := f.fullName("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newfull, err := f.fullName("rename", newname)
+	if err != nil {
+		return err
+	}
+	return f.fixErr(Rename(f.fsys, oldfull, newfull))
+}
+
+func (f *subFS) Chmod(name string, mode FileMode) error {
+	full, err := f.fullName("chmod", name)
+	if err != nil {
+		return err
+	}
+	return f.fixErr(Chmod(f.fsys, full, mode))
+}
+
+func (f *subFS) Chtimes(name string, atime, mtime time.Time) error {
+	full, err := f.fullName("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return f.fixErr(Chtimes(f.fsys, full, atime, mtime))
+}
+
+// Symlink creates newname as a symbolic link to oldname.
+// Oldname is the content of the link, so it is not translated.
+func (f *subFS) Symlink(oldname, newname string) error {
+	full, err := f.fullName("symlink", newname)
+	if err != nil {
+		return err
+	}
+	return f.fixErr(Symlink(f.fsys, oldname, full))
+}
+
+func (f *subFS) Readlink(name string) (string, error) {
+	full, err := f.fullName("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	target, err := Readlink(f.fsys, full)
+	return target, f.fixErr(err)
+}
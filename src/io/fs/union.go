@@ -0,0 +1,225 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"errors"
+	"io"
+	"path"
+	"sort"
+)
+
+// Union returns a file system that overlays the given layers,
+// with earlier layers shadowing later ones.
+//
+// A name refers to the file in the first layer that contains it.
+// If that file is not a directory, it hides the files of the same name
+// in all later layers, along with anything inside them.
+// If it is a directory, its entries are merged with those of the
+// directories of the same name in later layers; non-directories of that
+// name in later layers are ignored. An entry in a merged directory is
+// the one from the first layer that has an entry of that name.
+//
+// For example, to serve operator overrides from a directory in
+// preference to defaults embedded in the program:
+//
+//	fsys := fs.Union(os.DirFS("/etc/myserver"), defaults)
+//
+// The result implements StatFS and ReadDirFS.
+func Union(layers ...FS) FS {
+	return unionFS(append([]FS(nil), layers...))
+}
+
+type unionFS []FS
+
+// dirLayers returns the layers that contribute to the directory dir:
+// those in which dir and all its parents are directories, up to
+// the first layer in which one of them is not a directory.
+func (u unionFS) dirLayers(op, dir string) ([]FS, error) {
+	layers := []FS(u)
+	if dir == "." {
+		return layers, nil
+	}
+	for i := 0; i <= len(dir); i++ {
+		if i < len(dir) && dir[i] != '/' {
+			continue
+		}
+		prefix := dir[:i]
+		var next []FS
+		for _, fsys := range layers {
+			info, err := Stat(fsys, prefix)
+			if err != nil {
+				continue
+			}
+			if !info.IsDir() {
+				if len(next) == 0 {
+					// A file shadows everything below it.
+					return nil, &PathError{Op: op, Path: dir, Err: ErrNotExist}
+				}
+				continue
+			}
+			next = append(next, fsys)
+		}
+		if len(next) == 0 {
+			return nil, &PathError{Op: op, Path: dir, Err: ErrNotExist}
+		}
+		layers = next
+	}
+	return layers, nil
+}
+
+// candidates returns the layers that may contain name.
+func (u unionFS) candidates(op, name string) ([]FS, error) {
+	if !ValidPath(name) {
+		return nil, &PathError{Op: op, Path: name, Err: ErrInvalid}
+	}
+	if name == "." {
+		return u, nil
+	}
+	return u.dirLayers(op, path.Dir(name))
+}
+
+func (u unionFS) Open(name string) (File, error) {
+	layers, err := u.candidates("open", name)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		top   File
+		info  FileInfo
+		lists [][]DirEntry
+	)
+	for _, fsys := range layers {
+		f, err := fsys.Open(name)
+		if err != nil {
+			if top == nil && !errors.Is(err, ErrNotExist) {
+				return nil, err
+			}
+			continue
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			if top == nil {
+				return nil, err
+			}
+			continue
+		}
+		if top == nil {
+			if !fi.IsDir() {
+				return f, nil
+			}
+			top, info = f, fi
+		} else if !fi.IsDir() {
+			f.Close()
+			continue
+		}
+		list, err := readDirFile(f, name)
+		if f != top {
+			f.Close()
+		}
+		if err != nil {
+			top.Close()
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	if top == nil {
+		return nil, &PathError{Op: "open", Path: name, Err: ErrNotExist}
+	}
+	top.Close()
+	return &listDir{name: name, info: info, entries: mergeEntries(lists)}, nil
+}
+
+func (u unionFS) Stat(name string) (FileInfo, error) {
+	layers, err := u.candidates("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	for _, fsys := range layers {
+		info, err := Stat(fsys, name)
+		if err == nil {
+			return info, nil
+		}
+		if !errors.Is(err, ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &PathError{Op: "stat", Path: name, Err: ErrNotExist}
+}
+
+func (u unionFS) ReadDir(name string) ([]DirEntry, error) {
+	f, err := u.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, ok := f.(*listDir)
+	if !ok {
+		return nil, &PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	return append([]DirEntry(nil), d.entries...), nil
+}
+
+var errNotDir = errors.New("not a directory")
+
+// mergeEntries merges sorted directory listings,
+// keeping the first entry with each name.
+func mergeEntries(lists [][]DirEntry) []DirEntry {
+	if len(lists) == 1 {
+		return lists[0]
+	}
+	seen := make(map[string]bool)
+	var list []DirEntry
+	for _, l := range lists {
+		for _, e := range l {
+			if !seen[e.Name()] {
+				seen[e.Name()] = true
+				list = append(list, e)
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// readDirFile reads all the entries of the open directory f.
+func readDirFile(f File, name string) ([]DirEntry, error) {
+	d, ok := f.(ReadDirFile)
+	if !ok {
+		return nil, &PathError{Op: "readdir", Path: name, Err: errors.New("not implemented")}
+	}
+	list, err := d.ReadDir(-1)
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, err
+}
+
+// A listDir is a directory File whose entries have been read in advance.
+type listDir struct {
+	name    string
+	info    FileInfo
+	entries []DirEntry
+	offset  int
+}
+
+func (d *listDir) Stat() (FileInfo, error) { return d.info, nil }
+func (d *listDir) Close() error            { return nil }
+func (d *listDir) Read(b []byte) (int, error) {
+	return 0, &PathError{Op: "read", Path: d.name, Err: ErrInvalid}
+}
+
+func (d *listDir) ReadDir(count int) ([]DirEntry, error) {
+	n := len(d.entries) - d.offset
+	if count > 0 && n > count {
+		n = count
+	}
+	if n == 0 && count > 0 {
+		return nil, io.EOF
+	}
+	list := make([]DirEntry, n)
+	copy(list, d.entries[d.offset:])
+	d.offset += n
+	return list, nil
+}
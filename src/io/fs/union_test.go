@@ -0,0 +1,66 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs_test
+
+import (
+	"errors"
+	. "io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestUnion(t *testing.T) {
+	upper := fstest.MapFS{
+		"a.txt":       {Data: []byte("upper a")},
+		"dir/b.txt":   {Data: []byte("upper b")},
+		"file-or-dir": {Data: []byte("upper file")},
+		"empty":       {Mode: ModeDir | 0755},
+	}
+	lower := fstest.MapFS{
+		"a.txt":             {Data: []byte("lower a")},
+		"dir/b.txt":         {Data: []byte("lower b")},
+		"dir/c.txt":         {Data: []byte("lower c")},
+		"dir/sub/d.txt":     {Data: []byte("lower d")},
+		"file-or-dir/e.txt": {Data: []byte("hidden")},
+		"empty":             {Data: []byte("not a dir")},
+		"only-lower":        {Data: []byte("lower only")},
+	}
+	fsys := Union(upper, lower)
+	if err := fstest.TestFS(fsys, "a.txt", "dir/b.txt", "dir/c.txt", "dir/sub/d.txt", "file-or-dir", "empty", "only-lower"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"a.txt":       "upper a",
+		"dir/b.txt":   "upper b",
+		"dir/c.txt":   "lower c",
+		"file-or-dir": "upper file",
+		"only-lower":  "lower only",
+	} {
+		data, err := ReadFile(fsys, name)
+		if err != nil || string(data) != want {
+			t.Errorf("ReadFile(%s) = %q, %v, want %q", name, data, err, want)
+		}
+	}
+	if _, err := Stat(fsys, "file-or-dir/e.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("file shadowing directory: Stat(file-or-dir/e.txt) = %v, want ErrNotExist", err)
+	}
+	if info, err := Stat(fsys, "empty"); err != nil || !info.IsDir() {
+		t.Errorf("Stat(empty) = %v, %v, want directory", info, err)
+	}
+
+	list, err := ReadDir(fsys, "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range list {
+		names = append(names, e.Name())
+	}
+	if want := []string{"b.txt", "c.txt", "sub"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ReadDir(dir) = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"errors"
+	"io"
+	"path"
+	"time"
+)
+
+// Flags to OpenFile. They have the same values on every system,
+// which may differ from those of the corresponding flags in package os;
+// file systems backed by the operating system, such as os.DirFS,
+// convert them as needed.
+const (
+	// Exactly one of O_RDONLY, O_WRONLY, or O_RDWR must be specified.
+	O_RDONLY int = 0x0 // open the file read-only.
+	O_WRONLY int = 0x1 // open the file write-only.
+	O_RDWR   int = 0x2 // open the file read-write.
+	// The remaining values may be or'ed in to control behavior.
+	O_APPEND int = 0x8  // append data to the file when writing.
+	O_CREATE int = 0x10 // create a new file if none exists.
+	O_EXCL   int = 0x20 // used with O_CREATE, file must not exist.
+	O_SYNC   int = 0x40 // open for synchronous I/O.
+	O_TRUNC  int = 0x80 // truncate regular writable file when opened.
+)
+
+// An OpenFileFS is a file system with an OpenFile method.
+type OpenFileFS interface {
+	FS
+
+	// OpenFile opens the named file with the specified flag
+	// (O_RDONLY etc.) and, if the file is created, perm.
+	// A file opened for writing must implement io.Writer.
+	// If there is an error, it should be of type *PathError.
+	OpenFile(name string, flag int, perm FileMode) (File, error)
+}
+
+// OpenFile opens the named file from the file system
+// with the specified flag (O_RDONLY etc.).
+// If the file does not exist and the O_CREATE flag is passed,
+// it is created with mode perm (before umask, if any).
+//
+// If fs implements OpenFileFS, OpenFile calls fs.OpenFile.
+// Otherwise, if flag requests only reading, OpenFile calls fs.Open.
+// Otherwise OpenFile returns an error wrapping ErrUnsupported.
+func OpenFile(fsys FS, name string, flag int, perm FileMode) (File, error) {
+	if fsys, ok := fsys.(OpenFileFS); ok {
+		return fsys.OpenFile(name, flag, perm)
+	}
+	if flag&(O_WRONLY|O_RDWR|O_APPEND|O_CREATE|O_TRUNC) == 0 {
+		return fsys.Open(name)
+	}
+	return nil, &PathError{Op: "open", Path: name, Err: ErrUnsupported}
+}
+
+// Create creates or truncates the named file in the file system.
+// If the file is created, it has mode 0666 (before umask, if any).
+// Create calls OpenFile with flag O_RDWR|O_CREATE|O_TRUNC.
+func Create(fsys FS, name string) (File, error) {
+	return OpenFile(fsys, name, O_RDWR|O_CREATE|O_TRUNC, 0666)
+}
+
+// WriteFile writes data to the named file in the file system,
+// creating it if necessary. If the file does not exist, WriteFile
+// creates it with permissions perm (before umask, if any);
+// otherwise WriteFile truncates it before writing, without changing permissions.
+func WriteFile(fsys FS, name string, data []byte, perm FileMode) error {
+	f, err := OpenFile(fsys, name, O_WRONLY|O_CREATE|O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	w, ok := f.(io.Writer)
+	if !ok {
+		f.Close()
+		return &PathError{Op: "write", Path: name, Err: ErrUnsupported}
+	}
+	_, err = w.Write(data)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+// A MkdirFS is a file system with a Mkdir method.
+type MkdirFS interface {
+	FS
+
+	// Mkdir creates a new directory with the specified name and permission bits.
+	// If there is an error, it should be of type *PathError.
+	Mkdir(name string, perm FileMode) error
+}
+
+// Mkdir creates a new directory in the file system
+// with the specified name and permission bits (before umask, if any).
+//
+// If fs implements MkdirFS, Mkdir calls fs.Mkdir.
+// Otherwise Mkdir returns an error wrapping ErrUnsupported.
+func Mkdir(fsys FS, name string, perm FileMode) error {
+	if fsys, ok := fsys.(MkdirFS); ok {
+		return fsys.Mkdir(name, perm)
+	}
+	return &PathError{Op: "mkdir", Path: name, Err: ErrUnsupported}
+}
+
+// A MkdirAllFS is a file system with a MkdirAll method.
+type MkdirAllFS interface {
+	FS
+
+	// MkdirAll creates a directory named name,
+	// along with any necessary parents.
+	MkdirAll(name string, perm FileMode) error
+}
+
+// MkdirAll creates a directory named name in the file system,
+// along with any necessary parents, and returns nil,
+// or else returns an error.
+// The permission bits perm (before umask, if any) are used for all
+// directories that MkdirAll creates.
+// If name is already a directory, MkdirAll does nothing
+// and returns nil.
+//
+// If fs implements MkdirAllFS, MkdirAll calls fs.MkdirAll.
+// Otherwise MkdirAll calls Mkdir for each missing directory.
+func MkdirAll(fsys FS, name string, perm FileMode) error {
+	if fsys, ok := fsys.(MkdirAllFS); ok {
+		return fsys.MkdirAll(name, perm)
+	}
+	if info, err := Stat(fsys, name); err == nil {
+		if info.IsDir() {
+			return nil
+		}
+		return &PathError{Op: "mkdir", Path: name, Err: ErrExist}
+	}
+	if dir := path.Dir(name); dir != "." {
+		if err := MkdirAll(fsys, dir, perm); err != nil {
+			return err
+		}
+	}
+	err := Mkdir(fsys, name, perm)
+	if err != nil {
+		// The directory may have been created concurrently.
+		if info, err1 := Stat(fsys, name); err1 == nil && info.IsDir() {
+			return nil
+		}
+	}
+	return err
+}
+
+// A RemoveFS is a file system with a Remove method.
+type RemoveFS interface {
+	FS
+
+	// Remove removes the named file or (empty) directory.
+	// If there is an error, it should be of type *PathError.
+	Remove(name string) error
+}
+
+// Remove removes the named file or (empty) directory from the file system.
+//
+// If fs implements RemoveFS, Remove calls fs.Remove.
+// Otherwise Remove returns an error wrapping ErrUnsupported.
+func Remove(fsys FS, name string) error {
+	if fsys, ok := fsys.(RemoveFS); ok {
+		return fsys.Remove(name)
+	}
+	return &PathError{Op: "remove", Path: name, Err: ErrUnsupported}
+}
+
+// A RemoveAllFS is a file system with a RemoveAll method.
+type RemoveAllFS interface {
+	FS
+
+	// RemoveAll removes name and any children it contains.
+	RemoveAll(name string) error
+}
+
+// RemoveAll removes name from the file system, along with any children
+// it contains. It removes everything it can but returns the first error
+// it encounters. If name does not exist, RemoveAll returns nil.
+//
+// If fs implements RemoveAllFS, RemoveAll calls fs.RemoveAll.
+// Otherwise RemoveAll calls ReadDir and Remove. Symbolic links
+// are removed, not followed.
+func RemoveAll(fsys FS, name string) error {
+	if fsys, ok := fsys.(RemoveAllFS); ok {
+		return fsys.RemoveAll(name)
+	}
+	if !ValidPath(name) || name == "." {
+		return &PathError{Op: "removeall", Path: name, Err: ErrInvalid}
+	}
+	err := Remove(fsys, name)
+	if err == nil || errors.Is(err, ErrNotExist) {
+		return nil
+	}
+	list, err1 := ReadDir(fsys, name)
+	if err1 != nil {
+		// Not a directory, or not readable: report the original error.
+		return err
+	}
+	err = nil
+	for _, d := range list {
+		if err1 := RemoveAll(fsys, path.Join(name, d.Name())); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+	if err1 := Remove(fsys, name); err1 != nil && !errors.Is(err1, ErrNotExist) && err == nil {
+		err = err1
+	}
+	return err
+}
+
+// A RenameFS is a file system with a Rename method.
+type RenameFS interface {
+	FS
+
+	// Rename renames (moves) oldname to newname.
+	// If newname already exists and is not a directory, Rename replaces it.
+	// If there is an error, it should be of type *PathError.
+	Rename(oldname, newname string) error
+}
+
+// Rename renames (moves) oldname to newname in the file system.
+//
+// If fs implements RenameFS, Rename calls fs.Rename.
+// Otherwise Rename returns an error wrapping ErrUnsupported.
+func Rename(fsys FS, oldname, newname string) error {
+	if fsys, ok := fsys.(RenameFS); ok {
+		return fsys.Rename(oldname, newname)
+	}
+	return &PathError{Op: "rename", Path: oldname, Err: ErrUnsupported}
+}
+
+// A ChmodFS is a file system with a Chmod method.
+type ChmodFS interface {
+	FS
+
+	// Chmod changes the mode of the named file to mode.
+	// If there is an error, it should be of type *PathError.
+	Chmod(name string, mode FileMode) error
+}
+
+// Chmod changes the mode of the named file in the file system to mode.
+//
+// If fs implements ChmodFS, Chmod calls fs.Chmod.
+// Otherwise Chmod returns an error wrapping ErrUnsupported.
+func Chmod(fsys FS, name string, mode FileMode) error {
+	if fsys, ok := fsys.(ChmodFS); ok {
+		return fsys.Chmod(name, mode)
+	}
+	return &PathError{Op: "chmod", Path: name, Err: ErrUnsupported}
+}
+
+// A ChtimesFS is a file system with a Chtimes method.
+type ChtimesFS interface {
+	FS
+
+	// Chtimes changes the access and modification times of the named file.
+	// If there is an error, it should be of type *PathError.
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// Chtimes changes the access and modification times of the named file
+// in the file system. The underlying file system may truncate or round
+// the values to a less precise time unit.
+//
+// If fs implements ChtimesFS, Chtimes calls fs.Chtimes.
+// Otherwise Chtimes returns an error wrapping ErrUnsupported.
+func Chtimes(fsys FS, name string, atime, mtime time.Time) error {
+	if fsys, ok := fsys.(ChtimesFS); ok {
+		return fsys.Chtimes(name, atime, mtime)
+	}
+	return &PathError{Op: "chtimes", Path: name, Err: ErrUnsupported}
+}
+
+// A SymlinkFS is a file system with a Symlink method.
+type SymlinkFS interface {
+	FS
+
+	// Symlink creates newname as a symbolic link to oldname.
+	// Oldname is stored as is: it is not interpreted as a name in the file system.
+	// If there is an error, it should be of type *PathError.
+	Symlink(oldname, newname string) error
+}
+
+// Symlink creates newname as a symbolic link to oldname in the file system.
+//
+// If fs implements SymlinkFS, Symlink calls fs.Symlink.
+// Otherwise Symlink returns an error wrapping ErrUnsupported.
+func Symlink(fsys FS, oldname, newname string) error {
+	if fsys, ok := fsys.(SymlinkFS); ok {
+		return fsys.Symlink(oldname, newname)
+	}
+	return &PathError{Op: "symlink", Path: newname, Err: ErrUnsupported}
+}
+
+// A ReadlinkFS is a file system with a Readlink method.
+type ReadlinkFS interface {
+	FS
+
+	// Readlink returns the destination of the named symbolic link.
+	// If there is an error, it should be of type *PathError.
+	Readlink(name string) (string, error)
+}
+
+// Readlink returns the destination of the named symbolic link
+// in the file system.
+//
+// If fs implements ReadlinkFS, Readlink calls fs.Readlink.
+// Otherwise Readlink returns an error wrapping ErrUnsupported.
+func Readlink(fsys FS, name string) (string, error) {
+	if fsys, ok := fsys.(ReadlinkFS); ok {
+		return fsys.Readlink(name)
+	}
+	return "", &PathError{Op: "readlink", Path: name, Err: ErrUnsupported}
+}
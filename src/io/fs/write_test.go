@@ -0,0 +1,84 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs_test
+
+import (
+	"errors"
+	. "io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// basicWriteFS exposes only the minimal write methods of a MapFS,
+// so that the helpers must use their fallback implementations.
+type basicWriteFS struct {
+	m fstest.MapFS
+}
+
+func (f basicWriteFS) Open(name string) (File, error) { return f.m.Open(name) }
+func (f basicWriteFS) OpenFile(name string, flag int, perm FileMode) (File, error) {
+	return f.m.OpenFile(name, flag, perm)
+}
+func (f basicWriteFS) Mkdir(name string, perm FileMode) error { return f.m.Mkdir(name, perm) }
+func (f basicWriteFS) Remove(name string) error               { return f.m.Remove(name) }
+
+func TestWriteFallbacks(t *testing.T) {
+	m := fstest.MapFS{}
+	fsys := basicWriteFS{m}
+	if err := fstest.TestWriteFS(fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := MkdirAll(fsys, "a/b/c", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(fsys, "a/b/c/file", []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveAll(fsys, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 0 {
+		t.Errorf("after RemoveAll, map contains %v", m)
+	}
+	if err := RemoveAll(fsys, "."); !errors.Is(err, ErrInvalid) {
+		t.Errorf("RemoveAll(.): %v, want ErrInvalid", err)
+	}
+}
+
+func TestWriteUnsupported(t *testing.T) {
+	fsys := struct{ FS }{testFsys}
+	if err := Mkdir(fsys, "dir", 0755); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Mkdir: %v, want ErrUnsupported", err)
+	}
+	if err := WriteFile(fsys, "hello.txt", nil, 0644); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("WriteFile: %v, want ErrUnsupported", err)
+	}
+	if err := Rename(fsys, "hello.txt", "x"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Rename: %v, want ErrUnsupported", err)
+	}
+	// Opening for reading falls back to Open.
+	f, err := OpenFile(fsys, "hello.txt", O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// Sub forwards writes to the parent file system.
+	m := fstest.MapFS{"sub/old": {Data: []byte("x")}}
+	sub, err := Sub(m, "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Rename(sub, "old", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if m["sub/new"] == nil || m["sub/old"] != nil {
+		t.Errorf("after Rename in Sub, map contains %v", m)
+	}
+	if err := Remove(sub, "missing"); err == nil || err.(*PathError).Path != "missing" {
+		t.Errorf("Remove in Sub: got %v, want error for path missing", err)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package metrics serves via its HTTP server the metrics of the
+// runtime/metrics package in the Prometheus text exposition format.
+//
+// The package is typically only imported for the side effect of
+// registering its HTTP handler at /debug/metrics:
+//	import _ "net/http/metrics"
+//
+// If you are not using DefaultServeMux, you will have to register
+// the handler returned by Handler with the mux you are using.
+//
+// Each runtime metric is served under a name made of the prefix go,
+// the path of its key and its unit, with the characters that are not
+// allowed in Prometheus metric names replaced by underscores. For
+// example, /gc/heap/goal:bytes is served as go_gc_heap_goal_bytes.
+// Cumulative metrics that are single numbers are counters, and have
+// a _total suffix. Other single numbers are gauges.
+//
+// Distributions are histograms. Since the runtime's own histograms
+// have many fine-grained buckets, neighboring buckets are merged to
+// keep at most about 64 of them. The _sum of a histogram is an
+// estimate computed from the midpoints of its buckets.
+//
+// Reading the metrics does not stop the world, so the handler is
+// cheap enough to be scraped often.
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"net/http"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	http.Handle("/debug/metrics", Handler())
+}
+
+// Handler returns the HTTP handler that serves the runtime metrics.
+//
+// This is only needed to install the handler in a non-standard location.
+func Handler() http.Handler {
+	return http.HandlerFunc(serveMetrics)
+}
+
+// maxBuckets is the number of histogram buckets above which
+// neighboring buckets are merged.
+const maxBuckets = 64
+
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	descs := metrics.All()
+	samples := make([]metrics.Sample, len(descs))
+	for i := range samples {
+		samples[i].Name = descs[i].Name
+	}
+	metrics.Read(samples)
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	for i, s := range samples {
+		d := &descs[i]
+		name := metricName(d.Name)
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			writeScalar(bw, name, d, strconv.FormatUint(s.Value.Uint64(), 10))
+		case metrics.KindFloat64:
+			writeScalar(bw, name, d, formatFloat(s.Value.Float64()))
+		case metrics.KindFloat64Histogram:
+			writeHeader(bw, name, d, "histogram")
+			writeHistogram(bw, name, s.Value.Float64Histogram())
+		}
+	}
+	bw.Flush()
+}
+
+// metricName returns the Prometheus name of the runtime metric key.
+func metricName(key string) string {
+	return "go" + strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+func writeHeader(w *bufio.Writer, name string, d *metrics.Description, typ string) {
+	w.WriteString("# HELP " + name + " ")
+	w.WriteString(strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.Description))
+	w.WriteString("\n# TYPE " + name + " " + typ + "\n")
+}
+
+func writeScalar(w *bufio.Writer, name string, d *metrics.Description, value string) {
+	if d.Cumulative {
+		name += "_total"
+		writeHeader(w, name, d, "counter")
+	} else {
+		writeHeader(w, name, d, "gauge")
+	}
+	w.WriteString(name + " " + value + "\n")
+}
+
+// writeHistogram writes the buckets, the sum and the count of h.
+// Prometheus buckets are cumulative and identified by their upper bound,
+// so a bucket is written for every stride-th bucket of h, and for the
+// last one.
+func writeHistogram(w *bufio.Writer, name string, h *metrics.Float64Histogram) {
+	stride := (len(h.Counts) + maxBuckets - 1) / maxBuckets
+	var count uint64
+	var sum float64
+	for i, n := range h.Counts {
+		count += n
+		sum += float64(n) * midpoint(h.Buckets[i], h.Buckets[i+1])
+		if (i+1)%stride == 0 || i == len(h.Counts)-1 {
+			w.WriteString(name + `_bucket{le="` + formatFloat(h.Buckets[i+1]) + `"} `)
+			w.WriteString(strconv.FormatUint(count, 10) + "\n")
+		}
+	}
+	if len(h.Counts) == 0 || !math.IsInf(h.Buckets[len(h.Buckets)-1], 1) {
+		w.WriteString(name + `_bucket{le="+Inf"} ` + strconv.FormatUint(count, 10) + "\n")
+	}
+	w.WriteString(name + "_sum " + formatFloat(sum) + "\n")
+	w.WriteString(name + "_count " + strconv.FormatUint(count, 10) + "\n")
+}
+
+// midpoint returns the value that stands for the samples of the bucket
+// [lo, hi). The open-ended buckets at either end stand for their finite
+// boundary.
+func midpoint(lo, hi float64) float64 {
+	switch {
+	case math.IsInf(lo, -1) && math.IsInf(hi, 1):
+		return 0
+	case math.IsInf(lo, -1):
+		return hi
+	case math.IsInf(hi, 1):
+		return lo
+	}
+	return lo + (hi-lo)/2
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
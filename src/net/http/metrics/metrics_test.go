@@ -0,0 +1,105 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bufio"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	runtime.GC()
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/debug/metrics", nil))
+	if got, want := w.Header().Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8"; got != want {
+		t.Errorf("Content-Type = %q, want %q", got, want)
+	}
+
+	types := make(map[string]string)
+	values := make(map[string]float64)
+	var lastBucket float64
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "# TYPE ") {
+			f := strings.Fields(line)
+			types[f[2]] = f[3]
+			lastBucket = 0
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			t.Fatalf("bad line %q", line)
+		}
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("bad value in line %q: %v", line, err)
+		}
+		key := line[:i]
+		if strings.Contains(key, "_bucket{") {
+			if v < lastBucket {
+				t.Errorf("bucket %q is not cumulative", line)
+			}
+			lastBucket = v
+		}
+		values[key] = v
+	}
+
+	for name, typ := range map[string]string{
+		"go_gc_cycles_total_gc_cycles_total": "counter",
+		"go_gc_heap_goal_bytes":              "gauge",
+		"go_sched_goroutines_goroutines":     "gauge",
+		"go_gc_pauses_seconds":               "histogram",
+	} {
+		if types[name] != typ {
+			t.Errorf("type of %s is %q, want %q", name, types[name], typ)
+		}
+	}
+	if values["go_gc_cycles_total_gc_cycles_total"] < 1 {
+		t.Error("go_gc_cycles_total_gc_cycles_total is 0 after a GC")
+	}
+	if values["go_sched_goroutines_goroutines"] < 1 {
+		t.Error("go_sched_goroutines_goroutines is 0")
+	}
+	count := values["go_gc_pauses_seconds_count"]
+	if count < 1 {
+		t.Error("go_gc_pauses_seconds_count is 0 after a GC")
+	}
+	if inf := values[`go_gc_pauses_seconds_bucket{le="+Inf"}`]; inf != count {
+		t.Errorf("+Inf bucket of go_gc_pauses_seconds is %v, want the count %v", inf, count)
+	}
+	if values["go_gc_pauses_seconds_sum"] <= 0 {
+		t.Error("go_gc_pauses_seconds_sum is not positive")
+	}
+	n := 0
+	for key := range values {
+		if strings.HasPrefix(key, "go_gc_pauses_seconds_bucket{") {
+			n++
+		}
+	}
+	if n > maxBuckets+1 {
+		t.Errorf("go_gc_pauses_seconds has %d buckets, want at most %d", n, maxBuckets+1)
+	}
+}
+
+func TestMetricName(t *testing.T) {
+	for key, want := range map[string]string{
+		"/gc/heap/goal:bytes":            "go_gc_heap_goal_bytes",
+		"/gc/heap/allocs-by-size:bytes":  "go_gc_heap_allocs_by_size_bytes",
+		"/gc/cpu/assist:cpu-seconds":     "go_gc_cpu_assist_cpu_seconds",
+		"/sync/mutex/wait/total:seconds": "go_sync_mutex_wait_total_seconds",
+	} {
+		if got := metricName(key); got != want {
+			t.Errorf("metricName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -12,6 +12,7 @@ package http
 import (
 	"bufio"
 	"compress/gzip"
+	"compress/zstd"
 	"container/list"
 	"context"
 	"crypto/tls"
@@ -188,6 +189,14 @@ type Transport struct {
 	// uncompressed.
 	DisableCompression bool
 
+	// RequestZstd, if true, makes the Transport request zstd as
+	// well as gzip, with an "Accept-Encoding: gzip, zstd" header,
+	// when it requests compression on its own for an HTTP/1
+	// request, and transparently decode zstd encoded responses.
+	// HTTP/2 requests still ask for gzip only.
+	// RequestZstd has no effect if DisableCompression is true.
+	RequestZstd bool
+
 	// MaxIdleConns controls the maximum number of idle (keep-alive)
 	// connections across all hosts. Zero means no limit.
 	MaxIdleConns int
@@ -315,6 +324,7 @@ func (t *Transport) Clone() *Transport {
 		TLSHandshakeTimeout:    t.TLSHandshakeTimeout,
 		DisableKeepAlives:      t.DisableKeepAlives,
 		DisableCompression:     t.DisableCompression,
+		RequestZstd:            t.RequestZstd,
 		MaxIdleConns:           t.MaxIdleConns,
 		MaxIdleConnsPerHost:    t.MaxIdleConnsPerHost,
 		MaxConnsPerHost:        t.MaxConnsPerHost,
@@ -2183,8 +2193,15 @@ func (pc *persistConn) readLoop() {
 		}
 
 		resp.Body = body
-		if rc.addedGzip && strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
-			resp.Body = &gzipReader{body: body}
+		if rc.addedCompression {
+			switch ce := resp.Header.Get("Content-Encoding"); {
+			case strings.EqualFold(ce, "gzip"):
+				resp.Body = &gzipReader{body: body}
+			case rc.addedZstd && strings.EqualFold(ce, "zstd"):
+				resp.Body = &zstdReader{body: body}
+			}
+		}
+		if resp.Body != body {
 			resp.Header.Del("Content-Encoding")
 			resp.Header.Del("Content-Length")
 			resp.ContentLength = -1
@@ -2464,9 +2481,10 @@ type requestAndChan struct {
 	ch        chan responseAndError // unbuffered; always send in select on callerGone
 
 	// whether the Transport (as opposed to the user client code)
-	// added the Accept-Encoding gzip header. If the Transport
-	// set it, only then do we transparently decode the gzip.
-	addedGzip bool
+	// added the Accept-Encoding header. If the Transport set it,
+	// only then do we transparently decode the response body.
+	addedCompression bool
+	addedZstd        bool // zstd was requested as well as gzip
 
 	// Optional blocking chan for Expect: 100-continue (for send).
 	// If the request has an "Expect: 100-continue" header and
@@ -2538,15 +2556,16 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 
 	// Ask for a compressed version if the caller didn't set their
 	// own value for Accept-Encoding. We only attempt to
-	// uncompress the gzip stream if we were the layer that
-	// requested it.
-	requestedGzip := false
+	// uncompress the gzip or zstd stream if we were the layer
+	// that requested it. zstd is only requested if RequestZstd
+	// is set, as the HTTP/2 transport asks for gzip only.
+	requestedCompression, requestedZstd := false, false
 	if !pc.t.DisableCompression &&
 		req.Header.Get("Accept-Encoding") == "" &&
 		req.Header.Get("Range") == "" &&
 		req.Method != "HEAD" {
-		// Request gzip only, not deflate. Deflate is ambiguous and
-		// not as universally supported anyway.
+		// Request gzip (and zstd) only, not deflate. Deflate is ambiguous
+		// and not as universally supported anyway.
 		// See: https://zlib.net/zlib_faq.html#faq39
 		//
 		// Note that we don't request this for HEAD requests,
@@ -2554,11 +2573,16 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 		//   https://trac.nginx.org/nginx/ticket/358
 		//   https://golang.org/issue/5522
 		//
-		// We don't request gzip if the request is for a range, since
-		// auto-decoding a portion of a gzipped document will just fail
-		// anyway. See https://golang.org/issue/8923
-		requestedGzip = true
-		req.extraHeaders().Set("Accept-Encoding", "gzip")
+		// We don't request compression if the request is for a range,
+		// since auto-decoding a portion of a compressed document will
+		// just fail anyway. See https://golang.org/issue/8923
+		requestedCompression = true
+		if pc.t.RequestZstd {
+			requestedZstd = true
+			req.extraHeaders().Set("Accept-Encoding", "gzip, zstd")
+		} else {
+			req.extraHeaders().Set("Accept-Encoding", "gzip")
+		}
 	}
 
 	var continueCh chan struct{}
@@ -2592,12 +2616,13 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 
 	resc := make(chan responseAndError)
 	pc.reqch <- requestAndChan{
-		req:        req.Request,
-		cancelKey:  req.cancelKey,
-		ch:         resc,
-		addedGzip:  requestedGzip,
-		continueCh: continueCh,
-		callerGone: gone,
+		req:              req.Request,
+		cancelKey:        req.cancelKey,
+		ch:               resc,
+		addedCompression: requestedCompression,
+		addedZstd:        requestedZstd,
+		continueCh:       continueCh,
+		callerGone:       gone,
 	}
 
 	var respHeaderTimer <-chan time.Time
@@ -2832,6 +2857,39 @@ func (gz *gzipReader) Close() error {
 	return gz.body.Close()
 }
 
+// zstdMaxWindow is the largest window size accepted in response
+// bodies with the zstd content coding, as required by RFC 9659.
+const zstdMaxWindow = 8 << 20
+
+// zstdReader wraps a response body so it can lazily
+// call zstd.NewReader on the first call to Read
+type zstdReader struct {
+	_    incomparable
+	body *bodyEOFSignal // underlying HTTP/1 response body framing
+	zr   *zstd.Reader   // lazily-initialized zstd reader
+}
+
+func (zs *zstdReader) Read(p []byte) (n int, err error) {
+	zs.body.mu.Lock()
+	if zs.body.closed {
+		err = errReadOnClosedResBody
+	}
+	zs.body.mu.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+	if zs.zr == nil {
+		zs.zr = zstd.NewReader(zs.body)
+		zs.zr.SetMaxWindow(zstdMaxWindow)
+	}
+	return zs.zr.Read(p)
+}
+
+func (zs *zstdReader) Close() error {
+	return zs.body.Close()
+}
+
 type tlsHandshakeTimeoutError struct{}
 
 func (tlsHandshakeTimeoutError) Timeout() bool   { return true }
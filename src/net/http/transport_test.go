@@ -13,6 +13,7 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"compress/zstd"
 	"context"
 	"crypto/rand"
 	"crypto/tls"
@@ -1122,6 +1123,53 @@ func TestTransportGzip(t *testing.T) {
 	}
 }
 
+func TestTransportZstd(t *testing.T) {
+	setParallel(t)
+	defer afterTest(t)
+	const content = "I am some zstd compressed content. Go go go go go go go go go go go go should compress well."
+	// A frame with a 16 MiB window, more than RFC 9659 allows.
+	largeWindow := []byte{0x28, 0xB5, 0x2F, 0xFD, 0x00, 14 << 3, 5<<3 | 1, 0, 0, 'h', 'e', 'l', 'l', 'o'}
+	ts := httptest.NewServer(HandlerFunc(func(rw ResponseWriter, req *Request) {
+		if g, e := req.Header.Get("Accept-Encoding"), "gzip, zstd"; g != e {
+			t.Errorf("Accept-Encoding = %q, want %q", g, e)
+		}
+		rw.Header().Set("Content-Encoding", "zstd")
+		if req.FormValue("window") == "large" {
+			rw.Write(largeWindow)
+			return
+		}
+		zw := zstd.NewWriter(rw)
+		io.WriteString(zw, content)
+		zw.Close()
+	}))
+	defer ts.Close()
+	c := ts.Client()
+	c.Transport.(*Transport).RequestZstd = true
+
+	res, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil || string(body) != content {
+		t.Errorf("body = %q, %v; want %q", body, err, content)
+	}
+	if !res.Uncompressed || res.Header.Get("Content-Encoding") != "" {
+		t.Errorf("Uncompressed = %v, Content-Encoding = %q; want true and none", res.Uncompressed, res.Header.Get("Content-Encoding"))
+	}
+
+	res, err = c.Get(ts.URL + "/?window=large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != zstd.ErrWindowSize {
+		t.Errorf("reading body with a 16 MiB window: %v, want zstd.ErrWindowSize", err)
+	}
+}
+
 // If a request has Expect:100-continue header, the request blocks sending body until the first response.
 // Premature consumption of the request body should not be occurred.
 func TestTransportExpect100Continue(t *testing.T) {
@@ -616,6 +616,10 @@ func isWindowsNulName(name string) bool {
 // the /prefix tree, then using DirFS does not stop the access any more than using
 // os.Open does. DirFS is therefore not a general substitute for a chroot-style security
 // mechanism when the directory tree contains arbitrary content.
+//
+// The file system also implements the optional write interfaces of package fs,
+// such as fs.OpenFileFS, fs.MkdirFS, fs.RemoveFS and fs.RenameFS, in terms of
+// the corresponding functions in this package.
 func DirFS(dir string) fs.FS {
 	return dirFS(dir)
 }
@@ -633,17 +637,151 @@ func containsAny(s, chars string) bool {
 
 type dirFS string
 
-func (dir dirFS) Open(name string) (fs.File, error) {
+// join returns the operating system name for name,
+// which must be a valid path for the file system.
+func (dir dirFS) join(op, name string) (string, error) {
 	if !fs.ValidPath(name) || runtime.GOOS == "windows" && containsAny(name, `\:`) {
-		return nil, &PathError{Op: "open", Path: name, Err: ErrInvalid}
+		return "", &PathError{Op: op, Path: name, Err: ErrInvalid}
+	}
+	return string(dir) + "/" + name, nil
+}
+
+func (dir dirFS) Open(name string) (fs.File, error) {
+	full, err := dir.join("open", name)
+	if err != nil {
+		return nil, err
 	}
-	f, err := Open(string(dir) + "/" + name)
+	f, err := Open(full)
 	if err != nil {
 		return nil, err // nil fs.File
 	}
 	return f, nil
 }
 
+func (dir dirFS) OpenFile(name string, flag int, perm FileMode) (fs.File, error) {
+	full, err := dir.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := OpenFile(full, fsOpenFlag(flag), perm)
+	if err != nil {
+		return nil, err // nil fs.File
+	}
+	return f, nil
+}
+
+// fsOpenFlags maps the flags of io/fs to those of this package.
+var fsOpenFlags = [...]struct{ fs, os int }{
+	{fs.O_WRONLY, O_WRONLY},
+	{fs.O_RDWR, O_RDWR},
+	{fs.O_APPEND, O_APPEND},
+	{fs.O_CREATE, O_CREATE},
+	{fs.O_EXCL, O_EXCL},
+	{fs.O_SYNC, O_SYNC},
+	{fs.O_TRUNC, O_TRUNC},
+}
+
+// fsOpenFlag converts flag, a combination of the io/fs O_* flags,
+// to the equivalent flags of this package. O_RDONLY is 0 in both.
+func fsOpenFlag(flag int) int {
+	f := 0
+	for _, m := range fsOpenFlags {
+		if flag&m.fs != 0 {
+			f |= m.os
+		}
+	}
+	return f
+}
+
+func (dir dirFS) Mkdir(name string, perm FileMode) error {
+	full, err := dir.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return Mkdir(full, perm)
+}
+
+func (dir dirFS) MkdirAll(name string, perm FileMode) error {
+	full, err := dir.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return MkdirAll(full, perm)
+}
+
+func (dir dirFS) Remove(name string) error {
+	full, err := dir.join("remove", name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return &PathError{Op: "remove", Path: name, Err: ErrInvalid}
+	}
+	return Remove(full)
+}
+
+func (dir dirFS) RemoveAll(name string) error {
+	full, err := dir.join("removeall", name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return &PathError{Op: "removeall", Path: name, Err: ErrInvalid}
+	}
+	return RemoveAll(full)
+}
+
+func (dir dirFS) Rename(oldname, newname string) error {
+	oldfull, err := dir.join("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newfull, err := dir.join("rename", newname)
+	if err != nil {
+		return err
+	}
+	// Rename returns a *LinkError, but fs.RenameFS promises a *PathError.
+	if err := Rename(oldfull, newfull); err != nil {
+		if le, ok := err.(*LinkError); ok {
+			return &PathError{Op: "rename", Path: oldname, Err: le.Err}
+		}
+		return err
+	}
+	return nil
+}
+
+func (dir dirFS) Chmod(name string, mode FileMode) error {
+	full, err := dir.join("chmod", name)
+	if err != nil {
+		return err
+	}
+	return Chmod(full, mode)
+}
+
+func (dir dirFS) Chtimes(name string, atime, mtime time.Time) error {
+	full, err := dir.join("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return Chtimes(full, atime, mtime)
+}
+
+func (dir dirFS) Symlink(oldname, newname string) error {
+	full, err := dir.join("symlink", newname)
+	if err != nil {
+		return err
+	}
+	return Symlink(oldname, full)
+}
+
+func (dir dirFS) Readlink(name string) (string, error) {
+	full, err := dir.join("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return Readlink(full)
+}
+
 // ReadFile reads the named file and returns the contents.
 // A successful call returns err == nil, not err == EOF.
 // Because ReadFile reads the whole file, it does not treat an EOF from Read
@@ -2726,6 +2726,21 @@ func TestDirFS(t *testing.T) {
 	if err == nil {
 		t.Fatalf(`Open testdata\dirfs succeeded`)
 	}
+
+	// The io/fs flags differ from those of package os
+	// and must be converted by OpenFile.
+	if err := fstest.TestWriteFS(DirFS(t.TempDir())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirFSRenameError(t *testing.T) {
+	fsys := DirFS(t.TempDir()).(fs.RenameFS)
+	err := fsys.Rename("missing", "new")
+	var pe *fs.PathError
+	if !errors.As(err, &pe) || pe.Op != "rename" || pe.Path != "missing" || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Rename of missing file: got %#v, want *PathError{Op: \"rename\", Path: \"missing\"} wrapping ErrNotExist", err)
+	}
 }
 
 func TestDirFSPathsValid(t *testing.T) {
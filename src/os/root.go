@@ -0,0 +1,234 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package os
+
+import (
+	"errors"
+	"io/fs"
+	"runtime"
+	"sort"
+	"syscall"
+)
+
+// Root may be used to only access files within a single directory tree.
+//
+// Methods on Root can only access files and directories beneath a root directory.
+// If any component of a file name passed to a method of Root references a location
+// outside the root, the method returns an error.
+// File names may reference the directory itself (.).
+//
+// Methods on Root will follow symbolic links, but symbolic links may not
+// reference a location outside the root.
+// Symbolic links must not be absolute.
+//
+// Methods on Root do not prohibit traversal of filesystem boundaries,
+// Linux bind mounts, /proc special files, or access to Unix device files.
+//
+// On Linux, Root uses the openat family of system calls, resolving each
+// path component relative to an open directory, so that its guarantees
+// hold even if the tree is modified concurrently. On other systems,
+// Root resolves names by examining each path component in turn;
+// it is not safe against concurrent renames of directories within the root.
+//
+// Methods on Root are safe to be used from multiple goroutines simultaneously.
+type Root struct {
+	root *root
+}
+
+// errPathEscapes is returned when a name refers to a location outside the root.
+var errPathEscapes = errors.New("path escapes from parent")
+
+// maxSymlinks is the number of symbolic links Root follows
+// while resolving a name before reporting ELOOP.
+const maxSymlinks = 40
+
+// OpenRoot opens the named directory for use as a Root.
+// If there is an error, it will be of type *PathError.
+func OpenRoot(name string) (*Root, error) {
+	return openRootNolog(name)
+}
+
+// Name returns the name of the directory presented to OpenRoot.
+//
+// It is safe to call Name after Close.
+func (r *Root) Name() string {
+	return r.root.name
+}
+
+// Close closes the Root.
+// After Close is called, methods on Root return errors.
+func (r *Root) Close() error {
+	return r.root.Close()
+}
+
+// Open opens the named file in the root for reading.
+// See Open for more details.
+func (r *Root) Open(name string) (*File, error) {
+	return r.OpenFile(name, O_RDONLY, 0)
+}
+
+// Create creates or truncates the named file in the root.
+// See Create for more details.
+func (r *Root) Create(name string) (*File, error) {
+	return r.OpenFile(name, O_RDWR|O_CREATE|O_TRUNC, 0666)
+}
+
+// OpenFile opens the named file in the root.
+// See OpenFile for more details.
+//
+// If perm contains bits other than the nine least-significant bits (0o777),
+// OpenFile returns an error.
+func (r *Root) OpenFile(name string, flag int, perm FileMode) (*File, error) {
+	if perm&0777 != perm {
+		return nil, &PathError{Op: "openat", Path: name, Err: errors.New("unsupported file mode")}
+	}
+	return rootOpenFile(r, name, flag, perm)
+}
+
+// Mkdir creates a new directory in the root
+// with the specified name and permission bits (before umask).
+// See Mkdir for more details.
+//
+// If perm contains bits other than the nine least-significant bits (0o777),
+// Mkdir returns an error.
+func (r *Root) Mkdir(name string, perm FileMode) error {
+	if perm&0777 != perm {
+		return &PathError{Op: "mkdirat", Path: name, Err: errors.New("unsupported file mode")}
+	}
+	return rootMkdir(r, name, perm)
+}
+
+// Remove removes the named file or (empty) directory in the root.
+// See Remove for more details.
+func (r *Root) Remove(name string) error {
+	return rootRemove(r, name)
+}
+
+// Stat returns a FileInfo describing the named file in the root.
+// See Stat for more details.
+func (r *Root) Stat(name string) (FileInfo, error) {
+	return rootStat(r, name, false)
+}
+
+// Lstat returns a FileInfo describing the named file in the root.
+// If the file is a symbolic link, the returned FileInfo
+// describes the symbolic link.
+// See Lstat for more details.
+func (r *Root) Lstat(name string) (FileInfo, error) {
+	return rootStat(r, name, true)
+}
+
+// FS returns a file system (an fs.FS) for the tree of files in the root.
+//
+// The result implements fs.StatFS and fs.ReadDirFS, as well as the
+// write interfaces fs.OpenFileFS, fs.MkdirFS and fs.RemoveFS.
+func (r *Root) FS() fs.FS {
+	return (*rootFS)(r)
+}
+
+// splitPathInRoot splits name into its components,
+// dropping empty and "." components.
+// It reports an error if name is empty or absolute.
+func splitPathInRoot(name string) ([]string, error) {
+	if name == "" {
+		return nil, syscall.ENOENT
+	}
+	if IsPathSeparator(name[0]) || runtime.GOOS == "windows" && len(name) >= 2 && name[1] == ':' {
+		return nil, errPathEscapes
+	}
+	var parts []string
+	for i := 0; i < len(name); {
+		j := i
+		for j < len(name) && !IsPathSeparator(name[j]) {
+			j++
+		}
+		if part := name[i:j]; part != "" && part != "." {
+			parts = append(parts, part)
+		}
+		i = j + 1
+	}
+	return parts, nil
+}
+
+// spliceSymlink replaces the first n components of parts
+// with the components of the symbolic link target.
+func spliceSymlink(parts []string, n int, target string) ([]string, error) {
+	link, err := splitPathInRoot(target)
+	if err != nil {
+		return nil, err
+	}
+	return append(link, parts[n:]...), nil
+}
+
+// rootErr returns err, reported by an operation on a name
+// within the root, as a *PathError for op and name.
+func rootErr(op, name string, err error) error {
+	if pe, ok := err.(*PathError); ok {
+		err = pe.Err
+	}
+	return &PathError{Op: op, Path: name, Err: err}
+}
+
+type rootFS Root
+
+func (rfs *rootFS) validate(op, name string) error {
+	if !fs.ValidPath(name) || runtime.GOOS == "windows" && containsAny(name, `\:`) {
+		return &PathError{Op: op, Path: name, Err: ErrInvalid}
+	}
+	return nil
+}
+
+func (rfs *rootFS) Open(name string) (fs.File, error) {
+	return rfs.OpenFile(name, fs.O_RDONLY, 0)
+}
+
+func (rfs *rootFS) OpenFile(name string, flag int, perm FileMode) (fs.File, error) {
+	if err := rfs.validate("open", name); err != nil {
+		return nil, err
+	}
+	f, err := (*Root)(rfs).OpenFile(name, fsOpenFlag(flag), perm&0777)
+	if err != nil {
+		return nil, err // nil fs.File
+	}
+	return f, nil
+}
+
+func (rfs *rootFS) Stat(name string) (FileInfo, error) {
+	if err := rfs.validate("stat", name); err != nil {
+		return nil, err
+	}
+	return (*Root)(rfs).Stat(name)
+}
+
+func (rfs *rootFS) ReadDir(name string) ([]DirEntry, error) {
+	if err := rfs.validate("readdir", name); err != nil {
+		return nil, err
+	}
+	f, err := (*Root)(rfs).Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dirs, err := f.ReadDir(-1)
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].Name() < dirs[j].Name() })
+	return dirs, err
+}
+
+func (rfs *rootFS) Mkdir(name string, perm FileMode) error {
+	if err := rfs.validate("mkdir", name); err != nil {
+		return err
+	}
+	return (*Root)(rfs).Mkdir(name, perm&0777)
+}
+
+func (rfs *rootFS) Remove(name string) error {
+	if err := rfs.validate("remove", name); err != nil {
+		return err
+	}
+	if name == "." {
+		return &PathError{Op: "remove", Path: name, Err: ErrInvalid}
+	}
+	return (*Root)(rfs).Remove(name)
+}
@@ -0,0 +1,169 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package os
+
+import (
+	"errors"
+	"sync"
+)
+
+// root implementation for platforms without openat.
+// Names are resolved by examining each path component in turn.
+type root struct {
+	name string
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func (r *root) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return &PathError{Op: "close", Path: r.name, Err: ErrClosed}
+	}
+	r.closed = true
+	return nil
+}
+
+func openRootNolog(name string) (*Root, error) {
+	fi, err := Stat(name)
+	if err != nil {
+		return nil, rootErr("open", name, err)
+	}
+	if !fi.IsDir() {
+		return nil, &PathError{Op: "open", Path: name, Err: errNotDir}
+	}
+	return &Root{&root{name: name}}, nil
+}
+
+var errNotDir = errors.New("not a directory")
+
+// resolve returns the operating system name of the file
+// that name refers to within the root.
+// Symbolic links in the final component of the name
+// are followed only if follow is set.
+func (r *root) resolve(name string, follow bool) (string, error) {
+	if r.closed {
+		return "", ErrClosed
+	}
+	parts, err := splitPathInRoot(name)
+	if err != nil {
+		return "", err
+	}
+	var dirs []string // resolved components below the root
+	links := 0
+	for i := 0; i < len(parts); {
+		part := parts[i]
+		if part == ".." {
+			if len(dirs) == 0 {
+				return "", errPathEscapes
+			}
+			dirs = dirs[:len(dirs)-1]
+			i++
+			continue
+		}
+		last := i == len(parts)-1
+		p := r.join(append(dirs, part))
+		fi, err := Lstat(p)
+		if err != nil {
+			if last && IsNotExist(err) {
+				// The file may be about to be created.
+				dirs = append(dirs, part)
+				break
+			}
+			return "", err
+		}
+		if fi.Mode()&ModeSymlink != 0 && (!last || follow) {
+			target, err := Readlink(p)
+			if err != nil {
+				return "", err
+			}
+			links++
+			if links > maxSymlinks {
+				return "", errTooManyLinks
+			}
+			parts, err = spliceSymlink(parts, i+1, target)
+			if err != nil {
+				return "", err
+			}
+			i = 0
+			continue
+		}
+		dirs = append(dirs, part)
+		i++
+	}
+	return r.join(dirs), nil
+}
+
+var errTooManyLinks = errors.New("too many levels of symbolic links")
+
+// join returns the operating system name for the components
+// of a name within the root.
+func (r *root) join(parts []string) string {
+	name := r.name
+	for _, part := range parts {
+		name = joinPath(name, part)
+	}
+	return name
+}
+
+func (r *Root) resolve(name string, follow bool) (string, error) {
+	r.root.mu.RLock()
+	defer r.root.mu.RUnlock()
+	return r.root.resolve(name, follow)
+}
+
+func rootOpenFile(r *Root, name string, flag int, perm FileMode) (*File, error) {
+	p, err := r.resolve(name, true)
+	if err != nil {
+		return nil, rootErr("openat", name, err)
+	}
+	f, err := OpenFile(p, flag, perm)
+	if err != nil {
+		return nil, rootErr("openat", name, err)
+	}
+	return f, nil
+}
+
+func rootMkdir(r *Root, name string, perm FileMode) error {
+	p, err := r.resolve(name, false)
+	if err == nil {
+		err = Mkdir(p, perm)
+	}
+	if err != nil {
+		return rootErr("mkdirat", name, err)
+	}
+	return nil
+}
+
+func rootRemove(r *Root, name string) error {
+	p, err := r.resolve(name, false)
+	if err == nil {
+		err = Remove(p)
+	}
+	if err != nil {
+		return rootErr("removeat", name, err)
+	}
+	return nil
+}
+
+func rootStat(r *Root, name string, lstat bool) (FileInfo, error) {
+	op := "statat"
+	if lstat {
+		op = "lstatat"
+	}
+	p, err := r.resolve(name, !lstat)
+	if err != nil {
+		return nil, rootErr(op, name, err)
+	}
+	fi, err := Lstat(p)
+	if err != nil {
+		return nil, rootErr(op, name, err)
+	}
+	return fi, nil
+}
@@ -0,0 +1,254 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package os
+
+import (
+	"internal/syscall/unix"
+	"runtime"
+	"sync"
+	"syscall"
+)
+
+// root implementation for platforms with a function to open a file
+// relative to a directory.
+type root struct {
+	name string
+
+	// mu prevents the descriptor from being closed
+	// while it is in use by an operation.
+	mu     sync.RWMutex
+	fd     int
+	closed bool
+}
+
+func (r *root) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return &PathError{Op: "close", Path: r.name, Err: ErrClosed}
+	}
+	r.closed = true
+	runtime.SetFinalizer(r, nil)
+	if err := syscall.Close(r.fd); err != nil {
+		return &PathError{Op: "close", Path: r.name, Err: err}
+	}
+	return nil
+}
+
+func openRootNolog(name string) (*Root, error) {
+	var fd int
+	err := ignoringEINTR(func() error {
+		var err error
+		fd, err = syscall.Open(name, O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+		return err
+	})
+	if err != nil {
+		return nil, &PathError{Op: "open", Path: name, Err: err}
+	}
+	r := &root{name: name, fd: fd}
+	runtime.SetFinalizer(r, (*root).Close)
+	return &Root{r}, nil
+}
+
+// doInRoot resolves name relative to the root and calls f with
+// the descriptor of the directory containing the final component
+// and the name of that component.
+//
+// Each directory on the way is opened with O_NOFOLLOW, relative to the
+// previous one, so symbolic links are seen and resolved by doInRoot itself;
+// ".." components return to the previously opened directory, and
+// may not go above the root.
+// If f returns ELOOP and the final component is a symbolic link,
+// doInRoot follows the link and continues.
+func doInRoot(r *Root, name string, f func(parent int, name string) error) error {
+	r.root.mu.RLock()
+	defer r.root.mu.RUnlock()
+	if r.root.closed {
+		return ErrClosed
+	}
+	parts, err := splitPathInRoot(name)
+	if err != nil {
+		return err
+	}
+	parts = finalPart(parts)
+
+	var dirs []int // descriptors of the directories below the root
+	defer func() {
+		for _, fd := range dirs {
+			syscall.Close(fd)
+		}
+	}()
+	dirfd := r.root.fd
+	links := 0
+	for i := 0; i < len(parts); {
+		part := parts[i]
+		if part == ".." {
+			if len(dirs) == 0 {
+				return errPathEscapes
+			}
+			syscall.Close(dirs[len(dirs)-1])
+			dirs = dirs[:len(dirs)-1]
+			dirfd = r.root.fd
+			if len(dirs) > 0 {
+				dirfd = dirs[len(dirs)-1]
+			}
+			i++
+			continue
+		}
+
+		var err error
+		if i == len(parts)-1 {
+			err = f(dirfd, part)
+			if err != syscall.ELOOP {
+				return err
+			}
+		} else {
+			var fd int
+			fd, err = openDirAt(dirfd, part)
+			if err == nil {
+				dirs = append(dirs, fd)
+				dirfd = fd
+				i++
+				continue
+			}
+			if err != syscall.ELOOP && err != syscall.ENOTDIR {
+				return err
+			}
+		}
+
+		// The component may be a symbolic link.
+		target, err1 := readlinkat(dirfd, part)
+		if err1 != nil {
+			return err
+		}
+		links++
+		if links > maxSymlinks {
+			return syscall.ELOOP
+		}
+		parts, err = spliceSymlink(parts, i+1, target)
+		if err != nil {
+			return err
+		}
+		parts = finalPart(parts)
+		i = 0
+	}
+	return nil
+}
+
+// finalPart makes sure that parts ends with a name to pass to the
+// function called by doInRoot, adding "." to refer to a directory.
+func finalPart(parts []string) []string {
+	if len(parts) == 0 || parts[len(parts)-1] == ".." {
+		parts = append(parts, ".")
+	}
+	return parts
+}
+
+// openDirAt opens the directory name relative to dirfd
+// without following symbolic links.
+func openDirAt(dirfd int, name string) (int, error) {
+	var fd int
+	err := ignoringEINTR(func() error {
+		var err error
+		fd, err = unix.Openat(dirfd, name, O_RDONLY|syscall.O_DIRECTORY|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+		return err
+	})
+	return fd, err
+}
+
+func readlinkat(dirfd int, name string) (string, error) {
+	for n := 128; ; n *= 2 {
+		b := make([]byte, n)
+		var nn int
+		err := ignoringEINTR(func() error {
+			var err error
+			nn, err = unix.Readlinkat(dirfd, name, b)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+		if nn < n {
+			return string(b[:nn]), nil
+		}
+	}
+}
+
+func rootOpenFile(r *Root, name string, flag int, perm FileMode) (*File, error) {
+	var fd int
+	err := doInRoot(r, name, func(parent int, name string) error {
+		return ignoringEINTR(func() error {
+			var err error
+			fd, err = unix.Openat(parent, name, flag|syscall.O_LARGEFILE|syscall.O_CLOEXEC|syscall.O_NOFOLLOW, syscallMode(perm))
+			return err
+		})
+	})
+	if err != nil {
+		return nil, &PathError{Op: "openat", Path: name, Err: err}
+	}
+	return newFile(uintptr(fd), joinPath(r.Name(), name), kindOpenFile), nil
+}
+
+func rootMkdir(r *Root, name string, perm FileMode) error {
+	err := doInRoot(r, name, func(parent int, name string) error {
+		return ignoringEINTR(func() error {
+			return unix.Mkdirat(parent, name, syscallMode(perm))
+		})
+	})
+	if err != nil {
+		return &PathError{Op: "mkdirat", Path: name, Err: err}
+	}
+	return nil
+}
+
+func rootRemove(r *Root, name string) error {
+	err := doInRoot(r, name, func(parent int, name string) error {
+		// As in Remove, try both unlink and rmdir.
+		e := ignoringEINTR(func() error {
+			return unix.Unlinkat(parent, name, 0)
+		})
+		if e == nil {
+			return nil
+		}
+		e1 := ignoringEINTR(func() error {
+			return unix.Unlinkat(parent, name, unix.AT_REMOVEDIR)
+		})
+		if e1 == nil {
+			return nil
+		}
+		if e1 != syscall.ENOTDIR {
+			e = e1
+		}
+		return e
+	})
+	if err != nil {
+		return &PathError{Op: "removeat", Path: name, Err: err}
+	}
+	return nil
+}
+
+func rootStat(r *Root, name string, lstat bool) (FileInfo, error) {
+	var fs fileStat
+	err := doInRoot(r, name, func(parent int, name string) error {
+		err := ignoringEINTR(func() error {
+			return unix.Fstatat(parent, name, &fs.sys, unix.AT_SYMLINK_NOFOLLOW)
+		})
+		if err == nil && !lstat && fs.sys.Mode&syscall.S_IFMT == syscall.S_IFLNK {
+			return syscall.ELOOP // follow the link
+		}
+		return err
+	})
+	if err != nil {
+		op := "statat"
+		if lstat {
+			op = "lstatat"
+		}
+		return nil, &PathError{Op: op, Path: name, Err: err}
+	}
+	fillFileStatFromSys(&fs, name)
+	return &fs, nil
+}
@@ -0,0 +1,247 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package os_test
+
+import (
+	"errors"
+	"internal/testenv"
+	"io"
+	"io/fs"
+	. "os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// makeRootTree creates a directory tree for the Root tests:
+//
+//	outside           file outside the root
+//	root/file         contains "file"
+//	root/dir/sub      contains "sub"
+//	root/link         -> dir/sub (if symlinks are supported)
+//	root/dirlink      -> dir
+//	root/up           -> dir/../file
+//	root/escape       -> ../outside
+//	root/abs          -> absolute path of outside
+//	root/loop         -> loop
+func makeRootTree(t *testing.T) (dir string, symlinks bool) {
+	dir = t.TempDir()
+	for name, data := range map[string]string{
+		"outside":      "outside",
+		"root/file":    "file",
+		"root/dir/sub": "sub",
+	} {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := MkdirAll(filepath.Dir(name), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteFile(name, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !testenv.HasSymlink() {
+		return dir, false
+	}
+	for link, target := range map[string]string{
+		"link":    "dir/sub",
+		"dirlink": "dir",
+		"up":      "dir/../file",
+		"escape":  "../outside",
+		"abs":     filepath.Join(dir, "outside"),
+		"loop":    "loop",
+	} {
+		if err := Symlink(filepath.FromSlash(target), filepath.Join(dir, "root", link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, true
+}
+
+func TestRootOpen(t *testing.T) {
+	dir, symlinks := makeRootTree(t)
+	root, err := OpenRoot(filepath.Join(dir, "root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	tests := []struct {
+		name    string
+		want    string // file content; "" means an error is expected
+		escapes bool   // the error must report an escape
+		symlink bool   // test needs symlinks
+	}{
+		{name: "file", want: "file"},
+		{name: "./dir//sub", want: "sub"},
+		{name: "dir/../file", want: "file"},
+		{name: "dir/../dir/./sub", want: "sub"},
+		{name: "missing"},
+		{name: "file/sub"},
+		{name: "..", escapes: true},
+		{name: "dir/../../outside", escapes: true},
+		{name: "../root/file", escapes: true},
+		{name: "/etc/passwd", escapes: true},
+		{name: "link", want: "sub", symlink: true},
+		{name: "dirlink/sub", want: "sub", symlink: true},
+		{name: "dirlink/../file", want: "file", symlink: true},
+		{name: "up", want: "file", symlink: true},
+		{name: "escape", escapes: true, symlink: true},
+		{name: "abs", escapes: true, symlink: true},
+		{name: "loop", symlink: true},
+	}
+	for _, test := range tests {
+		if test.symlink && !symlinks {
+			continue
+		}
+		f, err := root.Open(test.name)
+		if test.want == "" {
+			if err == nil {
+				f.Close()
+				t.Errorf("Open(%q) succeeded, want error", test.name)
+			} else if test.escapes && !strings.Contains(err.Error(), "path escapes from parent") {
+				t.Errorf("Open(%q) = %v, want escape error", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Open(%q): %v", test.name, err)
+			continue
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil || string(data) != test.want {
+			t.Errorf("Open(%q): read %q, %v, want %q", test.name, data, err, test.want)
+		}
+	}
+}
+
+func TestRootWrite(t *testing.T) {
+	dir, symlinks := makeRootTree(t)
+	root, err := OpenRoot(filepath.Join(dir, "root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	if err := root.Mkdir("new", 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Mkdir("new", 0777); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("Mkdir of existing directory: %v, want ErrExist", err)
+	}
+	if err := root.Mkdir("../new", 0777); err == nil {
+		t.Errorf("Mkdir(../new) succeeded")
+	}
+	if err := root.Mkdir("x", 01777); err == nil {
+		t.Errorf("Mkdir with sticky bit succeeded")
+	}
+	f, err := root.Create("new/created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("created"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if data, err := ReadFile(filepath.Join(dir, "root", "new", "created")); err != nil || string(data) != "created" {
+		t.Errorf("reading created file: %q, %v", data, err)
+	}
+	if _, err := root.Create("dir/../../created"); err == nil {
+		t.Errorf("Create outside root succeeded")
+	}
+	if _, err := Stat(filepath.Join(dir, "created")); !IsNotExist(err) {
+		t.Errorf("file created outside root: %v", err)
+	}
+
+	fi, err := root.Stat("new/created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Name() != "created" || fi.Size() != 7 || !fi.Mode().IsRegular() {
+		t.Errorf("Stat: name %q, size %d, mode %v", fi.Name(), fi.Size(), fi.Mode())
+	}
+	if err := root.Remove("new"); err == nil {
+		t.Errorf("Remove of non-empty directory succeeded")
+	}
+	if err := root.Remove("new/created"); err != nil {
+		t.Error(err)
+	}
+	if err := root.Remove("new"); err != nil {
+		t.Error(err)
+	}
+	if _, err := root.Stat("new"); !IsNotExist(err) {
+		t.Errorf("Stat after Remove: %v, want not exist", err)
+	}
+
+	if symlinks {
+		if fi, err := root.Lstat("link"); err != nil || fi.Mode()&ModeSymlink == 0 {
+			t.Errorf("Lstat(link): %v, %v, want symlink", fi, err)
+		}
+		if fi, err := root.Stat("link"); err != nil || !fi.Mode().IsRegular() {
+			t.Errorf("Stat(link): %v, %v, want regular file", fi, err)
+		}
+		if _, err := root.Stat("escape"); err == nil {
+			t.Errorf("Stat(escape) succeeded")
+		}
+		if _, err := root.Create("escape"); err == nil {
+			t.Errorf("Create(escape) succeeded")
+		}
+		if data, err := ReadFile(filepath.Join(dir, "outside")); err != nil || string(data) != "outside" {
+			t.Errorf("file outside root changed: %q, %v", data, err)
+		}
+		// Remove removes the link, not its target.
+		if err := root.Remove("escape"); err != nil {
+			t.Error(err)
+		}
+		if _, err := Stat(filepath.Join(dir, "outside")); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestRootClose(t *testing.T) {
+	root, err := OpenRoot(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := root.Name()
+	if err := root.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Close(); err == nil {
+		t.Errorf("second Close succeeded")
+	}
+	if _, err := root.Open("."); !errors.Is(err, ErrClosed) {
+		t.Errorf("Open after Close: %v, want ErrClosed", err)
+	}
+	if root.Name() != name {
+		t.Errorf("Name after Close = %q, want %q", root.Name(), name)
+	}
+	if _, err := OpenRoot(filepath.Join(name, "missing")); !IsNotExist(err) {
+		t.Errorf("OpenRoot of missing directory: %v", err)
+	}
+}
+
+func TestRootFS(t *testing.T) {
+	dir, _ := makeRootTree(t)
+	root, err := OpenRoot(filepath.Join(dir, "root", "dir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+	if err := fstest.TestFS(root.FS(), "sub"); err != nil {
+		t.Fatal(err)
+	}
+
+	empty, err := OpenRoot(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer empty.Close()
+	if err := fstest.TestWriteFS(empty.FS()); err != nil {
+		t.Fatal(err)
+	}
+}
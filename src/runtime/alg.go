@@ -311,6 +311,11 @@ func efaceHash(i interface{}, seed uintptr) uintptr {
 	return nilinterhash(noescape(unsafe.Pointer(&i)), seed)
 }
 
+//go:linkname sync_runtime_efaceHash sync.runtime_efaceHash
+func sync_runtime_efaceHash(i interface{}, seed uintptr) uintptr {
+	return nilinterhash(noescape(unsafe.Pointer(&i)), seed)
+}
+
 func ifaceHash(i interface {
 	F()
 }, seed uintptr) uintptr {
@@ -865,6 +865,12 @@ TEXT runtime·stackcheck(SB), NOSPLIT, $0-0
 	CALL	runtime·abort(SB)
 	RET
 
+// func getfp() uintptr
+TEXT runtime·getfp(SB),NOSPLIT,$0-8
+	MOVQ	BP, AX
+	MOVQ	AX, ret+0(FP)
+	RET
+
 // func cputicks() int64
 TEXT runtime·cputicks(SB),NOSPLIT,$0-0
 	CMPB	runtime·lfenceBeforeRdtsc(SB), $1
@@ -850,6 +850,12 @@ aesloop:
 	VST1	[V0.D1], (R2)
 	RET
 
+// func getfp() uintptr
+TEXT runtime·getfp(SB),NOSPLIT|NOFRAME,$0-8
+	MOVD	R29, R0
+	MOVD	R0, ret+0(FP)
+	RET
+
 TEXT runtime·procyield(SB),NOSPLIT,$0-0
 	MOVWU	cycles+0(FP), R0
 again:
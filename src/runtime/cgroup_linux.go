@@ -0,0 +1,341 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import "unsafe"
+
+// The CPU bandwidth limit of a cgroup is set in the cpu.max file of
+// its directory for cgroup v2, and in the cpu.cfs_quota_us and
+// cpu.cfs_period_us files for cgroup v1. The limits of the ancestors
+// of a cgroup also apply to it.
+//
+// The runtime finds the directory of the process's cgroup once at
+// startup, from /proc/self/cgroup and /proc/self/mountinfo, and reads
+// the limits from there whenever it needs them.
+
+var (
+	procSelfCgroup    = []byte("/proc/self/cgroup\x00")
+	procSelfMountinfo = []byte("/proc/self/mountinfo\x00")
+)
+
+// cgroupPathMax is the maximum length of the path of a cgroup directory.
+// Deeper cgroups are treated as if they had no CPU limit.
+const cgroupPathMax = 512
+
+// cgroupLineMax is the maximum length of a line of the files read by
+// a cgroupLineReader.
+const cgroupLineMax = 1024
+
+// A cgroupState is the location of the cgroup of the process in the
+// hierarchy with the CPU controller.
+type cgroupState struct {
+	version int // 1 or 2, or 0 if there is no CPU controller
+
+	// dir[:ndir] is the directory of the cgroup, and dir[:nroot]
+	// the mount point of the hierarchy. The limits of every
+	// directory between the two apply to the process.
+	dir   [cgroupPathMax]byte
+	ndir  int
+	nroot int
+}
+
+// cgroupCPU is the cgroup of the process. It is set by cgroupInit
+// before any other goroutine runs, and read-only after.
+var cgroupCPU cgroupState
+
+// cgroupInit finds the cgroup of the process.
+func cgroupInit() {
+	if debug.containermaxprocs == 0 {
+		return
+	}
+	cgroupCPU.find(&procSelfCgroup[0], &procSelfMountinfo[0])
+}
+
+// cgroupCPULimit returns the CPU bandwidth limit of the process,
+// in CPUs, or 0 if there is none.
+func cgroupCPULimit() float64 {
+	return cgroupCPU.cpuLimit()
+}
+
+// cgroupCPUFound reports whether the process has
+// a cgroup with a CPU controller.
+func cgroupCPUFound() bool {
+	return cgroupCPU.version != 0
+}
+
+// find sets c to the cgroup with the CPU controller described by the
+// cgroup file, which lists the cgroups of the process, and the
+// mountinfo file, which lists the mount points of the hierarchies.
+// A v1 CPU controller takes precedence over the unified v2 hierarchy,
+// as it's the one that applies when the two coexist.
+func (c *cgroupState) find(cgroupFile, mountinfoFile *byte) {
+	*c = cgroupState{}
+
+	// Lines of the cgroup file are hierarchy-ID:controller-list:cgroup-path.
+	// The v2 hierarchy has ID 0 and no controller list.
+	var r cgroupLineReader
+	if !r.open(cgroupFile) {
+		return
+	}
+	var v1, v2 [cgroupPathMax]byte
+	n1, n2 := -1, -1
+	for {
+		line, ok := r.next()
+		if !ok {
+			break
+		}
+		id, line := cgroupCut(line, ':')
+		ctrls, path := cgroupCut(line, ':')
+		if len(path) > cgroupPathMax {
+			continue
+		}
+		if string(id) == "0" && len(ctrls) == 0 {
+			n2 = copy(v2[:], path)
+		} else if cgroupHasOption(ctrls, "cpu") {
+			n1 = copy(v1[:], path)
+		}
+	}
+	r.close()
+	if n1 < 0 && n2 < 0 {
+		return
+	}
+
+	// Fields of the mountinfo file are described in proc(5):
+	// mount-ID parent-ID major:minor root mount-point options
+	// optional-fields... - fstype source super-options
+	if !r.open(mountinfoFile) {
+		return
+	}
+	for c.version != 1 {
+		line, ok := r.next()
+		if !ok {
+			break
+		}
+		var f [5][]byte
+		for i := range f {
+			f[i], line = cgroupCut(line, ' ')
+		}
+		root, mount := f[3], f[4]
+		for len(line) > 0 {
+			var field []byte
+			if field, line = cgroupCut(line, ' '); string(field) == "-" {
+				break
+			}
+		}
+		fstype, line := cgroupCut(line, ' ')
+		_, line = cgroupCut(line, ' ')
+		opts, _ := cgroupCut(line, ' ')
+
+		var path []byte
+		switch {
+		case n1 >= 0 && string(fstype) == "cgroup" && cgroupHasOption(opts, "cpu"):
+			path = v1[:n1]
+		case n2 >= 0 && string(fstype) == "cgroup2" && c.version == 0:
+			path = v2[:n2]
+		default:
+			continue
+		}
+		// The cgroup path is relative to the root of the hierarchy,
+		// of which the mount point may be a subdirectory.
+		if string(root) != "/" {
+			if !cgroupHasPathPrefix(path, root) {
+				continue
+			}
+			path = path[len(root):]
+		}
+		if string(path) == "/" {
+			path = nil
+		}
+		if len(mount)+len(path) > len(c.dir) {
+			continue
+		}
+		c.nroot = copy(c.dir[:], mount)
+		c.ndir = c.nroot + copy(c.dir[c.nroot:], path)
+		if string(fstype) == "cgroup" {
+			c.version = 1
+		} else {
+			c.version = 2
+		}
+	}
+	r.close()
+}
+
+// cpuLimit returns the lowest CPU bandwidth limit of the cgroup c and its
+// ancestors, in CPUs, or 0 if there is none.
+func (c *cgroupState) cpuLimit() float64 {
+	if c.version == 0 {
+		return 0
+	}
+	limit := 0.0
+	n := c.ndir
+	for {
+		if l := c.dirCPULimit(c.dir[:n]); l > 0 && (limit == 0 || l < limit) {
+			limit = l
+		}
+		if n <= c.nroot {
+			return limit
+		}
+		// Move on to the parent directory.
+		for n > c.nroot && c.dir[n-1] != '/' {
+			n--
+		}
+		if n > c.nroot {
+			n-- // the slash
+		}
+	}
+}
+
+// dirCPULimit returns the CPU bandwidth limit set in the cgroup directory
+// dir, in CPUs, or 0 if there is none.
+func (c *cgroupState) dirCPULimit(dir []byte) float64 {
+	var buf [64]byte
+	var quota, period int64
+	if c.version == 2 {
+		// cpu.max is "max period" or "quota period".
+		b := cgroupReadFile(dir, "/cpu.max", buf[:])
+		q, p := cgroupCut(b, ' ')
+		if string(q) == "max" {
+			return 0
+		}
+		quota, period = cgroupAtoi(q), cgroupAtoi(p)
+	} else {
+		// cpu.cfs_quota_us is -1 if there is no limit.
+		quota = cgroupAtoi(cgroupReadFile(dir, "/cpu.cfs_quota_us", buf[:]))
+		period = cgroupAtoi(cgroupReadFile(dir, "/cpu.cfs_period_us", buf[:]))
+	}
+	if quota <= 0 || period <= 0 {
+		return 0
+	}
+	return float64(quota) / float64(period)
+}
+
+// cgroupReadFile reads the file dir+name into buf,
+// and returns its contents without trailing newline.
+// It returns nil if the file can't be read.
+func cgroupReadFile(dir []byte, name string, buf []byte) []byte {
+	var path [cgroupPathMax + 32]byte
+	if len(dir)+len(name) >= len(path) {
+		return nil
+	}
+	n := copy(path[:], dir)
+	n += copy(path[n:], name)
+	path[n] = 0
+	fd := open(&path[0], 0 /* O_RDONLY */, 0)
+	if fd < 0 {
+		return nil
+	}
+	r := read(fd, noescape(unsafe.Pointer(&buf[0])), int32(len(buf)))
+	closefd(fd)
+	if r <= 0 {
+		return nil
+	}
+	b := buf[:r]
+	for len(b) > 0 && b[len(b)-1] == '\n' {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+// A cgroupLineReader reads the lines of a file.
+// Lines longer than its buffer are skipped.
+type cgroupLineReader struct {
+	fd         int32
+	buf        [cgroupLineMax]byte
+	start, end int  // unread bytes of buf
+	eof        bool // no more bytes to read
+	skip       bool // skip the rest of the current line
+}
+
+func (r *cgroupLineReader) open(path *byte) bool {
+	*r = cgroupLineReader{}
+	r.fd = open(path, 0 /* O_RDONLY */, 0)
+	return r.fd >= 0
+}
+
+func (r *cgroupLineReader) close() {
+	closefd(r.fd)
+}
+
+// next returns the next line of the file, without its newline.
+// It returns false at the end of the file, or if it can't be read.
+func (r *cgroupLineReader) next() ([]byte, bool) {
+	for {
+		for i := r.start; i < r.end; i++ {
+			if r.buf[i] != '\n' {
+				continue
+			}
+			line := r.buf[r.start:i]
+			r.start = i + 1
+			if r.skip {
+				r.skip = false
+				continue
+			}
+			return line, true
+		}
+		if r.eof {
+			line := r.buf[r.start:r.end]
+			r.start = r.end
+			return line, len(line) > 0 && !r.skip
+		}
+		// Move the partial line to the front of buf and read more.
+		r.end = copy(r.buf[:], r.buf[r.start:r.end])
+		r.start = 0
+		if r.end == len(r.buf) {
+			r.skip = true
+			r.end = 0
+		}
+		n := read(r.fd, noescape(unsafe.Pointer(&r.buf[r.end])), int32(len(r.buf)-r.end))
+		if n <= 0 {
+			r.eof = true
+		} else {
+			r.end += int(n)
+		}
+	}
+}
+
+// cgroupCut slices b around the first instance of sep.
+func cgroupCut(b []byte, sep byte) (before, after []byte) {
+	for i, c := range b {
+		if c == sep {
+			return b[:i], b[i+1:]
+		}
+	}
+	return b, nil
+}
+
+// cgroupHasOption reports whether the comma-separated list opts has opt.
+func cgroupHasOption(opts []byte, opt string) bool {
+	for len(opts) > 0 {
+		var o []byte
+		o, opts = cgroupCut(opts, ',')
+		if string(o) == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// cgroupHasPathPrefix reports whether path is in the directory dir.
+func cgroupHasPathPrefix(path, dir []byte) bool {
+	if len(path) < len(dir) || string(path[:len(dir)]) != string(dir) {
+		return false
+	}
+	return len(path) == len(dir) || path[len(dir)] == '/'
+}
+
+// cgroupAtoi returns the value of the decimal number b, or -1.
+func cgroupAtoi(b []byte) int64 {
+	if len(b) == 0 {
+		return -1
+	}
+	n := int64(0)
+	for _, c := range b {
+		if c < '0' || c > '9' || n > 1<<40 {
+			return -1
+		}
+		n = n*10 + int64(c-'0')
+	}
+	return n
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime_test
+
+import (
+	"os"
+	"path/filepath"
+	. "runtime"
+	"strings"
+	"testing"
+)
+
+func TestCgroupCPULimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		cgroup    string
+		mountinfo string
+		files     map[string]string // relative to the mount point
+		version   int
+		dir       string // relative to the mount point
+		limit     float64
+	}{
+		{
+			name:   "v2",
+			cgroup: "0::/a/b\n",
+			mountinfo: "21 1 0:19 / /proc rw - proc proc rw\n" +
+				"30 21 0:26 / MNT rw,nosuid shared:4 - cgroup2 cgroup2 rw,nsdelegate\n",
+			files: map[string]string{
+				"a/b/cpu.max": "max 100000\n",
+				"a/cpu.max":   "150000 100000\n",
+				"cpu.max":     "400000 100000\n",
+			},
+			version: 2,
+			dir:     "a/b",
+			limit:   1.5,
+		},
+		{
+			name:      "v2 no limit",
+			cgroup:    "0::/a\n",
+			mountinfo: "30 21 0:26 / MNT rw shared:4 - cgroup2 cgroup2 rw\n",
+			files: map[string]string{
+				"a/cpu.max": "max 100000\n",
+			},
+			version: 2,
+			dir:     "a",
+			limit:   0,
+		},
+		{
+			name: "v1",
+			cgroup: "12:memory:/x/y\n" +
+				"3:cpu,cpuacct:/x/y\n" +
+				"0::/\n",
+			mountinfo: "30 21 0:26 / /nonexistent rw - cgroup2 cgroup2 rw\n" +
+				"31 21 0:27 / MNT rw shared:5 - cgroup cgroup rw,cpu,cpuacct\n" +
+				"32 21 0:28 / /nonexistent/memory rw shared:6 - cgroup cgroup rw,memory\n",
+			files: map[string]string{
+				"x/y/cpu.cfs_quota_us":  "250000\n",
+				"x/y/cpu.cfs_period_us": "100000\n",
+				"x/cpu.cfs_quota_us":    "-1\n",
+				"x/cpu.cfs_period_us":   "100000\n",
+			},
+			version: 1,
+			dir:     "x/y",
+			limit:   2.5,
+		},
+		{
+			name:      "mount of a subtree",
+			cgroup:    "0::/pod/ctr\n",
+			mountinfo: "30 21 0:26 /pod MNT rw - cgroup2 cgroup2 rw\n",
+			files: map[string]string{
+				"ctr/cpu.max": "300000 100000\n",
+			},
+			version: 2,
+			dir:     "ctr",
+			limit:   3,
+		},
+		{
+			name:      "no controller",
+			cgroup:    "12:memory:/x\n",
+			mountinfo: "32 21 0:28 / MNT rw - cgroup cgroup rw,memory\n",
+			version:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			mnt := filepath.Join(tmp, "mnt")
+			for name, data := range tt.files {
+				name = filepath.Join(mnt, name)
+				if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(name, []byte(data), 0666); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cgroupFile := filepath.Join(tmp, "cgroup")
+			mountinfoFile := filepath.Join(tmp, "mountinfo")
+			if err := os.WriteFile(cgroupFile, []byte(tt.cgroup), 0666); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(mountinfoFile, []byte(strings.ReplaceAll(tt.mountinfo, "MNT", mnt)), 0666); err != nil {
+				t.Fatal(err)
+			}
+
+			var c CgroupState
+			version, dir := c.Find(cgroupFile, mountinfoFile)
+			if version != tt.version {
+				t.Fatalf("got version %d, want %d", version, tt.version)
+			}
+			if version == 0 {
+				return
+			}
+			if want := filepath.Join(mnt, tt.dir); dir != want {
+				t.Errorf("got directory %q, want %q", dir, want)
+			}
+			if limit := c.CPULimit(); limit != tt.limit {
+				t.Errorf("got limit %v, want %v", limit, tt.limit)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package runtime
+
+// cgroupInit finds the cgroup of the process.
+// Only Linux has cgroups.
+func cgroupInit() {}
+
+// cgroupCPULimit returns the CPU bandwidth limit of the process,
+// in CPUs, or 0 if there is none.
+func cgroupCPULimit() float64 {
+	return 0
+}
+
+// cgroupCPUFound reports whether the process has
+// a cgroup with a CPU controller.
+func cgroupCPUFound() bool {
+	return false
+}
@@ -10,9 +10,17 @@ import (
 )
 
 // GOMAXPROCS sets the maximum number of CPUs that can be executing
-// simultaneously and returns the previous setting. It defaults to
-// the value of runtime.NumCPU. If n < 1, it does not change the current setting.
-// This call will go away when the scheduler improves.
+// simultaneously and returns the previous setting. If n < 1, it does not
+// change the current setting. This call will go away when the scheduler improves.
+//
+// Unless the GOMAXPROCS environment variable is set, GOMAXPROCS defaults
+// to the value of runtime.NumCPU. On Linux, if the process's cgroup, or
+// one of its ancestors, limits the CPU bandwidth of the process, the
+// default is instead that limit rounded up, and at least 2, when that is
+// lower than NumCPU. The runtime checks the limit periodically, and
+// updates GOMAXPROCS when it changes, until GOMAXPROCS is called with
+// n >= 1. The containermaxprocs and updatemaxprocs settings of GODEBUG
+// turn off the use of the limit and its updates.
 func GOMAXPROCS(n int) int {
 	if GOARCH == "wasm" && n > 1 {
 		n = 1 // WebAssembly has no threads yet, so only one CPU is possible.
@@ -21,6 +29,10 @@ func GOMAXPROCS(n int) int {
 	lock(&sched.lock)
 	ret := int(gomaxprocs)
 	unlock(&sched.lock)
+	if n > 0 {
+		// The setting is the user's from now on.
+		atomic.Store(&maxprocs.source, gomaxprocsSourceCall)
+	}
 	if n <= 0 || n == ret {
 		return ret
 	}
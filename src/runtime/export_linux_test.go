@@ -17,3 +17,18 @@ type EpollEvent epollevent
 func Epollctl(epfd, op, fd int32, ev unsafe.Pointer) int32 {
 	return epollctl(epfd, op, fd, (*epollevent)(ev))
 }
+
+type CgroupState struct {
+	c cgroupState
+}
+
+// Find looks up the cgroup with the CPU controller in the given
+// cgroup and mountinfo files, and returns its version and directory.
+func (c *CgroupState) Find(cgroupFile, mountinfoFile string) (version int, dir string) {
+	c.c.find(&[]byte(cgroupFile + "\x00")[0], &[]byte(mountinfoFile + "\x00")[0])
+	return c.c.version, string(c.c.dir[:c.c.ndir])
+}
+
+func (c *CgroupState) CPULimit() float64 {
+	return c.c.cpuLimit()
+}
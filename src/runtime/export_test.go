@@ -441,6 +441,18 @@ func (rw *RWMutex) Unlock() {
 	rw.rw.unlock()
 }
 
+type Mutex struct {
+	m mutex
+}
+
+func (m *Mutex) Lock() {
+	lock(&m.m)
+}
+
+func (m *Mutex) Unlock() {
+	unlock(&m.m)
+}
+
 const RuntimeHmapSize = unsafe.Sizeof(hmap{})
 
 func MapBucketsCount(m map[int]int) int {
@@ -38,6 +38,10 @@ It is a comma-separated list of name=val pairs setting these named variables:
 	expensive checks that should not miss any errors, but will
 	cause your program to run slower.
 
+	containermaxprocs: setting containermaxprocs=0 makes the default GOMAXPROCS
+	the number of CPUs, ignoring the CPU bandwidth limit of the process's cgroup
+	on Linux. See the GOMAXPROCS function.
+
 	efence: setting efence=1 causes the allocator to run in a mode
 	where each object is allocated on a unique page and addresses are
 	never recycled.
@@ -139,6 +143,15 @@ It is a comma-separated list of name=val pairs setting these named variables:
 	IDs will refer to the ID of the goroutine at the time of creation; it's possible for this
 	ID to be reused for another goroutine. Setting N to 0 will report no ancestry information.
 
+	tracefpunwindoff: setting tracefpunwindoff=1 makes the execution tracer unwind
+	stacks with the same unwinder as panics and runtime.Callers, rather than by
+	following frame pointers. This is much slower, and is only useful to work
+	around a problem with frame pointers.
+
+	updatemaxprocs: setting updatemaxprocs=0 stops the runtime from updating
+	the default GOMAXPROCS when the CPU bandwidth limit of the process's cgroup
+	changes. The limit read at startup still applies.
+
 	asyncpreemptoff: asyncpreemptoff=1 disables signal-based
 	asynchronous goroutine preemption. This makes some loops
 	non-preemptible for long periods, which may delay GC and
@@ -153,7 +166,7 @@ The GOMAXPROCS variable limits the number of operating system threads that
 can execute user-level Go code simultaneously. There is no limit to the number of threads
 that can be blocked in system calls on behalf of Go code; those do not count against
 the GOMAXPROCS limit. This package's GOMAXPROCS function queries and changes
-the limit.
+the limit, and describes its default value.
 
 The GORACE variable configures the race detector, for programs built using -race.
 See https://golang.org/doc/articles/race_detector.html for details.
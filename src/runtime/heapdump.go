@@ -6,8 +6,8 @@
 // objects in the heap plus additional info (roots, threads,
 // finalizers, etc.) to a file.
 
-// The format of the dumped file is described at
-// https://golang.org/s/go15heapdump.
+// The format of the dumped file is described in the documentation
+// of package debug/heapdump, which reads it.
 
 package runtime
 
@@ -61,6 +61,7 @@ const (
 	tagPanic           = 15
 	tagMemProf         = 16
 	tagAllocSample     = 17
+	tagModule          = 18
 )
 
 var dumpfd uintptr // fd to write the dump to.
@@ -434,18 +435,19 @@ func dumproots() {
 	// To protect mheap_.allspans.
 	assertWorldStopped()
 
-	// TODO(mwhudson): dump datamask etc from all objects
-	// data segment
-	dumpint(tagData)
-	dumpint(uint64(firstmoduledata.data))
-	dumpmemrange(unsafe.Pointer(firstmoduledata.data), firstmoduledata.edata-firstmoduledata.data)
-	dumpfields(firstmoduledata.gcdatamask)
+	for _, md := range activeModules() {
+		// data segment
+		dumpint(tagData)
+		dumpint(uint64(md.data))
+		dumpmemrange(unsafe.Pointer(md.data), md.edata-md.data)
+		dumpfields(md.gcdatamask)
 
-	// bss segment
-	dumpint(tagBSS)
-	dumpint(uint64(firstmoduledata.bss))
-	dumpmemrange(unsafe.Pointer(firstmoduledata.bss), firstmoduledata.ebss-firstmoduledata.bss)
-	dumpfields(firstmoduledata.gcbssmask)
+		// bss segment
+		dumpint(tagBSS)
+		dumpint(uint64(md.bss))
+		dumpmemrange(unsafe.Pointer(md.bss), md.ebss-md.bss)
+		dumpfields(md.gcbssmask)
+	}
 
 	// mspan.types
 	for _, s := range mheap_.allspans {
@@ -533,6 +535,29 @@ func dumpparams() {
 	dumpstr(sys.GOARCH)
 	dumpstr(sys.Goexperiment)
 	dumpint(uint64(ncpu))
+	dumpstr(buildVersion)
+}
+
+// dumpmodules dumps the address ranges of the sections of each module,
+// which readers need to match the dump with the symbols and debug
+// information of the executable.
+func dumpmodules() {
+	for _, md := range activeModules() {
+		dumpint(tagModule)
+		dumpstr(md.modulename)
+		dumpint(uint64(md.text))
+		dumpint(uint64(md.etext))
+		dumpint(uint64(md.types))
+		dumpint(uint64(md.etypes))
+		dumpint(uint64(md.noptrdata))
+		dumpint(uint64(md.enoptrdata))
+		dumpint(uint64(md.data))
+		dumpint(uint64(md.edata))
+		dumpint(uint64(md.bss))
+		dumpint(uint64(md.ebss))
+		dumpint(uint64(md.noptrbss))
+		dumpint(uint64(md.enoptrbss))
+	}
 }
 
 func itab_callback(tab *itab) {
@@ -663,7 +688,10 @@ func dumpmemprof() {
 	}
 }
 
-var dumphdr = []byte("go1.7 heap dump\n")
+// The header identifies the format version. Version 2 added the
+// module records, the data and bss of every module, and the Go
+// version in the parameters.
+var dumphdr = []byte("go heap dump v2\n")
 
 func mdump(m *MemStats) {
 	assertWorldStopped()
@@ -677,6 +705,7 @@ func mdump(m *MemStats) {
 	memclrNoHeapPointers(unsafe.Pointer(&typecache), unsafe.Sizeof(typecache))
 	dwrite(unsafe.Pointer(&dumphdr[0]), uintptr(len(dumphdr)))
 	dumpparams()
+	dumpmodules()
 	dumpitabs()
 	dumpobjs()
 	dumpgs()
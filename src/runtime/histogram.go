@@ -114,6 +114,18 @@ func (h *timeHistogram) record(duration int64) {
 	atomic.Xadd64(&h.counts[superBucket*timeHistNumSubBuckets+subBucket], 1)
 }
 
+// read copies the counts of h into hist, whose buckets must be
+// timeHistBuckets.
+func (h *timeHistogram) read(hist *metricFloat64Histogram) {
+	// The bottom-most bucket, containing negative values, is tracked
+	// as a separately as underflow, so fill that in manually and then
+	// iterate over the rest.
+	hist.counts[0] = atomic.Load64(&h.underflow)
+	for i := range h.counts {
+		hist.counts[i+1] = atomic.Load64(&h.counts[i])
+	}
+}
+
 const (
 	fInf    = 0x7FF0000000000000
 	fNegInf = 0xFFF0000000000000
@@ -61,6 +61,9 @@ func lock2(l *mutex) {
 		return
 	}
 
+	timer := lockTimer{lock: l}
+	timer.begin()
+
 	// wait is either MUTEX_LOCKED or MUTEX_SLEEPING
 	// depending on whether there is a thread sleeping
 	// on this mutex. If we ever change l->key from
@@ -81,6 +84,7 @@ func lock2(l *mutex) {
 		for i := 0; i < spin; i++ {
 			for l.key == mutex_unlocked {
 				if atomic.Cas(key32(&l.key), mutex_unlocked, wait) {
+					timer.end()
 					return
 				}
 			}
@@ -91,6 +95,7 @@ func lock2(l *mutex) {
 		for i := 0; i < passive_spin; i++ {
 			for l.key == mutex_unlocked {
 				if atomic.Cas(key32(&l.key), mutex_unlocked, wait) {
+					timer.end()
 					return
 				}
 			}
@@ -100,9 +105,11 @@ func lock2(l *mutex) {
 		// Sleep.
 		v = atomic.Xchg(key32(&l.key), mutex_sleeping)
 		if v == mutex_unlocked {
+			timer.end()
 			return
 		}
 		wait = mutex_sleeping
+		timer.wait()
 		futexsleep(key32(&l.key), mutex_sleeping, -1)
 	}
 }
@@ -116,15 +123,19 @@ func unlock2(l *mutex) {
 	if v == mutex_unlocked {
 		throw("unlock of unlocked lock")
 	}
+	gp := getg()
 	if v == mutex_sleeping {
 		futexwakeup(key32(&l.key), 1)
+		gp.m.mLockProfile.recordUnlock(l)
 	}
 
-	gp := getg()
 	gp.m.locks--
 	if gp.m.locks < 0 {
 		throw("runtime·unlock: lock count")
 	}
+	if gp.m.locks == 0 && gp.m.mLockProfile.cycles != 0 {
+		gp.m.mLockProfile.store()
+	}
 	if gp.m.locks == 0 && gp.preempt { // restore the preemption request in case we've cleared it in newstack
 		gp.stackguard0 = stackPreempt
 	}
@@ -48,6 +48,8 @@ func lock2(l *mutex) {
 		return
 	}
 	semacreate(gp.m)
+	timer := lockTimer{lock: l}
+	timer.begin()
 
 	// On uniprocessor's, no point spinning.
 	// On multiprocessors, spin for ACTIVE_SPIN attempts.
@@ -61,6 +63,7 @@ Loop:
 		if v&locked == 0 {
 			// Unlocked. Try to lock.
 			if atomic.Casuintptr(&l.key, v, v|locked) {
+				timer.end()
 				return
 			}
 			i = 0
@@ -86,6 +89,7 @@ Loop:
 			}
 			if v&locked != 0 {
 				// Queued. Wait.
+				timer.wait()
 				semasleep(-1)
 				i = 0
 			}
@@ -115,6 +119,7 @@ func unlock2(l *mutex) {
 			if atomic.Casuintptr(&l.key, v, uintptr(mp.nextwaitm)) {
 				// Dequeued an M.  Wake it.
 				semawakeup(mp)
+				gp.m.mLockProfile.recordUnlock(l)
 				break
 			}
 		}
@@ -123,6 +128,9 @@ func unlock2(l *mutex) {
 	if gp.m.locks < 0 {
 		throw("runtime·unlock: lock count")
 	}
+	if gp.m.locks == 0 && gp.m.mLockProfile.cycles != 0 {
+		gp.m.mLockProfile.store()
+	}
 	if gp.m.locks == 0 && gp.preempt { // restore the preemption request in case we've cleared it in newstack
 		gp.stackguard0 = stackPreempt
 	}
@@ -51,6 +51,36 @@ type Description struct {
 // The English language descriptions below must be kept in sync with the
 // descriptions of each metric in doc.go.
 var allDesc = []Description{
+	{
+		Name:        "/cgo/go-to-c-calls:calls",
+		Description: "Count of calls made from Go to C by the current process.",
+		Kind:        KindUint64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/gc/cpu/assist:cpu-seconds",
+		Description: "Estimated total CPU time goroutines spent performing GC tasks to assist the GC and prevent it from falling behind the application. This metric is updated at the end of each GC cycle.",
+		Kind:        KindFloat64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/gc/cpu/background:cpu-seconds",
+		Description: "Estimated total CPU time spent performing GC tasks on processors dedicated to the GC, either fully or for a fraction of the time. This metric is updated at the end of each GC cycle.",
+		Kind:        KindFloat64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/gc/cpu/idle:cpu-seconds",
+		Description: "Estimated total CPU time spent performing GC tasks on spare CPU resources that the Go scheduler could not otherwise find a use for. This metric is updated at the end of each GC cycle.",
+		Kind:        KindFloat64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/gc/cpu/pause:cpu-seconds",
+		Description: "Estimated total CPU time spent with the application paused by the GC, that is, pause time multiplied by GOMAXPROCS. This metric is updated at the end of each GC cycle.",
+		Kind:        KindFloat64,
+		Cumulative:  true,
+	},
 	{
 		Name:        "/gc/cycles/automatic:gc-cycles",
 		Description: "Count of completed GC cycles generated by the Go runtime.",
@@ -69,18 +99,47 @@ var allDesc = []Description{
 		Kind:        KindUint64,
 		Cumulative:  true,
 	},
+	{
+		Name:        "/gc/gogc:percent",
+		Description: "Heap size target percentage configured by the user with the GOGC environment variable or runtime/debug.SetGCPercent, otherwise 100. If the GC is off, the value is the maximum uint64 value.",
+		Kind:        KindUint64,
+	},
 	{
 		Name:        "/gc/heap/allocs-by-size:bytes",
-		Description: "Distribution of all objects allocated by approximate size.",
+		Description: "Distribution of all objects allocated by approximate size. Small objects have a bucket for each size class, so the counts are per-size-class allocation counts; larger objects share the last bucket.",
 		Kind:        KindFloat64Histogram,
 		Cumulative:  true,
 	},
+	{
+		Name:        "/gc/heap/allocs:bytes",
+		Description: "Cumulative sum of memory allocated to the heap by the application. Tiny allocations that share a block are not counted individually.",
+		Kind:        KindUint64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/gc/heap/allocs:objects",
+		Description: "Cumulative count of heap allocations triggered by the application. Tiny allocations that share a block are counted as one allocation.",
+		Kind:        KindUint64,
+		Cumulative:  true,
+	},
 	{
 		Name:        "/gc/heap/frees-by-size:bytes",
-		Description: "Distribution of all objects freed by approximate size.",
+		Description: "Distribution of all objects freed by approximate size. Small objects have a bucket for each size class, so the counts are per-size-class free counts; larger objects share the last bucket.",
 		Kind:        KindFloat64Histogram,
 		Cumulative:  true,
 	},
+	{
+		Name:        "/gc/heap/frees:bytes",
+		Description: "Cumulative sum of heap memory freed by the garbage collector.",
+		Kind:        KindUint64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/gc/heap/frees:objects",
+		Description: "Cumulative count of heap allocations whose storage was freed by the garbage collector. Tiny allocations that share a block are counted as one allocation.",
+		Kind:        KindUint64,
+		Cumulative:  true,
+	},
 	{
 		Name:        "/gc/heap/goal:bytes",
 		Description: "Heap size target for the end of the GC cycle.",
@@ -171,11 +230,60 @@ var allDesc = []Description{
 		Description: "All memory mapped by the Go runtime into the current process as read-write. Note that this does not include memory mapped by code called via cgo or via the syscall package. Sum of all metrics in /memory/classes.",
 		Kind:        KindUint64,
 	},
+	{
+		Name:        "/sched/gomaxprocs/cpu-limit:cpus",
+		Description: "CPU bandwidth limit of the process's cgroup, and its ancestors, as last read by the runtime, or 0 if there is none. Only Linux has cgroups.",
+		Kind:        KindFloat64,
+	},
+	{
+		Name:        "/sched/gomaxprocs/source:enum",
+		Description: "Source of the current GOMAXPROCS setting: 0 for the number of CPUs, 1 for the CPU bandwidth limit of the process's cgroup, 2 for the GOMAXPROCS environment variable, and 3 for a call to runtime.GOMAXPROCS.",
+		Kind:        KindUint64,
+	},
+	{
+		Name:        "/sched/gomaxprocs:threads",
+		Description: "The current runtime.GOMAXPROCS setting, or the number of operating system threads that can execute user-level Go code simultaneously.",
+		Kind:        KindUint64,
+	},
 	{
 		Name:        "/sched/goroutines:goroutines",
 		Description: "Count of live goroutines.",
 		Kind:        KindUint64,
 	},
+	{
+		Name:        "/sched/latencies:seconds",
+		Description: "Distribution of the time goroutines have spent in the scheduler in a runnable state before actually running. Only a sample of scheduling events is measured.",
+		Kind:        KindFloat64Histogram,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/sched/stacks:stacks",
+		Description: "Count of stacks allocated by the runtime for goroutines and threads and not yet freed, including those kept for reuse by exited goroutines.",
+		Kind:        KindUint64,
+	},
+	{
+		Name:        "/sched/threads:threads",
+		Description: "Count of live operating system threads created by the runtime, whether running Go code, blocked in system calls or idle.",
+		Kind:        KindUint64,
+	},
+	{
+		Name:        "/sync/mutex/runtime/wait:seconds",
+		Description: "Distribution of individual durations threads spent waiting for contended runtime-internal locks.",
+		Kind:        KindFloat64Histogram,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/sync/mutex/wait/total:seconds",
+		Description: "Approximate cumulative time goroutines and threads have spent blocked on a sync.Mutex, a sync.RWMutex, or a runtime-internal lock.",
+		Kind:        KindFloat64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/sync/mutex/wait:seconds",
+		Description: "Distribution of individual durations goroutines spent blocked on a contended sync.Mutex or sync.RWMutex.",
+		Kind:        KindFloat64Histogram,
+		Cumulative:  true,
+	},
 }
 
 // All returns a slice of containing metric descriptions for all supported metrics.
@@ -51,6 +51,32 @@ Supported metrics
 
 Below is the full list of supported metrics, ordered lexicographically.
 
+	/cgo/go-to-c-calls:calls
+		Count of calls made from Go to C by the current process.
+
+	/gc/cpu/assist:cpu-seconds
+		Estimated total CPU time goroutines spent performing GC
+		tasks to assist the GC and prevent it from falling behind
+		the application. This metric is updated at the end of each
+		GC cycle.
+
+	/gc/cpu/background:cpu-seconds
+		Estimated total CPU time spent performing GC tasks on
+		processors dedicated to the GC, either fully or for a
+		fraction of the time. This metric is updated at the end of
+		each GC cycle.
+
+	/gc/cpu/idle:cpu-seconds
+		Estimated total CPU time spent performing GC tasks on spare
+		CPU resources that the Go scheduler could not otherwise find
+		a use for. This metric is updated at the end of each GC
+		cycle.
+
+	/gc/cpu/pause:cpu-seconds
+		Estimated total CPU time spent with the application paused
+		by the GC, that is, pause time multiplied by GOMAXPROCS.
+		This metric is updated at the end of each GC cycle.
+
 	/gc/cycles/automatic:gc-cycles
 		Count of completed GC cycles generated by the Go runtime.
 
@@ -60,11 +86,42 @@ Below is the full list of supported metrics, ordered lexicographically.
 	/gc/cycles/total:gc-cycles
 		Count of all completed GC cycles.
 
+	/gc/gogc:percent
+		Heap size target percentage configured by the user with the
+		GOGC environment variable or runtime/debug.SetGCPercent,
+		otherwise 100. If the GC is off, the value is the maximum
+		uint64 value.
+
 	/gc/heap/allocs-by-size:bytes
 		Distribution of all objects allocated by approximate size.
+		Small objects have a bucket for each size class, so the counts
+		are per-size-class allocation counts; larger objects share the
+		last bucket.
+
+	/gc/heap/allocs:bytes
+		Cumulative sum of memory allocated to the heap by the
+		application. Tiny allocations that share a block are not
+		counted individually.
+
+	/gc/heap/allocs:objects
+		Cumulative count of heap allocations triggered by the
+		application. Tiny allocations that share a block are counted
+		as one allocation.
 
 	/gc/heap/frees-by-size:bytes
-		Distribution of all objects freed by approximate size.
+		Distribution of all objects freed by approximate size. Small
+		objects have a bucket for each size class, so the counts are
+		per-size-class free counts; larger objects share the last
+		bucket.
+
+	/gc/heap/frees:bytes
+		Cumulative sum of heap memory freed by the garbage
+		collector.
+
+	/gc/heap/frees:objects
+		Cumulative count of heap allocations whose storage was freed
+		by the garbage collector. Tiny allocations that share a
+		block are counted as one allocation.
 
 	/gc/heap/goal:bytes
 		Heap size target for the end of the GC cycle.
@@ -137,7 +194,51 @@ Below is the full list of supported metrics, ordered lexicographically.
 		by code called via cgo or via the syscall package.
 		Sum of all metrics in /memory/classes.
 
+	/sched/gomaxprocs/cpu-limit:cpus
+		CPU bandwidth limit of the process's cgroup, and its
+		ancestors, as last read by the runtime, or 0 if there is
+		none. Only Linux has cgroups.
+
+	/sched/gomaxprocs/source:enum
+		Source of the current GOMAXPROCS setting: 0 for the number
+		of CPUs, 1 for the CPU bandwidth limit of the process's
+		cgroup, 2 for the GOMAXPROCS environment variable, and 3 for
+		a call to runtime.GOMAXPROCS.
+
+	/sched/gomaxprocs:threads
+		The current runtime.GOMAXPROCS setting, or the number of
+		operating system threads that can execute user-level Go code
+		simultaneously.
+
 	/sched/goroutines:goroutines
 		Count of live goroutines.
+
+	/sched/latencies:seconds
+		Distribution of the time goroutines have spent in the
+		scheduler in a runnable state before actually running. Only
+		a sample of scheduling events is measured.
+
+	/sched/stacks:stacks
+		Count of stacks allocated by the runtime for goroutines and
+		threads and not yet freed, including those kept for reuse by
+		exited goroutines.
+
+	/sched/threads:threads
+		Count of live operating system threads created by the
+		runtime, whether running Go code, blocked in system calls or
+		idle.
+
+	/sync/mutex/runtime/wait:seconds
+		Distribution of individual durations threads spent waiting for
+		contended runtime-internal locks.
+
+	/sync/mutex/wait/total:seconds
+		Approximate cumulative time goroutines and threads have spent
+		blocked on a sync.Mutex, a sync.RWMutex, or a runtime-internal
+		lock.
+
+	/sync/mutex/wait:seconds
+		Distribution of individual durations goroutines spent blocked
+		on a contended sync.Mutex or sync.RWMutex.
 */
 package metrics
@@ -40,6 +40,7 @@ func TestReadMetrics(t *testing.T) {
 	}
 
 	// Check to make sure the values we read line up with other values we read.
+	var allocs, frees uint64
 	for i := range samples {
 		switch name := samples[i].Name; name {
 		case "/memory/classes/heap/free:bytes":
@@ -98,6 +99,14 @@ func TestReadMetrics(t *testing.T) {
 					t.Errorf("histogram counts do not much BySize for class %d: got %d, want %d", i, c, f)
 				}
 			}
+		case "/gc/heap/allocs:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.TotalAlloc)
+		case "/gc/heap/frees:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.TotalAlloc-mstats.HeapAlloc)
+		case "/gc/heap/allocs:objects":
+			allocs = samples[i].Value.Uint64()
+		case "/gc/heap/frees:objects":
+			frees = samples[i].Value.Uint64()
 		case "/gc/heap/objects:objects":
 			checkUint64(t, name, samples[i].Value.Uint64(), mstats.HeapObjects)
 		case "/gc/heap/goal:bytes":
@@ -108,8 +117,13 @@ func TestReadMetrics(t *testing.T) {
 			checkUint64(t, name, samples[i].Value.Uint64(), uint64(mstats.NumForcedGC))
 		case "/gc/cycles/total:gc-cycles":
 			checkUint64(t, name, samples[i].Value.Uint64(), uint64(mstats.NumGC))
+		case "/sched/gomaxprocs:threads":
+			checkUint64(t, name, samples[i].Value.Uint64(), uint64(runtime.GOMAXPROCS(0)))
 		}
 	}
+	// Tiny allocations are counted in Mallocs and Frees
+	// but not in the metrics, so only their difference matches.
+	checkUint64(t, "/gc/heap/allocs:objects - /gc/heap/frees:objects", allocs-frees, mstats.Mallocs-mstats.Frees)
 }
 
 func TestReadMetricsConsistency(t *testing.T) {
@@ -123,6 +137,20 @@ func TestReadMetricsConsistency(t *testing.T) {
 	runtime.GC()
 	runtime.GC()
 
+	// Switch between goroutines many times, so that some of
+	// the scheduling latencies are sampled.
+	c := make(chan int)
+	go func() {
+		for v := range c {
+			c <- v
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		c <- i
+		<-c
+	}
+	close(c)
+
 	// Read all the supported metrics through the metrics package.
 	descs, samples := prepareAllMetricsSamples()
 	metrics.Read(samples)
@@ -138,7 +166,9 @@ func TestReadMetricsConsistency(t *testing.T) {
 	var gc struct {
 		numGC  uint64
 		pauses uint64
+		cpu    float64
 	}
+	var schedLatencies uint64
 	for i := range samples {
 		kind := samples[i].Value.Kind()
 		if want := descs[samples[i].Name].Kind; kind != want {
@@ -173,10 +203,26 @@ func TestReadMetricsConsistency(t *testing.T) {
 			for i := range h.Counts {
 				gc.pauses += h.Counts[i]
 			}
+		case "/gc/cpu/assist:cpu-seconds", "/gc/cpu/background:cpu-seconds",
+			"/gc/cpu/idle:cpu-seconds", "/gc/cpu/pause:cpu-seconds":
+			gc.cpu += samples[i].Value.Float64()
 		case "/sched/goroutines:goroutines":
 			if samples[i].Value.Uint64() < 1 {
 				t.Error("number of goroutines is less than one")
 			}
+		case "/sched/latencies:seconds":
+			h := samples[i].Value.Float64Histogram()
+			for i := range h.Counts {
+				schedLatencies += h.Counts[i]
+			}
+		case "/sched/stacks:stacks":
+			if samples[i].Value.Uint64() < 1 {
+				t.Error("number of stacks is less than one")
+			}
+		case "/sched/threads:threads":
+			if samples[i].Value.Uint64() < 1 {
+				t.Error("number of threads is less than one")
+			}
 		}
 	}
 	if totalVirtual.got != totalVirtual.want {
@@ -219,6 +265,12 @@ func TestReadMetricsConsistency(t *testing.T) {
 	if gc.pauses < gc.numGC*2 {
 		t.Errorf("fewer pauses than expected: got %d, want at least %d", gc.pauses, gc.numGC*2)
 	}
+	if gc.cpu <= 0 {
+		t.Errorf("GC CPU time is %v after %d GC cycles", gc.cpu, gc.numGC)
+	}
+	if schedLatencies == 0 {
+		t.Error("no scheduling latencies were sampled")
+	}
 }
 
 func BenchmarkReadMetricsLatency(b *testing.B) {
@@ -256,3 +308,100 @@ func BenchmarkReadMetricsLatency(b *testing.B) {
 	b.ReportMetric(float64(latencies[len(latencies)*90/100]), "p90-ns")
 	b.ReportMetric(float64(latencies[len(latencies)*99/100]), "p99-ns")
 }
+
+func TestRuntimeLockContention(t *testing.T) {
+	if runtime.GOMAXPROCS(0) < 2 {
+		t.Skip("skipping with GOMAXPROCS=1")
+	}
+	defer runtime.SetMutexProfileFraction(runtime.SetMutexProfileFraction(1))
+	runtime.SetBlockProfileRate(1)
+	defer runtime.SetBlockProfileRate(0)
+
+	samples := []metrics.Sample{
+		{Name: "/sync/mutex/runtime/wait:seconds"},
+		{Name: "/sync/mutex/wait/total:seconds"},
+	}
+	metrics.Read(samples)
+	before := histogramCount(samples[0].Value.Float64Histogram())
+	total := samples[1].Value.Float64()
+
+	// Hold a runtime lock long enough for another
+	// goroutine to go to sleep waiting for it.
+	const n = 10
+	var mu runtime.Mutex
+	for i := 0; i < n; i++ {
+		done := make(chan bool)
+		mu.Lock()
+		go func() {
+			mu.Lock()
+			mu.Unlock()
+			done <- true
+		}()
+		runtime.Usleep(10000)
+		mu.Unlock()
+		<-done
+	}
+
+	metrics.Read(samples)
+	if got := histogramCount(samples[0].Value.Float64Histogram()) - before; got < n {
+		t.Errorf("runtime lock wait histogram has %d new waits, want at least %d", got, n)
+	}
+	if got := samples[1].Value.Float64() - total; got < n*0.005 {
+		t.Errorf("total mutex wait time grew by %fs, want at least %fs", got, n*0.005)
+	}
+
+	// The holder is in the mutex profile and the waiter in the block profile.
+	var records []runtime.BlockProfileRecord
+	for {
+		n, _ := runtime.MutexProfile(records)
+		if n <= len(records) {
+			records = records[:n]
+			break
+		}
+		records = make([]runtime.BlockProfileRecord, n+10)
+	}
+	if !profileHasStack(records, "runtime.unlock", "runtime_test.TestRuntimeLockContention") {
+		t.Errorf("mutex profile has no sample for the lock holder")
+	}
+	records = nil
+	for {
+		n, _ := runtime.BlockProfile(records)
+		if n <= len(records) {
+			records = records[:n]
+			break
+		}
+		records = make([]runtime.BlockProfileRecord, n+10)
+	}
+	if !profileHasStack(records, "runtime.lock", "runtime_test.TestRuntimeLockContention.func1") {
+		t.Errorf("block profile has no sample for the lock waiter")
+	}
+}
+
+func histogramCount(h *metrics.Float64Histogram) (n uint64) {
+	for _, c := range h.Counts {
+		n += c
+	}
+	return n
+}
+
+// profileHasStack reports whether one of the records has a stack
+// with functions that have the prefixes want, in order.
+func profileHasStack(records []runtime.BlockProfileRecord, want ...string) bool {
+	for _, r := range records {
+		frames := runtime.CallersFrames(r.Stack())
+		i := 0
+		for i < len(want) {
+			f, more := frames.Next()
+			if strings.HasPrefix(f.Function, want[i]) {
+				i++
+			}
+			if !more {
+				break
+			}
+		}
+		if i == len(want) {
+			return true
+		}
+	}
+	return false
+}
@@ -1006,6 +1006,16 @@ const gcAssistTimeSlack = 5000
 // assist by pre-paying for this many bytes of future allocations.
 const gcOverAssistWork = 64 << 10
 
+// gcCPUStats is the cumulative CPU time spent in the GC, in nanoseconds,
+// by kind of work, as of the end of the last cycle. The fields are
+// updated atomically, as they are read by runtime/metrics.
+var gcCPUStats struct {
+	assist     uint64 // in mutator assists
+	background uint64 // in dedicated and fractional mark workers
+	idle       uint64 // in idle mark workers
+	pause      uint64 // during stop-the-world pauses, times GOMAXPROCS
+}
+
 var work struct {
 	full  lfstack          // lock-free list of full blocks workbuf
 	empty lfstack          // lock-free list of empty blocks workbuf
@@ -1109,6 +1119,16 @@ var work struct {
 	// incremented at mark termination.
 	cycles uint32
 
+	// leakRequest is set by detectGoroutineLeaks to make the next
+	// cycle detect leaked goroutines. leakDetect is set during that
+	// cycle until findGoroutineLeaks is done, and leakCycle is the
+	// number of the last cycle that detected leaks. leakCount is the
+	// number of goroutines that cycle found leaked.
+	leakRequest uint32
+	leakDetect  bool
+	leakCycle   uint32
+	leakCount   uint32
+
 	// Timing/utilization stats for this cycle.
 	stwprocs, maxprocs                 int32
 	tSweepTerm, tMark, tMarkTerm, tEnd int64 // nanotime() of phase start
@@ -1196,6 +1216,24 @@ func GC() {
 	releasem(mp)
 }
 
+// detectGoroutineLeaks runs a garbage collection that detects leaked
+// goroutines, those blocked on channels, semaphores or sync.Cond values
+// that no goroutine that may run can reach, and sets g.leaked on them.
+// See findGoroutineLeaks.
+func detectGoroutineLeaks() {
+	for {
+		n := atomic.Load(&work.cycles)
+		gcWaitOnMark(n)
+		atomic.Store(&work.leakRequest, 1)
+		gcStart(gcTrigger{kind: gcTriggerCycle, n: n + 1})
+		gcWaitOnMark(n + 1)
+		if atomic.Load(&work.leakCycle) == n+1 {
+			return
+		}
+		// Another goroutine started cycle n+1 first. Try again.
+	}
+}
+
 // gcWaitOnMark blocks until GC finishes the Nth mark phase. If GC has
 // already completed this mark phase, it returns immediately.
 func gcWaitOnMark(n uint32) {
@@ -1339,6 +1377,14 @@ func gcStart(trigger gcTrigger) {
 		mode = gcForceBlockMode
 	}
 
+	// A cycle that detects leaked goroutines does not let user
+	// goroutines run, so none can wake a blocked goroutine it has
+	// not yet found reachable.
+	leakDetect := atomic.Cas(&work.leakRequest, 1, 0)
+	if leakDetect && mode == gcBackgroundMode {
+		mode = gcForceMode
+	}
+
 	// Ok, we're doing it! Stop everybody else
 	semacquire(&gcsema)
 	semacquire(&worldsema)
@@ -1368,6 +1414,7 @@ func gcStart(trigger gcTrigger) {
 	work.heap0 = atomic.Load64(&memstats.heap_live)
 	work.pauseNS = 0
 	work.mode = mode
+	work.leakDetect = leakDetect
 
 	now := nanotime()
 	work.tSweepTerm = now
@@ -1585,6 +1632,10 @@ top:
 				break
 			}
 		}
+		if !restart && work.leakDetect {
+			// Leak detection may have more stacks to scan.
+			restart = findGoroutineLeaks()
+		}
 	})
 	if restart {
 		getg().m.preemptoff = ""
@@ -1719,6 +1770,10 @@ func gcMarkTermination(nextTriggerRatio float64) {
 	markTermCpu := int64(work.stwprocs) * (work.tEnd - work.tMarkTerm)
 	cycleCpu := sweepTermCpu + markCpu + markTermCpu
 	work.totaltime += cycleCpu
+	atomic.Xadd64(&gcCPUStats.assist, gcController.assistTime)
+	atomic.Xadd64(&gcCPUStats.background, gcController.dedicatedMarkTime+gcController.fractionalMarkTime)
+	atomic.Xadd64(&gcCPUStats.idle, gcController.idleMarkTime)
+	atomic.Xadd64(&gcCPUStats.pause, sweepTermCpu+markTermCpu)
 
 	// Compute overall GC CPU utilization.
 	totalCpu := sched.totaltime + (now-sched.procresizetime)*int64(gomaxprocs)
@@ -103,6 +103,9 @@ func gcMarkRootPrepare() {
 	// there's nothing to scan, and any roots they create during
 	// the concurrent phase will be caught by the write barrier.
 	work.nStackRoots = int(atomic.Loaduintptr(&allglen))
+	if work.leakDetect {
+		prepareGoroutineLeaks()
+	}
 
 	work.markrootNext = 0
 	work.markrootJobs = uint32(fixedRootCount + work.nFlushCacheRoots + work.nDataRoots + work.nBSSRoots + work.nSpanRoots + work.nStackRoots)
@@ -201,6 +204,12 @@ func markroot(gcw *gcWork, i uint32) {
 			gp.waitsince = work.tstart
 		}
 
+		if gp.leakCandidate {
+			// Goroutine leak detection scans gp's stack
+			// once it finds that gp may run again.
+			return
+		}
+
 		// scanstack must be done on the system stack in case
 		// we're trying to scan our own stack.
 		systemstack(func() {
@@ -241,6 +250,165 @@ func markroot(gcw *gcWork, i uint32) {
 	}
 }
 
+// Goroutine leak detection.
+//
+// A goroutine blocked on a channel, a semaphore (such as that of a
+// sync.Mutex or sync.WaitGroup) or a sync.Cond can only be woken by a
+// goroutine that can reach the object it is blocked on. In a cycle that
+// detects leaks, user goroutines are not scheduled, and the stacks of the
+// blocked goroutines, the candidates, are not scanned at first. The sudogs
+// of the candidates hide their pointers to the objects they are blocked on
+// (see maybeTraceablePtr), so those objects are only marked if they are
+// reachable from a goroutine that may run. At the end of the mark phase,
+// findGoroutineLeaks scans the stacks of the candidates whose objects were
+// marked, which may mark more objects, and repeats until no more are found.
+// The remaining candidates can never be woken, and have leaked.
+
+// prepareGoroutineLeaks picks the candidates of a leak detection cycle
+// and hides the objects they are blocked on. The world must be stopped.
+func prepareGoroutineLeaks() {
+	for _, gp := range allgs[:work.nStackRoots] {
+		gp.leaked = false
+		gp.leakCandidate = isLeakCandidate(gp)
+		if gp.leakCandidate {
+			for sg := gp.waiting; sg != nil; sg = sg.waitlink {
+				sg.c.setUntraceable()
+				sg.elem.setUntraceable()
+			}
+		}
+	}
+	forEachSemaSudog(func(s *sudog) {
+		if s.g.leakCandidate {
+			s.elem.setUntraceable()
+		}
+	})
+}
+
+// isLeakCandidate reports whether gp is a user goroutine blocked on a
+// channel operation, a semaphore or a sync.Cond. A select with no cases
+// blocks forever on purpose, and does not count.
+func isLeakCandidate(gp *g) bool {
+	if readgstatus(gp) != _Gwaiting || isSystemGoroutine(gp, false) {
+		return false
+	}
+	switch gp.waitreason {
+	case waitReasonChanReceive, waitReasonChanSend,
+		waitReasonChanReceiveNilChan, waitReasonChanSendNilChan,
+		waitReasonSelect, waitReasonSemacquire, waitReasonSyncCondWait:
+		return true
+	}
+	return false
+}
+
+// leakBlocked reports whether the candidate gp is still blocked and
+// none of the objects it is blocked on have been marked.
+func leakBlocked(gp *g) bool {
+	if readgstatus(gp) != _Gwaiting {
+		// Woken by the runtime, such as by a timer.
+		return false
+	}
+	switch gp.waitreason {
+	case waitReasonSemacquire, waitReasonSyncCondWait:
+		// The tiny allocator combines small objects without pointers,
+		// such as a sync.Mutex or a sync.WaitGroup allocated on its
+		// own, into blocks that are marked as a whole, so that one
+		// may be unreachable while its block is marked for another.
+		// Rather than report such leaks only when no neighbor in the
+		// block happens to be live, never report them.
+		return !isMarkedOrNotInHeap(gp.waitobj) && !inTinyBlock(gp.waitobj)
+	}
+	for sg := gp.waiting; sg != nil; sg = sg.waitlink {
+		if isMarkedOrNotInHeap(uintptr(unsafe.Pointer(sg.c.get()))) {
+			return false
+		}
+	}
+	return true
+}
+
+// isMarkedOrNotInHeap reports whether p points into a marked heap
+// object or outside the heap, such as to a global variable.
+func isMarkedOrNotInHeap(p uintptr) bool {
+	s := spanOfHeap(p)
+	if s == nil {
+		return true
+	}
+	return s.markBitsForIndex(s.objIndex(p)).isMarked()
+}
+
+// inTinyBlock reports whether p points into a heap block that the tiny
+// allocator may have combined several objects into.
+func inTinyBlock(p uintptr) bool {
+	s := spanOfHeap(p)
+	return s != nil && s.spanclass == tinySpanClass
+}
+
+// findGoroutineLeaks is called at the end of the mark phase of a leak
+// detection cycle, with the world stopped. It scans the stacks of the
+// candidates that may still run and, once there are none, those of the
+// leaked goroutines, setting g.leaked on them and ending leak detection.
+// It reports whether this left marking work to do.
+func findGoroutineLeaks() bool {
+	// Like a self-scan in markroot, put the user G in _Gwaiting
+	// so that suspendG can be used.
+	userG := getg().m.curg
+	if userG != nil && readgstatus(userG) == _Grunning {
+		casgstatus(userG, _Grunning, _Gwaiting)
+		userG.waitreason = waitReasonGarbageCollectionScan
+		defer casgstatus(userG, _Gwaiting, _Grunning)
+	}
+
+	pp := getg().m.p.ptr()
+	gcw := &pp.gcw
+	for work.leakDetect {
+		found := false
+		for _, gp := range allgs[:work.nStackRoots] {
+			if gp.leakCandidate && !leakBlocked(gp) {
+				scanLeakCandidate(gp, gcw)
+				found = true
+			}
+		}
+		if !found {
+			n := uint32(0)
+			for _, gp := range allgs[:work.nStackRoots] {
+				if gp.leakCandidate {
+					gp.leaked = true
+					scanLeakCandidate(gp, gcw)
+					n++
+				}
+			}
+			atomic.Store(&work.leakCount, n)
+			forEachSemaSudog(func(s *sudog) {
+				s.elem.setTraceable()
+			})
+			work.leakDetect = false
+			atomic.Store(&work.leakCycle, work.cycles)
+		}
+		wbBufFlush1(pp)
+		if !gcw.empty() {
+			return true
+		}
+	}
+	return false
+}
+
+// scanLeakCandidate makes the objects the candidate gp is blocked on
+// visible to the garbage collector again and scans gp's stack.
+func scanLeakCandidate(gp *g, gcw *gcWork) {
+	gp.leakCandidate = false
+	for sg := gp.waiting; sg != nil; sg = sg.waitlink {
+		sg.c.setTraceable()
+		sg.elem.setTraceable()
+	}
+	stopped := suspendG(gp)
+	if stopped.dead {
+		gp.gcscandone = true
+		return
+	}
+	scanstack(gp, gcw)
+	gp.gcscandone = true
+	resumeG(stopped)
+}
+
 // markrootBlock scans the shard'th shard of the block of memory [b0,
 // b0+n0), with the given pointer mask.
 //
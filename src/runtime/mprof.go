@@ -433,6 +433,10 @@ var mutexprofilerate uint64 // fraction sampled
 // that are reported in the mutex profile. On average 1/rate events are
 // reported. The previous rate is returned.
 //
+// The mutex profile includes contention on sync.Mutex and sync.RWMutex,
+// and on locks internal to the runtime, attributed to the stack that
+// releases the lock.
+//
 // To turn off profiling entirely, pass rate 0.
 // To just read the current rate, pass rate < 0.
 // (For n>1 the details of sampling may change.)
@@ -458,6 +462,153 @@ func mutexevent(cycles int64, skip int) {
 	}
 }
 
+var (
+	// mutexWaitDist and lockWaitDist are the distributions of the
+	// times spent waiting for contended sync.Mutexes and RWMutexes,
+	// and for contended runtime-internal locks. They are reported
+	// by runtime/metrics. They are global variables, so they are
+	// 8-byte aligned for atomic access even on 32-bit systems.
+	mutexWaitDist timeHistogram
+	lockWaitDist  timeHistogram
+
+	// mutexWaitTotal is the total time in nanoseconds spent waiting
+	// for both kinds of locks. Accessed atomically.
+	mutexWaitTotal uint64
+)
+
+// recordMutexWait records that a goroutine waited nanos nanoseconds
+// for a contended sync.Mutex or sync.RWMutex.
+func recordMutexWait(nanos int64) {
+	mutexWaitDist.record(nanos)
+	if nanos > 0 {
+		atomic.Xadd64(&mutexWaitTotal, nanos)
+	}
+}
+
+// A lockTimer measures the wait of an M for a contended runtime lock,
+// from the time lock2 fails to take it right away.
+//
+// Unlike sync.Mutex, a runtime lock does not know who holds it, so the
+// holder's side of the contention is recorded by the unlocker:
+// waiters store the lock and the time they start sleeping in their M,
+// and an unlock2 that wakes a waiter attributes the longest time since
+// then to its own stack in the mutex profile. The waiter's side is recorded
+// in the block profile with the stack of the lock call.
+type lockTimer struct {
+	lock  *mutex
+	nanos int64
+	ticks int64 // 0 if neither profile is enabled
+}
+
+//go:nosplit
+func (lt *lockTimer) begin() {
+	lt.nanos = nanotime()
+	if atomic.Load64(&mutexprofilerate) > 0 || atomic.Load64(&blockprofilerate) > 0 {
+		lt.ticks = cputicks()
+	}
+}
+
+// wait is called by a waiter for lt.lock before it goes to sleep.
+//
+//go:nosplit
+func (lt *lockTimer) wait() {
+	if lt.ticks != 0 && atomic.Load64(&mutexprofilerate) > 0 {
+		mp := getg().m
+		// The truncated ticks are good for waits of up
+		// to a few seconds on 32-bit systems.
+		atomic.Storeuintptr(&mp.lockWaitStart, uintptr(cputicks()))
+		atomic.Storeuintptr(&mp.lockWait, uintptr(unsafe.Pointer(lt.lock)))
+	}
+}
+
+// end is called when the M has taken lt.lock.
+func (lt *lockTimer) end() {
+	if mp := getg().m; mp.lockWait != 0 {
+		atomic.Storeuintptr(&mp.lockWait, 0)
+	}
+	nanos := nanotime() - lt.nanos
+	lockWaitDist.record(nanos)
+	if nanos > 0 {
+		atomic.Xadd64(&mutexWaitTotal, nanos)
+	}
+	if lt.ticks != 0 {
+		cycles := cputicks() - lt.ticks
+		if cycles <= 0 {
+			cycles = 1
+		}
+		if blocksampled(cycles) {
+			getg().m.mLockProfile.add(cycles, blockProfile)
+		}
+	}
+}
+
+// mLockProfile holds a sampled contention event on a runtime lock
+// until the M has released all its locks and can take proflock.
+//
+// Only one event is pending at a time. Since events are sampled, the
+// rare ones that happen while another is pending are dropped.
+type mLockProfile struct {
+	cycles  int64 // 0 if no event is pending
+	which   bucketType
+	nstk    int
+	stack   [maxStack]uintptr
+	storing bool // store is running, so new events are dropped
+}
+
+// recordUnlock is called by unlock2 when it wakes a waiter for l,
+// to attribute the waiter's delay to the unlocker.
+//
+//go:nosplit
+func (prof *mLockProfile) recordUnlock(l *mutex) {
+	rate := int64(atomic.Load64(&mutexprofilerate))
+	if rate <= 0 || int64(fastrand())%rate != 0 {
+		return
+	}
+	// Find the M that has been sleeping on l the longest.
+	now := uintptr(cputicks())
+	var cycles int64
+	for mp := (*m)(atomic.Loadp(unsafe.Pointer(&allm))); mp != nil; mp = mp.alllink {
+		if atomic.Loaduintptr(&mp.lockWait) != uintptr(unsafe.Pointer(l)) {
+			continue
+		}
+		if c := int64(now - atomic.Loaduintptr(&mp.lockWaitStart)); c > cycles {
+			cycles = c
+		}
+	}
+	if cycles > 0 {
+		prof.add(cycles, mutexProfile)
+	}
+}
+
+// add makes an event of the given cycles pending for profile which,
+// with the stack of the lock2 or unlock2 call that recorded it.
+func (prof *mLockProfile) add(cycles int64, which bucketType) {
+	if prof.cycles != 0 || prof.storing {
+		return
+	}
+	prof.cycles = cycles
+	prof.which = which
+	prof.nstk = callers(2, prof.stack[:])
+}
+
+// store adds the pending event to its profile. It is called by unlock2
+// once the M holds no more locks. It needs a P for write barriers.
+//
+//go:yeswritebarrierrec
+func (prof *mLockProfile) store() {
+	if getg().m.p == 0 || prof.storing {
+		return
+	}
+	prof.storing = true
+	lock(&proflock)
+	b := stkbucket(prof.which, 0, prof.stack[:prof.nstk], true)
+	b.bp().count++
+	b.bp().cycles += prof.cycles
+	unlock(&proflock)
+	prof.cycles = 0
+	prof.storing = false
+}
+
 // Go interface to profile data.
 
 // A StackRecord describes a single execution stack.
@@ -778,6 +929,64 @@ func goroutineProfileWithLabels(p []StackRecord, labels []unsafe.Pointer) (n int
 	return n, ok
 }
 
+//go:linkname runtime_detectGoroutineLeaks runtime/pprof.runtime_detectGoroutineLeaks
+func runtime_detectGoroutineLeaks() {
+	detectGoroutineLeaks()
+}
+
+//go:linkname runtime_goroutineLeakCount runtime/pprof.runtime_goroutineLeakCount
+func runtime_goroutineLeakCount() int {
+	return int(atomic.Load(&work.leakCount))
+}
+
+//go:linkname runtime_goroutineLeakProfileWithLabels runtime/pprof.runtime_goroutineLeakProfileWithLabels
+func runtime_goroutineLeakProfileWithLabels(p []StackRecord, labels []unsafe.Pointer) (n int, ok bool) {
+	return goroutineLeakProfileWithLabels(p, labels)
+}
+
+// goroutineLeakProfileWithLabels is like goroutineProfileWithLabels, but
+// it reports the goroutines found leaked by the last call to
+// detectGoroutineLeaks. Each stack ends with the go statement that
+// created the goroutine.
+func goroutineLeakProfileWithLabels(p []StackRecord, labels []unsafe.Pointer) (n int, ok bool) {
+	if labels != nil && len(labels) != len(p) {
+		labels = nil
+	}
+
+	stopTheWorld("profile")
+
+	for _, gp1 := range allgs {
+		if gp1.leaked {
+			n++
+		}
+	}
+
+	if n <= len(p) {
+		ok = true
+		i := 0
+		for _, gp1 := range allgs {
+			if !gp1.leaked {
+				continue
+			}
+			r := &p[i]
+			saveg(^uintptr(0), ^uintptr(0), gp1, r)
+			if k := len(r.Stack()); k < len(r.Stack0) && gp1.gopc != 0 {
+				r.Stack0[k] = gp1.gopc
+				if k+1 < len(r.Stack0) {
+					r.Stack0[k+1] = 0
+				}
+			}
+			if labels != nil {
+				labels[i] = gp1.labels
+			}
+			i++
+		}
+	}
+
+	startTheWorld()
+	return n, ok
+}
+
 // GoroutineProfile returns n, the number of records in the active goroutine stack profile.
 // If len(p) >= n, GoroutineProfile copies the profile into p and returns n, true.
 // If len(p) < n, GoroutineProfile does not change p and returns n, false.
@@ -99,12 +99,13 @@ import (
 //
 // Each Profile has a unique name. A few profiles are predefined:
 //
-//	goroutine    - stack traces of all current goroutines
-//	heap         - a sampling of memory allocations of live objects
-//	allocs       - a sampling of all past memory allocations
-//	threadcreate - stack traces that led to the creation of new OS threads
-//	block        - stack traces that led to blocking on synchronization primitives
-//	mutex        - stack traces of holders of contended mutexes
+//	goroutine     - stack traces of all current goroutines
+//	goroutineleak - stack traces of goroutines blocked forever
+//	heap          - a sampling of memory allocations of live objects
+//	allocs        - a sampling of all past memory allocations
+//	threadcreate  - stack traces that led to the creation of new OS threads
+//	block         - stack traces that led to blocking on synchronization primitives
+//	mutex         - stack traces of holders of contended mutexes
 //
 // These predefined profiles maintain themselves and panic on an explicit
 // Add or Remove method call.
@@ -126,6 +127,29 @@ import (
 // pprof display to -alloc_space, the total number of bytes allocated since
 // the program began (including garbage-collected bytes).
 //
+// The goroutineleak profile reports goroutines blocked on channel
+// operations, sync.Mutex, sync.RWMutex, sync.WaitGroup or sync.Cond values
+// that no other goroutine that can run is able to reach, and that can
+// therefore never be woken. Writing the profile runs a garbage collection
+// that finds them, during which no other goroutine runs. Its Count method
+// reports the number found when the profile was last written.
+// The stack of each goroutine ends with the go statement that created it.
+// Goroutines blocked in a select statement with no cases are not reported.
+// Neither are goroutines blocked on a sync.Mutex or sync.WaitGroup that was
+// allocated on its own, rather than as part of a larger value: the runtime
+// combines such small values without pointers into shared memory blocks,
+// and cannot tell whether one of them is unreachable.
+//
+// The mutex and block profiles show the two sides of lock contention.
+// The mutex profile attributes the time goroutines wait for a sync.Mutex
+// or sync.RWMutex to the goroutine that unlocks it, and the block profile
+// attributes it to the goroutine that waits. Both also include contention
+// on the locks internal to the runtime, such as those of the scheduler:
+// the mutex profile with the stack of the thread that releases a lock
+// another thread sleeps on, the block profile with the stack of the
+// thread that waits. The runtime/metrics package reports histograms of
+// the wait times.
+//
 // The CPU profile is not available as a Profile. It has a special API,
 // the StartCPUProfile and StopCPUProfile functions, because it streams
 // output to a writer during profiling.
@@ -150,6 +174,12 @@ var goroutineProfile = &Profile{
 	write: writeGoroutine,
 }
 
+var goroutineLeakProfile = &Profile{
+	name:  "goroutineleak",
+	count: countGoroutineLeak,
+	write: writeGoroutineLeak,
+}
+
 var threadcreateProfile = &Profile{
 	name:  "threadcreate",
 	count: countThreadCreate,
@@ -185,12 +215,13 @@ func lockProfiles() {
 	if profiles.m == nil {
 		// Initial built-in profiles.
 		profiles.m = map[string]*Profile{
-			"goroutine":    goroutineProfile,
-			"threadcreate": threadcreateProfile,
-			"heap":         heapProfile,
-			"allocs":       allocsProfile,
-			"block":        blockProfile,
-			"mutex":        mutexProfile,
+			"goroutine":     goroutineProfile,
+			"goroutineleak": goroutineLeakProfile,
+			"threadcreate":  threadcreateProfile,
+			"heap":          heapProfile,
+			"allocs":        allocsProfile,
+			"block":         blockProfile,
+			"mutex":         mutexProfile,
 		}
 	}
 }
@@ -684,6 +715,28 @@ func writeGoroutine(w io.Writer, debug int) error {
 	return writeRuntimeProfile(w, debug, "goroutine", runtime_goroutineProfileWithLabels)
 }
 
+// runtime_detectGoroutineLeaks is defined in runtime/mprof.go
+func runtime_detectGoroutineLeaks()
+
+// runtime_goroutineLeakCount is defined in runtime/mprof.go
+func runtime_goroutineLeakCount() int
+
+// runtime_goroutineLeakProfileWithLabels is defined in runtime/mprof.go
+func runtime_goroutineLeakProfileWithLabels(p []runtime.StackRecord, labels []unsafe.Pointer) (n int, ok bool)
+
+// countGoroutineLeak returns the number of goroutines found leaked
+// when the profile was last written. It does not look for leaks
+// itself, which would stop the world.
+func countGoroutineLeak() int {
+	return runtime_goroutineLeakCount()
+}
+
+// writeGoroutineLeak finds the leaked goroutines and writes their stacks to w.
+func writeGoroutineLeak(w io.Writer, debug int) error {
+	runtime_detectGoroutineLeaks()
+	return writeRuntimeProfile(w, debug, "goroutineleak", runtime_goroutineLeakProfileWithLabels)
+}
+
 func writeGoroutineStacks(w io.Writer) error {
 	// We don't know how big the buffer needs to be to collect
 	// all the goroutines. Start with 1 MB and try a few times, doubling each time.
@@ -1045,6 +1045,167 @@ func TestGoroutineCounts(t *testing.T) {
 	time.Sleep(10 * time.Millisecond) // let goroutines exit
 }
 
+func leakChanRecv() {
+	c := make(chan int)
+	<-c
+}
+
+func leakChanSendSelect() {
+	c1, c2 := make(chan int), make(chan int)
+	select {
+	case c1 <- 1:
+	case <-c2:
+	}
+}
+
+func leakNilChan() {
+	var c chan int
+	c <- 1
+}
+
+func leakMutex() {
+	// A Mutex that is part of a larger value is not allocated
+	// by the tiny allocator.
+	m := new(struct {
+		sync.Mutex
+		_ [64]byte
+	})
+	m.Lock()
+	m.Lock()
+}
+
+func leakRWMutex() {
+	m := new(sync.RWMutex)
+	m.Lock()
+	m.Lock()
+}
+
+func leakWaitGroup() {
+	wg := new(struct {
+		sync.WaitGroup
+		_ [64]byte
+	})
+	wg.Add(1)
+	wg.Wait()
+}
+
+// leakTinyMutex leaks a goroutine blocked on a Mutex allocated on its own,
+// which the tiny allocator may combine with live objects. Such leaks are
+// never reported.
+func leakTinyMutex() {
+	m := new(sync.Mutex)
+	m.Lock()
+	m.Lock()
+}
+
+// leakTinyWaitGroup is like leakTinyMutex, for a WaitGroup.
+func leakTinyWaitGroup() {
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	wg.Wait()
+}
+
+func leakCond() {
+	c := sync.NewCond(new(sync.Mutex))
+	c.L.Lock()
+	c.Wait()
+}
+
+func blockedOnLiveChan(c chan int) {
+	<-c
+}
+
+func TestGoroutineLeakProfile(t *testing.T) {
+	if os.Getenv("GO_PPROF_LEAK_CHILD") != "1" {
+		// The leaked goroutines never exit, so leak them in a child
+		// process, which also checks that -test.goroutineleak fails.
+		testenv.MustHaveExec(t)
+		cmd := exec.Command(os.Args[0], "-test.run=^TestGoroutineLeakProfile$", "-test.v", "-test.goroutineleak")
+		cmd.Env = append(os.Environ(), "GO_PPROF_LEAK_CHILD=1")
+		out, err := cmd.CombinedOutput()
+		if !bytes.Contains(out, []byte("--- PASS: TestGoroutineLeakProfile")) {
+			t.Fatalf("child process failed: %v\n%s", err, out)
+		}
+		if err == nil || !bytes.Contains(out, []byte(" leaked goroutines\n")) {
+			t.Fatalf("-test.goroutineleak did not report the leaked goroutines: %v\n%s", err, out)
+		}
+		return
+	}
+
+	c := make(chan int)
+	defer close(c)
+	go blockedOnLiveChan(c)
+	Do(context.Background(), Labels("leak", "yes"), func(context.Context) {
+		go leakChanRecv()
+		go leakChanSendSelect()
+		go leakNilChan()
+		go leakMutex()
+		go leakRWMutex()
+		go leakWaitGroup()
+		go leakCond()
+		go leakTinyMutex()
+		go leakTinyWaitGroup()
+	})
+
+	leaks := []string{
+		"runtime/pprof.leakChanRecv+",
+		"runtime/pprof.leakChanSendSelect+",
+		"runtime/pprof.leakNilChan+",
+		"runtime/pprof.leakMutex+",
+		"runtime/pprof.leakRWMutex+",
+		"runtime/pprof.leakWaitGroup+",
+		"runtime/pprof.leakCond+",
+	}
+	var prof string
+	for i := 0; ; i++ {
+		// Give the goroutines time to block.
+		time.Sleep(10 * time.Millisecond)
+		var w bytes.Buffer
+		if err := Lookup("goroutineleak").WriteTo(&w, 1); err != nil {
+			t.Fatal(err)
+		}
+		prof = w.String()
+		if containsAll(prof, leaks) || i == 100 {
+			break
+		}
+	}
+	for _, leak := range leaks {
+		if !strings.Contains(prof, leak) {
+			t.Errorf("goroutineleak profile does not contain %s", leak)
+		}
+	}
+	if strings.Contains(prof, "blockedOnLiveChan") {
+		t.Errorf("goroutineleak profile contains a goroutine blocked on a reachable channel")
+	}
+	if strings.Contains(prof, "leakTinyMutex") || strings.Contains(prof, "leakTinyWaitGroup") {
+		t.Errorf("goroutineleak profile contains a goroutine blocked on a value from the tiny allocator")
+	}
+	labels := labelMap{"leak": "yes"}
+	if !strings.Contains(prof, "\n# labels: "+labels.String()) {
+		t.Errorf("goroutineleak profile is missing labels")
+	}
+	// The stacks end with the go statements that created the goroutines.
+	if !strings.Contains(prof, "runtime/pprof.TestGoroutineLeakProfile.func1+") {
+		t.Errorf("goroutineleak profile is missing the creators of the goroutines")
+	}
+	if t.Failed() {
+		t.Logf("profile:\n%s", prof)
+	}
+
+	if n := Lookup("goroutineleak").Count(); n < len(leaks) {
+		t.Errorf("goroutineleak profile count = %d, want at least %d", n, len(leaks))
+	}
+}
+
+func containsAll(s string, all []string) bool {
+	for _, x := range all {
+		if !strings.Contains(s, x) {
+			return false
+		}
+	}
+	return true
+}
+
 func containsInOrder(s string, all ...string) bool {
 	for _, t := range all {
 		i := strings.Index(s, t)
@@ -283,6 +283,107 @@ func forcegchelper() {
 	}
 }
 
+// The sources of the GOMAXPROCS setting,
+// as reported by the /sched/gomaxprocs/source:enum metric.
+const (
+	gomaxprocsSourceCPUs   = iota // the number of CPUs
+	gomaxprocsSourceCgroup        // the CPU bandwidth limit of the cgroup
+	gomaxprocsSourceEnv           // the GOMAXPROCS environment variable
+	gomaxprocsSourceCall          // a call to GOMAXPROCS
+)
+
+// maxprocsCheckPeriod is the minimum time in nanoseconds
+// between the checks of the default GOMAXPROCS by sysmon.
+const maxprocsCheckPeriod = 1e9
+
+// maxprocs is the state of the default GOMAXPROCS, which follows the
+// CPU bandwidth limit of the process's cgroup, unless the user sets
+// GOMAXPROCS.
+var maxprocs struct {
+	limit  uint64 // float64 bits of the last CPU limit read, in CPUs
+	source uint32 // source of the GOMAXPROCS setting
+
+	// The updater goroutine applies a new default GOMAXPROCS
+	// found by sysmon, which can't stop the world itself.
+	g         *g
+	idle      uint32
+	procs     int32  // new GOMAXPROCS for the updater
+	newSource uint32 // new source for the updater
+	lastCheck int64  // nanotime of the last check, owned by sysmon
+}
+
+// defaultGOMAXPROCS returns the default GOMAXPROCS, which is the number
+// of CPUs, capped by the CPU bandwidth limit of the cgroup, and its source.
+func defaultGOMAXPROCS() (int32, uint32) {
+	limit := cgroupCPULimit()
+	atomic.Store64(&maxprocs.limit, float64bits(limit))
+	if limit == 0 {
+		return ncpu, gomaxprocsSourceCPUs
+	}
+	// Round a fractional limit up, so that it can be used fully.
+	// Keep at least 2 Ps: the limit is only enforced on average
+	// over a period, and a single P would serialize the program
+	// even when it could use more CPU for a while.
+	procs := int32(limit)
+	if float64(procs) < limit {
+		procs++
+	}
+	if procs < 2 {
+		procs = 2
+	}
+	if procs >= ncpu {
+		return ncpu, gomaxprocsSourceCPUs
+	}
+	return procs, gomaxprocsSourceCgroup
+}
+
+// start the GOMAXPROCS updater goroutine, if the CPU limit can change
+// and the user didn't set GOMAXPROCS.
+func init() {
+	if cgroupCPUFound() && debug.updatemaxprocs != 0 && maxprocs.source < gomaxprocsSourceEnv {
+		go maxprocsUpdater()
+	}
+}
+
+func maxprocsUpdater() {
+	maxprocs.g = getg()
+	for {
+		gopark(maxprocsUpdaterPark, nil, waitReasonMaxProcsUpdaterIdle, traceEvGoBlock, 1)
+		// this goroutine is explicitly resumed by sysmon
+		stopTheWorldGC("GOMAXPROCS updater")
+		// The user may have set GOMAXPROCS since sysmon looked.
+		if atomic.Load(&maxprocs.source) < gomaxprocsSourceEnv {
+			// newprocs will be processed by startTheWorld
+			newprocs = maxprocs.procs
+			atomic.Store(&maxprocs.source, maxprocs.newSource)
+		}
+		startTheWorldGC()
+	}
+}
+
+// maxprocsUpdaterPark marks the updater idle once it's parked,
+// so that sysmon can't ready it before that.
+func maxprocsUpdaterPark(gp *g, _ unsafe.Pointer) bool {
+	atomic.Store(&maxprocs.idle, 1)
+	return true
+}
+
+// sysmonUpdateMaxProcs checks whether the default GOMAXPROCS changed,
+// and if so, has the updater goroutine apply it.
+func sysmonUpdateMaxProcs() {
+	procs, source := defaultGOMAXPROCS()
+	cur := atomic.Load(&maxprocs.source)
+	if cur >= gomaxprocsSourceEnv || procs == gomaxprocs && source == cur {
+		return
+	}
+	atomic.Store(&maxprocs.idle, 0)
+	maxprocs.procs = procs
+	maxprocs.newSource = source
+	var list gList
+	list.push(maxprocs.g)
+	injectglist(&list)
+}
+
 //go:nosplit
 
 // Gosched yields the processor, allowing other goroutines to run. It does not
@@ -379,7 +480,7 @@ func acquireSudog() *sudog {
 	s := pp.sudogcache[n-1]
 	pp.sudogcache[n-1] = nil
 	pp.sudogcache = pp.sudogcache[:n-1]
-	if s.elem != nil {
+	if s.elem.get() != nil {
 		throw("acquireSudog: found s.elem != nil in cache")
 	}
 	releasem(mp)
@@ -388,7 +489,7 @@ func acquireSudog() *sudog {
 
 //go:nosplit
 func releaseSudog(s *sudog) {
-	if s.elem != nil {
+	if s.elem.get() != nil {
 		throw("runtime: sudog with non-nil elem")
 	}
 	if s.isSelect {
@@ -403,7 +504,7 @@ func releaseSudog(s *sudog) {
 	if s.waitlink != nil {
 		throw("runtime: sudog with non-nil waitlink")
 	}
-	if s.c != nil {
+	if s.c.get() != nil {
 		throw("runtime: sudog with non-nil c")
 	}
 	gp := getg()
@@ -612,7 +713,8 @@ func schedinit() {
 	lockInit(&trace.stringsLock, lockRankTraceStrings)
 	lockInit(&trace.lock, lockRankTrace)
 	lockInit(&cpuprof.lock, lockRankCpuprof)
-	lockInit(&trace.stackTab.lock, lockRankTraceStackTab)
+	lockInit(&trace.stackTab[0].lock, lockRankTraceStackTab)
+	lockInit(&trace.stackTab[1].lock, lockRankTraceStackTab)
 	// Enforce that this lock is always a leaf lock.
 	// All of this lock's critical sections should be
 	// extremely short.
@@ -649,12 +751,15 @@ func schedinit() {
 	parsedebugvars()
 	gcinit()
 
+	cgroupInit()
+
 	lock(&sched.lock)
 	sched.lastpoll = uint64(nanotime())
-	procs := ncpu
+	procs, source := defaultGOMAXPROCS()
 	if n, ok := atoi32(gogetenv("GOMAXPROCS")); ok && n > 0 {
-		procs = n
+		procs, source = n, gomaxprocsSourceEnv
 	}
+	maxprocs.source = source
 	if procresize(procs) != nil {
 		throw("unknown runnable goroutine during bootstrap")
 	}
@@ -920,8 +1025,44 @@ func casgstatus(gp *g, oldval, newval uint32) {
 			nextYield = nanotime() + yieldDelay/2
 		}
 	}
+
+	// Track every gTrackingPeriod time a goroutine transitions out of running.
+	if oldval == _Grunning {
+		if gp.trackingSeq%gTrackingPeriod == 0 {
+			gp.tracking = true
+			gp.runnableStamp = 0
+		}
+		gp.trackingSeq++
+	}
+	if !gp.tracking {
+		return
+	}
+	switch newval {
+	case _Grunnable:
+		// A stack copy doesn't end the wait.
+		if oldval != _Gcopystack {
+			gp.runnableStamp = nanotime()
+		}
+	case _Grunning:
+		// The goroutine may not have been runnable,
+		// as when it returns from a system call.
+		gp.tracking = false
+		if gp.runnableStamp != 0 {
+			schedLatencyDist.record(nanotime() - gp.runnableStamp)
+		}
+	}
 }
 
+// gTrackingPeriod is the number of transitions out of _Grunning
+// between the times casgstatus tracks the scheduling latency of a
+// goroutine. Tracking every one of them would be too costly.
+const gTrackingPeriod = 8
+
+// schedLatencyDist is the distribution of the times goroutines spend
+// runnable before they run, as sampled by casgstatus. It is a global
+// variable, so it is 8-byte aligned for atomic access.
+var schedLatencyDist timeHistogram
+
 // casgstatus(gp, oldstatus, Gcopystack), assuming oldstatus is Gwaiting or Grunnable.
 // Returns old status. Cannot call casgstatus directly, because we are racing with an
 // async wakeup that might come in from netpoll. If we see Gwaiting from the readgstatus,
@@ -1421,8 +1562,12 @@ found:
 	}
 	unlock(&sched.lock)
 
-	// Release the P.
-	handoffp(releasep())
+	// Release the P. The trace buffer of m is no longer visible
+	// through allm, so queue it, including the ProcStop event
+	// emitted by releasep, before the world can stop.
+	pp := releasep()
+	traceMExit(m)
+	handoffp(pp)
 	// After this point we must not have write barriers.
 
 	// Invoke the deadlock detector. This must happen after
@@ -3437,6 +3582,7 @@ func goexit0(gp *g) {
 	gp.param = nil
 	gp.labels = nil
 	gp.timer = nil
+	gp.leaked = false
 
 	if gcBlackenEnabled != 0 && gp.gcAssistBytes > 0 {
 		// Flush assist credit to the global pool. This gives
@@ -4118,6 +4264,9 @@ func newproc1(fn *funcval, argp unsafe.Pointer, narg int32, callergp *g, callerp
 	if isSystemGoroutine(newg, false) {
 		atomic.Xadd(&sched.ngsys, +1)
 	}
+	newg.trackingSeq = uint8(fastrand())
+	newg.tracking = newg.trackingSeq%gTrackingPeriod == 0
+	newg.runnableStamp = 0
 	casgstatus(newg, _Gdead, _Grunnable)
 
 	if _p_.goidcache == _p_.goidcacheend {
@@ -4791,7 +4940,6 @@ func (pp *p) destroy() {
 	freemcache(pp.mcache)
 	pp.mcache = nil
 	gfpurge(pp)
-	traceProcFree(pp)
 	if raceenabled {
 		if pp.timerRaceCtx != 0 {
 			// The race detector code uses a callback to fetch
@@ -5291,6 +5439,11 @@ func sysmon() {
 			injectglist(&list)
 			unlock(&forcegc.lock)
 		}
+		// check if the default GOMAXPROCS changed
+		if now-maxprocs.lastCheck >= maxprocsCheckPeriod && atomic.Load(&maxprocs.idle) != 0 {
+			maxprocs.lastCheck = now
+			sysmonUpdateMaxProcs()
+		}
 		if debug.schedtrace > 0 && lasttrace+int64(debug.schedtrace)*1000000 <= now {
 			lasttrace = now
 			schedtrace(debug.scheddetail > 0)
@@ -302,6 +302,7 @@ type dbgVar struct {
 var debug struct {
 	cgocheck           int32
 	clobberfree        int32
+	containermaxprocs  int32
 	efence             int32
 	gccheckmark        int32
 	gcpacertrace       int32
@@ -315,6 +316,8 @@ var debug struct {
 	scheddetail        int32
 	schedtrace         int32
 	tracebackancestors int32
+	tracefpunwindoff   int32
+	updatemaxprocs     int32
 	asyncpreemptoff    int32
 
 	// debug.malloc is used as a combined debug check
@@ -330,6 +333,7 @@ var dbgvars = []dbgVar{
 	{"allocfreetrace", &debug.allocfreetrace},
 	{"clobberfree", &debug.clobberfree},
 	{"cgocheck", &debug.cgocheck},
+	{"containermaxprocs", &debug.containermaxprocs},
 	{"efence", &debug.efence},
 	{"gccheckmark", &debug.gccheckmark},
 	{"gcpacertrace", &debug.gcpacertrace},
@@ -344,6 +348,8 @@ var dbgvars = []dbgVar{
 	{"scheddetail", &debug.scheddetail},
 	{"schedtrace", &debug.schedtrace},
 	{"tracebackancestors", &debug.tracebackancestors},
+	{"tracefpunwindoff", &debug.tracefpunwindoff},
+	{"updatemaxprocs", &debug.updatemaxprocs},
 	{"asyncpreemptoff", &debug.asyncpreemptoff},
 	{"inittrace", &debug.inittrace},
 }
@@ -352,6 +358,8 @@ func parsedebugvars() {
 	// defaults
 	debug.cgocheck = 1
 	debug.invalidptr = 1
+	debug.containermaxprocs = 1
+	debug.updatemaxprocs = 1
 	if GOOS == "linux" {
 		// On Linux, MADV_FREE is faster than MADV_DONTNEED,
 		// but doesn't affect many of the statistics that
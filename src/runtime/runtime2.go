@@ -351,7 +351,7 @@ type sudog struct {
 
 	next *sudog
 	prev *sudog
-	elem unsafe.Pointer // data element (may point to stack)
+	elem maybeTraceablePtr // data element (may point to stack)
 
 	// The following fields are never accessed concurrently.
 	// For channels, waitlink is only accessed by g.
@@ -372,10 +372,55 @@ type sudog struct {
 	// because c was closed.
 	success bool
 
-	parent   *sudog // semaRoot binary tree
-	waitlink *sudog // g.waiting list or semaRoot
-	waittail *sudog // semaRoot
-	c        *hchan // channel
+	parent   *sudog             // semaRoot binary tree
+	waitlink *sudog             // g.waiting list or semaRoot
+	waittail *sudog             // semaRoot
+	c        maybeTraceableChan // channel
+}
+
+// A maybeTraceablePtr is a pointer that can be hidden from the garbage
+// collector. During goroutine leak detection, the sudogs of blocked
+// goroutines hide their channels and semaphore addresses, so that the
+// objects a goroutine is blocked on are only marked if something other
+// than the goroutine itself can reach them. See findGoroutineLeaks.
+type maybeTraceablePtr struct {
+	vp unsafe.Pointer // the pointer, or nil while it is hidden
+	vu uintptr        // the pointer as an integer
+}
+
+func (p *maybeTraceablePtr) set(v unsafe.Pointer) {
+	p.vp = v
+	p.vu = uintptr(v)
+}
+
+func (p *maybeTraceablePtr) get() unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&p.vu))
+}
+
+// setUntraceable hides p from the garbage collector.
+// It clears p.vp without a write barrier, which would shade
+// the object p points to.
+func (p *maybeTraceablePtr) setUntraceable() {
+	*(*uintptr)(unsafe.Pointer(&p.vp)) = 0
+}
+
+// setTraceable makes p visible to the garbage collector again.
+// The write barrier shades the object p points to.
+func (p *maybeTraceablePtr) setTraceable() {
+	p.vp = p.get()
+}
+
+// A maybeTraceableChan is a maybeTraceablePtr to a channel.
+type maybeTraceableChan struct {
+	maybeTraceablePtr
+}
+
+func (c *maybeTraceableChan) set(v *hchan) {
+	c.maybeTraceablePtr.set(unsafe.Pointer(v))
+}
+
+func (c *maybeTraceableChan) get() *hchan {
+	return (*hchan)(c.maybeTraceablePtr.get())
 }
 
 type libcall struct {
@@ -427,6 +472,7 @@ type g struct {
 	schedlink    guintptr
 	waitsince    int64      // approx time when the g become blocked
 	waitreason   waitReason // if status==Gwaiting
+	waitobj      uintptr    // object waited for in semacquire or sync.Cond.Wait, for leak detection
 
 	preempt       bool // preemption signal, duplicates stackguard0 = stackpreempt
 	preemptStop   bool // transition to _Gpreempted on preemption; otherwise, just deschedule
@@ -440,6 +486,10 @@ type g struct {
 	paniconfault bool // panic (instead of crash) on unexpected fault address
 	gcscandone   bool // g has scanned stack; protected by _Gscan bit in status
 	throwsplit   bool // must not split stack
+	// leakCandidate and leaked are set by goroutine leak detection.
+	// See findGoroutineLeaks.
+	leakCandidate bool // g is blocked and its stack is not scanned yet
+	leaked        bool // g is blocked forever
 	// activeStackChans indicates that there are unlocked channels
 	// pointing into this goroutine's stack. If true, stack
 	// copying needs to acquire channel locks to protect these
@@ -452,9 +502,14 @@ type g struct {
 
 	raceignore     int8     // ignore race detection events
 	sysblocktraced bool     // StartTrace has emitted EvGoInSyscall about this goroutine
+	tracking       bool     // whether we're tracking this G for sched latency statistics
+	trackingSeq    uint8    // used to decide whether to track this G
+	runnableStamp  int64    // nanotime when the G last became runnable, only used when tracking
 	sysexitticks   int64    // cputicks when syscall has returned (for tracing)
 	traceseq       uint64   // trace event sequencer
 	tracelastp     puintptr // last P emitted an event for this goroutine
+	tracegen       uint32   // trace generation that has the state of this goroutine, see traceGoLock
+	tracestatus    uint8    // state of this goroutine as the trace shows it, see traceGoState
 	lockedm        muintptr
 	sig            uint32
 	writebuf       []byte
@@ -534,9 +589,23 @@ type m struct {
 	waittraceev   byte
 	waittraceskip int
 	startingtrace bool
+	tracebuf      [2]traceBufPtr // per-M trace buffers by generation parity, see traceAcquireBuffer
+	traceseqlock  uint32         // odd while writing trace events, see traceAdvance
+	tracegen      uint64         // trace generation of the events being written
+	tracelocks    int32          // nesting depth of traceAcquireBuffer
 	syscalltick   uint32
 	freelink      *m // on sched.freem
 
+	// mLockProfile holds a sampled contention event on a runtime
+	// lock until it can be added to its profile.
+	mLockProfile mLockProfile
+
+	// lockWait is the address of the runtime lock the M sleeps on,
+	// and lockWaitStart the time it went to sleep, in truncated
+	// cputicks, if mutex profiling is enabled. See lockTimer.
+	lockWait      uintptr
+	lockWaitStart uintptr
+
 	// mFixup is used to synchronize OS related m state
 	// (credentials etc) use mutex to access. To avoid deadlocks
 	// an atomic.Load() of used being zero in mDoFixupFn()
@@ -630,7 +699,17 @@ type p struct {
 		buf [128]*mspan
 	}
 
-	tracebuf traceBufPtr
+	// traceBatchSeq orders the batches of trace events of this P,
+	// which are written by whichever M holds the P. See traceBatchStart.
+	traceBatchSeq uint64
+
+	// traceGen is the trace generation in which the state of this P
+	// was last written. traceRunning and traceG are what the trace
+	// shows: whether the P is running, and the goroutine it runs.
+	// See traceProcState.
+	traceGen     uint64
+	traceRunning bool
+	traceG       guintptr
 
 	// traceSweep indicates the sweep events should be traced.
 	// This is used to defer the sweep start event until a span
@@ -642,8 +721,6 @@ type p struct {
 
 	palloc persistentAlloc // per-P to avoid mutex
 
-	_ uint32 // Alignment for atomic fields below
-
 	// The when field of the first entry on the timer heap.
 	// This is updated using atomic functions.
 	// This is 0 if the timer heap is empty.
@@ -1009,6 +1086,7 @@ const (
 	waitReasonGCWorkerIdle                            // "GC worker (idle)"
 	waitReasonPreempted                               // "preempted"
 	waitReasonDebugCall                               // "debug call"
+	waitReasonMaxProcsUpdaterIdle                     // "GOMAXPROCS updater (idle)"
 )
 
 var waitReasonStrings = [...]string{
@@ -1039,6 +1117,7 @@ var waitReasonStrings = [...]string{
 	waitReasonGCWorkerIdle:          "GC worker (idle)",
 	waitReasonPreempted:             "preempted",
 	waitReasonDebugCall:             "debug call",
+	waitReasonMaxProcsUpdaterIdle:   "GOMAXPROCS updater (idle)",
 }
 
 func (w waitReason) String() string {
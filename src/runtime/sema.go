@@ -128,6 +128,10 @@ func semacquire1(addr *uint32, lifo bool, profile semaProfileFlags, skipframes i
 		}
 		s.acquiretime = t0
 	}
+	var waitStart int64
+	if profile&semaMutexProfile != 0 {
+		waitStart = nanotime()
+	}
 	for {
 		lockWithRank(&root.lock, lockRankRoot)
 		// Add ourselves to nwait to disable "easy case" in semrelease.
@@ -141,7 +145,9 @@ func semacquire1(addr *uint32, lifo bool, profile semaProfileFlags, skipframes i
 		// Any semrelease after the cansemacquire knows we're waiting
 		// (we set nwait above), so go to sleep.
 		root.queue(addr, s, lifo)
+		gp.waitobj = uintptr(unsafe.Pointer(addr))
 		goparkunlock(&root.lock, waitReasonSemacquire, traceEvGoBlockSync, 4+skipframes)
+		gp.waitobj = 0
 		if s.ticket != 0 || cansemacquire(addr) {
 			break
 		}
@@ -149,6 +155,9 @@ func semacquire1(addr *uint32, lifo bool, profile semaProfileFlags, skipframes i
 	if s.releasetime > 0 {
 		blockevent(s.releasetime-t0, 3+skipframes)
 	}
+	if waitStart != 0 {
+		recordMutexWait(nanotime() - waitStart)
+	}
 	releaseSudog(s)
 }
 
@@ -233,14 +242,14 @@ func cansemacquire(addr *uint32) bool {
 // queue adds s to the blocked goroutines in semaRoot.
 func (root *semaRoot) queue(addr *uint32, s *sudog, lifo bool) {
 	s.g = getg()
-	s.elem = unsafe.Pointer(addr)
+	s.elem.set(unsafe.Pointer(addr))
 	s.next = nil
 	s.prev = nil
 
 	var last *sudog
 	pt := &root.treap
 	for t := *pt; t != nil; t = *pt {
-		if t.elem == unsafe.Pointer(addr) {
+		if t.elem.get() == unsafe.Pointer(addr) {
 			// Already have addr in list.
 			if lifo {
 				// Substitute s in t's place in treap.
@@ -279,7 +288,7 @@ func (root *semaRoot) queue(addr *uint32, s *sudog, lifo bool) {
 			return
 		}
 		last = t
-		if uintptr(unsafe.Pointer(addr)) < uintptr(t.elem) {
+		if uintptr(unsafe.Pointer(addr)) < uintptr(t.elem.get()) {
 			pt = &t.prev
 		} else {
 			pt = &t.next
@@ -322,10 +331,10 @@ func (root *semaRoot) dequeue(addr *uint32) (found *sudog, now int64) {
 	ps := &root.treap
 	s := *ps
 	for ; s != nil; s = *ps {
-		if s.elem == unsafe.Pointer(addr) {
+		if s.elem.get() == unsafe.Pointer(addr) {
 			goto Found
 		}
-		if uintptr(unsafe.Pointer(addr)) < uintptr(s.elem) {
+		if uintptr(unsafe.Pointer(addr)) < uintptr(s.elem.get()) {
 			ps = &s.prev
 		} else {
 			ps = &s.next
@@ -380,13 +389,35 @@ Found:
 		}
 	}
 	s.parent = nil
-	s.elem = nil
+	s.elem.set(nil)
 	s.next = nil
 	s.prev = nil
 	s.ticket = 0
 	return s, now
 }
 
+// forEachSemaSudog calls f for each sudog blocked on a semaphore.
+func forEachSemaSudog(f func(*sudog)) {
+	for i := range semtable {
+		root := &semtable[i].root
+		lockWithRank(&root.lock, lockRankRoot)
+		semaWalk(root.treap, f)
+		unlock(&root.lock)
+	}
+}
+
+// semaWalk calls f for each sudog in the tree rooted at s.
+func semaWalk(s *sudog, f func(*sudog)) {
+	if s == nil {
+		return
+	}
+	for t := s; t != nil; t = t.waitlink {
+		f(t)
+	}
+	semaWalk(s.prev, f)
+	semaWalk(s.next, f)
+}
+
 // rotateLeft rotates the tree rooted at node x.
 // turning (x a (y b c)) into (y (x a b) c).
 func (root *semaRoot) rotateLeft(x *sudog) {
@@ -510,7 +541,9 @@ func notifyListWait(l *notifyList, t uint32) {
 		l.tail.next = s
 	}
 	l.tail = s
+	s.g.waitobj = uintptr(unsafe.Pointer(l))
 	goparkunlock(&l.lock, waitReasonSyncCondWait, traceEvGoBlockCond, 3)
+	s.g.waitobj = 0
 	if t0 != 0 {
 		blockevent(s.releasetime-t0, 2)
 	}
@@ -35,3 +35,6 @@ func retpolineR12()
 func retpolineR13()
 func retpolineR14()
 func retpolineR15()
+
+// getfp returns the frame pointer register of its caller.
+func getfp() uintptr
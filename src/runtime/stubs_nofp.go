@@ -0,0 +1,11 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !amd64,!arm64
+
+package runtime
+
+// getfp returns the frame pointer register of its caller,
+// or 0 if the architecture does not keep frame pointers.
+func getfp() uintptr { return 0 }
@@ -21,7 +21,7 @@ import (
 // Event types in the trace, args are given in square brackets.
 const (
 	traceEvNone              = 0  // unused
-	traceEvBatch             = 1  // start of per-P batch of events [pid, timestamp]
+	traceEvBatch             = 1  // start of batch of events of a P [pid, batch seq, timestamp]
 	traceEvFrequency         = 2  // contains tracer timer frequency [frequency (ticks per second)]
 	traceEvStack             = 3  // stack [stack id, number of PCs, array of {PC, func string ID, file string ID, line}]
 	traceEvGomaxprocs        = 4  // current value of GOMAXPROCS [timestamp, GOMAXPROCS, stack id]
@@ -69,7 +69,9 @@ const (
 	traceEvUserTaskEnd       = 46 // end of a task [timestamp, internal task id, stack]
 	traceEvUserRegion        = 47 // trace.WithRegion [timestamp, internal task id, mode(0:start, 1:end), stack, name string]
 	traceEvUserLog           = 48 // trace.Log [timestamp, internal task id, key string id, stack, value string]
-	traceEvCount             = 49
+	traceEvGeneration        = 49 // start of a trace generation [generation number]
+	traceEvState             = 50 // state at the start of a generation [timestamp, event type, event args]
+	traceEvCount             = 51
 	// Byte is used but only 6 bits are available for event type.
 	// The remaining 2 bits are used to specify the number of arguments.
 	// That means, the max event type value is 63.
@@ -107,52 +109,68 @@ const (
 
 // trace is global tracing context.
 var trace struct {
-	lock          mutex       // protects the following members
-	lockOwner     *g          // to avoid deadlocks during recursive lock locks
-	enabled       bool        // when set runtime traces events
-	shutdown      bool        // set when we are waiting for trace reader to finish after setting enabled to false
-	headerWritten bool        // whether ReadTrace has emitted trace header
-	footerWritten bool        // whether ReadTrace has emitted trace footer
-	shutdownSema  uint32      // used to wait for ReadTrace completion
-	seqStart      uint64      // sequence number when tracing was started
-	ticksStart    int64       // cputicks when tracing was started
-	ticksEnd      int64       // cputicks when tracing was stopped
-	timeStart     int64       // nanotime when tracing was started
-	timeEnd       int64       // nanotime when tracing was stopped
-	seqGC         uint64      // GC start/done sequencer
-	reading       traceBufPtr // buffer currently handed off to user
-	empty         traceBufPtr // stack of empty buffers
-	fullHead      traceBufPtr // queue of full buffers
-	fullTail      traceBufPtr
-	reader        guintptr        // goroutine that called ReadTrace, or nil
-	stackTab      traceStackTable // maps stack traces to unique ids
-
-	// Dictionary for traceEvString.
+	// gen is the current generation, see traceAdvance. It is first
+	// so that it is 64-bit aligned for atomic access.
+	gen uint64
+
+	lock          mutex         // protects the following members
+	lockOwner     *g            // to avoid deadlocks during recursive lock locks
+	enabled       bool          // when set runtime traces events
+	shutdown      bool          // set when we are waiting for trace reader to finish after setting enabled to false
+	headerWritten bool          // whether ReadTrace has emitted trace header
+	shutdownSema  uint32        // used to wait for ReadTrace completion
+	seqStart      uint64        // sequence number when tracing was started
+	ticksStart    int64         // cputicks when the current generation started
+	ticksEnd      int64         // cputicks when the last generation ended
+	timeStart     int64         // nanotime when the current generation started
+	timeEnd       int64         // nanotime when the last generation ended
+	seqGC         uint64        // GC start/done sequencer
+	flushedGen    uint64        // last generation whose buffers are all queued
+	reading       traceBufPtr   // buffer currently handed off to user
+	empty         traceBufPtr   // stack of empty buffers
+	full          traceBufQueue // full buffers of generations up to flushedGen+1
+	fullNext      traceBufQueue // full buffers of generation flushedGen+2, see traceFullQueue
+	reader        guintptr      // goroutine that called ReadTrace, or nil
+
+	// stackTab maps stack traces to unique ids, one table
+	// per generation, indexed by generation parity.
+	stackTab [2]traceStackTable
+
+	// Dictionary for traceEvString, indexed by generation parity.
 	//
 	// TODO: central lock to access the map is not ideal.
 	//   option: pre-assign ids to all user annotation region names and tags
 	//   option: per-P cache
 	//   option: sync.Map like data structure
 	stringsLock mutex
-	strings     map[string]uint64
-	stringSeq   uint64
+	strings     [2]map[string]uint64
+	stringSeq   [2]uint64
 
-	// markWorkerLabels maps gcMarkWorkerMode to string ID.
-	markWorkerLabels [len(gcMarkWorkerModeStrings)]uint64
+	// markWorkerLabels maps gcMarkWorkerMode to string ID,
+	// indexed by generation parity.
+	markWorkerLabels [2][len(gcMarkWorkerModeStrings)]uint64
 
-	bufLock mutex       // protects buf
-	buf     traceBufPtr // global trace buffer, used when running without a p
+	// bufLock is held while writing events without a P, and while
+	// taking the trace buffers of other Ms.
+	bufLock  mutex
+	batchSeq uint64 // like p.traceBatchSeq, for events written without a P
 }
 
-// traceBufHeader is per-P tracing buffer.
+// traceAdvanceSema serializes traceAdvance, StartTrace and StopTrace.
+var traceAdvanceSema uint32 = 1
+
+// traceBufHeader is per-M tracing buffer.
 type traceBufHeader struct {
 	link      traceBufPtr             // in trace.empty/full
 	lastTicks uint64                  // when we wrote the last event
+	gen       uint64                  // generation of the events in the buffer
+	pid       int32                   // P of the current batch
+	seq       uint64                  // sequence number of the current batch, or 0
 	pos       int                     // next write offset in arr
 	stk       [traceStackSize]uintptr // scratch buffer for traceback
 }
 
-// traceBuf is per-P tracing buffer.
+// traceBuf is per-M tracing buffer.
 //
 //go:notinheap
 type traceBuf struct {
@@ -181,6 +199,8 @@ func traceBufPtrOf(b *traceBuf) traceBufPtr {
 // Most clients should use the runtime/trace package or the testing package's
 // -test.trace flag instead of calling StartTrace directly.
 func StartTrace() error {
+	semacquire(&traceAdvanceSema)
+
 	// Stop the world so that we can take a consistent snapshot
 	// of all goroutines at the beginning of the trace.
 	// Do not stop the world during GC so we ensure we always see
@@ -194,14 +214,15 @@ func StartTrace() error {
 	// We are in stop-the-world, but syscalls can finish and write to trace concurrently.
 	// Exitsyscall could check trace.enabled long before and then suddenly wake up
 	// and decide to write to trace at a random point in time.
-	// However, such syscall will use the global trace.buf buffer, because we've
-	// acquired all p's by doing stop-the-world. So this protects us from such races.
+	// However, such syscall has no P, so it writes events while holding trace.bufLock,
+	// because we've acquired all p's by doing stop-the-world. So this protects us from such races.
 	lock(&trace.bufLock)
 
 	if trace.enabled || trace.shutdown {
 		unlock(&trace.bufLock)
 		unlock(&sched.sysmonlock)
 		startTheWorldGC()
+		semrelease(&traceAdvanceSema)
 		return errorString("tracing is already enabled")
 	}
 
@@ -215,33 +236,48 @@ func StartTrace() error {
 	_g_ := getg()
 	_g_.m.startingtrace = true
 
-	// Obtain current stack ID to use in all traceEvGoCreate events below.
-	mp := acquirem()
-	stkBuf := make([]uintptr, traceStackSize)
-	stackID := traceStackID(mp, stkBuf, 2)
-	releasem(mp)
+	trace.headerWritten = false
 
-	for _, gp := range allgs {
-		status := readgstatus(gp)
-		if status != _Gdead {
-			gp.traceseq = 0
-			gp.tracelastp = getg().m.p
-			// +PCQuantum because traceFrameForPC expects return PCs and subtracts PCQuantum.
-			id := trace.stackTab.put([]uintptr{gp.startpc + sys.PCQuantum})
-			traceEvent(traceEvGoCreate, -1, uint64(gp.goid), uint64(id), stackID)
+	// Generation numbers keep growing from one trace to the next, so
+	// that the generations a previous trace left in goroutines and Ps
+	// are older than any generation of this one.
+	gen := trace.gen + 1
+	trace.flushedGen = gen - 1
+	trace.seqGC = 0
+	atomic.Store64(&trace.gen, gen)
+	marker := traceGenMarker(gen)
+	lock(&trace.lock)
+	traceFullQueue(marker)
+	unlock(&trace.lock)
+	traceGenPrepare(gen)
+
+	// Record the state of all goroutines and Ps as the trace will
+	// show it, and write the goroutines to the trace. Ps beyond
+	// GOMAXPROCS may be brought back later, so reset them too.
+	for _, pp := range allp[:cap(allp)] {
+		if pp != nil {
+			pp.traceRunning = false
+			pp.traceG = 0
 		}
-		if status == _Gwaiting {
-			// traceEvGoWaiting is implied to have seq=1.
-			gp.traceseq++
-			traceEvent(traceEvGoWaiting, -1, uint64(gp.goid))
+	}
+	for _, gp := range allgs {
+		switch readgstatus(gp) &^ _Gscan {
+		case _Gdead:
+			gp.tracestatus = traceGoStatusDead
+		case _Grunning:
+			gp.tracestatus = traceGoStatusRunning
+		case _Gwaiting:
+			gp.tracestatus = traceGoStatusWaiting
+		case _Gsyscall:
+			gp.tracestatus = traceGoStatusSyscall
+		default:
+			gp.tracestatus = traceGoStatusRunnable
 		}
-		if status == _Gsyscall {
-			gp.traceseq++
-			traceEvent(traceEvGoInSyscall, -1, uint64(gp.goid))
-		} else {
+		if gp.tracestatus != traceGoStatusSyscall {
 			gp.sysblocktraced = false
 		}
 	}
+	traceGoSnapshot()
 	traceProcStart()
 	traceGoStart()
 	// Note: ticksStart needs to be set after we emit traceEvGoInSyscall events.
@@ -250,37 +286,27 @@ func StartTrace() error {
 	// It will lead to a false conclusion that cputicks is broken.
 	trace.ticksStart = cputicks()
 	trace.timeStart = nanotime()
-	trace.headerWritten = false
-	trace.footerWritten = false
 
-	// string to id mapping
-	//  0 : reserved for an empty string
-	//  remaining: other strings registered by traceString
-	trace.stringSeq = 0
-	trace.strings = make(map[string]uint64)
+	// Queue the snapshot ahead of the events of other Ms.
+	traceFlushM(_g_.m, gen)
 
-	trace.seqGC = 0
 	_g_.m.startingtrace = false
 	trace.enabled = true
 
-	// Register runtime goroutine labels.
-	_, pid, bufp := traceAcquireBuffer()
-	for i, label := range gcMarkWorkerModeStrings[:] {
-		trace.markWorkerLabels[i], bufp = traceString(bufp, pid, label)
-	}
-	traceReleaseBuffer(pid)
-
 	unlock(&trace.bufLock)
 
 	unlock(&sched.sysmonlock)
 
 	startTheWorldGC()
+	semrelease(&traceAdvanceSema)
 	return nil
 }
 
 // StopTrace stops tracing, if it was previously enabled.
 // StopTrace only returns after all the reads for the trace have completed.
 func StopTrace() {
+	semacquire(&traceAdvanceSema)
+
 	// Stop the world so that we can collect the trace buffers from all p's below,
 	// and also to avoid races with traceEvent.
 	stopTheWorldGC("stop tracing")
@@ -295,47 +321,31 @@ func StopTrace() {
 		unlock(&trace.bufLock)
 		unlock(&sched.sysmonlock)
 		startTheWorldGC()
+		semrelease(&traceAdvanceSema)
 		return
 	}
 
 	traceGoSched()
-
-	// Loop over all allocated Ps because dead Ps may still have
-	// trace buffers.
-	for _, p := range allp[:cap(allp)] {
-		buf := p.tracebuf
-		if buf != 0 {
-			traceFullQueue(buf)
-			p.tracebuf = 0
-		}
-	}
-	if trace.buf != 0 {
-		buf := trace.buf
-		trace.buf = 0
-		if buf.ptr().pos != 0 {
-			traceFullQueue(buf)
-		}
-	}
-
-	for {
-		trace.ticksEnd = cputicks()
-		trace.timeEnd = nanotime()
-		// Windows time can tick only every 15ms, wait for at least one tick.
-		if trace.timeEnd != trace.timeStart {
-			break
-		}
-		osyield()
-	}
+	traceFlushAll()
+	traceGenEnd()
 
 	trace.enabled = false
-	trace.shutdown = true
 	unlock(&trace.bufLock)
 
 	unlock(&sched.sysmonlock)
 
 	startTheWorldGC()
 
-	// The world is started but we've set trace.shutdown, so new tracing can't start.
+	// Queue the rest of the last generation, and then let the reader
+	// finish. Setting trace.shutdown keeps new tracing from starting.
+	gen := trace.gen
+	traceGenFlush(gen, traceFrequency())
+	lock(&trace.lock)
+	trace.flushedGen = gen
+	trace.shutdown = true
+	unlock(&trace.lock)
+	semrelease(&traceAdvanceSema)
+
 	// Wait for the trace reader to flush pending buffers and stop.
 	semacquire(&trace.shutdownSema)
 	if raceenabled {
@@ -344,15 +354,12 @@ func StopTrace() {
 
 	// The lock protects us from races with StartTrace/StopTrace because they do stop-the-world.
 	lock(&trace.lock)
-	for _, p := range allp[:cap(allp)] {
-		if p.tracebuf != 0 {
-			throw("trace: non-empty trace buffer in proc")
+	for mp := allm; mp != nil; mp = mp.alllink {
+		if mp.tracebuf[0] != 0 || mp.tracebuf[1] != 0 {
+			throw("trace: non-empty trace buffer in thread")
 		}
 	}
-	if trace.buf != 0 {
-		throw("trace: non-empty global trace buffer")
-	}
-	if trace.fullHead != 0 || trace.fullTail != 0 {
+	if !trace.full.empty() || !trace.fullNext.empty() {
 		throw("trace: non-empty full trace buffer")
 	}
 	if trace.reading != 0 || trace.reader != 0 {
@@ -363,17 +370,343 @@ func StopTrace() {
 		trace.empty = buf.ptr().link
 		sysFree(unsafe.Pointer(buf), unsafe.Sizeof(*buf.ptr()), &memstats.other_sys)
 	}
-	trace.strings = nil
+	trace.strings = [2]map[string]uint64{}
 	trace.shutdown = false
 	unlock(&trace.lock)
 }
 
+// traceAdvance ends the current trace generation and starts a new one.
+// It returns the number of the new generation, or 0 if tracing is not
+// enabled.
+//
+// Each generation is self-contained: it is preceded by a traceEvGeneration
+// marker, describes each goroutine and P before its first event about them,
+// and is followed by its own timer frequency and stack table, and it has
+// its own string dictionary. A reader can therefore drop the oldest
+// generations of a trace and still parse the rest, which is what the flight
+// recorder in runtime/trace does.
+//
+// traceAdvance does not stop the world. Each M writes the events of one
+// generation at a time, the one it saw when it began writing (see
+// traceAcquireBuffer), and keeps a buffer for each of the two generations
+// that may be in flight. Once no M is still writing events of the old
+// generation, traceAdvance queues their buffers for it, followed by its
+// frequency and stacks. Goroutines and Ps describe themselves lazily in
+// the new generation, and traceAdvance then describes the goroutines
+// that have not done so yet.
+//
+//go:linkname traceAdvance runtime/trace.runtime_traceAdvance
+func traceAdvance() uint64 {
+	semacquire(&traceAdvanceSema)
+	if !trace.enabled {
+		semrelease(&traceAdvanceSema)
+		return 0
+	}
+	gen := trace.gen
+	traceGenPrepare(gen + 1)
+
+	// Start the new generation while no GC is running, so that
+	// each GC starts and ends in the same generation.
+	semacquire(&gcsema)
+	traceGenEnd()
+	freq := traceFrequency()
+	trace.ticksStart = trace.ticksEnd
+	trace.timeStart = trace.timeEnd
+	trace.seqGC = 0
+	atomic.Store64(&trace.gen, gen+1)
+	semrelease(&gcsema)
+
+	// Wait for the Ms that may still be writing events of the old
+	// generation. An M that starts writing from now on sees the new one.
+	// Ms that exit from now on flush their own buffers, and their
+	// alllink still leads to the rest of the list.
+	for mp := (*m)(atomic.Loadp(unsafe.Pointer(&allm))); mp != nil; mp = mp.alllink {
+		seq := atomic.Load(&mp.traceseqlock)
+		for seq%2 == 1 && atomic.Load(&mp.traceseqlock) == seq {
+			osyield()
+		}
+	}
+	lock(&trace.bufLock)
+	for mp := (*m)(atomic.Loadp(unsafe.Pointer(&allm))); mp != nil; mp = mp.alllink {
+		traceFlushM(mp, gen)
+	}
+	unlock(&trace.bufLock)
+
+	traceGenFlush(gen, freq)
+
+	// Let the reader move on to the new generation.
+	marker := traceGenMarker(gen + 1)
+	lock(&trace.lock)
+	trace.flushedGen = gen
+	trace.full.push(marker)
+	trace.full.pushAll(&trace.fullNext)
+	unlock(&trace.lock)
+
+	traceGoSnapshot()
+
+	semrelease(&traceAdvanceSema)
+	return gen + 1
+}
+
+// traceGenPrepare resets the string dictionary for generation gen,
+// which the generation two before it used, and registers the labels
+// of GC mark workers in it.
+func traceGenPrepare(gen uint64) {
+	// string to id mapping
+	//  0 : reserved for an empty string
+	//  remaining: other strings registered by traceString
+	strings := make(map[string]uint64)
+	lock(&trace.stringsLock)
+	trace.strings[gen%2] = strings
+	trace.stringSeq[gen%2] = 0
+	unlock(&trace.stringsLock)
+
+	var buf traceBufPtr
+	bufp := &buf
+	for i, label := range gcMarkWorkerModeStrings[:] {
+		trace.markWorkerLabels[gen%2][i], bufp = traceString(bufp, traceGlobProc, gen, label)
+	}
+	lock(&trace.lock)
+	traceFullQueue(buf)
+	unlock(&trace.lock)
+}
+
+// traceGenMarker returns a buffer holding the marker that starts
+// generation gen. The marker gets a buffer of its own, which must be
+// queued ahead of any buffer holding events of the generation.
+func traceGenMarker(gen uint64) traceBufPtr {
+	buf := traceFlush(0, traceGlobProc, gen)
+	buf.ptr().byte(traceEvGeneration | 0<<traceArgCountShift)
+	buf.ptr().varint(gen)
+	return buf
+}
+
+// traceGenFlush queues the timer frequency and the stack table of
+// generation gen, which no M is writing events of anymore.
+func traceGenFlush(gen, freq uint64) {
+	buf := traceFlush(0, traceGlobProc, gen)
+	buf.ptr().byte(traceEvFrequency | 0<<traceArgCountShift)
+	buf.ptr().varint(freq)
+	lock(&trace.lock)
+	traceFullQueue(buf)
+	unlock(&trace.lock)
+	trace.stackTab[gen%2].dump(gen)
+}
+
+// traceGenEnd records when the current generation ends.
+func traceGenEnd() {
+	for {
+		trace.ticksEnd = cputicks()
+		trace.timeEnd = nanotime()
+		// Windows time can tick only every 15ms, wait for at least one tick.
+		if trace.timeEnd != trace.timeStart {
+			break
+		}
+		osyield()
+	}
+}
+
+// traceFrequency returns the timer frequency, in ticks per second,
+// of the generation ended by traceGenEnd.
+func traceFrequency() uint64 {
+	// Use float64 because (trace.ticksEnd - trace.ticksStart) * 1e9 can overflow int64.
+	freq := float64(trace.ticksEnd-trace.ticksStart) * 1e9 / float64(trace.timeEnd-trace.timeStart) / traceTickDiv
+	return uint64(freq)
+}
+
+// traceFlushAll queues the trace buffers of all Ms. It must be called
+// with the world stopped and trace.bufLock held, so that no M is
+// writing to its buffers.
+func traceFlushAll() {
+	for mp := allm; mp != nil; mp = mp.alllink {
+		traceFlushM(mp, 0)
+		traceFlushM(mp, 1)
+	}
+}
+
+// traceFlushM queues the trace buffer of mp for the generations with
+// the parity of gen. mp must not be writing to it, and trace.bufLock
+// must be held.
+func traceFlushM(mp *m, gen uint64) {
+	buf := mp.tracebuf[gen%2]
+	if buf == 0 {
+		return
+	}
+	mp.tracebuf[gen%2] = 0
+	lock(&trace.lock)
+	traceFullQueue(buf)
+	unlock(&trace.lock)
+}
+
+// traceMExit queues the trace buffers of mp, which is exiting.
+func traceMExit(mp *m) {
+	lock(&trace.bufLock)
+	traceFlushM(mp, 0)
+	traceFlushM(mp, 1)
+	unlock(&trace.bufLock)
+}
+
+// Goroutine states as the trace shows them, see g.tracestatus.
+const (
+	traceGoStatusDead = iota // also used for goroutines the trace has not seen
+	traceGoStatusRunnable
+	traceGoStatusRunning
+	traceGoStatusWaiting
+	traceGoStatusSyscall
+)
+
+// traceGenBusy is the value of g.tracegen while an M writes the state
+// of the goroutine, see traceGoLock.
+const traceGenBusy = ^uint32(0)
+
+// traceGoSnapshot writes the state of each goroutine that has not yet
+// described itself in the current generation.
+func traceGoSnapshot() {
+	ptr, n := atomicAllG()
+	for i := uintptr(0); i < n; i++ {
+		gp := atomicAllGIndex(ptr, i)
+		mp, pid, bufp := traceAcquireBuffer()
+		if trace.enabled || mp.startingtrace {
+			traceGoState(mp, pid, bufp, gp)
+		}
+		traceReleaseBuffer(pid)
+	}
+}
+
+// traceGoState writes the state of gp to the generation mp is writing,
+// unless the generation already has it, and reports whether it did.
+// A generation describes each goroutine before its first event about
+// the goroutine, so that it can be parsed without the ones before it.
+func traceGoState(mp *m, pid int32, bufp *traceBufPtr, gp *g) bool {
+	gen := uint32(mp.tracegen)
+	if atomic.Load(&gp.tracegen) == gen {
+		return false
+	}
+	old := traceGoLock(gp)
+	if old >= gen || gp.tracestatus == traceGoStatusDead {
+		if old < gen {
+			old = gen
+		}
+		traceGoUnlock(gp, old)
+		return false
+	}
+	gp.traceseq = 0
+	gp.tracelastp = 0
+	// +PCQuantum because traceFrameForPC expects return PCs and subtracts PCQuantum.
+	pcs := [...]uintptr{traceLogicalStack, gp.startpc + sys.PCQuantum}
+	id := trace.stackTab[mp.tracegen%2].put(pcs[:])
+	traceEventLocked(0, mp, pid, bufp, traceEvState, 0, traceEvGoCreate, uint64(gp.goid), uint64(id))
+	switch gp.tracestatus {
+	case traceGoStatusWaiting:
+		// traceEvGoWaiting is implied to have seq=1.
+		gp.traceseq++
+		traceEventLocked(0, mp, pid, bufp, traceEvState, -1, traceEvGoWaiting, uint64(gp.goid))
+	case traceGoStatusSyscall:
+		gp.traceseq++
+		// The syscall may have returned before this event,
+		// see traceGoSysExit.
+		gp.sysexitticks = 0
+		traceEventLocked(0, mp, pid, bufp, traceEvState, -1, traceEvGoInSyscall, uint64(gp.goid))
+	}
+	traceGoUnlock(gp, gen)
+	return true
+}
+
+// traceGoLock waits until no other M is writing the state of gp, and
+// keeps others from doing so until traceGoUnlock. It returns the
+// generation that has the state of gp.
+func traceGoLock(gp *g) uint32 {
+	for {
+		gen := atomic.Load(&gp.tracegen)
+		if gen != traceGenBusy && atomic.Cas(&gp.tracegen, gen, traceGenBusy) {
+			return gen
+		}
+		osyield()
+	}
+}
+
+// traceGoUnlock undoes traceGoLock, recording that generation gen
+// has the state of gp.
+func traceGoUnlock(gp *g, gen uint32) {
+	atomic.Store(&gp.tracegen, gen)
+}
+
+// traceProcState writes the state of pp, as the trace shows it, to the
+// generation mp is writing. It is called before the first event of pp
+// in the generation.
+func traceProcState(mp *m, pid int32, bufp *traceBufPtr, pp *p) {
+	pp.traceGen = mp.tracegen
+	if !pp.traceRunning {
+		return
+	}
+	traceEventLocked(0, mp, pid, bufp, traceEvState, -1, traceEvProcStart, uint64(mp.id))
+	if pp.traceSweep && pp.traceSwept != 0 {
+		traceEventLocked(0, mp, pid, bufp, traceEvState, 0, traceEvGCSweepStart)
+	}
+	gp := pp.traceG.ptr()
+	if gp == nil {
+		return
+	}
+	traceGoState(mp, pid, bufp, gp)
+	gp.traceseq++
+	gp.tracelastp.set(pp)
+	if pp.gcMarkWorkerMode != gcMarkWorkerNotWorker {
+		label := trace.markWorkerLabels[mp.tracegen%2][pp.gcMarkWorkerMode]
+		traceEventLocked(0, mp, pid, bufp, traceEvState, -1, traceEvGoStartLabel, uint64(gp.goid), gp.traceseq, label)
+	} else {
+		traceEventLocked(0, mp, pid, bufp, traceEvState, -1, traceEvGoStart, uint64(gp.goid), gp.traceseq)
+	}
+}
+
+// traceProcUpdate records what the trace shows about pp, and the
+// goroutine it runs, after event ev of pp.
+func traceProcUpdate(mp *m, pp *p, ev byte) {
+	var status uint8
+	switch ev {
+	case traceEvProcStart:
+		pp.traceRunning = true
+		return
+	case traceEvProcStop:
+		pp.traceRunning = false
+		return
+	case traceEvGoStart, traceEvGoStartLocal, traceEvGoStartLabel:
+		pp.traceG.set(mp.curg)
+		mp.curg.tracestatus = traceGoStatusRunning
+		return
+	case traceEvGoEnd, traceEvGoStop:
+		status = traceGoStatusDead
+	case traceEvGoSched, traceEvGoPreempt:
+		status = traceGoStatusRunnable
+	case traceEvGoSleep, traceEvGoBlock, traceEvGoBlockSend, traceEvGoBlockRecv,
+		traceEvGoBlockSelect, traceEvGoBlockSync, traceEvGoBlockCond, traceEvGoBlockNet,
+		traceEvGoBlockGC:
+		status = traceGoStatusWaiting
+	case traceEvGoSysBlock:
+		status = traceGoStatusSyscall
+	default:
+		return
+	}
+	if gp := pp.traceG.ptr(); gp != nil {
+		gp.tracestatus = status
+		pp.traceG = 0
+	}
+}
+
 // ReadTrace returns the next chunk of binary tracing data, blocking until data
 // is available. If tracing is turned off and all the data accumulated while it
 // was on has been returned, ReadTrace returns nil. The caller must copy the
 // returned data before calling ReadTrace again.
 // ReadTrace must be called from one goroutine at a time.
 func ReadTrace() []byte {
+	buf, _ := readTrace()
+	return buf
+}
+
+// readTrace is ReadTrace that also returns the generation of the data
+// it returns, or 0 for the trace header.
+//
+//go:linkname readTrace runtime/trace.runtime_readTrace
+func readTrace() ([]byte, uint64) {
 	// This function may need to lock trace.lock recursively
 	// (goparkunlock -> traceGoPark -> traceEvent -> traceFlush).
 	// To allow this we use trace.lockOwner.
@@ -390,7 +723,7 @@ func ReadTrace() []byte {
 		trace.lockOwner = nil
 		unlock(&trace.lock)
 		println("runtime: ReadTrace called from multiple goroutines simultaneously")
-		return nil
+		return nil, 0
 	}
 	// Recycle the old buffer.
 	if buf := trace.reading; buf != 0 {
@@ -403,36 +736,21 @@ func ReadTrace() []byte {
 		trace.headerWritten = true
 		trace.lockOwner = nil
 		unlock(&trace.lock)
-		return []byte("go 1.11 trace\x00\x00\x00")
+		return []byte("go 1.17 trace\x00\x00\x00"), 0
 	}
 	// Wait for new data.
-	if trace.fullHead == 0 && !trace.shutdown {
+	if trace.full.empty() && !trace.shutdown {
 		trace.reader.set(getg())
 		goparkunlock(&trace.lock, waitReasonTraceReaderBlocked, traceEvGoBlock, 2)
 		lock(&trace.lock)
 	}
 	// Write a buffer.
-	if trace.fullHead != 0 {
-		buf := traceFullDequeue()
+	if !trace.full.empty() {
+		buf := trace.full.pop()
 		trace.reading = buf
 		trace.lockOwner = nil
 		unlock(&trace.lock)
-		return buf.ptr().arr[:buf.ptr().pos]
-	}
-	// Write footer with timer frequency.
-	if !trace.footerWritten {
-		trace.footerWritten = true
-		// Use float64 because (trace.ticksEnd - trace.ticksStart) * 1e9 can overflow int64.
-		freq := float64(trace.ticksEnd-trace.ticksStart) * 1e9 / float64(trace.timeEnd-trace.timeStart) / traceTickDiv
-		trace.lockOwner = nil
-		unlock(&trace.lock)
-		var data []byte
-		data = append(data, traceEvFrequency|0<<traceArgCountShift)
-		data = traceAppend(data, uint64(freq))
-		// This will emit a bunch of full buffers, we will pick them up
-		// on the next iteration.
-		trace.stackTab.dump()
-		return data
+		return buf.ptr().arr[:buf.ptr().pos], buf.ptr().gen
 	}
 	// Done.
 	if trace.shutdown {
@@ -446,22 +764,22 @@ func ReadTrace() []byte {
 		}
 		// trace.enabled is already reset, so can call traceable functions.
 		semrelease(&trace.shutdownSema)
-		return nil
+		return nil, 0
 	}
 	// Also bad, but see the comment above.
 	trace.lockOwner = nil
 	unlock(&trace.lock)
 	println("runtime: spurious wakeup of trace reader")
-	return nil
+	return nil, 0
 }
 
 // traceReader returns the trace reader that should be woken up, if any.
 func traceReader() *g {
-	if trace.reader == 0 || (trace.fullHead == 0 && !trace.shutdown) {
+	if trace.reader == 0 || (trace.full.empty() && !trace.shutdown) {
 		return nil
 	}
 	lock(&trace.lock)
-	if trace.reader == 0 || (trace.fullHead == 0 && !trace.shutdown) {
+	if trace.reader == 0 || (trace.full.empty() && !trace.shutdown) {
 		unlock(&trace.lock)
 		return nil
 	}
@@ -471,38 +789,61 @@ func traceReader() *g {
 	return gp
 }
 
-// traceProcFree frees trace buffer associated with pp.
-func traceProcFree(pp *p) {
-	buf := pp.tracebuf
-	pp.tracebuf = 0
-	if buf == 0 {
-		return
+// traceFullQueue queues buf into queue of full buffers. Buffers of the
+// generation after the one traceAdvance is flushing wait in trace.fullNext
+// until it is done, so that the reader gets generations one at a time.
+func traceFullQueue(buf traceBufPtr) {
+	if buf.ptr().gen > trace.flushedGen+1 {
+		trace.fullNext.push(buf)
+	} else {
+		trace.full.push(buf)
 	}
-	lock(&trace.lock)
-	traceFullQueue(buf)
-	unlock(&trace.lock)
 }
 
-// traceFullQueue queues buf into queue of full buffers.
-func traceFullQueue(buf traceBufPtr) {
+// traceBufQueue is a queue of trace buffers.
+type traceBufQueue struct {
+	head, tail traceBufPtr
+}
+
+// empty reports whether q is empty.
+func (q *traceBufQueue) empty() bool {
+	return q.head == 0
+}
+
+// push adds buf to the end of q.
+func (q *traceBufQueue) push(buf traceBufPtr) {
 	buf.ptr().link = 0
-	if trace.fullHead == 0 {
-		trace.fullHead = buf
+	if q.head == 0 {
+		q.head = buf
 	} else {
-		trace.fullTail.ptr().link = buf
+		q.tail.ptr().link = buf
 	}
-	trace.fullTail = buf
+	q.tail = buf
 }
 
-// traceFullDequeue dequeues from queue of full buffers.
-func traceFullDequeue() traceBufPtr {
-	buf := trace.fullHead
+// pushAll moves the buffers of r to the end of q.
+func (q *traceBufQueue) pushAll(r *traceBufQueue) {
+	if r.head == 0 {
+		return
+	}
+	if q.head == 0 {
+		q.head = r.head
+	} else {
+		q.tail.ptr().link = r.head
+	}
+	q.tail = r.tail
+	r.head, r.tail = 0, 0
+}
+
+// pop removes and returns the buffer at the front of q, or 0 if q is empty.
+func (q *traceBufQueue) pop() traceBufPtr {
+	buf := q.head
 	if buf == 0 {
 		return 0
 	}
-	trace.fullHead = buf.ptr().link
-	if trace.fullHead == 0 {
-		trace.fullTail = 0
+	q.head = buf.ptr().link
+	if q.head == 0 {
+		q.tail = 0
 	}
 	buf.ptr().link = 0
 	return buf
@@ -541,13 +882,27 @@ func traceEvent(ev byte, skip int, args ...uint64) {
 }
 
 func traceEventLocked(extraBytes int, mp *m, pid int32, bufp *traceBufPtr, ev byte, skip int, args ...uint64) {
+	pp := mp.p.ptr()
+	if pid == traceGlobProc {
+		pp = nil
+	}
+	if pp != nil && pp.traceGen != mp.tracegen {
+		traceProcState(mp, pid, bufp, pp)
+	}
 	buf := bufp.ptr()
 	// TODO: test on non-zero extraBytes param.
 	maxSize := 2 + 5*traceBytesPerNumber + extraBytes // event type, length, sequence, timestamp, stack id and two add params
-	if buf == nil || len(buf.arr)-buf.pos < maxSize {
-		buf = traceFlush(traceBufPtrOf(buf), pid).ptr()
+	if buf == nil || len(buf.arr)-buf.pos < traceBatchHeaderSize+maxSize {
+		buf = traceFlush(traceBufPtrOf(buf), pid, mp.tracegen).ptr()
 		bufp.set(buf)
 	}
+	seq := &trace.batchSeq
+	if pp != nil {
+		seq = &pp.traceBatchSeq
+	}
+	if buf.pid != pid || buf.seq == 0 || buf.seq != *seq {
+		buf.batchStart(pid, seq)
+	}
 
 	ticks := uint64(cputicks()) / traceTickDiv
 	tickDiff := ticks - buf.lastTicks
@@ -586,36 +941,97 @@ func traceEventLocked(extraBytes int, mp *m, pid int32, bufp *traceBufPtr, ev by
 		// Fill in actual length.
 		*lenp = byte(evSize - 2)
 	}
+	if pp != nil {
+		traceProcUpdate(mp, pp, ev)
+	}
 }
 
+// traceLogicalStack is the first element of the stacks in the stack table
+// whose PCs are logical frames, as returned by callers. In the other
+// stacks, which were unwound by following frame pointers, the PCs are
+// return addresses of physical frames, and the first element is the number
+// of logical frames to skip once inlined frames have been expanded.
+const traceLogicalStack = ^uintptr(0)
+
+// traceStackID records the stack of mp's current goroutine,
+// skipping skip frames, and returns its ID in the stack table.
+//
+//go:noinline
 func traceStackID(mp *m, buf []uintptr, skip int) uint64 {
 	_g_ := getg()
 	gp := mp.curg
-	var nstk int
-	if gp == _g_ {
-		nstk = callers(skip+1, buf)
-	} else if gp != nil {
-		gp = mp.curg
-		nstk = gcallers(gp, skip, buf)
-	}
-	if nstk > 0 {
+	nstk := 1
+	if gp == _g_ && traceFPUnwind() {
+		// Following frame pointers is much faster than callers,
+		// but it cannot expand inlined frames, so that is left to
+		// traceStackTable.dump, along with skipping frames.
+		buf[0] = uintptr(skip)
+		nstk += fpTracebackPCs(unsafe.Pointer(getfp()), buf[1:])
+	} else {
+		buf[0] = traceLogicalStack
+		if gp == _g_ {
+			nstk += callers(skip+1, buf[1:])
+		} else if gp != nil {
+			nstk += gcallers(gp, skip, buf[1:])
+		}
+	}
+	if nstk > 1 {
 		nstk-- // skip runtime.goexit
 	}
-	if nstk > 0 && gp.goid == 1 {
+	if nstk > 1 && gp.goid == 1 {
 		nstk-- // skip runtime.main
 	}
-	id := trace.stackTab.put(buf[:nstk])
+	if nstk == 1 {
+		return 0
+	}
+	id := trace.stackTab[mp.tracegen%2].put(buf[:nstk])
 	return uint64(id)
 }
 
+// traceFPUnwind reports whether traceStackID can unwind the stack of
+// the current goroutine by following frame pointers. C code does not
+// reliably maintain frame pointers, so this is disabled for programs
+// that call C or, as on Windows, are called back from it.
+func traceFPUnwind() bool {
+	return framepointer_enabled && debug.tracefpunwindoff == 0 && !iscgo && GOOS != "windows"
+}
+
+// fpTracebackPCs follows the frame pointers starting at fp and
+// stores the return addresses it finds in pcBuf.
+func fpTracebackPCs(fp unsafe.Pointer, pcBuf []uintptr) (i int) {
+	for i = 0; i < len(pcBuf) && fp != nil; i++ {
+		// The return address sits one word above the frame pointer.
+		pcBuf[i] = *(*uintptr)(unsafe.Pointer(uintptr(fp) + sys.PtrSize))
+		// Follow the frame pointer to the next one.
+		fp = unsafe.Pointer(*(*uintptr)(fp))
+	}
+	return i
+}
+
 // traceAcquireBuffer returns trace buffer to use and, if necessary, locks it.
+//
+// Each M writes to its own buffer. The events of a P are written by
+// whichever M holds it, in batches ordered by p.traceBatchSeq, so the
+// reader can put them back in order without a global sequence. An M
+// without a P holds trace.bufLock to write its events.
+//
+// The M writes the events of the generation it sees here, into the
+// buffer for that generation, until the outermost traceReleaseBuffer.
+// m.traceseqlock is odd in between, which traceAdvance waits for.
 func traceAcquireBuffer() (mp *m, pid int32, bufp *traceBufPtr) {
 	mp = acquirem()
 	if p := mp.p.ptr(); p != nil {
-		return mp, p.id, &p.tracebuf
+		pid = p.id
+	} else {
+		pid = traceGlobProc
+		lock(&trace.bufLock)
 	}
-	lock(&trace.bufLock)
-	return mp, traceGlobProc, &trace.buf
+	if mp.tracelocks == 0 {
+		atomic.Xadd(&mp.traceseqlock, 1)
+		mp.tracegen = atomic.Load64(&trace.gen)
+	}
+	mp.tracelocks++
+	return mp, pid, &mp.tracebuf[mp.tracegen%2]
 }
 
 // traceReleaseBuffer releases a buffer previously acquired with traceAcquireBuffer.
@@ -623,11 +1039,17 @@ func traceReleaseBuffer(pid int32) {
 	if pid == traceGlobProc {
 		unlock(&trace.bufLock)
 	}
-	releasem(getg().m)
+	mp := getg().m
+	mp.tracelocks--
+	if mp.tracelocks == 0 {
+		atomic.Xadd(&mp.traceseqlock, 1)
+	}
+	releasem(mp)
 }
 
-// traceFlush puts buf onto stack of full buffers and returns an empty buffer.
-func traceFlush(buf traceBufPtr, pid int32) traceBufPtr {
+// traceFlush puts buf onto stack of full buffers and returns an empty buffer
+// for events of generation gen.
+func traceFlush(buf traceBufPtr, pid int32, gen uint64) traceBufPtr {
 	owner := trace.lockOwner
 	dolock := owner == nil || owner != getg().m.curg
 	if dolock {
@@ -649,11 +1071,16 @@ func traceFlush(buf traceBufPtr, pid int32) traceBufPtr {
 	bufp.link.set(nil)
 	bufp.pos = 0
 
-	// initialize the buffer for a new batch
+	// Initialize the buffer with a batch without events.
+	// traceEventLocked starts a new batch before writing events.
 	ticks := uint64(cputicks()) / traceTickDiv
 	bufp.lastTicks = ticks
-	bufp.byte(traceEvBatch | 1<<traceArgCountShift)
+	bufp.gen = gen
+	bufp.pid = pid
+	bufp.seq = 0
+	bufp.byte(traceEvBatch | 2<<traceArgCountShift)
 	bufp.varint(uint64(pid))
+	bufp.varint(0)
 	bufp.varint(ticks)
 
 	if dolock {
@@ -662,8 +1089,26 @@ func traceFlush(buf traceBufPtr, pid int32) traceBufPtr {
 	return buf
 }
 
-// traceString adds a string to the trace.strings and returns the id.
-func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr) {
+// traceBatchHeaderSize is the maximum size of a batch header.
+const traceBatchHeaderSize = 1 + 3*traceBytesPerNumber
+
+// batchStart starts a new batch of events of P pid in buf, taking its
+// sequence number from *seq, which is the batch sequence of the P.
+func (buf *traceBuf) batchStart(pid int32, seq *uint64) {
+	*seq++
+	ticks := uint64(cputicks()) / traceTickDiv
+	buf.lastTicks = ticks
+	buf.pid = pid
+	buf.seq = *seq
+	buf.byte(traceEvBatch | 2<<traceArgCountShift)
+	buf.varint(uint64(pid))
+	buf.varint(*seq)
+	buf.varint(ticks)
+}
+
+// traceString adds a string to the dictionary of generation gen
+// and returns the id.
+func traceString(bufp *traceBufPtr, pid int32, gen uint64, s string) (uint64, *traceBufPtr) {
 	if s == "" {
 		return 0, bufp
 	}
@@ -675,7 +1120,7 @@ func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr)
 		raceacquire(unsafe.Pointer(&trace.stringsLock))
 	}
 
-	if id, ok := trace.strings[s]; ok {
+	if id, ok := trace.strings[gen%2][s]; ok {
 		if raceenabled {
 			racerelease(unsafe.Pointer(&trace.stringsLock))
 		}
@@ -684,9 +1129,9 @@ func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr)
 		return id, bufp
 	}
 
-	trace.stringSeq++
-	id := trace.stringSeq
-	trace.strings[s] = id
+	trace.stringSeq[gen%2]++
+	id := trace.stringSeq[gen%2]
+	trace.strings[gen%2][s] = id
 
 	if raceenabled {
 		racerelease(unsafe.Pointer(&trace.stringsLock))
@@ -701,7 +1146,7 @@ func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr)
 	buf := bufp.ptr()
 	size := 1 + 2*traceBytesPerNumber + len(s)
 	if buf == nil || len(buf.arr)-buf.pos < size {
-		buf = traceFlush(traceBufPtrOf(buf), pid).ptr()
+		buf = traceFlush(traceBufPtrOf(buf), pid, gen).ptr()
 		bufp.set(buf)
 	}
 	buf.byte(traceEvString)
@@ -781,7 +1226,8 @@ func (tab *traceStackTable) put(pcs []uintptr) uint32 {
 	if len(pcs) == 0 {
 		return 0
 	}
-	hash := memhash(unsafe.Pointer(&pcs[0]), 0, uintptr(len(pcs))*unsafe.Sizeof(pcs[0]))
+	// pcs does not escape, so that callers can pass stack memory.
+	hash := memhash(noescape(unsafe.Pointer(&pcs[0])), 0, uintptr(len(pcs))*unsafe.Sizeof(pcs[0]))
 	// First, search the hashtable w/o the mutex.
 	if id := tab.find(pcs, hash); id != 0 {
 		return id
@@ -831,6 +1277,22 @@ func (tab *traceStackTable) newStack(n int) *traceStack {
 	return (*traceStack)(tab.mem.alloc(unsafe.Sizeof(traceStack{}) + uintptr(n)*sys.PtrSize))
 }
 
+// traceExpandStack returns the frames of a stack recorded by traceStackID.
+func traceExpandStack(pcs []uintptr) []Frame {
+	if len(pcs) == 0 {
+		return nil
+	}
+	skip := pcs[0]
+	frames := allFrames(pcs[1:])
+	if skip != traceLogicalStack {
+		if skip > uintptr(len(frames)) {
+			skip = uintptr(len(frames))
+		}
+		frames = frames[skip:]
+	}
+	return frames
+}
+
 // allFrames returns all of the Frames corresponding to pcs.
 func allFrames(pcs []uintptr) []Frame {
 	frames := make([]Frame, 0, len(pcs))
@@ -844,21 +1306,21 @@ func allFrames(pcs []uintptr) []Frame {
 	}
 }
 
-// dump writes all previously cached stacks to trace buffers,
-// releases all memory and resets state.
-func (tab *traceStackTable) dump() {
+// dump writes all previously cached stacks to trace buffers of
+// generation gen, releases all memory and resets state.
+func (tab *traceStackTable) dump(gen uint64) {
 	var tmp [(2 + 4*traceStackSize) * traceBytesPerNumber]byte
-	bufp := traceFlush(0, 0)
+	bufp := traceFlush(0, 0, gen)
 	for _, stk := range tab.tab {
 		stk := stk.ptr()
 		for ; stk != nil; stk = stk.link.ptr() {
 			tmpbuf := tmp[:0]
 			tmpbuf = traceAppend(tmpbuf, uint64(stk.id))
-			frames := allFrames(stk.stack())
+			frames := traceExpandStack(stk.stack())
 			tmpbuf = traceAppend(tmpbuf, uint64(len(frames)))
 			for _, f := range frames {
 				var frame traceFrame
-				frame, bufp = traceFrameForPC(bufp, 0, f)
+				frame, bufp = traceFrameForPC(bufp, 0, gen, f)
 				tmpbuf = traceAppend(tmpbuf, uint64(f.PC))
 				tmpbuf = traceAppend(tmpbuf, uint64(frame.funcID))
 				tmpbuf = traceAppend(tmpbuf, uint64(frame.fileID))
@@ -867,7 +1329,7 @@ func (tab *traceStackTable) dump() {
 			// Now copy to the buffer.
 			size := 1 + traceBytesPerNumber + len(tmpbuf)
 			if buf := bufp.ptr(); len(buf.arr)-buf.pos < size {
-				bufp = traceFlush(bufp, 0)
+				bufp = traceFlush(bufp, 0, gen)
 			}
 			buf := bufp.ptr()
 			buf.byte(traceEvStack | 3<<traceArgCountShift)
@@ -893,7 +1355,7 @@ type traceFrame struct {
 
 // traceFrameForPC records the frame information.
 // It may allocate memory.
-func traceFrameForPC(buf traceBufPtr, pid int32, f Frame) (traceFrame, traceBufPtr) {
+func traceFrameForPC(buf traceBufPtr, pid int32, gen uint64, f Frame) (traceFrame, traceBufPtr) {
 	bufp := &buf
 	var frame traceFrame
 
@@ -902,13 +1364,13 @@ func traceFrameForPC(buf traceBufPtr, pid int32, f Frame) (traceFrame, traceBufP
 	if len(fn) > maxLen {
 		fn = fn[len(fn)-maxLen:]
 	}
-	frame.funcID, bufp = traceString(bufp, pid, fn)
+	frame.funcID, bufp = traceString(bufp, pid, gen, fn)
 	frame.line = uint64(f.Line)
 	file := f.File
 	if len(file) > maxLen {
 		file = file[len(file)-maxLen:]
 	}
-	frame.fileID, bufp = traceString(bufp, pid, file)
+	frame.fileID, bufp = traceString(bufp, pid, gen, file)
 	return frame, (*bufp)
 }
 
@@ -1052,26 +1514,53 @@ func traceGCMarkAssistDone() {
 	traceEvent(traceEvGCMarkAssistDone, -1)
 }
 
+// The functions below that write events about a goroutine other than
+// the running one call traceEventLocked directly, so that they can first
+// write the state of the goroutine with traceGoState. Their skip is that
+// of traceEvent, as they replace its frame.
+
 func traceGoCreate(newg *g, pc uintptr) {
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
+	// Lock newg, which may be a dead goroutine that traceGoSnapshot
+	// is looking at, so that it does not describe newg as well.
+	gen := traceGoLock(newg)
+	if gen < uint32(mp.tracegen) {
+		gen = uint32(mp.tracegen)
+	}
 	newg.traceseq = 0
-	newg.tracelastp = getg().m.p
+	newg.tracelastp = mp.p
+	newg.tracestatus = traceGoStatusRunnable
 	// +PCQuantum because traceFrameForPC expects return PCs and subtracts PCQuantum.
-	id := trace.stackTab.put([]uintptr{pc + sys.PCQuantum})
-	traceEvent(traceEvGoCreate, 2, uint64(newg.goid), uint64(id))
+	id := trace.stackTab[mp.tracegen%2].put([]uintptr{traceLogicalStack, pc + sys.PCQuantum})
+	traceEventLocked(0, mp, pid, bufp, traceEvGoCreate, 2, uint64(newg.goid), uint64(id))
+	traceGoUnlock(newg, gen)
+	traceReleaseBuffer(pid)
 }
 
 func traceGoStart() {
-	_g_ := getg().m.curg
-	_p_ := _g_.m.p
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
+	_g_ := mp.curg
+	_p_ := mp.p
+	traceGoState(mp, pid, bufp, _g_)
 	_g_.traceseq++
 	if _p_.ptr().gcMarkWorkerMode != gcMarkWorkerNotWorker {
-		traceEvent(traceEvGoStartLabel, -1, uint64(_g_.goid), _g_.traceseq, trace.markWorkerLabels[_p_.ptr().gcMarkWorkerMode])
+		label := trace.markWorkerLabels[mp.tracegen%2][_p_.ptr().gcMarkWorkerMode]
+		traceEventLocked(0, mp, pid, bufp, traceEvGoStartLabel, -1, uint64(_g_.goid), _g_.traceseq, label)
 	} else if _g_.tracelastp == _p_ {
-		traceEvent(traceEvGoStartLocal, -1, uint64(_g_.goid))
+		traceEventLocked(0, mp, pid, bufp, traceEvGoStartLocal, -1, uint64(_g_.goid))
 	} else {
 		_g_.tracelastp = _p_
-		traceEvent(traceEvGoStart, -1, uint64(_g_.goid), _g_.traceseq)
+		traceEventLocked(0, mp, pid, bufp, traceEvGoStart, -1, uint64(_g_.goid), _g_.traceseq)
 	}
+	traceReleaseBuffer(pid)
 }
 
 func traceGoEnd() {
@@ -1098,14 +1587,22 @@ func traceGoPark(traceEv byte, skip int) {
 }
 
 func traceGoUnpark(gp *g, skip int) {
-	_p_ := getg().m.p
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
+	_p_ := mp.p
+	traceGoState(mp, pid, bufp, gp)
 	gp.traceseq++
+	gp.tracestatus = traceGoStatusRunnable
 	if gp.tracelastp == _p_ {
-		traceEvent(traceEvGoUnblockLocal, skip, uint64(gp.goid))
+		traceEventLocked(0, mp, pid, bufp, traceEvGoUnblockLocal, skip, uint64(gp.goid))
 	} else {
 		gp.tracelastp = _p_
-		traceEvent(traceEvGoUnblock, skip, uint64(gp.goid), gp.traceseq)
+		traceEventLocked(0, mp, pid, bufp, traceEvGoUnblock, skip, uint64(gp.goid), gp.traceseq)
 	}
+	traceReleaseBuffer(pid)
 }
 
 func traceGoSysCall() {
@@ -1113,7 +1610,14 @@ func traceGoSysCall() {
 }
 
 func traceGoSysExit(ts int64) {
-	if ts != 0 && ts < trace.ticksStart {
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
+	_g_ := mp.curg
+	traceGoState(mp, pid, bufp, _g_)
+	if ts != 0 && (ts < trace.ticksStart || _g_.sysexitticks == 0) {
 		// There is a race between the code that initializes sysexitticks
 		// (in exitsyscall, which runs without a P, and therefore is not
 		// stopped with the rest of the world) and the code that initializes
@@ -1123,12 +1627,16 @@ func traceGoSysExit(ts int64) {
 		// trace, assume that the trace was started after the actual syscall
 		// exit (but before we actually managed to start the goroutine,
 		// aka right now), and assign a fresh time stamp to keep the log consistent.
+		// The same goes if traceGoState has just written the syscall state
+		// of the goroutine, which clears sysexitticks, as the syscall may
+		// have returned before that.
 		ts = 0
 	}
-	_g_ := getg().m.curg
 	_g_.traceseq++
-	_g_.tracelastp = _g_.m.p
-	traceEvent(traceEvGoSysExit, -1, uint64(_g_.goid), _g_.traceseq, uint64(ts)/traceTickDiv)
+	_g_.tracelastp = mp.p
+	_g_.tracestatus = traceGoStatusRunnable
+	traceEventLocked(0, mp, pid, bufp, traceEvGoSysExit, -1, uint64(_g_.goid), _g_.traceseq, uint64(ts)/traceTickDiv)
+	traceReleaseBuffer(pid)
 }
 
 func traceGoSysBlock(pp *p) {
@@ -1171,7 +1679,7 @@ func trace_userTaskCreate(id, parentID uint64, taskType string) {
 		return
 	}
 
-	typeStringID, bufp := traceString(bufp, pid, taskType)
+	typeStringID, bufp := traceString(bufp, pid, mp.tracegen, taskType)
 	traceEventLocked(0, mp, pid, bufp, traceEvUserTaskCreate, 3, id, parentID, typeStringID)
 	traceReleaseBuffer(pid)
 }
@@ -1193,7 +1701,7 @@ func trace_userRegion(id, mode uint64, name string) {
 		return
 	}
 
-	nameStringID, bufp := traceString(bufp, pid, name)
+	nameStringID, bufp := traceString(bufp, pid, mp.tracegen, name)
 	traceEventLocked(0, mp, pid, bufp, traceEvUserRegion, 3, id, mode, nameStringID)
 	traceReleaseBuffer(pid)
 }
@@ -1210,7 +1718,7 @@ func trace_userLog(id uint64, category, message string) {
 		return
 	}
 
-	categoryID, bufp := traceString(bufp, pid, category)
+	categoryID, bufp := traceString(bufp, pid, mp.tracegen, category)
 
 	extraSpace := traceBytesPerNumber + len(message) // extraSpace for the value string
 	traceEventLocked(extraSpace, mp, pid, bufp, traceEvUserLog, 3, id, categoryID)
@@ -0,0 +1,288 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+	_ "unsafe"
+)
+
+// FlightRecorderConfig configures a FlightRecorder.
+type FlightRecorderConfig struct {
+	// MinAge is a lower bound on the age of the trace data
+	// the flight recorder retains: a snapshot covers at least the
+	// last MinAge of execution, unless MaxBytes is reached first.
+	// If zero, it defaults to 10 seconds.
+	MinAge time.Duration
+
+	// MaxBytes is an upper bound on the size of the trace data
+	// the flight recorder retains. It takes precedence over MinAge.
+	// If zero, it defaults to 10 MiB.
+	MaxBytes uint64
+}
+
+// A FlightRecorder traces the program continuously but only keeps the
+// most recent trace data in memory. When something interesting happens,
+// such as a slow request or a failed health check, the program can write
+// out a snapshot of the recent past with WriteTo.
+//
+// The trace is recorded in generations, each of which can be parsed
+// without the ones before it. The flight recorder starts a new
+// generation about every second, and drops the oldest generations
+// once they are no longer needed to satisfy MinAge or exceed MaxBytes.
+//
+// Only one trace, whether started by Start or by a FlightRecorder,
+// can be running at a time.
+type FlightRecorder struct {
+	cfg FlightRecorderConfig
+
+	writing sync.Mutex // serializes WriteTo and Stop
+
+	mu      sync.Mutex
+	cond    sync.Cond // signaled when the reader has read more data
+	running bool
+	reading bool          // reader goroutine is running
+	header  []byte        // trace header
+	gens    []*generation // complete generations, oldest first
+	cur     *generation   // generation being read
+	size    uint64        // size of gens
+	stop    chan struct{} // closed to stop the advancer goroutine
+	full    chan struct{} // signals the advancer that cur is large
+	done    chan struct{} // closed when the reader goroutine exits
+}
+
+// A generation is the data of one trace generation,
+// as returned by the runtime.
+type generation struct {
+	num   uint64
+	start time.Time
+	data  [][]byte
+	size  uint64
+}
+
+// NewFlightRecorder returns a new, stopped flight recorder
+// configured by cfg.
+func NewFlightRecorder(cfg FlightRecorderConfig) *FlightRecorder {
+	if cfg.MinAge <= 0 {
+		cfg.MinAge = 10 * time.Second
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = 10 << 20
+	}
+	fr := &FlightRecorder{cfg: cfg}
+	fr.cond.L = &fr.mu
+	return fr
+}
+
+// Start starts recording. It returns an error if the flight recorder
+// is already running or if tracing is already enabled.
+func (fr *FlightRecorder) Start() error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if fr.running {
+		return errors.New("trace: flight recorder already running")
+	}
+
+	tracing.Lock()
+	defer tracing.Unlock()
+	if err := runtime.StartTrace(); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&tracing.enabled, 1)
+
+	fr.running = true
+	fr.reading = true
+	fr.header = nil
+	fr.gens = nil
+	fr.cur = nil
+	fr.size = 0
+	fr.stop = make(chan struct{})
+	fr.full = make(chan struct{}, 1)
+	fr.done = make(chan struct{})
+	go fr.read()
+	go fr.advance(fr.stop, fr.full)
+	return nil
+}
+
+// Stop stops recording and discards the recorded data.
+// It waits for any call to WriteTo to complete.
+func (fr *FlightRecorder) Stop() {
+	fr.writing.Lock()
+	defer fr.writing.Unlock()
+
+	fr.mu.Lock()
+	if !fr.running {
+		fr.mu.Unlock()
+		return
+	}
+	fr.running = false
+	close(fr.stop)
+	fr.mu.Unlock()
+
+	tracing.Lock()
+	atomic.StoreInt32(&tracing.enabled, 0)
+	runtime.StopTrace()
+	tracing.Unlock()
+	<-fr.done
+
+	fr.mu.Lock()
+	fr.header = nil
+	fr.gens = nil
+	fr.cur = nil
+	fr.size = 0
+	fr.mu.Unlock()
+}
+
+// Enabled reports whether the flight recorder is running.
+func (fr *FlightRecorder) Enabled() bool {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.running
+}
+
+// WriteTo writes a snapshot of the recorded trace data to w.
+// The snapshot is a complete trace that ends at the time of the call.
+// It returns an error if the flight recorder is not running.
+// Calls to WriteTo are serialized.
+func (fr *FlightRecorder) WriteTo(w io.Writer) (n int64, err error) {
+	fr.writing.Lock()
+	defer fr.writing.Unlock()
+
+	if !fr.Enabled() {
+		return 0, errors.New("trace: flight recorder is not running")
+	}
+	// End the current generation and wait for the reader
+	// to receive all of its data.
+	gen := runtime_traceAdvance()
+	fr.mu.Lock()
+	for fr.reading && gen != 0 && (fr.cur == nil || fr.cur.num < gen) {
+		fr.cond.Wait()
+	}
+	if !fr.reading || gen == 0 {
+		fr.mu.Unlock()
+		return 0, errors.New("trace: tracing stopped during flight recorder WriteTo")
+	}
+	// Copy the generations, as complete modifies fr.gens
+	// and its backing array while we write.
+	header := fr.header
+	gens := append([]*generation(nil), fr.gens...)
+	fr.mu.Unlock()
+
+	m, err := w.Write(header)
+	n += int64(m)
+	for _, g := range gens {
+		for _, data := range g.data {
+			if err != nil {
+				return n, err
+			}
+			m, err = w.Write(data)
+			n += int64(m)
+		}
+	}
+	return n, err
+}
+
+// read reads trace data from the runtime until tracing stops.
+func (fr *FlightRecorder) read() {
+	for {
+		data, gen := runtime_readTrace()
+		if data == nil {
+			break
+		}
+		// The runtime reuses the buffer on the next call.
+		data = append([]byte(nil), data...)
+
+		fr.mu.Lock()
+		if gen == 0 {
+			fr.header = data
+		} else {
+			if fr.cur != nil && fr.cur.num != gen {
+				fr.complete()
+			}
+			if fr.cur == nil {
+				fr.cur = &generation{num: gen, start: time.Now()}
+			}
+			fr.cur.data = append(fr.cur.data, data)
+			fr.cur.size += uint64(len(data))
+			if fr.cur.size > fr.cfg.MaxBytes/4 {
+				select {
+				case fr.full <- struct{}{}:
+				default:
+				}
+			}
+		}
+		fr.cond.Broadcast()
+		fr.mu.Unlock()
+	}
+
+	fr.mu.Lock()
+	fr.reading = false
+	fr.cond.Broadcast()
+	fr.mu.Unlock()
+	close(fr.done)
+}
+
+// complete adds the current generation to the complete ones and drops
+// the oldest generations that are no longer needed. It always keeps
+// the newest complete generation. fr.mu must be held.
+func (fr *FlightRecorder) complete() {
+	fr.gens = append(fr.gens, fr.cur)
+	fr.size += fr.cur.size
+	fr.cur = nil
+	for len(fr.gens) > 1 {
+		if fr.size <= fr.cfg.MaxBytes && time.Since(fr.gens[1].start) < fr.cfg.MinAge {
+			break
+		}
+		fr.size -= fr.gens[0].size
+		fr.gens[0] = nil
+		fr.gens = fr.gens[1:]
+	}
+}
+
+// advance periodically starts a new trace generation, and early when the
+// current one grows large, so that old data can be dropped in small steps.
+func (fr *FlightRecorder) advance(stop, full <-chan struct{}) {
+	const minPeriod = 10 * time.Millisecond
+	period := time.Second
+	if p := fr.cfg.MinAge / 2; p < period {
+		period = p
+	}
+	if period < minPeriod {
+		period = minPeriod
+	}
+	t := time.NewTicker(period)
+	defer t.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		case <-full:
+			// Each generation has a fixed cost, so don't start
+			// them too often, however small MaxBytes is.
+			if time.Since(last) < minPeriod {
+				continue
+			}
+		}
+		runtime_traceAdvance()
+		last = time.Now()
+	}
+}
+
+// Function bodies are defined in runtime/trace.go.
+
+// runtime_readTrace is runtime.ReadTrace, but also returns
+// the generation of the data, or 0 for the trace header.
+func runtime_readTrace() ([]byte, uint64)
+
+// runtime_traceAdvance starts a new trace generation and returns its
+// number, or 0 if tracing is not enabled.
+func runtime_traceAdvance() uint64
@@ -0,0 +1,239 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace_test
+
+import (
+	"bytes"
+	"context"
+	"internal/trace"
+	"io"
+	"runtime"
+	"runtime/debug"
+	. "runtime/trace"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	fr := NewFlightRecorder(FlightRecorderConfig{MinAge: time.Hour})
+	if err := fr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Stop()
+	if !fr.Enabled() {
+		t.Fatal("flight recorder is not enabled after Start")
+	}
+	if err := Start(new(bytes.Buffer)); err == nil {
+		Stop()
+		t.Fatal("Start succeeded while the flight recorder is running")
+	}
+
+	// Spread some work over several generations, with a task
+	// that starts in one and ends in another.
+	ctx, task := NewTask(context.Background(), "flight")
+	for i := 0; i < 3; i++ {
+		pingPong(100)
+		var buf bytes.Buffer
+		if _, err := fr.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		res, err := trace.Parse(&buf, "")
+		if err != nil {
+			t.Fatalf("snapshot %d: %v", i, err)
+		}
+		if len(res.Events) == 0 {
+			t.Fatalf("snapshot %d has no events", i)
+		}
+	}
+	Log(ctx, "key", "value")
+	task.End()
+
+	var buf bytes.Buffer
+	if _, err := fr.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	res, err := trace.Parse(&buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, ev := range res.Events {
+		if ev.Type == trace.EvUserLog && ev.SArgs[0] == "key" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("snapshot is missing the logged event")
+	}
+}
+
+func TestFlightRecorderMaxBytes(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	fr := NewFlightRecorder(FlightRecorderConfig{MinAge: time.Hour, MaxBytes: 1})
+	if err := fr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Stop()
+
+	// Only the latest generation is kept, so an event
+	// is in the next snapshot but not in the one after.
+	Log(context.Background(), "old", "")
+	for i := 0; i < 2; i++ {
+		pingPong(100)
+		var buf bytes.Buffer
+		if _, err := fr.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		res, err := trace.Parse(&buf, "")
+		if err != nil {
+			t.Fatalf("snapshot %d: %v", i, err)
+		}
+		found := false
+		for _, ev := range res.Events {
+			if ev.Type == trace.EvUserLog && ev.SArgs[0] == "old" {
+				found = true
+			}
+		}
+		if found != (i == 0) {
+			t.Errorf("snapshot %d: found old event = %v, want %v", i, found, i == 0)
+		}
+	}
+	fr.Stop()
+	if fr.Enabled() {
+		t.Error("flight recorder is enabled after Stop")
+	}
+	if _, err := fr.WriteTo(new(bytes.Buffer)); err == nil {
+		t.Error("WriteTo succeeded after Stop")
+	}
+	// The flight recorder can be restarted.
+	if err := fr.Start(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A slowWriter is slow to write, so that the flight recorder completes
+// and drops generations while WriteTo is writing.
+type slowWriter struct {
+	bytes.Buffer
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(100 * time.Millisecond)
+	return w.Buffer.Write(p)
+}
+
+func TestFlightRecorderSlowWriter(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	fr := NewFlightRecorder(FlightRecorderConfig{MinAge: 50 * time.Millisecond})
+	if err := fr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Stop()
+	pingPong(100)
+	var w slowWriter
+	if _, err := fr.WriteTo(&w); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := trace.Parse(&w.Buffer, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFlightRecorderPause checks that starting a new generation does not
+// stop the world: a spinning goroutine keeps running while the state of
+// many parked goroutines is written out.
+func TestFlightRecorderPause(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	if testing.Short() {
+		t.Skip("skipping in -short mode")
+	}
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+
+	const n = 100000
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-stop
+		}()
+	}
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	fr := NewFlightRecorder(FlightRecorderConfig{MinAge: time.Hour})
+	if err := fr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Stop()
+
+	// Record the longest time the spinner did not run.
+	var maxGap time.Duration
+	var done int32
+	spun := make(chan struct{})
+	go func() {
+		defer close(spun)
+		last := time.Now()
+		for atomic.LoadInt32(&done) == 0 {
+			now := time.Now()
+			if gap := now.Sub(last); gap > maxGap {
+				maxGap = gap
+			}
+			last = now
+		}
+	}()
+	var advance time.Duration
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		if _, err := fr.WriteTo(io.Discard); err != nil {
+			t.Fatal(err)
+		}
+		if d := time.Since(start); d > advance {
+			advance = d
+		}
+	}
+	atomic.StoreInt32(&done, 1)
+	<-spun
+
+	// A stop around the snapshot would pause the spinner for about
+	// as long as the whole advance.
+	if maxGap > advance/2 {
+		t.Errorf("goroutine paused for %v while a generation advance took %v", maxGap, advance)
+	}
+}
+
+// pingPong passes a value back and forth n times between two goroutines.
+func pingPong(n int) {
+	var wg sync.WaitGroup
+	c := make(chan int)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for v := range c {
+			c <- v
+		}
+	}()
+	for i := 0; i < n; i++ {
+		c <- i
+		<-c
+	}
+	close(c)
+	wg.Wait()
+}
@@ -12,7 +12,7 @@
 // GC-related events, changes of heap size, processor start/stop, etc.
 // A precise nanosecond-precision timestamp and a stack trace is
 // captured for most events. The generated trace can be interpreted
-// using `go tool trace`.
+// using `go tool trace`, or read by programs with the debug/trace package.
 //
 // Support for tracing tests and benchmarks built with the standard
 // testing package is built into `go test`. For example, the following
@@ -34,6 +34,13 @@
 // See the net/http/pprof package for more details about all of the
 // debug endpoints installed by this import.
 //
+// Flight recording
+//
+// Tracing a long-running program produces more data than can be kept.
+// A FlightRecorder keeps tracing the program but only holds on to the
+// last few seconds of the trace, which it writes out on request, for
+// example after the program notices that a request was slow.
+//
 // User annotation
 //
 // Package trace provides user annotation APIs that can be used to
@@ -1428,6 +1428,141 @@ func TestUnaligned64(t *testing.T) {
 	shouldPanic(t, "AddUint64", func() { AddUint64(p, 3) })
 }
 
+func TestTypes(t *testing.T) {
+	var b Bool
+	if b.Load() || b.Swap(true) || !b.Load() {
+		t.Errorf("Bool: Load/Swap/Load = false/false/true failed")
+	}
+	if b.CompareAndSwap(false, true) || !b.CompareAndSwap(true, false) || b.Load() {
+		t.Errorf("Bool: CompareAndSwap failed")
+	}
+	b.Store(true)
+	if !b.Load() {
+		t.Errorf("Bool: Store(true) didn't store true")
+	}
+
+	var i32 Int32
+	if i32.Add(-2) != -2 || i32.Swap(3) != -2 || i32.Load() != 3 {
+		t.Errorf("Int32: Add/Swap/Load = -2/-2/3 failed")
+	}
+	if i32.CompareAndSwap(-2, 4) || !i32.CompareAndSwap(3, 4) || i32.Load() != 4 {
+		t.Errorf("Int32: CompareAndSwap failed")
+	}
+	i32.Store(5)
+	if i32.Load() != 5 {
+		t.Errorf("Int32: Store(5) didn't store 5")
+	}
+
+	var i64 Int64
+	if i64.Add(-magic64) != -magic64 || i64.Swap(3) != -magic64 || i64.Load() != 3 {
+		t.Errorf("Int64: Add/Swap/Load failed")
+	}
+	if i64.CompareAndSwap(-magic64, 4) || !i64.CompareAndSwap(3, magic64) || i64.Load() != magic64 {
+		t.Errorf("Int64: CompareAndSwap failed")
+	}
+	i64.Store(5)
+	if i64.Load() != 5 {
+		t.Errorf("Int64: Store(5) didn't store 5")
+	}
+
+	var u32 Uint32
+	if u32.Add(magic32) != magic32 || u32.Swap(3) != magic32 || u32.Load() != 3 {
+		t.Errorf("Uint32: Add/Swap/Load failed")
+	}
+	if u32.CompareAndSwap(magic32, 4) || !u32.CompareAndSwap(3, 4) || u32.Load() != 4 {
+		t.Errorf("Uint32: CompareAndSwap failed")
+	}
+	u32.Store(5)
+	if u32.Load() != 5 {
+		t.Errorf("Uint32: Store(5) didn't store 5")
+	}
+
+	var u64 Uint64
+	if u64.Add(magic64) != magic64 || u64.Swap(3) != magic64 || u64.Load() != 3 {
+		t.Errorf("Uint64: Add/Swap/Load failed")
+	}
+	if u64.CompareAndSwap(magic64, 4) || !u64.CompareAndSwap(3, magic64) || u64.Load() != magic64 {
+		t.Errorf("Uint64: CompareAndSwap failed")
+	}
+	u64.Store(5)
+	if u64.Load() != 5 {
+		t.Errorf("Uint64: Store(5) didn't store 5")
+	}
+
+	var up Uintptr
+	const magicptr = uintptr(magic32)
+	if up.Add(magicptr) != magicptr || up.Swap(3) != magicptr || up.Load() != 3 {
+		t.Errorf("Uintptr: Add/Swap/Load failed")
+	}
+	if up.CompareAndSwap(magicptr, 4) || !up.CompareAndSwap(3, 4) || up.Load() != 4 {
+		t.Errorf("Uintptr: CompareAndSwap failed")
+	}
+	up.Store(5)
+	if up.Load() != 5 {
+		t.Errorf("Uintptr: Store(5) didn't store 5")
+	}
+
+	var p Pointer
+	x, y := new(int), new(int)
+	if p.Load() != nil || p.Swap(unsafe.Pointer(x)) != nil || p.Load() != unsafe.Pointer(x) {
+		t.Errorf("Pointer: Load/Swap/Load failed")
+	}
+	if p.CompareAndSwap(nil, unsafe.Pointer(y)) || !p.CompareAndSwap(unsafe.Pointer(x), unsafe.Pointer(y)) || p.Load() != unsafe.Pointer(y) {
+		t.Errorf("Pointer: CompareAndSwap failed")
+	}
+	p.Store(nil)
+	if p.Load() != nil {
+		t.Errorf("Pointer: Store(nil) didn't store nil")
+	}
+}
+
+type align64Test struct {
+	a uint32
+	x Int64
+	b uint32
+	y Uint64
+}
+
+var align64Global align64Test
+
+func TestAligned64(t *testing.T) {
+	// Int64 and Uint64 must be 64-bit aligned wherever they are,
+	// including on 32-bit systems where int64 is only 32-bit aligned.
+	if a := unsafe.Alignof(Int64{}); a != 8 {
+		t.Errorf("Alignof(Int64{}) = %d, want 8", a)
+	}
+	if a := unsafe.Alignof(Uint64{}); a != 8 {
+		t.Errorf("Alignof(Uint64{}) = %d, want 8", a)
+	}
+	var local align64Test
+	for _, tt := range []struct {
+		name string
+		v    *align64Test
+	}{
+		{"global", &align64Global},
+		{"local", &local},
+		{"heap", new(align64Test)},
+		{"slice element", &make([]align64Test, 3)[1]},
+	} {
+		if p := uintptr(unsafe.Pointer(&tt.v.x)); p%8 != 0 {
+			t.Errorf("%s: Int64 at %#x is not 64-bit aligned", tt.name, p)
+		}
+		if p := uintptr(unsafe.Pointer(&tt.v.y)); p%8 != 0 {
+			t.Errorf("%s: Uint64 at %#x is not 64-bit aligned", tt.name, p)
+		}
+		tt.v.x.Add(1)
+		tt.v.y.Add(1)
+	}
+
+	// A variable that doesn't otherwise escape can't be
+	// left on the stack, which is only pointer-aligned.
+	var x Int64
+	if p := uintptr(unsafe.Pointer(&x)); p%8 != 0 {
+		t.Errorf("local Int64 at %#x is not 64-bit aligned", p)
+	}
+	x.Add(1)
+}
+
 func TestNilDeref(t *testing.T) {
 	funcs := [...]func(){
 		func() { CompareAndSwapInt32(nil, 0, 0) },
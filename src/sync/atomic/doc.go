@@ -37,6 +37,10 @@
 // functions, are the atomic equivalents of "return *addr" and
 // "*addr = val".
 //
+// The Bool, Int32, Int64, Uint32, Uint64, Uintptr and Pointer types
+// provide the same operations as methods on a value of the type,
+// which can't be accessed non-atomically by mistake.
+//
 package atomic
 
 import (
@@ -50,7 +54,8 @@ import (
 // On ARM, 386, and 32-bit MIPS, it is the caller's responsibility
 // to arrange for 64-bit alignment of 64-bit words accessed atomically.
 // The first word in a variable or in an allocated struct, array, or slice can
-// be relied upon to be 64-bit aligned.
+// be relied upon to be 64-bit aligned. The Int64 and Uint64 types are
+// always 64-bit aligned, wherever they are placed.
 
 // SwapInt32 atomically stores new into *addr and returns the previous *addr value.
 func SwapInt32(addr *int32, new int32) (old int32)
@@ -5,6 +5,7 @@
 package atomic_test
 
 import (
+	"fmt"
 	"math/rand"
 	"runtime"
 	. "sync/atomic"
@@ -76,6 +77,59 @@ func TestValuePanic(t *testing.T) {
 	}()
 }
 
+func TestValueSwap(t *testing.T) {
+	var v Value
+	if old := v.Swap(42); old != nil {
+		t.Fatalf("Swap on empty Value = %v, want nil", old)
+	}
+	if old := v.Swap(84); old != 42 {
+		t.Fatalf("Swap = %v, want 42", old)
+	}
+	if x := v.Load(); x != 84 {
+		t.Fatalf("Load after Swap = %v, want 84", x)
+	}
+	valueShouldPanic(t, "Swap(nil)", "sync/atomic: swap of nil value into Value", func() { v.Swap(nil) })
+	valueShouldPanic(t, "Swap(string)", "sync/atomic: swap of inconsistently typed value into Value", func() { v.Swap("foo") })
+}
+
+func TestValueCompareAndSwap(t *testing.T) {
+	var v Value
+	if v.CompareAndSwap(42, 84) {
+		t.Fatal("CompareAndSwap(42, 84) on empty Value succeeded")
+	}
+	if !v.CompareAndSwap(nil, 42) {
+		t.Fatal("CompareAndSwap(nil, 42) on empty Value failed")
+	}
+	if v.CompareAndSwap(nil, 84) || v.CompareAndSwap(84, 126) {
+		t.Fatal("CompareAndSwap with wrong old value succeeded")
+	}
+	if !v.CompareAndSwap(42, 84) {
+		t.Fatal("CompareAndSwap(42, 84) failed")
+	}
+	if x := v.Load(); x != 84 {
+		t.Fatalf("Load after CompareAndSwap = %v, want 84", x)
+	}
+	valueShouldPanic(t, "CompareAndSwap(84, nil)", "sync/atomic: compare and swap of nil value into Value", func() { v.CompareAndSwap(84, nil) })
+	valueShouldPanic(t, "CompareAndSwap(84, string)", "sync/atomic: compare and swap of inconsistently typed values", func() { v.CompareAndSwap(84, "foo") })
+	valueShouldPanic(t, "CompareAndSwap(string, string)", "sync/atomic: compare and swap of inconsistently typed value into Value", func() { v.CompareAndSwap("foo", "bar") })
+
+	var w Value
+	w.Store([]int{1})
+	valueShouldPanic(t, "CompareAndSwap(slice, slice)", "runtime error: comparing uncomparable type []int", func() { w.CompareAndSwap([]int{1}, []int{2}) })
+}
+
+func valueShouldPanic(t *testing.T, name, want string, f func()) {
+	defer func() {
+		err := recover()
+		if err == nil {
+			t.Errorf("%s did not panic", name)
+		} else if s := fmt.Sprint(err); s != want {
+			t.Errorf("%s: wanted panic %q, got %q", name, want, s)
+		}
+	}()
+	f()
+}
+
 func TestValueConcurrent(t *testing.T) {
 	tests := [][]interface{}{
 		{uint16(0), ^uint16(0), uint16(1 + 2<<8), uint16(3 + 4<<8)},
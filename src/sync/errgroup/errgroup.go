@@ -0,0 +1,197 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package errgroup provides synchronization, error propagation, and
+// Context cancellation for groups of goroutines working on subtasks
+// of a common task.
+//
+// A Group is like a sync.WaitGroup whose goroutines return errors.
+// The Context of a Group created by WithContext is canceled when one
+// of its goroutines fails, and the Group is traced as a runtime/trace
+// task, with a region for each of its goroutines.
+package errgroup
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"runtime/trace"
+	"sync"
+)
+
+// A Group is a collection of goroutines working on subtasks that are part of
+// the same overall task.
+//
+// A zero Group is valid, has no limit on the number of active goroutines,
+// and does not cancel on error.
+type Group struct {
+	cancel func()
+	ctx    context.Context // for tracing, nil for a zero Group
+	task   *trace.Task
+
+	wg  sync.WaitGroup
+	sem chan struct{} // one element per active goroutine, if limited
+
+	mu   sync.Mutex
+	errs []error // errors returned by the goroutines, in order
+	done bool    // Wait returned
+}
+
+// WithContext returns a new Group and an associated Context derived from ctx.
+//
+// The derived Context is canceled the first time a function passed to Go
+// returns a non-nil error or panics, or the first time Wait returns,
+// whichever occurs first.
+//
+// The Group is traced as a runtime/trace task of type "errgroup.Group",
+// a subtask of the task of ctx if any, which ends when Wait returns.
+// The derived Context carries the task.
+func WithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, task := trace.NewTask(ctx, "errgroup.Group")
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{cancel: cancel, ctx: ctx, task: task}, ctx
+}
+
+// Wait blocks until all function calls from the Go method have returned,
+// then returns the first non-nil error (if any) from them.
+func (g *Group) Wait() error {
+	errs := g.WaitAll()
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs[0]
+}
+
+// WaitAll blocks until all function calls from the Go method have returned,
+// then returns all the non-nil errors from them, in the order they
+// were returned.
+func (g *Group) WaitAll() []error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel()
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.done {
+		g.done = true
+		if g.task != nil {
+			g.task.End()
+		}
+	}
+	return append([]error(nil), g.errs...)
+}
+
+// Go calls the given function in a new goroutine.
+// It blocks until the new goroutine can be added without the number of
+// active goroutines in the group exceeding the configured limit.
+//
+// The first call to return a non-nil error cancels the group's Context,
+// if the group was created by calling WithContext. The error will be
+// returned by Wait. A panic in f is recovered and returned as a
+// *PanicError.
+func (g *Group) Go(f func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.start(f)
+}
+
+// TryGo calls the given function in a new goroutine only if the number of
+// active goroutines in the group is currently below the configured limit.
+//
+// The return value reports whether the goroutine was started.
+func (g *Group) TryGo(f func() error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.start(f)
+	return true
+}
+
+// SetLimit limits the number of active goroutines in this group to at most n.
+// A negative value indicates no limit.
+//
+// Any subsequent call to the Go method will block until it can add an active
+// goroutine without exceeding the configured limit.
+//
+// The limit must not be modified while any goroutines in the group are active.
+func (g *Group) SetLimit(n int) {
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	if len(g.sem) != 0 {
+		panic(fmt.Errorf("errgroup: modify limit while %v goroutines in the group are still active", len(g.sem)))
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// start runs f in a new goroutine, which holds a slot of g.sem.
+func (g *Group) start(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.done1()
+		if err := g.run(f); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+// run calls f, within a trace region of the group's task,
+// and returns its error, or the panic it recovered from.
+func (g *Group) run(f func() error) (err error) {
+	if g.ctx != nil {
+		defer trace.StartRegion(g.ctx, "errgroup.Go").End()
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return f()
+}
+
+// fail records the error err, and cancels the group's
+// Context if it's the first.
+func (g *Group) fail(err error) {
+	g.mu.Lock()
+	g.errs = append(g.errs, err)
+	first := len(g.errs) == 1
+	g.mu.Unlock()
+	if first && g.cancel != nil {
+		if g.ctx != nil && trace.IsEnabled() {
+			trace.Log(g.ctx, "errgroup", err.Error())
+		}
+		g.cancel()
+	}
+}
+
+// done1 marks the end of a goroutine of the group.
+func (g *Group) done1() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
+
+// A PanicError is the error returned by Wait
+// for a function of the group that panicked.
+type PanicError struct {
+	Value interface{} // the value passed to panic
+	Stack []byte      // the stack of the goroutine when it panicked
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("errgroup: goroutine panicked: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the value passed to panic, if it's an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
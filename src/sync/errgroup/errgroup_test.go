@@ -0,0 +1,257 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errgroup_test
+
+import (
+	"bytes"
+	"context"
+	"debug/trace"
+	"errors"
+	"io"
+	rtrace "runtime/trace"
+	"strings"
+	"sync/atomic"
+	. "sync/errgroup"
+	"testing"
+	"time"
+)
+
+func TestZeroGroup(t *testing.T) {
+	err1 := errors.New("errgroup_test: 1")
+	err2 := errors.New("errgroup_test: 2")
+
+	cases := []struct {
+		errs []error
+	}{
+		{errs: []error{}},
+		{errs: []error{nil}},
+		{errs: []error{err1}},
+		{errs: []error{err1, nil}},
+		{errs: []error{err1, nil, err2}},
+	}
+
+	for _, tc := range cases {
+		g := new(Group)
+
+		var firstErr error
+		for i, err := range tc.errs {
+			err := err
+			g.Go(func() error { return err })
+
+			if firstErr == nil && err != nil {
+				firstErr = err
+			}
+
+			if gErr := g.Wait(); gErr != firstErr {
+				t.Errorf("after %T.Go(func() error { return err }) for err in %v\n"+
+					"g.Wait() = %v; want %v",
+					g, tc.errs[:i+1], gErr, firstErr)
+			}
+		}
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	errDoom := errors.New("group_test: doomed")
+
+	cases := []struct {
+		errs []error
+		want error
+	}{
+		{want: nil},
+		{errs: []error{nil}, want: nil},
+		{errs: []error{errDoom}, want: errDoom},
+		{errs: []error{errDoom, nil}, want: errDoom},
+	}
+
+	for _, tc := range cases {
+		g, ctx := WithContext(context.Background())
+
+		for _, err := range tc.errs {
+			err := err
+			g.Go(func() error { return err })
+		}
+
+		if err := g.Wait(); err != tc.want {
+			t.Errorf("after %T.Go(func() error { return err }) for err in %v\n"+
+				"g.Wait() = %v; want %v",
+				g, tc.errs, err, tc.want)
+		}
+
+		canceled := false
+		select {
+		case <-ctx.Done():
+			canceled = true
+		default:
+		}
+		if !canceled {
+			t.Errorf("after %T.Go(func() error { return err }) for err in %v\n"+
+				"ctx.Done() was not closed",
+				g, tc.errs)
+		}
+	}
+}
+
+func TestCancelOnError(t *testing.T) {
+	errDoom := errors.New("group_test: doomed")
+	g, ctx := WithContext(context.Background())
+	g.Go(func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	g.Go(func() error { return errDoom })
+	if err := g.Wait(); err != errDoom {
+		t.Errorf("g.Wait() = %v; want %v", err, errDoom)
+	}
+}
+
+func TestWaitAll(t *testing.T) {
+	errs := []error{errors.New("errgroup_test: 1"), errors.New("errgroup_test: 2")}
+	var g Group
+	for _, err := range errs {
+		err := err
+		g.Go(func() error { return err })
+		g.Go(func() error { return nil })
+	}
+	got := g.WaitAll()
+	if len(got) != len(errs) {
+		t.Fatalf("g.WaitAll() = %v; want %v in any order", got, errs)
+	}
+	if !(got[0] == errs[0] && got[1] == errs[1] || got[0] == errs[1] && got[1] == errs[0]) {
+		t.Errorf("g.WaitAll() = %v; want %v in any order", got, errs)
+	}
+	if err := g.Wait(); err != got[0] {
+		t.Errorf("g.Wait() = %v; want the first error %v", err, got[0])
+	}
+}
+
+func TestPanic(t *testing.T) {
+	var g Group
+	errPanic := errors.New("errgroup_test: panic")
+	g.Go(func() error { panic(errPanic) })
+	err := g.Wait()
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("g.Wait() = %v; want a *PanicError", err)
+	}
+	if pe.Value != errPanic || !errors.Is(err, errPanic) {
+		t.Errorf("PanicError.Value = %v; want %v", pe.Value, errPanic)
+	}
+	if !strings.Contains(string(pe.Stack), "TestPanic") {
+		t.Errorf("PanicError.Stack doesn't contain the panicking function:\n%s", pe.Stack)
+	}
+}
+
+func TestSetLimit(t *testing.T) {
+	const limit = 3
+	var g Group
+	g.SetLimit(limit)
+	var active, max int32
+	for i := 0; i < 20; i++ {
+		g.Go(func() error {
+			n := atomic.AddInt32(&active, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&active, -1)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if max > limit {
+		t.Errorf("%d goroutines were active at once; want at most %d", max, limit)
+	}
+}
+
+func TestTryGo(t *testing.T) {
+	var g Group
+	g.SetLimit(1)
+	release := make(chan struct{})
+	if !g.TryGo(func() error { <-release; return nil }) {
+		t.Fatal("TryGo failed with no active goroutine")
+	}
+	if g.TryGo(func() error { return nil }) {
+		t.Error("TryGo succeeded while the limit was reached")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("SetLimit didn't panic with an active goroutine")
+			}
+		}()
+		g.SetLimit(2)
+	}()
+	close(release)
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if !g.TryGo(func() error { return nil }) {
+		t.Error("TryGo failed after Wait")
+	}
+	g.Wait()
+}
+
+func TestTrace(t *testing.T) {
+	if rtrace.IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	var buf bytes.Buffer
+	if err := rtrace.Start(&buf); err != nil {
+		t.Fatal(err)
+	}
+	errDoom := errors.New("group_test: doomed")
+	g, _ := WithContext(context.Background())
+	g.Go(func() error { return nil })
+	g.Go(func() error { return errDoom })
+	g.Wait()
+	rtrace.Stop()
+
+	r, err := trace.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		task                     uint64
+		begin, end, log          bool
+		regionBegins, regionEnds int
+	)
+	for {
+		ev, err := r.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch ev.Kind {
+		case trace.EventTaskBegin:
+			if ev.Name == "errgroup.Group" {
+				task, begin = ev.Task, true
+			}
+		case trace.EventTaskEnd:
+			end = end || begin && ev.Task == task
+		case trace.EventRegionBegin:
+			if ev.Task == task && ev.Name == "errgroup.Go" {
+				regionBegins++
+			}
+		case trace.EventRegionEnd:
+			if ev.Task == task && ev.Name == "errgroup.Go" {
+				regionEnds++
+			}
+		case trace.EventLog:
+			log = log || ev.Task == task && ev.Category == "errgroup" && ev.Message == errDoom.Error()
+		}
+	}
+	if !begin || !end || !log || regionBegins != 2 || regionEnds != 2 {
+		t.Errorf("task begin %v, end %v, error log %v, %d region begins and %d ends; want all, and 2 regions",
+			begin, end, log, regionBegins, regionEnds)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errgroup_test
+
+import (
+	"context"
+	"fmt"
+	"sync/errgroup"
+)
+
+// Search runs a search for query in each of the given backends
+// concurrently, and returns the results in the order of the backends.
+// The searches still running are canceled when one fails.
+func ExampleGroup_search() {
+	type Result string
+	type Search func(ctx context.Context, query string) (Result, error)
+	fakeSearch := func(kind string) Search {
+		return func(_ context.Context, query string) (Result, error) {
+			return Result(fmt.Sprintf("%s result for %q", kind, query)), nil
+		}
+	}
+	backends := []Search{fakeSearch("web"), fakeSearch("image"), fakeSearch("video")}
+
+	g, ctx := errgroup.WithContext(context.Background())
+	g.SetLimit(2)
+	results := make([]Result, len(backends))
+	for i, search := range backends {
+		i, search := i, search // https://golang.org/doc/faq#closures_and_goroutines
+		g.Go(func() error {
+			result, err := search(ctx, "golang")
+			if err == nil {
+				results[i] = result
+			}
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, result := range results {
+		fmt.Println(result)
+	}
+
+	// Output:
+	// web result for "golang"
+	// image result for "golang"
+	// video result for "golang"
+}
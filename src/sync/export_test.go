@@ -4,6 +4,11 @@
 
 package sync
 
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
 // Export for testing.
 var Runtime_Semacquire = runtime_Semacquire
 var Runtime_Semrelease = runtime_Semrelease
@@ -55,3 +60,29 @@ func (c *poolChain) PopHead() (interface{}, bool) {
 func (c *poolChain) PopTail() (interface{}, bool) {
 	return c.popTail()
 }
+
+// HoldSlot locks the node of m holding the slot of key, as a writer of key
+// does before it updates the slot. It returns a function that finishes
+// the write, storing value if the slot is empty, and unlocks the node.
+func (m *Map) HoldSlot(key interface{}) (write func(value interface{})) {
+	m.init()
+	hash := runtime_efaceHash(key, m.seed)
+	i := m.rootNode()
+	var slot *atomic.Pointer
+	for shift := hashBits; shift != 0; {
+		shift -= nChildrenLog2
+		slot = &i.children[(hash>>shift)&nChildrenMask]
+		n := (*node)(slot.Load())
+		if n == nil || n.isEntry {
+			break
+		}
+		i = n.indirect()
+	}
+	i.mu.Lock()
+	return func(value interface{}) {
+		if slot.Load() == nil {
+			slot.Store(unsafe.Pointer(newEntry(key, value)))
+		}
+		i.mu.Unlock()
+	}
+}
@@ -17,291 +17,596 @@ import (
 // with separate locking or coordination, for better type safety and to make it
 // easier to maintain other invariants along with the map content.
 //
-// The Map type is optimized for two common use cases: (1) when the entry for a given
-// key is only ever written once but read many times, as in caches that only grow,
-// or (2) when multiple goroutines read, write, and overwrite entries for disjoint
-// sets of keys. In these two cases, use of a Map may significantly reduce lock
-// contention compared to a Go map paired with a separate Mutex or RWMutex.
+// The Map type is optimized for concurrent use: loads take no locks, and
+// stores and deletes of different keys rarely contend with each other, so
+// a Map scales with the number of goroutines using it, for reads and
+// writes alike. The exceptions are the first operation on a zero Map,
+// which initializes it under a lock, and Clear, which waits for the
+// writes in progress and makes new ones wait for it. Keys must be
+// comparable, as for a Go map.
 //
 // The zero Map is empty and ready for use. A Map must not be copied after first use.
 type Map struct {
-	mu Mutex
-
-	// read contains the portion of the map's contents that are safe for
-	// concurrent access (with or without mu held).
-	//
-	// The read field itself is always safe to load, but must only be stored with
-	// mu held.
-	//
-	// Entries stored in read may be updated concurrently without mu, but updating
-	// a previously-expunged entry requires that the entry be copied to the dirty
-	// map and unexpunged with mu held.
-	read atomic.Value // readOnly
-
-	// dirty contains the portion of the map's contents that require mu to be
-	// held. To ensure that the dirty map can be promoted to the read map quickly,
-	// it also includes all of the non-expunged entries in the read map.
+	// The Map is a hash-trie: a tree of indirect nodes, each with
+	// nChildren children indexed by nChildrenLog2 bits of the hash of
+	// the keys, from the most significant bits down. The leaves are
+	// entries, which hold a key and its value, and a list of the other
+	// entries whose keys have the same hash.
 	//
-	// Expunged entries are not stored in the dirty map. An expunged entry in the
-	// clean map must be unexpunged and added to the dirty map before a new value
-	// can be stored to it.
-	//
-	// If the dirty map is nil, the next write to the map will initialize it by
-	// making a shallow copy of the clean map, omitting stale entries.
-	dirty map[interface{}]*entry
+	// Readers walk the tree without locking: all the links between
+	// nodes are atomic pointers, and entries are immutable once
+	// published, except for their overflow links. Writers lock the
+	// indirect node holding the slot they update, and publish a new
+	// entry, or a new subtree of indirect nodes, with a single store
+	// to that slot.
 
-	// misses counts the number of loads since the read map was last updated that
-	// needed to lock mu to determine whether the key was present.
-	//
-	// Once enough misses have occurred to cover the cost of copying the dirty
-	// map, the dirty map will be promoted to the read map (in the unamended
-	// state) and the next store to the map will make a new dirty copy.
-	misses int
+	inited  atomic.Uint32
+	initMu  Mutex
+	clearMu Mutex          // held by Clear
+	root    atomic.Pointer // *indirect
+	seed    uintptr
+}
+
+const (
+	// nChildrenLog2 is the number of bits of the hash consumed
+	// at each level of the tree.
+	nChildrenLog2 = 4
+	nChildren     = 1 << nChildrenLog2
+	nChildrenMask = nChildren - 1
+
+	// hashBits is the number of bits of a hash.
+	hashBits = 8 * unsafe.Sizeof(uintptr(0))
+)
+
+// A node is a node of the tree: an indirect node or an entry.
+type node struct {
+	isEntry bool
+}
+
+func (n *node) entry() *entry {
+	if !n.isEntry {
+		panic("sync: Map: expected entry node")
+	}
+	return (*entry)(unsafe.Pointer(n))
+}
+
+func (n *node) indirect() *indirect {
+	if n.isEntry {
+		panic("sync: Map: expected indirect node")
+	}
+	return (*indirect)(unsafe.Pointer(n))
+}
+
+// An indirect is an internal node of the tree.
+type indirect struct {
+	node
+	dead     atomic.Bool // removed from the tree, or from a cleared Map
+	mu       Mutex       // protects the children, and the entries among them
+	parent   *indirect
+	children [nChildren]atomic.Pointer // *node
+}
+
+func newIndirect(parent *indirect) *indirect {
+	return &indirect{parent: parent}
 }
 
-// readOnly is an immutable struct stored atomically in the Map.read field.
-type readOnly struct {
-	m       map[interface{}]*entry
-	amended bool // true if the dirty map contains some key not in m.
+func (i *indirect) child(j uintptr) *node {
+	return (*node)(i.children[j].Load())
 }
 
-// expunged is an arbitrary pointer that marks entries which have been deleted
-// from the dirty map.
-var expunged = unsafe.Pointer(new(interface{}))
+// empty reports whether i has no children.
+func (i *indirect) empty() bool {
+	for j := range i.children {
+		if i.children[j].Load() != nil {
+			return false
+		}
+	}
+	return true
+}
 
-// An entry is a slot in the map corresponding to a particular key.
+// An entry is a leaf of the tree, which maps key to value.
+// The key and value of an entry never change; a new entry
+// replaces it instead.
 type entry struct {
-	// p points to the interface{} value stored for the entry.
-	//
-	// If p == nil, the entry has been deleted and m.dirty == nil.
-	//
-	// If p == expunged, the entry has been deleted, m.dirty != nil, and the entry
-	// is missing from m.dirty.
-	//
-	// Otherwise, the entry is valid and recorded in m.read.m[key] and, if m.dirty
-	// != nil, in m.dirty[key].
-	//
-	// An entry can be deleted by atomic replacement with nil: when m.dirty is
-	// next created, it will atomically replace nil with expunged and leave
-	// m.dirty[key] unset.
-	//
-	// An entry's associated value can be updated by atomic replacement, provided
-	// p != expunged. If p == expunged, an entry's associated value can be updated
-	// only after first setting m.dirty[key] = e so that lookups using the dirty
-	// map find the entry.
-	p unsafe.Pointer // *interface{}
+	node
+	overflow atomic.Pointer // *entry with the same key hash
+	key      interface{}
+	value    interface{}
+}
+
+func newEntry(key, value interface{}) *entry {
+	return &entry{node: node{isEntry: true}, key: key, value: value}
+}
+
+func (e *entry) next() *entry {
+	return (*entry)(e.overflow.Load())
+}
+
+// runtime_efaceHash returns the hash of i, which is the hash used by
+// Go maps with interface{} keys. It panics if i is not hashable.
+// Implemented in the runtime.
+//go:noescape
+func runtime_efaceHash(i interface{}, seed uintptr) uintptr
+
+func (m *Map) init() {
+	if m.inited.Load() == 0 {
+		m.initSlow()
+	}
+}
+
+func (m *Map) initSlow() {
+	m.initMu.Lock()
+	defer m.initMu.Unlock()
+	if m.inited.Load() != 0 {
+		// Someone got to it while we were waiting.
+		return
+	}
+	m.seed = uintptr(fastrand())
+	m.root.Store(unsafe.Pointer(newIndirect(nil)))
+	m.inited.Store(1)
 }
 
-func newEntry(i interface{}) *entry {
-	return &entry{p: unsafe.Pointer(&i)}
+func (m *Map) rootNode() *indirect {
+	return (*indirect)(m.root.Load())
 }
 
 // Load returns the value stored in the map for a key, or nil if no
 // value is present.
 // The ok result indicates whether value was found in the map.
 func (m *Map) Load(key interface{}) (value interface{}, ok bool) {
-	read, _ := m.read.Load().(readOnly)
-	e, ok := read.m[key]
-	if !ok && read.amended {
-		m.mu.Lock()
-		// Avoid reporting a spurious miss if m.dirty got promoted while we were
-		// blocked on m.mu. (If further loads of the same key will not miss, it's
-		// not worth copying the dirty map for this key.)
-		read, _ = m.read.Load().(readOnly)
-		e, ok = read.m[key]
-		if !ok && read.amended {
-			e, ok = m.dirty[key]
-			// Regardless of whether the entry was present, record a miss: this key
-			// will take the slow path until the dirty map is promoted to the read
-			// map.
-			m.missLocked()
+	m.init()
+	hash := runtime_efaceHash(key, m.seed)
+
+	i := m.rootNode()
+	for shift := hashBits; shift != 0; {
+		shift -= nChildrenLog2
+		n := i.child((hash >> shift) & nChildrenMask)
+		if n == nil {
+			return nil, false
 		}
-		m.mu.Unlock()
+		if n.isEntry {
+			return n.entry().lookup(key)
+		}
+		i = n.indirect()
 	}
-	if !ok {
-		return nil, false
+	panic("sync: Map: ran out of hash bits while iterating")
+}
+
+// lookup returns the value of key in the entries starting at e.
+func (e *entry) lookup(key interface{}) (value interface{}, ok bool) {
+	for ; e != nil; e = e.next() {
+		if e.key == key {
+			return e.value, true
+		}
 	}
-	return e.load()
+	return nil, false
 }
 
-func (e *entry) load() (value interface{}, ok bool) {
-	p := atomic.LoadPointer(&e.p)
-	if p == nil || p == expunged {
-		return nil, false
+// lookupValue is like lookup, but it only finds the entry of key
+// if its value is equal to value.
+func (e *entry) lookupValue(key, value interface{}) bool {
+	for ; e != nil; e = e.next() {
+		if e.key == key && e.value == value {
+			return true
+		}
 	}
-	return *(*interface{})(p), true
+	return false
 }
 
 // Store sets the value for a key.
 func (m *Map) Store(key, value interface{}) {
-	read, _ := m.read.Load().(readOnly)
-	if e, ok := read.m[key]; ok && e.tryStore(&value) {
-		return
+	_, _ = m.Swap(key, value)
+}
+
+// LoadOrStore returns the existing value for the key if present.
+// Otherwise, it stores and returns the given value.
+// The loaded result is true if the value was loaded, false if stored.
+func (m *Map) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	m.init()
+	hash := runtime_efaceHash(key, m.seed)
+
+	var (
+		i     *indirect
+		shift uintptr
+		slot  *atomic.Pointer
+		n     *node
+	)
+	for {
+		// Find the key or the slot to insert it in.
+		i = m.rootNode()
+		shift = hashBits
+		found := false
+		for shift != 0 {
+			shift -= nChildrenLog2
+			slot = &i.children[(hash>>shift)&nChildrenMask]
+			n = (*node)(slot.Load())
+			if n == nil {
+				// An empty slot, where the key can go.
+				found = true
+				break
+			}
+			if n.isEntry {
+				// An entry, which the key can share the slot with,
+				// unless it's the key.
+				if v, ok := n.entry().lookup(key); ok {
+					return v, true
+				}
+				found = true
+				break
+			}
+			i = n.indirect()
+		}
+		if !found {
+			panic("sync: Map: ran out of hash bits while iterating")
+		}
+
+		// Lock i and check that the slot is still as we saw it.
+		i.mu.Lock()
+		n = (*node)(slot.Load())
+		if (n == nil || n.isEntry) && !i.dead.Load() {
+			break
+		}
+		// Start over.
+		m.retry(i)
 	}
+	defer i.mu.Unlock()
 
-	m.mu.Lock()
-	read, _ = m.read.Load().(readOnly)
-	if e, ok := read.m[key]; ok {
-		if e.unexpungeLocked() {
-			// The entry was previously expunged, which implies that there is a
-			// non-nil dirty map and this entry is not in it.
-			m.dirty[key] = e
+	var old *entry
+	if n != nil {
+		old = n.entry()
+		if v, ok := old.lookup(key); ok {
+			// The key was stored while we were locking i.
+			return v, true
 		}
-		e.storeLocked(&value)
-	} else if e, ok := m.dirty[key]; ok {
-		e.storeLocked(&value)
+	}
+	e := newEntry(key, value)
+	if old == nil {
+		slot.Store(unsafe.Pointer(e))
 	} else {
-		if !read.amended {
-			// We're adding the first new key to the dirty map.
-			// Make sure it is allocated and mark the read-only map as incomplete.
-			m.dirtyLocked()
-			m.read.Store(readOnly{m: read.m, amended: true})
-		}
-		m.dirty[key] = newEntry(value)
+		// Publish old and e together, so that no reader
+		// can see the tree without old.
+		slot.Store(unsafe.Pointer(m.expand(old, e, hash, shift, i)))
 	}
-	m.mu.Unlock()
+	return value, false
 }
 
-// tryStore stores a value if the entry has not been expunged.
-//
-// If the entry is expunged, tryStore returns false and leaves the entry
-// unchanged.
-func (e *entry) tryStore(i *interface{}) bool {
+// expand returns the node that replaces the entry old, to also hold the
+// new entry e, whose key has the hash newHash. old is in the slot of i
+// at the level of shift.
+func (m *Map) expand(old, e *entry, newHash, shift uintptr, i *indirect) *node {
+	oldHash := runtime_efaceHash(old.key, m.seed)
+	if oldHash == newHash {
+		// A hash collision: e goes in front of the overflow list.
+		e.overflow.Store(unsafe.Pointer(old))
+		return &e.node
+	}
+	// Add indirect nodes down to the first level at which
+	// the hashes differ.
+	top := newIndirect(i)
+	i = top
 	for {
-		p := atomic.LoadPointer(&e.p)
-		if p == expunged {
-			return false
+		if shift == 0 {
+			panic("sync: Map: ran out of hash bits while inserting")
 		}
-		if atomic.CompareAndSwapPointer(&e.p, p, unsafe.Pointer(i)) {
-			return true
+		shift -= nChildrenLog2
+		oi := (oldHash >> shift) & nChildrenMask
+		ni := (newHash >> shift) & nChildrenMask
+		if oi != ni {
+			i.children[oi].Store(unsafe.Pointer(old))
+			i.children[ni].Store(unsafe.Pointer(e))
+			break
 		}
+		next := newIndirect(i)
+		i.children[oi].Store(unsafe.Pointer(next))
+		i = next
 	}
+	return &top.node
 }
 
-// unexpungeLocked ensures that the entry is not marked as expunged.
-//
-// If the entry was previously expunged, it must be added to the dirty map
-// before m.mu is unlocked.
-func (e *entry) unexpungeLocked() (wasExpunged bool) {
-	return atomic.CompareAndSwapPointer(&e.p, expunged, nil)
-}
+// Swap swaps the value for a key and returns the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *Map) Swap(key, value interface{}) (previous interface{}, loaded bool) {
+	m.init()
+	hash := runtime_efaceHash(key, m.seed)
 
-// storeLocked unconditionally stores a value to the entry.
-//
-// The entry must be known not to be expunged.
-func (e *entry) storeLocked(i *interface{}) {
-	atomic.StorePointer(&e.p, unsafe.Pointer(i))
-}
+	var (
+		i     *indirect
+		shift uintptr
+		slot  *atomic.Pointer
+		n     *node
+	)
+	for {
+		// Find the slot of the key, or to insert it in.
+		i = m.rootNode()
+		shift = hashBits
+		found := false
+		for shift != 0 {
+			shift -= nChildrenLog2
+			slot = &i.children[(hash>>shift)&nChildrenMask]
+			n = (*node)(slot.Load())
+			if n == nil || n.isEntry {
+				found = true
+				break
+			}
+			i = n.indirect()
+		}
+		if !found {
+			panic("sync: Map: ran out of hash bits while iterating")
+		}
 
-// LoadOrStore returns the existing value for the key if present.
-// Otherwise, it stores and returns the given value.
-// The loaded result is true if the value was loaded, false if stored.
-func (m *Map) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
-	// Avoid locking if it's a clean hit.
-	read, _ := m.read.Load().(readOnly)
-	if e, ok := read.m[key]; ok {
-		actual, loaded, ok := e.tryLoadOrStore(value)
-		if ok {
-			return actual, loaded
+		// Lock i and check that the slot is still as we saw it.
+		i.mu.Lock()
+		n = (*node)(slot.Load())
+		if (n == nil || n.isEntry) && !i.dead.Load() {
+			break
 		}
+		// Start over.
+		m.retry(i)
 	}
+	defer i.mu.Unlock()
 
-	m.mu.Lock()
-	read, _ = m.read.Load().(readOnly)
-	if e, ok := read.m[key]; ok {
-		if e.unexpungeLocked() {
-			m.dirty[key] = e
+	var old *entry
+	if n != nil {
+		old = n.entry()
+		if head, previous, ok := old.swap(key, value); ok {
+			slot.Store(unsafe.Pointer(head))
+			return previous, true
 		}
-		actual, loaded, _ = e.tryLoadOrStore(value)
-	} else if e, ok := m.dirty[key]; ok {
-		actual, loaded, _ = e.tryLoadOrStore(value)
-		m.missLocked()
+	}
+	// The key isn't in the map: insert it.
+	e := newEntry(key, value)
+	if old == nil {
+		slot.Store(unsafe.Pointer(e))
 	} else {
-		if !read.amended {
-			// We're adding the first new key to the dirty map.
-			// Make sure it is allocated and mark the read-only map as incomplete.
-			m.dirtyLocked()
-			m.read.Store(readOnly{m: read.m, amended: true})
-		}
-		m.dirty[key] = newEntry(value)
-		actual, loaded = value, false
+		slot.Store(unsafe.Pointer(m.expand(old, e, hash, shift, i)))
 	}
-	m.mu.Unlock()
-
-	return actual, loaded
+	return nil, false
 }
 
-// tryLoadOrStore atomically loads or stores a value if the entry is not
-// expunged.
+// swap replaces the entry of key in the list starting at head with a new
+// entry for value. It returns the new head of the list, and the previous
+// value, if key was in the list.
 //
-// If the entry is expunged, tryLoadOrStore leaves the entry unchanged and
-// returns with ok==false.
-func (e *entry) tryLoadOrStore(i interface{}) (actual interface{}, loaded, ok bool) {
-	p := atomic.LoadPointer(&e.p)
-	if p == expunged {
-		return nil, false, false
+// swap must be called with the lock of the indirect node holding head.
+func (head *entry) swap(key, value interface{}) (*entry, interface{}, bool) {
+	if head.key == key {
+		e := newEntry(head.key, value)
+		e.overflow.Store(head.overflow.Load())
+		return e, head.value, true
 	}
-	if p != nil {
-		return *(*interface{})(p), true, true
+	prev := &head.overflow
+	for e := head.next(); e != nil; e = e.next() {
+		if e.key == key {
+			ne := newEntry(e.key, value)
+			ne.overflow.Store(e.overflow.Load())
+			prev.Store(unsafe.Pointer(ne))
+			return head, e.value, true
+		}
+		prev = &e.overflow
 	}
+	return head, nil, false
+}
 
-	// Copy the interface after the first load to make this method more amenable
-	// to escape analysis: if we hit the "load" path or the entry is expunged, we
-	// shouldn't bother heap-allocating.
-	ic := i
-	for {
-		if atomic.CompareAndSwapPointer(&e.p, nil, unsafe.Pointer(&ic)) {
-			return i, false, true
-		}
-		p = atomic.LoadPointer(&e.p)
-		if p == expunged {
-			return nil, false, false
-		}
-		if p != nil {
-			return *(*interface{})(p), true, true
+// CompareAndSwap swaps the old and new values for key
+// if the value stored in the map is equal to old.
+// The old value must be of a comparable type.
+func (m *Map) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	m.init()
+	hash := runtime_efaceHash(key, m.seed)
+
+	i, _, slot, n := m.find(key, hash, true, old)
+	if i != nil {
+		defer i.mu.Unlock()
+	}
+	if n == nil {
+		return false
+	}
+	head, swapped := n.entry().compareAndSwap(key, old, new)
+	if swapped {
+		slot.Store(unsafe.Pointer(head))
+	}
+	return swapped
+}
+
+// compareAndSwap is like swap, but it only replaces the entry of key
+// if its value is equal to old.
+func (head *entry) compareAndSwap(key, old, new interface{}) (*entry, bool) {
+	if head.key == key && head.value == old {
+		e := newEntry(head.key, new)
+		e.overflow.Store(head.overflow.Load())
+		return e, true
+	}
+	prev := &head.overflow
+	for e := head.next(); e != nil; e = e.next() {
+		if e.key == key && e.value == old {
+			ne := newEntry(e.key, new)
+			ne.overflow.Store(e.overflow.Load())
+			prev.Store(unsafe.Pointer(ne))
+			return head, true
 		}
+		prev = &e.overflow
 	}
+	return head, false
 }
 
 // LoadAndDelete deletes the value for a key, returning the previous value if any.
 // The loaded result reports whether the key was present.
 func (m *Map) LoadAndDelete(key interface{}) (value interface{}, loaded bool) {
-	read, _ := m.read.Load().(readOnly)
-	e, ok := read.m[key]
-	if !ok && read.amended {
-		m.mu.Lock()
-		read, _ = m.read.Load().(readOnly)
-		e, ok = read.m[key]
-		if !ok && read.amended {
-			e, ok = m.dirty[key]
-			delete(m.dirty, key)
-			// Regardless of whether the entry was present, record a miss: this key
-			// will take the slow path until the dirty map is promoted to the read
-			// map.
-			m.missLocked()
+	m.init()
+	hash := runtime_efaceHash(key, m.seed)
+
+	i, shift, slot, n := m.find(key, hash, false, nil)
+	if n == nil {
+		if i != nil {
+			i.mu.Unlock()
 		}
-		m.mu.Unlock()
+		return nil, false
 	}
-	if ok {
-		return e.delete()
+	value, head, loaded := n.entry().loadAndDelete(key)
+	if !loaded {
+		i.mu.Unlock()
+		return nil, false
 	}
-	return nil, false
+	m.replace(i, shift, slot, head, hash)
+	return value, true
+}
+
+// loadAndDelete removes the entry of key from the list starting at head.
+// It returns the value of key, if it was in the list, and the new head
+// of the list, which is nil if the list is now empty.
+//
+// loadAndDelete must be called with the lock of the indirect node holding
+// head.
+func (head *entry) loadAndDelete(key interface{}) (interface{}, *entry, bool) {
+	if head.key == key {
+		return head.value, head.next(), true
+	}
+	prev := &head.overflow
+	for e := head.next(); e != nil; e = e.next() {
+		if e.key == key {
+			prev.Store(e.overflow.Load())
+			return e.value, head, true
+		}
+		prev = &e.overflow
+	}
+	return nil, head, false
 }
 
 // Delete deletes the value for a key.
 func (m *Map) Delete(key interface{}) {
-	m.LoadAndDelete(key)
+	_, _ = m.LoadAndDelete(key)
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+// The old value must be of a comparable type.
+//
+// If there is no current value for key in the map, CompareAndDelete
+// returns false (even if the old value is the nil interface value).
+func (m *Map) CompareAndDelete(key, old interface{}) (deleted bool) {
+	m.init()
+	hash := runtime_efaceHash(key, m.seed)
+
+	i, shift, slot, n := m.find(key, hash, true, old)
+	if n == nil {
+		if i != nil {
+			i.mu.Unlock()
+		}
+		return false
+	}
+	head, deleted := n.entry().compareAndDelete(key, old)
+	if !deleted {
+		i.mu.Unlock()
+		return false
+	}
+	m.replace(i, shift, slot, head, hash)
+	return true
+}
+
+// compareAndDelete is like loadAndDelete, but it only removes the entry
+// of key if its value is equal to old.
+func (head *entry) compareAndDelete(key, old interface{}) (*entry, bool) {
+	if head.key == key && head.value == old {
+		return head.next(), true
+	}
+	prev := &head.overflow
+	for e := head.next(); e != nil; e = e.next() {
+		if e.key == key && e.value == old {
+			prev.Store(e.overflow.Load())
+			return head, true
+		}
+		prev = &e.overflow
+	}
+	return head, false
 }
 
-func (e *entry) delete() (value interface{}, ok bool) {
+// replace stores the list head, with one entry less, into slot of the
+// indirect node i at the level of shift, on behalf of a deletion of a key
+// with the given hash. If the list is now empty, it removes i, and its
+// ancestors, from the tree as long as they are empty. It unlocks i.
+func (m *Map) replace(i *indirect, shift uintptr, slot *atomic.Pointer, head *entry, hash uintptr) {
+	if head != nil {
+		// Other entries remain, so i isn't empty.
+		slot.Store(unsafe.Pointer(head))
+		i.mu.Unlock()
+		return
+	}
+	slot.Store(nil)
+	for i.parent != nil && i.empty() {
+		if shift == hashBits {
+			panic("sync: Map: ran out of hash bits while iterating")
+		}
+		shift += nChildrenLog2
+
+		// Remove i from its parent, and mark it dead, so that
+		// writers waiting for its lock start over.
+		parent := i.parent
+		parent.mu.Lock()
+		i.dead.Store(true)
+		parent.children[(hash>>shift)&nChildrenMask].Store(nil)
+		i.mu.Unlock()
+		i = parent
+	}
+	i.mu.Unlock()
+}
+
+// find returns the entry holding key, whose hash is hash, and the slot of
+// the indirect node i holding it, at the level of shift. If checkValue is
+// set, the entry must also hold value. If the entry is found, i is locked,
+// and the caller must unlock it, and n is nil if the entry was deleted
+// while find was locking i.
+func (m *Map) find(key interface{}, hash uintptr, checkValue bool, value interface{}) (i *indirect, shift uintptr, slot *atomic.Pointer, n *node) {
 	for {
-		p := atomic.LoadPointer(&e.p)
-		if p == nil || p == expunged {
-			return nil, false
+		// Find the key or return if it's not there.
+		i = m.rootNode()
+		shift = hashBits
+		found := false
+		for shift != 0 {
+			shift -= nChildrenLog2
+			slot = &i.children[(hash>>shift)&nChildrenMask]
+			n = (*node)(slot.Load())
+			if n == nil {
+				return nil, 0, nil, nil
+			}
+			if n.isEntry {
+				e := n.entry()
+				if checkValue {
+					found = e.lookupValue(key, value)
+				} else {
+					_, found = e.lookup(key)
+				}
+				if !found {
+					return nil, 0, nil, nil
+				}
+				break
+			}
+			i = n.indirect()
+		}
+		if !found {
+			panic("sync: Map: ran out of hash bits while iterating")
 		}
-		if atomic.CompareAndSwapPointer(&e.p, p, nil) {
-			return *(*interface{})(p), true
+
+		// Lock i and check that the slot is still an entry, or
+		// is now empty.
+		i.mu.Lock()
+		n = (*node)(slot.Load())
+		if !i.dead.Load() && (n == nil || n.isEntry) {
+			return i, shift, slot, n
 		}
+		// Start over.
+		m.retry(i)
+	}
+}
+
+// retry unlocks i, which the caller found dead or changed, so that the
+// caller can start over. If i is dead because of a Clear in progress,
+// retry waits for the Clear to finish rather than let the caller spin
+// on the dead tree.
+func (m *Map) retry(i *indirect) {
+	dead := i.dead.Load()
+	i.mu.Unlock()
+	if dead {
+		m.clearMu.Lock()
+		m.clearMu.Unlock()
 	}
 }
 
@@ -310,75 +615,72 @@ func (e *entry) delete() (value interface{}, ok bool) {
 //
 // Range does not necessarily correspond to any consistent snapshot of the Map's
 // contents: no key will be visited more than once, but if the value for any key
-// is stored or deleted concurrently, Range may reflect any mapping for that key
-// from any point during the Range call.
+// is stored or deleted concurrently (including by f), Range may reflect any
+// mapping for that key from any point during the Range call. Keys that are
+// neither stored nor deleted during the Range call are always visited.
+//
+// Range does not block other methods on the receiver; even f itself may
+// call any method on m.
 //
 // Range may be O(N) with the number of elements in the map even if f returns
 // false after a constant number of calls.
 func (m *Map) Range(f func(key, value interface{}) bool) {
-	// We need to be able to iterate over all of the keys that were already
-	// present at the start of the call to Range.
-	// If read.amended is false, then read.m satisfies that property without
-	// requiring us to hold m.mu for a long time.
-	read, _ := m.read.Load().(readOnly)
-	if read.amended {
-		// m.dirty contains keys not in read.m. Fortunately, Range is already O(N)
-		// (assuming the caller does not break out early), so a call to Range
-		// amortizes an entire copy of the map: we can promote the dirty copy
-		// immediately!
-		m.mu.Lock()
-		read, _ = m.read.Load().(readOnly)
-		if read.amended {
-			read = readOnly{m: m.dirty}
-			m.read.Store(read)
-			m.dirty = nil
-			m.misses = 0
-		}
-		m.mu.Unlock()
-	}
+	m.init()
+	m.iter(m.rootNode(), f)
+}
 
-	for k, e := range read.m {
-		v, ok := e.load()
-		if !ok {
+// iter calls f for the entries of the subtree of i, in the order of the
+// hashes of their keys, and reports whether f always returned true.
+func (m *Map) iter(i *indirect, f func(key, value interface{}) bool) bool {
+	for j := range i.children {
+		n := i.child(uintptr(j))
+		if n == nil {
 			continue
 		}
-		if !f(k, v) {
-			break
+		if !n.isEntry {
+			if !m.iter(n.indirect(), f) {
+				return false
+			}
+			continue
+		}
+		for e := n.entry(); e != nil; e = e.next() {
+			if !f(e.key, e.value) {
+				return false
+			}
 		}
 	}
+	return true
 }
 
-func (m *Map) missLocked() {
-	m.misses++
-	if m.misses < len(m.dirty) {
-		return
-	}
-	m.read.Store(readOnly{m: m.dirty})
-	m.dirty = nil
-	m.misses = 0
+// Clear deletes all the entries, resulting in an empty Map.
+func (m *Map) Clear() {
+	m.init()
+	m.clearMu.Lock()
+	defer m.clearMu.Unlock()
+	// Writers may hold the lock of a node in the old tree, and would
+	// store into it after the root is replaced. Mark every node dead,
+	// under its lock, first: writers that got there earlier finish
+	// before Clear, and those that come later start over and wait for
+	// the new root.
+	old := m.rootNode()
+	old.kill()
+	m.root.Store(unsafe.Pointer(newIndirect(nil)))
 }
 
-func (m *Map) dirtyLocked() {
-	if m.dirty != nil {
-		return
-	}
-
-	read, _ := m.read.Load().(readOnly)
-	m.dirty = make(map[interface{}]*entry, len(read.m))
-	for k, e := range read.m {
-		if !e.tryExpungeLocked() {
-			m.dirty[k] = e
+// kill marks i and the indirect nodes below it dead.
+func (i *indirect) kill() {
+	var children [nChildren]*indirect
+	i.mu.Lock()
+	i.dead.Store(true)
+	for j := range i.children {
+		if n := i.child(uintptr(j)); n != nil && !n.isEntry {
+			children[j] = n.indirect()
 		}
 	}
-}
-
-func (e *entry) tryExpungeLocked() (isExpunged bool) {
-	p := atomic.LoadPointer(&e.p)
-	for p == nil {
-		if atomic.CompareAndSwapPointer(&e.p, nil, expunged) {
-			return true
+	i.mu.Unlock()
+	for _, c := range children {
+		if c != nil {
+			c.kill()
 		}
-		p = atomic.LoadPointer(&e.p)
 	}
-	return p == expunged
 }
@@ -18,7 +18,11 @@ type mapInterface interface {
 	LoadOrStore(key, value interface{}) (actual interface{}, loaded bool)
 	LoadAndDelete(key interface{}) (value interface{}, loaded bool)
 	Delete(interface{})
+	Swap(key, value interface{}) (previous interface{}, loaded bool)
+	CompareAndSwap(key, old, new interface{}) (swapped bool)
+	CompareAndDelete(key, old interface{}) (deleted bool)
 	Range(func(key, value interface{}) (shouldContinue bool))
+	Clear()
 }
 
 // RWMutexMap is an implementation of mapInterface using a sync.RWMutex.
@@ -75,6 +79,37 @@ func (m *RWMutexMap) Delete(key interface{}) {
 	m.mu.Unlock()
 }
 
+func (m *RWMutexMap) Swap(key, value interface{}) (previous interface{}, loaded bool) {
+	m.mu.Lock()
+	if m.dirty == nil {
+		m.dirty = make(map[interface{}]interface{})
+	}
+	previous, loaded = m.dirty[key]
+	m.dirty[key] = value
+	m.mu.Unlock()
+	return
+}
+
+func (m *RWMutexMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if value, ok := m.dirty[key]; !ok || value != old {
+		return false
+	}
+	m.dirty[key] = new
+	return true
+}
+
+func (m *RWMutexMap) CompareAndDelete(key, old interface{}) (deleted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if value, ok := m.dirty[key]; !ok || value != old {
+		return false
+	}
+	delete(m.dirty, key)
+	return true
+}
+
 func (m *RWMutexMap) Range(f func(key, value interface{}) (shouldContinue bool)) {
 	m.mu.RLock()
 	keys := make([]interface{}, 0, len(m.dirty))
@@ -94,6 +129,12 @@ func (m *RWMutexMap) Range(f func(key, value interface{}) (shouldContinue bool))
 	}
 }
 
+func (m *RWMutexMap) Clear() {
+	m.mu.Lock()
+	m.dirty = nil
+	m.mu.Unlock()
+}
+
 // DeepCopyMap is an implementation of mapInterface using a Mutex and
 // atomic.Value.  It makes deep copies of the map on every write to avoid
 // acquiring the Mutex in Load.
@@ -155,6 +196,52 @@ func (m *DeepCopyMap) Delete(key interface{}) {
 	m.mu.Unlock()
 }
 
+func (m *DeepCopyMap) Swap(key, value interface{}) (previous interface{}, loaded bool) {
+	m.mu.Lock()
+	dirty := m.dirty()
+	previous, loaded = dirty[key]
+	dirty[key] = value
+	m.clean.Store(dirty)
+	m.mu.Unlock()
+	return
+}
+
+func (m *DeepCopyMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	clean, _ := m.clean.Load().(map[interface{}]interface{})
+	if previous, ok := clean[key]; !ok || previous != old {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dirty := m.dirty()
+	value, loaded := dirty[key]
+	if loaded && value == old {
+		dirty[key] = new
+		m.clean.Store(dirty)
+		return true
+	}
+	return false
+}
+
+func (m *DeepCopyMap) CompareAndDelete(key, old interface{}) (deleted bool) {
+	clean, _ := m.clean.Load().(map[interface{}]interface{})
+	if previous, ok := clean[key]; !ok || previous != old {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dirty := m.dirty()
+	value, loaded := dirty[key]
+	if loaded && value == old {
+		delete(dirty, key)
+		m.clean.Store(dirty)
+		return true
+	}
+	return false
+}
+
 func (m *DeepCopyMap) Range(f func(key, value interface{}) (shouldContinue bool)) {
 	clean, _ := m.clean.Load().(map[interface{}]interface{})
 	for k, v := range clean {
@@ -164,6 +251,12 @@ func (m *DeepCopyMap) Range(f func(key, value interface{}) (shouldContinue bool)
 	}
 }
 
+func (m *DeepCopyMap) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clean.Store((map[interface{}]interface{})(nil))
+}
+
 func (m *DeepCopyMap) dirty() map[interface{}]interface{} {
 	clean, _ := m.clean.Load().(map[interface{}]interface{})
 	dirty := make(map[interface{}]interface{}, len(clean)+1)
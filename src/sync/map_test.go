@@ -12,19 +12,34 @@ import (
 	"sync/atomic"
 	"testing"
 	"testing/quick"
+	"time"
 )
 
 type mapOp string
 
 const (
-	opLoad          = mapOp("Load")
-	opStore         = mapOp("Store")
-	opLoadOrStore   = mapOp("LoadOrStore")
-	opLoadAndDelete = mapOp("LoadAndDelete")
-	opDelete        = mapOp("Delete")
+	opLoad             = mapOp("Load")
+	opStore            = mapOp("Store")
+	opLoadOrStore      = mapOp("LoadOrStore")
+	opLoadAndDelete    = mapOp("LoadAndDelete")
+	opDelete           = mapOp("Delete")
+	opSwap             = mapOp("Swap")
+	opCompareAndSwap   = mapOp("CompareAndSwap")
+	opCompareAndDelete = mapOp("CompareAndDelete")
+	opClear            = mapOp("Clear")
 )
 
-var mapOps = [...]mapOp{opLoad, opStore, opLoadOrStore, opLoadAndDelete, opDelete}
+var mapOps = [...]mapOp{
+	opLoad,
+	opStore,
+	opLoadOrStore,
+	opLoadAndDelete,
+	opDelete,
+	opSwap,
+	opCompareAndSwap,
+	opCompareAndDelete,
+	opClear,
+}
 
 // mapCall is a quick.Generator for calls on mapInterface.
 type mapCall struct {
@@ -46,6 +61,15 @@ func (c mapCall) apply(m mapInterface) (interface{}, bool) {
 	case opDelete:
 		m.Delete(c.k)
 		return nil, false
+	case opSwap:
+		return m.Swap(c.k, c.v)
+	case opCompareAndSwap:
+		return nil, m.CompareAndSwap(c.k, c.v, c.v.(string)+"'")
+	case opCompareAndDelete:
+		return nil, m.CompareAndDelete(c.k, c.v)
+	case opClear:
+		m.Clear()
+		return nil, false
 	default:
 		panic("invalid mapOp")
 	}
@@ -67,7 +91,7 @@ func randValue(r *rand.Rand) interface{} {
 func (mapCall) Generate(r *rand.Rand, size int) reflect.Value {
 	c := mapCall{op: mapOps[rand.Intn(len(mapOps))], k: randValue(r)}
 	switch c.op {
-	case opStore, opLoadOrStore:
+	case opStore, opLoadOrStore, opSwap, opCompareAndSwap, opCompareAndDelete:
 		c.v = randValue(r)
 	}
 	return reflect.ValueOf(c)
@@ -195,3 +219,247 @@ func TestIssue40999(t *testing.T) {
 		runtime.GC()
 	}
 }
+
+func TestMapManyKeys(t *testing.T) {
+	// Enough keys to build several levels of the trie,
+	// and to remove them when the keys are deleted.
+	const n = 1 << 14
+
+	var m sync.Map
+	for i := 0; i < n; i++ {
+		if _, loaded := m.LoadOrStore(i, i); loaded {
+			t.Fatalf("LoadOrStore(%d) loaded a value from an empty key", i)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if v, ok := m.Load(i); !ok || v != i {
+			t.Fatalf("Load(%d) = %v, %v; want %d, true", i, v, ok, i)
+		}
+	}
+	for i := 0; i < n; i += 2 {
+		if v, ok := m.LoadAndDelete(i); !ok || v != i {
+			t.Fatalf("LoadAndDelete(%d) = %v, %v; want %d, true", i, v, ok, i)
+		}
+	}
+	count := 0
+	m.Range(func(k, v interface{}) bool {
+		if k.(int)%2 == 0 {
+			t.Fatalf("Range visited deleted key %v", k)
+		}
+		count++
+		return true
+	})
+	if count != n/2 {
+		t.Fatalf("Range visited %d keys; want %d", count, n/2)
+	}
+	for i := 1; i < n; i += 2 {
+		if !m.CompareAndDelete(i, i) {
+			t.Fatalf("CompareAndDelete(%d, %d) failed", i, i)
+		}
+	}
+	m.Range(func(k, v interface{}) bool {
+		t.Fatalf("Range visited key %v of an empty map", k)
+		return false
+	})
+}
+
+func TestMapCompareAndSwap(t *testing.T) {
+	var m sync.Map
+	if m.CompareAndSwap("k", nil, 1) {
+		t.Fatal("CompareAndSwap on a missing key succeeded")
+	}
+	if m.CompareAndDelete("k", nil) {
+		t.Fatal("CompareAndDelete on a missing key succeeded")
+	}
+	m.Store("k", 1)
+	if m.CompareAndSwap("k", 2, 3) || m.CompareAndDelete("k", 2) {
+		t.Fatal("CompareAndSwap or CompareAndDelete with the wrong old value succeeded")
+	}
+	if !m.CompareAndSwap("k", 1, 2) {
+		t.Fatal("CompareAndSwap(k, 1, 2) failed")
+	}
+	if v, loaded := m.Swap("k", 3); !loaded || v != 2 {
+		t.Fatalf("Swap(k, 3) = %v, %v; want 2, true", v, loaded)
+	}
+	if !m.CompareAndDelete("k", 3) {
+		t.Fatal("CompareAndDelete(k, 3) failed")
+	}
+	if v, ok := m.Load("k"); ok {
+		t.Fatalf("Load(k) after CompareAndDelete = %v, true; want nil, false", v)
+	}
+
+	// Comparing uncomparable values panics, as ==.
+	m.Store("s", []int{1})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("CompareAndSwap of an uncomparable value didn't panic")
+			}
+		}()
+		m.CompareAndSwap("s", []int{1}, []int{2})
+	}()
+}
+
+func TestMapUnhashableKey(t *testing.T) {
+	var m sync.Map
+	defer func() {
+		if recover() == nil {
+			t.Error("Store of an unhashable key didn't panic")
+		}
+	}()
+	m.Store([]int{1}, 1)
+}
+
+func TestMapClear(t *testing.T) {
+	var m sync.Map
+	m.Clear()
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	m.Clear()
+	m.Range(func(k, v interface{}) bool {
+		t.Fatalf("Range visited key %v after Clear", k)
+		return false
+	})
+	m.Store(1, 1)
+	if v, ok := m.Load(1); !ok || v != 1 {
+		t.Fatalf("Load(1) after Clear and Store = %v, %v; want 1, true", v, ok)
+	}
+}
+
+func TestMapClearWaitsForWriter(t *testing.T) {
+	// A writer that locked a node before Clear replaced the root must
+	// not store into the old tree after Clear returns.
+	var m sync.Map
+	write := m.HoldSlot(1)
+	cleared := make(chan struct{})
+	go func() {
+		m.Clear()
+		close(cleared)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-cleared:
+		t.Fatal("Clear returned while a writer held a node lock")
+	default:
+	}
+	write(1)
+	<-cleared
+	m.Store(2, 2)
+	if v, ok := m.Load(2); !ok || v != 2 {
+		t.Fatalf("Load(2) after Clear and Store = %v, %v; want 2, true", v, ok)
+	}
+}
+
+func TestMapClearConcurrent(t *testing.T) {
+	// A Store that no Clear overlaps must not be lost, while Clear runs
+	// over and over around it.
+	var (
+		m                sync.Map
+		started, cleared atomic.Int64
+		done             = make(chan struct{})
+		clearer          = make(chan struct{})
+	)
+	go func() {
+		defer close(clearer)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			started.Add(1)
+			m.Clear()
+			cleared.Add(1)
+		}
+	}()
+	defer func() {
+		close(done)
+		<-clearer
+	}()
+
+	n := runtime.GOMAXPROCS(0)
+	lost := make(chan int, n)
+	var wg sync.WaitGroup
+	for g := 0; g < n; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				k := g<<20 | i
+				s := started.Load()
+				if cleared.Load() != s {
+					continue // a Clear is in progress
+				}
+				m.Store(k, i)
+				if _, ok := m.Load(k); !ok && started.Load() == s {
+					lost <- k
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(lost)
+	for k := range lost {
+		t.Errorf("Store(%d) was lost without a concurrent Clear", k)
+	}
+}
+
+func TestMapRangeStable(t *testing.T) {
+	// Keys that aren't modified during a Range must be visited exactly
+	// once, even while other keys are inserted and deleted around them.
+	const stable, churn = 1 << 10, 1 << 10
+
+	var m sync.Map
+	for i := 0; i < stable; i++ {
+		m.Store(i, i)
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+	for g := 0; g < runtime.GOMAXPROCS(0); g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(g)))
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				k := stable + r.Intn(churn)
+				if r.Intn(2) == 0 {
+					m.Store(k, k)
+				} else {
+					m.Delete(k)
+				}
+			}
+		}(g)
+	}
+
+	iters := 1 << 8
+	if testing.Short() {
+		iters = 16
+	}
+	for n := 0; n < iters; n++ {
+		seen := make(map[int]bool, stable+churn)
+		m.Range(func(ki, _ interface{}) bool {
+			k := ki.(int)
+			if seen[k] {
+				t.Fatalf("Range visited key %v twice", k)
+			}
+			seen[k] = true
+			return true
+		})
+		for i := 0; i < stable; i++ {
+			if !seen[i] {
+				t.Fatalf("Range didn't visit key %v, which wasn't modified", i)
+			}
+		}
+	}
+}
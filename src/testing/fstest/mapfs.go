@@ -30,6 +30,12 @@ import (
 // Another implication is that opening or reading a directory requires
 // iterating over the entire map, so a MapFS should typically be used with not more
 // than a few hundred entries or directory reads.
+//
+// MapFS also implements the write interfaces of package fs, such as
+// fs.OpenFileFS, fs.MkdirFS, fs.RemoveFS and fs.RenameFS, by editing the map.
+// A file opened for writing writes directly to its MapFile's Data.
+// Symbolic links created by Symlink are stored as files with the ModeSymlink bit
+// set and the link destination as Data; Open does not follow them.
 type MapFS map[string]*MapFile
 
 // A MapFile describes a single file in a MapFS.
@@ -51,7 +57,7 @@ func (fsys MapFS) Open(name string) (fs.File, error) {
 	file := fsys[name]
 	if file != nil && file.Mode&fs.ModeDir == 0 {
 		// Ordinary file
-		return &openMapFile{name, mapFileInfo{path.Base(name), file}, 0}, nil
+		return &openMapFile{name, mapFileInfo{path.Base(name), file}, 0, fs.O_RDONLY}, nil
 	}
 
 	// Directory, possibly synthesized.
@@ -142,6 +148,251 @@ func (fsys MapFS) Sub(dir string) (fs.FS, error) {
 	return fs.Sub(noSub{fsys}, dir)
 }
 
+// stat reports whether name exists in fsys, explicitly or as a
+// synthesized parent directory, and whether it is a directory.
+func (fsys MapFS) stat(name string) (exists, isDir bool) {
+	if name == "." {
+		return true, true
+	}
+	if f := fsys[name]; f != nil {
+		return true, f.Mode&fs.ModeDir != 0
+	}
+	if fsys.hasChildren(name) {
+		return true, true
+	}
+	return false, false
+}
+
+// hasChildren reports whether any name in fsys is inside the directory dir.
+func (fsys MapFS) hasChildren(dir string) bool {
+	prefix := dir + "/"
+	for fname := range fsys {
+		if strings.HasPrefix(fname, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkParent returns an error if the parent directory of name does not exist.
+func (fsys MapFS) checkParent(op, name string) error {
+	exists, isDir := fsys.stat(path.Dir(name))
+	if !exists {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !isDir {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+// OpenFile opens the named file with the specified flag.
+// If the file is created, it has permission bits perm.
+func (fsys MapFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	if flag&(fs.O_WRONLY|fs.O_RDWR|fs.O_APPEND|fs.O_CREATE|fs.O_TRUNC) == 0 {
+		return fsys.Open(name)
+	}
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	file := fsys[name]
+	exists, isDir := fsys.stat(name)
+	switch {
+	case isDir:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	case exists:
+		if flag&(fs.O_CREATE|fs.O_EXCL) == fs.O_CREATE|fs.O_EXCL {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+		}
+		if flag&fs.O_TRUNC != 0 {
+			file.Data = nil
+		}
+	default:
+		if flag&fs.O_CREATE == 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		if err := fsys.checkParent("open", name); err != nil {
+			return nil, err
+		}
+		file = &MapFile{Mode: perm & fs.ModePerm}
+		fsys[name] = file
+	}
+	return &openMapFile{name, mapFileInfo{path.Base(name), file}, 0, flag}, nil
+}
+
+// Mkdir creates a directory with the given permission bits.
+func (fsys MapFS) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if exists, _ := fsys.stat(name); exists {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if err := fsys.checkParent("mkdir", name); err != nil {
+		return err
+	}
+	fsys[name] = &MapFile{Mode: fs.ModeDir | perm&fs.ModePerm}
+	return nil
+}
+
+// MkdirAll creates a directory and any missing parents.
+func (fsys MapFS) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if exists, isDir := fsys.stat(name); exists {
+		if isDir {
+			return nil
+		}
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if dir := path.Dir(name); dir != "." {
+		if err := fsys.MkdirAll(dir, perm); err != nil {
+			return err
+		}
+	}
+	return fsys.Mkdir(name, perm)
+}
+
+// Remove removes a file or empty directory.
+func (fsys MapFS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	exists, isDir := fsys.stat(name)
+	if !exists {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if isDir && fsys.hasChildren(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrExist}
+	}
+	delete(fsys, name)
+	return nil
+}
+
+// RemoveAll removes name and everything inside it.
+func (fsys MapFS) RemoveAll(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrInvalid}
+	}
+	delete(fsys, name)
+	prefix := name + "/"
+	for fname := range fsys {
+		if strings.HasPrefix(fname, prefix) {
+			delete(fsys, fname)
+		}
+	}
+	return nil
+}
+
+// Rename moves oldname, and everything inside it, to newname.
+// An existing file or empty directory at newname is replaced.
+func (fsys MapFS) Rename(oldname, newname string) error {
+	if !fs.ValidPath(oldname) || !fs.ValidPath(newname) || oldname == "." || newname == "." {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrInvalid}
+	}
+	exists, isDir := fsys.stat(oldname)
+	if !exists {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+	if oldname == newname {
+		return nil
+	}
+	if isDir && strings.HasPrefix(newname, oldname+"/") {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrInvalid}
+	}
+	if newExists, newIsDir := fsys.stat(newname); newExists {
+		if isDir != newIsDir || newIsDir && fsys.hasChildren(newname) {
+			return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+		}
+		delete(fsys, newname)
+	} else if err := fsys.checkParent("rename", newname); err != nil {
+		return err
+	}
+	if f := fsys[oldname]; f != nil {
+		delete(fsys, oldname)
+		fsys[newname] = f
+	}
+	if isDir {
+		prefix := oldname + "/"
+		for fname, f := range fsys {
+			if strings.HasPrefix(fname, prefix) {
+				delete(fsys, fname)
+				fsys[newname+"/"+fname[len(prefix):]] = f
+			}
+		}
+	}
+	return nil
+}
+
+// lookup returns the MapFile for name, adding an entry
+// for a synthesized directory if necessary.
+func (fsys MapFS) lookup(op, name string) (*MapFile, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if f := fsys[name]; f != nil {
+		return f, nil
+	}
+	if exists, _ := fsys.stat(name); !exists {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	f := &MapFile{Mode: fs.ModeDir | 0555}
+	fsys[name] = f
+	return f, nil
+}
+
+// Chmod sets the permission bits of name.
+func (fsys MapFS) Chmod(name string, mode fs.FileMode) error {
+	f, err := fsys.lookup("chmod", name)
+	if err != nil {
+		return err
+	}
+	f.Mode = f.Mode&^fs.ModePerm | mode&fs.ModePerm
+	return nil
+}
+
+// Chtimes sets the modification time of name.
+// MapFS does not record access times.
+func (fsys MapFS) Chtimes(name string, atime, mtime time.Time) error {
+	f, err := fsys.lookup("chtimes", name)
+	if err != nil {
+		return err
+	}
+	f.ModTime = mtime
+	return nil
+}
+
+// Symlink creates newname as a symbolic link to oldname.
+func (fsys MapFS) Symlink(oldname, newname string) error {
+	if !fs.ValidPath(newname) {
+		return &fs.PathError{Op: "symlink", Path: newname, Err: fs.ErrInvalid}
+	}
+	if exists, _ := fsys.stat(newname); exists {
+		return &fs.PathError{Op: "symlink", Path: newname, Err: fs.ErrExist}
+	}
+	if err := fsys.checkParent("symlink", newname); err != nil {
+		return err
+	}
+	fsys[newname] = &MapFile{Data: []byte(oldname), Mode: fs.ModeSymlink | 0777}
+	return nil
+}
+
+// Readlink returns the destination of the symbolic link name.
+func (fsys MapFS) Readlink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	f := fsys[name]
+	if f == nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+	if f.Mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return string(f.Data), nil
+}
+
 // A mapFileInfo implements fs.FileInfo and fs.DirEntry for a given map file.
 type mapFileInfo struct {
 	name string
@@ -157,11 +408,13 @@ func (i *mapFileInfo) IsDir() bool                { return i.f.Mode&fs.ModeDir !
 func (i *mapFileInfo) Sys() interface{}           { return i.f.Sys }
 func (i *mapFileInfo) Info() (fs.FileInfo, error) { return i, nil }
 
-// An openMapFile is a regular (non-directory) fs.File open for reading.
+// An openMapFile is a regular (non-directory) fs.File open for reading
+// and, depending on flag, writing.
 type openMapFile struct {
 	path string
 	mapFileInfo
 	offset int64
+	flag   int
 }
 
 func (f *openMapFile) Stat() (fs.FileInfo, error) { return &f.mapFileInfo, nil }
@@ -169,6 +422,9 @@ func (f *openMapFile) Stat() (fs.FileInfo, error) { return &f.mapFileInfo, nil }
 func (f *openMapFile) Close() error { return nil }
 
 func (f *openMapFile) Read(b []byte) (int, error) {
+	if f.flag&fs.O_WRONLY != 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: fs.ErrPermission}
+	}
 	if f.offset >= int64(len(f.f.Data)) {
 		return 0, io.EOF
 	}
@@ -180,6 +436,22 @@ func (f *openMapFile) Read(b []byte) (int, error) {
 	return n, nil
 }
 
+func (f *openMapFile) Write(b []byte) (int, error) {
+	if f.flag&(fs.O_WRONLY|fs.O_RDWR) == 0 {
+		return 0, &fs.PathError{Op: "write", Path: f.path, Err: fs.ErrPermission}
+	}
+	if f.flag&fs.O_APPEND != 0 {
+		f.offset = int64(len(f.f.Data))
+	}
+	end := f.offset + int64(len(b))
+	if end > int64(len(f.f.Data)) {
+		f.f.Data = append(f.f.Data, make([]byte, end-int64(len(f.f.Data)))...)
+	}
+	copy(f.f.Data[f.offset:], b)
+	f.offset = end
+	return len(b), nil
+}
+
 func (f *openMapFile) Seek(offset int64, whence int) (int64, error) {
 	switch whence {
 	case 0:
@@ -5,6 +5,8 @@
 package fstest
 
 import (
+	"io/fs"
+	"strings"
 	"testing"
 )
 
@@ -17,3 +19,54 @@ func TestMapFS(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestMapFSReadOnly(t *testing.T) {
+	// TestFS must not use the write methods of MapFS.
+	m := MapFS{"hello": {Data: []byte("hello, world\n")}}
+	if err := TestFS(m, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 1 || m["hello"] == nil {
+		t.Errorf("after TestFS, m = %v", m)
+	}
+}
+
+func TestMapFSWrite(t *testing.T) {
+	if err := TestWriteFS(MapFS{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A noRemoveFS is a writable MapFS whose Remove reports success
+// without removing anything.
+type noRemoveFS struct {
+	m MapFS
+}
+
+func (f noRemoveFS) Open(name string) (fs.File, error) { return f.m.Open(name) }
+func (f noRemoveFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	return f.m.OpenFile(name, flag, perm)
+}
+func (f noRemoveFS) Mkdir(name string, perm fs.FileMode) error { return f.m.Mkdir(name, perm) }
+func (f noRemoveFS) Remove(name string) error                  { return nil }
+
+func TestWriteFSBroken(t *testing.T) {
+	err := TestWriteFS(noRemoveFS{MapFS{}})
+	if err == nil || !strings.Contains(err.Error(), "Stat after Remove") {
+		t.Fatalf("TestWriteFS of FS with broken Remove: got %v, want error mentioning Stat after Remove", err)
+	}
+}
+
+func TestMapFSWriteSub(t *testing.T) {
+	m := MapFS{"sub": {Mode: fs.ModeDir | 0755}}
+	sub, err := fs.Sub(m, "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := TestWriteFS(sub); err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 1 || m["sub"] == nil {
+		t.Errorf("after TestWriteFS of fs.Sub(m, \"sub\"), m = %v", m)
+	}
+}
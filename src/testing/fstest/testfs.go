@@ -25,6 +25,8 @@ import (
 // As a special case, if no expected files are listed, fsys must be empty.
 // Otherwise, fsys must only contain at least the listed files: it can also contain others.
 // The contents of fsys must not change concurrently with TestFS.
+// TestFS does not modify fsys, even if it implements the write
+// interfaces of package fs; TestWriteFS tests those.
 //
 // If TestFS finds any misbehaviors, it returns an error reporting all of them.
 // The error text spans multiple lines, one per detected misbehavior.
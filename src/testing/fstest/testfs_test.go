@@ -29,3 +29,9 @@ func TestSymlink(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDirFSWrite(t *testing.T) {
+	if err := TestWriteFS(os.DirFS(t.TempDir())); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fstest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// TestWriteFS tests the write operations of a file system implementation.
+// It creates, modifies, renames and removes files and directories using
+// the helpers in package fs, such as fs.WriteFile, fs.MkdirAll and fs.Rename,
+// checks that each operation has the expected effect, and checks the
+// resulting tree with TestFS.
+//
+// The file system must implement fs.OpenFileFS, fs.MkdirFS and fs.RemoveFS.
+// The other write interfaces, such as fs.RenameFS and fs.SymlinkFS,
+// are tested if fsys implements them.
+//
+// The file system must be empty when TestWriteFS is called,
+// and it is left empty if TestWriteFS succeeds.
+// The contents of fsys must not change concurrently with TestWriteFS.
+// These checks are not part of TestFS because they modify the file
+// system, while TestFS is often run on ones that tests must not change,
+// such as an os.DirFS of a testdata directory.
+//
+// If TestWriteFS finds any misbehaviors, it returns an error reporting all of them.
+// The error text spans multiple lines, one per detected misbehavior.
+func TestWriteFS(fsys fs.FS) error {
+	_, ok1 := fsys.(fs.OpenFileFS)
+	_, ok2 := fsys.(fs.MkdirFS)
+	_, ok3 := fsys.(fs.RemoveFS)
+	if !ok1 || !ok2 || !ok3 {
+		return fmt.Errorf("TestWriteFS: %T must implement fs.OpenFileFS, fs.MkdirFS and fs.RemoveFS", fsys)
+	}
+	list, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return fmt.Errorf("TestWriteFS: %v", err)
+	}
+	if len(list) > 0 {
+		return fmt.Errorf("TestWriteFS: file system is not empty: contains %s", list[0].Name())
+	}
+
+	t := fsTester{fsys: fsys}
+	t.checkWrite()
+	if len(t.errText) == 0 {
+		return nil
+	}
+	return errors.New("TestWriteFS found errors:\n" + string(t.errText))
+}
+
+// checkWrite runs the write tests.
+// It stops at the first failure that later steps depend on.
+func (t *fsTester) checkWrite() {
+	fsys := t.fsys
+
+	// Regular files.
+	if err := fs.WriteFile(fsys, "a", []byte("hello"), 0644); err != nil {
+		t.errorf("a: WriteFile: %v", err)
+		return
+	}
+	t.checkContent("a", "hello")
+	if info, err := fs.Stat(fsys, "a"); err != nil {
+		t.errorf("a: Stat: %v", err)
+	} else if !info.Mode().IsRegular() || info.Size() != 5 {
+		t.errorf("a: Stat after WriteFile: mode %v, size %d, want regular file of size 5", info.Mode(), info.Size())
+	}
+	if f, err := fs.OpenFile(fsys, "a", fs.O_WRONLY|fs.O_CREATE|fs.O_EXCL, 0644); !errors.Is(err, fs.ErrExist) {
+		t.errorf("a: OpenFile with O_CREATE|O_EXCL of existing file: %v, want ErrExist", err)
+		if err == nil {
+			f.Close()
+		}
+	}
+	if err := t.writeFile("a", fs.O_WRONLY|fs.O_APPEND, " world"); err != nil {
+		t.errorf("a: write with O_APPEND: %v", err)
+	}
+	t.checkContent("a", "hello world")
+	if err := t.writeFile("a", fs.O_RDWR, "J"); err != nil {
+		t.errorf("a: write with O_RDWR: %v", err)
+	}
+	t.checkContent("a", "Jello world")
+	if err := t.writeFile("a", fs.O_RDONLY, "x"); err == nil {
+		t.errorf("a: write to file opened with O_RDONLY succeeded")
+	}
+	t.checkContent("a", "Jello world")
+	if f, err := fs.OpenFile(fsys, "missing/b", fs.O_WRONLY|fs.O_CREATE, 0644); !errors.Is(err, fs.ErrNotExist) {
+		t.errorf("missing/b: OpenFile with O_CREATE in missing directory: %v, want ErrNotExist", err)
+		if err == nil {
+			f.Close()
+		}
+	}
+	if err := fs.WriteFile(fsys, "t", []byte("truncate me"), 0644); err != nil {
+		t.errorf("t: WriteFile: %v", err)
+	} else if err := t.writeFile("t", fs.O_WRONLY|fs.O_TRUNC, "ok"); err != nil {
+		t.errorf("t: write with O_TRUNC: %v", err)
+	} else {
+		t.checkContent("t", "ok")
+	}
+	if err := fs.Remove(fsys, "t"); err != nil {
+		t.errorf("t: Remove: %v", err)
+	}
+
+	// Directories.
+	if err := fs.Mkdir(fsys, "d", 0755); err != nil {
+		t.errorf("d: Mkdir: %v", err)
+		return
+	}
+	if err := fs.Mkdir(fsys, "d", 0755); !errors.Is(err, fs.ErrExist) {
+		t.errorf("d: Mkdir of existing directory: %v, want ErrExist", err)
+	}
+	if err := fs.Mkdir(fsys, "missing/d", 0755); !errors.Is(err, fs.ErrNotExist) {
+		t.errorf("missing/d: Mkdir in missing directory: %v, want ErrNotExist", err)
+	}
+	if err := fs.MkdirAll(fsys, "d/e/f", 0755); err != nil {
+		t.errorf("d/e/f: MkdirAll: %v", err)
+		return
+	}
+	if err := fs.MkdirAll(fsys, "d/e/f", 0755); err != nil {
+		t.errorf("d/e/f: MkdirAll of existing directory: %v", err)
+	}
+	if err := fs.MkdirAll(fsys, "a/x", 0755); err == nil {
+		t.errorf("a/x: MkdirAll below a file succeeded")
+	}
+	if info, err := fs.Stat(fsys, "d/e/f"); err != nil {
+		t.errorf("d/e/f: Stat: %v", err)
+	} else if !info.IsDir() {
+		t.errorf("d/e/f: Stat after MkdirAll: mode %v, want directory", info.Mode())
+	}
+	if err := fs.WriteFile(fsys, "d/e/b", []byte("bee"), 0644); err != nil {
+		t.errorf("d/e/b: WriteFile: %v", err)
+		return
+	}
+
+	// Renames.
+	if _, ok := fsys.(fs.RenameFS); ok {
+		if err := fs.Rename(fsys, "a", "d/e/c"); err != nil {
+			t.errorf("a: Rename to d/e/c: %v", err)
+			return
+		}
+		if _, err := fs.Stat(fsys, "a"); !errors.Is(err, fs.ErrNotExist) {
+			t.errorf("a: Stat after Rename: %v, want ErrNotExist", err)
+		}
+		if err := fs.Rename(fsys, "d/e/f", "d/g"); err != nil {
+			t.errorf("d/e/f: Rename to d/g: %v", err)
+			return
+		}
+		var pe *fs.PathError
+		if err := fs.Rename(fsys, "missing", "d/h"); !errors.Is(err, fs.ErrNotExist) {
+			t.errorf("missing: Rename: %v, want ErrNotExist", err)
+		} else if !errors.As(err, &pe) {
+			t.errorf("missing: Rename: error has type %T, want *fs.PathError", err)
+		}
+	} else {
+		if err := fs.WriteFile(fsys, "d/e/c", []byte("Jello world"), 0644); err != nil {
+			t.errorf("d/e/c: WriteFile: %v", err)
+			return
+		}
+		if err := fs.Remove(fsys, "a"); err != nil {
+			t.errorf("a: Remove: %v", err)
+		}
+		if err := fs.Mkdir(fsys, "d/g", 0755); err != nil {
+			t.errorf("d/g: Mkdir: %v", err)
+		}
+		if err := fs.Remove(fsys, "d/e/f"); err != nil {
+			t.errorf("d/e/f: Remove: %v", err)
+		}
+	}
+	t.checkContent("d/e/c", "Jello world")
+	if info, err := fs.Stat(fsys, "d/g"); err != nil || !info.IsDir() {
+		t.errorf("d/g: Stat: %v, want directory", err)
+	}
+
+	// Metadata.
+	if _, ok := fsys.(fs.ChmodFS); ok {
+		if err := fs.Chmod(fsys, "d/e/c", 0444); err != nil {
+			t.errorf("d/e/c: Chmod: %v", err)
+		} else if info, err := fs.Stat(fsys, "d/e/c"); err != nil {
+			t.errorf("d/e/c: Stat: %v", err)
+		} else if info.Mode()&0222 != 0 {
+			t.errorf("d/e/c: mode after Chmod(0444) is %v, want read-only", info.Mode())
+		}
+		if err := fs.Chmod(fsys, "d/e/c", 0644); err != nil {
+			t.errorf("d/e/c: Chmod: %v", err)
+		}
+	}
+	if _, ok := fsys.(fs.ChtimesFS); ok {
+		mtime := time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)
+		if err := fs.Chtimes(fsys, "d/e/b", mtime, mtime); err != nil {
+			t.errorf("d/e/b: Chtimes: %v", err)
+		} else if info, err := fs.Stat(fsys, "d/e/b"); err != nil {
+			t.errorf("d/e/b: Stat: %v", err)
+		} else if !info.ModTime().Equal(mtime) {
+			t.errorf("d/e/b: ModTime after Chtimes is %v, want %v", info.ModTime(), mtime)
+		}
+	}
+
+	// Symbolic links.
+	expected := []string{"d/e/b", "d/e/c", "d/g"}
+	if _, ok := fsys.(fs.SymlinkFS); ok {
+		err := fs.Symlink(fsys, "c", "d/e/link")
+		switch {
+		case err == nil:
+			expected = append(expected, "d/e/link")
+			if _, ok := fsys.(fs.ReadlinkFS); ok {
+				if target, err := fs.Readlink(fsys, "d/e/link"); err != nil || target != "c" {
+					t.errorf("d/e/link: Readlink = %q, %v, want %q", target, err, "c")
+				}
+			}
+			if err := fs.Symlink(fsys, "c", "d/e/link"); !errors.Is(err, fs.ErrExist) {
+				t.errorf("d/e/link: Symlink over existing link: %v, want ErrExist", err)
+			}
+		case errors.Is(err, fs.ErrPermission):
+			// Symbolic links may need privileges the caller does not have.
+		default:
+			t.errorf("d/e/link: Symlink: %v", err)
+		}
+	}
+
+	// The read operations must see the result.
+	if err := testFS(fsys, expected...); err != nil {
+		t.errorf("checking file system after writes: %v", err)
+	}
+
+	// Removal.
+	if err := fs.Remove(fsys, "d"); err == nil {
+		t.errorf("d: Remove of non-empty directory succeeded")
+	}
+	if err := fs.Remove(fsys, "d/e/b"); err != nil {
+		t.errorf("d/e/b: Remove: %v", err)
+	}
+	if _, err := fs.Stat(fsys, "d/e/b"); !errors.Is(err, fs.ErrNotExist) {
+		t.errorf("d/e/b: Stat after Remove: %v, want ErrNotExist", err)
+	}
+	if err := fs.Remove(fsys, "d/e/b"); !errors.Is(err, fs.ErrNotExist) {
+		t.errorf("d/e/b: Remove of missing file: %v, want ErrNotExist", err)
+	}
+	if err := fs.RemoveAll(fsys, "d"); err != nil {
+		t.errorf("d: RemoveAll: %v", err)
+	}
+	if _, err := fs.Stat(fsys, "d"); !errors.Is(err, fs.ErrNotExist) {
+		t.errorf("d: Stat after RemoveAll: %v, want ErrNotExist", err)
+	}
+	if err := fs.RemoveAll(fsys, "d"); err != nil {
+		t.errorf("d: RemoveAll of missing directory: %v", err)
+	}
+	if list, err := fs.ReadDir(fsys, "."); err != nil {
+		t.errorf(".: ReadDir: %v", err)
+	} else if len(list) > 0 {
+		t.errorf(".: file system not empty after RemoveAll: contains %s", list[0].Name())
+	}
+}
+
+// writeFile opens name with the given flag and writes data at the start
+// of the file, or at the end if flag includes O_APPEND.
+func (t *fsTester) writeFile(name string, flag int, data string) error {
+	f, err := fs.OpenFile(t.fsys, name, flag, 0644)
+	if err != nil {
+		return err
+	}
+	w, ok := f.(io.Writer)
+	if !ok {
+		f.Close()
+		return fmt.Errorf("OpenFile returned File type %T, not an io.Writer", f)
+	}
+	_, err = w.Write([]byte(data))
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+// checkContent checks that the named file contains want.
+func (t *fsTester) checkContent(name, want string) {
+	data, err := fs.ReadFile(t.fsys, name)
+	if err != nil {
+		t.errorf("%s: ReadFile: %v", name, err)
+		return
+	}
+	if string(data) != want {
+		t.errorf("%s: ReadFile = %q, want %q", name, data, want)
+	}
+}
@@ -12,6 +12,7 @@ package testdeps
 
 import (
 	"bufio"
+	"bytes"
 	"internal/testlog"
 	"io"
 	"regexp"
@@ -50,6 +51,24 @@ func (TestDeps) WriteProfileTo(name string, w io.Writer, debug int) error {
 	return pprof.Lookup(name).WriteTo(w, debug)
 }
 
+// WriteGoroutineLeaks writes the goroutineleak profile to w if any
+// goroutines have leaked, and returns their number.
+func (TestDeps) WriteGoroutineLeaks(w io.Writer) (int, error) {
+	// Writing the profile looks for leaks; Count then
+	// reports the number found.
+	p := pprof.Lookup("goroutineleak")
+	var buf bytes.Buffer
+	if err := p.WriteTo(&buf, 1); err != nil {
+		return 0, err
+	}
+	n := p.Count()
+	if n == 0 {
+		return 0, nil
+	}
+	_, err := buf.WriteTo(w)
+	return n, err
+}
+
 // ImportPath is the import path of the testing binary, set by the generated main function.
 var ImportPath string
 
@@ -274,6 +274,7 @@ func Init() {
 
 	// The failfast flag requests that test execution stop after the first test failure.
 	failFast = flag.Bool("test.failfast", false, "do not start new tests after the first test failure")
+	goroutineLeak = flag.Bool("test.goroutineleak", false, "fail if goroutines are left blocked forever after running the tests")
 
 	// The directory in which to create profile files and the like. When run from
 	// "go test", the binary always runs in the source directory for the package;
@@ -307,6 +308,7 @@ var (
 	// Flags, registered during Init.
 	short                *bool
 	failFast             *bool
+	goroutineLeak        *bool
 	outputDir            *string
 	chatty               *bool
 	count                *uint
@@ -1322,6 +1324,7 @@ func (f matchStringOnly) ImportPath() string                          { return "
 func (f matchStringOnly) StartTestLog(io.Writer)                      {}
 func (f matchStringOnly) StopTestLog() error                          { return errMain }
 func (f matchStringOnly) SetPanicOnExit0(bool)                        {}
+func (f matchStringOnly) WriteGoroutineLeaks(io.Writer) (int, error)  { return 0, errMain }
 
 // Main is an internal function, part of the implementation of the "go test" command.
 // It was exported because it is cross-package and predates "internal" packages.
@@ -1363,6 +1366,7 @@ type testDeps interface {
 	StartTestLog(io.Writer)
 	StopTestLog() error
 	WriteProfileTo(string, io.Writer, int) error
+	WriteGoroutineLeaks(io.Writer) (int, error)
 }
 
 // MainStart is meant for use by tests generated by 'go test'.
@@ -1420,7 +1424,7 @@ func (m *M) Run() (code int) {
 	if !testRan && !exampleRan && *matchBenchmarks == "" {
 		fmt.Fprintln(os.Stderr, "testing: warning: no tests to run")
 	}
-	if !testOk || !exampleOk || !runBenchmarks(m.deps.ImportPath(), m.deps.MatchString, m.benchmarks) || race.Errors() > 0 {
+	if !testOk || !exampleOk || !runBenchmarks(m.deps.ImportPath(), m.deps.MatchString, m.benchmarks) || race.Errors() > 0 || !m.checkGoroutineLeaks() {
 		fmt.Println("FAIL")
 		m.exitCode = 1
 		return
@@ -1431,6 +1435,26 @@ func (m *M) Run() (code int) {
 	return
 }
 
+// checkGoroutineLeaks reports the goroutines left blocked forever
+// by the tests if -test.goroutineleak is set. It returns false if
+// there are any.
+func (m *M) checkGoroutineLeaks() bool {
+	if !*goroutineLeak {
+		return true
+	}
+	var buf bytes.Buffer
+	n, err := m.deps.WriteGoroutineLeaks(&buf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "testing: checking for leaked goroutines: %s\n", err)
+		return false
+	}
+	if n > 0 {
+		fmt.Fprintf(os.Stderr, "testing: %d leaked goroutines\n%s", n, buf.Bytes())
+		return false
+	}
+	return true
+}
+
 func (t *T) report() {
 	if t.parent == nil {
 		return
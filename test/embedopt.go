@@ -0,0 +1,15 @@
+// errorcheck
+
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p
+
+import _ "embed"
+
+//go:embed -compress x.txt // ERROR "options require type embed.FS"
+var x string
+
+//go:embed -modtime y.txt // ERROR "options require type embed.FS"
+var y []byte